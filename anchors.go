@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+var (
+	// anchorLinkRe matches the destination of an inline link or image with
+	// an anchor, such as ](guide.md#install or ](#install.
+	anchorLinkRe = regexp.MustCompile(`\]\(\s*<?([^\s#()<>]*)#([^\s()<>]+)`)
+	// anchorRefRe matches a link reference definition with an anchor.
+	anchorRefRe = regexp.MustCompile(`(?m)^ {0,3}\[[^\]]+\]:\s*<?([^\s#<>]*)#([^\s<>]+)`)
+)
+
+// HeadingRenames pairs the anchors of the headings that were renamed from
+// before to after, old to new. Headings are matched by position, so only
+// documents with the same headings at the same levels count; adding or
+// removing a heading at the same time hides the rename. Anchors that are
+// still there afterwards are left out.
+func (smp *SharedMarkdownProcessor) HeadingRenames(before, after string) map[string]string {
+	old, current := smp.Outline(before), smp.Outline(after)
+	if len(old) != len(current) || len(old) == 0 {
+		return nil
+	}
+	still := map[string]bool{}
+	for i := range old {
+		if old[i].Level != current[i].Level {
+			return nil
+		}
+		still[helpAnchor(current[i].Text)] = true
+	}
+	renames := map[string]string{}
+	for i := range old {
+		from, to := helpAnchor(old[i].Text), helpAnchor(current[i].Text)
+		if from != to && from != "" && to != "" && !still[from] {
+			renames[from] = to
+		}
+	}
+	if len(renames) == 0 {
+		return nil
+	}
+	return renames
+}
+
+// linksTo tells whether dest, the path part of a link in the file from,
+// points at doc. An empty path is a link within from itself.
+func linksTo(dest, from, doc string) bool {
+	if dest == "" {
+		return sameFile(from, doc)
+	}
+	if isURL(dest) {
+		return false
+	}
+	if unescaped, err := url.PathUnescape(dest); err == nil {
+		dest = unescaped
+	}
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(filepath.Dir(from), dest)
+	}
+	return sameFile(dest, doc)
+}
+
+// AnchorLinkUpdates finds the links to the renamed anchors of doc in files,
+// doc among them, as replacements of the old anchor by the new one.
+func AnchorLinkUpdates(doc string, renames map[string]string, files map[string]string) []ReplaceMatch {
+	if len(renames) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var matches []ReplaceMatch
+	for _, name := range names {
+		content := files[name]
+		var found []ReplaceMatch
+		for _, re := range []*regexp.Regexp{anchorLinkRe, anchorRefRe} {
+			for _, loc := range re.FindAllStringSubmatchIndex(content, -1) {
+				to, ok := renames[content[loc[4]:loc[5]]]
+				if !ok || !linksTo(content[loc[2]:loc[3]], name, doc) {
+					continue
+				}
+				start, end := loc[4], loc[5]
+				lineStart := strings.LastIndex(content[:start], "\n") + 1
+				lineEnd := len(content)
+				if i := strings.Index(content[end:], "\n"); i >= 0 {
+					lineEnd = end + i
+				}
+				found = append(found, ReplaceMatch{
+					File:        name,
+					Line:        strings.Count(content[:start], "\n"),
+					Start:       start,
+					End:         end,
+					Replacement: to,
+					Before:      content[lineStart:lineEnd],
+					After:       content[lineStart:start] + to + content[end:lineEnd],
+				})
+			}
+		}
+		sort.Slice(found, func(i, j int) bool { return found[i].Start < found[j].Start })
+		matches = append(matches, found...)
+	}
+	return matches
+}
+
+// offerLinkUpdates lists the links to headings renamed in the saved content
+// of a buffer for review, in the replace overlay.
+func (m *model) offerLinkUpdates(filename, before, after string) {
+	if filename == "" || m.overlay != overlayNone {
+		return
+	}
+	renames := m.mdProcessor.HeadingRenames(before, after)
+	if renames == nil {
+		return
+	}
+	contents := m.replaceSources()
+	matches := AnchorLinkUpdates(filename, renames, contents)
+	if len(matches) == 0 {
+		return
+	}
+	m.replacer = &replacer{links: true, reviewing: true, matches: matches, contents: contents}
+	m.overlay = overlayReplace
+}
+
+// offerLinkUpdates asks whether to update the links to the headings renamed
+// between before and the saved document, here and in the other files of
+// the workspace. It reports whether there were any.
+func (g *GUIApp) offerLinkUpdates(before string) bool {
+	if g.currentFile == "" {
+		return false
+	}
+	renames := g.mdProcessor.HeadingRenames(before, g.document())
+	if renames == nil {
+		return false
+	}
+	contents := map[string]string{}
+	for _, path := range browserFiles() {
+		if sameFile(path, g.currentFile) {
+			continue
+		}
+		if data, err := os.ReadFile(path); err == nil {
+			contents[path] = string(data)
+		}
+	}
+	contents[g.currentFile] = g.document()
+	matches := AnchorLinkUpdates(g.currentFile, renames, contents)
+	if len(matches) == 0 {
+		return false
+	}
+
+	list := container.NewVBox()
+	for i, match := range matches {
+		check := widget.NewCheck(fmt.Sprintf("%s:%d  %s", match.File, match.Line+1, strings.TrimSpace(match.After)), func(on bool) {
+			matches[i].Excluded = !on
+		})
+		check.SetChecked(true)
+		list.Add(check)
+	}
+	scroll := container.NewVScroll(list)
+	scroll.SetMinSize(fyne.NewSize(560, 240))
+	label := widget.NewLabel(fmt.Sprintf("%d links point to renamed headings of %s.", len(matches), filepath.Base(g.currentFile)))
+	dialog.ShowCustomConfirm("Update Links", "Update", "Skip", container.NewBorder(label, nil, nil, nil, scroll), func(update bool) {
+		if !update {
+			return
+		}
+		byFile := map[string][]ReplaceMatch{}
+		for _, match := range matches {
+			byFile[match.File] = append(byFile[match.File], match)
+		}
+		for path, found := range byFile {
+			updated := ApplyReplacements(contents[path], found)
+			if path == g.currentFile {
+				// Left unsaved so the change can still be reviewed
+				g.setDocument(updated)
+				continue
+			}
+			if updated == contents[path] {
+				continue
+			}
+			if err := os.WriteFile(path, []byte(updated), 0644); err != nil {
+				dialog.ShowError(notWritable(path, err), g.window)
+				return
+			}
+		}
+	}, g.window)
+	return true
+}
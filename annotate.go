@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+	"golang.org/x/net/html"
+)
+
+var (
+	trailingSpaceRe = regexp.MustCompile(`[ \t]+\n`)
+	blankLinesRe    = regexp.MustCompile(`\n(?:[ \t]*\n)+`)
+)
+
+// Source is a PDF or HTML file being annotated, as the text of its pages.
+// An HTML file is a single page.
+type Source struct {
+	Path  string
+	Title string
+	Pages []string
+}
+
+// isSourceFile tells whether path is a file that can be annotated.
+func isSourceFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".pdf", ".html", ".htm":
+		return true
+	}
+	return false
+}
+
+// LoadSource reads the text of the PDF or HTML file at path. PDFs are read
+// with pdftotext from poppler, which has to be installed.
+func LoadSource(path string) (*Source, error) {
+	src := &Source{Path: path, Title: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+	if strings.ToLower(filepath.Ext(path)) == ".pdf" {
+		out, err := exec.Command("pdftotext", "-enc", "UTF-8", path, "-").Output()
+		var exitErr *exec.ExitError
+		switch {
+		case errors.Is(err, exec.ErrNotFound):
+			return nil, fmt.Errorf("reading PDF files needs pdftotext, which comes with poppler-utils")
+		case errors.As(err, &exitErr):
+			return nil, fmt.Errorf("error reading %s: %s", filepath.Base(path), strings.TrimSpace(string(exitErr.Stderr)))
+		case err != nil:
+			return nil, fmt.Errorf("error reading %s: %v", filepath.Base(path), err)
+		}
+		// pdftotext ends every page with a form feed
+		src.Pages = strings.Split(strings.TrimSuffix(string(out), "\f"), "\f")
+		return src, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", filepath.Base(path), err)
+	}
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", filepath.Base(path), err)
+	}
+	var text strings.Builder
+	title := htmlText(doc, &text, false)
+	if title != "" {
+		src.Title = title
+	}
+	tidy := trailingSpaceRe.ReplaceAllString(text.String(), "\n")
+	src.Pages = []string{strings.TrimSpace(blankLinesRe.ReplaceAllString(tidy, "\n\n"))}
+	return src, nil
+}
+
+// htmlBlocks are the elements that start a paragraph of their own.
+var htmlBlocks = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true, "header": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "ul": true, "ol": true,
+	"li": true, "blockquote": true, "pre": true, "table": true, "tr": true, "figure": true, "figcaption": true,
+	"dl": true, "dt": true, "dd": true, "hr": true,
+}
+
+// htmlText writes the readable text of n to text, a paragraph to each block,
+// and returns the title of the page when n holds one. Scripts, styles and the
+// head are left out; white space is collapsed outside pre.
+func htmlText(n *html.Node, text *strings.Builder, pre bool) string {
+	title := ""
+	switch n.Type {
+	case html.TextNode:
+		if pre {
+			text.WriteString(n.Data)
+		} else if words := strings.Fields(n.Data); len(words) > 0 {
+			if s := text.String(); s != "" && !strings.HasSuffix(s, "\n") && !strings.HasSuffix(s, " ") &&
+				strings.TrimLeft(n.Data, " \t\r\n") != n.Data {
+				text.WriteString(" ")
+			}
+			text.WriteString(strings.Join(words, " "))
+			if strings.TrimRight(n.Data, " \t\r\n") != n.Data {
+				text.WriteString(" ")
+			}
+		}
+		return ""
+	case html.ElementNode:
+		switch n.Data {
+		case "script", "style", "noscript", "template":
+			return ""
+		case "title":
+			if n.FirstChild != nil {
+				return strings.Join(strings.Fields(n.FirstChild.Data), " ")
+			}
+			return ""
+		case "head":
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if t := htmlText(c, &strings.Builder{}, false); t != "" {
+					title = t
+				}
+			}
+			return title
+		case "br":
+			text.WriteString("\n")
+			return ""
+		case "pre":
+			pre = true
+		}
+	}
+
+	block := n.Type == html.ElementNode && htmlBlocks[n.Data]
+	if block {
+		text.WriteString("\n\n")
+		if n.Data == "li" {
+			text.WriteString("• ")
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if t := htmlText(c, text, pre); t != "" {
+			title = t
+		}
+	}
+	if block {
+		text.WriteString("\n\n")
+	}
+	return title
+}
+
+// notesPath is the notes file that goes with source: paper.pdf has
+// paper.notes.md next to it.
+func notesPath(source string) string {
+	return strings.TrimSuffix(source, filepath.Ext(source)) + ".notes.md"
+}
+
+// newNotes is the start of the notes file for src, saved at notes. The
+// source field of the front matter links the two.
+func newNotes(src *Source, notes string) string {
+	return fmt.Sprintf("---\ntitle: %q\nsource: %q\n---\n\n# Notes on %s\n\n", "Notes on "+src.Title, sourceLink(src.Path, notes), src.Title)
+}
+
+// sourceLink is the path of source as a link in the notes file at notes:
+// relative to it when that is possible, with forward slashes.
+func sourceLink(source, notes string) string {
+	if rel, err := filepath.Rel(filepath.Dir(notes), source); err == nil {
+		source = rel
+	}
+	return filepath.ToSlash(source)
+}
+
+// SourceOf is the file that the notes document content at docPath annotates,
+// named by the source field of its front matter, or "" when it has none that
+// can be annotated.
+func SourceOf(content, docPath string) string {
+	fm := ParseFrontMatter(content)
+	if fm == nil {
+		return ""
+	}
+	source := frontMatterString(fm.Fields["source"])
+	if source == "" || isURL(source) || !isSourceFile(source) {
+		return ""
+	}
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(filepath.Dir(docPath), filepath.FromSlash(source))
+	}
+	return source
+}
+
+// Cite is the markdown that cites page of src in the notes file at notes:
+// a link to the page, below quote as a blockquote when quote is not empty.
+// page counts from 0 and is left out of the link of a single page source.
+func (src *Source) Cite(page int, quote, notes string) string {
+	link := sourceLink(src.Path, notes)
+	label := src.Title
+	if len(src.Pages) > 1 {
+		link += fmt.Sprintf("#page=%d", page+1)
+		label += fmt.Sprintf(", p. %d", page+1)
+	}
+	cite := fmt.Sprintf("[%s](%s)", escapeLinkText(label), strings.ReplaceAll(link, " ", "%20"))
+
+	var paragraphs []string
+	for _, paragraph := range strings.Split(blankLinesRe.ReplaceAllString(strings.TrimSpace(quote), "\n\n"), "\n\n") {
+		if words := strings.Fields(paragraph); len(words) > 0 {
+			paragraphs = append(paragraphs, "> "+strings.Join(words, " "))
+		}
+	}
+	if len(paragraphs) == 0 {
+		return cite
+	}
+	return strings.Join(paragraphs, "\n>\n") + "\n>\n> — " + cite + "\n"
+}
+
+// escapeLinkText keeps brackets in text from ending the text of a link.
+func escapeLinkText(text string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`).Replace(text)
+}
+
+// setupSource builds the pane that shows the source being annotated in
+// place of the preview.
+func (g *GUIApp) setupSource() {
+	g.sourceText = widget.NewMultiLineEntry()
+	g.sourceText.Wrapping = fyne.TextWrapWord
+	// A disabled entry can still be selected and copied from
+	g.sourceText.Disable()
+	g.sourceLabel = widget.NewLabel("")
+	prev := widget.NewButton("◀", func() { g.showSourcePage(g.sourcePage - 1) })
+	next := widget.NewButton("▶", func() { g.showSourcePage(g.sourcePage + 1) })
+	cite := widget.NewButton("Cite", g.citeSource)
+	closeButton := widget.NewButton("Close", g.closeSource)
+	g.sourcePanel = container.NewBorder(
+		container.NewBorder(nil, nil, nil, container.NewHBox(prev, next, cite, closeButton), g.sourceLabel), nil, nil, nil,
+		g.sourceText,
+	)
+}
+
+// annotateFile asks for a PDF or HTML file and opens it beside its notes.
+func (g *GUIApp) annotateFile() {
+	g.confirmDiscard(func() {
+		open := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, g.window)
+				return
+			}
+			if reader == nil {
+				return
+			}
+			reader.Close()
+			g.annotate(uriPath(reader.URI()))
+		}, g.window)
+		open.SetFilter(storage.NewExtensionFileFilter([]string{".pdf", ".html", ".htm"}))
+		g.dialogLocation(open)
+		open.Show()
+	})
+}
+
+// annotate opens the notes file of source in the editor, starting it when
+// there is none yet, and shows source in place of the preview.
+func (g *GUIApp) annotate(source string) {
+	src, err := LoadSource(source)
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+	notes := notesPath(source)
+	if _, err := os.Stat(notes); os.IsNotExist(err) {
+		if err := os.WriteFile(notes, []byte(newNotes(src, notes)), 0644); err != nil {
+			dialog.ShowError(fmt.Errorf("error creating notes: %v", err), g.window)
+			return
+		}
+	}
+	g.openPath(notes, Location{})
+	if g.source == nil {
+		g.openSource(src)
+	}
+}
+
+// followSource shows the source that the document just opened annotates,
+// or the preview again when it annotates none.
+func (g *GUIApp) followSource() {
+	source := SourceOf(g.editor.Text, g.currentFile)
+	if source == "" {
+		g.closeSource()
+		return
+	}
+	if g.source != nil && g.source.Path == source {
+		return
+	}
+	src, err := LoadSource(source)
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		g.closeSource()
+		return
+	}
+	g.openSource(src)
+}
+
+// openSource puts src in place of the preview, at its first page.
+func (g *GUIApp) openSource(src *Source) {
+	g.source = src
+	g.showSourcePage(0)
+	if g.splitPanel.Trailing != g.sourcePanel {
+		g.splitPanel.Trailing = g.sourcePanel
+		g.splitPanel.Refresh()
+	}
+	if g.splitPanel.Offset > 0.75 {
+		g.splitPanel.SetOffset(0.5)
+	}
+}
+
+// closeSource puts the preview back in place of the source.
+func (g *GUIApp) closeSource() {
+	if g.source == nil {
+		return
+	}
+	g.source = nil
+	g.splitPanel.Trailing = g.previewPanel
+	g.splitPanel.Refresh()
+}
+
+func (g *GUIApp) showSourcePage(page int) {
+	if g.source == nil || page < 0 || page >= len(g.source.Pages) {
+		return
+	}
+	g.sourcePage = page
+	g.sourceText.SetText(g.source.Pages[page])
+	label := g.source.Title
+	if len(g.source.Pages) > 1 {
+		label += fmt.Sprintf(" — page %d of %d", page+1, len(g.source.Pages))
+	}
+	g.sourceLabel.SetText(label)
+}
+
+// citeSource cites the page shown, quoting the text selected in it, at the
+// cursor of the notes.
+func (g *GUIApp) citeSource() {
+	if g.source == nil {
+		dialog.ShowInformation("Cite", "Open a PDF or HTML file with File → Annotate first.", g.window)
+		return
+	}
+	cite := g.source.Cite(g.sourcePage, g.sourceText.SelectedText(), g.currentFile)
+	if strings.HasPrefix(cite, ">") && g.editor.CursorColumn > 0 {
+		// A quote starts a paragraph of its own
+		cite = "\n\n" + cite
+	}
+	g.insertAtCursor(cite)
+	g.window.Canvas().Focus(g.editor)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	draftStartRe  = regexp.MustCompile(`(?i)^\s*<!--\s*draft\s*-->\s*$`)
+	draftEndRe    = regexp.MustCompile(`(?i)^\s*<!--\s*/draft\s*-->\s*$`)
+	todoCalloutRe = regexp.MustCompile(`(?i)^\s*>\s*\[!todo\]`)
+	todoLineRe    = regexp.MustCompile(`^\s*TODO:`)
+)
+
+// StripAnnotations leaves out what is meant for the author only: HTML
+// comments, sections between <!-- draft --> and <!-- /draft --> lines, TODO
+// callouts (> [!TODO] blockquotes) and paragraphs starting with "TODO:".
+// Code blocks and the front matter are kept as they are. A line that held
+// nothing but a comment goes with it, so that no paragraph is split, and
+// blank lines in a row are merged.
+func StripAnnotations(content string) string {
+	head := ""
+	if fm := ParseFrontMatter(content); fm != nil {
+		head, content = content[:fm.End], content[fm.End:]
+	}
+
+	var out []string
+	fence := ""
+	inDraft, inComment, inCallout, inTodo := false, false, false, false
+	for _, line := range strings.Split(content, "\n") {
+		if inDraft {
+			inDraft = !draftEndRe.MatchString(line)
+			continue
+		}
+		if !inComment {
+			if matches := fenceRe.FindStringSubmatch(line); matches != nil && (fence == "" || matches[1] == fence) {
+				if fence == "" {
+					fence = matches[1]
+				} else {
+					fence = ""
+				}
+				out = append(out, line)
+				continue
+			}
+			if fence != "" {
+				out = append(out, line)
+				continue
+			}
+		}
+
+		if inCallout {
+			if strings.HasPrefix(strings.TrimSpace(line), ">") {
+				continue
+			}
+			inCallout = false
+		}
+		if inTodo {
+			if strings.TrimSpace(line) != "" {
+				continue
+			}
+			inTodo = false
+		}
+		if !inComment {
+			switch {
+			case draftStartRe.MatchString(line):
+				inDraft = true
+				continue
+			case todoCalloutRe.MatchString(line):
+				inCallout = true
+				continue
+			case todoLineRe.MatchString(line):
+				inTodo = true
+				continue
+			}
+		}
+
+		kept, stripped := stripComments(line, &inComment)
+		if stripped && strings.TrimSpace(kept) == "" {
+			continue
+		}
+		// What was left out often leaves blank lines on both sides
+		if n := len(out); kept == "" && n > 0 && out[n-1] == "" {
+			continue
+		}
+		out = append(out, kept)
+	}
+	return head + strings.Join(out, "\n")
+}
+
+// stripComments removes the HTML comments from line. inComment tells
+// whether a comment is open at the start of line, and is updated for the
+// next one. It reports whether anything was removed.
+func stripComments(line string, inComment *bool) (string, bool) {
+	var kept strings.Builder
+	stripped := *inComment
+	rest := line
+	for rest != "" {
+		if *inComment {
+			stripped = true
+			end := strings.Index(rest, "-->")
+			if end < 0 {
+				return strings.TrimRight(kept.String(), " \t"), true
+			}
+			rest = rest[end+len("-->"):]
+			*inComment = false
+			continue
+		}
+		start := strings.Index(rest, "<!--")
+		if start < 0 {
+			kept.WriteString(rest)
+			break
+		}
+		kept.WriteString(rest[:start])
+		rest = rest[start+len("<!--"):]
+		*inComment, stripped = true, true
+	}
+	if stripped {
+		return strings.TrimRight(kept.String(), " \t"), true
+	}
+	return kept.String(), false
+}
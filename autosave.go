@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// AutosavePath is where the recovery copy of docPath is kept, in the state
+// directory. Untitled documents are not autosaved.
+func AutosavePath(docPath string) string {
+	return recoveryPath(docPath, ".autosave")
+}
+
+func WriteAutosave(docPath, content string) error {
+	path := AutosavePath(docPath)
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error autosaving: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("error autosaving: %v", err)
+	}
+	return nil
+}
+
+// RemoveAutosave drops the recovery copy once the document is saved or its
+// changes were deliberately discarded.
+func RemoveAutosave(docPath string) {
+	path := AutosavePath(docPath)
+	if path == "" {
+		return
+	}
+	removeSwap(docPath)
+	os.Remove(path)
+}
+
+// Recovery returns the autosaved content of docPath when it holds changes
+// that never made it into the document. A swap file left by a crash is more
+// recent than the autosave copy, so it goes first.
+func Recovery(docPath string) (string, time.Time, bool) {
+	path := AutosavePath(docPath)
+	if path == "" {
+		return "", time.Time{}, false
+	}
+	migrateRecovery(docPath)
+	if swap, err := ReadSwap(docPath); err == nil && !swap.Live() {
+		current, _ := os.ReadFile(docPath)
+		if swap.Content != string(current) {
+			return swap.Content, swap.ModTime, true
+		}
+		os.Remove(SwapPath(docPath))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	recovered, err := os.ReadFile(path)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	current, _ := os.ReadFile(docPath)
+	if string(recovered) == string(current) {
+		RemoveAutosave(docPath)
+		return "", time.Time{}, false
+	}
+	return string(recovered), info.ModTime(), true
+}
+
+func (m model) scheduleAutosave() tea.Cmd {
+	if m.autosave <= 0 {
+		return nil
+	}
+	return tea.Tick(m.autosave, func(time.Time) tea.Msg {
+		return autosaveMsg{}
+	})
+}
+
+// writeAutosaves keeps a recovery copy of every buffer with unsaved changes.
+// Read-only buffers are left out, as the recovery copy belongs to the
+// instance holding the lock.
+func (m *model) writeAutosaves() {
+	m.stashBuffer()
+	for _, b := range m.buffers {
+		if !b.dirty() || b.readOnly {
+			continue
+		}
+		if err := WriteAutosave(b.filename, b.document()); err != nil {
+			m.status = err.Error()
+		}
+	}
+}
+
+// offerRecovery asks whether to restore the autosaved copy of the active
+// buffer when one was left behind.
+func (m *model) offerRecovery() {
+	text, modTime, ok := Recovery(m.filename)
+	if !ok {
+		return
+	}
+	m.recovery = text
+	m.recoveredAt = modTime
+	m.overlay = overlayRecover
+}
+
+func (m *model) updateRecover(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "r", "y", "enter":
+		// The restored text stays unsaved until the next ctrl+s
+		m.narrow = nil
+		m.textarea.SetValue(m.recovery)
+		m.content = m.recovery
+		if m.mode != editMode {
+			m.refreshPreview()
+		}
+		m.status = "Restored unsaved changes"
+	case "d", "n", "esc":
+		RemoveAutosave(m.filename)
+		m.status = "Discarded recovered changes"
+	default:
+		return
+	}
+	m.overlay = overlayNone
+	m.recovery = ""
+}
+
+func (m model) recoverView() string {
+	body := lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render("Recover Unsaved Changes"),
+		"",
+		fmt.Sprintf("%s has changes from %s that were never saved.", filepath.Base(m.filename), m.recoveredAt.Format("Jan 2 15:04")),
+		"",
+		helpStyle.Render("r: restore • d: discard"),
+	)
+	return pickerStyle.Render(body)
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxBrowserFiles keeps the file browser responsive when started from a
+// large directory tree.
+const maxBrowserFiles = 2000
+
+// buffer is a document open in the terminal app. The active buffer lives in
+// the model fields; its slot in model.buffers is refreshed on every switch.
+// A fresh buffer was read but never shown; it goes to loc when it is.
+type buffer struct {
+	filename  string
+	textarea  textarea.Model
+	saved     string
+	history   *History
+	jumps     JumpList
+	twin      *textarea.Model
+	twinFirst bool
+	fresh     bool
+	loc       Location
+	narrow    *Narrowing
+	readOnly  bool
+}
+
+func (b buffer) dirty() bool {
+	return b.document() != b.saved
+}
+
+func (b buffer) name() string {
+	if b.filename == "" {
+		return "untitled.md"
+	}
+	return filepath.Base(b.filename)
+}
+
+var (
+	tabStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#626262")).
+			Padding(0, 1)
+
+	activeTabStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("#FAFAFA")).
+			Background(lipgloss.Color("#04B575")).
+			Padding(0, 1)
+)
+
+func newEditor() textarea.Model {
+	ta := textarea.New()
+	ta.Placeholder = "Start writing your markdown ..."
+	ta.Focus()
+	return ta
+}
+
+func (m *model) stashBuffer() {
+	m.buffers[m.active] = buffer{filename: m.filename, textarea: m.textarea, saved: m.saved, history: m.history, jumps: m.jumps,
+		twin: m.twin, twinFirst: m.twinFirst, narrow: m.narrow, readOnly: m.readOnly}
+}
+
+// loadBuffer makes buffer i the active one, picking up the config of its
+// project directory.
+func (m *model) loadBuffer(i int) {
+	b := m.buffers[i]
+	m.active = i
+	m.filename = b.filename
+	m.textarea = b.textarea
+	m.saved = b.saved
+	m.history = b.history
+	m.jumps = b.jumps
+	m.twin, m.twinFirst = b.twin, b.twinFirst
+	m.narrow = b.narrow
+	m.readOnly = b.readOnly
+	m.content = m.textarea.Value()
+	m.taskFocus = -1
+	m.previewLine = -1
+	m.clearSelection()
+
+	cfg, err := LoadConfigFor(m.filename)
+	if err != nil {
+		m.status = err.Error()
+	}
+	m.mdProcessor = cfg.Processor()
+	m.imageOpts = cfg.Images
+	m.linter = cfg.Linter()
+	m.links = cfg.Links
+	m.positions = cfg.Positions
+	m.untitled = cfg.Untitled
+	m.secrets = cfg.Secrets
+	m.split = cfg.Split
+
+	if b.fresh {
+		m.buffers[i].fresh = false
+		m.restorePosition()
+		m.goTo(b.loc)
+		m.hookOpen = m.filename
+		if m.lockBuffer() {
+			m.offerRecovery()
+		}
+	}
+
+	m.textarea.Focus()
+	m.layout()
+	if m.mode != editMode {
+		m.refreshPreview()
+	}
+}
+
+func (m *model) switchBuffer(i int) {
+	if i == m.active || i < 0 || i >= len(m.buffers) {
+		return
+	}
+	m.savePosition()
+	m.stashBuffer()
+	m.loadBuffer(i)
+}
+
+// openBuffer switches to path when it is already open and otherwise reads
+// it into a new buffer.
+func (m *model) openBuffer(path string) {
+	m.savePosition()
+	m.stashBuffer()
+	for i, b := range m.buffers {
+		if b.filename != "" && sameFile(b.filename, path) {
+			m.loadBuffer(i)
+			return
+		}
+	}
+
+	if err := m.addBuffer(path, Location{}); err != nil {
+		m.status = errorMessage(err)
+		return
+	}
+	m.status = fmt.Sprintf("Opened %s", path)
+	m.loadBuffer(len(m.buffers) - 1)
+}
+
+// addBuffer reads path into a fresh buffer behind the others without
+// switching to it. A file that does not exist yet starts out empty.
+func (m *model) addBuffer(path string, loc Location) error {
+	content, err := readDocument(path)
+	if err != nil && !os.IsNotExist(err) {
+		if errors.Is(err, ErrEncodingUnsupported) {
+			return err
+		}
+		return fmt.Errorf("error opening file: %v", err)
+	}
+	ta := newEditor()
+	ta.SetValue(content)
+	m.buffers = append(m.buffers, buffer{filename: path, textarea: ta, saved: content, history: NewHistory(ta.Value()), fresh: true, loc: loc})
+	if m.watcher != nil {
+		if err := m.watcher.Watch(path); err != nil {
+			m.status = err.Error()
+		}
+	}
+	return nil
+}
+
+// openFiles adds the files named on the command line after the first one as
+// buffers, leaving the first one active.
+func (m *model) openFiles(paths []string, locs []Location) {
+	for i, path := range paths {
+		if err := m.addBuffer(path, locs[i]); err != nil {
+			m.status = errorMessage(err)
+		}
+	}
+	if len(m.buffers) > 1 && m.status == "" {
+		m.status = fmt.Sprintf("Opened %d files", len(m.buffers))
+	}
+}
+
+func sameFile(a, b string) bool {
+	absA, errA := normalizePath(a)
+	absB, errB := normalizePath(b)
+	if runtime.GOOS == "windows" {
+		return errA == nil && errB == nil && strings.EqualFold(absA, absB)
+	}
+	return errA == nil && errB == nil && absA == absB
+}
+
+// closeBuffer drops the active buffer. The last buffer always stays open.
+func (m model) closeBuffer() (tea.Model, tea.Cmd) {
+	if len(m.buffers) == 1 {
+		m.status = "Cannot close the last buffer"
+		return m, nil
+	}
+	m.savePosition()
+	if m.watcher != nil {
+		m.watcher.Unwatch(m.filename)
+	}
+	Unlock(m.filename)
+	m.buffers = append(m.buffers[:m.active], m.buffers[m.active+1:]...)
+	m.loadBuffer(min(m.active, len(m.buffers)-1))
+	return m, nil
+}
+
+// quitAll asks about every buffer with unsaved changes in turn before
+// quitting.
+func quitAll(m model) (tea.Model, tea.Cmd) {
+	m.savePosition()
+	m.stashBuffer()
+	for i, b := range m.buffers {
+		if !b.dirty() {
+			continue
+		}
+		m.switchBuffer(i)
+		return m.confirmDiscard(func(m model) (tea.Model, tea.Cmd) {
+			// Saved or discarded, either way this buffer is settled
+			m.saved = m.document()
+			return quitAll(m)
+		})
+	}
+	// Everything is saved or was discarded on purpose
+	for _, b := range m.buffers {
+		RemoveAutosave(b.filename)
+		Unlock(b.filename)
+	}
+	if m.listening != nil {
+		m.listening.Cancel()
+	}
+	return m, tea.Quit
+}
+
+func (m model) tabsView() string {
+	var tabs []string
+	for i, b := range m.buffers {
+		if i == m.active {
+			b = buffer{filename: m.filename, textarea: m.textarea, saved: m.saved}
+		}
+		label := fmt.Sprintf("%d %s", i+1, b.name())
+		if b.dirty() {
+			label += " *"
+		}
+		if i == m.active {
+			tabs = append(tabs, activeTabStyle.Render(label))
+		} else {
+			tabs = append(tabs, tabStyle.Render(label))
+		}
+	}
+	return lipgloss.NewStyle().MaxWidth(m.width).Render(lipgloss.JoinHorizontal(lipgloss.Top, tabs...))
+}
+
+// browserFiles lists the markdown and org files below the working directory,
+// skipping hidden directories.
+func browserFiles() []string {
+	var files []string
+	filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != "." && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".md", ".markdown", ".org":
+			files = append(files, path)
+		}
+		if len(files) >= maxBrowserFiles {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return files
+}
+
+func (m *model) openBrowser() {
+	files := browserFiles()
+	if len(files) == 0 {
+		m.status = "No markdown files in this directory"
+		return
+	}
+
+	items := make([]pickerItem, len(files))
+	for i, path := range files {
+		dir := filepath.Dir(path)
+		depth := 0
+		if dir != "." {
+			depth = strings.Count(filepath.ToSlash(dir), "/") + 1
+		}
+		items[i] = pickerItem{
+			title:  strings.Repeat("  ", depth) + filepath.Base(path),
+			detail: dir,
+			index:  i,
+		}
+		if dir == "." {
+			items[i].detail = ""
+		}
+	}
+	m.browserFiles = files
+	m.overlay = overlayFiles
+	m.picker = newPicker("Open File", items)
+	m.picker.hint = "enter: open • ctrl+d: trash • ctrl+r: rename • ctrl+t: show trash"
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The size of the day cells of a drawn calendar, and of the lines listing
+// what is on the days.
+const (
+	calendarCellWidth  = 44
+	calendarCellHeight = 32
+	calendarLineHeight = 18
+)
+
+// dueTaskIndexAge is how long the task index is used before the workspace
+// is looked through again.
+const dueTaskIndexAge = 5 * time.Second
+
+var (
+	// dueDateRe finds the due date of a task: due:2025-03-14, @due(2025-03-14)
+	// or 📅 2025-03-14 as the Tasks plugin of Obsidian writes it.
+	dueDateRe = regexp.MustCompile(`(?:\bdue:\s*|@due\(|📅\s*)(\d{4}-\d{2}-\d{2})\)?`)
+
+	calendarLineRe = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})(?:\s*(?:-|–|\.\.)\s*(\d{4}-\d{2}-\d{2}))?(?:\s*:?\s+(\S.*))?$`)
+)
+
+// DueTask is a task list item with a due date in a file of the workspace.
+type DueTask struct {
+	File string
+	Line int
+	Text string
+	Due  time.Time
+	Done bool
+}
+
+// dueTaskFile is the due tasks of a file as of when it was last changed.
+type dueTaskFile struct {
+	modTime time.Time
+	size    int64
+	tasks   []DueTask
+}
+
+// dueTaskIndex keeps the due tasks of the workspace. Files are only read
+// again when they change.
+var dueTaskIndex struct {
+	sync.Mutex
+	files   map[string]dueTaskFile
+	updated time.Time
+	tasks   []DueTask
+}
+
+// WorkspaceDueTasks lists the tasks with a due date in the markdown and org
+// files of the working directory and below, by due date. The date is
+// written in the task as due:2025-03-14, @due(2025-03-14) or 📅 2025-03-14.
+func WorkspaceDueTasks(smp *SharedMarkdownProcessor) []DueTask {
+	dueTaskIndex.Lock()
+	defer dueTaskIndex.Unlock()
+	if time.Since(dueTaskIndex.updated) < dueTaskIndexAge {
+		return dueTaskIndex.tasks
+	}
+	files := map[string]dueTaskFile{}
+	var tasks []DueTask
+	for _, path := range browserFiles() {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		file, ok := dueTaskIndex.files[path]
+		if !ok || !file.modTime.Equal(info.ModTime()) || file.size != info.Size() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			file = dueTaskFile{modTime: info.ModTime(), size: info.Size(), tasks: dueTasks(smp, path, string(data))}
+		}
+		files[path] = file
+		tasks = append(tasks, file.tasks...)
+	}
+	sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].Due.Before(tasks[j].Due) })
+	dueTaskIndex.files, dueTaskIndex.tasks, dueTaskIndex.updated = files, tasks, time.Now()
+	return tasks
+}
+
+// dueTasks is the tasks of content, the file at path, that have a due date.
+func dueTasks(smp *SharedMarkdownProcessor, path, content string) []DueTask {
+	var tasks []DueTask
+	for _, item := range smp.TaskItems(content) {
+		m := dueDateRe.FindStringSubmatchIndex(item.Text)
+		if m == nil {
+			continue
+		}
+		due, err := time.Parse("2006-01-02", item.Text[m[2]:m[3]])
+		if err != nil {
+			continue
+		}
+		text := strings.Join(strings.Fields(item.Text[:m[0]]+" "+item.Text[m[1]:]), " ")
+		tasks = append(tasks, DueTask{File: path, Line: item.Line, Text: text, Due: due, Done: item.Checked})
+	}
+	return tasks
+}
+
+// CalendarEvent is a day or span of days marked on a calendar.
+type CalendarEvent struct {
+	Start, End time.Time // End is the last day
+	Text       string
+	Task       bool
+	Done       bool
+}
+
+// Calendar is the content of a calendar block: a month with marked days.
+type Calendar struct {
+	Title  string
+	Month  time.Time
+	Today  time.Time
+	Events []CalendarEvent
+}
+
+// ParseCalendar reads a calendar block of title, month and tasks lines and
+// lines of a date, or two for a span, and an optional note:
+//
+//	month 2025-03
+//	2025-03-14 Release
+//	2025-03-20 - 2025-03-22 Conference
+//	tasks
+//
+// The month is that of the first date when it is not given, or else that of
+// now. tasks marks the due dates of the workspace's tasks, from tasks.
+func ParseCalendar(code string, now time.Time, tasks func() []DueTask) (*Calendar, error) {
+	c := &Calendar{Today: now}
+	withTasks := false
+	for n, line := range strings.Split(code, "\n") {
+		line = strings.TrimSpace(line)
+		keyword, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+		switch {
+		case line == "":
+			continue
+		case keyword == "title":
+			c.Title = rest
+			continue
+		case keyword == "month":
+			month, err := time.Parse("2006-01", rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %q is not a month like 2025-03", n+1, rest)
+			}
+			c.Month = month
+			continue
+		case line == "tasks":
+			withTasks = true
+			continue
+		}
+
+		m := calendarLineRe.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("line %d: %q is not a date", n+1, line)
+		}
+		start, err := time.Parse("2006-01-02", m[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %q is not a date", n+1, m[1])
+		}
+		event := CalendarEvent{Start: start, End: start, Text: m[3]}
+		if m[2] != "" {
+			if event.End, err = time.Parse("2006-01-02", m[2]); err != nil {
+				return nil, fmt.Errorf("line %d: %q is not a date", n+1, m[2])
+			}
+			if event.End.Before(start) {
+				return nil, fmt.Errorf("line %d: %s ends before it starts", n+1, line)
+			}
+		}
+		c.Events = append(c.Events, event)
+	}
+
+	if c.Month.IsZero() {
+		c.Month = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if len(c.Events) > 0 {
+			c.Month = time.Date(c.Events[0].Start.Year(), c.Events[0].Start.Month(), 1, 0, 0, 0, 0, time.UTC)
+		}
+	}
+	if withTasks && tasks != nil {
+		for _, task := range tasks() {
+			c.Events = append(c.Events, CalendarEvent{Start: task.Due, End: task.Due, Text: task.Text, Task: true, Done: task.Done})
+		}
+	}
+
+	// Only what falls in the month is shown
+	last := c.Month.AddDate(0, 1, -1)
+	events := c.Events[:0]
+	for _, event := range c.Events {
+		if !event.End.Before(c.Month) && !event.Start.After(last) {
+			events = append(events, event)
+		}
+	}
+	c.Events = events
+	sort.SliceStable(c.Events, func(i, j int) bool { return c.Events[i].Start.Before(c.Events[j].Start) })
+	return c, nil
+}
+
+// days is the number of days in the month and the column of its first day,
+// with weeks starting on Monday.
+func (c *Calendar) days() (int, int) {
+	return c.Month.AddDate(0, 1, -1).Day(), (int(c.Month.Weekday()) + 6) % 7
+}
+
+// marked tells whether day of the month has an event that is not a task,
+// and whether it has a task that is not done.
+func (c *Calendar) marked(day int) (event, task bool) {
+	date := c.Month.AddDate(0, 0, day-1)
+	for _, e := range c.Events {
+		if date.Before(e.Start) || date.After(e.End) {
+			continue
+		}
+		if e.Task {
+			task = task || !e.Done
+		} else {
+			event = true
+		}
+	}
+	return event, task
+}
+
+// isToday tells whether day of the month is today.
+func (c *Calendar) isToday(day int) bool {
+	y, m, d := c.Today.Date()
+	return y == c.Month.Year() && m == c.Month.Month() && d == day
+}
+
+// eventLine is an event as it is listed below the grid: its day or days,
+// and a checkbox for a task.
+func (c *Calendar) eventLine(e CalendarEvent) string {
+	days := strconv.Itoa(e.Start.Day())
+	if e.Start.Before(c.Month) {
+		days = "1"
+	}
+	if !e.End.Equal(e.Start) {
+		end := e.End
+		if last := c.Month.AddDate(0, 1, -1); end.After(last) {
+			end = last
+		}
+		days += "-" + strconv.Itoa(end.Day())
+	}
+	text := e.Text
+	if e.Task {
+		box := "[ ] "
+		if e.Done {
+			box = "[x] "
+		}
+		text = box + text
+	}
+	return strings.TrimSpace(fmt.Sprintf("%-5s %s", days, text))
+}
+
+// heading is the month and year, after the title when there is one.
+func (c *Calendar) heading() string {
+	heading := c.Month.Format("January 2006")
+	if c.Title != "" {
+		heading = c.Title + ", " + heading
+	}
+	return heading
+}
+
+// layout places the grid of days and the list of events below it.
+func (c *Calendar) layout() chartDrawing {
+	var marks []chartMark
+	var labels []chartLabel
+	width := 7*calendarCellWidth + 32
+	left, top := 16.0, 50.0
+	labels = append(labels, chartLabel{at: diagramPoint{float64(width) / 2, 22}, text: c.heading()})
+	for i, day := range []string{"Mo", "Tu", "We", "Th", "Fr", "Sa", "Su"} {
+		labels = append(labels, chartLabel{at: diagramPoint{left + (float64(i)+0.5)*calendarCellWidth, top - 8}, text: day})
+	}
+
+	days, first := c.days()
+	weeks := (first + days + 6) / 7
+	for day := 1; day <= days; day++ {
+		col, row := (first+day-1)%7, (first+day-1)/7
+		x, y := left+float64(col*calendarCellWidth), top+float64(row*calendarCellHeight)
+		event, task := c.marked(day)
+		if event {
+			marks = append(marks, chartMark{points: chartRect(x+3, y+3, x+calendarCellWidth-3, y+calendarCellHeight-3), color: chartColors[0], faint: true})
+		}
+		if c.isToday(day) {
+			marks = append(marks, chartMark{points: append(chartRect(x+3, y+3, x+calendarCellWidth-3, y+calendarCellHeight-3), diagramPoint{x + 3, y + 3}), width: 1.5})
+		}
+		if task {
+			marks = append(marks, chartMark{points: diagramCircle(diagramPoint{x + calendarCellWidth/2, y + calendarCellHeight - 8}, 2.5), color: chartColors[1]})
+		}
+		labels = append(labels, chartLabel{at: diagramPoint{x + calendarCellWidth/2, y + 18}, text: strconv.Itoa(day)})
+	}
+
+	y := top + float64(weeks*calendarCellHeight) + 12
+	maxChars := (width - 32) / chartCharWidth
+	for _, e := range c.Events {
+		y += calendarLineHeight
+		labels = append(labels, chartLabel{at: diagramPoint{left, y}, text: truncate(c.eventLine(e), maxChars), align: -1})
+	}
+	return chartDrawing{width: width, height: int(y) + 12, title: c.heading(), marks: marks, labels: labels}
+}
+
+// SVG draws the calendar, with its text in the color of the surrounding
+// text.
+func (c *Calendar) SVG() string {
+	return c.layout().SVG("calendar")
+}
+
+// Image draws the calendar on a transparent background, with its text in
+// fg.
+func (c *Calendar) Image(fg color.Color) *image.RGBA {
+	return c.layout().Image(fg)
+}
+
+// htmlCalendarRe finds the calendar blocks of exported HTML.
+var htmlCalendarRe = regexp.MustCompile(`(?s)<pre><code class="language-calendar">(.*?)</code></pre>`)
+
+// calendarHTML replaces the calendar blocks of body with SVG drawings.
+// Blocks with a mistake in them stay as they are.
+func calendarHTML(body string, smp *SharedMarkdownProcessor, now time.Time) string {
+	return htmlCalendarRe.ReplaceAllStringFunc(body, func(match string) string {
+		code := html.UnescapeString(htmlCalendarRe.FindStringSubmatch(match)[1])
+		c, err := ParseCalendar(code, now, func() []DueTask { return WorkspaceDueTasks(smp) })
+		if err != nil {
+			return match
+		}
+		return "<p>" + c.SVG() + "</p>"
+	})
+}
+
+// Terminal draws the calendar as a grid of day numbers, with marked days in
+// color and today underlined, and lists the events below it.
+func (c *Calendar) Terminal(width int) ([]string, error) {
+	if width < 20 {
+		return nil, fmt.Errorf("the preview is too narrow")
+	}
+	eventStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF")).Background(lipgloss.Color(chartColors[0]))
+	taskStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(chartColors[1])).Bold(true)
+	muted := lipgloss.NewStyle().Foreground(termMutedColor)
+
+	heading := truncate(c.heading(), width)
+	lines := []string{
+		lipgloss.NewStyle().Bold(true).Foreground(termStrongColor).Render(fmt.Sprintf("%*s", (20+len([]rune(heading)))/2, heading)),
+		muted.Render("Mo Tu We Th Fr Sa Su"),
+	}
+	days, first := c.days()
+	var week strings.Builder
+	week.WriteString(strings.Repeat("   ", first))
+	for day := 1; day <= days; day++ {
+		number := fmt.Sprintf("%2d", day)
+		switch event, task := c.marked(day); {
+		case event:
+			number = eventStyle.Render(number)
+		case task:
+			number = taskStyle.Render(number)
+		}
+		if c.isToday(day) {
+			number = lipgloss.NewStyle().Underline(true).Render(number)
+		}
+		week.WriteString(number)
+		if (first+day)%7 == 0 || day == days {
+			lines = append(lines, week.String())
+			week.Reset()
+		} else {
+			week.WriteString(" ")
+		}
+	}
+	if len(c.Events) > 0 {
+		lines = append(lines, "")
+	}
+	for _, e := range c.Events {
+		line := truncate(c.eventLine(e), width)
+		if e.Done {
+			line = muted.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// calendarBlock shows a calendar block in the terminal preview, or the
+// block with what is wrong with it.
+func (r *terminalRenderer) calendarBlock(code, info string, width int) []string {
+	c, err := ParseCalendar(code, time.Now(), func() []DueTask { return WorkspaceDueTasks(r.smp) })
+	var lines []string
+	if err == nil {
+		lines, err = c.Terminal(width)
+	}
+	if err != nil {
+		note := lipgloss.NewStyle().Foreground(termMutedColor).Render("Calendar: " + err.Error())
+		return append(r.codeBlock(code, info, width), note)
+	}
+	return lines
+}
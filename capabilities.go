@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// minTermWidth and minTermHeight are the smallest terminal the editor lays
+// itself out in; below that it only asks for more room.
+const (
+	minTermWidth  = 40
+	minTermHeight = 12
+)
+
+// TerminalConfig overrides what is detected about the terminal. Colors is
+// "truecolor", "256", "16" or "none" and Unicode "yes" or "no"; empty or
+// "auto" keeps what was detected.
+type TerminalConfig struct {
+	Colors  string `toml:"colors"`
+	Unicode string `toml:"unicode"`
+}
+
+// TermCaps is what the terminal can show.
+type TermCaps struct {
+	Colors  string
+	Unicode bool
+}
+
+// termCaps are the capabilities the terminal styles were set up for.
+var termCaps = TermCaps{Colors: "truecolor", Unicode: true}
+
+// taskBoxes are the checkboxes of the terminal preview, unchecked and
+// checked, as [glyphs] sets them.
+var taskBoxes = [2]string{"☐", "☑"}
+
+// optionMarks are the options of forms in the terminal preview, unselected
+// and selected, and blankMarks the ends of a blank to fill in.
+var (
+	optionMarks = [2]string{"○", "●"}
+	blankMarks  = [2]string{"⟦", "⟧"}
+)
+
+// asciiGlyphs replaces what the styles and previews draw outside of ASCII by
+// a character of the same width.
+var asciiGlyphs = strings.NewReplacer(
+	"╭", "+", "╮", "+", "╰", "+", "╯", "+", "┌", "+", "┐", "+", "└", "+", "┘", "+",
+	"┏", "+", "┓", "+", "┗", "+", "┛", "+", "├", "+", "┤", "+", "┬", "+", "┴", "+", "┼", "+",
+	"─", "-", "━", "-", "═", "=", "│", "|", "┃", "|",
+	"•", "*", "▪", "-", "◦", "o", "·", "-", "●", "*", "○", "o",
+	"▶", ">", "◀", "<", "➜", ">", "→", ">", "←", "<", "↑", "^", "↓", "v",
+	"❝", "\"", "…", ".", "█", "_", "▌", "|", "✓", "x",
+)
+
+var colorProfiles = map[string]termenv.Profile{
+	"truecolor": termenv.TrueColor,
+	"256":       termenv.ANSI256,
+	"16":        termenv.ANSI,
+	"none":      termenv.Ascii,
+}
+
+// DetectTermCaps finds out what the terminal shows from its environment and
+// applies the overrides of cfg.
+func DetectTermCaps(cfg TerminalConfig) (TermCaps, error) {
+	caps := TermCaps{Colors: detectColors(), Unicode: detectUnicode()}
+	switch cfg.Colors {
+	case "", "auto":
+	case "truecolor", "256", "16", "none":
+		caps.Colors = cfg.Colors
+	default:
+		return caps, fmt.Errorf("unknown terminal colors %q (available: auto, truecolor, 256, 16, none)", cfg.Colors)
+	}
+	switch cfg.Unicode {
+	case "", "auto":
+	case "yes":
+		caps.Unicode = true
+	case "no":
+		caps.Unicode = false
+	default:
+		return caps, fmt.Errorf("unknown terminal unicode %q (available: auto, yes, no)", cfg.Unicode)
+	}
+	return caps, nil
+}
+
+func detectColors() string {
+	if os.Getenv("NO_COLOR") != "" {
+		return "none"
+	}
+	switch lipgloss.ColorProfile() {
+	case termenv.TrueColor:
+		return "truecolor"
+	case termenv.ANSI256:
+		return "256"
+	case termenv.ANSI:
+		return "16"
+	}
+	return "none"
+}
+
+// detectUnicode tells from the locale whether the terminal takes UTF-8. The
+// Linux console and ConHost lack most of the glyphs even when it does.
+func detectUnicode() bool {
+	if runtime.GOOS == "windows" {
+		return os.Getenv("WT_SESSION") != "" || os.Getenv("TERM_PROGRAM") != ""
+	}
+	if term := os.Getenv("TERM"); term == "linux" || term == "dumb" {
+		return false
+	}
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := strings.ToLower(os.Getenv(name)); locale != "" {
+			return strings.Contains(locale, "utf-8") || strings.Contains(locale, "utf8")
+		}
+	}
+	return true
+}
+
+// applyTermCaps sets the terminal styles up for caps: colors are reduced to
+// what the terminal has, background fills that do not survive that go, and
+// without colors highlights are drawn in reverse video instead.
+func applyTermCaps(caps TermCaps) {
+	termCaps = caps
+	lipgloss.SetColorProfile(colorProfiles[caps.Colors])
+	if caps.Colors == "16" || caps.Colors == "none" {
+		termH1Style = termH1Style.UnsetBackground().Underline(true)
+		termCodeHeaderStyle = termCodeHeaderStyle.UnsetBackground()
+		termCodeBlockStyle = termCodeBlockStyle.UnsetBackground()
+		termInlineCodeStyle = termInlineCodeStyle.UnsetBackground()
+	}
+	if caps.Colors == "none" {
+		titleStyle = titleStyle.Reverse(true)
+		statusStyle = statusStyle.Reverse(true)
+		pickerSelectedStyle = pickerSelectedStyle.Reverse(true)
+	}
+	if !caps.Unicode {
+		optionMarks = [2]string{"( )", "(*)"}
+		blankMarks = [2]string{"[", "]"}
+	}
+}
+
+// fitTerminal adapts a frame of the terminal UI to the terminal: glyphs
+// become ASCII where Unicode is not shown, and a terminal too small for the
+// layout gets a note instead.
+func fitTerminal(view string, width, height int) string {
+	if width > 0 && (width < minTermWidth || height < minTermHeight) {
+		view = fmt.Sprintf("Terminal too small (%dx%d), parselt needs %dx%d", width, height, minTermWidth, minTermHeight)
+		view = lipgloss.NewStyle().Width(width).Render(view)
+	}
+	if !termCaps.Unicode {
+		view = asciiGlyphs.Replace(view)
+	}
+	return view
+}
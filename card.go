@@ -0,0 +1,731 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// defaultCardWidth is how wide image cards are, in pixels, unless the
+// [export] card_width says otherwise.
+const defaultCardWidth = 720
+
+const (
+	// cardScale draws PNG cards at twice their size, so they stay sharp on
+	// high density screens.
+	cardScale   = 2
+	cardMargin  = 32
+	cardPadding = 36
+	cardRadius  = 14
+	cardText    = 16
+	cardCode    = 14
+)
+
+type cardFont int
+
+const (
+	cardRegular cardFont = iota
+	cardBold
+	cardItalic
+	cardBoldItalic
+	cardMono
+)
+
+var cardFontData = map[cardFont][]byte{
+	cardRegular:    goregular.TTF,
+	cardBold:       gobold.TTF,
+	cardItalic:     goitalic.TTF,
+	cardBoldItalic: gobolditalic.TTF,
+	cardMono:       gomono.TTF,
+}
+
+// cardFamilies are the fonts asked for in SVG cards, which are drawn by
+// whatever shows them.
+var cardFamilies = map[cardFont]string{
+	cardRegular:    "'Go', 'Helvetica Neue', Arial, sans-serif",
+	cardBold:       "'Go', 'Helvetica Neue', Arial, sans-serif",
+	cardItalic:     "'Go', 'Helvetica Neue', Arial, sans-serif",
+	cardBoldItalic: "'Go', 'Helvetica Neue', Arial, sans-serif",
+	cardMono:       "'Go Mono', Menlo, Consolas, monospace",
+}
+
+type cardFaceKey struct {
+	font cardFont
+	size float64
+}
+
+var (
+	// cardMu guards the faces, which measure and draw through shared
+	// buffers; a card is laid out and drawn while holding it.
+	cardMu    sync.Mutex
+	cardFaces = map[cardFaceKey]font.Face{}
+)
+
+func cardFace(f cardFont, size float64) font.Face {
+	key := cardFaceKey{f, size}
+	if face, ok := cardFaces[key]; ok {
+		return face
+	}
+	parsed, err := opentype.Parse(cardFontData[f])
+	if err != nil {
+		panic(fmt.Sprintf("card font: %v", err))
+	}
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		panic(fmt.Sprintf("card font: %v", err))
+	}
+	cardFaces[key] = face
+	return face
+}
+
+func cardMeasure(f cardFont, size float64, text string) float64 {
+	return float64(font.MeasureString(cardFace(f, size), text)) / 64
+}
+
+// cardTheme colors an image card. Frame is the backdrop around the card.
+type cardTheme struct {
+	Frame      string
+	Shadow     string
+	Background string
+	Text       string
+	Muted      string
+	Accent     string
+	Code       string
+	CodeText   string
+	Rule       string
+	Syntax     map[string]string
+}
+
+var cardThemes = map[string]cardTheme{
+	"light": {
+		Frame: "#E3E8EF", Shadow: "#0F172A1A", Background: "#FFFFFF",
+		Text: "#24292F", Muted: "#57606A", Accent: "#0969DA",
+		Code: "#F6F8FA", CodeText: "#24292F", Rule: "#D0D7DE",
+		Syntax: syntaxLightColors,
+	},
+	"dark": {
+		Frame: "#14161B", Shadow: "#00000066", Background: "#282A36",
+		Text: "#E6E6E6", Muted: "#A0A4B8", Accent: "#8BE9FD",
+		Code: "#1E1F29", CodeText: "#E0E0E0", Rule: "#44475A",
+		Syntax: syntaxColors,
+	},
+}
+
+// cardThemeFor picks the card theme of an export theme; stylesheets get
+// the light one.
+func cardThemeFor(name string) cardTheme {
+	if theme, ok := cardThemes[name]; ok {
+		return theme
+	}
+	return cardThemes["light"]
+}
+
+type cardBox struct {
+	X, Y, W, H, Radius float64
+	Color              string
+}
+
+// cardRun is text drawn in one font and color, Y being its baseline.
+type cardRun struct {
+	X, Y  float64
+	Text  string
+	Font  cardFont
+	Size  float64
+	Color string
+}
+
+type cardSpan struct {
+	Text  string
+	Font  cardFont
+	Color string
+	Code  bool
+}
+
+// cardLayout places the blocks of a document on a card, top to bottom,
+// y being where the next one goes.
+type cardLayout struct {
+	smp    *SharedMarkdownProcessor
+	source []byte
+	theme  cardTheme
+	width  float64
+	y      float64
+	boxes  []cardBox
+	runs   []cardRun
+	// mono, when set, takes the place of Go Mono
+	mono      *opentype.Font
+	monoFaces map[float64]font.Face
+}
+
+// face is the face runs in f are drawn with at size.
+func (c *cardLayout) face(f cardFont, size float64) font.Face {
+	if f != cardMono || c.mono == nil {
+		return cardFace(f, size)
+	}
+	if face, ok := c.monoFaces[size]; ok {
+		return face
+	}
+	face, err := opentype.NewFace(c.mono, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return cardFace(f, size)
+	}
+	if c.monoFaces == nil {
+		c.monoFaces = map[float64]font.Face{}
+	}
+	c.monoFaces[size] = face
+	return face
+}
+
+// layoutCard lays content out on a card width pixels wide.
+func layoutCard(content string, opts ExportOptions) *cardLayout {
+	width := float64(opts.CardWidth)
+	if width <= 0 {
+		width = defaultCardWidth
+	}
+	width = max(width, 240)
+	smp := opts.processor()
+	doc, source := smp.Parse(content)
+	c := &cardLayout{smp: smp, source: source, theme: cardThemeFor(opts.Theme), width: width}
+
+	left := float64(cardMargin + cardPadding)
+	inner := width - 2*left
+	c.y = cardMargin + cardPadding
+	for child := doc.FirstChild(); child != nil; child = child.NextSibling() {
+		c.block(child, left, inner, c.theme.Text)
+	}
+	// The gap after the last block is padding enough
+	c.y = math.Ceil(max(c.y-cardText*0.75, cardMargin+cardPadding+cardText) + cardPadding)
+
+	card := []cardBox{
+		{0, 0, width, c.y + cardMargin, 0, c.theme.Frame},
+		{cardMargin, cardMargin + 6, width - 2*cardMargin, c.y - cardMargin, cardRadius, c.theme.Shadow},
+		{cardMargin, cardMargin, width - 2*cardMargin, c.y - cardMargin, cardRadius, c.theme.Background},
+	}
+	c.boxes = append(card, c.boxes...)
+	c.y += cardMargin
+	return c
+}
+
+func (c *cardLayout) block(n ast.Node, x, width float64, color string) {
+	switch node := n.(type) {
+	case *ast.Heading:
+		size := map[int]float64{1: 28, 2: 23, 3: 20}[node.Level]
+		if size == 0 {
+			size = 18
+		}
+		c.flow(c.spans(node, cardBold, color), x, width, size, size*1.3)
+		c.y += size * 0.5
+
+	case *ast.Paragraph, *ast.TextBlock:
+		c.flow(c.spans(node, cardRegular, color), x, width, cardText, cardText*1.5)
+		c.y += cardText * 0.75
+
+	case *ast.List:
+		number := node.Start
+		for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+			marker := "•"
+			if node.IsOrdered() {
+				marker = fmt.Sprintf("%d.", number)
+				number++
+			}
+			if first := item.FirstChild(); first != nil {
+				if checkbox, ok := first.FirstChild().(*east.TaskCheckBox); ok {
+					marker = "□"
+					if checkbox.IsChecked {
+						marker = "■"
+					}
+				}
+			}
+			indent := max(cardMeasure(cardRegular, cardText, marker)+10, 22)
+			c.runs = append(c.runs, cardRun{X: x, Y: c.y + cardText*1.1, Text: marker, Font: cardRegular, Size: cardText, Color: c.theme.Muted})
+			for child := item.FirstChild(); child != nil; child = child.NextSibling() {
+				c.block(child, x+indent, width-indent, color)
+				if _, text := child.(*ast.TextBlock); text {
+					c.y -= cardText * 0.75
+				}
+			}
+			c.y += cardText * 0.25
+		}
+		c.y += cardText * 0.5
+
+	case *ast.FencedCodeBlock:
+		lang := fenceLanguage(fenceInfo(node, c.source))
+		code := strings.TrimRight(c.smp.CodeBlockText(node, c.source), "\n")
+		tokens, ok := c.smp.Highlight(code, lang)
+		if !ok {
+			tokens = []HighlightToken{{Text: code}}
+		}
+		c.code(tokens, x, width)
+
+	case *ast.CodeBlock:
+		code := strings.TrimRight(c.smp.CodeBlockText(node, c.source), "\n")
+		c.code([]HighlightToken{{Text: code}}, x, width)
+
+	case *ast.Blockquote:
+		top := c.y
+		for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+			c.block(child, x+18, width-18, c.theme.Muted)
+		}
+		c.boxes = append(c.boxes, cardBox{x, top, 4, c.y - top - cardText*0.75, 2, c.theme.Rule})
+
+	case *east.Table:
+		var rows [][]string
+		for row := node.FirstChild(); row != nil; row = row.NextSibling() {
+			var cells []string
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, c.smp.PlainText(cell, c.source))
+			}
+			rows = append(rows, cells)
+		}
+		c.code([]HighlightToken{{Text: strings.Join(formatTextTable(rows), "\n")}}, x, width)
+
+	case *ast.ThematicBreak:
+		c.boxes = append(c.boxes, cardBox{x, c.y + cardText*0.5, width, 1, 0, c.theme.Rule})
+		c.y += cardText * 1.75
+
+	case *ast.HTMLBlock:
+		// Left out, as in plain text
+
+	default:
+		if text := strings.TrimSpace(c.smp.PlainText(node, c.source)); text != "" {
+			c.flow([]cardSpan{{Text: text, Font: cardRegular, Color: color}}, x, width, cardText, cardText*1.5)
+			c.y += cardText * 0.75
+		}
+	}
+}
+
+// spans are the inline pieces of n in their fonts and colors.
+func (c *cardLayout) spans(n ast.Node, base cardFont, color string) []cardSpan {
+	var spans []cardSpan
+	var walk func(n ast.Node, f cardFont, color string)
+	walk = func(n ast.Node, f cardFont, color string) {
+		for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+			switch node := child.(type) {
+			case *ast.Text:
+				text := string(node.Segment.Value(c.source))
+				switch {
+				case node.HardLineBreak():
+					text += "\n"
+				case node.SoftLineBreak():
+					text += " "
+				}
+				spans = append(spans, cardSpan{Text: text, Font: f, Color: color})
+			case *ast.String:
+				spans = append(spans, cardSpan{Text: string(node.Value), Font: f, Color: color})
+			case *ast.CodeSpan:
+				spans = append(spans, cardSpan{Text: c.smp.PlainText(node, c.source), Font: cardMono, Color: color, Code: true})
+			case *ast.Emphasis:
+				walk(node, emphasized(f, node.Level), color)
+			case *ast.Link:
+				walk(node, f, c.theme.Accent)
+			case *ast.AutoLink:
+				spans = append(spans, cardSpan{Text: string(node.Label(c.source)), Font: f, Color: c.theme.Accent})
+			case *ast.Image:
+				spans = append(spans, cardSpan{Text: c.smp.PlainText(node, c.source), Font: f, Color: c.theme.Muted})
+			case *east.Strikethrough:
+				walk(node, f, c.theme.Muted)
+			case *east.TaskCheckBox, *ast.RawHTML:
+			default:
+				walk(node, f, color)
+			}
+		}
+	}
+	walk(n, base, color)
+	if len(spans) > 0 {
+		// A task's text starts after the space following its box
+		spans[0].Text = strings.TrimLeft(spans[0].Text, " ")
+	}
+	return spans
+}
+
+func emphasized(f cardFont, level int) cardFont {
+	bold, italic := f == cardBold || f == cardBoldItalic, f == cardItalic || f == cardBoldItalic
+	if level >= 2 {
+		bold = true
+	} else {
+		italic = true
+	}
+	switch {
+	case bold && italic:
+		return cardBoldItalic
+	case bold:
+		return cardBold
+	case italic:
+		return cardItalic
+	}
+	return f
+}
+
+// cardWord is a word or a stretch of spaces of a span, measured.
+type cardWord struct {
+	span  cardSpan
+	text  string
+	width float64
+	space bool
+}
+
+// flow wraps spans into lines width wide.
+func (c *cardLayout) flow(spans []cardSpan, x, width, size, lineHeight float64) {
+	sizeOf := func(span cardSpan) float64 {
+		if span.Code {
+			return size * 0.9
+		}
+		return size
+	}
+	var line []cardWord
+	lineWidth := 0.0
+	emit := func() {
+		for len(line) > 0 && line[len(line)-1].space {
+			line = line[:len(line)-1]
+		}
+		c.line(line, x, size, lineHeight, sizeOf)
+		line, lineWidth = nil, 0
+	}
+	for _, span := range spans {
+		for i, part := range strings.Split(span.Text, "\n") {
+			if i > 0 {
+				emit()
+			}
+			for _, word := range cardWords(part) {
+				w := cardWord{span: span, text: word, space: strings.TrimSpace(word) == ""}
+				w.width = cardMeasure(span.Font, sizeOf(span), word)
+				if w.space && len(line) == 0 {
+					continue
+				}
+				for !w.space && lineWidth+w.width > width && (len(line) > 0 || w.width > width) {
+					if len(line) > 0 {
+						emit()
+						continue
+					}
+					// Longer than a line: break it where it fills one
+					cut := len(w.text)
+					for cut > 0 && cardMeasure(span.Font, sizeOf(span), w.text[:cut]) > width {
+						_, n := utf8.DecodeLastRuneInString(w.text[:cut])
+						cut -= n
+					}
+					if cut == 0 {
+						_, cut = utf8.DecodeRuneInString(w.text)
+					}
+					head := w
+					head.text = w.text[:cut]
+					head.width = cardMeasure(span.Font, sizeOf(span), head.text)
+					line = append(line, head)
+					emit()
+					w.text = w.text[cut:]
+					w.width = cardMeasure(span.Font, sizeOf(span), w.text)
+				}
+				line = append(line, w)
+				lineWidth += w.width
+			}
+		}
+	}
+	if len(line) > 0 {
+		emit()
+	}
+}
+
+// cardWords splits text into words and the spaces between them.
+func cardWords(text string) []string {
+	var words []string
+	start := 0
+	for i, r := range text {
+		if i > start && unicode.IsSpace(r) != unicode.IsSpace(rune(text[start])) {
+			words = append(words, text[start:i])
+			start = i
+		}
+	}
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+	return words
+}
+
+// line places the words of a line, joining neighbours of the same style
+// into one run.
+func (c *cardLayout) line(words []cardWord, x, size, lineHeight float64, sizeOf func(cardSpan) float64) {
+	baseline := c.y + (lineHeight+size*0.7)/2
+	for _, w := range words {
+		if w.span.Code && !w.space {
+			c.boxes = append(c.boxes, cardBox{x - 3, baseline - size*0.95, w.width + 6, size * 1.25, 4, c.theme.Code})
+		}
+		last := len(c.runs) - 1
+		if last >= 0 && c.runs[last].Y == baseline && c.runs[last].Font == w.span.Font &&
+			c.runs[last].Color == w.span.Color && !w.span.Code {
+			c.runs[last].Text += w.text
+		} else if !w.space {
+			c.runs = append(c.runs, cardRun{X: x, Y: baseline, Text: w.text, Font: w.span.Font, Size: sizeOf(w.span), Color: w.span.Color})
+		}
+		x += w.width
+	}
+	c.y += lineHeight
+}
+
+// code places a code block on its panel, wrapping lines too long for it.
+func (c *cardLayout) code(tokens []HighlightToken, x, width float64) {
+	const pad = 14
+	lineHeight := cardCode * 1.5
+	advance := cardMeasure(cardMono, cardCode, "0")
+	columns := max(int((width-2*pad)/advance), 8)
+
+	top, panel := c.y, len(c.boxes)
+	c.y += pad
+	col := 0
+	baseline := func() float64 { return c.y + (lineHeight+cardCode*0.7)/2 }
+	for _, token := range tokens {
+		color := c.theme.Syntax[token.Class]
+		if color == "" {
+			color = c.theme.CodeText
+		}
+		for i, part := range strings.Split(token.Text, "\n") {
+			if i > 0 {
+				c.y += lineHeight
+				col = 0
+			}
+			part = strings.ReplaceAll(part, "\t", "    ")
+			for part != "" {
+				if col >= columns {
+					c.y += lineHeight
+					col = 0
+				}
+				runes := []rune(part)
+				n := min(len(runes), columns-col)
+				if text := string(runes[:n]); strings.TrimSpace(text) != "" {
+					c.runs = append(c.runs, cardRun{X: x + pad + float64(col)*advance, Y: baseline(), Text: text, Font: cardMono, Size: cardCode, Color: color})
+				}
+				col += n
+				part = string(runes[n:])
+			}
+		}
+	}
+	c.y += lineHeight + pad
+	c.boxes = slices.Insert(c.boxes, panel, cardBox{x, top, width, c.y - top, 8, c.theme.Code})
+	c.y += cardText * 0.75
+}
+
+// cardColor reads #RRGGBB or #RRGGBBAA.
+func cardColor(hex string) color.NRGBA {
+	col := color.NRGBA{A: 0xff}
+	if len(hex) == 9 {
+		fmt.Sscanf(hex, "#%02x%02x%02x%02x", &col.R, &col.G, &col.B, &col.A)
+	} else {
+		fmt.Sscanf(hex, "#%02x%02x%02x", &col.R, &col.G, &col.B)
+	}
+	return col
+}
+
+func (c *cardLayout) png() ([]byte, error) {
+	w, h := int(math.Ceil(c.width*cardScale)), int(math.Ceil(c.y*cardScale))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for _, box := range c.boxes {
+		r := vector.NewRasterizer(w, h)
+		roundedRect(r, box.X*cardScale, box.Y*cardScale, box.W*cardScale, box.H*cardScale, box.Radius*cardScale)
+		r.Draw(img, img.Bounds(), image.NewUniform(cardColor(box.Color)), image.Point{})
+	}
+	for _, run := range c.runs {
+		drawer := font.Drawer{
+			Dst:  img,
+			Src:  image.NewUniform(cardColor(run.Color)),
+			Face: c.face(run.Font, run.Size*cardScale),
+			Dot:  fixed.Point26_6{X: fixed.Int26_6(run.X * cardScale * 64), Y: fixed.Int26_6(run.Y * cardScale * 64)},
+		}
+		drawer.DrawString(run.Text)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("error encoding card: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// roundedRect traces a rectangle with quarter circle corners; a square
+// with a radius of half its side is a circle.
+func roundedRect(r *vector.Rasterizer, x, y, w, h, radius float64) {
+	radius = min(radius, w/2, h/2)
+	// How far the control points of a Bézier quarter circle are from its ends
+	k := radius * 0.5523
+	f := func(v float64) float32 { return float32(v) }
+	r.MoveTo(f(x+radius), f(y))
+	r.LineTo(f(x+w-radius), f(y))
+	r.CubeTo(f(x+w-radius+k), f(y), f(x+w), f(y+radius-k), f(x+w), f(y+radius))
+	r.LineTo(f(x+w), f(y+h-radius))
+	r.CubeTo(f(x+w), f(y+h-radius+k), f(x+w-radius+k), f(y+h), f(x+w-radius), f(y+h))
+	r.LineTo(f(x+radius), f(y+h))
+	r.CubeTo(f(x+radius-k), f(y+h), f(x), f(y+h-radius+k), f(x), f(y+h-radius))
+	r.LineTo(f(x), f(y+radius))
+	r.CubeTo(f(x), f(y+radius-k), f(x+radius-k), f(y), f(x+radius), f(y))
+	r.ClosePath()
+}
+
+// svgFill is the fill of a color, with its opacity when it has one.
+func svgFill(hex string) string {
+	if len(hex) == 9 {
+		return fmt.Sprintf(`fill="%s" fill-opacity="%.2f"`, hex[:7], float64(cardColor(hex).A)/255)
+	}
+	return fmt.Sprintf(`fill="%s"`, hex)
+}
+
+func (c *cardLayout) svg() []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g">`+"\n",
+		math.Ceil(c.width), math.Ceil(c.y), math.Ceil(c.width), math.Ceil(c.y))
+	for _, box := range c.boxes {
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" rx="%g" %s/>`+"\n",
+			box.X, box.Y, box.W, box.H, box.Radius, svgFill(box.Color))
+	}
+	for _, run := range c.runs {
+		style := ""
+		if run.Font == cardBold || run.Font == cardBoldItalic {
+			style += ` font-weight="bold"`
+		}
+		if run.Font == cardItalic || run.Font == cardBoldItalic {
+			style += ` font-style="italic"`
+		}
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" font-family="%s" font-size="%g"%s fill="%s" xml:space="preserve">%s</text>`+"\n",
+			run.X, run.Y, cardFamilies[run.Font], run.Size, style, run.Color, html.EscapeString(run.Text))
+	}
+	b.WriteString("</svg>\n")
+	return []byte(b.String())
+}
+
+// ExportPNG draws the document as an image card: its blocks styled on a
+// rounded card over a backdrop, for sharing a snippet as a picture.
+func ExportPNG(content string, opts ExportOptions) ([]byte, error) {
+	cardMu.Lock()
+	defer cardMu.Unlock()
+	return layoutCard(content, opts).png()
+}
+
+// ExportSVG draws the same card as ExportPNG as vector graphics, leaving
+// the fonts to whatever shows it.
+func ExportSVG(content string, opts ExportOptions) ([]byte, error) {
+	cardMu.Lock()
+	defer cardMu.Unlock()
+	return layoutCard(content, opts).svg(), nil
+}
+
+// CardSource is what of content goes on an image card: the selected text
+// when there is some, else the code block the cursor is in, else its
+// section, else the whole document. It also names which it was.
+func (smp *SharedMarkdownProcessor) CardSource(content, selected string, cursor int) (string, string) {
+	if strings.TrimSpace(selected) != "" {
+		return selected, "selection"
+	}
+	if fence, ok := codeFenceAt(content, cursor); ok {
+		lines := strings.Split(content, "\n")
+		return strings.Join(lines[fence.Open:fence.Close+1], "\n"), "code block"
+	}
+	if _, section, ok := smp.NarrowSection(content, strings.Count(content[:cursor], "\n")); ok {
+		return section, "section"
+	}
+	return content, "document"
+}
+
+// cardPath is where the terminal puts the card of path: next to it, or in
+// the working directory for an untitled document, without overwriting an
+// earlier card.
+func cardPath(path, kind string) string {
+	dir, name := ".", "untitled"
+	if path != "" {
+		dir = filepath.Dir(path)
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	name += "-" + strings.ReplaceAll(kind, " ", "-")
+	candidate := filepath.Join(dir, name+".png")
+	for i := 2; ; i++ {
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = filepath.Join(dir, fmt.Sprintf("%s-%d.png", name, i))
+	}
+}
+
+// exportCard writes the selection, or the code block or section the cursor
+// is in, as a PNG card next to the document.
+func (m *model) exportCard() {
+	content, selected := m.textarea.Value(), ""
+	if m.selection != nil {
+		selected = content[m.selection.Start:m.selection.End]
+	}
+	source, kind := m.mdProcessor.CardSource(content, selected, m.cursorOffset())
+	m.guardSecrets(source, func(m *model) {
+		opts := m.export
+		opts.SourcePath = m.filename
+		format, _ := findExportFormat("png")
+		data, err := exportWith(format, source, opts)
+		if err != nil {
+			m.status = err.Error()
+			return
+		}
+		path := cardPath(m.filename, kind)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			m.status = fmt.Sprintf("error writing card: %v", err)
+			return
+		}
+		m.status = fmt.Sprintf("Exported the %s as %s", kind, path)
+	})
+}
+
+// exportCard saves the selection, or the code block or section the cursor
+// is in, as a PNG or SVG card.
+func (g *GUIApp) exportCard() {
+	content := g.editor.Text
+	cursor := runeOffset(content, g.editor.CursorRow, g.editor.CursorColumn)
+	source, kind := g.mdProcessor.CardSource(content, g.editor.SelectedText(), cursor)
+	opts := g.config.ExportOptions(g.currentFile)
+	g.guardSecrets(source, func() { g.saveCard(source, kind, opts) })
+}
+
+// saveCard asks where to save source, the card of kind, and saves it.
+func (g *GUIApp) saveCard(source, kind string, opts ExportOptions) {
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		format, ok := exportFormatForPath(uriPath(writer.URI()))
+		if !ok || (format.name != "png" && format.name != "svg") {
+			format, _ = findExportFormat("png")
+		}
+		data, err := exportWith(format, source, opts)
+		if err == nil {
+			_, err = writer.Write(data)
+		}
+		if err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+		dialog.ShowInformation("Exported", fmt.Sprintf("Exported the %s to %s", kind, uriPath(writer.URI())), g.window)
+		g.runHooks(g.config.Hooks.AfterExport, "after_export", g.currentFile, uriPath(writer.URI()))
+	}, g.window)
+	saveDialog.SetFileName(filepath.Base(cardPath(g.currentFile, kind)))
+	saveDialog.Show()
+}
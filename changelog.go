@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/yuin/goldmark/ast"
+)
+
+// ChangelogConfig is the [changelog] table. With Record set, every save
+// adds a line to a log next to the document, notes.changes.log for
+// notes.md, with the sections the save touched and the words it added and
+// removed.
+type ChangelogConfig struct {
+	Record bool `toml:"record"`
+}
+
+// ChangeEntry is one save in the change log. Sections are named by the
+// path of their heading, such as "Methods › Data".
+type ChangeEntry struct {
+	Time     time.Time
+	Added    int
+	Removed  int
+	Sections []string
+}
+
+const changeLayout = "2006-01-02 15:04:05"
+
+// topSection names the text before the first heading.
+const topSection = "(top)"
+
+func (e ChangeEntry) words() string {
+	return fmt.Sprintf("+%d -%d", e.Added, e.Removed)
+}
+
+// changelogPath is the change log of the document at path.
+func changelogPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".changes.log"
+}
+
+// changeSection is the own text of a section, without the sections nested
+// in it, and its words.
+type changeSection struct {
+	key   string
+	text  string
+	words []string
+}
+
+// changeSections splits content at its headings.
+func (smp *SharedMarkdownProcessor) changeSections(content string) []changeSection {
+	headings := smp.Outline(content)
+	lines := strings.Split(content, "\n")
+	var sections []changeSection
+	seen := map[string]int{}
+	start, key := 0, topSection
+	add := func(end int) {
+		text := strings.Join(lines[start:end], "\n")
+		if key == topSection && strings.TrimSpace(text) == "" {
+			return
+		}
+		if seen[key]++; seen[key] > 1 {
+			key = fmt.Sprintf("%s (%d)", key, seen[key])
+		}
+		sections = append(sections, changeSection{key: key, text: text, words: smp.proseWords(text)})
+	}
+	for _, heading := range headings {
+		add(heading.Line)
+		start, key = heading.Line, breadcrumb(headings, headingPath(headings, heading.Line))
+	}
+	add(len(lines))
+	return sections
+}
+
+// proseWords are the words of content that Stats counts, without the
+// punctuation around them, so that adding a comma does not change a word.
+func (smp *SharedMarkdownProcessor) proseWords(content string) []string {
+	doc, source := smp.Parse(content)
+	var prose strings.Builder
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		if n.Type() == ast.TypeBlock {
+			prose.WriteString("\n")
+		}
+		switch node := n.(type) {
+		case *ast.Image, *ast.FencedCodeBlock, *ast.CodeBlock:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			prose.Write(node.Segment.Value(source))
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				prose.WriteString(" ")
+			}
+		case *ast.String:
+			prose.Write(node.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	var words []string
+	for _, word := range strings.Fields(prose.String()) {
+		if word = strings.TrimFunc(word, unicode.IsPunct); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// diffWords counts the words of after that were not in before, and those
+// of before no longer in after. Words are counted, not placed: moving one
+// within a section is no change.
+func diffWords(before, after []string) (int, int) {
+	counts := map[string]int{}
+	for _, word := range before {
+		counts[word]++
+	}
+	added, removed := 0, 0
+	for _, word := range after {
+		if counts[word] > 0 {
+			counts[word]--
+		} else {
+			added++
+		}
+	}
+	for _, n := range counts {
+		removed += n
+	}
+	return added, removed
+}
+
+// SummarizeChange sums up the change from before to after. When both have
+// as many sections, they are paired in order, so renaming a heading counts
+// as touching its section rather than replacing it; otherwise they are
+// paired by heading.
+func (smp *SharedMarkdownProcessor) SummarizeChange(before, after string) ChangeEntry {
+	old, current := smp.changeSections(before), smp.changeSections(after)
+	var entry ChangeEntry
+	touch := func(key string, from, to []string) {
+		added, removed := diffWords(from, to)
+		entry.Added += added
+		entry.Removed += removed
+		entry.Sections = append(entry.Sections, key)
+	}
+	if len(old) == len(current) {
+		for i := range current {
+			if old[i].text != current[i].text || old[i].key != current[i].key {
+				touch(current[i].key, old[i].words, current[i].words)
+			}
+		}
+		return entry
+	}
+
+	byKey := map[string]changeSection{}
+	for _, section := range old {
+		byKey[section.key] = section
+	}
+	for _, section := range current {
+		previous, ok := byKey[section.key]
+		delete(byKey, section.key)
+		if !ok || previous.text != section.text {
+			touch(section.key, previous.words, section.words)
+		}
+	}
+	for _, section := range old {
+		if _, gone := byKey[section.key]; gone {
+			touch(section.key+" (removed)", section.words, nil)
+		}
+	}
+	return entry
+}
+
+// RecordChange adds the change from before to after, just saved to path,
+// to its change log.
+func RecordChange(smp *SharedMarkdownProcessor, path, before, after string, now time.Time) error {
+	if path == "" || before == after {
+		return nil
+	}
+	entry := smp.SummarizeChange(before, after)
+	if len(entry.Sections) == 0 {
+		return nil
+	}
+	line := fmt.Sprintf("%s  %s  %s\n", now.Format(changeLayout), entry.words(), strings.Join(entry.Sections, " · "))
+	file, err := os.OpenFile(changelogPath(path), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing change log: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(line); err != nil {
+		return fmt.Errorf("error writing change log: %v", err)
+	}
+	return nil
+}
+
+// ReadChangelog returns the change log of path, newest first. A document
+// without one has no entries.
+func ReadChangelog(path string) ([]ChangeEntry, error) {
+	data, err := os.ReadFile(changelogPath(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading change log: %v", err)
+	}
+	var entries []ChangeEntry
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.SplitN(line, "  ", 3)
+		if len(fields) < 2 {
+			continue
+		}
+		when, err := time.ParseInLocation(changeLayout, fields[0], time.Local)
+		if err != nil {
+			continue
+		}
+		entry := ChangeEntry{Time: when}
+		if counts := strings.Fields(fields[1]); len(counts) == 2 {
+			entry.Added, _ = strconv.Atoi(strings.TrimPrefix(counts[0], "+"))
+			entry.Removed, _ = strconv.Atoi(strings.TrimPrefix(counts[1], "-"))
+		}
+		if len(fields) == 3 && fields[2] != "" {
+			entry.Sections = strings.Split(fields[2], " · ")
+		}
+		entries = append(entries, entry)
+	}
+	slices.Reverse(entries)
+	return entries, nil
+}
+
+// changeTotals sums up entries for the title of the history.
+func changeTotals(path string, entries []ChangeEntry) string {
+	added, removed := 0, 0
+	for _, entry := range entries {
+		added += entry.Added
+		removed += entry.Removed
+	}
+	return fmt.Sprintf("Changes to %s: +%d -%d words in %d saves since %s", filepath.Base(path), added, removed,
+		len(entries), entries[len(entries)-1].Time.Format("Jan 2"))
+}
+
+// sectionLine is the line of the heading of the section named key in
+// content, as the change log names sections.
+func (smp *SharedMarkdownProcessor) sectionLine(content, key string) (int, bool) {
+	headings := smp.Outline(content)
+	for _, heading := range headings {
+		if breadcrumb(headings, headingPath(headings, heading.Line)) == key {
+			return heading.Line, true
+		}
+	}
+	return 0, false
+}
+
+// openChanges lists the change log of the active buffer, newest first.
+func (m *model) openChanges() {
+	if m.filename == "" {
+		m.status = "Save the document first, its change log goes next to it"
+		return
+	}
+	entries, err := ReadChangelog(m.filename)
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	if len(entries) == 0 {
+		if m.changelog.Record {
+			m.status = fmt.Sprintf("No changes to %s recorded yet", filepath.Base(m.filename))
+		} else {
+			m.status = "No change log: set record under [changelog] to keep one"
+		}
+		return
+	}
+	m.changes = entries
+	items := make([]pickerItem, len(entries))
+	for i, entry := range entries {
+		items[i] = pickerItem{
+			title:  entry.Time.Format("Jan 2 15:04") + "  " + entry.words(),
+			detail: strings.Join(entry.Sections, " · "),
+			index:  i,
+		}
+	}
+	m.overlay = overlayChanges
+	m.picker = newPicker(changeTotals(m.filename, entries), items)
+	m.picker.hint = "enter: go to section"
+}
+
+// jumpToChange moves the cursor to the first section of entry that is
+// still there.
+func (m *model) jumpToChange(entry ChangeEntry) {
+	for _, key := range entry.Sections {
+		if line, ok := m.mdProcessor.sectionLine(m.textarea.Value(), key); ok {
+			m.jumpTo(line, 0)
+			return
+		}
+	}
+	m.status = "The sections of that change are no longer here"
+}
+
+// recordChange adds the save just made, of what was saved as before, to
+// the change log when [changelog] asks for one.
+func (g *GUIApp) recordChange(before string) {
+	if !g.config.Changelog.Record {
+		return
+	}
+	if err := RecordChange(g.mdProcessor, g.currentFile, before, g.document(), time.Now()); err != nil {
+		dialog.ShowError(err, g.window)
+	}
+}
+
+// showChanges lists the change log of the document; choosing a save goes
+// to the first section it touched.
+func (g *GUIApp) showChanges() {
+	if g.currentFile == "" {
+		dialog.ShowInformation("Change History", "Save the document first, its change log goes next to it.", g.window)
+		return
+	}
+	entries, err := ReadChangelog(g.currentFile)
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+	if len(entries) == 0 {
+		text := fmt.Sprintf("No changes to %s recorded yet.", filepath.Base(g.currentFile))
+		if !g.config.Changelog.Record {
+			text = "There is no change log. Set record under [changelog] in the config to keep one."
+		}
+		dialog.ShowInformation("Change History", text, g.window)
+		return
+	}
+
+	var d dialog.Dialog
+	list := widget.NewList(
+		func() int { return len(entries) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			entry := entries[id]
+			obj.(*widget.Label).SetText(entry.Time.Format("Jan 2 15:04") + "   " + entry.words() + "   " + strings.Join(entry.Sections, " · "))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		d.Hide()
+		for _, key := range entries[id].Sections {
+			if line, ok := g.mdProcessor.sectionLine(g.editor.Text, key); ok {
+				g.jumpToLine(line)
+				return
+			}
+		}
+	}
+	label := widget.NewLabel(changeTotals(g.currentFile, entries))
+	d = dialog.NewCustom("Change History", "Close", container.NewBorder(label, nil, nil, nil, list), g.window)
+	d.Resize(fyne.NewSize(640, 420))
+	d.Show()
+}
@@ -0,0 +1,643 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+	"gopkg.in/yaml.v3"
+)
+
+// The size of a drawn chart in pixels at 96 DPI, and of the characters of
+// its labels.
+const (
+	chartWidth     = 480
+	chartHeight    = 270
+	chartCharWidth = 7
+)
+
+// chartColors are the colors of the series of a chart, or of the slices of
+// a pie, in turn.
+var chartColors = []string{"#7D56F4", "#2A9D8F", "#E9C46A", "#F4A261", "#E76F51", "#457B9D", "#8AB17D", "#D46A9F"}
+
+// chartSpecRe matches the type: and title: lines that may come before the
+// data of a chart written as CSV.
+var chartSpecRe = regexp.MustCompile(`^(type|title):\s*(.*)$`)
+
+// ChartSeries is one named row of values of a chart.
+type ChartSeries struct {
+	Name   string
+	Values []float64
+}
+
+// Chart is the content of a chart block: a bar, line or pie chart of one
+// or more series, with a value for each label.
+type Chart struct {
+	Type   string
+	Title  string
+	Labels []string
+	Series []ChartSeries
+}
+
+// ParseChart reads a chart block, written either as YAML with type, title,
+// labels and series, or data for a single series:
+//
+//	type: pie
+//	data:
+//	  Go: 60
+//	  Rust: 40
+//
+// or as CSV with a header row of series names, after optional type: and
+// title: lines. The type is bar when it is not given.
+func ParseChart(code string) (*Chart, error) {
+	c := &Chart{Type: "bar"}
+	var spec struct {
+		Type   string    `yaml:"type"`
+		Title  string    `yaml:"title"`
+		Labels []string  `yaml:"labels"`
+		Series yaml.Node `yaml:"series"`
+		Data   yaml.Node `yaml:"data"`
+	}
+	if err := yaml.Unmarshal([]byte(code), &spec); err == nil && (spec.Series.Kind != 0 || spec.Data.Kind != 0) {
+		if spec.Type != "" {
+			c.Type = spec.Type
+		}
+		c.Title, c.Labels = spec.Title, spec.Labels
+		if err := c.parseYAML(&spec.Series, &spec.Data); err != nil {
+			return nil, err
+		}
+	} else if err := c.parseCSV(code); err != nil {
+		return nil, err
+	}
+
+	if c.Type != "bar" && c.Type != "line" && c.Type != "pie" {
+		return nil, fmt.Errorf("unknown chart type %q (available: bar, line, pie)", c.Type)
+	}
+	if len(c.Labels) == 0 || len(c.Series) == 0 {
+		return nil, fmt.Errorf("the chart has no data")
+	}
+	for _, s := range c.Series {
+		if len(s.Values) != len(c.Labels) {
+			return nil, fmt.Errorf("%s has %d values for %d labels", s.Name, len(s.Values), len(c.Labels))
+		}
+	}
+	if c.Type == "pie" {
+		for _, v := range c.Series[0].Values {
+			if v < 0 {
+				return nil, fmt.Errorf("a pie chart cannot show %s", formatChartValue(v))
+			}
+		}
+	}
+	return c, nil
+}
+
+// parseYAML reads the series mapping, of names to lists of values, or the
+// data mapping, of labels to values.
+func (c *Chart) parseYAML(series, data *yaml.Node) error {
+	if data.Kind != 0 {
+		if data.Kind != yaml.MappingNode {
+			return fmt.Errorf("data has to map labels to values")
+		}
+		s := ChartSeries{Name: c.Title}
+		c.Labels = nil
+		for i := 0; i+1 < len(data.Content); i += 2 {
+			v, err := parseChartValue(data.Content[i+1].Value)
+			if err != nil {
+				return err
+			}
+			c.Labels = append(c.Labels, data.Content[i].Value)
+			s.Values = append(s.Values, v)
+		}
+		c.Series = []ChartSeries{s}
+		return nil
+	}
+	if series.Kind != yaml.MappingNode {
+		return fmt.Errorf("series has to map names to lists of values")
+	}
+	for i := 0; i+1 < len(series.Content); i += 2 {
+		s := ChartSeries{Name: series.Content[i].Value}
+		for _, item := range series.Content[i+1].Content {
+			v, err := parseChartValue(item.Value)
+			if err != nil {
+				return err
+			}
+			s.Values = append(s.Values, v)
+		}
+		c.Series = append(c.Series, s)
+	}
+	return nil
+}
+
+// parseCSV reads a chart written as CSV: labels in the first column and a
+// column of values for each series.
+func (c *Chart) parseCSV(code string) error {
+	lines := strings.Split(strings.TrimSpace(code), "\n")
+	for len(lines) > 0 {
+		m := chartSpecRe.FindStringSubmatch(strings.TrimSpace(lines[0]))
+		if m == nil {
+			break
+		}
+		if m[1] == "type" {
+			c.Type = strings.TrimSpace(m[2])
+		} else {
+			c.Title = strings.TrimSpace(m[2])
+		}
+		lines = lines[1:]
+	}
+	reader := csv.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("error reading the chart data: %v", err)
+	}
+	if len(rows) < 2 || len(rows[0]) < 2 {
+		return fmt.Errorf("the chart has no data")
+	}
+	for _, name := range rows[0][1:] {
+		c.Series = append(c.Series, ChartSeries{Name: name})
+	}
+	for _, row := range rows[1:] {
+		if len(row) != len(rows[0]) {
+			return fmt.Errorf("%s has %d values for %d series", row[0], len(row)-1, len(c.Series))
+		}
+		c.Labels = append(c.Labels, row[0])
+		for i, cell := range row[1:] {
+			v, err := parseChartValue(cell)
+			if err != nil {
+				return err
+			}
+			c.Series[i].Values = append(c.Series[i].Values, v)
+		}
+	}
+	return nil
+}
+
+func parseChartValue(text string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("%q is not a number", text)
+	}
+	return v, nil
+}
+
+// formatChartValue writes a value as short as it goes.
+func formatChartValue(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e4)/1e4, 'f', -1, 64)
+}
+
+// chartScale is the range and step of the value axis for values from lo to
+// hi, in steps of 1, 2, 2.5 or 5 times a power of ten.
+func chartScale(lo, hi float64) (float64, float64, float64) {
+	if hi == lo {
+		hi = lo + 1
+	}
+	raw := (hi - lo) / 4
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := magnitude * 10
+	for _, f := range []float64{1, 2, 2.5, 5} {
+		if f*magnitude >= raw {
+			step = f * magnitude
+			break
+		}
+	}
+	return math.Floor(lo/step) * step, math.Ceil(hi/step) * step, step
+}
+
+// chartMark is a shape of a drawn chart: a polyline of the given width, or a
+// filled polygon when the width is 0. An empty color is that of the text.
+type chartMark struct {
+	points []diagramPoint
+	color  string
+	width  float64
+	faint  bool
+}
+
+// chartLabel is a line of text of a drawn chart, on the baseline at its
+// point. align is -1 to start at the point, 0 to be centered on it and 1 to
+// end at it.
+type chartLabel struct {
+	at    diagramPoint
+	text  string
+	align int
+}
+
+// chartDrawing is a chart, or another block drawn like one, laid out in
+// pixels.
+type chartDrawing struct {
+	width, height int
+	title         string
+	marks         []chartMark
+	labels        []chartLabel
+}
+
+// chartRect is a rectangle as a polygon.
+func chartRect(x0, y0, x1, y1 float64) []diagramPoint {
+	return []diagramPoint{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}
+}
+
+// layout places the shapes and labels of the chart.
+func (c *Chart) layout() chartDrawing {
+	marks, labels := c.shapes()
+	return chartDrawing{width: chartWidth, height: chartHeight, title: c.Title, marks: marks, labels: labels}
+}
+
+func (c *Chart) shapes() ([]chartMark, []chartLabel) {
+	var marks []chartMark
+	var labels []chartLabel
+	top := 16.0
+	if c.Title != "" {
+		labels = append(labels, chartLabel{at: diagramPoint{chartWidth / 2, 22}, text: c.Title})
+		top = 40
+	}
+
+	if c.Type == "pie" {
+		values := c.Series[0].Values
+		total := 0.0
+		for _, v := range values {
+			total += v
+		}
+		radius := min(110, (chartHeight-top-16)/2)
+		center := diagramPoint{24 + radius, top + (chartHeight-top-16)/2}
+		angle := -math.Pi / 2
+		legendX, maxChars := 2*radius+64, int((chartWidth-2*radius-96)/chartCharWidth)
+		for i, v := range values {
+			color := chartColors[i%len(chartColors)]
+			if total > 0 && v > 0 {
+				sweep := 2 * math.Pi * v / total
+				wedge := []diagramPoint{center}
+				steps := int(math.Ceil(sweep/0.05)) + 1
+				for j := 0; j <= steps; j++ {
+					a := angle + sweep*float64(j)/float64(steps)
+					wedge = append(wedge, diagramPoint{center.X + radius*math.Cos(a), center.Y + radius*math.Sin(a)})
+				}
+				marks = append(marks, chartMark{points: wedge, color: color})
+				angle += sweep
+			}
+			y := top + 8 + float64(i)*20
+			if y > chartHeight-16 {
+				continue
+			}
+			marks = append(marks, chartMark{points: chartRect(legendX, y-10, legendX+10, y), color: color})
+			share := 0.0
+			if total > 0 {
+				share = 100 * v / total
+			}
+			text := truncate(c.Labels[i], maxChars-6) + " " + strconv.Itoa(int(math.Round(share))) + "%"
+			labels = append(labels, chartLabel{at: diagramPoint{legendX + 16, y}, text: text, align: -1})
+		}
+		return marks, labels
+	}
+
+	bottom := chartHeight - 28.0
+	if len(c.Series) > 1 {
+		// A legend of the series goes below the labels
+		bottom -= 20
+		width := 0.0
+		for _, s := range c.Series {
+			width += float64(16 + chartCharWidth*len([]rune(s.Name)) + 16)
+		}
+		x := (chartWidth - width) / 2
+		for i, s := range c.Series {
+			marks = append(marks, chartMark{points: chartRect(x, chartHeight-20, x+10, chartHeight-10), color: chartColors[i%len(chartColors)]})
+			labels = append(labels, chartLabel{at: diagramPoint{x + 16, chartHeight - 10}, text: s.Name, align: -1})
+			x += float64(16 + chartCharWidth*len([]rune(s.Name)) + 16)
+		}
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, s := range c.Series {
+		for _, v := range s.Values {
+			lo, hi = min(lo, v), max(hi, v)
+		}
+	}
+	if c.Type == "bar" || lo >= 0 {
+		lo, hi = min(lo, 0), max(hi, 0)
+	}
+	lo, hi, step := chartScale(lo, hi)
+	var ticks []string
+	tickWidth := 0
+	for v := lo; v <= hi+step/2; v += step {
+		ticks = append(ticks, formatChartValue(v))
+		tickWidth = max(tickWidth, len(ticks[len(ticks)-1]))
+	}
+	left, right := float64(tickWidth*chartCharWidth+16), chartWidth-16.0
+	y := func(v float64) float64 { return bottom - (v-lo)/(hi-lo)*(bottom-top) }
+	for i, tick := range ticks {
+		ty := y(lo + float64(i)*step)
+		marks = append(marks, chartMark{points: []diagramPoint{{left, ty}, {right, ty}}, width: 1, faint: true})
+		labels = append(labels, chartLabel{at: diagramPoint{left - 6, ty + 4}, text: tick, align: 1})
+	}
+	base := y(min(max(0, lo), hi))
+	marks = append(marks, chartMark{points: []diagramPoint{{left, base}, {right, base}}, width: 1})
+
+	slot := (right - left) / float64(len(c.Labels))
+	for i, label := range c.Labels {
+		labels = append(labels, chartLabel{
+			at:   diagramPoint{left + slot*(float64(i)+0.5), bottom + 18},
+			text: truncate(label, max(2, int(slot/chartCharWidth))),
+		})
+	}
+	for j, s := range c.Series {
+		color := chartColors[j%len(chartColors)]
+		if c.Type == "line" {
+			var points []diagramPoint
+			for i, v := range s.Values {
+				p := diagramPoint{left + slot*(float64(i)+0.5), y(v)}
+				points = append(points, p)
+				marks = append(marks, chartMark{points: diagramCircle(p, 3), color: color})
+			}
+			marks = append(marks, chartMark{points: points, color: color, width: 2})
+			continue
+		}
+		group := slot * 0.7
+		width := group / float64(len(c.Series))
+		for i, v := range s.Values {
+			x := left + slot*float64(i) + (slot-group)/2 + float64(j)*width
+			marks = append(marks, chartMark{points: chartRect(x, min(base, y(v)), x+width, max(base, y(v))), color: color})
+		}
+	}
+	return marks, labels
+}
+
+// SVG draws the chart, with its text and axes in the color of the
+// surrounding text.
+func (c *Chart) SVG() string {
+	return c.layout().SVG("chart")
+}
+
+// Image draws the chart on a transparent background, with its text and
+// axes in fg.
+func (c *Chart) Image(fg color.Color) *image.RGBA {
+	return c.layout().Image(fg)
+}
+
+// SVG draws d as an SVG image of the given class.
+func (d chartDrawing) SVG(class string) string {
+	var shapes, texts strings.Builder
+	for _, mark := range d.marks {
+		var path strings.Builder
+		for i, p := range mark.points {
+			command := "L"
+			if i == 0 {
+				command = "M"
+			}
+			fmt.Fprintf(&path, "%s%.5g %.5g", command, p.X, p.Y)
+		}
+		color := mark.color
+		if color == "" {
+			color = "currentColor"
+		}
+		opacity := ""
+		if mark.faint {
+			opacity = ` opacity=".25"`
+		}
+		if mark.width == 0 {
+			fmt.Fprintf(&shapes, `<path d="%sZ" fill="%s"%s/>`, path.String(), color, opacity)
+		} else {
+			fmt.Fprintf(&shapes, `<path d="%s" fill="none" stroke="%s" stroke-width="%g" stroke-linejoin="round"%s/>`, path.String(), color, mark.width, opacity)
+		}
+	}
+	anchors := map[int]string{-1: "start", 0: "middle", 1: "end"}
+	for _, label := range d.labels {
+		fmt.Fprintf(&texts, `<text x="%.5g" y="%.5g" text-anchor="%s">%s</text>`, label.at.X, label.at.Y, anchors[label.align], html.EscapeString(label.text))
+	}
+	return fmt.Sprintf(`<svg class="%s" xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" role="img"><title>%s</title>%s`+
+		`<g fill="currentColor" font-family="sans-serif" font-size="12">%s</g></svg>`,
+		class, d.width, d.height, d.width, d.height, html.EscapeString(d.title), shapes.String(), texts.String())
+}
+
+// Image draws d on a transparent background, with marks that have no
+// color of their own and the text in fg.
+func (d chartDrawing) Image(fg color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, d.width, d.height))
+	for _, mark := range d.marks {
+		col := color.NRGBAModel.Convert(fg).(color.NRGBA)
+		if mark.color != "" {
+			fmt.Sscanf(mark.color, "#%02x%02x%02x", &col.R, &col.G, &col.B)
+			col.A = 0xff
+		}
+		if mark.faint {
+			col.A /= 4
+		}
+		r := vector.NewRasterizer(d.width, d.height)
+		points := append([]diagramPoint(nil), mark.points...)
+		if mark.width == 0 {
+			rasterPolygon(r, points, true)
+		} else {
+			rasterLine(r, points, mark.width)
+		}
+		r.Draw(img, img.Bounds(), image.NewUniform(col), image.Point{})
+	}
+	drawer := font.Drawer{Dst: img, Src: image.NewUniform(fg), Face: basicfont.Face7x13}
+	for _, label := range d.labels {
+		x := label.at.X - float64((label.align+1)*chartCharWidth*len([]rune(label.text))/2)
+		drawer.Dot = fixed.P(int(x), int(label.at.Y))
+		drawer.DrawString(label.text)
+	}
+	return img
+}
+
+// drawing draws a chart, or another block laid out like one, as vector
+// graphics, in the colors of code blocks for its text and axes.
+func (w *pdfWriter) drawing(d chartDrawing) {
+	pdf := w.pdf
+	left, _, right, bottom := pdf.GetMargins()
+	pageWidth, pageHeight := pdf.GetPageSize()
+	k := min(25.4/96, (pageWidth-left-right)/float64(d.width))
+	if pdf.GetY()+float64(d.height)*k > pageHeight-bottom {
+		pdf.AddPage()
+	}
+	x0, y0 := left, pdf.GetY()
+
+	for _, mark := range d.marks {
+		rgb := [3]int{36, 41, 47}
+		if mark.color != "" {
+			fmt.Sscanf(mark.color, "#%02x%02x%02x", &rgb[0], &rgb[1], &rgb[2])
+		}
+		if mark.faint {
+			pdf.SetAlpha(0.25, "Normal")
+		}
+		pdf.MoveTo(x0+mark.points[0].X*k, y0+mark.points[0].Y*k)
+		for _, p := range mark.points[1:] {
+			pdf.LineTo(x0+p.X*k, y0+p.Y*k)
+		}
+		if mark.width == 0 {
+			pdf.SetFillColor(rgb[0], rgb[1], rgb[2])
+			pdf.ClosePath()
+			pdf.DrawPath("F")
+		} else {
+			pdf.SetDrawColor(rgb[0], rgb[1], rgb[2])
+			pdf.SetLineWidth(mark.width * k)
+			pdf.DrawPath("D")
+		}
+		pdf.SetAlpha(1, "Normal")
+	}
+	pdf.SetFont(pdfFont, "", 12*k*72/25.4)
+	pdf.SetTextColor(36, 41, 47)
+	for _, label := range d.labels {
+		text := w.tr(label.text)
+		x := x0 + label.at.X*k - float64(label.align+1)*pdf.GetStringWidth(text)/2
+		pdf.Text(x, y0+label.at.Y*k, text)
+	}
+	pdf.SetLineWidth(0.2)
+	pdf.SetY(y0 + float64(d.height)*k + 3)
+	w.resetText()
+}
+
+// htmlChartRe finds the chart blocks of exported HTML.
+var htmlChartRe = regexp.MustCompile(`(?s)<pre><code class="language-chart">(.*?)</code></pre>`)
+
+// chartHTML replaces the chart blocks of body with SVG drawings. Blocks
+// that are not a chart stay as they are.
+func chartHTML(body string) string {
+	return htmlChartRe.ReplaceAllStringFunc(body, func(match string) string {
+		c, err := ParseChart(html.UnescapeString(htmlChartRe.FindStringSubmatch(match)[1]))
+		if err != nil {
+			return match
+		}
+		return "<p>" + c.SVG() + "</p>"
+	})
+}
+
+// sparkline draws values in one line of block characters, or of ASCII
+// characters when the terminal has no Unicode.
+func sparkline(values []float64, lo, hi float64) string {
+	levels := []rune("▁▂▃▄▅▆▇█")
+	if !termCaps.Unicode {
+		levels = []rune("_.-=+*#@")
+	}
+	var line strings.Builder
+	for _, v := range values {
+		i := 0
+		if hi > lo {
+			i = int((v - lo) / (hi - lo) * float64(len(levels)-1))
+		}
+		line.WriteRune(levels[i])
+	}
+	return line.String()
+}
+
+// chartBar is a bar of value against the largest, in whole and eighth
+// blocks at most width cells long.
+func chartBar(value, largest float64, width int) string {
+	if largest <= 0 || value <= 0 {
+		return ""
+	}
+	eighths := int(math.Round(value / largest * float64(width*8)))
+	if !termCaps.Unicode {
+		return strings.Repeat("#", eighths/8)
+	}
+	bar := strings.Repeat("█", eighths/8)
+	if rest := eighths % 8; rest > 0 {
+		bar += string([]rune("▏▎▍▌▋▊▉")[rest-1])
+	}
+	return bar
+}
+
+// Terminal draws the chart in text: bars of block characters for bar and
+// pie charts and a sparkline for each series of a line chart.
+func (c *Chart) Terminal(width int) []string {
+	var lines []string
+	if c.Title != "" {
+		lines = append(lines, lipgloss.NewStyle().Bold(true).Foreground(termStrongColor).Render(truncate(c.Title, width)))
+	}
+	style := func(i int) lipgloss.Style {
+		return lipgloss.NewStyle().Foreground(lipgloss.Color(chartColors[i%len(chartColors)]))
+	}
+	muted := lipgloss.NewStyle().Foreground(termMutedColor)
+
+	if c.Type == "line" {
+		nameWidth := 0
+		for _, s := range c.Series {
+			nameWidth = max(nameWidth, len([]rune(s.Name)))
+		}
+		nameWidth = min(nameWidth, width/3)
+		for i, s := range c.Series {
+			lo, hi := math.Inf(1), math.Inf(-1)
+			for _, v := range s.Values {
+				lo, hi = min(lo, v), max(hi, v)
+			}
+			values := s.Values
+			if room := width - nameWidth - 1; len(values) > room && room > 0 {
+				// The latest values are the ones that fit
+				values = values[len(values)-room:]
+			}
+			name := fmt.Sprintf("%-*s", nameWidth, truncate(s.Name, nameWidth))
+			line := name + " " + style(i).Render(sparkline(values, lo, hi))
+			lines = append(lines, line)
+			lines = append(lines, strings.Repeat(" ", nameWidth+1)+muted.Render(truncate(formatChartValue(lo)+" – "+formatChartValue(hi), width-nameWidth-1)))
+		}
+		return lines
+	}
+
+	series := c.Series
+	if c.Type == "pie" {
+		series = series[:1]
+	}
+	labelWidth, valueWidth, largest, total := 0, 0, 0.0, 0.0
+	for _, label := range c.Labels {
+		labelWidth = max(labelWidth, len([]rune(label)))
+	}
+	labelWidth = min(labelWidth, width/3)
+	for _, s := range series {
+		for _, v := range s.Values {
+			largest, total = max(largest, v), total+v
+			valueWidth = max(valueWidth, len(formatChartValue(v)))
+		}
+	}
+	if c.Type == "pie" {
+		// Slices are shares of the whole
+		largest, valueWidth = total, 4
+	}
+	room := width - labelWidth - valueWidth - 3
+	for i, label := range c.Labels {
+		for j, s := range series {
+			text := ""
+			if j == 0 {
+				text = truncate(label, labelWidth)
+			}
+			value := formatChartValue(s.Values[i])
+			color := j
+			if c.Type == "pie" {
+				color = i
+			}
+			if c.Type == "pie" && total > 0 {
+				value = strconv.Itoa(int(math.Round(100*s.Values[i]/total))) + "%"
+			}
+			bar := chartBar(s.Values[i], largest, room)
+			lines = append(lines, fmt.Sprintf("%-*s ", labelWidth, text)+style(color).Render(bar)+" "+muted.Render(value))
+		}
+	}
+	if len(series) > 1 {
+		var legend []string
+		for j, s := range series {
+			legend = append(legend, style(j).Render("■")+" "+s.Name)
+		}
+		lines = append(lines, ansi.Truncate(strings.Join(legend, "  "), width, "…"))
+	}
+	return lines
+}
+
+// chartBlock shows a chart block in the terminal preview, or the block
+// with what is wrong with it when it is not a chart.
+func (r *terminalRenderer) chartBlock(code, info string, width int) []string {
+	c, err := ParseChart(code)
+	if err != nil {
+		note := lipgloss.NewStyle().Foreground(termMutedColor).Render("Chart: " + err.Error())
+		return append(r.codeBlock(code, info, width), note)
+	}
+	return c.Terminal(width)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type bindingGroup struct {
+	name     string
+	bindings []key.Binding
+}
+
+var (
+	cheatsheetGroupStyle = lipgloss.NewStyle().
+				Bold(true).
+				Underline(true)
+
+	cheatsheetKeyStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("#04B575"))
+)
+
+// groups sorts the bindings into the categories shown by the cheat sheet.
+func (k keyMap) groups() []bindingGroup {
+	return []bindingGroup{
+		{"File", []key.Binding{k.save, k.files, k.quit}},
+		{"Buffers", []key.Binding{k.nextBuffer, k.prevBuffer, k.close}},
+		{"Edit", []key.Binding{k.undo, k.redo}},
+		{"Lines", []key.Binding{k.lineUp, k.lineDown, k.duplicate, k.deleteLine, k.join, k.sentences}},
+		{"Selection", []key.Binding{k.expand, k.shrink}},
+		{"Cursor", []key.Binding{k.wordLeft, k.wordRight, k.delWordL, k.delWordR, k.lineStart, k.lineEnd, k.paraUp, k.paraDown}},
+		{"Format", []key.Binding{k.bold, k.italic, k.inlineCode, k.link, k.image, k.codeBlock, k.insertCode, k.codeLang, k.table, k.task, k.escape, k.strip}},
+		{"Navigate", []key.Binding{k.match, k.nextHead, k.prevHead, k.nextCode, k.prevCode}},
+		{"View", []key.Binding{k.edit, k.preview, k.split, k.outline}},
+		{"Tasks", []key.Binding{k.nextTask, k.prevTask, k.toggleTask}},
+		{"Tools", []key.Binding{k.lint, k.replace, k.sort, k.stats, k.tools, k.runCommand, k.refreshOut}},
+		{"Help", []key.Binding{k.help, k.cheatsheet}},
+	}
+}
+
+// cheatsheetView lays the groups out side by side, wrapping onto further
+// rows when the terminal is too narrow for all of them.
+func (k keyMap) cheatsheetView(width int) string {
+	var columns []string
+	for _, group := range k.groups() {
+		keyWidth := 0
+		for _, binding := range group.bindings {
+			keyWidth = max(keyWidth, lipgloss.Width(binding.Help().Key))
+		}
+
+		lines := []string{cheatsheetGroupStyle.Render(group.name)}
+		for _, binding := range group.bindings {
+			if !binding.Enabled() {
+				continue
+			}
+			keyText := cheatsheetKeyStyle.Width(keyWidth).Render(binding.Help().Key)
+			lines = append(lines, keyText+"  "+helpStyle.Render(binding.Help().Desc))
+		}
+		columns = append(columns, lipgloss.NewStyle().PaddingRight(4).Render(lipgloss.JoinVertical(lipgloss.Left, lines...)))
+	}
+
+	var rows []string
+	var row []string
+	rowWidth := 0
+	for _, column := range columns {
+		if len(row) > 0 && rowWidth+lipgloss.Width(column) > width-4 {
+			rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, row...))
+			row, rowWidth = nil, 0
+		}
+		row = append(row, column)
+		rowWidth += lipgloss.Width(column)
+	}
+	rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, row...))
+
+	footer := helpStyle.Render("press a key to run it • esc: close")
+	body := lipgloss.JoinVertical(lipgloss.Left, titleStyle.Render("Key Bindings"), "", lipgloss.JoinVertical(lipgloss.Left, rows...), "", footer)
+	return pickerStyle.Render(body)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxClips is how many cuts and copies the clip history keeps.
+const maxClips = 30
+
+// addClip puts text at the front of clips, the most recent first, moving it
+// there when it is already in the history. Blank text is not kept.
+func addClip(clips []string, text string) []string {
+	if strings.TrimSpace(text) == "" {
+		return clips
+	}
+	kept := []string{text}
+	for _, clip := range clips {
+		if clip != text && len(kept) < maxClips {
+			kept = append(kept, clip)
+		}
+	}
+	return kept
+}
+
+// clipItems lists clips for a picker: the first line of each, with the
+// number of lines when there are more.
+func clipItems(clips []string) []pickerItem {
+	items := make([]pickerItem, len(clips))
+	for i, clip := range clips {
+		first, _, _ := strings.Cut(strings.TrimSpace(clip), "\n")
+		items[i] = pickerItem{title: truncate(strings.TrimSpace(first), 60), index: i}
+		if lines := strings.Count(strings.TrimRight(clip, "\n"), "\n") + 1; lines > 1 {
+			items[i].detail = fmt.Sprintf("%d lines", lines)
+		}
+	}
+	return items
+}
+
+// clipRange is what copy and cut take in the terminal: the selection, or the
+// line under the cursor.
+func (m *model) clipRange() TextRange {
+	if m.selection != nil {
+		return *m.selection
+	}
+	return m.lineRange()
+}
+
+// lineRange is the line under the cursor with its line break.
+func (m *model) lineRange() TextRange {
+	content := m.textarea.Value()
+	start := runeOffset(content, m.textarea.Line(), 0)
+	end := len(content)
+	if next := strings.IndexByte(content[start:], '\n'); next >= 0 {
+		end = start + next + 1
+	}
+	return TextRange{Start: start, End: end}
+}
+
+// copyClip copies the selection or the current line to the clipboard and
+// the clip history.
+func (m *model) copyClip() {
+	r := m.clipRange()
+	text := m.textarea.Value()[r.Start:r.End]
+	m.keepClip(text)
+	m.status = "Copied " + clipSize(text)
+}
+
+// cutClip is copyClip that also removes what it copies.
+func (m *model) cutClip() {
+	r := m.clipRange()
+	content := m.textarea.Value()
+	text := content[r.Start:r.End]
+	if text == "" {
+		return
+	}
+	m.keepClip(text)
+	m.clearSelection()
+	m.replaceRange(r, "")
+	m.status = "Cut " + clipSize(text)
+}
+
+// keepClip puts text in the clip history and on the system clipboard.
+func (m *model) keepClip(text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	m.clips = addClip(m.clips, text)
+	copyToClipboard(text)
+}
+
+// killText forwards one of the textarea's own deleting keys, ctrl+k and
+// ctrl+u, keeping what it deleted in the clip history.
+func (m *model) killText(msg tea.KeyMsg) tea.Cmd {
+	before := m.textarea.Value()
+	cmd := m.forwardKey(msg)
+	if deleted := removedText(before, m.textarea.Value()); deleted != "" {
+		m.clips = addClip(m.clips, deleted)
+	}
+	return cmd
+}
+
+// removedText is the text taken out of before to leave after, when that is
+// all that changed.
+func removedText(before, after string) string {
+	if len(after) >= len(before) {
+		return ""
+	}
+	prefix := 0
+	for prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	if before[prefix+len(before)-len(after):] != after[prefix:] {
+		return ""
+	}
+	return before[prefix : prefix+len(before)-len(after)]
+}
+
+// openClips shows the clip history, to paste one of the clips.
+func (m *model) openClips() {
+	if len(m.clips) == 0 {
+		m.status = "Nothing cut or copied yet"
+		return
+	}
+	m.overlay = overlayClips
+	m.picker = newPicker("Paste from History", clipItems(m.clips))
+}
+
+// pasteClip puts clip i of the history at the cursor, over the selection
+// when there is one, and moves it to the front of the history.
+func (m *model) pasteClip(i int) {
+	clip := m.clips[i]
+	r := TextRange{Start: m.cursorOffset(), End: m.cursorOffset()}
+	if m.selection != nil {
+		r = *m.selection
+		m.clearSelection()
+	}
+	m.replaceRange(r, clip)
+	m.clips = addClip(m.clips, clip)
+	m.status = "Pasted " + clipSize(clip)
+}
+
+// replaceRange replaces r of the document with text and puts the cursor
+// after it.
+func (m *model) replaceRange(r TextRange, text string) {
+	content := m.textarea.Value()
+	updated := content[:r.Start] + text + content[r.End:]
+	m.textarea.SetValue(updated)
+	row, col := rowColumn(updated, r.Start+len(text))
+	moveCursorTo(&m.textarea, row, col)
+	m.content = updated
+	if m.mode == splitMode {
+		m.refreshPreview()
+		m.syncPreviewScroll()
+	}
+}
+
+// clipSize describes text for the status line.
+func clipSize(text string) string {
+	if lines := strings.Count(strings.TrimRight(text, "\n"), "\n") + 1; lines > 1 {
+		return fmt.Sprintf("%d lines", lines)
+	}
+	return fmt.Sprintf("%d characters", len([]rune(strings.TrimRight(text, "\n"))))
+}
+
+// clipShortcut runs a cut or copy shortcut on the focused widget, keeping
+// the selection in the clip history when that is the editor.
+func (g *GUIApp) clipShortcut(shortcut fyne.Shortcut) {
+	focused, ok := g.window.Canvas().Focused().(fyne.Shortcutable)
+	if !ok {
+		return
+	}
+	if focused == fyne.Shortcutable(g.editor) {
+		g.clips = addClip(g.clips, g.editor.SelectedText())
+	}
+	focused.TypedShortcut(shortcut)
+}
+
+// pasteFromHistory shows the clip history, newest first, and pastes the
+// chosen clip over the selection.
+func (g *GUIApp) pasteFromHistory() {
+	if len(g.clips) == 0 {
+		dialog.ShowInformation("Paste from History", "Nothing has been cut or copied yet.", g.window)
+		return
+	}
+	items := clipItems(g.clips)
+	var visible []pickerItem
+	filter := func(query string) {
+		query = strings.ToLower(strings.TrimSpace(query))
+		visible = visible[:0]
+		for _, item := range items {
+			if query == "" || strings.Contains(strings.ToLower(g.clips[item.index]), query) {
+				visible = append(visible, item)
+			}
+		}
+	}
+	filter("")
+
+	list := widget.NewList(
+		func() int { return len(visible) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			text := visible[id].title
+			if visible[id].detail != "" {
+				text += " (" + visible[id].detail + ")"
+			}
+			obj.(*widget.Label).SetText(text)
+		},
+	)
+	search := widget.NewEntry()
+	search.SetPlaceHolder("Search clips...")
+
+	var d dialog.Dialog
+	choose := func(item pickerItem) {
+		d.Hide()
+		g.pasteClip(item.index)
+	}
+	list.OnSelected = func(id widget.ListItemID) { choose(visible[id]) }
+	search.OnChanged = func(query string) {
+		filter(query)
+		list.UnselectAll()
+		list.Refresh()
+	}
+	search.OnSubmitted = func(string) {
+		if len(visible) > 0 {
+			choose(visible[0])
+		}
+	}
+
+	d = dialog.NewCustom("Paste from History", "Cancel", container.NewBorder(search, nil, nil, nil, list), g.window)
+	d.Resize(fyne.NewSize(480, 420))
+	d.Show()
+	g.window.Canvas().Focus(search)
+}
+
+func (g *GUIApp) pasteClip(i int) {
+	clip := g.clips[i]
+	content := g.editor.Text
+	text := []rune(content)
+	start, end := g.selectedRange()
+	sel := TextRange{Start: len(string(text[:start])), End: len(string(text[:end]))}
+	g.editor.SetText(content[:sel.Start] + clip + content[sel.End:])
+	cursor := sel.Start + len(clip)
+	g.selectRange(TextRange{Start: cursor, End: cursor})
+	g.clips = addClip(g.clips, clip)
+	g.window.Canvas().Focus(g.editor)
+}
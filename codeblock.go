@@ -0,0 +1,395 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/atotto/clipboard"
+	"github.com/muesli/termenv"
+	"github.com/yuin/goldmark/ast"
+)
+
+var codeFenceRe = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*([^ \t`]*)")
+
+// codeLanguageFirst are offered at the top of the language picker, ahead
+// of the rest of the highlighter's languages.
+var codeLanguageFirst = []string{
+	"go", "python", "javascript", "typescript", "bash", "json", "yaml", "toml", "html", "css",
+	"sql", "rust", "c", "cpp", "java", "ruby", "php", "markdown", "diff", "dockerfile", "text",
+}
+
+// codeDetectors guess the language of a snippet; the first match wins, so
+// the more specific ones come first.
+var codeDetectors = []struct {
+	lang string
+	re   *regexp.Regexp
+}{
+	{"bash", regexp.MustCompile(`^#!.*\b(ba|z)?sh\b`)},
+	{"python", regexp.MustCompile(`^#!.*\bpython`)},
+	{"javascript", regexp.MustCompile(`^#!.*\bnode\b`)},
+	{"php", regexp.MustCompile(`^<\?php`)},
+	{"xml", regexp.MustCompile(`^<\?xml`)},
+	{"html", regexp.MustCompile(`(?i)^<(!doctype html|html|head|body|div|p|span|ul|table)\b`)},
+	{"diff", regexp.MustCompile(`(?m)^(diff --git |@@ -\d+(,\d+)? \+\d+)`)},
+	{"dockerfile", regexp.MustCompile(`(?m)^FROM \S+(\s|$)[\s\S]*^(RUN|COPY|CMD|ENTRYPOINT) `)},
+	{"go", regexp.MustCompile(`(?m)^(package \w+$|func (\(\w+ \*?\w+\) )?\w+\(|import \(|\s*\w+ := )|\bfmt\.\w+\(`)},
+	{"rust", regexp.MustCompile(`(?m)^\s*(pub )?fn \w+(<[^>]*>)?\(|\blet mut \b|^use \w+(::\w+)+;`)},
+	{"cpp", regexp.MustCompile(`(?m)^#include <(iostream|vector|string|map)>|\bstd::`)},
+	{"c", regexp.MustCompile(`(?m)^#include [<"]|^int main\(`)},
+	{"java", regexp.MustCompile(`\bpublic static void main\(|\bSystem\.out\.print|(?m)^import java\.`)},
+	{"typescript", regexp.MustCompile(`(?m)^\s*(export )?(interface|type) \w+|\w+: (string|number|boolean)\b`)},
+	{"javascript", regexp.MustCompile(`\bconsole\.log\(|(?m)^\s*(const|let|var) \w+ = |\bfunction \w*\(|=> \{|\brequire\(`)},
+	{"python", regexp.MustCompile(`(?m)^\s*(def \w+\(.*\):|class \w+(\(.*\))?:|from [\w.]+ import |import \w+$|if __name__ == )|\bprint\(`)},
+	{"ruby", regexp.MustCompile(`(?m)^\s*(def \w+[?!]?(\(.*\))?$|require ['"]|puts |end$)`)},
+	{"sql", regexp.MustCompile(`(?im)^\s*(select .+ from|insert into|create table|update \w+ set|delete from)\b`)},
+	{"css", regexp.MustCompile(`(?m)^[.#@]?[\w-][^{]*\{\s*$[\s\S]*^\s*[\w-]+:\s*[^;]+;`)},
+	{"toml", regexp.MustCompile(`(?m)^\[[\w.-]+\]\s*$[\s\S]*^[\w-]+ = `)},
+	{"yaml", regexp.MustCompile(`(?m)^---\s*$|^[\w-]+:( .+)?$[\s\S]*^[\w-]+:( .+)?$`)},
+	{"bash", regexp.MustCompile(`(?m)^\s*\$ |^\s*(sudo|apt|apt-get|brew|npm|go|git|cd|echo|export|mkdir|curl) `)},
+}
+
+// codeFence is a fenced code block. Open and Close are its fence lines and
+// the offsets are bytes of the document; Info is where the language goes on
+// the opening line.
+type codeFence struct {
+	Lang      string
+	Open      int
+	Close     int
+	Info      TextRange
+	BodyStart int
+	BodyEnd   int
+}
+
+// codeFences finds the fenced code blocks of content. One without a
+// closing fence runs to the end, as it does in the preview.
+func codeFences(content string) []codeFence {
+	var fences []codeFence
+	var current *codeFence
+	marker := ""
+	offset := 0
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lineEnd := offset + len(line)
+		matches := codeFenceRe.FindStringSubmatchIndex(line)
+		switch {
+		case current == nil && matches != nil:
+			marker = line[matches[2]:matches[3]]
+			current = &codeFence{
+				Lang:      line[matches[4]:matches[5]],
+				Open:      i,
+				Info:      TextRange{Start: offset + matches[4], End: offset + matches[5]},
+				BodyStart: min(lineEnd+1, len(content)),
+			}
+		case current != nil && strings.TrimSpace(line) != "" && strings.Trim(strings.TrimSpace(line), marker[:1]) == "" &&
+			len(strings.TrimSpace(line)) >= len(marker):
+			current.Close = i
+			current.BodyEnd = max(offset-1, current.BodyStart)
+			fences = append(fences, *current)
+			current = nil
+		}
+		offset = lineEnd + 1
+	}
+	if current != nil {
+		current.Close = len(lines) - 1
+		current.BodyEnd = len(content)
+		fences = append(fences, *current)
+	}
+	return fences
+}
+
+// codeFenceAt returns the fenced code block that pos is in, its fence lines
+// included.
+func codeFenceAt(content string, pos int) (codeFence, bool) {
+	line := strings.Count(content[:pos], "\n")
+	for _, fence := range codeFences(content) {
+		if line >= fence.Open && line <= fence.Close {
+			return fence, true
+		}
+	}
+	return codeFence{}, false
+}
+
+// fenceInfo is what follows the opening fence of node: the language and
+// anything after it.
+func fenceInfo(node *ast.FencedCodeBlock, source []byte) string {
+	if node.Info == nil {
+		return ""
+	}
+	return strings.TrimSpace(string(node.Info.Segment.Value(source)))
+}
+
+// fenceLanguage is the language at the start of a fence info string.
+func fenceLanguage(info string) string {
+	if i := strings.IndexAny(info, " \t{"); i >= 0 {
+		return info[:i]
+	}
+	return info
+}
+
+// maxHighlightedLines bounds a range of highlighted lines, so that a typo
+// such as {1-1000000000} costs nothing.
+const maxHighlightedLines = 10000
+
+// fenceHighlights reads the lines to highlight from the braces of a fence
+// info string, as in ```go {3-5,8}. Lines count from 1 there and from 0 in
+// the result; what does not parse is left out.
+func fenceHighlights(info string) map[int]bool {
+	open, end := strings.Index(info, "{"), strings.LastIndex(info, "}")
+	if open < 0 || end < open {
+		return nil
+	}
+	lines := map[int]bool{}
+	for _, part := range strings.Split(info[open+1:end], ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		first, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil || first < 1 {
+			continue
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(strings.TrimSpace(to)); err != nil || last < first {
+				continue
+			}
+		}
+		for line := first; line <= min(last, first+maxHighlightedLines); line++ {
+			lines[line-1] = true
+		}
+	}
+	return lines
+}
+
+func (f codeFence) body(content string) string {
+	return content[f.BodyStart:f.BodyEnd]
+}
+
+// SetLanguage replaces the language on the opening fence line.
+func (f codeFence) SetLanguage(content, lang string) string {
+	return content[:f.Info.Start] + lang + content[f.Info.End:]
+}
+
+// DetectLanguage guesses the language of a code snippet, or returns "" when
+// it cannot tell.
+func DetectLanguage(code string) string {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return ""
+	}
+	if (strings.HasPrefix(code, "{") || strings.HasPrefix(code, "[")) && json.Valid([]byte(code)) {
+		return "json"
+	}
+	for _, detector := range codeDetectors {
+		if detector.re.MatchString(code) {
+			return detector.lang
+		}
+	}
+	if lexer := lexers.Analyse(code); lexer != nil {
+		return codeLanguageTag(lexer.Config().Name, lexer.Config().Aliases)
+	}
+	return ""
+}
+
+// codeLanguage is a language of the picker: the tag written after the
+// fence and the highlighter's name for it.
+type codeLanguage struct {
+	tag  string
+	name string
+}
+
+func codeLanguageTag(name string, aliases []string) string {
+	if len(aliases) > 0 {
+		return aliases[0]
+	}
+	return strings.ToLower(strings.ReplaceAll(name, " ", "-"))
+}
+
+// codeLanguages lists the languages for the picker: the suggestions first,
+// then the common ones, then everything else the highlighter knows.
+func codeLanguages(suggested ...string) []codeLanguage {
+	known := map[string]codeLanguage{}
+	var rest []codeLanguage
+	for _, lexer := range lexers.GlobalLexerRegistry.Lexers {
+		config := lexer.Config()
+		language := codeLanguage{tag: codeLanguageTag(config.Name, config.Aliases), name: config.Name}
+		if _, ok := known[language.tag]; ok {
+			continue
+		}
+		known[language.tag] = language
+		rest = append(rest, language)
+	}
+	sort.Slice(rest, func(i, j int) bool { return rest[i].tag < rest[j].tag })
+
+	var languages []codeLanguage
+	seen := map[string]bool{}
+	add := func(tag string) {
+		if tag == "" || seen[tag] {
+			return
+		}
+		seen[tag] = true
+		language, ok := known[tag]
+		if !ok {
+			if lexer := lexers.Get(tag); lexer != nil {
+				language = codeLanguage{tag: tag, name: lexer.Config().Name}
+			} else {
+				language = codeLanguage{tag: tag, name: tag}
+			}
+		}
+		languages = append(languages, language)
+	}
+	for _, tag := range suggested {
+		add(tag)
+	}
+	for _, tag := range codeLanguageFirst {
+		add(tag)
+	}
+	for _, language := range rest {
+		add(language.tag)
+	}
+	return languages
+}
+
+// codeBlockWith fences the lines with lang, leaving the cursor at the start
+// of the code.
+func codeBlockWith(lang string) func(content string, sel TextRange) (string, TextRange) {
+	return lineFormat(func(lines string) (string, int) {
+		return "```" + lang + "\n" + lines + "\n```", len(lang) + 4
+	})
+}
+
+// openLanguagePicker offers the languages for the code block under the
+// cursor when change is set, and otherwise for a new code block around the
+// selected or current lines. The language the code looks like comes first.
+func (m *model) openLanguagePicker(change bool) {
+	content := m.textarea.Value()
+	cursor := m.cursorOffset()
+	m.languageFence = nil
+	m.languageSel = TextRange{Start: cursor, End: cursor}
+	if m.selection != nil {
+		m.languageSel = *m.selection
+	}
+
+	var code, current string
+	if change {
+		fence, ok := codeFenceAt(content, cursor)
+		if !ok {
+			m.status = "Not in a fenced code block"
+			return
+		}
+		m.languageFence = &fence
+		code, current = fence.body(content), fence.Lang
+	} else {
+		start := strings.LastIndex(content[:m.languageSel.Start], "\n") + 1
+		end := strings.Index(content[m.languageSel.End:], "\n")
+		if end < 0 {
+			end = len(content)
+		} else {
+			end += m.languageSel.End
+		}
+		code = content[start:end]
+	}
+
+	detected := DetectLanguage(code)
+	m.languages = codeLanguages(current, detected)
+	var items []pickerItem
+	for i, language := range m.languages {
+		detail := language.name
+		switch language.tag {
+		case current:
+			detail += " · current"
+		case detected:
+			detail += " · detected"
+		}
+		items = append(items, pickerItem{title: language.tag, detail: detail, index: i})
+	}
+	title := "Insert Code Block"
+	if change {
+		title = "Code Block Language"
+	}
+	m.overlay = overlayLanguage
+	m.picker = newPicker(title, items)
+}
+
+func (m *model) applyLanguage(lang string) {
+	content := m.textarea.Value()
+	var updated string
+	var cursor int
+	if fence := m.languageFence; fence != nil {
+		updated = fence.SetLanguage(content, lang)
+		cursor = m.cursorOffset()
+		switch {
+		case cursor >= fence.Info.End:
+			cursor += len(lang) - (fence.Info.End - fence.Info.Start)
+		case cursor > fence.Info.Start:
+			cursor = fence.Info.Start + len(lang)
+		}
+		m.status = "Code block language: " + lang
+	} else {
+		var result TextRange
+		updated, result = codeBlockWith(lang)(content, m.languageSel)
+		cursor = result.End
+	}
+
+	m.clearSelection()
+	m.textarea.SetValue(updated)
+	row, col := rowColumn(updated, cursor)
+	moveCursorTo(&m.textarea, row, col)
+	m.content = updated
+	if m.mode == splitMode {
+		m.refreshPreview()
+	}
+}
+
+// suggestLanguage names the language of code pasted into a code block that
+// has none yet.
+func (m *model) suggestLanguage() {
+	content := m.textarea.Value()
+	fence, ok := codeFenceAt(content, m.cursorOffset())
+	if !ok || fence.Lang != "" {
+		return
+	}
+	if lang := DetectLanguage(fence.body(content)); lang != "" {
+		m.status = fmt.Sprintf("Looks like %s: %s sets the code block language", lang, m.keys.codeLang.Help().Key)
+	}
+}
+
+// copyCodeBlock copies the code of the code block the cursor is in, or in
+// preview mode of the first one on screen.
+func (m *model) copyCodeBlock() {
+	content := m.textarea.Value()
+	fence, ok := codeFenceAt(content, m.cursorOffset())
+	if m.mode == previewMode && m.scrollMap != nil {
+		fence, ok = m.previewCodeFence(content)
+	}
+	if !ok {
+		m.status = "No code block here"
+		return
+	}
+	code := fence.body(content)
+	m.keepClip(code)
+	m.status = fmt.Sprintf("Copied %d lines of code", strings.Count(code, "\n")+1)
+}
+
+// previewCodeFence finds the first code block that the preview shows some
+// of.
+func (m *model) previewCodeFence(content string) (codeFence, bool) {
+	top, bottom := m.viewport.YOffset, m.viewport.YOffset+m.viewport.Height
+	for _, fence := range codeFences(content) {
+		if m.scrollMap.RenderedLine(fence.Close+1) > top && m.scrollMap.RenderedLine(fence.Open) < bottom {
+			return fence, true
+		}
+	}
+	return codeFence{}, false
+}
+
+// copyToClipboard puts text on the system clipboard, or where there is none
+// to reach, such as over SSH, asks the terminal to with OSC 52.
+func copyToClipboard(text string) {
+	if err := clipboard.WriteAll(text); err == nil {
+		return
+	}
+	termenv.Copy(text)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// commandCaptionRe matches the caption of a command output block: the
+// command as inline code after a "$ " prompt.
+var commandCaptionRe = regexp.MustCompile("^(`+) ?\\$ (.*?) ?(`+)[ \t]*$")
+
+// CommandBlock is the markdown for what command printed: a text code block
+// with the command as its caption on the line above, from where a refresh
+// can run it again.
+func CommandBlock(command, output string) string {
+	output = strings.TrimRight(output, "\n")
+	ticks := "`"
+	for strings.Contains(command, ticks) {
+		ticks += "`"
+	}
+	caption := ticks + "$ " + command + ticks
+	if ticks != "`" {
+		caption = ticks + " $ " + command + " " + ticks
+	}
+	fence := "```"
+	for strings.Contains(output, fence) {
+		fence += "`"
+	}
+	return caption + "\n" + fence + "text\n" + output + "\n" + fence
+}
+
+// commandBlock is a command output block in a document; Range runs from the
+// start of the caption to the end of the closing fence.
+type commandBlock struct {
+	Command string
+	Range   TextRange
+}
+
+// commandBlockAt finds the command output block that pos is in, its caption
+// included.
+func commandBlockAt(content string, pos int) (commandBlock, bool) {
+	lines := strings.Split(content, "\n")
+	starts := make([]int, len(lines))
+	for i := 1; i < len(lines); i++ {
+		starts[i] = starts[i-1] + len(lines[i-1]) + 1
+	}
+	line := strings.Count(content[:pos], "\n")
+	for _, fence := range codeFences(content) {
+		caption := fence.Open - 1
+		if caption < 0 || line < caption || line > fence.Close {
+			continue
+		}
+		matches := commandCaptionRe.FindStringSubmatch(lines[caption])
+		if matches == nil || matches[1] != matches[3] {
+			continue
+		}
+		end := starts[fence.Close] + len(lines[fence.Close])
+		return commandBlock{Command: matches[2], Range: TextRange{Start: starts[caption], End: end}}, true
+	}
+	return commandBlock{}, false
+}
+
+// RunCommandBlock runs command in the directory of file and returns the
+// command output block for it.
+func RunCommandBlock(command, file string) (string, error) {
+	output, err := runCommand(command, command, "command", file)
+	if err != nil {
+		return "", err
+	}
+	return CommandBlock(command, output), nil
+}
+
+// commandDoneMsg brings back a command output block. content is the document
+// when the command started, and target the selection to put a new block
+// after, or the block to replace on a refresh.
+type commandDoneMsg struct {
+	command string
+	block   string
+	err     error
+	content string
+	target  TextRange
+	refresh bool
+}
+
+func (m *model) openCommandPrompt() {
+	m.overlay = overlayCommand
+	m.commandInput = ""
+}
+
+func (m *model) updateCommandPrompt(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		m.overlay = overlayNone
+	case "enter":
+		m.overlay = overlayNone
+		if command := strings.TrimSpace(m.commandInput); command != "" {
+			sel := TextRange{Start: m.cursorOffset(), End: m.cursorOffset()}
+			if m.selection != nil {
+				sel = *m.selection
+			}
+			return m.runCommandBlock(command, sel, false)
+		}
+	case "backspace":
+		if runes := []rune(m.commandInput); len(runes) > 0 {
+			m.commandInput = string(runes[:len(runes)-1])
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			m.commandInput += string(msg.Runes)
+		}
+	}
+	return nil
+}
+
+// refreshCommandBlock runs the command of the block under the cursor again.
+func (m *model) refreshCommandBlock() tea.Cmd {
+	block, ok := commandBlockAt(m.textarea.Value(), m.cursorOffset())
+	if !ok {
+		m.status = "Not in a command output block"
+		return nil
+	}
+	return m.runCommandBlock(block.Command, block.Range, true)
+}
+
+func (m *model) runCommandBlock(command string, target TextRange, refresh bool) tea.Cmd {
+	content, file := m.textarea.Value(), m.filename
+	m.status = "Running " + command + " ..."
+	return func() tea.Msg {
+		block, err := RunCommandBlock(command, file)
+		return commandDoneMsg{command: command, block: block, err: err, content: content, target: target, refresh: refresh}
+	}
+}
+
+func (m *model) commandDone(msg commandDoneMsg) {
+	if msg.err != nil {
+		m.status = msg.err.Error()
+		return
+	}
+	updated, cursor, ok := placeCommandBlock(m.textarea.Value(), m.cursorOffset(), msg)
+	if !ok {
+		m.status = "The command output block changed, run the refresh again"
+		return
+	}
+	m.clearSelection()
+	m.textarea.SetValue(updated)
+	row, col := rowColumn(updated, cursor)
+	moveCursorTo(&m.textarea, row, col)
+	m.content = updated
+	if m.mode == splitMode {
+		m.refreshPreview()
+	}
+	if msg.refresh {
+		m.status = "Refreshed the output of " + msg.command
+	} else {
+		m.status = "Inserted the output of " + msg.command
+	}
+}
+
+// placeCommandBlock puts the block of msg into content and returns where the
+// cursor goes. A new block goes after the lines of the selection, or at the
+// cursor when content was edited in the meantime. A refreshed block replaces
+// the old one, as long as it is still found with the same command.
+func placeCommandBlock(content string, cursor int, msg commandDoneMsg) (string, int, bool) {
+	if !msg.refresh {
+		target := msg.target
+		if content != msg.content {
+			target = TextRange{Start: cursor, End: cursor}
+		}
+		updated, result := blockFormat(msg.block, len(msg.block))(content, target)
+		return updated, result.End, true
+	}
+	target := msg.target
+	if content != msg.content {
+		block, ok := commandBlockAt(content, min(msg.target.Start, len(content)))
+		if !ok || block.Command != msg.command {
+			return content, cursor, false
+		}
+		target = block.Range
+	}
+	return content[:target.Start] + msg.block + content[target.End:], target.Start + len(msg.block), true
+}
+
+func (m model) commandPromptView() string {
+	body := lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render("Insert Command Output"),
+		"",
+		pickerSelectedStyle.Render("$ "+m.commandInput+"█"),
+		"",
+		helpStyle.Render("enter: run • esc: cancel"),
+	)
+	return pickerStyle.Render(body)
+}
+
+func (g *GUIApp) insertCommandOutput() {
+	command := widget.NewEntry()
+	command.SetPlaceHolder("ls -l")
+
+	dialog.ShowForm("Insert Command Output", "Run", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("$", command)},
+		func(ok bool) {
+			if !ok || strings.TrimSpace(command.Text) == "" {
+				return
+			}
+			content := g.editor.Text
+			text := []rune(content)
+			start, end := g.selectedRange()
+			sel := TextRange{Start: len(string(text[:start])), End: len(string(text[:end]))}
+			g.runCommandBlock(strings.TrimSpace(command.Text), sel, false)
+		}, g.window)
+}
+
+// refreshCommandOutput runs the command of the block under the cursor again.
+func (g *GUIApp) refreshCommandOutput() {
+	content := g.editor.Text
+	block, ok := commandBlockAt(content, runeOffset(content, g.editor.CursorRow, g.editor.CursorColumn))
+	if !ok {
+		dialog.ShowInformation("Refresh Command Output", "The cursor is not in a command output block.", g.window)
+		return
+	}
+	g.runCommandBlock(block.Command, block.Range, true)
+}
+
+func (g *GUIApp) runCommandBlock(command string, target TextRange, refresh bool) {
+	content, file := g.editor.Text, g.currentFile
+	go func() {
+		block, err := RunCommandBlock(command, file)
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, g.window)
+				return
+			}
+			msg := commandDoneMsg{command: command, block: block, content: content, target: target, refresh: refresh}
+			current := g.editor.Text
+			updated, cursor, ok := placeCommandBlock(current, runeOffset(current, g.editor.CursorRow, g.editor.CursorColumn), msg)
+			if !ok {
+				dialog.ShowInformation("Refresh Command Output", "The command output block changed, run the refresh again.", g.window)
+				return
+			}
+			g.editor.SetText(updated)
+			g.selectRange(TextRange{Start: cursor, End: cursor})
+		})
+	}()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	conditionStartRe = regexp.MustCompile(`(?i)^\s*<!--\s*(only|not)\s*:\s*(.*?)\s*-->\s*$`)
+	conditionEndRe   = regexp.MustCompile(`(?i)^\s*<!--\s*/(only|not)\s*-->\s*$`)
+)
+
+// exportTargets are the names conditional blocks are checked against when
+// exporting content in format: the name of the format, that of the template
+// for a template export, the flags of the front matter and those of opts.
+func exportTargets(format exportFormat, content string, opts ExportOptions) []string {
+	targets := []string{format.name}
+	if format.name == "template" {
+		name, _, _ := strings.Cut(format.description, ".")
+		targets = append(targets, name)
+	}
+	targets = append(targets, opts.Flags...)
+	if fm := ParseFrontMatter(content); fm != nil {
+		switch flags := fm.Fields["flags"].(type) {
+		case []any:
+			for _, flag := range flags {
+				targets = append(targets, frontMatterString(flag))
+			}
+		case string:
+			targets = append(targets, splitNames(flags)...)
+		}
+	}
+	return targets
+}
+
+// SelectTargets keeps the conditional blocks of content that apply to
+// targets and drops the others. A block runs from a <!-- only: pdf, html -->
+// line, kept when one of the names is a target, or a <!-- not: pdf --> line,
+// kept when none is, to the matching <!-- /only --> or <!-- /not --> line.
+// Blocks nest; the marker lines themselves always go. Names are compared
+// without regard to case.
+func SelectTargets(content string, targets []string) string {
+	if !strings.Contains(content, "<!--") {
+		return content
+	}
+	active := map[string]bool{}
+	for _, target := range targets {
+		active[strings.ToLower(strings.TrimSpace(target))] = true
+	}
+
+	var out []string
+	// keep holds whether each open block applies, the outermost first
+	var keep []bool
+	fence := ""
+	for _, line := range strings.Split(content, "\n") {
+		kept := !slices.Contains(keep, false)
+		if matches := fenceRe.FindStringSubmatch(line); matches != nil && (fence == "" || matches[1] == fence) {
+			if fence == "" {
+				fence = matches[1]
+			} else {
+				fence = ""
+			}
+		} else if fence == "" {
+			if matches := conditionStartRe.FindStringSubmatch(line); matches != nil {
+				applies := false
+				for _, name := range splitNames(matches[2]) {
+					applies = applies || active[strings.ToLower(name)]
+				}
+				if strings.EqualFold(matches[1], "not") {
+					applies = !applies
+				}
+				keep = append(keep, applies)
+				continue
+			}
+			if conditionEndRe.MatchString(line) && len(keep) > 0 {
+				keep = keep[:len(keep)-1]
+				continue
+			}
+		}
+		if kept {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// splitNames splits a comma or space separated list of names.
+func splitNames(list string) []string {
+	return strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' })
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+const projectConfigName = ".parselt.toml"
+
+// ActiveProfile names the [profiles.<name>] table applied on top of the user
+// config. It is set once from the -profile flag at startup.
+var ActiveProfile string
+
+type Config struct {
+	Flavor    string              `toml:"flavor"`
+	Theme     string              `toml:"theme"`
+	Panels    []string            `toml:"panels"`
+	Directory string              `toml:"directory"`
+	Colors    ColorConfig         `toml:"colors"`
+	Code      CodeConfig          `toml:"code"`
+	Keys      map[string][]string `toml:"keys"`
+	Lint      LintConfig          `toml:"lint"`
+	Autosave  AutosaveConfig      `toml:"autosave"`
+	Links     LinksConfig         `toml:"links"`
+	Export    ExportConfig        `toml:"export"`
+	SMTP      SMTPConfig          `toml:"smtp"`
+	Hooks     HooksConfig         `toml:"hooks"`
+	Tools     []ToolConfig        `toml:"tools"`
+	Dictation DictationConfig     `toml:"dictation"`
+	OCR       OCRConfig           `toml:"ocr"`
+	Review    ReviewConfig        `toml:"review"`
+	Periodic  PeriodicConfig      `toml:"periodic"`
+	Meetings  MeetingsConfig      `toml:"meetings"`
+	Inbox     InboxConfig         `toml:"inbox"`
+	Metrics   MetricsConfig       `toml:"metrics"`
+	Runbook   RunbookConfig       `toml:"runbook"`
+	Snippet   SnippetConfig       `toml:"snippet"`
+	Changelog ChangelogConfig     `toml:"changelog"`
+	Positions PositionsConfig     `toml:"positions"`
+	Untitled  UntitledConfig      `toml:"untitled"`
+	Secrets   SecretsConfig       `toml:"secrets"`
+	Split     SplitConfig         `toml:"split"`
+	Glyphs    GlyphConfig         `toml:"glyphs"`
+	Terminal  TerminalConfig      `toml:"terminal"`
+	Images    ImageOptions        `toml:"images"`
+
+	Profiles map[string]toml.Primitive `toml:"profiles"`
+}
+
+// CodeConfig sets how the previews show code blocks. MaxHeight is in lines
+// and only applies to the GUI, which scrolls longer blocks inside the
+// preview; zero shows every block in full. Schemes picks the highlighting
+// colors by language from the styles of the highlighter.
+type CodeConfig struct {
+	LineNumbers bool              `toml:"line_numbers"`
+	MaxHeight   int               `toml:"max_height"`
+	Schemes     map[string]string `toml:"schemes"`
+}
+
+type LintConfig struct {
+	Disable []string `toml:"disable"`
+}
+
+// AutosaveConfig sets how often unsaved changes are written to the recovery
+// file, in seconds. Zero turns autosave off. Swap keeps a journal of the
+// edits in between.
+type AutosaveConfig struct {
+	Interval int  `toml:"interval"`
+	Swap     bool `toml:"swap"`
+	Lock     bool `toml:"lock"`
+}
+
+// LinksConfig turns on fetching page titles for pasted and inserted links.
+// Timeout is in seconds.
+type LinksConfig struct {
+	FetchTitles bool `toml:"fetch_titles"`
+	Timeout     int  `toml:"timeout"`
+}
+
+func (c LinksConfig) TitleTimeout() time.Duration {
+	return time.Duration(c.Timeout) * time.Second
+}
+
+type ExportConfig struct {
+	Width      int      `toml:"width"`
+	CardWidth  int      `toml:"card_width"`
+	Theme      string   `toml:"theme"`
+	ManSection string   `toml:"man_section"`
+	LatexClass string   `toml:"latex_class"`
+	LatexCode  string   `toml:"latex_code"`
+	Strip      bool     `toml:"strip_annotations"`
+	Flags      []string `toml:"flags"`
+}
+
+type SMTPConfig struct {
+	Host     string `toml:"host"`
+	Port     int    `toml:"port"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	From     string `toml:"from"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Flavor: "gfm",
+		Panels: []string{"editor", "preview"},
+		Export: ExportConfig{
+			Width:      defaultTextWidth,
+			CardWidth:  defaultCardWidth,
+			Theme:      "light",
+			ManSection: "1",
+			LatexClass: "article",
+			LatexCode:  "listings",
+		},
+		Autosave: AutosaveConfig{
+			Interval: 30,
+			Swap:     true,
+			Lock:     true,
+		},
+		Links: LinksConfig{
+			Timeout: 5,
+		},
+		Review: ReviewConfig{
+			Days: defaultReviewDays,
+		},
+		Periodic: PeriodicConfig{
+			Directory: "journal",
+		},
+		Meetings: MeetingsConfig{
+			Directory: "meetings",
+			Tasks:     "tasks.md",
+			People:    "people",
+		},
+		Inbox: InboxConfig{
+			File: "inbox.md",
+		},
+		Hooks: HooksConfig{
+			Review: true,
+		},
+		Untitled: UntitledConfig{
+			FromHeading: true,
+		},
+		Secrets: SecretsConfig{
+			Scan: true,
+		},
+		Split: SplitConfig{
+			FollowSections: true,
+		},
+		SMTP: SMTPConfig{
+			Port: 587,
+		},
+		Images: DefaultImageOptions(),
+		Snippet: SnippetConfig{
+			Theme:      "dark",
+			Background: "#ABB8C3",
+			Padding:    48,
+			FontSize:   14,
+			Chrome:     true,
+		},
+	}
+}
+
+func ConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "parselt", "config.toml")
+}
+
+// LoadConfig reads the user config file, falling back to defaults when it
+// does not exist.
+func LoadConfig() (*Config, error) {
+	cfg := DefaultConfig()
+
+	path := ConfigPath()
+	if path == "" {
+		return cfg, nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if ActiveProfile != "" {
+			return cfg, fmt.Errorf("unknown profile %q, %s does not exist", ActiveProfile, path)
+		}
+		return cfg, nil
+	}
+
+	meta, err := toml.DecodeFile(path, cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("error reading config %s: %v", path, err)
+	}
+
+	if ActiveProfile != "" {
+		profile, ok := cfg.Profiles[ActiveProfile]
+		if !ok {
+			return cfg, fmt.Errorf("unknown profile %q in %s", ActiveProfile, path)
+		}
+		if err := meta.PrimitiveDecode(profile, cfg); err != nil {
+			return cfg, fmt.Errorf("error reading profile %q: %v", ActiveProfile, err)
+		}
+	}
+	return cfg, nil
+}
+
+// ProjectConfigPath finds the nearest .parselt.toml in the directory of
+// docPath or any of its parents.
+func ProjectConfigPath(docPath string) string {
+	if docPath == "" {
+		return ""
+	}
+	dir, err := filepath.Abs(filepath.Dir(docPath))
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, projectConfigName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// LoadConfigFor layers the project config found above docPath over the user
+// config, so settings checked into a repository win over personal ones.
+func LoadConfigFor(docPath string) (*Config, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return cfg, err
+	}
+
+	path := ProjectConfigPath(docPath)
+	if path == "" {
+		return cfg, nil
+	}
+
+	// Mail credentials, commands to run, what the inbox accepts, the secret
+	// scan and fetching link titles from the network never come from a
+	// checked-out repository. The decoder fills slices in place, so they are
+	// cleared first to keep the saved ones apart.
+	user := *cfg
+	cfg.Hooks, cfg.Tools, cfg.Secrets = HooksConfig{}, nil, SecretsConfig{}
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		return cfg, fmt.Errorf("error reading config %s: %v", path, err)
+	}
+	cfg.SMTP, cfg.Hooks, cfg.Tools, cfg.Dictation, cfg.Inbox = user.SMTP, user.Hooks, user.Tools, user.Dictation, user.Inbox
+	cfg.Secrets, cfg.Links = user.Secrets, user.Links
+	return cfg, nil
+}
+
+func (c *Config) Processor() *SharedMarkdownProcessor {
+	return &SharedMarkdownProcessor{Flavor: c.Flavor, Code: c.Code}
+}
+
+// CheckCodeSchemes reports the first [code.schemes] entry that names no
+// highlighting style. Such languages keep the default colors.
+func (c *Config) CheckCodeSchemes() error {
+	for lang, scheme := range c.Code.Schemes {
+		if schemeColors(scheme) == nil {
+			return fmt.Errorf("unknown color scheme %q for %s in [code.schemes]", scheme, lang)
+		}
+	}
+	return nil
+}
+
+func (c *Config) Linter() *Linter {
+	l := NewLinter()
+	for _, rule := range c.Lint.Disable {
+		l.DisableRules[rule] = true
+	}
+	return l
+}
+
+func (c *Config) ExportOptions(sourcePath string) ExportOptions {
+	return ExportOptions{
+		SourcePath: sourcePath,
+		Flavor:     c.Flavor,
+		Width:      c.Export.Width,
+		CardWidth:  c.Export.CardWidth,
+		Theme:      c.Export.Theme,
+		ManSection: c.Export.ManSection,
+		LatexClass: c.Export.LatexClass,
+		LatexCode:  c.Export.LatexCode,
+		Strip:      c.Export.Strip,
+		Flags:      c.Export.Flags,
+	}
+}
+
+// KeyMap applies the [keys] remappings to the default terminal bindings.
+func (c *Config) KeyMap() (keyMap, error) {
+	km := keys
+	bindings := km.byName()
+	for name, remapped := range c.Keys {
+		binding, ok := bindings[name]
+		if !ok {
+			return keys, fmt.Errorf("unknown key binding %q in [keys]", name)
+		}
+		if len(remapped) == 0 {
+			return keys, fmt.Errorf("key binding %q has no keys", name)
+		}
+		binding.SetKeys(remapped...)
+		binding.SetHelp(strings.Join(remapped, "/"), binding.Help().Desc)
+	}
+	return km, nil
+}
+
+func (c *Config) AutosaveInterval() time.Duration {
+	return time.Duration(c.Autosave.Interval) * time.Second
+}
+
+func (c *Config) HasPanel(name string) bool {
+	for _, panel := range c.Panels {
+		if panel == name {
+			return true
+		}
+	}
+	return false
+}
+
+// StartDirectory expands a leading ~ in the configured directory.
+func (c *Config) StartDirectory() string {
+	return expandHome(c.Directory)
+}
+
+// expandHome expands a leading ~ in a configured path to the home
+// directory.
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
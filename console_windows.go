@@ -0,0 +1,23 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32              = syscall.NewLazyDLL("kernel32.dll")
+	getConsoleProcessList = kernel32.NewProc("GetConsoleProcessList")
+	freeConsole           = kernel32.NewProc("FreeConsole")
+)
+
+// detachConsole closes the console window Windows opens for parselt when
+// the GUI starts from a shortcut. A console shared with the shell it was
+// started from stays.
+func detachConsole() {
+	var processes [2]uint32
+	count, _, _ := getConsoleProcessList.Call(uintptr(unsafe.Pointer(&processes[0])), uintptr(len(processes)))
+	if count == 1 {
+		freeConsole.Call()
+	}
+}
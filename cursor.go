@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ctrlDeleteSequence is how bubbletea reports ctrl+delete, which it has no
+// key for.
+const ctrlDeleteSequence = "?CSI[51 59 53 126]?"
+
+// translateSequence turns the terminal sequences bubbletea does not know
+// into the keys the textarea has for them: ctrl+delete deletes the next word
+// like alt+delete.
+func translateSequence(msg tea.Msg) tea.Msg {
+	if _, ok := msg.(tea.KeyMsg); ok {
+		return msg
+	}
+	if s, ok := msg.(fmt.Stringer); ok && s.String() == ctrlDeleteSequence {
+		return tea.KeyMsg{Type: tea.KeyDelete, Alt: true}
+	}
+	return msg
+}
+
+// SmartHome is the column home goes to from col: the first non-blank
+// character of the line, or the start of the line when already there.
+func SmartHome(line string, col int) int {
+	indent := len([]rune(line)) - len([]rune(strings.TrimLeftFunc(line, unicode.IsSpace)))
+	if col == indent {
+		return 0
+	}
+	return indent
+}
+
+// SmartEnd is the column end goes to from col: the end of the line, or the
+// end of its text when the line has trailing blanks and the cursor is
+// already at the end.
+func SmartEnd(line string, col int) int {
+	length := len([]rune(line))
+	if col == length {
+		return len([]rune(strings.TrimRightFunc(line, unicode.IsSpace)))
+	}
+	return length
+}
+
+// ParagraphJump is the line delta -1 or 1 paragraphs away from row: the
+// first line of the next paragraph, or of the paragraph row is in (or the
+// one before when row is its first line). Past the first or last paragraph
+// it stops at the first or last line.
+func ParagraphJump(lines []string, row, delta int) int {
+	blank := func(i int) bool { return strings.TrimSpace(lines[i]) == "" }
+	if delta > 0 {
+		i := row
+		for i < len(lines) && !blank(i) {
+			i++
+		}
+		for i < len(lines) && blank(i) {
+			i++
+		}
+		return min(i, len(lines)-1)
+	}
+
+	i := row - 1
+	for i >= 0 && blank(i) {
+		i--
+	}
+	for i > 0 && !blank(i-1) {
+		i--
+	}
+	return max(i, 0)
+}
+
+// forwardKey hands msg to the textarea, for the commands it already has under
+// other keys.
+func (m *model) forwardKey(msg tea.KeyMsg) tea.Cmd {
+	before := m.textarea.Value()
+	var cmd tea.Cmd
+	m.textarea, cmd = m.textarea.Update(msg)
+	if m.mode != splitMode {
+		return cmd
+	}
+	if content := m.textarea.Value(); content != before {
+		m.content = content
+		m.refreshPreview()
+	}
+	m.syncPreviewScroll()
+	return cmd
+}
+
+// moveInLine puts the cursor at the column to returns for the current line.
+func (m *model) moveInLine(to func(line string, col int) int) {
+	row := m.textarea.Line()
+	info := m.textarea.LineInfo()
+	line := strings.Split(m.textarea.Value(), "\n")[row]
+	m.textarea.SetCursor(to(line, info.StartColumn+info.ColumnOffset))
+}
+
+func (m *model) jumpParagraph(delta int) {
+	row := ParagraphJump(strings.Split(m.textarea.Value(), "\n"), m.textarea.Line(), delta)
+	moveCursorTo(&m.textarea, row, 0)
+	if m.mode == splitMode {
+		m.syncPreviewScroll()
+	}
+}
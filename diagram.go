@@ -0,0 +1,471 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// A diagram is drawn on a grid of character cells, in pixels at 96 DPI.
+const (
+	diagramCellWidth  = 8
+	diagramCellHeight = 16
+	diagramMargin     = 2
+	diagramStroke     = 2
+	diagramArrow      = 8
+	diagramDotRadius  = 3
+)
+
+// diagramLanguages are the code block languages drawn as diagrams.
+var diagramLanguages = map[string]bool{"svgbob": true, "bob": true, "ditaa": true}
+
+// The characters a line meets on each side of a joint.
+const (
+	diagramMeetsH    = "-=+.'`*o<>"
+	diagramMeetsUp   = "|+.*o^"
+	diagramMeetsDown = "|+'`*ovV"
+)
+
+type diagramPoint struct {
+	X, Y float64
+}
+
+type diagramDot struct {
+	at     diagramPoint
+	filled bool
+}
+
+// diagramText is a run of characters that is not part of a drawing, starting
+// at the top left corner of its first cell.
+type diagramText struct {
+	at   diagramPoint
+	text string
+}
+
+// Diagram is ASCII art as lines, arrowheads, dots and text, in the style of
+// svgbob: - | _ / \ draw lines, + . ' and ` join them, with . and ' rounding
+// the corner they make, > < ^ v at the end of a line are arrowheads and * o
+// on a line are dots. Anything else is text.
+type Diagram struct {
+	Width, Height float64
+	lines         [][]diagramPoint
+	heads         [][3]diagramPoint
+	dots          []diagramDot
+	texts         []diagramText
+}
+
+// ParseDiagram reads the ASCII art of a diagram block.
+func ParseDiagram(code string) *Diagram {
+	rows := strings.Split(strings.TrimRight(strings.ReplaceAll(code, "\t", "    "), "\n"), "\n")
+	grid := make([][]rune, len(rows))
+	cols := 0
+	for i, row := range rows {
+		grid[i] = []rune(strings.TrimRight(row, " "))
+		cols = max(cols, len(grid[i]))
+	}
+	d := &Diagram{Width: float64(cols * diagramCellWidth), Height: float64(len(grid) * diagramCellHeight)}
+	at := func(c, r int) rune {
+		if r < 0 || r >= len(grid) || c < 0 || c >= len(grid[r]) {
+			return ' '
+		}
+		return grid[r][c]
+	}
+	for r, row := range grid {
+		start := -1
+		for c := 0; c <= len(row); c++ {
+			if c < len(row) && row[c] != ' ' && !d.shape(at, c, r) {
+				if start < 0 {
+					start = c
+				}
+				continue
+			}
+			if start >= 0 {
+				d.texts = append(d.texts, diagramText{
+					at:   diagramPoint{float64(start * diagramCellWidth), float64(r * diagramCellHeight)},
+					text: string(row[start:c]),
+				})
+				start = -1
+			}
+		}
+	}
+	return d
+}
+
+// shape draws the character at column c of row r when it is part of the
+// drawing, and reports whether it was.
+func (d *Diagram) shape(at func(c, r int) rune, c, r int) bool {
+	x0, y0 := float64(c*diagramCellWidth), float64(r*diagramCellHeight)
+	x1, y1 := x0+diagramCellWidth, y0+diagramCellHeight
+	cx, cy := x0+diagramCellWidth/2, y0+diagramCellHeight/2
+	left, right, up, down := at(c-1, r), at(c+1, r), at(c, r-1), at(c, r+1)
+	in := func(ch rune, set string) bool { return strings.ContainsRune(set, ch) }
+	line := func(points ...diagramPoint) { d.lines = append(d.lines, points) }
+
+	switch ch := at(c, r); ch {
+	case '-':
+		if !in(left, diagramMeetsH+"|") && !in(right, diagramMeetsH+"|") {
+			return false
+		}
+		line(diagramPoint{x0, cy}, diagramPoint{x1, cy})
+	case '=':
+		if !in(left, diagramMeetsH) && !in(right, diagramMeetsH) {
+			return false
+		}
+		line(diagramPoint{x0, cy - 2}, diagramPoint{x1, cy - 2})
+		line(diagramPoint{x0, cy + 2}, diagramPoint{x1, cy + 2})
+	case '|':
+		if !in(up, diagramMeetsUp) && !in(down, diagramMeetsDown) && left != '-' && right != '-' {
+			return false
+		}
+		line(diagramPoint{cx, y0}, diagramPoint{cx, y1})
+	case '_':
+		if !in(left, "_|") && !in(right, "_|") {
+			return false
+		}
+		line(diagramPoint{x0, y1}, diagramPoint{x1, y1})
+	case '/', '\\':
+		if isWordRune(left) || isWordRune(right) {
+			return false
+		}
+		if ch == '/' {
+			line(diagramPoint{x0, y1}, diagramPoint{x1, y0})
+		} else {
+			line(diagramPoint{x0, y0}, diagramPoint{x1, y1})
+		}
+	case '>', '<':
+		next := left
+		if ch == '<' {
+			next = right
+		}
+		if !in(next, "-=+.'`") {
+			return false
+		}
+		tip, base := x1, x1-diagramArrow
+		if ch == '<' {
+			tip, base = x0, x0+diagramArrow
+		}
+		d.heads = append(d.heads, [3]diagramPoint{{tip, cy}, {base, cy - diagramArrow/2}, {base, cy + diagramArrow/2}})
+	case '^', 'v', 'V':
+		tip, base, end, next := y0, y0+diagramArrow, y1, down
+		if ch != '^' {
+			tip, base, end, next = y1, y1-diagramArrow, y0, up
+		}
+		if !in(next, "|+.'`") || isWordRune(left) || isWordRune(right) {
+			return false
+		}
+		d.heads = append(d.heads, [3]diagramPoint{{cx, tip}, {cx - diagramArrow/2, base}, {cx + diagramArrow/2, base}})
+		line(diagramPoint{cx, base}, diagramPoint{cx, end})
+	case '+', '.', '\'', '`', '*', 'o':
+		// The edges of the cell the joint has lines to
+		var ends []diagramPoint
+		horizontal, vertical := 0, 0
+		if in(left, diagramMeetsH) {
+			ends, horizontal = append(ends, diagramPoint{x0, cy}), horizontal+1
+		}
+		if in(right, diagramMeetsH) {
+			ends, horizontal = append(ends, diagramPoint{x1, cy}), horizontal+1
+		}
+		if ch != '.' && in(up, diagramMeetsUp) {
+			ends, vertical = append(ends, diagramPoint{cx, y0}), vertical+1
+		}
+		if ch != '\'' && ch != '`' && in(down, diagramMeetsDown) {
+			ends, vertical = append(ends, diagramPoint{cx, y1}), vertical+1
+		}
+		if ch != '.' && at(c-1, r-1) == '\\' {
+			ends = append(ends, diagramPoint{x0, y0})
+		}
+		if ch != '.' && at(c+1, r-1) == '/' {
+			ends = append(ends, diagramPoint{x1, y0})
+		}
+		if ch != '\'' && ch != '`' && at(c-1, r+1) == '/' {
+			ends = append(ends, diagramPoint{x0, y1})
+		}
+		if ch != '\'' && ch != '`' && at(c+1, r+1) == '\\' {
+			ends = append(ends, diagramPoint{x1, y1})
+		}
+		if len(ends) == 0 || ch == 'o' && (isWordRune(left) || isWordRune(right)) {
+			return false
+		}
+		center := diagramPoint{cx, cy}
+		if strings.ContainsRune(".'`", ch) && horizontal == 1 && vertical == 1 && len(ends) == 2 {
+			line(diagramCurve(ends[0], center, ends[1])...)
+			return true
+		}
+		for _, end := range ends {
+			from := center
+			if ch == 'o' {
+				// Lines stop at the ring
+				dx, dy := end.X-cx, end.Y-cy
+				n := math.Hypot(dx, dy)
+				from = diagramPoint{cx + dx/n*diagramDotRadius, cy + dy/n*diagramDotRadius}
+			}
+			line(from, end)
+		}
+		if ch == '*' || ch == 'o' {
+			d.dots = append(d.dots, diagramDot{at: center, filled: ch == '*'})
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// isWordRune reports whether r is a letter or digit, which makes a slash
+// next to it part of the text.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// diagramCurve is the rounded corner from a to b around control point c, as
+// a polyline.
+func diagramCurve(a, c, b diagramPoint) []diagramPoint {
+	const steps = 8
+	points := make([]diagramPoint, 0, steps+1)
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / steps
+		u := 1 - t
+		points = append(points, diagramPoint{
+			X: u*u*a.X + 2*u*t*c.X + t*t*b.X,
+			Y: u*u*a.Y + 2*u*t*c.Y + t*t*b.Y,
+		})
+	}
+	return points
+}
+
+// SVG draws the diagram in the color of the surrounding text.
+func (d *Diagram) SVG() string {
+	var lines, shapes, texts strings.Builder
+	var last diagramPoint
+	for j, points := range d.lines {
+		for i, p := range points {
+			switch {
+			case i > 0:
+				fmt.Fprintf(&lines, "L%g %g", p.X, p.Y)
+			case j == 0 || p != last:
+				// A line that goes on from the last one carries on its path
+				fmt.Fprintf(&lines, "M%g %g", p.X, p.Y)
+			}
+		}
+		last = points[len(points)-1]
+	}
+	for _, head := range d.heads {
+		fmt.Fprintf(&shapes, `<path d="M%g %gL%g %gL%g %gz"/>`, head[0].X, head[0].Y, head[1].X, head[1].Y, head[2].X, head[2].Y)
+	}
+	for _, dot := range d.dots {
+		fill := "currentColor"
+		if !dot.filled {
+			fill = "none"
+		}
+		fmt.Fprintf(&shapes, `<circle cx="%g" cy="%g" r="%g" fill="%s" stroke="currentColor" stroke-width="%d"/>`, dot.at.X, dot.at.Y, float64(diagramDotRadius), fill, diagramStroke)
+	}
+	for _, text := range d.texts {
+		fmt.Fprintf(&texts, `<text x="%g" y="%g" textLength="%d" lengthAdjust="spacingAndGlyphs">%s</text>`,
+			text.at.X, text.at.Y+12, len([]rune(text.text))*diagramCellWidth, html.EscapeString(text.text))
+	}
+	width, height := d.Width+2*diagramMargin, d.Height+2*diagramMargin
+	return fmt.Sprintf(`<svg class="diagram" xmlns="http://www.w3.org/2000/svg" viewBox="%d %d %g %g" width="%g" height="%g" role="img">`+
+		`<path d="%s" fill="none" stroke="currentColor" stroke-width="%d" stroke-linecap="round" stroke-linejoin="round"/>`+
+		`<g fill="currentColor">%s</g><g fill="currentColor" font-family="monospace" font-size="13">%s</g></svg>`,
+		-diagramMargin, -diagramMargin, width, height, width, height, lines.String(), diagramStroke, shapes.String(), texts.String())
+}
+
+// Image draws the diagram in fg on a transparent background, with text in
+// a bitmap font that fits the cells.
+func (d *Diagram) Image(fg color.Color) *image.RGBA {
+	width := int(math.Ceil(d.Width)) + 2*diagramMargin
+	height := int(math.Ceil(d.Height)) + 2*diagramMargin
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	r := vector.NewRasterizer(width, height)
+	off := func(p diagramPoint) diagramPoint { return diagramPoint{p.X + diagramMargin, p.Y + diagramMargin} }
+
+	for _, points := range d.lines {
+		moved := make([]diagramPoint, len(points))
+		for i, p := range points {
+			moved[i] = off(p)
+		}
+		rasterLine(r, moved, diagramStroke)
+	}
+	for _, head := range d.heads {
+		rasterPolygon(r, []diagramPoint{off(head[0]), off(head[1]), off(head[2])}, true)
+	}
+	for _, dot := range d.dots {
+		rasterPolygon(r, diagramCircle(off(dot.at), diagramDotRadius+diagramStroke/2), true)
+		if !dot.filled {
+			// The inside, wound the other way, cuts a hole in the disc
+			rasterPolygon(r, diagramCircle(off(dot.at), diagramDotRadius-diagramStroke/2), false)
+		}
+	}
+	r.Draw(img, img.Bounds(), image.NewUniform(fg), image.Point{})
+
+	drawer := font.Drawer{Dst: img, Src: image.NewUniform(fg), Face: basicfont.Face7x13}
+	for _, text := range d.texts {
+		for i, ch := range []rune(text.text) {
+			drawer.Dot = fixed.P(int(text.at.X)+i*diagramCellWidth+diagramMargin, int(text.at.Y)+12+diagramMargin)
+			drawer.DrawString(string(ch))
+		}
+	}
+	return img
+}
+
+// rasterLine adds a polyline of the given width to r, with round joints.
+func rasterLine(r *vector.Rasterizer, points []diagramPoint, width float64) {
+	for i, p := range points {
+		rasterPolygon(r, diagramCircle(p, width/2), true)
+		if i == 0 {
+			continue
+		}
+		q := points[i-1]
+		dx, dy := p.X-q.X, p.Y-q.Y
+		n := math.Hypot(dx, dy)
+		if n == 0 {
+			continue
+		}
+		nx, ny := -dy/n*width/2, dx/n*width/2
+		rasterPolygon(r, []diagramPoint{{q.X + nx, q.Y + ny}, {p.X + nx, p.Y + ny}, {p.X - nx, p.Y - ny}, {q.X - nx, q.Y - ny}}, true)
+	}
+}
+
+// diagramCircle is a circle as a polygon.
+func diagramCircle(c diagramPoint, radius float64) []diagramPoint {
+	const steps = 16
+	points := make([]diagramPoint, steps)
+	for i := range points {
+		a := 2 * math.Pi * float64(i) / steps
+		points[i] = diagramPoint{c.X + radius*math.Cos(a), c.Y + radius*math.Sin(a)}
+	}
+	return points
+}
+
+// rasterPolygon adds a polygon to r, wound clockwise, or counterclockwise
+// when cw is false. Shapes wound the same way add up where they overlap.
+func rasterPolygon(r *vector.Rasterizer, points []diagramPoint, cw bool) {
+	area := 0.0
+	for i, p := range points {
+		q := points[(i+1)%len(points)]
+		area += p.X*q.Y - q.X*p.Y
+	}
+	if (area < 0) == cw {
+		for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+			points[i], points[j] = points[j], points[i]
+		}
+	}
+	r.MoveTo(float32(points[0].X), float32(points[0].Y))
+	for _, p := range points[1:] {
+		r.LineTo(float32(p.X), float32(p.Y))
+	}
+	r.ClosePath()
+}
+
+// htmlDiagramRe finds the diagram blocks of exported HTML.
+var htmlDiagramRe = regexp.MustCompile(`(?s)<pre><code class="language-(svgbob|bob|ditaa)">(.*?)</code></pre>`)
+
+// diagramHTML replaces the diagram blocks of body with SVG drawings.
+func diagramHTML(body string) string {
+	return htmlDiagramRe.ReplaceAllStringFunc(body, func(match string) string {
+		code := html.UnescapeString(htmlDiagramRe.FindStringSubmatch(match)[2])
+		return "<p>" + ParseDiagram(code).SVG() + "</p>"
+	})
+}
+
+// diagram draws a diagram block as vector graphics at most as wide as the
+// text, in the font and colors of code blocks.
+func (w *pdfWriter) diagram(d *Diagram) {
+	pdf := w.pdf
+	left, _, right, bottom := pdf.GetMargins()
+	pageWidth, pageHeight := pdf.GetPageSize()
+	k := min(25.4/96, (pageWidth-left-right)/(d.Width+2*diagramMargin))
+	height := (d.Height + 2*diagramMargin) * k
+	if pdf.GetY()+height > pageHeight-bottom {
+		pdf.AddPage()
+	}
+	x0, y0 := left+diagramMargin*k, pdf.GetY()+diagramMargin*k
+	x := func(p diagramPoint) float64 { return x0 + p.X*k }
+	y := func(p diagramPoint) float64 { return y0 + p.Y*k }
+
+	pdf.SetDrawColor(36, 41, 47)
+	pdf.SetFillColor(36, 41, 47)
+	pdf.SetLineWidth(diagramStroke * k)
+	pdf.SetLineCapStyle("round")
+	pdf.SetLineJoinStyle("round")
+	for _, points := range d.lines {
+		pdf.MoveTo(x(points[0]), y(points[0]))
+		for _, p := range points[1:] {
+			pdf.LineTo(x(p), y(p))
+		}
+		pdf.DrawPath("D")
+	}
+	for _, head := range d.heads {
+		pdf.MoveTo(x(head[0]), y(head[0]))
+		pdf.LineTo(x(head[1]), y(head[1]))
+		pdf.LineTo(x(head[2]), y(head[2]))
+		pdf.ClosePath()
+		pdf.DrawPath("F")
+	}
+	for _, dot := range d.dots {
+		style := "D"
+		if dot.filled {
+			style = "FD"
+		}
+		pdf.Circle(x(dot.at), y(dot.at), diagramDotRadius*k, style)
+	}
+	// Courier is 0.6em wide, which makes its size the one that fills a cell
+	pdf.SetFont(pdfMonoFont, "", diagramCellWidth*k/0.6*72/25.4)
+	pdf.SetTextColor(36, 41, 47)
+	for _, text := range d.texts {
+		pdf.Text(x(text.at), y(text.at)+12*k, w.tr(text.text))
+	}
+	pdf.SetLineCapStyle("butt")
+	pdf.SetLineJoinStyle("miter")
+	pdf.SetLineWidth(0.2)
+	pdf.SetY(y0 - diagramMargin*k + height + 3)
+	w.resetText()
+}
+
+// drawingSegment is a block of the GUI preview that is drawn rather than
+// shown as text, such as a diagram, in the color of the text.
+type drawingSegment struct {
+	draw func(fg color.Color) image.Image
+	text string
+}
+
+func (s *drawingSegment) Inline() bool {
+	return false
+}
+
+func (s *drawingSegment) Textual() string {
+	return s.text
+}
+
+func (s *drawingSegment) Visual() fyne.CanvasObject {
+	img := canvas.NewImageFromImage(s.draw(theme.Color(theme.ColorNameForeground)))
+	img.FillMode = canvas.ImageFillOriginal
+	return container.NewHBox(img)
+}
+
+func (s *drawingSegment) Update(o fyne.CanvasObject) {
+	img := o.(*fyne.Container).Objects[0].(*canvas.Image)
+	img.Image = s.draw(theme.Color(theme.ColorNameForeground))
+	img.Refresh()
+}
+
+func (s *drawingSegment) Select(begin, end fyne.Position) {}
+
+func (s *drawingSegment) SelectedText() string {
+	return ""
+}
+
+func (s *drawingSegment) Unselect() {}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// DictationConfig is the [dictation] table: the commands that record speech
+// and turn it into text. $AUDIO in either stands for the recording, a WAV
+// file. Record runs until dictation stops; Transcribe prints the text.
+type DictationConfig struct {
+	Record     string `toml:"record"`
+	Transcribe string `toml:"transcribe"`
+}
+
+// dictationStopWait is how long a recorder gets to finish the file once
+// asked to stop.
+const dictationStopWait = 5 * time.Second
+
+// Dictation is a recording in progress.
+type Dictation struct {
+	cfg    DictationConfig
+	file   string
+	dir    string
+	audio  string
+	cmd    *exec.Cmd
+	stderr bytes.Buffer
+	done   chan error
+}
+
+// StartDictation starts recording with the record command of cfg. file is
+// the document the text goes to; the commands run in its directory.
+func StartDictation(cfg DictationConfig, file string) (*Dictation, error) {
+	if strings.TrimSpace(cfg.Record) == "" || strings.TrimSpace(cfg.Transcribe) == "" {
+		return nil, fmt.Errorf("dictation needs record and transcribe commands in [dictation] of %s", ConfigPath())
+	}
+	dir, err := os.MkdirTemp("", "parselt-dictation")
+	if err != nil {
+		return nil, fmt.Errorf("error starting dictation: %v", err)
+	}
+	d := &Dictation{cfg: cfg, file: file, dir: dir, audio: filepath.Join(dir, "dictation.wav"), done: make(chan error, 1)}
+	command := expandVars(cfg.Record, audioVars)
+	if runtime.GOOS != "windows" {
+		// The recorder takes the place of the shell, so that it is the one
+		// asked to stop
+		command = "exec " + command
+	}
+	d.cmd = hookCommand(context.Background(), command, "dictation", file, "", "PARSELT_AUDIO="+d.audio)
+	d.cmd.Stderr = &d.stderr
+	if err := d.cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("error starting the recorder: %v", err)
+	}
+	go func() { d.done <- d.cmd.Wait() }()
+	return d, nil
+}
+
+// Stop ends the recording and returns what was said, with the spoken
+// punctuation applied.
+func (d *Dictation) Stop() (string, error) {
+	defer os.RemoveAll(d.dir)
+	d.interrupt()
+	if info, err := os.Stat(d.audio); err != nil || info.Size() == 0 {
+		message := strings.TrimSpace(d.stderr.String())
+		if message == "" {
+			message = "nothing was recorded"
+		}
+		return "", fmt.Errorf("recording failed: %s", message)
+	}
+	text, err := runCommand("transcription", expandVars(d.cfg.Transcribe, audioVars), "dictation", d.file, "PARSELT_AUDIO="+d.audio)
+	if err != nil {
+		return "", err
+	}
+	return ApplyDictationCommands(text), nil
+}
+
+// Cancel ends the recording and throws it away.
+func (d *Dictation) Cancel() {
+	d.interrupt()
+	os.RemoveAll(d.dir)
+}
+
+// interrupt asks the recorder to stop as if ctrl+c was pressed, which lets
+// it finish the file, and ends it when it does not stop in time. Windows has
+// no such signal, so there it is ended at once.
+func (d *Dictation) interrupt() {
+	if runtime.GOOS == "windows" || d.cmd.Process.Signal(os.Interrupt) != nil {
+		d.cmd.Process.Kill()
+	}
+	select {
+	case <-d.done:
+	case <-time.After(dictationStopWait):
+		d.cmd.Process.Kill()
+		<-d.done
+	}
+}
+
+// audioVars has $AUDIO of the dictation commands stand for the recording,
+// which they get in the environment.
+var audioVars = map[string]string{"AUDIO": "PARSELT_AUDIO"}
+
+// dictationCommands are the spoken words that stand for punctuation and
+// line breaks.
+var dictationCommands = []struct {
+	words []string
+	mark  string
+}{
+	{[]string{"new", "paragraph"}, "\n\n"},
+	{[]string{"new", "line"}, "\n"},
+	{[]string{"full", "stop"}, "."},
+	{[]string{"period"}, "."},
+	{[]string{"comma"}, ","},
+	{[]string{"question", "mark"}, "?"},
+	{[]string{"exclamation", "mark"}, "!"},
+	{[]string{"exclamation", "point"}, "!"},
+	{[]string{"colon"}, ":"},
+	{[]string{"semicolon"}, ";"},
+}
+
+// dictationCommand is the mark that the command at the start of words
+// stands for and how many words it takes, or 0 when there is none.
+// Transcribers often punctuate the command word itself, which is ignored.
+func dictationCommand(words []string) (string, int) {
+	for _, command := range dictationCommands {
+		if len(words) < len(command.words) {
+			continue
+		}
+		matched := true
+		for i, word := range command.words {
+			if strings.ToLower(strings.Trim(words[i], ".,;:!?")) != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return command.mark, len(command.words)
+		}
+	}
+	return "", 0
+}
+
+// ApplyDictationCommands turns transcribed speech into text: spoken
+// punctuation such as "comma" and "question mark" becomes the mark, "new
+// line" and "new paragraph" break the line, and a sentence after a spoken
+// full stop starts with a capital.
+func ApplyDictationCommands(text string) string {
+	words := strings.Fields(text)
+	var out strings.Builder
+	capital := false
+	for i := 0; i < len(words); {
+		if mark, n := dictationCommand(words[i:]); n > 0 {
+			s := strings.TrimRight(out.String(), " ")
+			if !strings.HasPrefix(mark, "\n") {
+				// The mark said takes the place of one the transcriber added
+				s = strings.TrimRight(s, ".,;:!?")
+			}
+			out.Reset()
+			out.WriteString(s + mark)
+			capital = strings.ContainsAny(mark, ".?!\n")
+			i += n
+			continue
+		}
+		word := words[i]
+		if capital {
+			r, size := utf8.DecodeRuneInString(word)
+			word = string(unicode.ToUpper(r)) + word[size:]
+			capital = false
+		}
+		if s := out.String(); s != "" && !strings.HasSuffix(s, "\n") {
+			out.WriteString(" ")
+		}
+		out.WriteString(word)
+		i++
+	}
+	return out.String()
+}
+
+// dictatedText is text as it goes in after before: set apart from a word
+// that ends right at the cursor.
+func dictatedText(before, text string) string {
+	if r, _ := utf8.DecodeLastRuneInString(before); before != "" && !unicode.IsSpace(r) && !strings.HasPrefix(text, "\n") {
+		return " " + text
+	}
+	return text
+}
+
+// dictationMsg brings back the text of a dictation.
+type dictationMsg struct {
+	text string
+	err  error
+}
+
+// toggleDictation starts listening, or stops and transcribes what was said
+// in the background. Terminals do not report keys being released, so the
+// push-to-talk key is pressed once to start and again to stop.
+func (m *model) toggleDictation() tea.Cmd {
+	if m.listening != nil {
+		d := m.listening
+		m.listening = nil
+		m.status = "Transcribing ..."
+		return func() tea.Msg {
+			text, err := d.Stop()
+			return dictationMsg{text: text, err: err}
+		}
+	}
+	d, err := StartDictation(m.dictation, m.filename)
+	if err != nil {
+		m.status = err.Error()
+		return nil
+	}
+	m.listening = d
+	m.status = "Listening ... " + m.keys.dictate.Help().Key + " stops"
+	return nil
+}
+
+// dictated inserts the text of a dictation at the cursor, over the
+// selection when there is one.
+func (m *model) dictated(msg dictationMsg) {
+	if msg.err != nil {
+		m.status = msg.err.Error()
+		return
+	}
+	if strings.TrimSpace(msg.text) == "" {
+		m.status = "Nothing was heard"
+		return
+	}
+	r := TextRange{Start: m.cursorOffset(), End: m.cursorOffset()}
+	if m.selection != nil {
+		r = *m.selection
+		m.clearSelection()
+	}
+	m.replaceRange(r, dictatedText(m.textarea.Value()[:r.Start], msg.text))
+	m.status = "Inserted " + clipSize(msg.text)
+}
+
+// toggleDictation starts listening, or stops and inserts what was said at
+// the cursor. The menu item says which one it does next.
+func (g *GUIApp) toggleDictation() {
+	if g.listening != nil {
+		d := g.listening
+		g.listening = nil
+		g.dictateItem.Label = "Transcribing..."
+		g.dictateItem.Disabled = true
+		g.window.MainMenu().Refresh()
+		go func() {
+			text, err := d.Stop()
+			fyne.Do(func() {
+				g.dictateItem.Label = "Start Dictation"
+				g.dictateItem.Disabled = false
+				g.window.MainMenu().Refresh()
+				if err != nil {
+					dialog.ShowError(err, g.window)
+					return
+				}
+				if strings.TrimSpace(text) == "" {
+					return
+				}
+				content := g.editor.Text
+				runes := []rune(content)
+				start, end := g.selectedRange()
+				sel := TextRange{Start: len(string(runes[:start])), End: len(string(runes[:end]))}
+				text = dictatedText(content[:sel.Start], text)
+				g.editor.SetText(content[:sel.Start] + text + content[sel.End:])
+				cursor := sel.Start + len(text)
+				g.selectRange(TextRange{Start: cursor, End: cursor})
+				g.window.Canvas().Focus(g.editor)
+			})
+		}()
+		return
+	}
+	d, err := StartDictation(g.config.Dictation, g.currentFile)
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+	g.listening = d
+	g.dictateItem.Label = "Stop Dictation"
+	g.window.MainMenu().Refresh()
+}
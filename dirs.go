@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// legacyStateFiles are what older versions kept next to the config file.
+var legacyStateFiles = []string{"positions.toml", "tutorial.toml", "tutorial.md", "parselt.log"}
+
+// stateDir is where parselt keeps what it remembers between runs and the
+// recovery copies of unsaved documents: $XDG_STATE_HOME/parselt on Linux and
+// the BSDs, Application Support on macOS and the local AppData on Windows,
+// which does not roam with the profile.
+func stateDir() string {
+	var base string
+	switch runtime.GOOS {
+	case "windows":
+		base = os.Getenv("LocalAppData")
+	case "darwin", "ios":
+		base, _ = os.UserConfigDir()
+	default:
+		base = os.Getenv("XDG_STATE_HOME")
+		if !filepath.IsAbs(base) {
+			base = ""
+			if home, err := os.UserHomeDir(); err == nil {
+				base = filepath.Join(home, ".local", "state")
+			}
+		}
+	}
+	if base == "" {
+		return filepath.Join(os.TempDir(), "parselt")
+	}
+	return filepath.Join(base, "parselt")
+}
+
+// cacheDir holds what parselt can fetch again, such as the images of the GUI
+// preview.
+func cacheDir() string {
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "parselt")
+	}
+	return filepath.Join(os.TempDir(), "parselt-cache")
+}
+
+// recoveryPath is the file in the state directory that keeps ext for
+// docPath. The hash of the directory keeps documents of the same name apart.
+func recoveryPath(docPath, ext string) string {
+	if docPath == "" {
+		return ""
+	}
+	abs, err := normalizePath(docPath)
+	if err != nil {
+		abs = docPath
+	}
+	sum := sha256.Sum256([]byte(filepath.Dir(abs)))
+	name := filepath.Base(abs) + "-" + hex.EncodeToString(sum[:6]) + ext
+	return filepath.Join(stateDir(), "recovery", name)
+}
+
+// migrateState moves what older versions kept next to the config file into
+// the state directory. Files already there win.
+func migrateState() {
+	configPath := ConfigPath()
+	if configPath == "" {
+		return
+	}
+	legacy, dir := filepath.Dir(configPath), stateDir()
+	if sameFile(legacy, dir) {
+		return
+	}
+	for _, name := range legacyStateFiles {
+		moveIfAbsent(filepath.Join(legacy, name), filepath.Join(dir, name))
+	}
+}
+
+// migrateRecovery moves the autosave copy and swap file of docPath from the
+// .parselt directory next to it, where older versions kept them.
+func migrateRecovery(docPath string) {
+	if docPath == "" {
+		return
+	}
+	legacy := filepath.Join(filepath.Dir(docPath), workspaceDir)
+	moved := moveIfAbsent(filepath.Join(legacy, filepath.Base(docPath)+".autosave"), AutosavePath(docPath))
+	moved = moveIfAbsent(filepath.Join(legacy, filepath.Base(docPath)+".swp"), SwapPath(docPath)) || moved
+	if moved {
+		// Only goes when nothing else, such as the trash, is left in it
+		os.Remove(legacy)
+	}
+}
+
+func moveIfAbsent(from, to string) bool {
+	if _, err := os.Stat(from); err != nil {
+		return false
+	}
+	if _, err := os.Stat(to); err == nil {
+		return false
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return false
+	}
+	return os.Rename(from, to) == nil
+}
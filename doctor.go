@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// maxLogSize is how large the log grows before its older half is dropped.
+const maxLogSize = 256 << 10
+
+// doctorLogLines is how much of the log goes into a doctor report.
+const doctorLogLines = 40
+
+var (
+	doctorEmailRe  = regexp.MustCompile(`[\w.+-]+@[\w-]+(\.[\w-]+)+`)
+	doctorSecretRe = regexp.MustCompile(`(?i)\b(password|passwd|token|secret|api[_-]?key)(\s*[=:]\s*)\S+`)
+)
+
+func logPath() string {
+	return filepath.Join(stateDir(), "parselt.log")
+}
+
+// startLog sends the standard logger to the log file in the state directory,
+// which `parselt doctor` puts in its report.
+func startLog() {
+	path := logPath()
+	if data, err := os.ReadFile(path); err == nil && len(data) > maxLogSize {
+		tail := data[len(data)-maxLogSize/2:]
+		if i := bytes.IndexByte(tail, '\n'); i >= 0 {
+			tail = tail[i+1:]
+		}
+		os.WriteFile(path, tail, 0644)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.SetOutput(os.Stderr)
+		return
+	}
+	log.SetOutput(file)
+}
+
+// logPanic logs a crash with its stack before it takes the program down.
+func logPanic() {
+	if r := recover(); r != nil {
+		log.Printf("panic: %v\n%s", r, debug.Stack())
+		panic(r)
+	}
+}
+
+// versionInfo describes the build: the module version, the commit it was
+// built from and the Go version.
+func versionInfo() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown (" + runtime.Version() + ")"
+	}
+	version := info.Main.Version
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			if setting.Value == "true" {
+				modified = ", modified"
+			}
+		}
+	}
+	if revision = revision[:min(len(revision), 12)]; revision != "" && !strings.Contains(version, revision) {
+		version += " " + revision + modified
+	}
+	return version + " (" + info.GoVersion + ")"
+}
+
+// imageProtocol names the inline image protocol the terminal announces
+// through its environment, or "" when there is no telling.
+func imageProtocol() string {
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("TERM") == "xterm-kitty" || os.Getenv("TERM_PROGRAM") == "ghostty":
+		return "kitty graphics"
+	case os.Getenv("TERM_PROGRAM") == "iTerm.app" || os.Getenv("TERM_PROGRAM") == "WezTerm" || os.Getenv("LC_TERMINAL") == "iTerm2":
+		return "iTerm2 inline images"
+	case strings.Contains(os.Getenv("TERM"), "sixel") || os.Getenv("TERM") == "mlterm" || os.Getenv("TERM_PROGRAM") == "foot":
+		return "sixel"
+	}
+	return ""
+}
+
+func terminalSize() string {
+	width, height, err := term.GetSize(os.Stdout.Fd())
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", width, height)
+}
+
+// DoctorReport describes the environment parselt runs in, in markdown to
+// paste into an issue. Personal details are redacted.
+func DoctorReport() string {
+	var b strings.Builder
+	line := func(name, value string) {
+		reportLine(&b, name, value)
+	}
+
+	b.WriteString("## parselt doctor\n\n")
+	line("Version", versionInfo())
+	line("OS", runtime.GOOS+"/"+runtime.GOARCH)
+
+	b.WriteString("\n### Terminal\n\n")
+	for _, name := range []string{"TERM", "COLORTERM", "TERM_PROGRAM", "TERM_PROGRAM_VERSION", "TMUX", "LANG"} {
+		value := os.Getenv(name)
+		if name == "TMUX" && value != "" {
+			value = "yes"
+		}
+		line(name, value)
+	}
+	tty := "no"
+	if stat, err := os.Stdout.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 {
+		tty = "yes"
+	}
+	line("Standard output is a terminal", tty)
+	caps, _ := DetectTermCaps(TerminalConfig{})
+	line("Colors", caps.Colors)
+	line("Truecolor", fmt.Sprint(caps.Colors == "truecolor"))
+	line("Unicode", fmt.Sprint(caps.Unicode))
+	line("Size", terminalSize())
+	protocol := imageProtocol()
+	if protocol == "" {
+		protocol = "none detected"
+	}
+	line("Image protocol", protocol)
+
+	b.WriteString("\n### Configuration\n\n")
+	b.WriteString(configSummary())
+
+	b.WriteString("\n### Recent log\n\n")
+	b.WriteString("```text\n" + recentLog(doctorLogLines) + "```\n")
+	return redact(b.String())
+}
+
+// configSummary lists what the config sets without the values that could be
+// personal, such as mail settings and commands.
+func configSummary() string {
+	var b strings.Builder
+	line := func(name, value string) {
+		reportLine(&b, name, value)
+	}
+	path := ConfigPath()
+	switch _, err := os.Stat(path); {
+	case path == "":
+		line("Config", "no config directory")
+	case os.IsNotExist(err):
+		line("Config", path+" (not found, defaults)")
+	default:
+		line("Config", path)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if project := ProjectConfigPath(filepath.Join(wd, "document.md")); project != "" {
+			line("Project config", project)
+		}
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		line("Error", err.Error())
+	}
+	profiles := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	line("Flavor", cfg.Flavor)
+	line("Theme", cfg.Theme)
+	line("Profiles", fmt.Sprintf("%d %v, active %q", len(profiles), profiles, ActiveProfile))
+	line("Remapped keys", fmt.Sprint(len(cfg.Keys)))
+	line("Disabled lint rules", fmt.Sprint(cfg.Lint.Disable))
+	line("Autosave", fmt.Sprintf("every %ds, swap %v", cfg.Autosave.Interval, cfg.Autosave.Swap))
+	line("Fetch link titles", fmt.Sprint(cfg.Links.FetchTitles))
+	line("SMTP", fmt.Sprint(cfg.SMTP.Host != ""))
+	line("Hooks", fmt.Sprintf("open %d, before_save %d, after_save %d, after_export %d",
+		len(cfg.Hooks.Open), len(cfg.Hooks.BeforeSave), len(cfg.Hooks.AfterSave), len(cfg.Hooks.AfterExport)))
+	line("Tools", fmt.Sprint(len(cfg.Tools)))
+	line("Dictation", fmt.Sprint(cfg.Dictation.Record != "" && cfg.Dictation.Transcribe != ""))
+	line("Inbox", fmt.Sprint(cfg.Inbox.Token != ""))
+	line("Metrics", fmt.Sprint(cfg.Metrics.Enabled))
+	line("Terminal", fmt.Sprintf("colors %q, unicode %q", cfg.Terminal.Colors, cfg.Terminal.Unicode))
+	return b.String()
+}
+
+func reportLine(b *strings.Builder, name, value string) {
+	if value == "" {
+		value = "-"
+	}
+	fmt.Fprintf(b, "- %s: %s\n", name, value)
+}
+
+// recentLog returns the last n lines of the log.
+func recentLog(n int) string {
+	data, err := os.ReadFile(logPath())
+	if err != nil {
+		return "(no log)\n"
+	}
+	lines := strings.SplitAfter(strings.TrimRight(string(data), "\n")+"\n", "\n")
+	lines = lines[:len(lines)-1]
+	return strings.Join(lines[max(len(lines)-n, 0):], "")
+}
+
+// redact hides what identifies the user: the home directory, user and host
+// names, email addresses and anything that looks like a password.
+func redact(report string) string {
+	report = doctorSecretRe.ReplaceAllString(report, "$1$2<redacted>")
+	report = doctorEmailRe.ReplaceAllString(report, "<email>")
+	if home, err := os.UserHomeDir(); err == nil && len(home) > 1 {
+		report = strings.ReplaceAll(report, home, "~")
+	}
+	if u, err := user.Current(); err == nil && len(u.Username) > 2 {
+		report = strings.ReplaceAll(report, u.Username, "<user>")
+	}
+	if host, err := os.Hostname(); err == nil && len(host) > 2 {
+		report = strings.ReplaceAll(report, host, "<host>")
+	}
+	return report
+}
+
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	output := fs.String("o", "", "write the report to this file instead of standard output")
+	fs.StringVar(&ActiveProfile, "profile", "", "configuration profile to report on")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: parselt doctor [-o report.md]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	report := DoctorReport()
+	if *output == "" {
+		fmt.Print(report)
+		return nil
+	}
+	if err := os.WriteFile(*output, []byte(report), 0644); err != nil {
+		return fmt.Errorf("error writing report: %v", err)
+	}
+	fmt.Printf("Wrote %s; check it before attaching it to an issue\n", *output)
+	return nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var twinStyle = editorStyle.BorderForeground(lipgloss.Color("#626262"))
+
+// shiftedRow keeps a view on the same text after an edit made in the other
+// view: lines inserted or removed above row move it along.
+func shiftedRow(before, after string, row int) int {
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	changed := strings.Count(before[:prefix], "\n")
+	if changed >= row {
+		return row
+	}
+	return max(row+strings.Count(after, "\n")-strings.Count(before, "\n"), changed)
+}
+
+// splitEditor splits the editor into two views of the document, or moves
+// the cursor to the other view when it is split already. m.textarea is
+// always the view with the cursor, so every command works on it; the twin
+// follows its edits.
+func (m *model) splitEditor() {
+	m.clearSelection()
+	if m.twin == nil {
+		info := m.textarea.LineInfo()
+		row, col := m.textarea.Line(), info.StartColumn+info.ColumnOffset
+		m.textarea.Blur()
+		other := m.textarea
+		m.textarea, m.twin, m.twinFirst = newEditor(), &other, true
+		m.layout()
+		m.textarea.SetValue(other.Value())
+		moveCursorTo(&m.textarea, row, col)
+		m.status = fmt.Sprintf("Split the editor, %s goes to the other view", m.keys.splitView.Help().Key)
+	} else {
+		m.textarea.Blur()
+		m.twin.Focus()
+		m.textarea, *m.twin = *m.twin, m.textarea
+		m.twinFirst = !m.twinFirst
+		m.layout()
+	}
+	if m.mode == splitMode {
+		m.syncPreviewScroll()
+	}
+}
+
+// unsplitEditor closes the view without the cursor.
+func (m *model) unsplitEditor() {
+	if m.twin == nil {
+		return
+	}
+	m.twin = nil
+	m.layout()
+}
+
+// syncTwin brings the edits made in the view with the cursor over to the
+// other one.
+func (m *model) syncTwin() {
+	if m.twin == nil {
+		return
+	}
+	before, after := m.twin.Value(), m.textarea.Value()
+	if before == after {
+		return
+	}
+	info := m.twin.LineInfo()
+	row, col := shiftedRow(before, after, m.twin.Line()), info.StartColumn+info.ColumnOffset
+	m.twin.SetValue(after)
+	moveCursorTo(m.twin, row, col)
+}
+
+// layoutViews sizes the editor, sharing its height between the two views
+// when it is split.
+func (m *model) layoutViews(width, height int) {
+	m.textarea.SetWidth(width)
+	if m.twin == nil {
+		m.textarea.SetHeight(height)
+		return
+	}
+	height -= 2
+	first := height / 2
+	m.twin.SetWidth(width)
+	if m.twinFirst {
+		m.twin.SetHeight(first)
+		m.textarea.SetHeight(height - first)
+	} else {
+		m.textarea.SetHeight(first)
+		m.twin.SetHeight(height - first)
+	}
+}
+
+// editorView draws the editor, or both views of a split one with the view
+// that has the cursor in the usual colors, and the document map next to it.
+func (m model) editorView() string {
+	editor := editorStyle.Render(m.textarea.View())
+	if m.twin != nil {
+		other := twinStyle.Render(m.twin.View())
+		if m.twinFirst {
+			editor = lipgloss.JoinVertical(lipgloss.Left, other, editor)
+		} else {
+			editor = lipgloss.JoinVertical(lipgloss.Left, editor, other)
+		}
+	}
+	return m.withDocMap(editor)
+}
+
+// editorHeight is how many rows the editor takes, borders included.
+func (m model) editorHeight() int {
+	height := m.textarea.Height() + 2
+	if m.twin != nil {
+		height += m.twin.Height() + 2
+	}
+	return height
+}
+
+// newEditorEntry makes an editor for the GUI, the main one or the second
+// view of a split editor.
+func newEditorEntry() *widget.Entry {
+	entry := widget.NewMultiLineEntry()
+	entry.Wrapping = fyne.TextWrapWord
+	entry.SetPlaceHolder("Start writing your markdown...")
+	entry.TextStyle = fyne.TextStyle{
+		Monospace: true,
+	}
+	return entry
+}
+
+// watchEditor sends the changes of entry to the editor handlers. Only
+// g.editor, the view last typed in or clicked, is handled; using the other
+// view makes it g.editor.
+func (g *GUIApp) watchEditor(entry *widget.Entry) {
+	entry.OnChanged = func(content string) {
+		if entry != g.editor {
+			if g.twinSyncing {
+				return
+			}
+			g.focusView(entry)
+		}
+		g.editorChanged(content)
+	}
+	entry.OnCursorChanged = func() {
+		if entry != g.editor {
+			if g.twinSyncing {
+				return
+			}
+			g.focusView(entry)
+		}
+		g.editorCursorChanged()
+	}
+}
+
+// toggleSplitEditor shows a second view of the document below the editor,
+// or closes the view that was not used last.
+func (g *GUIApp) toggleSplitEditor() {
+	if g.twin != nil {
+		g.twin = nil
+		g.editorPane.Objects = []fyne.CanvasObject{container.NewScroll(g.editor)}
+		g.editorPane.Refresh()
+		g.splitEditorItem.Checked = false
+		return
+	}
+
+	g.twin = newEditorEntry()
+	g.twin.SetText(g.editor.Text)
+	g.twin.CursorRow, g.twin.CursorColumn = g.editor.CursorRow, g.editor.CursorColumn
+	g.watchEditor(g.twin)
+	views := container.NewVSplit(container.NewScroll(g.editor), container.NewScroll(g.twin))
+	g.editorPane.Objects = []fyne.CanvasObject{views}
+	g.editorPane.Refresh()
+	g.splitEditorItem.Checked = true
+	g.window.Canvas().Focus(g.twin)
+}
+
+// otherView moves the cursor to the other view, splitting the editor first
+// when it is not.
+func (g *GUIApp) otherView() {
+	if g.twin == nil {
+		g.toggleSplitEditor()
+		return
+	}
+	g.focusView(g.twin)
+	g.window.Canvas().Focus(g.editor)
+}
+
+// focusView makes entry the view the commands work on.
+func (g *GUIApp) focusView(entry *widget.Entry) {
+	g.editor, g.twin = entry, g.editor
+}
+
+// syncTwin brings the edits made in g.editor over to the other view.
+func (g *GUIApp) syncTwin(content string) {
+	if g.twin == nil || g.twin.Text == content {
+		return
+	}
+	row, col := shiftedRow(g.twin.Text, content, g.twin.CursorRow), g.twin.CursorColumn
+	g.twinSyncing = true
+	g.twin.SetText(content)
+	g.twin.CursorRow, g.twin.CursorColumn = rowColumn(content, runeOffset(content, row, col))
+	g.twin.Refresh()
+	g.twinSyncing = false
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var emailStyles = map[string]string{
+	"h1":         "font-size:26px;line-height:32px;color:#2d2d2d;margin:0 0 16px 0;",
+	"h2":         "font-size:21px;line-height:28px;color:#2d2d2d;margin:24px 0 12px 0;border-bottom:1px solid #e5e5e5;",
+	"h3":         "font-size:18px;line-height:24px;color:#2d2d2d;margin:20px 0 10px 0;",
+	"h4":         "font-size:16px;line-height:22px;color:#2d2d2d;margin:16px 0 8px 0;",
+	"p":          "margin:0 0 14px 0;",
+	"a":          "color:#7D56F4;text-decoration:underline;",
+	"ul":         "margin:0 0 14px 0;padding-left:24px;",
+	"ol":         "margin:0 0 14px 0;padding-left:24px;",
+	"li":         "margin:0 0 4px 0;",
+	"blockquote": "margin:0 0 14px 0;padding:4px 12px;border-left:4px solid #cccccc;color:#666666;",
+	"pre":        "margin:0 0 14px 0;padding:12px;background-color:#f6f8fa;border:1px solid #e5e5e5;font-family:Consolas,Menlo,monospace;font-size:13px;line-height:18px;white-space:pre-wrap;",
+	"code":       "font-family:Consolas,Menlo,monospace;font-size:13px;background-color:#f6f8fa;",
+	"table":      "border-collapse:collapse;margin:0 0 14px 0;",
+	"th":         "border:1px solid #dddddd;padding:6px 10px;background-color:#f2f2f2;text-align:left;",
+	"td":         "border:1px solid #dddddd;padding:6px 10px;",
+	"img":        "max-width:100%;height:auto;border:0;",
+	"hr":         "border:0;border-top:1px solid #e5e5e5;margin:20px 0;",
+}
+
+var emailDarkStyles = map[string]string{
+	"h1":         "font-size:26px;line-height:32px;color:#f0f0f0;margin:0 0 16px 0;",
+	"h2":         "font-size:21px;line-height:28px;color:#f0f0f0;margin:24px 0 12px 0;border-bottom:1px solid #444444;",
+	"h3":         "font-size:18px;line-height:24px;color:#f0f0f0;margin:20px 0 10px 0;",
+	"h4":         "font-size:16px;line-height:22px;color:#f0f0f0;margin:16px 0 8px 0;",
+	"a":          "color:#b39dff;text-decoration:underline;",
+	"blockquote": "margin:0 0 14px 0;padding:4px 12px;border-left:4px solid #555555;color:#aaaaaa;",
+	"pre":        "margin:0 0 14px 0;padding:12px;background-color:#111111;border:1px solid #444444;font-family:Consolas,Menlo,monospace;font-size:13px;line-height:18px;white-space:pre-wrap;color:#e0e0e0;",
+	"code":       "font-family:Consolas,Menlo,monospace;font-size:13px;background-color:#111111;",
+	"th":         "border:1px solid #444444;padding:6px 10px;background-color:#2a2a2a;text-align:left;",
+	"td":         "border:1px solid #444444;padding:6px 10px;",
+	"hr":         "border:0;border-top:1px solid #444444;margin:20px 0;",
+}
+
+func emailStyle(theme, tag string) string {
+	if theme == "dark" {
+		if style, ok := emailDarkStyles[tag]; ok {
+			return style
+		}
+	}
+	return emailStyles[tag]
+}
+
+var (
+	emailTagRe   = regexp.MustCompile(`<(h[1-4]|p|a|ul|ol|li|blockquote|pre|code|table|th|td|img|hr)(\s[^>]*)?(/?)>`)
+	emailImageRe = regexp.MustCompile(`(<img[^>]*\ssrc=")([^"]+)(")`)
+)
+
+type emailImage struct {
+	cid         string
+	contentType string
+	data        []byte
+}
+
+type emailMessage struct {
+	subject string
+	html    string
+	images  []emailImage
+}
+
+// buildEmail renders the document as HTML that survives mail clients: all
+// styles are inlined, the body sits in a fixed-width presentation table and
+// local images are referenced by Content-ID.
+func buildEmail(content string, opts ExportOptions) emailMessage {
+	body := opts.processor().ConvertMarkdownToHTML(content)
+
+	var images []emailImage
+	baseDir := "."
+	if opts.SourcePath != "" {
+		baseDir = filepath.Dir(opts.SourcePath)
+	}
+
+	body = emailImageRe.ReplaceAllStringFunc(body, func(match string) string {
+		parts := emailImageRe.FindStringSubmatch(match)
+		src := parts[2]
+		if isURL(src) {
+			return match
+		}
+
+		path := src
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, filepath.FromSlash(src))
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return match
+		}
+
+		contentType := mime.TypeByExtension(filepath.Ext(path))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		cid := fmt.Sprintf("image%d@parselt", len(images)+1)
+		images = append(images, emailImage{cid: cid, contentType: contentType, data: data})
+		return parts[1] + "cid:" + cid + parts[3]
+	})
+
+	body = emailTagRe.ReplaceAllStringFunc(body, func(match string) string {
+		parts := emailTagRe.FindStringSubmatch(match)
+		tag, attrs, selfClose := parts[1], parts[2], parts[3]
+		style := emailStyle(opts.Theme, tag)
+		if tag == "code" && strings.Contains(attrs, "language-") {
+			// Code inside pre blocks is already styled by the pre tag
+			style = "font-family:Consolas,Menlo,monospace;font-size:13px;"
+		}
+		return fmt.Sprintf(`<%s%s style="%s"%s>`, tag, attrs, style, selfClose)
+	})
+
+	page, card, text := "#f4f4f4", "#ffffff", "#333333"
+	if opts.Theme == "dark" {
+		page, card, text = "#111111", "#1e1e1e", "#dddddd"
+	}
+
+	var buf strings.Builder
+	buf.WriteString("<!DOCTYPE html>\n<html>\n<head>\n")
+	buf.WriteString(`<meta http-equiv="Content-Type" content="text/html; charset=utf-8">` + "\n")
+	buf.WriteString(`<meta name="viewport" content="width=device-width, initial-scale=1.0">` + "\n")
+	fmt.Fprintf(&buf, "<title>%s</title>\n", htmlEscape(opts.Title))
+	buf.WriteString("</head>\n")
+	fmt.Fprintf(&buf, `<body style="margin:0;padding:0;background-color:%s;">`+"\n", page)
+	fmt.Fprintf(&buf, `<table role="presentation" width="100%%" cellpadding="0" cellspacing="0" border="0" style="background-color:%s;">`+"\n", page)
+	buf.WriteString(`<tr><td align="center" style="padding:24px 12px;">` + "\n")
+	fmt.Fprintf(&buf, `<table role="presentation" width="600" cellpadding="0" cellspacing="0" border="0" style="width:600px;max-width:600px;background-color:%s;">`+"\n", card)
+	fmt.Fprintf(&buf, `<tr><td style="padding:32px;font-family:Helvetica,Arial,sans-serif;font-size:15px;line-height:22px;color:%s;">`+"\n", text)
+	buf.WriteString(body)
+	buf.WriteString("</td></tr>\n</table>\n</td></tr>\n</table>\n</body>\n</html>\n")
+
+	return emailMessage{
+		subject: opts.Title,
+		html:    buf.String(),
+		images:  images,
+	}
+}
+
+func (e emailMessage) encode(from, to string) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	if from != "" {
+		fmt.Fprintf(&buf, "From: %s\r\n", from)
+	}
+	if to != "" {
+		fmt.Fprintf(&buf, "To: %s\r\n", to)
+	}
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", e.subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/related; boundary=%s; type=\"text/html\"\r\n\r\n", writer.Boundary())
+
+	htmlPart, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qp := quotedprintable.NewWriter(htmlPart)
+	if _, err := qp.Write([]byte(e.html)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	for _, image := range e.images {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {image.contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-ID":                {"<" + image.cid + ">"},
+			"Content-Disposition":       {"inline"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded := base64.StdEncoding.EncodeToString(image.data)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func ExportEmail(content string, opts ExportOptions) ([]byte, error) {
+	return buildEmail(content, opts).encode("", "")
+}
+
+// smtpPort is the submission port, which [smtp] uses unless it names
+// another.
+const smtpPort = 587
+
+func SendDocumentEmail(cfg SMTPConfig, to, content string, opts ExportOptions) error {
+	if cfg.Host == "" {
+		return fmt.Errorf("smtp host is not configured, set [smtp] in %s", ConfigPath())
+	}
+	from := cfg.From
+	if from == "" {
+		from = cfg.Username
+	}
+
+	start := time.Now()
+	content, opts = prepareExport(exportFormat{name: "email"}, content, opts)
+	msg, err := buildEmail(content, opts).encode(from, to)
+	timeRender("export_email", start)
+	if err != nil {
+		return fmt.Errorf("error building email: %v", err)
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = smtpPort
+	}
+	addr := fmt.Sprintf("%s:%d", cfg.Host, port)
+	if err := smtp.SendMail(addr, auth, from, []string{to}, msg); err != nil {
+		return fmt.Errorf("error sending email: %v", err)
+	}
+	return nil
+}
+
+func htmlEscape(text string) string {
+	replacer := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+	return replacer.Replace(text)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"unicode/utf8"
+)
+
+// Failures the editors explain rather than just print. They arrive wrapped
+// with the file and the cause, so check them with errors.Is.
+var (
+	ErrFileNotWritable     = errors.New("file is not writable")
+	ErrRenderFailed        = errors.New("rendering failed")
+	ErrEncodingUnsupported = errors.New("not UTF-8 text")
+	ErrFileLocked          = errors.New("open in another parselt")
+)
+
+// errorHint says what to do about err, or nothing for errors without advice.
+func errorHint(err error) string {
+	switch {
+	case errors.Is(err, ErrFileNotWritable):
+		return "Check the permissions of the file and its directory, or save it under another name."
+	case errors.Is(err, ErrEncodingUnsupported):
+		return "Convert it to UTF-8, for example with iconv -f latin1 -t utf-8, and open it again."
+	case errors.Is(err, ErrFileLocked):
+		return "Take over editing to save it here, or save your changes under another name."
+	case errors.Is(err, ErrRenderFailed):
+		return "Look for broken markup around the last change."
+	}
+	return ""
+}
+
+// errorMessage is err with its hint, for status lines and dialogs.
+func errorMessage(err error) string {
+	if hint := errorHint(err); hint != "" {
+		return err.Error() + ". " + hint
+	}
+	return err.Error()
+}
+
+// fatal reports a failure of the command line and exits.
+func fatal(what string, err error) {
+	log.Printf("Error %s: %v", what, err)
+	fmt.Fprintf(os.Stderr, "Error %s: %s\n", what, errorMessage(err))
+	os.Exit(1)
+}
+
+// readDocument reads the document at path. Text that is not UTF-8 is
+// refused, as saving it again would mangle it.
+func readDocument(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if err := checkEncoding(path, content); err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func checkEncoding(path string, content []byte) error {
+	if !utf8.Valid(content) {
+		return fmt.Errorf("error opening %s: %w", path, ErrEncodingUnsupported)
+	}
+	return nil
+}
+
+// writeDocument saves content to path.
+func writeDocument(path, content string) error {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return notWritable(path, err)
+	}
+	return nil
+}
+
+// notWritable is the error of a failed save of path, with the cause
+// without the path it already names.
+func notWritable(path string, err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return fmt.Errorf("error saving %s: %w (%w)", path, ErrFileNotWritable, err)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+var (
+	// escapeLineStartRe finds what only means something at the start of a
+	// line: headings, block quotes, list markers, and setext underlines.
+	escapeLineStartRe = regexp.MustCompile(`(?m)^( {0,3})([#>+=-]|\d+[.)])`)
+	escapeEntityRe    = regexp.MustCompile(`&(#?\w+;)`)
+	unescapeRe        = regexp.MustCompile("\\\\([!-/:-@\\[-`{-~])")
+)
+
+// escapeAnywhere are the characters that can start markup wherever they are.
+var escapeAnywhere = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`,
+	`<`, `\<`, `|`, `\|`, `~`, `\~`,
+)
+
+// EscapeMarkdown backslash-escapes the characters that markdown would read
+// as syntax, so that text shows literally. Text that is already escaped is
+// unescaped instead.
+func EscapeMarkdown(text string) string {
+	if strings.Contains(text, `\`) && escapeMarkdown(UnescapeMarkdown(text)) == text {
+		return UnescapeMarkdown(text)
+	}
+	return escapeMarkdown(text)
+}
+
+func escapeMarkdown(text string) string {
+	text = escapeAnywhere.Replace(text)
+	text = escapeEntityRe.ReplaceAllString(text, `\&$1`)
+	return escapeLineStartRe.ReplaceAllStringFunc(text, func(match string) string {
+		// The marker is the last character: # or the . of 1.
+		return match[:len(match)-1] + `\` + match[len(match)-1:]
+	})
+}
+
+// UnescapeMarkdown removes the backslashes in front of punctuation.
+func UnescapeMarkdown(text string) string {
+	return unescapeRe.ReplaceAllString(text, "$1")
+}
+
+// StripMarkdown turns markdown into plain text. Blocks stay on lines of their
+// own, list items and table rows one to a line; everything else about the
+// formatting goes.
+func StripMarkdown(text string) string {
+	doc, source := NewSharedMarkdownProcessor().Parse(text)
+	var out strings.Builder
+	write := func(block string, tight bool) {
+		if out.Len() > 0 {
+			out.WriteString("\n")
+			if !tight {
+				out.WriteString("\n")
+			}
+		}
+		out.WriteString(block)
+	}
+
+	var walk func(n ast.Node)
+	walk = func(n ast.Node) {
+		switch node := n.(type) {
+		case *ast.Heading, *ast.Paragraph, *ast.TextBlock:
+			write(plainInline(node, source), tightItem(node))
+		case *ast.FencedCodeBlock, *ast.CodeBlock, *ast.HTMLBlock:
+			var code strings.Builder
+			for i := 0; i < node.Lines().Len(); i++ {
+				segment := node.Lines().At(i)
+				code.Write(segment.Value(source))
+			}
+			write(strings.TrimRight(code.String(), "\n"), false)
+		case *ast.ThematicBreak:
+		case *east.Table:
+			var rows []string
+			for row := node.FirstChild(); row != nil; row = row.NextSibling() {
+				var cells []string
+				for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+					cells = append(cells, plainInline(cell, source))
+				}
+				rows = append(rows, strings.Join(cells, "\t"))
+			}
+			write(strings.Join(rows, "\n"), false)
+		default:
+			for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+	return out.String()
+}
+
+// tightItem tells whether block n starts an item of a tight list, which goes
+// on the line after the one before it. A list after a paragraph still gets a
+// blank line, a list nested in an item does not.
+func tightItem(n ast.Node) bool {
+	item, ok := n.Parent().(*ast.ListItem)
+	if !ok || n.PreviousSibling() != nil {
+		return false
+	}
+	list := item.Parent().(*ast.List)
+	if item.PreviousSibling() == nil {
+		_, nested := list.Parent().(*ast.ListItem)
+		return nested
+	}
+	return list.IsTight
+}
+
+// plainInline is the text of inline content with its line breaks kept.
+func plainInline(n ast.Node, source []byte) string {
+	var buf strings.Builder
+	ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := child.(type) {
+		case *ast.Text:
+			buf.WriteString(UnescapeMarkdown(string(node.Segment.Value(source))))
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				buf.WriteString("\n")
+			}
+		case *ast.String:
+			buf.Write(node.Value)
+		case *ast.CodeSpan:
+			for c := node.FirstChild(); c != nil; c = c.NextSibling() {
+				if t, ok := c.(*ast.Text); ok {
+					buf.Write(t.Segment.Value(source))
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.AutoLink:
+			buf.Write(node.Label(source))
+		case *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(buf.String())
+}
+
+// textFormat runs fn on the selection, or on the line of the cursor when
+// nothing is selected, and selects the result.
+func textFormat(fn func(text string) string) func(content string, sel TextRange) (string, TextRange) {
+	return func(content string, sel TextRange) (string, TextRange) {
+		if sel.Start == sel.End {
+			sel.Start = strings.LastIndex(content[:sel.Start], "\n") + 1
+			if i := strings.Index(content[sel.End:], "\n"); i >= 0 {
+				sel.End += i
+			} else {
+				sel.End = len(content)
+			}
+		}
+		text := fn(content[sel.Start:sel.End])
+		return content[:sel.Start] + text + content[sel.End:], TextRange{Start: sel.Start, End: sel.Start + len(text)}
+	}
+}
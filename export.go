@@ -0,0 +1,228 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type ExportOptions struct {
+	SourcePath string
+	Title      string
+	Flavor     string
+	Theme      string
+	Width      int
+	// CardWidth is how wide png and svg cards are, in pixels
+	CardWidth  int
+	ManSection string
+	LatexClass string
+	LatexCode  string
+	Template   string
+	// Strip leaves out comments, drafts and TODO callouts
+	Strip bool
+	// Flags are names that conditional blocks can be kept or dropped by,
+	// besides the export format
+	Flags []string
+}
+
+type exportFormat struct {
+	name        string
+	extension   string
+	description string
+	export      func(content string, opts ExportOptions) ([]byte, error)
+}
+
+var exportFormats = []exportFormat{
+	{"html", ".html", "Standalone HTML", ExportHTML},
+	{"pdf", ".pdf", "PDF document", ExportPDF},
+	{"email", ".eml", "Email-safe HTML message", ExportEmail},
+	{"gemini", ".gmi", "Gemtext (Gemini)", ExportGemtext},
+	{"text", ".txt", "Plain text", ExportPlainText},
+	{"man", ".1", "Man page (roff)", ExportMan},
+	{"latex", ".tex", "LaTeX document", ExportLatex},
+	{"png", ".png", "PNG image card", ExportPNG},
+	{"svg", ".svg", "SVG image card", ExportSVG},
+}
+
+func (opts ExportOptions) processor() *SharedMarkdownProcessor {
+	return &SharedMarkdownProcessor{Flavor: opts.Flavor}
+}
+
+func findExportFormat(name string) (exportFormat, bool) {
+	for _, format := range exportFormats {
+		if format.name == name {
+			return format, true
+		}
+	}
+	return exportFormat{}, false
+}
+
+func exportFormatForPath(path string) (exportFormat, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, format := range exportFormats {
+		if format.extension == ext {
+			return format, true
+		}
+	}
+	return exportFormat{}, false
+}
+
+func exportFormatNames() []string {
+	names := make([]string, len(exportFormats))
+	for i, format := range exportFormats {
+		names[i] = format.name
+	}
+	return names
+}
+
+func ExportDocument(content, formatName string, opts ExportOptions) ([]byte, error) {
+	format, ok := findExportFormat(formatName)
+	if !ok {
+		return nil, fmt.Errorf("unknown export format %q (available: %s)", formatName, strings.Join(exportFormatNames(), ", "))
+	}
+	return exportWith(format, content, opts)
+}
+
+func exportWith(format exportFormat, content string, opts ExportOptions) ([]byte, error) {
+	defer timeRender("export_"+format.name, time.Now())
+	content, opts = prepareExport(format, content, opts)
+	return format.export(content, opts)
+}
+
+// prepareExport readies content to be exported as format: it keeps the
+// blocks meant for it, leaves out annotations when asked to, expands
+// variables and gives the export a title when opts has none.
+func prepareExport(format exportFormat, content string, opts ExportOptions) (string, ExportOptions) {
+	content = SelectTargets(content, exportTargets(format, content, opts))
+	if opts.Strip {
+		content = StripAnnotations(content)
+	}
+	content = ExpandVars(content, time.Now())
+	if opts.Title == "" {
+		opts.Title = opts.processor().DocumentTitle(content)
+	}
+	if opts.Title == "" && opts.SourcePath != "" {
+		opts.Title = strings.TrimSuffix(filepath.Base(opts.SourcePath), filepath.Ext(opts.SourcePath))
+	}
+	return content, opts
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatName := fs.String("format", "", "export format: "+strings.Join(exportFormatNames(), ", "))
+	output := fs.String("o", "", "output file (defaults to stdout)")
+	sendTo := fs.String("send", "", "send the email export to this address via SMTP")
+	width := fs.Int("width", defaultTextWidth, "line width for plain text export")
+	cardWidth := fs.Int("card-width", defaultCardWidth, "width in pixels of png and svg cards")
+	section := fs.String("section", "1", "manual section for man page export")
+	class := fs.String("class", "article", "document class for LaTeX export")
+	code := fs.String("code", "listings", "LaTeX code block package: listings or minted")
+	theme := fs.String("theme", "light", "theme for html, email and image card export: light, dark or a .css file (html only)")
+	fs.StringVar(&ActiveProfile, "profile", "", "configuration profile to use")
+	flags := fs.String("flags", "", "comma separated names that <!-- only: name --> blocks are kept for, besides the format")
+	strip := fs.Bool("strip", false, "leave out HTML comments, <!-- draft --> sections and TODO callouts")
+	allowSecrets := fs.Bool("allow-secrets", false, "export even when the document looks like it holds API keys, tokens or passwords")
+	templateName := fs.String("template", "", "export through a template file or a template from "+TemplatesDir())
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: parselt export [-format name] [-o output] [-template name] [-flags names] [-strip] [-send address] [-allow-secrets] input.md")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one input file")
+	}
+
+	input := fs.Arg(0)
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+
+	name := *formatName
+	if name == "" && *output != "" {
+		if format, ok := exportFormatForPath(*output); ok {
+			name = format.name
+		}
+	}
+	if name == "" && *sendTo != "" {
+		name = "email"
+	}
+	if name == "" && *templateName == "" {
+		return fmt.Errorf("cannot infer export format, use -format (%s)", strings.Join(exportFormatNames(), ", "))
+	}
+
+	cfg, err := LoadConfigFor(input)
+	if err != nil {
+		return err
+	}
+	enableMetrics(cfg.Metrics)
+	defer flushMetrics()
+	opts := cfg.ExportOptions(input)
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "width":
+			opts.Width = *width
+		case "card-width":
+			opts.CardWidth = *cardWidth
+		case "section":
+			opts.ManSection = *section
+		case "class":
+			opts.LatexClass = *class
+		case "code":
+			opts.LatexCode = *code
+		case "theme":
+			opts.Theme = *theme
+		case "strip":
+			opts.Strip = *strip
+		case "flags":
+			opts.Flags = splitNames(*flags)
+		}
+	})
+
+	if !*allowSecrets {
+		if found := cfg.Processor().ScanSecrets(input, string(data), cfg.Secrets); len(found) > 0 {
+			return secretsError(input, found)
+		}
+	}
+
+	if *sendTo != "" {
+		if err := SendDocumentEmail(cfg.SMTP, *sendTo, string(data), opts); err != nil {
+			return err
+		}
+		fmt.Printf("Sent %s to %s\n", input, *sendTo)
+		return nil
+	}
+
+	var out []byte
+	if *templateName != "" {
+		path, err := ResolveTemplate(*templateName)
+		if err != nil {
+			return err
+		}
+		out, err = exportWith(templateFormat(path), string(data), opts)
+		if err != nil {
+			return err
+		}
+	} else {
+		out, err = ExportDocument(string(data), name, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	if err := os.WriteFile(*output, out, 0644); err != nil {
+		return fmt.Errorf("error writing export: %v", err)
+	}
+	fmt.Printf("Exported %s to %s\n", input, *output)
+	return RunHooks(cfg.Hooks.AfterExport, "after_export", input, *output)
+}
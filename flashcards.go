@@ -0,0 +1,433 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/BurntSushi/toml"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// flashcardEase is the ease factor of a card never reviewed, and
+	// flashcardMinEase the lowest it can fall to.
+	flashcardEase    = 2.5
+	flashcardMinEase = 1.3
+)
+
+var (
+	flashcardQuestionRe = regexp.MustCompile(`^\s*(?:[-*+]\s+)?Q:\s*(.*)$`)
+	flashcardAnswerRe   = regexp.MustCompile(`^\s*(?:[-*+]\s+)?A:\s*(.*)$`)
+	flashcardTagRe      = regexp.MustCompile(`(^|\s)#flashcard\b`)
+	flashcardHeadingRe  = regexp.MustCompile(`^(#{1,6})\s+`)
+	flashcardListRe     = regexp.MustCompile(`^(?:[-*+]|\d+[.)])\s+`)
+)
+
+// flashcardGrades names the SM-2 grades, from 0 for a complete blackout to
+// 5 for a perfect answer.
+var flashcardGrades = []string{"forgot", "wrong", "almost", "hard", "good", "easy"}
+
+// Flashcard is a question and its answer from a file of the workspace.
+type Flashcard struct {
+	File     string
+	Line     int
+	Question string
+	Answer   string
+}
+
+// ID keys the review state of the card. It is the question alone, so that
+// a card keeps its schedule when the answer is reworded or the file moves.
+func (c Flashcard) ID() string {
+	sum := sha1.Sum([]byte(strings.Join(strings.Fields(c.Question), " ")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ParseFlashcards finds the cards of content, the file at path. A card is
+// a "Q:" line and the "A:" line after it, each running on until a blank
+// line, or a line tagged #flashcard and what follows: the rest of the
+// paragraph, the next one if there is no rest, or the section of a heading.
+// Code blocks are left alone.
+func ParseFlashcards(path, content string) []Flashcard {
+	lines := strings.Split(content, "\n")
+	code := map[int]bool{}
+	for _, fence := range codeFences(content) {
+		for i := fence.Open; i <= fence.Close; i++ {
+			code[i] = true
+		}
+	}
+	blank := func(i int) bool {
+		return i >= len(lines) || code[i] || strings.TrimSpace(lines[i]) == ""
+	}
+
+	var cards []Flashcard
+	for i := 0; i < len(lines); i++ {
+		if code[i] {
+			continue
+		}
+		if m := flashcardQuestionRe.FindStringSubmatch(lines[i]); m != nil {
+			card := Flashcard{File: path, Line: i, Question: m[1]}
+			j := i + 1
+			for ; !blank(j) && !flashcardAnswerRe.MatchString(lines[j]); j++ {
+				card.Question += "\n" + strings.TrimSpace(lines[j])
+			}
+			// The answer may follow the question after a blank line
+			for j < len(lines) && !code[j] && strings.TrimSpace(lines[j]) == "" {
+				j++
+			}
+			if j >= len(lines) || code[j] {
+				continue
+			}
+			a := flashcardAnswerRe.FindStringSubmatch(lines[j])
+			if a == nil {
+				continue
+			}
+			card.Answer = a[1]
+			for j++; !blank(j) && !flashcardQuestionRe.MatchString(lines[j]); j++ {
+				card.Answer += "\n" + strings.TrimSpace(lines[j])
+			}
+			cards = append(cards, card.trimmed())
+			i = j - 1
+			continue
+		}
+		if !flashcardTagRe.MatchString(lines[i]) {
+			continue
+		}
+
+		question := strings.TrimSpace(flashcardTagRe.ReplaceAllString(lines[i], "$1"))
+		card := Flashcard{File: path, Line: i}
+		var answer []string
+		if m := flashcardHeadingRe.FindStringSubmatch(question); m != nil {
+			card.Question = question[len(m[0]):]
+			for j := i + 1; j < len(lines); j++ {
+				if h := flashcardHeadingRe.FindStringSubmatch(lines[j]); h != nil && !code[j] && len(h[1]) <= len(m[1]) {
+					break
+				}
+				answer = append(answer, lines[j])
+			}
+		} else {
+			card.Question = strings.TrimSpace(flashcardListRe.ReplaceAllString(question, ""))
+			j := i + 1
+			if blank(j) {
+				for j < len(lines) && blank(j) {
+					j++
+				}
+			}
+			for ; !blank(j); j++ {
+				answer = append(answer, strings.TrimSpace(lines[j]))
+			}
+		}
+		card.Answer = strings.Join(answer, "\n")
+		if card = card.trimmed(); card.Question != "" && card.Answer != "" {
+			cards = append(cards, card)
+		}
+	}
+	return cards
+}
+
+func (c Flashcard) trimmed() Flashcard {
+	c.Question = strings.TrimSpace(c.Question)
+	c.Answer = strings.TrimSpace(c.Answer)
+	return c
+}
+
+// WorkspaceFlashcards is every card of the files of the workspace.
+func WorkspaceFlashcards() []Flashcard {
+	var cards []Flashcard
+	for _, path := range browserFiles() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		cards = append(cards, ParseFlashcards(path, string(data))...)
+	}
+	return cards
+}
+
+// CardState is where a card stands in the SM-2 schedule: how many reviews
+// in a row went well, the days until the next one and how easy it is.
+type CardState struct {
+	Repetitions int       `toml:"repetitions"`
+	Interval    int       `toml:"interval"`
+	Ease        float64   `toml:"ease"`
+	Due         time.Time `toml:"due"`
+	Reviewed    time.Time `toml:"reviewed"`
+}
+
+// Review schedules the card after an answer of grade, 0 to 5, given at
+// now. An answer below 3 starts the card over; the ease goes up for easy
+// answers and down for hard ones.
+func (s CardState) Review(grade int, now time.Time) CardState {
+	if s.Ease == 0 {
+		s.Ease = flashcardEase
+	}
+	if grade >= 3 {
+		switch s.Repetitions {
+		case 0:
+			s.Interval = 1
+		case 1:
+			s.Interval = 6
+		default:
+			s.Interval = int(math.Round(float64(s.Interval) * s.Ease))
+		}
+		s.Repetitions++
+	} else {
+		s.Repetitions = 0
+		s.Interval = 1
+	}
+	miss := float64(5 - grade)
+	s.Ease = math.Max(s.Ease+0.1-miss*(0.08+miss*0.02), flashcardMinEase)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	s.Due = day.AddDate(0, 0, s.Interval)
+	s.Reviewed = now
+	return s
+}
+
+type flashcardStore struct {
+	Cards map[string]CardState `toml:"cards"`
+}
+
+func flashcardsPath() string {
+	return filepath.Join(stateDir(), "flashcards.toml")
+}
+
+func loadFlashcards() (*flashcardStore, error) {
+	store := &flashcardStore{Cards: map[string]CardState{}}
+	if _, err := os.Stat(flashcardsPath()); os.IsNotExist(err) {
+		return store, nil
+	}
+	if _, err := toml.DecodeFile(flashcardsPath(), store); err != nil {
+		return store, fmt.Errorf("error reading flashcards: %v", err)
+	}
+	if store.Cards == nil {
+		store.Cards = map[string]CardState{}
+	}
+	return store, nil
+}
+
+func (s *flashcardStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(flashcardsPath()), 0755); err != nil {
+		return fmt.Errorf("error saving flashcards: %v", err)
+	}
+	file, err := os.Create(flashcardsPath())
+	if err != nil {
+		return fmt.Errorf("error saving flashcards: %v", err)
+	}
+	defer file.Close()
+	if err := toml.NewEncoder(file).Encode(s); err != nil {
+		return fmt.Errorf("error saving flashcards: %v", err)
+	}
+	return nil
+}
+
+// DueFlashcards is the cards of the workspace to review at now: those due,
+// the longest overdue first, then those never reviewed.
+func DueFlashcards(now time.Time) ([]Flashcard, error) {
+	store, err := loadFlashcards()
+	if err != nil {
+		return nil, err
+	}
+	var due, fresh []Flashcard
+	seen := map[string]bool{}
+	for _, card := range WorkspaceFlashcards() {
+		id := card.ID()
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		state, ok := store.Cards[id]
+		switch {
+		case !ok:
+			fresh = append(fresh, card)
+		case !state.Due.After(now):
+			due = append(due, card)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return store.Cards[due[i].ID()].Due.Before(store.Cards[due[j].ID()].Due)
+	})
+	return append(due, fresh...), nil
+}
+
+// flashcardReview is a review session. The first grade of a card is the one
+// that schedules it; a card graded below 4 comes round again at the end of
+// the session until it is answered well.
+type flashcardReview struct {
+	queue    []Flashcard
+	shown    bool
+	graded   map[string]bool
+	reviewed int
+}
+
+func newFlashcardReview(now time.Time) (*flashcardReview, error) {
+	cards, err := DueFlashcards(now)
+	if err != nil {
+		return nil, err
+	}
+	if len(cards) == 0 {
+		return nil, nil
+	}
+	return &flashcardReview{queue: cards, graded: map[string]bool{}}, nil
+}
+
+func (r *flashcardReview) card() Flashcard {
+	return r.queue[0]
+}
+
+func (r *flashcardReview) done() bool {
+	return len(r.queue) == 0
+}
+
+// grade records grade for the current card and moves on to the next.
+func (r *flashcardReview) grade(grade int, now time.Time) error {
+	card := r.card()
+	r.queue, r.shown = r.queue[1:], false
+	if grade < 4 {
+		r.queue = append(r.queue, card)
+	}
+	id := card.ID()
+	if r.graded[id] {
+		return nil
+	}
+	r.graded[id] = true
+	r.reviewed++
+	store, err := loadFlashcards()
+	if err != nil {
+		return err
+	}
+	store.Cards[id] = store.Cards[id].Review(grade, now)
+	return store.save()
+}
+
+func (r *flashcardReview) summary() string {
+	if r.reviewed == 1 {
+		return "Reviewed 1 flashcard"
+	}
+	return fmt.Sprintf("Reviewed %d flashcards", r.reviewed)
+}
+
+func (m *model) openFlashcards() {
+	review, err := newFlashcardReview(time.Now())
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	if review == nil {
+		m.status = "No flashcards due"
+		return
+	}
+	m.overlay = overlayFlashcards
+	m.flashcards = review
+}
+
+func (m *model) updateFlashcards(msg tea.KeyMsg) {
+	r := m.flashcards
+	switch s := msg.String(); {
+	case s == "esc" || s == "q":
+		m.overlay = overlayNone
+		m.flashcards = nil
+		m.status = r.summary()
+	case !r.shown && (s == " " || s == "enter"):
+		r.shown = true
+	case r.shown && len(s) == 1 && s[0] >= '0' && s[0] <= '5':
+		if err := r.grade(int(s[0]-'0'), time.Now()); err != nil {
+			m.status = err.Error()
+		}
+		if r.done() {
+			m.overlay = overlayNone
+			m.flashcards = nil
+			m.status = r.summary()
+		}
+	}
+}
+
+func (m model) flashcardsView() string {
+	r := m.flashcards
+	card := r.card()
+	width := max(min(m.width-6, 80), 20)
+	wrap := lipgloss.NewStyle().Width(width)
+	parts := []string{
+		titleStyle.Render(fmt.Sprintf("Flashcards (%d left)", len(r.queue))),
+		helpStyle.Render(fmt.Sprintf("%s:%d", card.File, card.Line+1)),
+		"",
+		wrap.Bold(true).Render(card.Question),
+		"",
+	}
+	if r.shown {
+		var grades []string
+		for i, name := range flashcardGrades {
+			grades = append(grades, fmt.Sprintf("%d: %s", i, name))
+		}
+		parts = append(parts, wrap.Render(card.Answer), "", helpStyle.Render(strings.Join(grades, " • ")+" • esc: stop"))
+	} else {
+		parts = append(parts, helpStyle.Render("space: show answer • esc: stop"))
+	}
+	return pickerStyle.Render(lipgloss.JoinVertical(lipgloss.Left, parts...))
+}
+
+// reviewFlashcards runs a review session in a dialog.
+func (g *GUIApp) reviewFlashcards() {
+	review, err := newFlashcardReview(time.Now())
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+	if review == nil {
+		dialog.ShowInformation("Flashcards", "No flashcards due.", g.window)
+		return
+	}
+
+	source := widget.NewLabel("")
+	question := widget.NewRichTextFromMarkdown("")
+	question.Wrapping = fyne.TextWrapWord
+	answer := widget.NewRichTextFromMarkdown("")
+	answer.Wrapping = fyne.TextWrapWord
+	show := widget.NewButton("Show Answer", nil)
+	grades := container.NewGridWithColumns(len(flashcardGrades))
+	var d dialog.Dialog
+	refresh := func() {
+		if review.done() {
+			d.Hide()
+			dialog.ShowInformation("Flashcards", review.summary()+".", g.window)
+			return
+		}
+		card := review.card()
+		source.SetText(fmt.Sprintf("%s:%d (%d left)", card.File, card.Line+1, len(review.queue)))
+		question.ParseMarkdown("**" + card.Question + "**")
+		answer.ParseMarkdown(card.Answer)
+		answer.Hidden, grades.Hidden, show.Hidden = !review.shown, !review.shown, review.shown
+		answer.Refresh()
+		grades.Refresh()
+		show.Refresh()
+	}
+	show.OnTapped = func() {
+		review.shown = true
+		refresh()
+	}
+	for i, name := range flashcardGrades {
+		grades.Add(widget.NewButton(fmt.Sprintf("%d %s", i, name), func() {
+			if err := review.grade(i, time.Now()); err != nil {
+				dialog.ShowError(err, g.window)
+			}
+			refresh()
+		}))
+	}
+
+	content := container.NewVBox(source, question, widget.NewSeparator(), answer, show, grades)
+	d = dialog.NewCustom("Review Flashcards", "Stop", content, g.window)
+	d.Resize(fyne.NewSize(560, 360))
+	refresh()
+	d.Show()
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// SplitConfig is the [split] table. With FollowSections set, the preview
+// of split mode glides to a section when the cursor enters it, rather than
+// jumping, and briefly lights up its heading.
+type SplitConfig struct {
+	FollowSections bool `toml:"follow_sections"`
+}
+
+const (
+	followFrame    = 16 * time.Millisecond
+	followDuration = 200 * time.Millisecond
+	followFlash    = 700 * time.Millisecond
+)
+
+// sectionFollow is the preview following the section of the cursor.
+// section indexes model.crumbHeadings, -1 being the text before the first
+// heading; shown is the offset on screen after the last update, and target
+// where the preview glides to. The lines from flashStart to flashEnd are lit
+// up while flashing.
+type sectionFollow struct {
+	section    int
+	shown      int
+	target     int
+	gliding    bool
+	seq        int
+	flashStart int
+	flashEnd   int
+	flashing   bool
+	flashSeq   int
+}
+
+type followTickMsg struct {
+	seq int
+}
+
+type followFlashMsg struct {
+	seq int
+}
+
+// cursorSection is the heading of the section the source line is in, as an
+// index of headings, or -1 above the first one.
+func cursorSection(headings []OutlineHeading, line int) int {
+	path := headingPath(headings, line)
+	if len(path) == 0 {
+		return -1
+	}
+	return path[len(path)-1]
+}
+
+// headingBlock is the span of rendered lines the heading on source line
+// line became in the preview.
+func (s ScrollMap) headingBlock(line int) (int, int, bool) {
+	for i, anchor := range s {
+		if anchor.Source != line {
+			continue
+		}
+		end := anchor.Rendered + 1
+		if i+1 < len(s) {
+			end = max(s[i+1].Rendered, end)
+		}
+		return anchor.Rendered, end, true
+	}
+	return 0, 0, false
+}
+
+// followSection keeps the split preview on the section of the cursor once
+// msg has been handled: entering a section glides the preview to its
+// heading, and it then stays put while the cursor can be seen in it. Only
+// when the cursor would leave the preview does it follow the cursor line
+// again. Scrolling the preview itself is never slowed down.
+func (m *model) followSection(msg tea.Msg) tea.Cmd {
+	defer func() { m.follow.shown = m.viewport.YOffset }()
+	section := cursorSection(m.crumbHeadings, m.textarea.Line())
+	if !m.split.FollowSections || m.mode != splitMode || m.scrollMap == nil {
+		m.follow.section, m.follow.gliding, m.follow.flashing = section, false, false
+		return nil
+	}
+	switch msg := msg.(type) {
+	case followTickMsg, followFlashMsg:
+		return nil
+	case tea.MouseMsg:
+		if m.overPreview(msg.X, msg.Y) {
+			m.follow.section, m.follow.gliding = section, false
+			return nil
+		}
+	}
+
+	cursor := m.scrollMap.RenderedLine(m.textarea.Line())
+	visible := func(y int) bool { return cursor >= y && cursor < y+m.viewport.Height }
+	target := m.follow.shown
+	if m.follow.gliding {
+		target = m.follow.target
+	}
+	var cmds []tea.Cmd
+	if section != m.follow.section {
+		m.follow.section = section
+		target = 0
+		if section >= 0 {
+			heading := m.crumbHeadings[section].Line
+			target = max(m.scrollMap.RenderedLine(heading)-1, 0)
+			if start, end, ok := m.scrollMap.headingBlock(heading); ok {
+				m.follow.flashSeq++
+				seq := m.follow.flashSeq
+				m.follow.flashStart, m.follow.flashEnd, m.follow.flashing = start, end, true
+				cmds = append(cmds, tea.Tick(followFlash, func(time.Time) tea.Msg { return followFlashMsg{seq: seq} }))
+			}
+		}
+	}
+	if !visible(target) {
+		// Where syncPreviewScroll put the preview, the cursor a third down
+		target = m.viewport.YOffset
+	}
+
+	m.viewport.SetYOffset(target)
+	target = m.viewport.YOffset
+	m.viewport.SetYOffset(m.follow.shown)
+	if target == m.viewport.YOffset {
+		m.follow.gliding = false
+		return tea.Batch(cmds...)
+	}
+	m.follow.target = target
+	if !m.follow.gliding {
+		m.follow.gliding = true
+		m.follow.seq++
+		seq := m.follow.seq
+		cmds = append(cmds, tea.Tick(followFrame, func(time.Time) tea.Msg { return followTickMsg{seq: seq} }))
+	}
+	return tea.Batch(cmds...)
+}
+
+// glide moves the preview a step closer to where it is going: most of the
+// way at first and less as it gets there.
+func (m *model) glide(msg followTickMsg) tea.Cmd {
+	if msg.seq != m.follow.seq || !m.follow.gliding {
+		return nil
+	}
+	y := m.viewport.YOffset
+	step := (m.follow.target - y) / 3
+	switch {
+	case step == 0 && m.follow.target > y:
+		step = 1
+	case step == 0 && m.follow.target < y:
+		step = -1
+	}
+	m.viewport.SetYOffset(y + step)
+	if m.viewport.YOffset == y || m.viewport.YOffset == m.follow.target {
+		m.follow.gliding = false
+		return nil
+	}
+	return tea.Tick(followFrame, func(time.Time) tea.Msg { return followTickMsg{seq: msg.seq} })
+}
+
+func (m *model) endFlash(msg followFlashMsg) {
+	if msg.seq == m.follow.flashSeq {
+		m.follow.flashing = false
+	}
+}
+
+// splitPreview is the visible part of the split preview, with the heading
+// of the section just entered lit up.
+func (m model) splitPreview() string {
+	view := m.viewport.View()
+	if !m.follow.flashing {
+		return view
+	}
+	lines := strings.Split(view, "\n")
+	for i := range lines {
+		if line := m.viewport.YOffset + i; line >= m.follow.flashStart && line < m.follow.flashEnd {
+			if text := strings.TrimRight(ansi.Strip(lines[i]), " "); text != "" {
+				lines[i] = termTaskFocusStyle.Render(text) + strings.Repeat(" ", max(ansi.StringWidth(lines[i])-ansi.StringWidth(text), 0))
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// followSection keeps the preview on the section of line, the cursor
+// line, as the terminal does: entering a section glides to its heading and
+// lights it up, and the preview then stays put while the cursor can be seen
+// in it. It returns false when the preview should follow the cursor line
+// as usual instead.
+func (g *GUIApp) followSection(line int) bool {
+	section := cursorSection(g.crumbHeadings, line)
+	entered := section != g.followedSection
+	g.followedSection = section
+	positions := g.previewPositions()
+	if !g.config.Split.FollowSections || positions == nil {
+		return false
+	}
+	height := g.previewScroll.Size().Height
+	cursor := float32(positions.RenderedLine(line))
+	visible := func(y float32) bool { return cursor >= y && cursor < y+height }
+	from := g.previewScroll.Offset
+	if !entered {
+		if visible(from.Y) {
+			return true
+		}
+		if g.followAnimation != nil {
+			g.followAnimation.Stop()
+		}
+		return false
+	}
+
+	var to float32
+	if section >= 0 {
+		heading := g.crumbHeadings[section].Line
+		to = float32(positions.RenderedLine(heading))
+		if start, end, ok := positions.headingBlock(heading); ok {
+			g.flashPreview(float32(start), float32(end-start))
+		}
+	}
+	if !visible(to) {
+		g.scrollPreviewTo(line)
+		to = g.previewScroll.Offset.Y
+		g.previewScroll.ScrollToOffset(from)
+	}
+	to = min(max(to, 0), max(g.preview.MinSize().Height-height, 0))
+	if g.followAnimation != nil {
+		g.followAnimation.Stop()
+	}
+	g.followAnimation = fyne.NewAnimation(followDuration, func(done float32) {
+		g.previewScroll.ScrollToOffset(fyne.NewPos(0, from.Y+(to-from.Y)*done))
+	})
+	g.followAnimation.Curve = fyne.AnimationEaseOut
+	g.followAnimation.Start()
+	return true
+}
+
+// flashPreview lights up the band of the preview height pixels high at y
+// for a moment.
+func (g *GUIApp) flashPreview(y, height float32) {
+	if g.previewFlash == nil {
+		return
+	}
+	highlight := theme.Color(theme.ColorNameSelection)
+	g.previewFlash.FillColor = highlight
+	g.previewFlash.Move(fyne.NewPos(0, y))
+	g.previewFlash.Resize(fyne.NewSize(g.preview.Size().Width, height))
+	g.previewFlash.Show()
+	g.previewFlash.Refresh()
+	if g.flashAnimation != nil {
+		g.flashAnimation.Stop()
+	}
+	g.flashAnimation = canvas.NewColorRGBAAnimation(highlight, color.Transparent, followFlash, func(c color.Color) {
+		g.previewFlash.FillColor = c
+		g.previewFlash.Refresh()
+	})
+	g.flashAnimation.Curve = fyne.AnimationEaseIn
+	g.flashAnimation.Start()
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var (
+	formatTaskRe = regexp.MustCompile(`^(\s*(?:[-*+]|\d+[.)]) )\[[ xX]\] `)
+	formatListRe = regexp.MustCompile(`^(\s*(?:[-*+]|\d+[.)]) )`)
+	formatURLRe  = regexp.MustCompile(`^(https?://|mailto:|www\.)\S+$`)
+)
+
+// formatTable is the skeleton inserted by the table command.
+const formatTable = "|  |  |\n| --- | --- |\n|  |  |"
+
+// formatCommand wraps the selection in markup or inserts a snippet at the
+// cursor. format gets the selected byte range and returns the new content
+// and the range to select afterwards; an empty range is the cursor.
+type formatCommand struct {
+	name   string
+	title  string
+	label  string
+	format func(content string, sel TextRange) (string, TextRange)
+}
+
+var formatCommands = []formatCommand{
+	{"bold", "Bold", "B", wrapFormat("**")},
+	{"italic", "Italic", "I", wrapFormat("*")},
+	{"code", "Inline Code", "`", wrapFormat("`")},
+	{"link", "Link", "Link", linkFormat("[")},
+	{"image", "Image", "Image", linkFormat("![")},
+	{"code_block", "Code Block", "```", lineFormat(codeBlockFormat)},
+	{"table", "Table", "Table", blockFormat(formatTable, 2)},
+	{"task", "Task Item", "[ ]", lineFormat(taskFormat)},
+	{"escape", "Escape Markdown", `\`, textFormat(EscapeMarkdown)},
+	{"strip", "Strip Formatting", "Plain", textFormat(StripMarkdown)},
+}
+
+// wrapFormat puts marker around the selection, or removes it when the
+// selection is already wrapped in it. Without a selection the cursor ends
+// up between the markers.
+func wrapFormat(marker string) func(content string, sel TextRange) (string, TextRange) {
+	return func(content string, sel TextRange) (string, TextRange) {
+		text := content[sel.Start:sel.End]
+		n := len(marker)
+
+		// The markers are just outside the selection
+		if markerRun(content[:sel.Start], marker, true) && markerRun(content[sel.End:], marker, false) {
+			unwrapped := content[:sel.Start-n] + text + content[sel.End+n:]
+			return unwrapped, TextRange{Start: sel.Start - n, End: sel.End - n}
+		}
+		// The selection includes the markers
+		if len(text) >= 2*n && markerRun(text[:len(text)-n], marker, false) && markerRun(text[n:], marker, true) &&
+			strings.HasPrefix(text, marker) && strings.HasSuffix(text, marker) {
+			unwrapped := content[:sel.Start] + text[n:len(text)-n] + content[sel.End:]
+			return unwrapped, TextRange{Start: sel.Start, End: sel.End - 2*n}
+		}
+
+		// Spaces around the selection stay outside the markup
+		start := sel.Start + len(text) - len(strings.TrimLeftFunc(text, unicode.IsSpace))
+		end := max(start, sel.Start+len(strings.TrimRightFunc(text, unicode.IsSpace)))
+		open, close := marker, marker
+		if marker == "`" && strings.Contains(content[start:end], "`") {
+			open, close = "`` ", " ``"
+		}
+		wrapped := content[:start] + open + content[start:end] + close + content[end:]
+		return wrapped, TextRange{Start: start + len(open), End: end + len(open)}
+	}
+}
+
+// markerRun reports whether text has exactly marker at its end (or start),
+// so that the * of italic is not mistaken for half of a ** of bold. A run of
+// three is bold and italic at once.
+func markerRun(text, marker string, atEnd bool) bool {
+	run := 0
+	for run < len(text) {
+		i := run
+		if atEnd {
+			i = len(text) - 1 - run
+		}
+		if text[i] != marker[0] {
+			break
+		}
+		run++
+	}
+	return run == len(marker) || (marker[0] == '*' && run == 3)
+}
+
+// linkFormat makes the selection the text of a link or image, or its
+// destination when it is a URL, and puts the cursor where the missing part
+// goes.
+func linkFormat(open string) func(content string, sel TextRange) (string, TextRange) {
+	return func(content string, sel TextRange) (string, TextRange) {
+		text := content[sel.Start:sel.End]
+		var snippet string
+		cursor := len(open)
+		switch {
+		case text == "":
+			snippet = open + "]()"
+		case formatURLRe.MatchString(strings.TrimSpace(text)):
+			snippet = open + "](" + strings.TrimSpace(text) + ")"
+		default:
+			snippet = open + text + "]()"
+			cursor = len(snippet) - 1
+		}
+		pos := sel.Start + cursor
+		return content[:sel.Start] + snippet + content[sel.End:], TextRange{Start: pos, End: pos}
+	}
+}
+
+// lineFormat runs fn on the whole lines the selection touches.
+func lineFormat(fn func(lines string) (string, int)) func(content string, sel TextRange) (string, TextRange) {
+	return func(content string, sel TextRange) (string, TextRange) {
+		start := strings.LastIndex(content[:sel.Start], "\n") + 1
+		end := sel.End
+		// A selection ending at the start of a line leaves that line out
+		if end > sel.Start && content[end-1] == '\n' {
+			end--
+		}
+		if i := strings.Index(content[end:], "\n"); i >= 0 {
+			end += i
+		} else {
+			end = len(content)
+		}
+		replaced, cursor := fn(content[start:end])
+		pos := start + cursor
+		return content[:start] + replaced + content[end:], TextRange{Start: pos, End: pos}
+	}
+}
+
+// codeBlockFormat fences the lines, leaving the cursor where the language
+// goes.
+func codeBlockFormat(lines string) (string, int) {
+	return "```\n" + lines + "\n```", 3
+}
+
+// blockFormat puts block, such as a table skeleton, on an empty line or
+// after the lines, with the cursor at offset cursor in it. A blank line keeps
+// the line above from becoming part of the block.
+func blockFormat(block string, cursor int) func(content string, sel TextRange) (string, TextRange) {
+	return func(content string, sel TextRange) (string, TextRange) {
+		start := strings.LastIndex(content[:sel.Start], "\n") + 1
+		afterText := start > 1 && content[start-2] != '\n'
+		return lineFormat(func(lines string) (string, int) {
+			switch {
+			case strings.TrimSpace(lines) != "":
+				return lines + "\n\n" + block, len(lines) + 2 + cursor
+			case afterText:
+				return "\n" + block, 1 + cursor
+			}
+			return block, cursor
+		})(content, sel)
+	}
+}
+
+// taskFormat turns every line into a task list item, and task items back
+// into plain list items.
+func taskFormat(lines string) (string, int) {
+	split := strings.Split(lines, "\n")
+	for i, line := range split {
+		switch {
+		case formatTaskRe.MatchString(line):
+			split[i] = formatTaskRe.ReplaceAllString(line, "$1")
+		case formatListRe.MatchString(line):
+			split[i] = formatListRe.ReplaceAllString(line, "$1[ ] ")
+		default:
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			split[i] = indent + "- [ ] " + strings.TrimLeft(line, " \t")
+		}
+	}
+	result := strings.Join(split, "\n")
+	return result, len(result)
+}
+
+// formatFor finds the formatting command bound to msg. The commands share
+// their names with the [keys] config.
+func (k keyMap) formatFor(msg tea.KeyMsg) (formatCommand, bool) {
+	bindings := k.byName()
+	for _, command := range formatCommands {
+		if key.Matches(msg, *bindings[command.name]) {
+			return command, true
+		}
+	}
+	return formatCommand{}, false
+}
+
+// applyFormat runs a formatting command on the selection made with expand
+// selection, or at the cursor. A link made from a URL gets the page title
+// when fetching titles is turned on.
+func (m *model) applyFormat(command formatCommand) tea.Cmd {
+	content := m.textarea.Value()
+	sel := TextRange{Start: m.cursorOffset(), End: m.cursorOffset()}
+	if m.selection != nil {
+		sel = *m.selection
+	}
+	formatted, result := command.format(content, sel)
+
+	m.clearSelection()
+	m.textarea.SetValue(formatted)
+	row, col := rowColumn(formatted, result.End)
+	moveCursorTo(&m.textarea, row, col)
+	if result.End > result.Start {
+		// Keep the text selected so another format applies to it too
+		result.Kind = "text"
+		m.selection = &result
+	}
+	m.content = formatted
+	if m.mode == splitMode {
+		m.refreshPreview()
+		m.syncPreviewScroll()
+	}
+
+	if url := strings.TrimSpace(content[sel.Start:sel.End]); command.name == "link" && isBareURL(url) {
+		return m.fetchLinkTitle(sel.Start, "[]("+url+")", url)
+	}
+	return nil
+}
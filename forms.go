@@ -0,0 +1,459 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Like the task checkboxes, form fields are marked with private use
+// characters while the preview is rendered: an option, unselected or
+// selected, and the two ends of a blank, whose value sits between them.
+const (
+	fieldOption   = '\ue002'
+	fieldSelected = '\ue003'
+	fieldOpen     = '\ue004'
+	fieldClose    = '\ue005'
+)
+
+var (
+	// optionRe matches a list item that starts with ( ) or (x).
+	optionRe = regexp.MustCompile(`^(\s*(?:[-*+]|\d+[.)])\s+)\(([ xX])\)(?:\s|$)`)
+	// blankRe matches [___] and, filled in, [___ value]. A blank followed by
+	// ( or [ is the text of a link instead.
+	blankRe = regexp.MustCompile(`\[_{3,}(?:[ \t]+([^\]\n]*))?\]`)
+
+	fieldChars = string([]rune{fieldOption, fieldSelected, fieldOpen, fieldClose})
+)
+
+// FormField is an option of a list of choices, a list item that starts
+// with ( ) or, selected, (x), or a blank to fill in, [___] or, filled in,
+// [___ value], in the markdown source.
+type FormField struct {
+	Line  int
+	Start int // Byte offsets of the ( ) or of the whole blank
+	End   int
+	Blank bool
+	// Group is the line of the first option of the list an option is in;
+	// selecting one clears the others.
+	Group    int
+	Selected bool
+	Value    string
+}
+
+// FormFields lists the options and blanks of content in document order,
+// the order in which the previews render them. Code blocks and code spans
+// have none.
+func FormFields(content string) []FormField {
+	code := map[int]bool{}
+	for _, fence := range codeFences(content) {
+		for i := fence.Open; i <= fence.Close; i++ {
+			code[i] = true
+		}
+	}
+	var fields []FormField
+	group, indent := -1, ""
+	offset := 0
+	for i, line := range strings.Split(content, "\n") {
+		start := offset
+		offset += len(line) + 1
+		if code[i] {
+			group = -1
+			continue
+		}
+		if m := optionRe.FindStringSubmatchIndex(line); m != nil {
+			prefix := line[m[2]:m[3]]
+			itemIndent := prefix[:len(prefix)-len(strings.TrimLeft(prefix, " \t"))]
+			if group < 0 || itemIndent != indent {
+				group, indent = i, itemIndent
+			}
+			fields = append(fields, FormField{
+				Line:     i,
+				Start:    start + m[3],
+				End:      start + m[3] + 3,
+				Group:    group,
+				Selected: line[m[4]:m[5]] != " ",
+			})
+		} else if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, indent+" ") && !strings.HasPrefix(line, indent+"\t") {
+			group = -1
+		}
+		for _, m := range blankRe.FindAllStringSubmatchIndex(line, -1) {
+			if inCodeSpan(line, m[0]) || (m[1] < len(line) && (line[m[1]] == '(' || line[m[1]] == '[')) {
+				continue
+			}
+			field := FormField{Line: i, Start: start + m[0], End: start + m[1], Blank: true}
+			if m[2] >= 0 {
+				field.Value = strings.TrimSpace(line[m[2]:m[3]])
+			}
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// inCodeSpan reports whether byte i of line is inside a code span.
+func inCodeSpan(line string, i int) bool {
+	return strings.Count(line[:i], "`")%2 == 1
+}
+
+// markFormFields puts the placeholders of the form fields in content. Line
+// breaks stay where they are.
+func markFormFields(content string) string {
+	fields := FormFields(content)
+	if len(fields) == 0 {
+		return content
+	}
+	var b strings.Builder
+	last := 0
+	for _, f := range fields {
+		b.WriteString(content[last:f.Start])
+		switch {
+		case f.Blank:
+			b.WriteRune(fieldOpen)
+			b.WriteString(f.Value)
+			b.WriteRune(fieldClose)
+		case f.Selected:
+			b.WriteRune(fieldSelected)
+		default:
+			b.WriteRune(fieldOption)
+		}
+		last = f.End
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// SelectOption selects field and clears the other options of its list.
+func SelectOption(content string, fields []FormField, field FormField) string {
+	b := []byte(content)
+	for _, f := range fields {
+		if f.Blank || f.Group != field.Group {
+			continue
+		}
+		if f.End <= len(b) && b[f.Start] == '(' && b[f.End-1] == ')' {
+			b[f.Start+1] = ' '
+			if f.Start == field.Start {
+				b[f.Start+1] = 'x'
+			}
+		}
+	}
+	return string(b)
+}
+
+// FillBlank puts value in field, or empties it.
+func FillBlank(content string, field FormField, value string) string {
+	value = strings.Join(strings.Fields(strings.ReplaceAll(value, "]", "")), " ")
+	blank := "[___]"
+	if value != "" {
+		blank = "[___ " + value + "]"
+	}
+	return content[:field.Start] + blank + content[field.End:]
+}
+
+// terminalFields puts the glyphs of the form fields in place of the
+// placeholders and returns the lines they are on, a line once for each
+// field on it.
+func terminalFields(rendered string) (string, []int) {
+	if !strings.ContainsAny(rendered, fieldChars) {
+		return rendered, nil
+	}
+	replacer := strings.NewReplacer(
+		string([]rune{fieldOpen, fieldClose}), blankMarks[0]+"______"+blankMarks[1],
+		string(fieldOpen), blankMarks[0],
+		string(fieldClose), blankMarks[1],
+		string(fieldOption), optionMarks[0],
+		string(fieldSelected), optionMarks[1],
+	)
+	lines := strings.Split(rendered, "\n")
+	var fieldLines []int
+	for i, line := range lines {
+		for _, r := range line {
+			if r == fieldOption || r == fieldSelected || r == fieldOpen {
+				fieldLines = append(fieldLines, i)
+			}
+		}
+		lines[i] = replacer.Replace(line)
+	}
+	return strings.Join(lines, "\n"), fieldLines
+}
+
+// previewControl is a task checkbox or form field of the terminal preview.
+// index counts in TaskItems or FormFields.
+type previewControl struct {
+	line  int
+	field bool
+	index int
+}
+
+// controls merges the tasks and fields of the preview in the order they
+// are on screen.
+func (m *model) controls() []previewControl {
+	controls := make([]previewControl, 0, len(m.taskLines)+len(m.fieldLines))
+	t, f := 0, 0
+	for t < len(m.taskLines) || f < len(m.fieldLines) {
+		if f == len(m.fieldLines) || (t < len(m.taskLines) && m.taskLines[t] <= m.fieldLines[f]) {
+			controls = append(controls, previewControl{line: m.taskLines[t], index: t})
+			t++
+		} else {
+			controls = append(controls, previewControl{line: m.fieldLines[f], field: true, index: f})
+			f++
+		}
+	}
+	return controls
+}
+
+// activateControl toggles a task, selects an option or asks for the value
+// of a blank.
+func (m *model) activateControl(i int) {
+	controls := m.controls()
+	if i < 0 || i >= len(controls) {
+		return
+	}
+	m.taskFocus = i
+	if !controls[i].field {
+		m.toggleTask(controls[i].index)
+		return
+	}
+	fields := FormFields(m.textarea.Value())
+	index := controls[i].index
+	if len(fields) != len(m.fieldLines) {
+		return
+	}
+	if fields[index].Blank {
+		m.overlay = overlayBlank
+		m.blank = &blankPrompt{field: index, value: fields[index].Value}
+		return
+	}
+	m.setFormContent(SelectOption(m.textarea.Value(), fields, fields[index]))
+	m.status = "Selected option on line " + fmt.Sprint(fields[index].Line+1)
+}
+
+// setFormContent replaces the document with content, changed from the
+// preview or a runbook, and keeps the cursor and the preview where they were.
+func (m *model) setFormContent(content string) {
+	cursor := m.cursorOffset()
+	m.textarea.SetValue(content)
+	row, col := rowColumn(content, cursor)
+	moveCursorTo(&m.textarea, row, col)
+	m.content = content
+	offset := m.viewport.YOffset
+	m.refreshPreview()
+	m.viewport.SetYOffset(offset)
+}
+
+// blankPrompt asks the terminal app for the value of a blank.
+type blankPrompt struct {
+	field int
+	value string
+}
+
+func (m *model) updateBlank(msg tea.KeyMsg) {
+	p := m.blank
+	switch msg.String() {
+	case "esc":
+		m.overlay = overlayNone
+		m.blank = nil
+	case "enter":
+		m.overlay = overlayNone
+		m.blank = nil
+		fields := FormFields(m.textarea.Value())
+		if p.field >= len(fields) || !fields[p.field].Blank {
+			return
+		}
+		m.setFormContent(FillBlank(m.textarea.Value(), fields[p.field], p.value))
+		m.status = "Filled in line " + fmt.Sprint(fields[p.field].Line+1)
+	case "backspace":
+		if runes := []rune(p.value); len(runes) > 0 {
+			p.value = string(runes[:len(runes)-1])
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			p.value += string(msg.Runes)
+		}
+	}
+}
+
+func (m model) blankView() string {
+	body := lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render("Fill In"),
+		"",
+		pickerSelectedStyle.Render(m.blank.value+"█"),
+		"",
+		helpStyle.Render("enter: fill in • esc: cancel"),
+	)
+	return pickerStyle.Render(body)
+}
+
+// formSegments replaces the field placeholders in the GUI preview with
+// radio buttons and entries. fill gets the index of the field in FormFields
+// and, for a blank, its new value; without it the fields are read-only.
+// index counts the fields, it carries on from one call to the next.
+func formSegments(segments []widget.RichTextSegment, fill func(field int, value string), index *int) []widget.RichTextSegment {
+	var walk func([]widget.RichTextSegment) []widget.RichTextSegment
+	walk = func(segments []widget.RichTextSegment) []widget.RichTextSegment {
+		var out []widget.RichTextSegment
+		for _, segment := range segments {
+			switch seg := segment.(type) {
+			case *widget.ListSegment:
+				seg.Items = walk(seg.Items)
+			case *widget.ParagraphSegment:
+				seg.Texts = walk(seg.Texts)
+			case *widget.TextSegment:
+				out = append(out, splitFieldSegment(seg, index, fill)...)
+				continue
+			}
+			out = append(out, segment)
+		}
+		return out
+	}
+	return walk(segments)
+}
+
+func splitFieldSegment(seg *widget.TextSegment, index *int, fill func(field int, value string)) []widget.RichTextSegment {
+	var out []widget.RichTextSegment
+	text := seg.Text
+	for {
+		i := strings.IndexAny(text, string([]rune{fieldOption, fieldSelected, fieldOpen}))
+		if i < 0 {
+			break
+		}
+		if i > 0 {
+			before := *seg
+			before.Text = text[:i]
+			before.Style.Inline = true
+			out = append(out, &before)
+		}
+		field := &formFieldSegment{}
+		mark := []rune(text[i:])[0]
+		text = text[i+len(string(mark)):]
+		if mark == fieldOpen {
+			end := strings.IndexRune(text, fieldClose)
+			if end < 0 {
+				end = len(text)
+			}
+			field.blank, field.value = true, text[:end]
+			text = strings.TrimPrefix(text[end:], string(fieldClose))
+		} else {
+			field.selected = mark == fieldSelected
+			text = strings.TrimPrefix(text, " ")
+		}
+		if fill != nil {
+			n := *index
+			field.fill = func(value string) { fill(n, value) }
+		}
+		out = append(out, field)
+		*index++
+	}
+	if out == nil {
+		return []widget.RichTextSegment{seg}
+	}
+	if text != "" || !seg.Style.Inline {
+		seg.Text = text
+		out = append(out, seg)
+	}
+	return out
+}
+
+// radioOption is the one choice of the radio group an option is drawn as;
+// the label of the option follows in the text.
+const radioOption = " "
+
+// formFieldSegment is an option or a blank in the GUI preview. A blank is
+// filled in when Enter is pressed in it.
+type formFieldSegment struct {
+	blank    bool
+	selected bool
+	value    string
+	fill     func(value string)
+}
+
+func (s *formFieldSegment) Inline() bool {
+	return true
+}
+
+func (s *formFieldSegment) Textual() string {
+	switch {
+	case s.blank && s.value == "":
+		return "[______]"
+	case s.blank:
+		return "[" + s.value + "]"
+	case s.selected:
+		return "● "
+	}
+	return "○ "
+}
+
+func (s *formFieldSegment) Visual() fyne.CanvasObject {
+	if s.blank {
+		entry := widget.NewEntry()
+		entry.SetPlaceHolder("fill in")
+		box := container.NewGridWrap(fyne.NewSize(180, entry.MinSize().Height), entry)
+		s.Update(box)
+		return box
+	}
+	radio := widget.NewRadioGroup([]string{radioOption}, nil)
+	radio.Required = true
+	s.Update(radio)
+	return radio
+}
+
+func (s *formFieldSegment) Update(o fyne.CanvasObject) {
+	if box, ok := o.(*fyne.Container); ok {
+		entry := box.Objects[0].(*widget.Entry)
+		entry.OnSubmitted = nil
+		entry.SetText(s.value)
+		if s.fill == nil {
+			entry.Disable()
+			return
+		}
+		entry.Enable()
+		entry.OnSubmitted = func(value string) { s.fill(value) }
+		return
+	}
+	radio := o.(*widget.RadioGroup)
+	// Set the state first so that it does not count as a click
+	radio.OnChanged = nil
+	radio.SetSelected("")
+	if s.selected {
+		radio.SetSelected(radioOption)
+	}
+	if s.fill == nil {
+		radio.Disable()
+		return
+	}
+	radio.Enable()
+	radio.OnChanged = func(string) { s.fill("") }
+}
+
+func (s *formFieldSegment) Select(begin, end fyne.Position) {}
+
+func (s *formFieldSegment) SelectedText() string {
+	return ""
+}
+
+func (s *formFieldSegment) Unselect() {}
+
+// fillField selects option i of the document, or fills in blank i with
+// value.
+func (g *GUIApp) fillField(i int, value string) {
+	content := g.editor.Text
+	fields := FormFields(content)
+	if i >= len(fields) {
+		return
+	}
+	if fields[i].Blank {
+		content = FillBlank(content, fields[i], value)
+	} else {
+		content = SelectOption(content, fields, fields[i])
+	}
+	row, col := g.editor.CursorRow, g.editor.CursorColumn
+	g.editor.SetText(content)
+	g.editor.CursorRow, g.editor.CursorColumn = row, col
+	g.editor.Refresh()
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/charmbracelet/lipgloss"
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatter is the YAML block between --- lines at the very top of a
+// document, as used by static site generators.
+type FrontMatter struct {
+	Title  string
+	Date   string
+	Tags   []string
+	Fields map[string]any
+	// End is the byte offset just after the closing fence line.
+	End int
+
+	source string
+}
+
+// ParseFrontMatter returns the front matter of content, or nil when it has
+// none. A block that is not a YAML mapping is not front matter; a document
+// may well start with a thematic break.
+func ParseFrontMatter(content string) *FrontMatter {
+	first, rest, ok := strings.Cut(content, "\n")
+	if !ok || strings.TrimRight(first, " \t\r") != "---" {
+		return nil
+	}
+
+	offset := len(first) + 1
+	for {
+		line, next, more := strings.Cut(rest, "\n")
+		if fence := strings.TrimRight(line, " \t\r"); fence == "---" || fence == "..." {
+			fm := &FrontMatter{End: offset + len(line)}
+			if more {
+				fm.End++
+			}
+			if !fm.parse(content[len(first)+1 : offset]) {
+				return nil
+			}
+			return fm
+		}
+		if !more {
+			return nil
+		}
+		offset += len(line) + 1
+		rest = next
+	}
+}
+
+func (fm *FrontMatter) parse(yamlText string) bool {
+	if strings.TrimSpace(yamlText) == "" || yaml.Unmarshal([]byte(yamlText), &fm.Fields) != nil {
+		return false
+	}
+	fm.source = yamlText
+	fm.Title = frontMatterString(fm.Fields["title"])
+	fm.Date = frontMatterString(fm.Fields["date"])
+
+	switch tags := fm.Fields["tags"].(type) {
+	case []any:
+		for _, tag := range tags {
+			if s := frontMatterString(tag); s != "" {
+				fm.Tags = append(fm.Tags, s)
+			}
+		}
+	case string:
+		// Comma or space separated
+		for _, tag := range splitNames(tags) {
+			fm.Tags = append(fm.Tags, tag)
+		}
+	}
+	return true
+}
+
+func frontMatterString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04")
+	}
+	return fmt.Sprint(value)
+}
+
+// maskFrontMatter blanks out the front matter while keeping its line breaks,
+// so the markdown parser skips it and positions in the document stay valid.
+func maskFrontMatter(content string) string {
+	fm := ParseFrontMatter(content)
+	if fm == nil {
+		return content
+	}
+	masked := []byte(content)
+	for i := 0; i < fm.End; i++ {
+		if masked[i] != '\n' {
+			masked[i] = ' '
+		}
+	}
+	return string(masked)
+}
+
+// details is the line under the title: the date and the tags.
+func (fm *FrontMatter) details() string {
+	var parts []string
+	if fm.Date != "" {
+		parts = append(parts, fm.Date)
+	}
+	if len(fm.Tags) > 0 {
+		parts = append(parts, "#"+strings.Join(fm.Tags, " #"))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// terminalHeader renders the front matter as a box above the document.
+func (fm *FrontMatter) terminalHeader(width int) []string {
+	var lines []string
+	if fm.Title != "" {
+		lines = append(lines, lipgloss.NewStyle().Bold(true).Foreground(termStrongColor).Render(fm.Title))
+	}
+	if details := fm.details(); details != "" {
+		lines = append(lines, lipgloss.NewStyle().Foreground(termMutedColor).Render(details))
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(termBorderColor).
+		Padding(0, 1).
+		MaxWidth(width).
+		Render(strings.Join(lines, "\n"))
+	return strings.Split(box, "\n")
+}
+
+// fyneHeader renders the front matter as the first segments of the GUI
+// preview.
+func (fm *FrontMatter) fyneHeader() []widget.RichTextSegment {
+	var segments []widget.RichTextSegment
+	if fm.Title != "" {
+		segments = append(segments, &widget.TextSegment{Text: fm.Title, Style: widget.RichTextStyleHeading})
+	}
+	if details := fm.details(); details != "" {
+		style := widget.RichTextStyleParagraph
+		style.TextStyle.Italic = true
+		style.ColorName = theme.ColorNameDisabled
+		segments = append(segments, &widget.TextSegment{Text: details, Style: style})
+	}
+	if len(segments) > 0 {
+		segments = append(segments, &widget.SeparatorSegment{})
+	}
+	return segments
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The width of a drawn timeline in pixels, and the height of each of its
+// rows.
+const (
+	ganttWidth     = 640
+	ganttRowHeight = 22
+)
+
+// ganttCriticalColor is the color of tasks tagged crit.
+const ganttCriticalColor = "#E76F51"
+
+var (
+	ganttDurationRe = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([hdw])$`)
+	timelineLineRe  = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})(?:\s*(?:-|–|\.\.)\s*(\d{4}-\d{2}-\d{2}))?\s*:?\s+(\S.*)$`)
+	ganttDateFormat = strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02", "HH", "15", "mm", "04", "ss", "05")
+)
+
+// GanttTask is a task of a timeline, or a milestone when it has no length.
+type GanttTask struct {
+	Name                      string
+	Start, End                time.Time
+	Milestone, Done, Critical bool
+}
+
+// GanttSection is a named group of tasks.
+type GanttSection struct {
+	Name  string
+	Tasks []GanttTask
+}
+
+// Gantt is the content of a gantt or timeline block.
+type Gantt struct {
+	Title    string
+	Sections []GanttSection
+}
+
+// ganttSyntax tells whether a block of lang is a timeline, and whether it
+// is written in the native timeline syntax rather than mermaid's gantt
+// syntax. A mermaid block is a timeline when it starts with "gantt".
+func ganttSyntax(lang, code string) (native, ok bool) {
+	switch lang {
+	case "timeline":
+		return true, true
+	case "gantt":
+		return false, true
+	case "mermaid":
+		first, _, _ := strings.Cut(strings.TrimSpace(code), "\n")
+		return false, strings.TrimSpace(first) == "gantt"
+	}
+	return false, false
+}
+
+// ParseGantt reads a timeline block: mermaid's gantt syntax, or with native
+// the native one of a date, or two for a span, and an event on each line:
+//
+//	title Release plan
+//	section Design
+//	2025-01-06 - 2025-01-17 Mockups
+//	2025-01-20 Review
+//
+// Both have title and section lines. Native spans take in their last day.
+func ParseGantt(code string, native bool) (*Gantt, error) {
+	g := &Gantt{}
+	layout := "2006-01-02"
+	byID := map[string]GanttTask{}
+	var last *GanttTask
+	for n, line := range strings.Split(code, "\n") {
+		line = strings.TrimSpace(line)
+		keyword, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+		switch {
+		case line == "" || strings.HasPrefix(line, "%%") || line == "gantt":
+			continue
+		case keyword == "title":
+			g.Title = rest
+			continue
+		case keyword == "section":
+			g.Sections = append(g.Sections, GanttSection{Name: rest})
+			continue
+		case !native && keyword == "dateFormat":
+			layout = ganttDateFormat.Replace(rest)
+			continue
+		case !native && (keyword == "axisFormat" || keyword == "excludes" || keyword == "includes" || keyword == "todayMarker" ||
+			keyword == "tickInterval" || keyword == "weekday" || keyword == "inclusiveEndDates" || keyword == "topAxis" || keyword == "displayMode"):
+			// Settings of mermaid's own drawing
+			continue
+		}
+
+		var task GanttTask
+		var err error
+		if native {
+			task, err = parseTimelineEvent(line)
+		} else {
+			task, err = parseGanttTask(line, layout, byID, last)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+		if task.End.Before(task.Start) {
+			return nil, fmt.Errorf("line %d: %s ends before it starts", n+1, task.Name)
+		}
+		if len(g.Sections) == 0 {
+			g.Sections = append(g.Sections, GanttSection{})
+		}
+		section := &g.Sections[len(g.Sections)-1]
+		section.Tasks = append(section.Tasks, task)
+		last = &section.Tasks[len(section.Tasks)-1]
+	}
+
+	for _, section := range g.Sections {
+		if len(section.Tasks) > 0 {
+			return g, nil
+		}
+	}
+	return nil, fmt.Errorf("the timeline has no tasks")
+}
+
+// parseTimelineEvent reads a line of the native syntax.
+func parseTimelineEvent(line string) (GanttTask, error) {
+	m := timelineLineRe.FindStringSubmatch(line)
+	if m == nil {
+		return GanttTask{}, fmt.Errorf("%q is not a date and an event", line)
+	}
+	start, err := time.Parse("2006-01-02", m[1])
+	if err != nil {
+		return GanttTask{}, fmt.Errorf("%q is not a date", m[1])
+	}
+	task := GanttTask{Name: m[3], Start: start, End: start, Milestone: m[2] == ""}
+	if m[2] != "" {
+		end, err := time.Parse("2006-01-02", m[2])
+		if err != nil {
+			return GanttTask{}, fmt.Errorf("%q is not a date", m[2])
+		}
+		task.End = end.AddDate(0, 0, 1)
+	}
+	return task, nil
+}
+
+// parseGanttTask reads a task of mermaid's gantt syntax: a name and, after a
+// colon, tags, an id, a start and an end or length, with only the last
+// required. Without a start a task follows the one before it.
+func parseGanttTask(line, layout string, byID map[string]GanttTask, last *GanttTask) (GanttTask, error) {
+	name, meta, ok := strings.Cut(line, ":")
+	if !ok {
+		return GanttTask{}, fmt.Errorf("%q is not a task", line)
+	}
+	task := GanttTask{Name: strings.TrimSpace(name)}
+	var items []string
+	for _, item := range strings.Split(meta, ",") {
+		items = append(items, strings.TrimSpace(item))
+	}
+tags:
+	for len(items) > 1 {
+		switch items[0] {
+		case "done":
+			task.Done = true
+		case "active":
+			// Drawn like any task that is not done
+		case "crit":
+			task.Critical = true
+		case "milestone":
+			task.Milestone = true
+		default:
+			break tags
+		}
+		items = items[1:]
+	}
+	id := ""
+	if len(items) == 3 {
+		id, items = items[0], items[1:]
+	}
+
+	var err error
+	switch {
+	case len(items) == 2:
+		if task.Start, err = ganttStart(items[0], layout, byID); err != nil {
+			return GanttTask{}, err
+		}
+	case len(items) == 1 && last != nil:
+		task.Start = last.End
+	case len(items) == 1:
+		return GanttTask{}, fmt.Errorf("%s needs a start", task.Name)
+	default:
+		return GanttTask{}, fmt.Errorf("%q is not a task", line)
+	}
+	if task.End, err = ganttEnd(items[len(items)-1], task.Start, layout, byID); err != nil {
+		return GanttTask{}, err
+	}
+	if task.Milestone {
+		task.End = task.Start
+	}
+	if id != "" {
+		byID[id] = task
+	}
+	return task, nil
+}
+
+// ganttStart reads the start of a task: a date, or "after" and the ids of
+// the tasks it waits for.
+func ganttStart(text, layout string, byID map[string]GanttTask) (time.Time, error) {
+	ids, ok := strings.CutPrefix(text, "after ")
+	if !ok {
+		return ganttDate(text, layout)
+	}
+	var start time.Time
+	for _, id := range strings.Fields(ids) {
+		task, ok := byID[id]
+		if !ok {
+			return time.Time{}, fmt.Errorf("no task has the id %s", id)
+		}
+		if task.End.After(start) {
+			start = task.End
+		}
+	}
+	return start, nil
+}
+
+// ganttEnd reads the end of a task: a date, a length such as 5d, 2w or 4h,
+// or "until" and the id of the task it ends at the start of.
+func ganttEnd(text string, start time.Time, layout string, byID map[string]GanttTask) (time.Time, error) {
+	if id, ok := strings.CutPrefix(text, "until "); ok {
+		task, ok := byID[strings.TrimSpace(id)]
+		if !ok {
+			return time.Time{}, fmt.Errorf("no task has the id %s", strings.TrimSpace(id))
+		}
+		return task.Start, nil
+	}
+	if m := ganttDurationRe.FindStringSubmatch(text); m != nil {
+		n, _ := strconv.ParseFloat(m[1], 64)
+		unit := map[string]time.Duration{"h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour}[m[2]]
+		return start.Add(time.Duration(n * float64(unit))), nil
+	}
+	return ganttDate(text, layout)
+}
+
+func ganttDate(text, layout string) (time.Time, error) {
+	t, err := time.Parse(layout, text)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date like %s", text, layout)
+	}
+	return t, nil
+}
+
+// span is the time from the first start to the last end, at least a day.
+func (g *Gantt) span() (time.Time, time.Time) {
+	var lo, hi time.Time
+	for _, section := range g.Sections {
+		for _, task := range section.Tasks {
+			if lo.IsZero() || task.Start.Before(lo) {
+				lo = task.Start
+			}
+			if task.End.After(hi) {
+				hi = task.End
+			}
+		}
+	}
+	if !hi.After(lo) {
+		hi = lo.AddDate(0, 0, 1)
+	}
+	return lo, hi
+}
+
+// ganttTicks are the dates on the axis of a timeline from lo to hi: days,
+// weeks, months, quarters or years, whichever gives at most most of them.
+func ganttTicks(lo, hi time.Time, most int) ([]time.Time, []string) {
+	days := hi.Sub(lo).Hours() / 24
+	type unit struct {
+		days   float64
+		start  func(time.Time) time.Time
+		next   func(time.Time) time.Time
+		format string
+	}
+	midnight := func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()) }
+	month := func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()) }
+	units := []unit{
+		{1, midnight, func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }, "Jan 2"},
+		{7, func(t time.Time) time.Time {
+			return midnight(t).AddDate(0, 0, -(int(t.Weekday())+6)%7)
+		}, func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }, "Jan 2"},
+		{30, month, func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }, "Jan 2006"},
+		{91, func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, t.Location())
+		}, func(t time.Time) time.Time { return t.AddDate(0, 3, 0) }, "Jan 2006"},
+		{365, func(t time.Time) time.Time {
+			return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+		}, func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }, "2006"},
+	}
+	u := units[len(units)-1]
+	for _, candidate := range units {
+		if days/candidate.days <= float64(most) {
+			u = candidate
+			break
+		}
+	}
+	var ticks []time.Time
+	var labels []string
+	for t := u.start(lo); !t.After(hi); t = u.next(t) {
+		if t.Before(lo) {
+			continue
+		}
+		ticks = append(ticks, t)
+		labels = append(labels, t.Format(u.format))
+	}
+	return ticks, labels
+}
+
+// layout places the bars, milestones and labels of the timeline.
+func (g *Gantt) layout() chartDrawing {
+	var marks []chartMark
+	var labels []chartLabel
+	top := 16.0
+	if g.Title != "" {
+		labels = append(labels, chartLabel{at: diagramPoint{ganttWidth / 2, 22}, text: g.Title})
+		top = 40
+	}
+	rows, nameChars := 0, 0
+	for _, section := range g.Sections {
+		if section.Name != "" {
+			rows++
+			nameChars = max(nameChars, len([]rune(section.Name)))
+		}
+		for _, task := range section.Tasks {
+			rows++
+			nameChars = max(nameChars, len([]rune(task.Name))+2)
+		}
+	}
+	left := min(200, float64(nameChars*chartCharWidth+24))
+	right := ganttWidth - 16.0
+	bottom := top + float64(rows*ganttRowHeight)
+	height := int(bottom) + 28
+
+	lo, hi := g.span()
+	x := func(t time.Time) float64 {
+		return left + t.Sub(lo).Hours()/hi.Sub(lo).Hours()*(right-left)
+	}
+	ticks, tickLabels := ganttTicks(lo, hi, int((right-left)/64))
+	for i, tick := range ticks {
+		tx := x(tick)
+		marks = append(marks, chartMark{points: []diagramPoint{{tx, top}, {tx, bottom}}, width: 1, faint: true})
+		labels = append(labels, chartLabel{at: diagramPoint{tx, bottom + 18}, text: tickLabels[i]})
+	}
+	marks = append(marks, chartMark{points: []diagramPoint{{left, bottom}, {right, bottom}}, width: 1})
+
+	y := top
+	maxChars := int((left - 24) / chartCharWidth)
+	for i, section := range g.Sections {
+		color := chartColors[i%len(chartColors)]
+		indent := 8.0
+		if section.Name != "" {
+			marks = append(marks, chartMark{points: chartRect(8, y+6, 18, y+16), color: color})
+			labels = append(labels, chartLabel{at: diagramPoint{24, y + 15}, text: truncate(section.Name, maxChars), align: -1})
+			y += ganttRowHeight
+			indent = 24
+		}
+		for _, task := range section.Tasks {
+			labels = append(labels, chartLabel{at: diagramPoint{indent, y + 15}, text: truncate(task.Name, maxChars), align: -1})
+			mark := chartMark{color: color, faint: task.Done}
+			if task.Critical {
+				mark.color = ganttCriticalColor
+			}
+			if task.Milestone || !task.End.After(task.Start) {
+				cx, cy := x(task.Start), y+11
+				mark.points = []diagramPoint{{cx, cy - 7}, {cx + 7, cy}, {cx, cy + 7}, {cx - 7, cy}}
+			} else {
+				mark.points = chartRect(x(task.Start), y+4, max(x(task.End), x(task.Start)+2), y+18)
+			}
+			marks = append(marks, mark)
+			y += ganttRowHeight
+		}
+	}
+	return chartDrawing{width: ganttWidth, height: height, title: g.Title, marks: marks, labels: labels}
+}
+
+// SVG draws the timeline, with its text and axis in the color of the
+// surrounding text.
+func (g *Gantt) SVG() string {
+	return g.layout().SVG("timeline")
+}
+
+// Image draws the timeline on a transparent background, with its text and
+// axis in fg.
+func (g *Gantt) Image(fg color.Color) *image.RGBA {
+	return g.layout().Image(fg)
+}
+
+// htmlGanttRe finds the blocks of exported HTML that may be timelines.
+var htmlGanttRe = regexp.MustCompile(`(?s)<pre><code class="language-(gantt|timeline|mermaid)">(.*?)</code></pre>`)
+
+// ganttHTML replaces the timeline blocks of body with SVG drawings. Blocks
+// that are not a timeline stay as they are.
+func ganttHTML(body string) string {
+	return htmlGanttRe.ReplaceAllStringFunc(body, func(match string) string {
+		parts := htmlGanttRe.FindStringSubmatch(match)
+		code := html.UnescapeString(parts[2])
+		native, ok := ganttSyntax(parts[1], code)
+		if !ok {
+			return match
+		}
+		g, err := ParseGantt(code, native)
+		if err != nil {
+			return match
+		}
+		return "<p>" + g.SVG() + "</p>"
+	})
+}
+
+// Terminal draws the timeline as text: the name of each task next to its
+// bar, on a shared axis of dates.
+func (g *Gantt) Terminal(width int) ([]string, error) {
+	nameChars := 0
+	for _, section := range g.Sections {
+		nameChars = max(nameChars, len([]rune(section.Name)))
+		for _, task := range section.Tasks {
+			nameChars = max(nameChars, len([]rune(task.Name))+2)
+		}
+	}
+	nameWidth := min(nameChars+2, width/3)
+	room := width - nameWidth
+	if room < 10 {
+		return nil, fmt.Errorf("the preview is too narrow")
+	}
+	lo, hi := g.span()
+	col := func(t time.Time) int {
+		return int(math.Round(t.Sub(lo).Hours() / hi.Sub(lo).Hours() * float64(room-1)))
+	}
+	muted := lipgloss.NewStyle().Foreground(termMutedColor)
+
+	var lines []string
+	if g.Title != "" {
+		lines = append(lines, lipgloss.NewStyle().Bold(true).Foreground(termStrongColor).Render(truncate(g.Title, width)))
+	}
+	axis := []rune(strings.Repeat(" ", room))
+	ticks, tickLabels := ganttTicks(lo, hi, room/8)
+	next := 0
+	for i, tick := range ticks {
+		c := col(tick)
+		label := []rune(tickLabels[i])
+		if c < next || c+len(label) > room {
+			continue
+		}
+		copy(axis[c:], label)
+		next = c + len(label) + 1
+	}
+	lines = append(lines, strings.Repeat(" ", nameWidth)+muted.Render(string(axis)))
+
+	bar, milestone := "█", "◆"
+	if !termCaps.Unicode {
+		bar, milestone = "#", "*"
+	}
+	for i, section := range g.Sections {
+		style := lipgloss.NewStyle().Foreground(lipgloss.Color(chartColors[i%len(chartColors)]))
+		indent := ""
+		if section.Name != "" {
+			lines = append(lines, style.Bold(true).Render(truncate(section.Name, width)))
+			indent = "  "
+		}
+		for _, task := range section.Tasks {
+			name := fmt.Sprintf("%-*s", nameWidth, truncate(indent+task.Name, nameWidth-1))
+			taskStyle := style
+			switch {
+			case task.Critical:
+				taskStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(ganttCriticalColor))
+			case task.Done:
+				taskStyle = muted
+			}
+			start := col(task.Start)
+			mark := milestone
+			if !task.Milestone && task.End.After(task.Start) {
+				mark = strings.Repeat(bar, max(1, col(task.End)-start))
+			}
+			lines = append(lines, name+strings.Repeat(" ", start)+taskStyle.Render(mark))
+		}
+	}
+	return lines, nil
+}
+
+// ganttBlock shows a timeline block in the terminal preview, or the block
+// with what is wrong with it when it is not a timeline.
+func (r *terminalRenderer) ganttBlock(code, info string, native bool, width int) []string {
+	g, err := ParseGantt(code, native)
+	var lines []string
+	if err == nil {
+		lines, err = g.Terminal(width)
+	}
+	if err != nil {
+		note := lipgloss.NewStyle().Foreground(termMutedColor).Render("Timeline: " + err.Error())
+		return append(r.codeBlock(code, info, width), note)
+	}
+	return lines
+}
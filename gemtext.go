@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+type gemLink struct {
+	url   string
+	label string
+}
+
+type gemtextWriter struct {
+	smp    *SharedMarkdownProcessor
+	source []byte
+	lines  []string
+}
+
+// ExportGemtext converts markdown to gemtext. Gemtext has no inline links, so
+// links found in a block are listed as "=>" lines right after it.
+func ExportGemtext(content string, opts ExportOptions) ([]byte, error) {
+	smp := opts.processor()
+	doc, source := smp.Parse(content)
+
+	w := &gemtextWriter{smp: smp, source: source}
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		w.block(n, 0)
+	}
+
+	out := strings.Join(collapseBlankLines(w.lines), "\n")
+	return []byte(strings.TrimSpace(out) + "\n"), nil
+}
+
+func (w *gemtextWriter) blank() {
+	if len(w.lines) > 0 && w.lines[len(w.lines)-1] != "" {
+		w.lines = append(w.lines, "")
+	}
+}
+
+func (w *gemtextWriter) links(links []gemLink) {
+	for _, link := range links {
+		if link.label != "" && link.label != link.url {
+			w.lines = append(w.lines, fmt.Sprintf("=> %s %s", link.url, link.label))
+		} else {
+			w.lines = append(w.lines, "=> "+link.url)
+		}
+	}
+}
+
+func (w *gemtextWriter) block(n ast.Node, depth int) {
+	switch node := n.(type) {
+	case *ast.Heading:
+		level := min(node.Level, 3)
+		text, links := w.inline(node)
+		w.blank()
+		w.lines = append(w.lines, strings.Repeat("#", level)+" "+text)
+		w.links(links)
+		w.blank()
+
+	case *ast.Paragraph, *ast.TextBlock:
+		text, links := w.inline(node)
+		// A paragraph consisting only of a link becomes just the link line
+		if len(links) == 1 && (text == links[0].label || text == links[0].url) {
+			w.links(links)
+		} else if text != "" {
+			w.lines = append(w.lines, text)
+			w.links(links)
+		}
+		if _, ok := node.(*ast.Paragraph); ok {
+			w.blank()
+		}
+
+	case *ast.List:
+		number := node.Start
+		for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+			prefix := "* "
+			if node.IsOrdered() {
+				prefix = fmt.Sprintf("* %d. ", number)
+				number++
+			}
+			prefix += strings.Repeat("  ", depth)
+			w.listItem(item, prefix, depth)
+		}
+		if depth == 0 {
+			w.blank()
+		}
+
+	case *ast.FencedCodeBlock:
+		w.lines = append(w.lines, "```"+string(node.Language(w.source)))
+		w.lines = append(w.lines, w.smp.CodeBlockText(node, w.source))
+		w.lines = append(w.lines, "```")
+		w.blank()
+
+	case *ast.CodeBlock:
+		w.lines = append(w.lines, "```")
+		w.lines = append(w.lines, w.smp.CodeBlockText(node, w.source))
+		w.lines = append(w.lines, "```")
+		w.blank()
+
+	case *ast.Blockquote:
+		for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+			text, links := w.inline(child)
+			if text != "" {
+				w.lines = append(w.lines, "> "+text)
+			}
+			w.links(links)
+		}
+		w.blank()
+
+	case *ast.ThematicBreak:
+		w.lines = append(w.lines, strings.Repeat("─", 20))
+		w.blank()
+
+	case *east.Table:
+		w.lines = append(w.lines, "```table")
+		w.lines = append(w.lines, w.table(node)...)
+		w.lines = append(w.lines, "```")
+		w.blank()
+	}
+}
+
+func (w *gemtextWriter) listItem(item ast.Node, prefix string, depth int) {
+	first := true
+	for child := item.FirstChild(); child != nil; child = child.NextSibling() {
+		if list, ok := child.(*ast.List); ok {
+			w.block(list, depth+1)
+			continue
+		}
+		text, links := w.inline(child)
+		if first {
+			text = prefix + taskPrefix(child) + text
+			first = false
+		} else {
+			text = "* " + strings.Repeat("  ", depth) + text
+		}
+		w.lines = append(w.lines, text)
+		w.links(links)
+	}
+}
+
+func taskPrefix(n ast.Node) string {
+	if checkbox, ok := n.FirstChild().(*east.TaskCheckBox); ok {
+		if checkbox.IsChecked {
+			return "[x] "
+		}
+		return "[ ] "
+	}
+	return ""
+}
+
+func (w *gemtextWriter) inline(n ast.Node) (string, []gemLink) {
+	var links []gemLink
+	var buf strings.Builder
+
+	ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := child.(type) {
+		case *ast.Link:
+			label := w.smp.PlainText(node, w.source)
+			buf.WriteString(label)
+			links = append(links, gemLink{url: string(node.Destination), label: label})
+			return ast.WalkSkipChildren, nil
+		case *ast.Image:
+			label := w.smp.PlainText(node, w.source)
+			if label == "" {
+				label = "image"
+			}
+			links = append(links, gemLink{url: string(node.Destination), label: label})
+			return ast.WalkSkipChildren, nil
+		case *ast.AutoLink:
+			url := string(node.URL(w.source))
+			buf.Write(node.Label(w.source))
+			links = append(links, gemLink{url: url, label: url})
+		case *ast.CodeSpan:
+			buf.WriteString(w.smp.PlainText(node, w.source))
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			buf.Write(node.Segment.Value(w.source))
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				buf.WriteString(" ")
+			}
+		case *ast.String:
+			buf.Write(node.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return strings.TrimSpace(buf.String()), links
+}
+
+func (w *gemtextWriter) table(table *east.Table) []string {
+	var rows [][]string
+	for row := table.FirstChild(); row != nil; row = row.NextSibling() {
+		var cells []string
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			cells = append(cells, w.smp.PlainText(cell, w.source))
+		}
+		rows = append(rows, cells)
+	}
+	return formatTextTable(rows)
+}
+
+// formatTextTable aligns rows into columns, underlining the header row.
+func formatTextTable(rows [][]string) []string {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], len([]rune(cell)))
+		}
+	}
+
+	var lines []string
+	for r, row := range rows {
+		var cells []string
+		for i, cell := range row {
+			cells = append(cells, cell+strings.Repeat(" ", widths[i]-len([]rune(cell))))
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(cells, " | "), " "))
+		if r == 0 {
+			var rules []string
+			for _, width := range widths {
+				rules = append(rules, strings.Repeat("-", width))
+			}
+			lines = append(lines, strings.Join(rules, "-+-"))
+		}
+	}
+	return lines
+}
+
+func collapseBlankLines(lines []string) []string {
+	var result []string
+	for i, line := range lines {
+		if line == "" && i > 0 && lines[i-1] == "" {
+			continue
+		}
+		result = append(result, line)
+	}
+	return result
+}
@@ -0,0 +1,99 @@
+package main
+
+import "fmt"
+
+// GlyphConfig is the [glyphs] table: the marks the terminal preview draws
+// for list items, tasks, quotes and headings. Set names the preset to start
+// from, "unicode", "minimal" or "ascii"; empty or "auto" is unicode, or
+// ascii on a terminal without Unicode. The other fields replace the marks
+// of the preset: Bullets by list depth, Tasks unchecked and checked,
+// Headings by level from 1 to 4 and TitleEnd after a level 1 heading.
+type GlyphConfig struct {
+	Set      string   `toml:"set"`
+	Bullets  []string `toml:"bullets"`
+	Tasks    []string `toml:"tasks"`
+	Quote    string   `toml:"quote"`
+	Headings []string `toml:"headings"`
+	TitleEnd string   `toml:"title_end"`
+}
+
+var glyphSets = map[string]GlyphConfig{
+	"unicode": {
+		Bullets:  []string{"•", "▪", "◦"},
+		Tasks:    []string{"☐", "☑"},
+		Quote:    "❝ ",
+		Headings: []string{"▶ ", "▶▶ ", "▶▶▶ ", "◦ "},
+		TitleEnd: " ◀",
+	},
+	"minimal": {
+		Bullets:  []string{"·"},
+		Tasks:    []string{"○", "●"},
+		Headings: []string{"", "", "", ""},
+	},
+	"ascii": {
+		Bullets:  []string{"*", "-", "o"},
+		Tasks:    []string{"[ ]", "[x]"},
+		Quote:    "\" ",
+		Headings: []string{"> ", ">> ", ">>> ", "o "},
+		TitleEnd: " <",
+	},
+}
+
+// termGlyphs are the marks the terminal preview was set up with.
+var termGlyphs = glyphSets["unicode"]
+
+// GlyphSet starts from the preset of [glyphs], or the one that suits caps,
+// and applies the overrides of the table on top.
+func (c *Config) GlyphSet(caps TermCaps) (GlyphConfig, error) {
+	name := c.Glyphs.Set
+	if name == "" || name == "auto" {
+		name = "unicode"
+		if !caps.Unicode {
+			name = "ascii"
+		}
+	}
+	glyphs, ok := glyphSets[name]
+	if !ok {
+		return glyphSets["unicode"], fmt.Errorf("unknown glyph set %q (available: auto, unicode, minimal, ascii)", c.Glyphs.Set)
+	}
+
+	if len(c.Glyphs.Bullets) > 0 {
+		glyphs.Bullets = c.Glyphs.Bullets
+	}
+	switch len(c.Glyphs.Tasks) {
+	case 0:
+	case 2:
+		glyphs.Tasks = c.Glyphs.Tasks
+	default:
+		return glyphs, fmt.Errorf("tasks under [glyphs] takes two marks, unchecked and checked, not %d", len(c.Glyphs.Tasks))
+	}
+	if c.Glyphs.Quote != "" {
+		glyphs.Quote = c.Glyphs.Quote
+	}
+	if len(c.Glyphs.Headings) > 4 {
+		return glyphs, fmt.Errorf("headings under [glyphs] takes up to four marks, one per level, not %d", len(c.Glyphs.Headings))
+	}
+	headings := append([]string(nil), glyphs.Headings...)
+	copy(headings, c.Glyphs.Headings)
+	glyphs.Headings = headings
+	if c.Glyphs.TitleEnd != "" {
+		glyphs.TitleEnd = c.Glyphs.TitleEnd
+	}
+	return glyphs, nil
+}
+
+// applyGlyphs sets the terminal preview up to draw g.
+func applyGlyphs(g GlyphConfig) {
+	termGlyphs = g
+	taskBoxes = [2]string{g.Tasks[0], g.Tasks[1]}
+}
+
+// bullet is the mark of a list item depth levels deep.
+func (g GlyphConfig) bullet(depth int) string {
+	return g.Bullets[min(depth, len(g.Bullets)-1)]
+}
+
+// heading is the mark before a heading of level.
+func (g GlyphConfig) heading(level int) string {
+	return g.Headings[min(level, len(g.Headings))-1]
+}
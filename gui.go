@@ -1,37 +1,135 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"io"
+	"image/color"
+	"log"
 	"os"
 	"path/filepath"
-	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 )
 
+// DocumentStore reads and writes the documents the GUI opens and saves.
+// The app uses the disk; a test can give it one held in memory.
+type DocumentStore interface {
+	ReadDocument(path string) ([]byte, error)
+	WriteDocument(path string, content []byte) error
+}
+
+// diskDocuments is the DocumentStore of the files on disk.
+type diskDocuments struct{}
+
+func (diskDocuments) ReadDocument(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (diskDocuments) WriteDocument(path string, content []byte) error {
+	return writeDocument(path, string(content))
+}
+
 type GUIApp struct {
-	app         fyne.App
-	window      fyne.Window
-	editor      *widget.Entry
-	preview     *widget.RichText
-	currentFile string
-	fileLabel   *widget.Label
-	splitPanel  *container.Split
+	app             fyne.App
+	docs            DocumentStore
+	window          fyne.Window
+	editor          *widget.Entry
+	editorPane      *fyne.Container
+	twin            *widget.Entry
+	twinSyncing     bool
+	preview         *widget.RichText
+	peekPopUp       *widget.PopUp
+	previewScroll   *container.Scroll
+	outline         *widget.Tree
+	outlinePanel    fyne.CanvasObject
+	headings        []OutlineHeading
+	targets         []SectionTarget
+	outlineNodes    map[string][]string
+	currentFile     string
+	savedText       string
+	fileLabel       *widget.Label
+	statsButton     *widget.Button
+	crumbButton     *widget.Button
+	crumbHeadings   []OutlineHeading
+	outlineItem     *fyne.MenuItem
+	splitEditorItem *fyne.MenuItem
+	narrowItem      *fyne.MenuItem
+	optimizeItem    *fyne.MenuItem
+	docMap          *docMapStrip
+	docMapItem      *fyne.MenuItem
+	mapMarks        []MapMark
+	scratch         *widget.Entry
+	scratchPanel    *fyne.Container
+	scratchItem     *fyne.MenuItem
+	previewPanel    fyne.CanvasObject
+	sourcePanel     *fyne.Container
+	sourceText      *widget.Entry
+	sourceLabel     *widget.Label
+	source          *Source
+	sourcePage      int
+	statsTimer      *time.Timer
+	previewTimer    *time.Timer
+	splitPanel      *container.Split
+
+	// previewAnchors map source lines to preview segments, previewPixels
+	// to pixel offsets at previewPixelWidth. previewLine is the source line
+	// to show at the top once the preview is rendered, or -1.
+	previewAnchors    ScrollMap
+	previewPixels     ScrollMap
+	previewPixelWidth float32
+	previewLine       int
+	scrollSyncing     bool
+	// followedSection is the section of the cursor the preview followed,
+	// previewFlash lights up its heading
+	followedSection int
+	followAnimation *fyne.Animation
+	flashAnimation  *fyne.Animation
+	previewFlash    *canvas.Rectangle
 
 	model       model
 	mdProcessor *SharedMarkdownProcessor
+	imageOpts   ImageOptions
+	linter      *Linter
+	config      *Config
+
+	watcher      *FileWatcher
+	server       *PreviewServer
+	watchedFile  string
+	reloadDialog dialog.Dialog
+	selections   []TextRange
+	history      *History
+	lockedFile   string
+	readOnly     bool
+	reviewed     *string
+	narrow       *Narrowing
+	jumps        JumpList
+	clips        []string
+	listening    *Dictation
+	dictateItem  *fyne.MenuItem
+	// files are the files named on the command line
+	files []string
 }
 
 func NewGUIApp() *GUIApp {
-	myApp := app.NewWithID("com.parselt.editor")
+	return newGUIAppWith(app.NewWithID("com.parselt.editor"), diskDocuments{})
+}
 
+// newGUIAppWith builds the GUI on myApp, which may be a test app, with its
+// documents in docs.
+func newGUIAppWith(myApp fyne.App, docs DocumentStore) *GUIApp {
 	myApp.SetIcon(resourceParseltIconPng)
 
 	myWindow := myApp.NewWindow("Parselt - Markdown Editor")
@@ -42,22 +140,40 @@ func NewGUIApp() *GUIApp {
 
 	m := initialModel("")
 
-	return &GUIApp{
+	g := &GUIApp{
 		app:         myApp,
+		docs:        docs,
 		window:      myWindow,
 		model:       m,
-		mdProcessor: NewSharedMarkdownProcessor(),
+		history:     NewHistory(""),
+		previewLine: -1,
 	}
+	g.loadConfig()
+	myApp.Settings().SetTheme(newSyntaxTheme(g.config.Theme))
+	return g
 }
 
-func (g *GUIApp) setupUI() {
-	g.editor = widget.NewMultiLineEntry()
-	g.editor.Wrapping = fyne.TextWrapWord
-	g.editor.SetPlaceHolder("Start writing your markdown...")
-
-	g.editor.TextStyle = fyne.TextStyle{
-		Monospace: true,
+// loadConfig picks up the config that applies to the current file, including
+// any .parselt.toml in its project directory.
+func (g *GUIApp) loadConfig() {
+	cfg, err := LoadConfigFor(g.currentFile)
+	if err != nil {
+		log.Printf("Error loading config: %v", err)
 	}
+	if err := cfg.CheckCodeSchemes(); err != nil {
+		log.Printf("Error in config: %v", err)
+	}
+	g.config = cfg
+	g.mdProcessor = cfg.Processor()
+	g.linter = cfg.Linter()
+	g.imageOpts = cfg.Images
+	if g.optimizeItem != nil {
+		g.optimizeItem.Checked = g.imageOpts.Optimize
+	}
+}
+
+func (g *GUIApp) setupUI() {
+	g.editor = newEditorEntry()
 
 	g.preview = widget.NewRichText()
 	g.preview.Wrapping = fyne.TextWrapWord
@@ -66,24 +182,49 @@ func (g *GUIApp) setupUI() {
 	g.fileLabel.TextStyle = fyne.TextStyle{
 		Bold: true,
 	}
+	g.statsButton = widget.NewButton("", g.showStats)
+	g.statsButton.Importance = widget.LowImportance
+	g.crumbButton = widget.NewButton("", g.showHeadingInOutline)
+	g.crumbButton.Importance = widget.LowImportance
+	g.crumbButton.Hide()
+	g.docMap = newDocMapStrip(g.mapJump)
+	if !g.config.HasPanel("minimap") {
+		g.docMap.Hide()
+	}
+	g.updateStats()
 
+	g.editorPane = container.NewStack(container.NewScroll(g.editor))
+	g.setupScratch()
 	editorContainer := container.NewBorder(
-		widget.NewCard("Editor", "", nil), nil, nil, nil,
-		container.NewScroll(g.editor),
+		container.NewVBox(widget.NewCard("Editor", "", nil), g.formatToolbar()), g.scratchPanel, nil, g.docMap,
+		g.editorPane,
 	)
 
-	previewContainer := container.NewBorder(
+	g.previewFlash = canvas.NewRectangle(color.Transparent)
+	g.previewFlash.Hide()
+	g.previewScroll = container.NewScroll(container.NewStack(newTapArea(g.preview, g.showSource), container.NewWithoutLayout(g.previewFlash)))
+	g.previewPanel = container.NewBorder(
 		widget.NewCard("Preview", "", nil), nil, nil, nil,
-		container.NewScroll(g.preview),
+		g.previewScroll,
 	)
 
-	g.splitPanel = container.NewHSplit(editorContainer, previewContainer)
-	g.splitPanel.SetOffset(0.5)
+	g.setupOutline()
+	g.setupSource()
+
+	g.splitPanel = container.NewHSplit(editorContainer, g.previewPanel)
+	switch {
+	case !g.config.HasPanel("preview"):
+		g.splitPanel.SetOffset(1.0)
+	case !g.config.HasPanel("editor"):
+		g.splitPanel.SetOffset(0.0)
+	default:
+		g.splitPanel.SetOffset(0.5)
+	}
 
 	content := container.NewBorder(
 		nil,
-		g.fileLabel,
-		nil,
+		container.NewBorder(nil, nil, nil, container.NewHBox(g.crumbButton, g.statsButton), g.fileLabel),
+		g.outlinePanel,
 		nil,
 		g.splitPanel,
 	)
@@ -93,6 +234,58 @@ func (g *GUIApp) setupUI() {
 	g.setupMenu()
 }
 
+// setupOutline builds the heading tree shown left of the editor. It starts
+// hidden and is toggled from the View menu.
+func (g *GUIApp) setupOutline() {
+	g.outline = widget.NewTree(
+		func(id widget.TreeNodeID) []widget.TreeNodeID { return g.outlineNodes[id] },
+		func(id widget.TreeNodeID) bool { return len(g.outlineNodes[id]) > 0 },
+		func(bool) fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.TreeNodeID, _ bool, obj fyne.CanvasObject) {
+			if i, err := strconv.Atoi(id); err == nil && i < len(g.headings) {
+				text := g.headings[i].Text
+				if progress := targetProgress(g.targets, i); progress != "" {
+					text += "  " + progress
+				}
+				obj.(*widget.Label).SetText(text)
+			}
+		},
+	)
+	g.outline.OnSelected = func(id widget.TreeNodeID) {
+		if i, err := strconv.Atoi(id); err == nil && i < len(g.headings) {
+			g.jumpToHeading(g.headings[i])
+		}
+		g.outline.UnselectAll()
+	}
+
+	width := canvas.NewRectangle(color.Transparent)
+	width.SetMinSize(fyne.NewSize(220, 0))
+	g.outlinePanel = container.NewBorder(
+		widget.NewCard("Outline", "", nil), nil, nil, nil,
+		container.NewStack(width, g.outline),
+	)
+	g.outlinePanel.Hide()
+}
+
+func (g *GUIApp) refreshOutline() {
+	g.headings = g.mdProcessor.Outline(g.editor.Text)
+	g.targets = g.mdProcessor.SectionTargets(g.editor.Text, g.headings)
+	g.outlineNodes = outlineTree(g.headings)
+	g.outline.Refresh()
+	g.outline.OpenAllBranches()
+}
+
+// jumpToHeading puts the editor cursor on the heading and scrolls the preview
+// to where it was rendered.
+func (g *GUIApp) jumpToHeading(heading OutlineHeading) {
+	g.pushJump()
+	g.editor.CursorRow = heading.Line
+	g.editor.CursorColumn = 0
+	g.editor.Refresh()
+	g.window.Canvas().Focus(g.editor)
+	g.scrollPreviewTo(heading.Line)
+}
+
 func (g *GUIApp) setupMenu() {
 	newItem := fyne.NewMenuItem("New", g.newFile)
 	newItem.Icon = theme.DocumentCreateIcon()
@@ -105,12 +298,63 @@ func (g *GUIApp) setupMenu() {
 
 	saveAsItem := fyne.NewMenuItem("Save As...", g.saveAsFile)
 
+	var exportItems []*fyne.MenuItem
+	for _, format := range exportFormats {
+		format := format
+		exportItems = append(exportItems, fyne.NewMenuItem(format.description+"...", func() {
+			g.exportAs(format)
+		}))
+	}
+	if templates := UserTemplates(); len(templates) > 0 {
+		exportItems = append(exportItems, fyne.NewMenuItemSeparator())
+		for _, name := range templates {
+			format := templateFormat(filepath.Join(TemplatesDir(), name+".tmpl"))
+			exportItems = append(exportItems, fyne.NewMenuItem(format.description+"...", func() {
+				g.exportAs(format)
+			}))
+		}
+	}
+	cardItem := fyne.NewMenuItem("Selection as Image Card...", g.exportCard)
+	cardItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyI, Modifier: fyne.KeyModifierAlt | fyne.KeyModifierShift}
+	exportItems = append(exportItems, fyne.NewMenuItemSeparator(), cardItem)
+	stripItem := fyne.NewMenuItem("Leave Out Comments and Drafts", nil)
+	stripItem.Checked = g.config.Export.Strip
+	stripItem.Action = func() {
+		g.config.Export.Strip = !g.config.Export.Strip
+		stripItem.Checked = g.config.Export.Strip
+	}
+	exportItems = append(exportItems, fyne.NewMenuItemSeparator(), stripItem)
+	exportItem := fyne.NewMenuItem("Export", nil)
+	exportItem.ChildMenu = fyne.NewMenu("", exportItems...)
+
+	sendItem := fyne.NewMenuItem("Send as Email...", g.sendEmail)
+
 	quitItem := fyne.NewMenuItem("Quit", func() {
-		g.app.Quit()
+		g.confirmDiscard(g.quit)
 	})
 
-	fileMenu := fyne.NewMenu("File", newItem, openItem, fyne.NewMenuItemSeparator(),
-		saveItem, saveAsItem, fyne.NewMenuItemSeparator(), quitItem)
+	annotateItem := fyne.NewMenuItem("Annotate PDF or HTML...", g.annotateFile)
+	randomNoteItem := fyne.NewMenuItem("Open Random Note", g.openRandomNote)
+	resurfaceItem := fyne.NewMenuItem("Resurface Old Notes...", g.resurfaceNotes)
+	periodicItem := fyne.NewMenuItem("Periodic Notes", nil)
+	periodicItem.ChildMenu = g.periodicMenu()
+	meetingItem := fyne.NewMenuItem("New Meeting Note...", g.newMeetingNote)
+
+	fileItems := []*fyne.MenuItem{newItem, openItem, annotateItem, periodicItem, meetingItem, randomNoteItem, resurfaceItem}
+	if len(g.files) > 1 {
+		var filesItems []*fyne.MenuItem
+		for _, path := range g.files {
+			filesItems = append(filesItems, fyne.NewMenuItem(path, func() {
+				g.confirmDiscard(func() { g.openPath(path, Location{}) })
+			}))
+		}
+		filesItem := fyne.NewMenuItem("Command Line Files", nil)
+		filesItem.ChildMenu = fyne.NewMenu("", filesItems...)
+		fileItems = append(fileItems, filesItem)
+	}
+	fileMenu := fyne.NewMenu("File", append(fileItems, fyne.NewMenuItemSeparator(),
+		saveItem, saveAsItem, fyne.NewMenuItemSeparator(), exportItem, sendItem,
+		fyne.NewMenuItemSeparator(), quitItem)...)
 
 	toggleViewItem := fyne.NewMenuItem("Toggle Split View", g.toggleView)
 	editorOnlyItem := fyne.NewMenuItem("Editor Only", func() {
@@ -123,231 +367,622 @@ func (g *GUIApp) setupMenu() {
 		g.splitPanel.SetOffset(0.5)
 	})
 
+	g.outlineItem = fyne.NewMenuItem("Outline", nil)
+	g.outlineItem.Action = func() {
+		if g.outlinePanel.Visible() {
+			g.outlinePanel.Hide()
+		} else {
+			g.refreshOutline()
+			g.outlinePanel.Show()
+		}
+		g.outlineItem.Checked = g.outlinePanel.Visible()
+	}
+
+	g.splitEditorItem = fyne.NewMenuItem("Split Editor", g.toggleSplitEditor)
+	g.splitEditorItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyV, Modifier: fyne.KeyModifierAlt | fyne.KeyModifierShift}
+	otherViewItem := fyne.NewMenuItem("Other Editor View", g.otherView)
+	otherViewItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyV, Modifier: fyne.KeyModifierAlt}
+	g.narrowItem = fyne.NewMenuItem("Narrow to Section", g.toggleNarrow)
+	g.narrowItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyS, Modifier: fyne.KeyModifierAlt | fyne.KeyModifierShift}
+
+	g.docMapItem = fyne.NewMenuItem("Document Map", g.toggleDocMap)
+	g.docMapItem.Checked = g.docMap.Visible()
+	g.docMapItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyM, Modifier: fyne.KeyModifierAlt}
+	g.scratchItem = fyne.NewMenuItem("Scratch Pad", g.toggleScratch)
+	g.scratchItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyA, Modifier: fyne.KeyModifierAlt}
+	// Alt+Enter is Edit → Quick Fix, which moves the scratch pad while it has
+	// the keyboard
+	scratchMoveItem := fyne.NewMenuItem("Move Scratch Pad to Document", g.moveScratch)
+
 	viewMenu := fyne.NewMenu("View", toggleViewItem, fyne.NewMenuItemSeparator(),
-		editorOnlyItem, previewOnlyItem, splitViewItem)
+		editorOnlyItem, previewOnlyItem, splitViewItem, fyne.NewMenuItemSeparator(), g.outlineItem, g.docMapItem,
+		fyne.NewMenuItemSeparator(), g.splitEditorItem, otherViewItem, g.narrowItem, fyne.NewMenuItemSeparator(), g.scratchItem, scratchMoveItem)
+
+	imageItem := fyne.NewMenuItem("Image...", g.insertImage)
+	codeBlockItem := fyne.NewMenuItem("Code Block...", g.insertCodeBlock)
+	codeLangItem := fyne.NewMenuItem("Code Block Language...", g.changeCodeLanguage)
+	g.optimizeItem = fyne.NewMenuItem("Optimize Embedded Images", nil)
+	g.optimizeItem.Checked = g.imageOpts.Optimize
+	g.optimizeItem.Action = func() {
+		g.imageOpts.Optimize = !g.imageOpts.Optimize
+		g.optimizeItem.Checked = g.imageOpts.Optimize
+	}
 
+	cutItem := fyne.NewMenuItem("Cut", func() { g.clipShortcut(&fyne.ShortcutCut{Clipboard: g.app.Clipboard()}) })
+	cutItem.Shortcut = &fyne.ShortcutCut{}
+	copyItem := fyne.NewMenuItem("Copy", func() { g.clipShortcut(&fyne.ShortcutCopy{Clipboard: g.app.Clipboard()}) })
+	copyItem.Shortcut = &fyne.ShortcutCopy{}
+	pasteItem := fyne.NewMenuItem("Paste", g.paste)
+	pasteItem.Shortcut = &fyne.ShortcutPaste{}
+	pasteClipItem := fyne.NewMenuItem("Paste from History...", g.pasteFromHistory)
+	snippetItem := fyne.NewMenuItem("Copy Code Block as Image", g.snippetImage)
+	snippetItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyG, Modifier: fyne.KeyModifierAlt | fyne.KeyModifierShift}
+	pasteClipItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyV, Modifier: fyne.KeyModifierShortcutDefault | fyne.KeyModifierShift}
+	undoItem := fyne.NewMenuItem("Undo", g.undo)
+	undoItem.Shortcut = &fyne.ShortcutUndo{}
+	redoItem := fyne.NewMenuItem("Redo", g.redo)
+	redoItem.Shortcut = &fyne.ShortcutRedo{}
+	moveUpItem := fyne.NewMenuItem("Move Line Up", func() { g.moveLines(-1) })
+	moveUpItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyUp, Modifier: fyne.KeyModifierAlt}
+	moveDownItem := fyne.NewMenuItem("Move Line Down", func() { g.moveLines(1) })
+	moveDownItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyDown, Modifier: fyne.KeyModifierAlt}
+	duplicateItem := fyne.NewMenuItem("Duplicate Line", g.duplicateLines)
+	duplicateItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyD, Modifier: fyne.KeyModifierShortcutDefault | fyne.KeyModifierShift}
+	deleteLineItem := fyne.NewMenuItem("Delete Line", g.deleteLines)
+	deleteLineItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyK, Modifier: fyne.KeyModifierShortcutDefault | fyne.KeyModifierShift}
+	joinItem := fyne.NewMenuItem("Join Lines", func() { g.reflow(JoinLines) })
+	joinItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyJ, Modifier: fyne.KeyModifierShortcutDefault | fyne.KeyModifierShift}
+	sentencesItem := fyne.NewMenuItem("One Sentence per Line", func() { g.reflow(SplitSentences) })
+	expandItem := fyne.NewMenuItem("Expand Selection", g.expandSelection)
+	expandItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyRight, Modifier: fyne.KeyModifierAlt | fyne.KeyModifierShift}
+	shrinkItem := fyne.NewMenuItem("Shrink Selection", g.shrinkSelection)
+	shrinkItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyLeft, Modifier: fyne.KeyModifierAlt | fyne.KeyModifierShift}
+	quickFixItem := fyne.NewMenuItem("Quick Fix...", g.showQuickFix)
+	quickFixItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyReturn, Modifier: fyne.KeyModifierAlt}
+	formatShortcuts := map[string]fyne.KeyName{
+		"bold": fyne.KeyB, "italic": fyne.KeyI, "code": fyne.KeyBackTick, "link": fyne.KeyK,
+	}
+	formatItems := []*fyne.MenuItem{fyne.NewMenuItemSeparator()}
+	for _, command := range formatCommands {
+		item := fyne.NewMenuItem(command.title, func() { g.applyFormat(command) })
+		if name, ok := formatShortcuts[command.name]; ok {
+			item.Shortcut = &desktop.CustomShortcut{KeyName: name, Modifier: fyne.KeyModifierShortcutDefault}
+		}
+		formatItems = append(formatItems, item)
+	}
+	editMenu := fyne.NewMenu("Edit", undoItem, redoItem, fyne.NewMenuItemSeparator(), cutItem, copyItem, pasteItem, pasteClipItem, snippetItem, fyne.NewMenuItemSeparator(), moveUpItem, moveDownItem, fyne.NewMenuItemSeparator(), duplicateItem, deleteLineItem,
+		fyne.NewMenuItemSeparator(), joinItem, sentencesItem, fyne.NewMenuItemSeparator(), expandItem, shrinkItem, fyne.NewMenuItemSeparator(), quickFixItem)
+	editMenu.Items = append(editMenu.Items, formatItems...)
+
+	citeItem := fyne.NewMenuItem("Cite Source", g.citeSource)
+	citeItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyQ, Modifier: fyne.KeyModifierAlt}
+	ocrItem := fyne.NewMenuItem("Text from Image", func() { g.recognizeText(false) })
+	ocrQuoteItem := fyne.NewMenuItem("Text from Image as Quote", func() { g.recognizeText(true) })
+	commandItem := fyne.NewMenuItem("Command Output...", g.insertCommandOutput)
+	refreshCommandItem := fyne.NewMenuItem("Refresh Command Output", g.refreshCommandOutput)
+	insertMenu := fyne.NewMenu("Insert", imageItem, codeBlockItem, codeLangItem, fyne.NewMenuItemSeparator(), citeItem, ocrItem, ocrQuoteItem, fyne.NewMenuItemSeparator(), commandItem, refreshCommandItem,
+		fyne.NewMenuItemSeparator(), g.optimizeItem)
+
+	var sortItems []*fyne.MenuItem
+	for i, command := range sortCommands {
+		if i > 0 && command.list != sortCommands[i-1].list {
+			sortItems = append(sortItems, fyne.NewMenuItemSeparator())
+		}
+		sortItems = append(sortItems, fyne.NewMenuItem(command.title, func() {
+			g.applySort(command)
+		}))
+	}
+	sortItem := fyne.NewMenuItem("Sort", nil)
+	sortItem.ChildMenu = fyne.NewMenu("", sortItems...)
+
+	lintItem := fyne.NewMenuItem("Lint Document", g.showLint)
+	orgItem := fyne.NewMenuItem("Convert Org to Markdown", g.convertOrg)
+	statsItem := fyne.NewMenuItem("Document Statistics", g.showStats)
+	workspaceStatsItem := fyne.NewMenuItem("Workspace Statistics…", g.showWorkspaceStats)
+	flashcardsItem := fyne.NewMenuItem("Review Flashcards", g.reviewFlashcards)
+	actionItemsItem := fyne.NewMenuItem("Send Action Items to Tasks", g.sendActionItems)
+	runbookItem := fyne.NewMenuItem("Runbook…", g.runbook)
+	changesItem := fyne.NewMenuItem("Change History…", g.showChanges)
+	changesItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyH, Modifier: fyne.KeyModifierAlt | fyne.KeyModifierShift}
+	exportSettingsItem := fyne.NewMenuItem("Export Settings...", g.exportSettings)
+	importSettingsItem := fyne.NewMenuItem("Import Settings...", g.importSettings)
+	externalItem := fyne.NewMenuItem("External Tools", nil)
+	externalItem.ChildMenu = g.toolsMenu()
+	g.dictateItem = fyne.NewMenuItem("Start Dictation", g.toggleDictation)
+	g.dictateItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyD, Modifier: fyne.KeyModifierAlt}
+	toolsMenu := fyne.NewMenu("Tools", lintItem, sortItem, orgItem, fyne.NewMenuItemSeparator(), statsItem, workspaceStatsItem, flashcardsItem, actionItemsItem, runbookItem, changesItem,
+		fyne.NewMenuItemSeparator(), externalItem, g.dictateItem, fyne.NewMenuItemSeparator(), exportSettingsItem, importSettingsItem)
+
+	manualItem := fyne.NewMenuItem("Manual", g.showManual)
+	manualItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyH, Modifier: fyne.KeyModifierShortcutDefault}
 	aboutItem := fyne.NewMenuItem("About", g.showAbout)
-	helpMenu := fyne.NewMenu("Help", aboutItem)
-
-	mainMenu := fyne.NewMainMenu(fileMenu, viewMenu, helpMenu)
+	helpMenu := fyne.NewMenu("Help", manualItem, fyne.NewMenuItemSeparator(), aboutItem)
+
+	matchItem := fyne.NewMenuItem("Matching Element", g.jumpToMatch)
+	matchItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyRightBracket, Modifier: fyne.KeyModifierShortcutDefault}
+	nextHeadingItem := fyne.NewMenuItem("Next Heading", func() { g.jumpHeading(1) })
+	nextHeadingItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyDown, Modifier: fyne.KeyModifierShortcutDefault}
+	prevHeadingItem := fyne.NewMenuItem("Previous Heading", func() { g.jumpHeading(-1) })
+	prevHeadingItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyUp, Modifier: fyne.KeyModifierShortcutDefault}
+	nextCodeItem := fyne.NewMenuItem("Next Code Block", func() { g.jumpCodeBlock(1) })
+	nextCodeItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyPageDown, Modifier: fyne.KeyModifierAlt}
+	prevCodeItem := fyne.NewMenuItem("Previous Code Block", func() { g.jumpCodeBlock(-1) })
+	prevCodeItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyPageUp, Modifier: fyne.KeyModifierAlt}
+	backItem := fyne.NewMenuItem("Back", g.jumpBack)
+	backItem.Icon = theme.NavigateBackIcon()
+	backItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyLeft, Modifier: fyne.KeyModifierAlt}
+	forwardItem := fyne.NewMenuItem("Forward", g.jumpForward)
+	forwardItem.Icon = theme.NavigateNextIcon()
+	forwardItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyRight, Modifier: fyne.KeyModifierAlt}
+	peekItem := fyne.NewMenuItem("Peek", g.peekAtCursor)
+	peekItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyK, Modifier: fyne.KeyModifierAlt}
+	bookmarkItem := fyne.NewMenuItem("Toggle Bookmark", g.toggleBookmark)
+	bookmarkItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyF, Modifier: fyne.KeyModifierAlt | fyne.KeyModifierShift}
+	bookmarksItem := fyne.NewMenuItem("Bookmarks…", g.showBookmarks)
+	bookmarksItem.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyJ, Modifier: fyne.KeyModifierAlt | fyne.KeyModifierShift}
+	goMenu := fyne.NewMenu("Go", backItem, forwardItem, fyne.NewMenuItemSeparator(), matchItem, peekItem,
+		fyne.NewMenuItemSeparator(), nextHeadingItem, prevHeadingItem,
+		fyne.NewMenuItemSeparator(), nextCodeItem, prevCodeItem,
+		fyne.NewMenuItemSeparator(), bookmarkItem, bookmarksItem)
+
+	mainMenu := fyne.NewMainMenu(fileMenu, editMenu, insertMenu, viewMenu, goMenu, toolsMenu, helpMenu)
+	countMenuItems(mainMenu.Items)
 	g.window.SetMainMenu(mainMenu)
 }
 
 func (g *GUIApp) setupEventHandlers() {
-	g.editor.OnChanged = func(content string) {
-		g.updatePreview(content)
-	}
+	g.watchEditor(g.editor)
+	g.previewScroll.OnScrolled = g.syncEditorScroll
+
+	g.window.SetCloseIntercept(func() {
+		g.confirmDiscard(g.quit)
+	})
 
 	g.window.Canvas().SetOnTypedKey(func(key *fyne.KeyEvent) {
 		if key.Name == fyne.KeyS && (key.Physical.ScanCode == 0 || key.Physical.ScanCode == 1) {
 			g.saveFile()
 		}
+		if key.Name == fyne.KeyF1 {
+			g.showManual()
+		}
 	})
 }
 
+// editorChanged follows an edit in the editor.
+func (g *GUIApp) editorChanged(content string) {
+	g.syncTwin(content)
+	g.history.Record(g.document())
+	g.schedulePreview()
+	if g.server != nil {
+		g.server.Update(g.currentFile, content)
+	}
+	g.updateTitle()
+	g.scheduleStats()
+	if g.outlinePanel.Visible() {
+		g.refreshOutline()
+	}
+}
+
+// editorCursorChanged follows the cursor of the editor.
+func (g *GUIApp) editorCursorChanged() {
+	g.updateBreadcrumb()
+	g.updateDocMap()
+	if g.previewSyncing() && !g.scrollSyncing && !g.followSection(g.editor.CursorRow) {
+		g.scrollPreviewTo(g.editor.CursorRow)
+	}
+}
+
 func (g *GUIApp) updatePreview(content string) {
+	defer timeRender("gui_preview", time.Now())
+	g.previewAnchors, g.previewPixels = nil, nil
 	if content == "" {
 		g.preview.ParseMarkdown("")
 		return
 	}
 
-	htmlContent := g.mdProcessor.ConvertMarkdownToHTML(content)
-	markdownForFyne := g.htmlToMarkdown(htmlContent)
-	g.preview.ParseMarkdown(markdownForFyne)
+	// Tasks can only be ticked, and lines mapped, where the preview matches
+	// the source
+	if isOrgFile(g.currentFile) {
+		g.mdProcessor.RenderFynePreview(g.preview, OrgToMarkdown(content), nil, nil, g.imageLoaded)
+		return
+	}
+
+	g.previewAnchors = g.mdProcessor.RenderFynePreview(g.preview, markFormFields(ExpandVars(content, time.Now())), g.toggleTask, g.fillField, g.imageLoaded)
+	hookPeekLinks(g.preview.Segments, g.peekLink, g.hidePeek)
+	g.preview.Refresh()
+	g.restorePreview()
 }
 
-func (g *GUIApp) htmlToMarkdown(html string) string {
-	text := g.mdProcessor.UnescapeHTML(html)
+// imageLoaded renders the preview again once an image from the web is in.
+func (g *GUIApp) imageLoaded() {
+	fyne.Do(g.schedulePreview)
+}
 
-	lines := strings.Split(text, "\n")
-	var result []string
-	var inCodeBlock bool
-	var codeBlockContent []string
-	var codeBlockLang string
+// toggleTask checks or unchecks a task list item from the preview.
+func (g *GUIApp) toggleTask(task int) {
+	content := g.editor.Text
+	items := g.mdProcessor.TaskItems(content)
+	if task >= len(items) {
+		return
+	}
+	row, col := g.editor.CursorRow, g.editor.CursorColumn
+	g.editor.SetText(ToggleTask(content, items[task]))
+	g.editor.CursorRow, g.editor.CursorColumn = row, col
+	g.editor.Refresh()
+}
 
-	for _, line := range lines {
-		originalLine := line
-		line = strings.TrimSpace(line)
-		if line == "" {
-			if inCodeBlock {
-				codeBlockContent = append(codeBlockContent, "")
-			} else {
-				result = append(result, "")
-			}
-			continue
-		}
+func (g *GUIApp) newFile() {
+	g.confirmDiscard(func() {
+		g.savePosition()
+		g.currentFile = ""
+		g.loadConfig()
+		g.watchCurrentFile()
+		g.lockCurrentFile()
+		g.showDocument("")
+		g.markSaved()
+		g.resetHistory()
+		g.closeSource()
+	})
+}
 
-		if strings.Contains(line, "<pre><code") {
-			inCodeBlock = true
-			codeBlockContent = []string{}
-			codeBlockLang = g.mdProcessor.ExtractCodeLanguage(line)
-			content := g.mdProcessor.RemoveHTMLTags(line)
-			if strings.TrimSpace(content) != "" {
-				codeBlockContent = append(codeBlockContent, content)
-			}
-			continue
-		}
+// markSaved records the editor text as what is on disk.
+func (g *GUIApp) markSaved() {
+	g.savedText = g.document()
+	g.updateTitle()
+}
 
-		if strings.Contains(line, "</code></pre>") {
-			inCodeBlock = false
-			content := strings.ReplaceAll(line, "</code></pre>", "")
-			content = g.mdProcessor.RemoveHTMLTags(content)
-			if strings.TrimSpace(content) != "" {
-				codeBlockContent = append(codeBlockContent, content)
-			}
+// resetHistory starts a new undo history for a document that was just
+// opened.
+func (g *GUIApp) resetHistory() {
+	g.history = NewHistory(g.document())
+	g.jumps = JumpList{}
+}
 
-			if codeBlockLang != "" {
-				result = append(result, "```"+codeBlockLang)
-			} else {
-				result = append(result, "```")
-			}
-			result = append(result, strings.Join(codeBlockContent, "\n"))
-			result = append(result, "```")
-			result = append(result, "")
-			continue
-		}
+func (g *GUIApp) undo() {
+	g.applyHistory(g.history.Undo)
+}
 
-		if inCodeBlock {
-			content := g.mdProcessor.RemoveHTMLTags(line)
-			codeBlockContent = append(codeBlockContent, content)
-			continue
-		}
+func (g *GUIApp) redo() {
+	g.applyHistory(g.history.Redo)
+}
 
-		if strings.Contains(line, "<h1") {
-			if content := g.mdProcessor.ExtractHeaderContent(line, 1); content != "" {
-				result = append(result, "# "+content)
-				result = append(result, "")
-			}
-		} else if strings.Contains(line, "<h2") {
-			if content := g.mdProcessor.ExtractHeaderContent(line, 2); content != "" {
-				result = append(result, "## "+content)
-				result = append(result, "")
-			}
-		} else if strings.Contains(line, "<h3") {
-			if content := g.mdProcessor.ExtractHeaderContent(line, 3); content != "" {
-				result = append(result, "### "+content)
-				result = append(result, "")
-			}
-		} else if strings.Contains(line, "<h4") {
-			if content := g.mdProcessor.ExtractHeaderContent(line, 4); content != "" {
-				result = append(result, "#### "+content)
-				result = append(result, "")
-			}
-		} else if strings.Contains(line, "<ol>") || strings.Contains(line, "</ol>") {
-			continue
-		} else if strings.Contains(line, "<ul>") || strings.Contains(line, "</ul>") {
-			continue
-		} else if strings.Contains(line, "<li>") {
-			content := strings.ReplaceAll(line, "<li>", "")
-			content = strings.ReplaceAll(content, "</li>", "")
-
-			content = g.processInlineFormatting(content)
-			content = g.mdProcessor.RemoveHTMLTags(content)
-			content = strings.TrimSpace(content)
-
-			if content != "" {
-				leadingSpaces := len(originalLine) - len(strings.TrimLeft(originalLine, " \t"))
-				indent := ""
-				bullet := "- "
-
-				if leadingSpaces >= 4 {
-					indent = "    "
-				} else if leadingSpaces >= 2 {
-					indent = "  "
+func (g *GUIApp) applyHistory(step func(content string) (string, int, error)) {
+	content, offset, err := step(g.document())
+	if err != nil {
+		// Nothing to undo or redo
+		return
+	}
+	g.setDocument(content)
+	row, col := rowColumn(content, offset)
+	g.editor.CursorRow, g.editor.CursorColumn = row-g.narrow.lineOffset(), col
+	g.editor.Refresh()
+}
+
+func (g *GUIApp) dirty() bool {
+	return g.document() != g.savedText
+}
+
+// updateTitle shows the file name in the window title and label, with a *
+// while there are unsaved changes.
+func (g *GUIApp) updateTitle() {
+	name := "untitled.md"
+	title := "Parselt - Markdown Editor"
+	if g.currentFile != "" {
+		name = filepath.Base(g.currentFile)
+		title = fmt.Sprintf("Parselt - %s", name)
+	}
+	if fm := ParseFrontMatter(g.document()); fm != nil && fm.Title != "" {
+		title += fmt.Sprintf(" (%s)", fm.Title)
+	}
+	if g.narrow != nil {
+		title += fmt.Sprintf(" › %s", g.narrow.Title)
+	}
+	if g.readOnly {
+		name += " (read-only)"
+		title += " (read-only)"
+	}
+	if g.dirty() {
+		name += " *"
+		title += " *"
+	}
+	g.fileLabel.SetText(name)
+	g.window.SetTitle(title)
+}
+
+// confirmDiscard runs action right away when there is nothing to lose and
+// otherwise asks whether to save, discard or cancel.
+func (g *GUIApp) confirmDiscard(action func()) {
+	if !g.dirty() {
+		action()
+		return
+	}
+
+	name := "untitled.md"
+	if g.currentFile != "" {
+		name = filepath.Base(g.currentFile)
+	}
+	confirm := dialog.NewCustomWithoutButtons("Unsaved Changes",
+		widget.NewLabel(fmt.Sprintf("Save changes to %s before continuing?", name)), g.window)
+	saveButton := widget.NewButton("Save", func() {
+		confirm.Hide()
+		g.saveThen(action)
+	})
+	saveButton.Importance = widget.HighImportance
+	confirm.SetButtons([]fyne.CanvasObject{
+		widget.NewButton("Cancel", confirm.Hide),
+		widget.NewButton("Discard", func() {
+			confirm.Hide()
+			RemoveAutosave(g.currentFile)
+			action()
+		}),
+		saveButton,
+	})
+	confirm.Show()
+}
+
+// quit leaves no recovery copy behind: by now every change was either saved
+// or discarded on purpose.
+func (g *GUIApp) quit() {
+	g.savePosition()
+	g.saveScratch()
+	if g.listening != nil {
+		g.listening.Cancel()
+	}
+	RemoveAutosave(g.currentFile)
+	Unlock(g.currentFile)
+	g.app.Quit()
+}
+
+// startAutosave writes the recovery copy of unsaved changes in the
+// background at the configured interval.
+func (g *GUIApp) startAutosave() {
+	interval := g.config.AutosaveInterval()
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(interval) {
+			fyne.Do(func() {
+				if !g.dirty() || g.readOnly {
+					return
+				}
+				if err := WriteAutosave(g.currentFile, g.document()); err != nil {
+					log.Printf("Error writing autosave: %v", err)
 				}
+			})
+		}
+	}()
+}
 
-				numRe := regexp.MustCompile(`(\d+)\.`)
-				if matches := numRe.FindStringSubmatch(content); len(matches) > 1 {
-					bullet = matches[1] + ". "
-					content = numRe.ReplaceAllString(content, "")
-					content = strings.TrimSpace(content)
+// startWatcher reloads or asks about the current file whenever another
+// program changes it.
+func (g *GUIApp) startWatcher() {
+	watcher, err := NewFileWatcher()
+	if err != nil {
+		log.Printf("Error watching files: %v", err)
+		return
+	}
+	g.watcher = watcher
+	go func() {
+		for path := range watcher.Changes() {
+			fyne.Do(func() {
+				if g.currentFile != "" && sameFile(path, g.currentFile) {
+					g.handleFileChange()
 				}
+			})
+		}
+	}()
+}
 
-				result = append(result, indent+bullet+content)
-			}
-		} else if strings.Contains(line, "<p>") {
-			content := strings.ReplaceAll(line, "<p>", "")
-			content = strings.ReplaceAll(content, "</p>", "")
-			content = g.processInlineFormatting(content)
-			content = g.mdProcessor.RemoveHTMLTags(content)
-			content = strings.TrimSpace(content)
-
-			if content != "" {
-				result = append(result, content)
-				result = append(result, "")
-			}
-		} else if strings.Contains(line, "<blockquote>") {
-			content := strings.ReplaceAll(line, "<blockquote>", "")
-			content = strings.ReplaceAll(content, "</blockquote>", "")
-			content = g.processInlineFormatting(content)
-			content = g.mdProcessor.RemoveHTMLTags(content)
-			content = strings.TrimSpace(content)
-
-			if content != "" {
-				result = append(result, "> "+content)
-			}
-		} else {
-			content := g.processInlineFormatting(line)
-			cleanLine := g.mdProcessor.RemoveHTMLTags(content)
-			cleanLine = strings.TrimSpace(cleanLine)
-			if cleanLine != "" {
-				result = append(result, cleanLine)
-			}
+func (g *GUIApp) watchCurrentFile() {
+	if g.watcher == nil || g.watchedFile == g.currentFile {
+		return
+	}
+	g.watcher.Unwatch(g.watchedFile)
+	g.watchedFile = g.currentFile
+	if err := g.watcher.Watch(g.currentFile); err != nil {
+		dialog.ShowError(err, g.window)
+	}
+}
+
+// handleFileChange reloads the file when there are no unsaved changes and
+// otherwise asks whether to reload it or keep the edits.
+func (g *GUIApp) handleFileChange() {
+	disk, changed := externalChange(g.currentFile, g.savedText, g.document())
+	if !changed {
+		if disk == g.document() {
+			g.markSaved()
 		}
+		return
 	}
+	if !g.dirty() {
+		g.showDocument(disk)
+		g.markSaved()
+		return
+	}
+
+	if g.reloadDialog != nil {
+		g.reloadDialog.Hide()
+	}
+	message := fmt.Sprintf("%s was changed by another program.\nReload it and lose your unsaved changes?", filepath.Base(g.currentFile))
+	confirm := dialog.NewConfirm("File Changed on Disk", message, func(reload bool) {
+		g.reloadDialog = nil
+		if reload {
+			g.showDocument(disk)
+		}
+		// Either way the editor now knows what is on disk
+		g.savedText = disk
+		g.updateTitle()
+	}, g.window)
+	confirm.SetConfirmText("Reload")
+	confirm.SetDismissText("Keep Mine")
+	confirm.Show()
+	g.reloadDialog = confirm
+}
 
-	var cleanResult []string
-	for i, line := range result {
-		if line == "" && i > 0 && i < len(result)-1 && result[i-1] == "" {
-			continue // Skip consecutive empty lines
+// offerRecovery asks whether to restore the autosaved copy of the current
+// file when one was left behind by a crash.
+func (g *GUIApp) offerRecovery() {
+	if g.readOnly {
+		// The recovery copy may be the other parselt's
+		return
+	}
+	text, modTime, ok := Recovery(g.currentFile)
+	if !ok {
+		return
+	}
+	message := fmt.Sprintf("%s has changes from %s that were never saved.\nRestore them?",
+		filepath.Base(g.currentFile), modTime.Format("Jan 2 15:04"))
+	dialog.ShowConfirm("Recover Unsaved Changes", message, func(restore bool) {
+		if !restore {
+			RemoveAutosave(g.currentFile)
+			return
 		}
-		cleanResult = append(cleanResult, line)
+		// Left unsaved so the restored text can be reviewed first
+		g.showDocument(text)
+	}, g.window)
+}
+
+// dialogLocation starts file dialogs in the configured directory.
+func (g *GUIApp) dialogLocation(d interface{ SetLocation(fyne.ListableURI) }) {
+	dir := g.config.StartDirectory()
+	if dir == "" {
+		return
+	}
+	if lister, err := storage.ListerForURI(storage.NewFileURI(dir)); err == nil {
+		d.SetLocation(lister)
 	}
-	return strings.Join(cleanResult, "\n")
 }
 
-func (g *GUIApp) processInlineFormatting(content string) string {
-	codeRe := regexp.MustCompile(`<code[^>]*>(.*?)</code>`)
-	content = codeRe.ReplaceAllStringFunc(content, func(match string) string {
-		matches := codeRe.FindStringSubmatch(match)
-		if len(matches) > 1 {
-			codeContent := strings.TrimSpace(matches[1])
-			return "`" + codeContent + "`"
+func (g *GUIApp) openFile() {
+	g.confirmDiscard(g.showOpenDialog)
+}
+
+func (g *GUIApp) showOpenDialog() {
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, g.window)
+			return
 		}
-		return match
-	})
+		if reader == nil {
+			return
+		}
+		// Only the path is wanted; the document store reads the file
+		reader.Close()
+		g.openPath(uriPath(reader.URI()), Location{})
+	}, g.window)
+	g.dialogLocation(openDialog)
+	openDialog.Show()
+}
+
+func (g *GUIApp) saveFile() {
+	g.saveThen(nil)
+}
 
-	strongRe := regexp.MustCompile(`<strong[^>]*>(.*?)</strong>`)
-	content = strongRe.ReplaceAllStringFunc(content, func(match string) string {
-		matches := strongRe.FindStringSubmatch(match)
-		if len(matches) > 1 {
-			boldContent := strings.TrimSpace(matches[1])
-			return "**" + boldContent + "**"
+// saveThen saves the document and runs next afterwards, or reports the save
+// when there is nothing to continue with.
+func (g *GUIApp) saveThen(next func()) {
+	if g.currentFile == "" {
+		if !g.saveUntitledThen(next) {
+			g.saveAsThen(next)
 		}
-		return match
-	})
+		return
+	}
+
+	if !g.saveLocked(next) || !g.formatBeforeSave(next) {
+		return
+	}
+	err := g.docs.WriteDocument(g.currentFile, []byte(g.document()))
+	if err != nil {
+		g.saveFailed(err, next)
+		return
+	}
+	before := g.savedText
+	g.markSaved()
+	g.recordChange(before)
+	RemoveAutosave(g.currentFile)
+	g.runHooks(g.config.Hooks.AfterSave, "after_save", g.currentFile, "")
+
+	if next != nil {
+		next()
+		return
+	}
+	if g.offerLinkUpdates(before) {
+		return
+	}
+	dialog.ShowInformation("Saved", fmt.Sprintf("File saved to %s", g.currentFile), g.window)
+}
+
+func (g *GUIApp) saveAsFile() {
+	g.saveAsThen(nil)
+}
 
-	emRe := regexp.MustCompile(`<em[^>]*>(.*?)</em>`)
-	content = emRe.ReplaceAllStringFunc(content, func(match string) string {
-		matches := emRe.FindStringSubmatch(match)
-		if len(matches) > 1 {
-			italicContent := strings.TrimSpace(matches[1])
-			return "*" + italicContent + "*"
+func (g *GUIApp) saveAsThen(next func()) {
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		// Only the path is wanted; the document store writes the file
+		writer.Close()
+		path := uriPath(writer.URI())
+
+		if !g.filterBeforeSave(path) {
+			return
+		}
+		if err := g.docs.WriteDocument(path, []byte(g.document())); err != nil {
+			g.saveFailed(err, next)
+			return
 		}
-		return match
-	})
 
-	return content
+		g.currentFile = path
+		g.loadConfig()
+		g.watchCurrentFile()
+		g.lockCurrentFile()
+		g.updatePreview(g.editor.Text)
+		before := g.savedText
+		g.markSaved()
+		g.recordChange(before)
+		RemoveAutosave(g.currentFile)
+		g.runHooks(g.config.Hooks.AfterSave, "after_save", g.currentFile, "")
+
+		if next != nil {
+			next()
+			return
+		}
+		dialog.ShowInformation("Saved", fmt.Sprintf("File saved to %s", g.currentFile), g.window)
+	}, g.window)
+	g.dialogLocation(saveDialog)
+	saveDialog.Show()
 }
 
-func (g *GUIApp) newFile() {
-	g.editor.SetText("")
-	g.currentFile = ""
-	g.fileLabel.SetText("untitled.md")
-	g.window.SetTitle("Parselt - Markdown Editor")
+// saveFailed explains why a save failed and offers to try again, or to save
+// under another name, before going on with next.
+func (g *GUIApp) saveFailed(err error, next func()) {
+	label := widget.NewLabel(errorMessage(err))
+	label.Wrapping = fyne.TextWrapWord
+	failed := dialog.NewCustomWithoutButtons("Could Not Save", label, g.window)
+	retryButton := widget.NewButton("Retry", func() {
+		failed.Hide()
+		g.saveThen(next)
+	})
+	retryButton.Importance = widget.HighImportance
+	failed.SetButtons([]fyne.CanvasObject{
+		widget.NewButton("Cancel", failed.Hide),
+		widget.NewButton("Save As…", func() {
+			failed.Hide()
+			g.saveAsThen(next)
+		}),
+		retryButton,
+	})
+	failed.Resize(fyne.NewSize(480, 0))
+	failed.Show()
 }
 
-func (g *GUIApp) openFile() {
+func (g *GUIApp) insertImage() {
 	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
 		if err != nil {
 			dialog.ShowError(err, g.window)
@@ -356,38 +991,432 @@ func (g *GUIApp) openFile() {
 		if reader == nil {
 			return
 		}
-		defer reader.Close()
+		reader.Close()
 
-		data, err := io.ReadAll(reader)
+		embedded, err := EmbedImage(uriPath(reader.URI()), g.currentFile, g.imageOpts)
 		if err != nil {
 			dialog.ShowError(err, g.window)
 			return
 		}
 
-		g.editor.SetText(string(data))
-		g.currentFile = reader.URI().Path()
-		g.fileLabel.SetText(filepath.Base(g.currentFile))
-		g.window.SetTitle(fmt.Sprintf("Parselt - %s", filepath.Base(g.currentFile)))
+		g.insertAtCursor(embedded.Markdown(""))
+		dialog.ShowInformation("Image Embedded", embedded.Report(), g.window)
 	}, g.window)
 }
 
-func (g *GUIApp) saveFile() {
-	if g.currentFile == "" {
-		g.saveAsFile()
+func (g *GUIApp) insertAtCursor(text string) {
+	lines := strings.Split(g.editor.Text, "\n")
+	row := g.editor.CursorRow
+	if row >= len(lines) {
+		row = len(lines) - 1
+	}
+
+	line := []rune(lines[row])
+	col := g.editor.CursorColumn
+	if col > len(line) {
+		col = len(line)
+	}
+	lines[row] = string(line[:col]) + text + string(line[col:])
+
+	g.editor.SetText(strings.Join(lines, "\n"))
+
+	inserted := strings.Split(text, "\n")
+	g.editor.CursorRow = row + len(inserted) - 1
+	if len(inserted) == 1 {
+		g.editor.CursorColumn = col + len([]rune(text))
+	} else {
+		g.editor.CursorColumn = len([]rune(inserted[len(inserted)-1]))
+	}
+	g.editor.Refresh()
+}
+
+// selectedRange returns the rune offsets of the selection, or the cursor
+// twice when nothing is selected. The Entry only exposes the selected text,
+// so it is located next to the cursor.
+func (g *GUIApp) selectedRange() (int, int) {
+	text := []rune(g.editor.Text)
+	selected := []rune(g.editor.SelectedText())
+
+	lines := strings.Split(g.editor.Text, "\n")
+	cursor := 0
+	for _, line := range lines[:min(g.editor.CursorRow, len(lines)-1)] {
+		cursor += len([]rune(line)) + 1
+	}
+	cursor = min(cursor+g.editor.CursorColumn, len(text))
+
+	start := cursor - len(selected)
+	if start < 0 || string(text[start:cursor]) != string(selected) {
+		start = cursor
+	}
+	return start, min(start+len(selected), len(text))
+}
+
+// selectedLines returns the first and last line of the selection, or just
+// the cursor line and false when nothing is selected.
+func (g *GUIApp) selectedLines() (int, int, bool) {
+	if g.editor.SelectedText() == "" {
+		return g.editor.CursorRow, g.editor.CursorRow, false
+	}
+	text := []rune(g.editor.Text)
+	start, end := g.selectedRange()
+	first := strings.Count(string(text[:start]), "\n")
+	last := first + strings.Count(string(text[start:end]), "\n")
+	// A selection ending at the start of a line leaves that line out
+	if last > first && (end == 0 || text[end-1] == '\n') {
+		last--
+	}
+	return first, last, true
+}
+
+// expandSelection grows the selection to the next enclosing element. The
+// previous selections are kept for shrinkSelection as long as the selection
+// is not changed by hand.
+func (g *GUIApp) expandSelection() {
+	content := g.editor.Text
+	text := []rune(content)
+	start, end := g.selectedRange()
+	current := TextRange{Start: len(string(text[:start])), End: len(string(text[:end]))}
+	if len(g.selections) > 0 && !g.selections[len(g.selections)-1].same(current) {
+		g.selections = nil
+	}
+
+	next, ok := g.mdProcessor.ExpandSelection(content, current)
+	if !ok {
 		return
 	}
+	if len(g.selections) == 0 {
+		g.selections = append(g.selections, current)
+	}
+	g.selections = append(g.selections, next)
+	g.selectRange(next)
+}
 
-	err := os.WriteFile(g.currentFile, []byte(g.editor.Text), 0644)
-	if err != nil {
-		dialog.ShowError(err, g.window)
+func (g *GUIApp) shrinkSelection() {
+	start, end := g.selectedRange()
+	text := []rune(g.editor.Text)
+	current := TextRange{Start: len(string(text[:start])), End: len(string(text[:end]))}
+	if len(g.selections) < 2 || !g.selections[len(g.selections)-1].same(current) {
+		g.selections = nil
 		return
 	}
+	g.selections = g.selections[:len(g.selections)-1]
+	g.selectRange(g.selections[len(g.selections)-1])
+}
 
-	dialog.ShowInformation("Saved", fmt.Sprintf("File saved to %s", g.currentFile), g.window)
+// formatToolbar has a button for every formatting command.
+func (g *GUIApp) formatToolbar() fyne.CanvasObject {
+	back := widget.NewButtonWithIcon("", theme.NavigateBackIcon(), g.jumpBack)
+	back.Importance = widget.LowImportance
+	forward := widget.NewButtonWithIcon("", theme.NavigateNextIcon(), g.jumpForward)
+	forward.Importance = widget.LowImportance
+	toolbar := container.NewHBox(back, forward, widget.NewSeparator())
+	for _, command := range formatCommands {
+		button := widget.NewButton(command.label, func() { g.applyFormat(command) })
+		button.Importance = widget.LowImportance
+		toolbar.Add(button)
+	}
+	return toolbar
 }
 
-func (g *GUIApp) saveAsFile() {
-	dialog.ShowFileSave(func(writer fyne.URIWriteCloser, err error) {
+// applyFormat runs a formatting command on the selection, or at the cursor.
+func (g *GUIApp) applyFormat(command formatCommand) {
+	content := g.editor.Text
+	text := []rune(content)
+	start, end := g.selectedRange()
+	sel := TextRange{Start: len(string(text[:start])), End: len(string(text[:end]))}
+	formatted, result := command.format(content, sel)
+	g.editor.SetText(formatted)
+	g.selectRange(result)
+
+	if url := strings.TrimSpace(content[sel.Start:sel.End]); command.name == "link" && isBareURL(url) {
+		g.fetchLinkTitle(linkTitle{start: sel.Start, original: "[](" + url + ")", url: url})
+	}
+}
+
+// paste makes the selection a link when a bare URL is pasted over it, inserts
+// a bare URL and fetches its title when that is turned on, and otherwise
+// pastes as usual.
+func (g *GUIApp) paste() {
+	clipboard := g.app.Clipboard()
+	url := strings.TrimSpace(clipboard.Content())
+	content := g.editor.Text
+	text := []rune(content)
+	start, end := g.selectedRange()
+	sel := TextRange{Start: len(string(text[:start])), End: len(string(text[:end]))}
+	if isBareURL(url) && strings.TrimSpace(content[sel.Start:sel.End]) != "" {
+		linked, cursor := LinkSelection(content, sel, url)
+		g.editor.SetText(linked)
+		g.selectRange(TextRange{Start: cursor, End: cursor})
+		return
+	}
+	if !g.config.Links.FetchTitles || !isBareURL(url) {
+		g.editor.TypedShortcut(&fyne.ShortcutPaste{Clipboard: clipboard})
+		g.suggestLanguage()
+		return
+	}
+
+	g.editor.SetText(content[:sel.Start] + url + content[sel.End:])
+	cursor := sel.Start + len(url)
+	g.selectRange(TextRange{Start: cursor, End: cursor})
+	g.fetchLinkTitle(linkTitle{start: sel.Start, original: url, url: url})
+}
+
+// fetchLinkTitle fetches the title for a link in the background and puts it
+// in, unless the link was changed in the meantime or the fetch failed.
+func (g *GUIApp) fetchLinkTitle(link linkTitle) {
+	if !g.config.Links.FetchTitles {
+		return
+	}
+	history := g.history
+	timeout := g.config.Links.TitleTimeout()
+	go func() {
+		title, err := FetchTitle(link.url, timeout)
+		fyne.Do(func() {
+			if err != nil || g.history != history {
+				return
+			}
+			link.title = title
+			content := g.editor.Text
+			titled, delta, ok := link.apply(content)
+			if !ok {
+				return
+			}
+			cursor := link.cursor(runeOffset(content, g.editor.CursorRow, g.editor.CursorColumn), delta)
+			g.editor.SetText(titled)
+			g.editor.CursorRow, g.editor.CursorColumn = rowColumn(titled, cursor)
+			g.editor.Refresh()
+		})
+	}()
+}
+
+func (g *GUIApp) jumpToMatch() {
+	content := g.editor.Text
+	target, ok := g.mdProcessor.MatchingElement(content, runeOffset(content, g.editor.CursorRow, g.editor.CursorColumn))
+	if !ok {
+		return
+	}
+	g.pushJump()
+	g.selectRange(TextRange{Start: target, End: target})
+}
+
+func (g *GUIApp) jumpHeading(delta int) {
+	if line, ok := g.mdProcessor.HeadingLine(g.editor.Text, g.editor.CursorRow, delta); ok {
+		g.jumpToLine(line)
+	}
+}
+
+func (g *GUIApp) jumpCodeBlock(delta int) {
+	if line, ok := CodeBlockLine(g.editor.Text, g.editor.CursorRow, delta); ok {
+		g.jumpToLine(line)
+	}
+}
+
+func (g *GUIApp) jumpToLine(line int) {
+	g.pushJump()
+	offset := runeOffset(g.editor.Text, line, 0)
+	g.selectRange(TextRange{Start: offset, End: offset})
+}
+
+// pushJump remembers the cursor in the jump list before it is moved away.
+func (g *GUIApp) pushJump() {
+	g.jumps.Push(JumpPosition{Line: g.editor.CursorRow, Column: g.editor.CursorColumn})
+}
+
+func (g *GUIApp) jumpBack() {
+	if pos, ok := g.jumps.Back(JumpPosition{Line: g.editor.CursorRow, Column: g.editor.CursorColumn}); ok {
+		g.moveCursor(pos)
+	}
+}
+
+func (g *GUIApp) jumpForward() {
+	if pos, ok := g.jumps.Forward(); ok {
+		g.moveCursor(pos)
+	}
+}
+
+// moveCursor puts the cursor back at a position from the jump list. Lines
+// may have been shortened or removed since it was recorded.
+func (g *GUIApp) moveCursor(pos JumpPosition) {
+	offset := runeOffset(g.editor.Text, pos.Line, pos.Column)
+	g.selectRange(TextRange{Start: offset, End: offset})
+	g.window.Canvas().Focus(g.editor)
+	g.scrollPreviewTo(g.editor.CursorRow)
+}
+
+// selectRange selects the byte range r of the editor. The Entry has no way
+// to set the selection directly, so a shift+arrow selection is simulated.
+func (g *GUIApp) selectRange(r TextRange) {
+	content := g.editor.Text
+	if g.editor.SelectedText() != "" {
+		// An unshifted arrow ends the old selection
+		g.editor.TypedKey(&fyne.KeyEvent{Name: fyne.KeyLeft})
+	}
+	row, col := rowColumn(content, r.Start)
+	g.editor.CursorRow, g.editor.CursorColumn = row, col
+	if r.End > r.Start {
+		endRow, endCol := rowColumn(content, r.End)
+		shift := &fyne.KeyEvent{Name: desktop.KeyShiftLeft}
+		g.editor.KeyDown(shift)
+		if endCol > 0 {
+			g.editor.CursorRow, g.editor.CursorColumn = endRow, endCol-1
+			g.editor.TypedKey(&fyne.KeyEvent{Name: fyne.KeyRight})
+		} else {
+			// The end is at the start of a line
+			lines := strings.Split(content, "\n")
+			g.editor.CursorRow = endRow - 1
+			g.editor.CursorColumn = len([]rune(lines[endRow-1]))
+			g.editor.TypedKey(&fyne.KeyEvent{Name: fyne.KeyRight})
+		}
+		g.editor.KeyUp(shift)
+	}
+	g.editor.Refresh()
+	g.window.Canvas().Focus(g.editor)
+}
+
+// editLines runs a line command on the selected lines or the cursor line and
+// moves the cursor by offset rows.
+func (g *GUIApp) editLines(edit func(content string, first, last int) (string, int)) {
+	first, last, _ := g.selectedLines()
+	edited, offset := edit(g.editor.Text, first, last)
+	if edited == g.editor.Text {
+		return
+	}
+
+	row, col := g.editor.CursorRow, g.editor.CursorColumn
+	g.editor.SetText(edited)
+	lines := strings.Split(edited, "\n")
+	g.editor.CursorRow = max(min(row+offset, len(lines)-1), 0)
+	g.editor.CursorColumn = min(col, len([]rune(lines[g.editor.CursorRow])))
+	g.editor.Refresh()
+}
+
+// reflow runs fn on the selected lines, or on the paragraph around the
+// cursor when nothing is selected, and puts the cursor on the first of them.
+func (g *GUIApp) reflow(fn func(content string, first, last int) string) {
+	g.editLines(func(content string, first, last int) (string, int) {
+		if _, _, selected := g.selectedLines(); !selected {
+			first, last = ParagraphAt(content, first)
+		}
+		return fn(content, first, last), first - g.editor.CursorRow
+	})
+}
+
+func (g *GUIApp) moveLines(delta int) {
+	g.editLines(func(content string, first, last int) (string, int) {
+		moved, _ := MoveLines(content, first, last, delta)
+		return moved, delta
+	})
+}
+
+func (g *GUIApp) duplicateLines() {
+	g.editLines(func(content string, first, last int) (string, int) {
+		return DuplicateLines(content, first, last), last - first + 1
+	})
+}
+
+func (g *GUIApp) deleteLines() {
+	g.editLines(func(content string, first, last int) (string, int) {
+		if lines := strings.Split(content, "\n"); last < len(lines) {
+			g.clips = addClip(g.clips, strings.Join(lines[first:last+1], "\n")+"\n")
+		}
+		return DeleteLines(content, first, last), first - g.editor.CursorRow
+	})
+}
+
+func (g *GUIApp) applySort(command sortCommand) {
+	content := g.editor.Text
+	var sorted string
+	if command.list {
+		var err error
+		sorted, err = SortList(content, g.editor.CursorRow, command.mode)
+		if err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+	} else {
+		start, end, selected := g.selectedLines()
+		if !selected {
+			start, end = ParagraphAt(content, start)
+		}
+		sorted = SortLineRange(content, start, end, command.mode)
+	}
+	if sorted == content {
+		return
+	}
+
+	row, col := g.editor.CursorRow, g.editor.CursorColumn
+	g.editor.SetText(sorted)
+	lines := strings.Split(sorted, "\n")
+	g.editor.CursorRow = min(row, len(lines)-1)
+	g.editor.CursorColumn = min(col, len([]rune(lines[g.editor.CursorRow])))
+	g.editor.Refresh()
+}
+
+func (g *GUIApp) showLint() {
+	issues := g.linter.Lint(g.editor.Text)
+	if len(issues) == 0 {
+		dialog.ShowInformation("Lint", "No lint issues found", g.window)
+		return
+	}
+
+	var lintDialog dialog.Dialog
+	list := widget.NewList(
+		func() int { return len(issues) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			text := issues[id].String()
+			if issues[id].Fix != nil {
+				text += fmt.Sprintf(" — suggestion: %q", issues[id].Fix.Text)
+			}
+			obj.(*widget.Label).SetText(text)
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		lintDialog.Hide()
+		g.applyLintIssue(issues[id])
+	}
+
+	lintDialog = dialog.NewCustom(fmt.Sprintf("Lint (%d issues)", len(issues)), "Close", list, g.window)
+	if slices.ContainsFunc(issues, func(issue LintIssue) bool { return issue.Fix != nil }) {
+		lintDialog.(*dialog.CustomDialog).SetButtons([]fyne.CanvasObject{
+			widget.NewButton("Fix All…", func() {
+				lintDialog.Hide()
+				g.fixAllLint(issues)
+			}),
+			widget.NewButton("Close", lintDialog.Hide),
+		})
+	}
+	lintDialog.Resize(fyne.NewSize(700, 400))
+	lintDialog.Show()
+}
+
+func (g *GUIApp) applyLintIssue(issue LintIssue) {
+	g.pushJump()
+	g.editor.CursorRow = issue.Line
+	g.editor.CursorColumn = issue.Column
+	g.editor.Refresh()
+	g.window.Canvas().Focus(g.editor)
+
+	if issue.Fix == nil {
+		return
+	}
+
+	g.fixAllLint([]LintIssue{issue})
+}
+
+func (g *GUIApp) exportAs(format exportFormat) {
+	g.guardSecrets(g.document(), func() { g.saveExport(format) })
+}
+
+// saveExport asks where to save the document exported as format.
+func (g *GUIApp) saveExport(format exportFormat) {
+	data, err := exportWith(format, g.document(), g.config.ExportOptions(g.currentFile))
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
 		if err != nil {
 			dialog.ShowError(err, g.window)
 			return
@@ -397,18 +1426,60 @@ func (g *GUIApp) saveAsFile() {
 		}
 		defer writer.Close()
 
-		_, err = writer.Write([]byte(g.editor.Text))
-		if err != nil {
+		if _, err := writer.Write(data); err != nil {
 			dialog.ShowError(err, g.window)
 			return
 		}
+		dialog.ShowInformation("Exported", fmt.Sprintf("Exported to %s", uriPath(writer.URI())), g.window)
+		g.runHooks(g.config.Hooks.AfterExport, "after_export", g.currentFile, uriPath(writer.URI()))
+	}, g.window)
+
+	name := "untitled"
+	if g.currentFile != "" {
+		name = strings.TrimSuffix(filepath.Base(g.currentFile), filepath.Ext(g.currentFile))
+	}
+	saveDialog.SetFileName(name + format.extension)
+	saveDialog.Show()
+}
 
-		g.currentFile = writer.URI().Path()
-		g.fileLabel.SetText(filepath.Base(g.currentFile))
-		g.window.SetTitle(fmt.Sprintf("Parselt - %s", filepath.Base(g.currentFile)))
+func (g *GUIApp) sendEmail() {
+	recipient := widget.NewEntry()
+	recipient.SetPlaceHolder("name@example.com")
 
-		dialog.ShowInformation("Saved", fmt.Sprintf("File saved to %s", g.currentFile), g.window)
-	}, g.window)
+	dialog.ShowForm("Send as Email", "Send", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("To", recipient)},
+		func(ok bool) {
+			if !ok || recipient.Text == "" {
+				return
+			}
+
+			content := g.editor.Text
+			opts := g.config.ExportOptions(g.currentFile)
+			g.guardSecrets(content, func() {
+				go func() {
+					err := SendDocumentEmail(g.config.SMTP, recipient.Text, content, opts)
+					fyne.Do(func() {
+						if err != nil {
+							dialog.ShowError(err, g.window)
+							return
+						}
+						dialog.ShowInformation("Sent", fmt.Sprintf("Email sent to %s", recipient.Text), g.window)
+					})
+				}()
+			})
+		}, g.window)
+}
+
+func (g *GUIApp) convertOrg() {
+	converted := OrgToMarkdown(g.editor.Text)
+	if isOrgFile(g.currentFile) {
+		// Keep the org source untouched; the converted text is saved separately
+		g.currentFile = ""
+		g.watchCurrentFile()
+		g.lockCurrentFile()
+	}
+	g.editor.SetText(converted)
+	g.updateTitle()
 }
 
 func (g *GUIApp) toggleView() {
@@ -421,24 +1492,352 @@ func (g *GUIApp) toggleView() {
 	}
 }
 
+// showManual opens the manual in its own window. Sections are listed on the
+// left, filtered by the search box, and links between them navigate in place.
+func (g *GUIApp) showManual() {
+	sections := loadManual()
+	visible := make([]int, len(sections))
+	for i := range sections {
+		visible[i] = i
+	}
+
+	page := widget.NewRichText()
+	page.Wrapping = fyne.TextWrapWord
+	scroll := container.NewVScroll(page)
+
+	// A processor of its own keeps the pages, whose links are hooked up
+	// below, out of the cache of the document preview
+	smp := NewSharedMarkdownProcessor()
+	var show func(index int)
+	render := func(index int) {
+		smp.RenderFynePreview(page, sections[index].body, nil, nil, nil)
+		hookManualLinks(page.Segments, func(anchor string) {
+			if target := findHelpSection(sections, anchor); target >= 0 {
+				show(target)
+			}
+		})
+		page.Refresh()
+		scroll.ScrollToTop()
+	}
+
+	list := widget.NewList(
+		func() int { return len(visible) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(sections[visible[id]].title)
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		if id < len(visible) {
+			render(visible[id])
+		}
+	}
+
+	show = func(index int) {
+		for row, i := range visible {
+			if i == index {
+				list.Select(row)
+				return
+			}
+		}
+		list.UnselectAll()
+		render(index)
+	}
+
+	search := widget.NewEntry()
+	search.SetPlaceHolder("Search the manual...")
+	search.OnChanged = func(query string) {
+		query = strings.ToLower(strings.TrimSpace(query))
+		visible = visible[:0]
+		for i, section := range sections {
+			if query == "" || strings.Contains(strings.ToLower(section.body), query) {
+				visible = append(visible, i)
+			}
+		}
+		list.UnselectAll()
+		list.Refresh()
+		if len(visible) > 0 {
+			list.Select(0)
+		}
+	}
+
+	split := container.NewHSplit(container.NewBorder(search, nil, nil, nil, list), scroll)
+	split.SetOffset(0.25)
+
+	window := g.app.NewWindow("Parselt Manual")
+	window.SetContent(split)
+	window.Resize(fyne.NewSize(900, 650))
+	window.Canvas().SetOnTypedKey(func(key *fyne.KeyEvent) {
+		if key.Name == fyne.KeyEscape {
+			window.Close()
+		}
+	})
+	list.Select(0)
+	window.Show()
+}
+
+// hookManualLinks makes the manual's "#section" links navigate inside the
+// manual window instead of being handed to the system browser.
+func hookManualLinks(segments []widget.RichTextSegment, follow func(anchor string)) {
+	for _, segment := range segments {
+		switch seg := segment.(type) {
+		case *widget.HyperlinkSegment:
+			if seg.URL != nil && seg.URL.Scheme == "" && seg.URL.Fragment != "" {
+				anchor := seg.URL.Fragment
+				seg.OnTapped = func() { follow(anchor) }
+			}
+		case *widget.ParagraphSegment:
+			hookManualLinks(seg.Texts, follow)
+		case *widget.ListSegment:
+			hookManualLinks(seg.Items, follow)
+		}
+	}
+}
+
+// insertCodeBlock fences the selected or current lines with a language
+// picked from a list.
+func (g *GUIApp) insertCodeBlock() {
+	content := g.editor.Text
+	text := []rune(content)
+	start, end := g.selectedRange()
+	sel := TextRange{Start: len(string(text[:start])), End: len(string(text[:end]))}
+	lineStart := strings.LastIndex(content[:sel.Start], "\n") + 1
+	lineEnd := len(content)
+	if i := strings.Index(content[sel.End:], "\n"); i >= 0 {
+		lineEnd = sel.End + i
+	}
+
+	g.pickLanguage("Insert Code Block", "", DetectLanguage(content[lineStart:lineEnd]), func(lang string) {
+		formatted, result := codeBlockWith(lang)(g.editor.Text, sel)
+		g.editor.SetText(formatted)
+		g.selectRange(result)
+	})
+}
+
+func (g *GUIApp) changeCodeLanguage() {
+	content := g.editor.Text
+	start, _ := g.selectedRange()
+	fence, ok := codeFenceAt(content, len(string([]rune(content)[:start])))
+	if !ok {
+		dialog.ShowInformation("Code Block Language", "The cursor is not in a fenced code block.", g.window)
+		return
+	}
+	g.pickLanguage("Code Block Language", fence.Lang, DetectLanguage(fence.body(content)), func(lang string) {
+		g.setCodeLanguage(fence, lang)
+	})
+}
+
+func (g *GUIApp) setCodeLanguage(fence codeFence, lang string) {
+	row, col := g.editor.CursorRow, g.editor.CursorColumn
+	g.editor.SetText(fence.SetLanguage(g.editor.Text, lang))
+	if row == fence.Open {
+		col = len([]rune(strings.Split(g.editor.Text, "\n")[row]))
+	}
+	g.editor.CursorRow, g.editor.CursorColumn = row, col
+	g.editor.Refresh()
+}
+
+// suggestLanguage offers to set the language of a code block that has none
+// after something was pasted into it.
+func (g *GUIApp) suggestLanguage() {
+	content := g.editor.Text
+	start, _ := g.selectedRange()
+	fence, ok := codeFenceAt(content, len(string([]rune(content)[:start])))
+	if !ok || fence.Lang != "" {
+		return
+	}
+	lang := DetectLanguage(fence.body(content))
+	if lang == "" {
+		return
+	}
+	dialog.ShowConfirm("Code Block Language", fmt.Sprintf("The pasted code looks like %s. Set the language of the code block to %s?", lang, lang),
+		func(ok bool) {
+			if ok {
+				g.setCodeLanguage(fence, lang)
+			}
+		}, g.window)
+}
+
+// pickLanguage shows the searchable list of languages, with the current and
+// the detected one on top, and calls apply with the chosen one.
+func (g *GUIApp) pickLanguage(title, current, detected string, apply func(lang string)) {
+	languages := codeLanguages(current, detected)
+	var visible []codeLanguage
+	filter := func(query string) {
+		query = strings.ToLower(strings.TrimSpace(query))
+		visible = visible[:0]
+		for _, language := range languages {
+			if query == "" || strings.Contains(strings.ToLower(language.tag+" "+language.name), query) {
+				visible = append(visible, language)
+			}
+		}
+	}
+	filter("")
+
+	list := widget.NewList(
+		func() int { return len(visible) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			language := visible[id]
+			text := language.tag + " — " + language.name
+			switch language.tag {
+			case current:
+				text += " (current)"
+			case detected:
+				text += " (detected)"
+			}
+			obj.(*widget.Label).SetText(text)
+		},
+	)
+	search := widget.NewEntry()
+	search.SetPlaceHolder("Search languages...")
+
+	var d dialog.Dialog
+	choose := func(language codeLanguage) {
+		d.Hide()
+		apply(language.tag)
+	}
+	list.OnSelected = func(id widget.ListItemID) { choose(visible[id]) }
+	search.OnChanged = func(query string) {
+		filter(query)
+		list.UnselectAll()
+		list.Refresh()
+	}
+	search.OnSubmitted = func(string) {
+		if len(visible) > 0 {
+			choose(visible[0])
+		}
+	}
+
+	d = dialog.NewCustom(title, "Cancel", container.NewBorder(search, nil, nil, nil, list), g.window)
+	d.Resize(fyne.NewSize(420, 480))
+	d.Show()
+	g.window.Canvas().Focus(search)
+}
+
+// schedulePreview renders the preview once typing pauses, so that long
+// documents do not hold up every key.
+func (g *GUIApp) schedulePreview() {
+	if g.previewTimer != nil {
+		g.previewTimer.Stop()
+	}
+	g.previewTimer = time.AfterFunc(previewDebounce, func() {
+		fyne.Do(func() { g.updatePreview(g.editor.Text) })
+	})
+}
+
+// scheduleStats recounts the document in the footer once typing pauses.
+func (g *GUIApp) scheduleStats() {
+	if g.statsTimer != nil {
+		g.statsTimer.Stop()
+	}
+	g.statsTimer = time.AfterFunc(statsDebounce, func() {
+		fyne.Do(g.updateStats)
+	})
+}
+
+func (g *GUIApp) updateStats() {
+	g.statsButton.SetText(g.mdProcessor.Stats(g.editor.Text).Summary())
+	g.crumbHeadings = g.mdProcessor.Outline(g.editor.Text)
+	g.updateBreadcrumb()
+	if g.docMap.Visible() {
+		g.mapMarks = g.mdProcessor.DocumentMap(g.editor.Text)
+		g.updateDocMap()
+	}
+}
+
+// updateBreadcrumb shows the headings the cursor is under next to the
+// counts. The headings are those of the last count.
+func (g *GUIApp) updateBreadcrumb() {
+	crumb := breadcrumb(g.crumbHeadings, headingPath(g.crumbHeadings, g.editor.CursorRow))
+	if crumb == g.crumbButton.Text {
+		return
+	}
+	g.crumbButton.SetText(crumb)
+	if crumb == "" {
+		g.crumbButton.Hide()
+	} else {
+		g.crumbButton.Show()
+	}
+}
+
+// showHeadingInOutline opens the outline at the heading the cursor is under.
+func (g *GUIApp) showHeadingInOutline() {
+	if !g.outlinePanel.Visible() {
+		g.refreshOutline()
+		g.outlinePanel.Show()
+		g.outlineItem.Checked = true
+	}
+	path := headingPath(g.headings, g.editor.CursorRow)
+	if len(path) == 0 {
+		return
+	}
+	id := strconv.Itoa(path[len(path)-1])
+	g.outline.ScrollTo(id)
+}
+
+func (g *GUIApp) showStats() {
+	form := container.New(layout.NewFormLayout())
+	for _, row := range g.mdProcessor.Stats(g.editor.Text).Rows() {
+		value := widget.NewLabel(row[1])
+		value.Alignment = fyne.TextAlignTrailing
+		form.Add(widget.NewLabelWithStyle(row[0], fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		form.Add(value)
+	}
+	dialog.ShowCustom("Document Statistics", "Close", form, g.window)
+}
+
 func (g *GUIApp) showAbout() {
 	dialog.ShowInformation("About Parselt",
 		"Parselt - Markdown Editor\n\nA simple and elegant markdown editor built with Go and Fyne.\n\nReusing the terminal app's rendering engine for consistency!",
 		g.window)
 }
 
-func (g *GUIApp) Run() {
+// openPath opens a file named on the command line or chosen to open, and
+// puts the cursor at loc. A file that does not exist yet is created on the
+// first save.
+func (g *GUIApp) openPath(path string, loc Location) {
+	content, err := g.docs.ReadDocument(path)
+	if err == nil {
+		err = checkEncoding(path, content)
+	}
+	if errors.Is(err, ErrEncodingUnsupported) {
+		dialog.ShowError(errors.New(errorMessage(err)), g.window)
+		return
+	}
+	if err != nil && !os.IsNotExist(err) {
+		dialog.ShowError(fmt.Errorf("error opening file: %v", err), g.window)
+		return
+	}
+	g.savePosition()
+	g.currentFile = path
+	g.loadConfig()
+	g.watchCurrentFile()
+	g.lockCurrentFile()
+	g.showDocument(string(content))
+	g.markSaved()
+	g.resetHistory()
+	g.offerRecovery()
+	g.followSource()
+	if err == nil {
+		g.restorePosition()
+		g.goTo(loc)
+		g.runHooks(g.config.Hooks.Open, "open", g.currentFile, "")
+	}
+}
+
+// Run shows the window with the first of files; the others are listed under
+// File → Command Line Files.
+func (g *GUIApp) Run(files []string, loc Location) {
+	g.files = files
 	g.setupUI()
+	g.startWatcher()
 
-	if len(os.Args) > 2 && os.Args[1] == "-gui" {
-		filename := os.Args[2]
-		if content, err := os.ReadFile(filename); err == nil {
-			g.editor.SetText(string(content))
-			g.currentFile = filename
-			g.fileLabel.SetText(filepath.Base(filename))
-			g.window.SetTitle(fmt.Sprintf("Parselt - %s", filepath.Base(filename)))
-		}
+	if len(files) > 0 {
+		g.openPath(files[0], loc)
 	}
 
+	g.startAutosave()
+	g.startSwap()
 	g.window.ShowAndRun()
 }
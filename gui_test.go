@@ -0,0 +1,164 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+
+	"fyne.io/fyne/v2/test"
+	"fyne.io/fyne/v2/widget"
+)
+
+// memoryDocuments is a DocumentStore held in memory.
+type memoryDocuments map[string][]byte
+
+func (m memoryDocuments) ReadDocument(path string) ([]byte, error) {
+	content, ok := m[path]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+	}
+	return content, nil
+}
+
+func (m memoryDocuments) WriteDocument(path string, content []byte) error {
+	m[path] = content
+	return nil
+}
+
+// newTestGUI builds the GUI on the test driver, away from the config and
+// state of the user.
+func newTestGUI(t *testing.T, docs memoryDocuments) *GUIApp {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	g := newGUIAppWith(test.NewTempApp(t), docs)
+	g.setupUI()
+	return g
+}
+
+// previewText is the text the preview shows.
+func previewText(g *GUIApp) string {
+	var b strings.Builder
+	for _, segment := range g.preview.Segments {
+		if text, ok := segment.(*widget.TextSegment); ok {
+			b.WriteString(text.Text)
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// settle does at once what the GUI puts off until typing pauses, as the
+// test driver would run it alongside the test.
+func settle(g *GUIApp) {
+	if g.previewTimer != nil && g.previewTimer.Stop() {
+		g.updatePreview(g.editor.Text)
+	}
+	if g.statsTimer != nil && g.statsTimer.Stop() {
+		g.updateStats()
+	}
+}
+
+func TestGUIOpen(t *testing.T) {
+	docs := memoryDocuments{"notes.md": []byte("# Notes\n\nFirst line\n")}
+	g := newTestGUI(t, docs)
+
+	g.openPath("notes.md", Location{})
+	settle(g)
+	if g.currentFile != "notes.md" {
+		t.Errorf("current file = %q, want notes.md", g.currentFile)
+	}
+	if g.editor.Text != "# Notes\n\nFirst line\n" {
+		t.Errorf("editor text = %q", g.editor.Text)
+	}
+	if g.dirty() {
+		t.Error("a document just opened is dirty")
+	}
+}
+
+func TestGUIOpenMissing(t *testing.T) {
+	g := newTestGUI(t, memoryDocuments{})
+
+	g.openPath("new.md", Location{})
+	settle(g)
+	if g.currentFile != "new.md" || g.editor.Text != "" {
+		t.Errorf("opened %q with %q, want an empty new.md", g.currentFile, g.editor.Text)
+	}
+}
+
+func TestGUISave(t *testing.T) {
+	docs := memoryDocuments{"notes.md": []byte("# Notes\n")}
+	g := newTestGUI(t, docs)
+	g.openPath("notes.md", Location{})
+
+	g.editor.SetText("# Notes\n\nMore\n")
+	settle(g)
+	if !g.dirty() {
+		t.Fatal("an edited document is not dirty")
+	}
+	g.saveFile()
+	if got := string(docs["notes.md"]); got != "# Notes\n\nMore\n" {
+		t.Errorf("saved %q", got)
+	}
+	if g.dirty() {
+		t.Error("a saved document is dirty")
+	}
+}
+
+func TestGUIPreview(t *testing.T) {
+	docs := memoryDocuments{"notes.md": []byte("# Title\n\nSome **bold** text and \\*stars\\*\n")}
+	g := newTestGUI(t, docs)
+
+	g.openPath("notes.md", Location{})
+	settle(g)
+	text := previewText(g)
+	for _, want := range []string{"Title", "bold", "*stars*"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("preview %q lacks %q", text, want)
+		}
+	}
+	if strings.Contains(text, "**") || strings.Contains(text, "# ") {
+		t.Errorf("preview %q shows markup", text)
+	}
+}
+
+func TestGUIUpdate(t *testing.T) {
+	docs := memoryDocuments{"notes.md": []byte("# Before\n")}
+	g := newTestGUI(t, docs)
+	g.openPath("notes.md", Location{})
+
+	settle(g)
+	if text := previewText(g); !strings.Contains(text, "Before") {
+		t.Fatalf("preview %q lacks the document", text)
+	}
+
+	g.editor.SetText("# After\n")
+	settle(g)
+	if text := previewText(g); !strings.Contains(text, "After") || strings.Contains(text, "Before") {
+		t.Errorf("preview %q does not follow the edit", text)
+	}
+}
+
+func TestGUIProjectImages(t *testing.T) {
+	docs := memoryDocuments{"notes.md": []byte("# Notes\n")}
+	g := newTestGUI(t, docs)
+	if !g.imageOpts.Optimize || !g.optimizeItem.Checked {
+		t.Fatal("images are not optimized by default")
+	}
+
+	project := "[images]\noptimize = false\nmax_width = 320\n"
+	if err := os.WriteFile(projectConfigName, []byte(project), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g.openPath("notes.md", Location{})
+	if g.imageOpts.Optimize || g.imageOpts.MaxWidth != 320 {
+		t.Errorf("image options = %+v, want those of %s", g.imageOpts, projectConfigName)
+	}
+	if g.optimizeItem.Checked {
+		t.Error("Optimize Embedded Images is still checked")
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/yuin/goldmark/ast"
+)
+
+//go:embed manual.md
+var manualSource string
+
+var anchorStripRe = regexp.MustCompile(`[^\p{L}\p{N}_-]+`)
+
+type helpSection struct {
+	title  string
+	anchor string
+	body   string
+}
+
+type helpLink struct {
+	text   string
+	target string
+	offset int
+}
+
+// loadManual splits the manual into one section per "## " heading. The text
+// before the first of them becomes the start page.
+func loadManual() []helpSection {
+	sections := []helpSection{{title: "Parselt Manual"}}
+	inFence := false
+	var body []string
+	for _, line := range strings.Split(manualSource, "\n") {
+		if fenceRe.MatchString(line) {
+			inFence = !inFence
+		}
+		if !inFence && strings.HasPrefix(line, "## ") {
+			sections[len(sections)-1].body = strings.TrimSpace(strings.Join(body, "\n"))
+			title := strings.TrimSpace(strings.TrimPrefix(line, "## "))
+			sections = append(sections, helpSection{title: title, anchor: helpAnchor(title)})
+			body = nil
+		}
+		body = append(body, line)
+	}
+	sections[len(sections)-1].body = strings.TrimSpace(strings.Join(body, "\n"))
+	return sections
+}
+
+// helpAnchor mirrors goldmark's automatic heading ids.
+func helpAnchor(title string) string {
+	anchor := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(title)), " ", "-")
+	return anchorStripRe.ReplaceAllString(anchor, "")
+}
+
+func findHelpSection(sections []helpSection, anchor string) int {
+	anchor = strings.TrimPrefix(anchor, "#")
+	for i, section := range sections {
+		if section.anchor == anchor {
+			return i
+		}
+	}
+	return -1
+}
+
+// helpLinks lists the links between manual sections in the order they appear.
+func helpLinks(smp *SharedMarkdownProcessor, body string) []helpLink {
+	doc, source := smp.Parse(body)
+	var links []helpLink
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		link, ok := n.(*ast.Link)
+		if !entering || !ok || !strings.HasPrefix(string(link.Destination), "#") {
+			return ast.WalkContinue, nil
+		}
+		text, ok := link.FirstChild().(*ast.Text)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+		links = append(links, helpLink{
+			text:   smp.PlainText(link, source),
+			target: string(link.Destination),
+			offset: text.Segment.Start,
+		})
+		return ast.WalkSkipChildren, nil
+	})
+	return links
+}
+
+type helpBrowser struct {
+	smp      *SharedMarkdownProcessor
+	sections []helpSection
+	current  int
+	history  []int
+	links    []helpLink
+	link     int
+	viewport viewport.Model
+	picker   *picker
+	closeKey key.Binding
+}
+
+func newHelpBrowser(smp *SharedMarkdownProcessor, closeKey key.Binding, width, height int) *helpBrowser {
+	h := &helpBrowser{
+		smp:      smp,
+		closeKey: closeKey,
+		sections: loadManual(),
+		viewport: viewport.New(width, height),
+	}
+	h.resize(width, height)
+	h.show(0)
+	return h
+}
+
+func (h *helpBrowser) resize(width, height int) {
+	h.viewport.Width = width - 6
+	h.viewport.Height = max(height-3, 3)
+	h.render()
+}
+
+func (h *helpBrowser) show(index int) {
+	h.current = index
+	h.links = helpLinks(h.smp, h.sections[index].body)
+	h.link = -1
+	h.render()
+	h.viewport.GotoTop()
+}
+
+// render draws the current section, marking the selected link with an arrow.
+func (h *helpBrowser) render() {
+	if len(h.sections) == 0 {
+		return
+	}
+	body := h.sections[h.current].body
+	if h.link >= 0 && h.link < len(h.links) {
+		offset := h.links[h.link].offset
+		body = body[:offset] + "➜ " + body[offset:]
+	}
+	h.viewport.SetContent(h.smp.RenderTerminal(body, h.viewport.Width+6))
+}
+
+func (h *helpBrowser) follow(target string) {
+	index := findHelpSection(h.sections, target)
+	if index < 0 || index == h.current {
+		return
+	}
+	h.history = append(h.history, h.current)
+	h.show(index)
+}
+
+func (h *helpBrowser) openIndex() {
+	items := make([]pickerItem, len(h.sections))
+	for i, section := range h.sections {
+		items[i] = pickerItem{title: section.title, index: i}
+	}
+	h.picker = newPicker("Manual Index", items)
+}
+
+// openSearch offers every line of the manual, so typing narrows the list to
+// the lines containing the query.
+func (h *helpBrowser) openSearch() {
+	var items []pickerItem
+	for i, section := range h.sections {
+		for _, line := range strings.Split(section.body, "\n") {
+			line = strings.TrimSpace(strings.Trim(line, "#|-` "))
+			if line == "" {
+				continue
+			}
+			items = append(items, pickerItem{title: section.title, detail: line, index: i})
+		}
+	}
+	h.picker = newPicker("Search Manual", items)
+}
+
+// update handles a key press and reports whether the browser was closed.
+func (h *helpBrowser) update(msg tea.KeyMsg) bool {
+	if h.picker != nil {
+		closed, chosen := h.picker.update(msg)
+		if !closed {
+			return false
+		}
+		item, _ := h.picker.selected()
+		h.picker = nil
+		if chosen && item.index != h.current {
+			h.history = append(h.history, h.current)
+			h.show(item.index)
+		}
+		if chosen && item.detail != "" {
+			h.scrollTo(item.detail)
+		}
+		return false
+	}
+
+	if key.Matches(msg, h.closeKey) {
+		return true
+	}
+	switch msg.String() {
+	case "esc", "q":
+		return true
+	case "tab", "shift+tab":
+		if len(h.links) == 0 {
+			return false
+		}
+		if msg.String() == "tab" {
+			h.link = (h.link + 1) % len(h.links)
+		} else {
+			h.link = (h.link - 1 + len(h.links)) % len(h.links)
+		}
+		h.render()
+	case "enter":
+		if h.link >= 0 && h.link < len(h.links) {
+			h.follow(h.links[h.link].target)
+		}
+	case "backspace", "left":
+		if n := len(h.history); n > 0 {
+			index := h.history[n-1]
+			h.history = h.history[:n-1]
+			h.show(index)
+		}
+	case "i":
+		h.openIndex()
+	case "/":
+		h.openSearch()
+	default:
+		h.viewport, _ = h.viewport.Update(msg)
+	}
+	return false
+}
+
+// scrollTo moves the viewport to the rendered line containing text.
+func (h *helpBrowser) scrollTo(text string) {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return
+	}
+	needle := strings.ToLower(words[0])
+	for i, line := range strings.Split(h.smp.RenderTerminal(h.sections[h.current].body, h.viewport.Width+6), "\n") {
+		if strings.Contains(strings.ToLower(ansi.Strip(line)), needle) {
+			h.viewport.SetYOffset(i)
+			return
+		}
+	}
+}
+
+func (h *helpBrowser) view(width, height int) string {
+	if h.picker != nil {
+		return h.picker.view(width, height)
+	}
+
+	section := h.sections[h.current]
+	status := fmt.Sprintf("%s • tab: links • enter: follow • backspace: back • i: index • /: search • esc: close", section.title)
+	if h.link >= 0 && h.link < len(h.links) {
+		status = fmt.Sprintf("➜ %s • enter: follow • backspace: back • esc: close", h.links[h.link].text)
+	}
+	return previewStyle.Padding(0, 2).Render(h.viewport.View()) + "\n" + helpStyle.Render(status)
+}
@@ -0,0 +1,578 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Syntax classes shared by both previews. Chroma has far more token types
+// than either preview can usefully tell apart.
+const (
+	syntaxPlain       = "plain"
+	syntaxKeyword     = "keyword"
+	syntaxString      = "string"
+	syntaxComment     = "comment"
+	syntaxNumber      = "number"
+	syntaxFunction    = "function"
+	syntaxType        = "type"
+	syntaxOperator    = "operator"
+	syntaxPunctuation = "punctuation"
+)
+
+type HighlightToken struct {
+	Text  string
+	Class string
+}
+
+var syntaxColors = map[string]string{
+	syntaxPlain:       "#E0E0E0",
+	syntaxKeyword:     "#FF79C6",
+	syntaxString:      "#F1FA8C",
+	syntaxComment:     "#6272A4",
+	syntaxNumber:      "#BD93F9",
+	syntaxFunction:    "#50FA7B",
+	syntaxType:        "#8BE9FD",
+	syntaxOperator:    "#FF79C6",
+	syntaxPunctuation: "#E0E0E0",
+}
+
+// syntaxLightColors keep the GUI preview readable on the light theme.
+var syntaxLightColors = map[string]string{
+	syntaxKeyword:  "#A626A4",
+	syntaxString:   "#50A14F",
+	syntaxComment:  "#A0A1A7",
+	syntaxNumber:   "#986801",
+	syntaxFunction: "#4078F2",
+	syntaxType:     "#0184BC",
+	syntaxOperator: "#A626A4",
+}
+
+// Highlight splits code into classified tokens for lang. The second result
+// is false when the language is unknown, in which case callers keep their
+// plain code style.
+func (smp *SharedMarkdownProcessor) Highlight(code, lang string) ([]HighlightToken, bool) {
+	if lang == "" {
+		return nil, false
+	}
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		return nil, false
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return nil, false
+	}
+
+	var tokens []HighlightToken
+	for _, token := range iterator.Tokens() {
+		class := syntaxClass(token.Type)
+		if n := len(tokens); n > 0 && tokens[n-1].Class == class {
+			tokens[n-1].Text += token.Value
+			continue
+		}
+		tokens = append(tokens, HighlightToken{Text: token.Value, Class: class})
+	}
+
+	// Most lexers append a newline the code block never had
+	if n := len(tokens); n > 0 && !strings.HasSuffix(code, "\n") {
+		tokens[n-1].Text = strings.TrimSuffix(tokens[n-1].Text, "\n")
+		if tokens[n-1].Text == "" {
+			tokens = tokens[:n-1]
+		}
+	}
+	return tokens, true
+}
+
+// syntaxSchemeTokens are the token types whose colors in a highlighting
+// style stand for each syntax class.
+var syntaxSchemeTokens = map[string]chroma.TokenType{
+	syntaxPlain:       chroma.Text,
+	syntaxKeyword:     chroma.Keyword,
+	syntaxString:      chroma.LiteralString,
+	syntaxComment:     chroma.Comment,
+	syntaxNumber:      chroma.LiteralNumber,
+	syntaxFunction:    chroma.NameFunction,
+	syntaxType:        chroma.KeywordType,
+	syntaxOperator:    chroma.Operator,
+	syntaxPunctuation: chroma.Punctuation,
+}
+
+// schemes keeps the colors of the highlighting styles in use, by name.
+var schemes = struct {
+	sync.Mutex
+	byName map[string]map[string]string
+}{byName: map[string]map[string]string{}}
+
+// schemeColors gives the colors of the highlighting style name by syntax
+// class, or nil when there is no such style. Classes the style leaves to
+// its default are missing.
+func schemeColors(name string) map[string]string {
+	if name == "" {
+		return nil
+	}
+	schemes.Lock()
+	defer schemes.Unlock()
+	if colors, ok := schemes.byName[name]; ok {
+		return colors
+	}
+	var colors map[string]string
+	if style, ok := styles.Registry[strings.ToLower(name)]; ok {
+		colors = map[string]string{}
+		for class, t := range syntaxSchemeTokens {
+			if entry := style.Get(t); entry.Colour.IsSet() {
+				colors[class] = entry.Colour.String()
+			}
+		}
+	}
+	schemes.byName[name] = colors
+	return colors
+}
+
+// codeScheme is the color scheme configured for lang, found by the name of
+// the language or any of its aliases. Empty means the default colors.
+func (smp *SharedMarkdownProcessor) codeScheme(lang string) string {
+	if lang == "" || len(smp.Code.Schemes) == 0 {
+		return ""
+	}
+	lexer := lexers.Get(lang)
+	for name, scheme := range smp.Code.Schemes {
+		if strings.EqualFold(name, lang) {
+			return scheme
+		}
+		if other := lexers.Get(name); lexer != nil && other != nil && other.Config().Name == lexer.Config().Name {
+			return scheme
+		}
+	}
+	return ""
+}
+
+// codeGutter is the margin in front of line n, counted from 0, of a code
+// block of total lines: the line number when they are on, and a bar when
+// the line is highlighted.
+func (smp *SharedMarkdownProcessor) codeGutter(n, total int, highlighted bool) string {
+	gutter := ""
+	if smp.Code.LineNumbers {
+		gutter = fmt.Sprintf("%*d ", len(strconv.Itoa(total)), n+1)
+	}
+	if highlighted {
+		return gutter + "▌ "
+	}
+	return gutter + "  "
+}
+
+func syntaxClass(t chroma.TokenType) string {
+	switch {
+	case t.InCategory(chroma.Comment):
+		return syntaxComment
+	case t.InCategory(chroma.Keyword):
+		if t == chroma.KeywordType {
+			return syntaxType
+		}
+		return syntaxKeyword
+	case t.InCategory(chroma.LiteralString):
+		return syntaxString
+	case t.InCategory(chroma.LiteralNumber):
+		return syntaxNumber
+	case t == chroma.NameFunction || t == chroma.NameFunctionMagic:
+		return syntaxFunction
+	case t == chroma.NameBuiltin || t == chroma.NameClass || t == chroma.NameBuiltinPseudo:
+		return syntaxType
+	case t.InCategory(chroma.Operator):
+		return syntaxOperator
+	case t.InCategory(chroma.Punctuation):
+		return syntaxPunctuation
+	}
+	return syntaxPlain
+}
+
+// highlightTerminal colors the tokens for the code block body, in the colors
+// of scheme where it has them. Every token carries the block background so
+// resets between tokens do not punch holes into it.
+func highlightTerminal(tokens []HighlightToken, scheme string) string {
+	colors := schemeColors(scheme)
+	var out string
+	for _, token := range tokens {
+		color, ok := colors[token.Class]
+		if !ok {
+			color = termSyntaxColors[token.Class]
+		}
+		style := lipgloss.NewStyle().
+			Foreground(lipgloss.Color(color)).
+			Background(termCodeBlockStyle.GetBackground())
+		switch token.Class {
+		case syntaxKeyword:
+			style = style.Bold(true)
+		case syntaxComment:
+			style = style.Italic(true)
+		}
+
+		// Style each line separately so the code block border stays intact
+		start := 0
+		for i := 0; i < len(token.Text); i++ {
+			if token.Text[i] == '\n' {
+				out += style.Render(token.Text[start:i]) + "\n"
+				start = i + 1
+			}
+		}
+		if start < len(token.Text) {
+			out += style.Render(token.Text[start:])
+		}
+	}
+	return out
+}
+
+// syntaxTheme resolves the syntax color names used by highlighted code
+// segments in the GUI preview and defers everything else to the default
+// theme. A "dark" or "light" preference overrides the system variant.
+type syntaxTheme struct {
+	fyne.Theme
+	variant *fyne.ThemeVariant
+}
+
+func newSyntaxTheme(preference string) fyne.Theme {
+	t := &syntaxTheme{Theme: theme.DefaultTheme()}
+	switch preference {
+	case "dark":
+		variant := theme.VariantDark
+		t.variant = &variant
+	case "light":
+		variant := theme.VariantLight
+		t.variant = &variant
+	}
+	return t
+}
+
+// syntaxColorName names the theme color of class in the GUI preview, taken
+// from scheme when it is set.
+func syntaxColorName(class, scheme string) fyne.ThemeColorName {
+	if scheme != "" {
+		return fyne.ThemeColorName("syntax-" + class + "@" + scheme)
+	}
+	return fyne.ThemeColorName("syntax-" + class)
+}
+
+func (t *syntaxTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
+	if t.variant != nil {
+		variant = *t.variant
+	}
+	class, ok := strings.CutPrefix(string(name), "syntax-")
+	if !ok {
+		return t.Theme.Color(name, variant)
+	}
+	class, scheme, _ := strings.Cut(class, "@")
+	hex, ok := schemeColors(scheme)[class]
+	if !ok {
+		if hex, ok = syntaxColors[class]; !ok {
+			return t.Theme.Color(name, variant)
+		}
+		if class == syntaxPlain || class == syntaxPunctuation {
+			return t.Theme.Color(theme.ColorNameForeground, variant)
+		}
+		if light, ok := syntaxLightColors[class]; ok && variant == theme.VariantLight {
+			hex = light
+		}
+	}
+	c := color.NRGBA{A: 0xff}
+	fmt.Sscanf(hex, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	return c
+}
+
+// RenderFynePreview fills the GUI preview, coloring fenced code blocks.
+// Ticking a task list checkbox calls toggle with the index of the task in
+// TaskItems; a nil toggle makes the checkboxes read-only. fill likewise gets
+// the form field chosen or filled in, by its index in FormFields. Images
+// from the web show a placeholder until they are downloaded and loaded is
+// called; with a nil loaded Fyne fetches them while rendering. The returned
+// map gives the index of the segment each source line starts at.
+func (smp *SharedMarkdownProcessor) RenderFynePreview(preview *widget.RichText, content string, toggle func(task int), fill func(field int, value string), loaded func()) ScrollMap {
+	var segments []widget.RichTextSegment
+	if fm := ParseFrontMatter(content); fm != nil {
+		segments = fm.fyneHeader()
+	}
+
+	// Each block is parsed on its own so that its segments can be found, and
+	// kept for the next render unless it has tasks or form fields, which know
+	// their place in the document
+	blocks, _ := smp.renderFyneBlocks(content)
+	smp.fyneCache.next()
+	anchors := ScrollMap{{}}
+	task, field := 0, 0
+	for _, block := range blocks {
+		var parsed []widget.RichTextSegment
+		if cached, ok := smp.fyneCache.get(block.markdown); ok {
+			parsed = cached.([]widget.RichTextSegment)
+		} else {
+			parsed = widget.NewRichTextFromMarkdown(block.markdown).Segments
+			restoreFyneLiterals(parsed)
+			parsed = smp.highlightFyneSegments(parsed, block.code)
+			pending := false
+			if loaded != nil {
+				parsed, pending = remoteImageSegments(parsed, loaded)
+			}
+			tasks := strings.ContainsAny(block.markdown, string([]rune{taskUnchecked, taskChecked}))
+			fields := strings.ContainsAny(block.markdown, fieldChars)
+			if tasks {
+				parsed = taskSegments(parsed, toggle, &task)
+			}
+			if fields {
+				parsed = formSegments(parsed, fill, &field)
+			}
+			if !tasks && !fields && !pending {
+				smp.fyneCache.put(block.markdown, parsed)
+			}
+		}
+		if block.line >= 0 {
+			anchors = append(anchors, ScrollAnchor{Source: block.line, Rendered: len(segments)})
+		}
+		segments = append(segments, parsed...)
+	}
+	preview.Segments = segments
+	preview.Refresh()
+	return append(anchors, ScrollAnchor{Source: sourceLineCount(content), Rendered: len(segments)})
+}
+
+// highlightFyneSegments replaces the code block segments produced by
+// RichText.ParseMarkdown with per-token colored segments. Fyne drops the
+// fence language, so blocks are matched back to their source by content.
+func (smp *SharedMarkdownProcessor) highlightFyneSegments(segments []widget.RichTextSegment, blocks []fyneCodeBlock) []widget.RichTextSegment {
+	var out []widget.RichTextSegment
+	for _, segment := range segments {
+		switch seg := segment.(type) {
+		case *widget.TextSegment:
+			if seg.Style != widget.RichTextStyleCodeBlock {
+				break
+			}
+			for i, block := range blocks {
+				if block.used || block.code != strings.TrimRight(seg.Text, "\n") {
+					continue
+				}
+				blocks[i].used = true
+				if code := smp.fyneCodeSegments(block); code != nil {
+					out = append(out, code...)
+					segment = nil
+				}
+				break
+			}
+		case *widget.ListSegment:
+			for i, item := range seg.Items {
+				if highlighted := smp.highlightFyneSegments([]widget.RichTextSegment{item}, blocks); len(highlighted) == 1 {
+					seg.Items[i] = highlighted[0]
+				}
+			}
+		case *widget.ParagraphSegment:
+			seg.Texts = smp.highlightFyneSegments(seg.Texts, blocks)
+		}
+		if segment != nil {
+			out = append(out, segment)
+		}
+	}
+	return out
+}
+
+// fyneCodeSegments shows a code block in the GUI preview: highlighted,
+// behind a gutter when it has line numbers or highlighted lines, with a copy
+// button and scrolling inside the preview when it is taller than the
+// configured maximum. A qrcode block shows its code instead, and a diagram,
+// chart, timeline or calendar block its drawing. Nil leaves the block, a table, to Fyne's own plain
+// rendering.
+func (smp *SharedMarkdownProcessor) fyneCodeSegments(block fyneCodeBlock) []widget.RichTextSegment {
+	if block.table {
+		return nil
+	}
+	if block.lang == "qrcode" {
+		if q, err := EncodeQR(qrText(block.code)); err == nil {
+			return []widget.RichTextSegment{&qrSegment{code: q, text: qrText(block.code)}}
+		}
+	}
+	if block.lang == "chart" {
+		if c, err := ParseChart(block.code); err == nil {
+			return []widget.RichTextSegment{&drawingSegment{draw: func(fg color.Color) image.Image { return c.Image(fg) }, text: block.code}}
+		}
+	}
+	if block.lang == "calendar" {
+		if c, err := ParseCalendar(block.code, time.Now(), func() []DueTask { return WorkspaceDueTasks(smp) }); err == nil {
+			return []widget.RichTextSegment{&drawingSegment{draw: func(fg color.Color) image.Image { return c.Image(fg) }, text: block.code}}
+		}
+	}
+	if native, ok := ganttSyntax(block.lang, block.code); ok {
+		if g, err := ParseGantt(block.code, native); err == nil {
+			return []widget.RichTextSegment{&drawingSegment{draw: func(fg color.Color) image.Image { return g.Image(fg) }, text: block.code}}
+		}
+	}
+	if diagramLanguages[block.lang] {
+		d := ParseDiagram(block.code)
+		return []widget.RichTextSegment{&drawingSegment{draw: func(fg color.Color) image.Image { return d.Image(fg) }, text: block.code}}
+	}
+	tokens, ok := smp.Highlight(block.code, block.lang)
+	if !ok {
+		tokens = []HighlightToken{{Text: block.code, Class: syntaxPlain}}
+	}
+	gutter := smp.Code.LineNumbers || len(block.highlighted) > 0
+	lines := strings.Count(block.code, "\n") + 1
+
+	var segments []widget.RichTextSegment
+	for i, line := range tokenLines(tokens) {
+		if i > 0 {
+			segments[len(segments)-1].(*widget.TextSegment).Text += "\n"
+		}
+		if gutter {
+			style := widget.RichTextStyleCodeBlock
+			style.ColorName = theme.ColorNamePlaceHolder
+			if block.highlighted[i] {
+				style.ColorName = theme.ColorNamePrimary
+			}
+			style.Inline = true
+			segments = append(segments, &widget.TextSegment{Style: style, Text: smp.codeGutter(i, lines, block.highlighted[i])})
+		}
+		segments = append(segments, fyneTokenSegments(line, smp.codeScheme(block.lang))...)
+	}
+	segments[len(segments)-1].(*widget.TextSegment).Style.Inline = false
+	code := &codeBlockSegment{segments: segments, code: block.code}
+	if smp.Code.MaxHeight > 0 && lines > smp.Code.MaxHeight {
+		code.maxLines = smp.Code.MaxHeight
+	}
+	return []widget.RichTextSegment{code}
+}
+
+// tokenLines splits tokens at the line breaks. Every line keeps at least one
+// token, if only an empty one.
+func tokenLines(tokens []HighlightToken) [][]HighlightToken {
+	lines := [][]HighlightToken{nil}
+	for _, token := range tokens {
+		for i, part := range strings.Split(token.Text, "\n") {
+			if i > 0 {
+				lines = append(lines, nil)
+			}
+			if part != "" {
+				lines[len(lines)-1] = append(lines[len(lines)-1], HighlightToken{Text: part, Class: token.Class})
+			}
+		}
+	}
+	for i := range lines {
+		if len(lines[i]) == 0 {
+			lines[i] = []HighlightToken{{Class: syntaxPlain}}
+		}
+	}
+	return lines
+}
+
+func fyneTokenSegments(tokens []HighlightToken, scheme string) []widget.RichTextSegment {
+	var segments []widget.RichTextSegment
+	for _, token := range tokens {
+		style := widget.RichTextStyleCodeBlock
+		style.ColorName = syntaxColorName(token.Class, scheme)
+		// Fyne ships no bold or italic monospace face, so color alone it is
+		style.Inline = true
+		segments = append(segments, &widget.TextSegment{Style: style, Text: token.Text})
+	}
+	return segments
+}
+
+// codeBlockSegment is a code block of the GUI preview. With maxLines set it
+// scrolls inside the preview.
+type codeBlockSegment struct {
+	segments []widget.RichTextSegment
+	code     string
+	maxLines int
+}
+
+func (s *codeBlockSegment) Inline() bool {
+	return false
+}
+
+func (s *codeBlockSegment) Textual() string {
+	return s.code
+}
+
+func (s *codeBlockSegment) Visual() fyne.CanvasObject {
+	view := newCodeBlockView()
+	s.Update(view)
+	return view
+}
+
+func (s *codeBlockSegment) Update(o fyne.CanvasObject) {
+	o.(*codeBlockView).show(s)
+}
+
+func (s *codeBlockSegment) Select(begin, end fyne.Position) {}
+
+func (s *codeBlockSegment) SelectedText() string {
+	return ""
+}
+
+func (s *codeBlockSegment) Unselect() {}
+
+// codeBlockView draws a code block of the GUI preview, with a button in the
+// corner that copies the code while the mouse is over the block. The button
+// takes taps but not the hover, so that reaching for it keeps it shown.
+type codeBlockView struct {
+	widget.BaseWidget
+	code    *widget.RichText
+	scroll  *container.Scroll
+	icon    *widget.Icon
+	button  *tapArea
+	content *fyne.Container
+	text    string
+}
+
+func newCodeBlockView() *codeBlockView {
+	v := &codeBlockView{code: widget.NewRichText(), icon: widget.NewIcon(theme.ContentCopyIcon())}
+	v.code.Wrapping = fyne.TextWrapWord
+	v.scroll = container.NewVScroll(v.code)
+	v.button = newTapArea(v.icon, func(fyne.Position) {
+		fyne.CurrentApp().Clipboard().SetContent(v.text)
+		v.icon.SetResource(theme.ConfirmIcon())
+	})
+	v.button.Hide()
+	corner := container.NewBorder(container.NewHBox(layout.NewSpacer(), v.button), nil, nil, nil)
+	v.content = container.NewStack(v.code, corner)
+	v.ExtendBaseWidget(v)
+	return v
+}
+
+// show puts the code of s in the view, in a scroll of the maximum height
+// when it has one.
+func (v *codeBlockView) show(s *codeBlockSegment) {
+	v.text = s.code
+	v.code.Segments = s.segments
+	v.content.Objects[0] = v.code
+	if s.maxLines > 0 {
+		line := fyne.MeasureText("M", theme.TextSize(), fyne.TextStyle{Monospace: true}).Height + theme.LineSpacing()
+		v.scroll.SetMinSize(fyne.NewSize(0, float32(s.maxLines)*line+2*theme.InnerPadding()))
+		v.content.Objects[0] = v.scroll
+	}
+	v.code.Refresh()
+	v.content.Refresh()
+}
+
+func (v *codeBlockView) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(v.content)
+}
+
+func (v *codeBlockView) MouseIn(*desktop.MouseEvent) {
+	v.icon.SetResource(theme.ContentCopyIcon())
+	v.button.Show()
+}
+
+func (v *codeBlockView) MouseMoved(*desktop.MouseEvent) {}
+
+func (v *codeBlockView) MouseOut() {
+	v.button.Hide()
+}
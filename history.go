@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// historyLimit is how many undo steps a buffer keeps.
+const historyLimit = 500
+
+// historyMergeWindow groups keystrokes typed in quick succession into a
+// single undo step.
+const historyMergeWindow = time.Second
+
+// historyEdit replaces removed with inserted at byte offset pos.
+type historyEdit struct {
+	pos      int
+	removed  string
+	inserted string
+	at       time.Time
+}
+
+// History is the undo and redo stack of one document. Both editors report
+// their text with Record after every change; only the changed part is kept,
+// so a step costs about as much memory as the edit itself.
+type History struct {
+	text string
+	undo []historyEdit
+	redo []historyEdit
+}
+
+// NewHistory starts an empty history for a document with content.
+func NewHistory(content string) *History {
+	return &History{text: content}
+}
+
+// Record adds the change since the last recorded text as an undo step and
+// forgets anything that could be redone. Typing or deleting characters one
+// after the other is merged into a step per word.
+func (h *History) Record(content string) {
+	before, after := h.text, content
+	if before == after {
+		return
+	}
+	h.text = content
+
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix &&
+		before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+	// Keep the edit on rune boundaries
+	for prefix > 0 && prefix < len(before) && !utf8.RuneStart(before[prefix]) {
+		prefix--
+	}
+	for suffix > 0 && !utf8.RuneStart(before[len(before)-suffix]) {
+		suffix--
+	}
+
+	edit := historyEdit{
+		pos:      prefix,
+		removed:  before[prefix : len(before)-suffix],
+		inserted: after[prefix : len(after)-suffix],
+		at:       time.Now(),
+	}
+	h.redo = nil
+	if n := len(h.undo); n > 0 && h.undo[n-1].merge(edit) {
+		return
+	}
+	h.undo = append(h.undo, edit)
+	if len(h.undo) > historyLimit {
+		h.undo = h.undo[len(h.undo)-historyLimit:]
+	}
+}
+
+// merge folds next into e when it continues typing or deleting where e left
+// off. A new word starts a new step.
+func (e *historyEdit) merge(next historyEdit) bool {
+	if next.at.Sub(e.at) > historyMergeWindow || strings.Contains(next.inserted+next.removed, "\n") {
+		return false
+	}
+	switch {
+	case e.removed == "" && next.removed == "" && next.pos == e.pos+len(e.inserted):
+		if wordStart(e.inserted, next.inserted) {
+			return false
+		}
+		e.inserted += next.inserted
+	case e.inserted == "" && next.inserted == "" && next.pos+len(next.removed) == e.pos:
+		// Backspace
+		if wordStart(next.removed, e.removed) {
+			return false
+		}
+		e.pos = next.pos
+		e.removed = next.removed + e.removed
+	case e.inserted == "" && next.inserted == "" && next.pos == e.pos:
+		// Delete
+		if wordStart(e.removed, next.removed) {
+			return false
+		}
+		e.removed += next.removed
+	default:
+		return false
+	}
+	e.at = next.at
+	return true
+}
+
+// wordStart reports whether text begins a new word after previous.
+func wordStart(previous, text string) bool {
+	last, _ := utf8.DecodeLastRuneInString(previous)
+	first, _ := utf8.DecodeRuneInString(text)
+	return unicode.IsSpace(last) && !unicode.IsSpace(first)
+}
+
+// Undo reverts the last step of content and returns the result with the
+// byte offset of the cursor after it.
+func (h *History) Undo(content string) (string, int, error) {
+	h.Record(content)
+	if len(h.undo) == 0 {
+		return content, 0, fmt.Errorf("nothing to undo")
+	}
+	edit := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, edit)
+	h.text = content[:edit.pos] + edit.removed + content[edit.pos+len(edit.inserted):]
+	h.seal()
+	return h.text, edit.pos + len(edit.removed), nil
+}
+
+// Redo applies the last undone step again.
+func (h *History) Redo(content string) (string, int, error) {
+	h.Record(content)
+	if len(h.redo) == 0 {
+		return content, 0, fmt.Errorf("nothing to redo")
+	}
+	edit := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, edit)
+	h.text = content[:edit.pos] + edit.inserted + content[edit.pos+len(edit.removed):]
+	h.seal()
+	return h.text, edit.pos + len(edit.inserted), nil
+}
+
+// seal keeps the next edit from merging into the step before it.
+func (h *History) seal() {
+	if n := len(h.undo); n > 0 {
+		h.undo[n-1].at = time.Time{}
+	}
+}
+
+func (m *model) undo() {
+	m.applyHistory(m.history.Undo)
+}
+
+func (m *model) redo() {
+	m.applyHistory(m.history.Redo)
+}
+
+func (m *model) applyHistory(step func(content string) (string, int, error)) {
+	content, offset, err := step(m.document())
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	m.clearSelection()
+	m.setDocument(content)
+	row, col := rowColumn(content, offset)
+	moveCursorTo(&m.textarea, row-m.narrow.lineOffset(), col)
+	if m.mode == splitMode {
+		m.refreshPreview()
+		m.syncPreviewScroll()
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// hookTimeout stops hooks that hang, so that a save never waits forever.
+const hookTimeout = 30 * time.Second
+
+// HooksConfig lists shell commands to run on events. before_save commands
+// are filters: they get the document on stdin, and what they print is saved
+// instead; with Review set, what they change is shown to accept or skip
+// first. The others run in the background once the event happened.
+type HooksConfig struct {
+	Open        []string `toml:"open"`
+	BeforeSave  []string `toml:"before_save"`
+	AfterSave   []string `toml:"after_save"`
+	AfterExport []string `toml:"after_export"`
+	Review      bool     `toml:"review"`
+}
+
+// hookCommand runs command through the shell in the directory of file, with
+// the event, the files it concerns and env in the environment.
+func hookCommand(ctx context.Context, command, event, file, output string, env ...string) *exec.Cmd {
+	cmd := shellCommand(ctx, command)
+	if file != "" {
+		cmd.Dir = filepath.Dir(file)
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+	}
+	cmd.Env = append(os.Environ(), "PARSELT_EVENT="+event, "PARSELT_FILE="+file)
+	if output != "" {
+		if abs, err := filepath.Abs(output); err == nil {
+			output = abs
+		}
+		cmd.Env = append(cmd.Env, "PARSELT_OUTPUT="+output)
+	}
+	cmd.Env = append(cmd.Env, env...)
+	return cmd
+}
+
+// RunHooks runs the commands for event one after the other and stops at the
+// first that fails.
+func RunHooks(commands []string, event, file, output string) error {
+	for _, command := range commands {
+		ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+		out, err := hookCommand(ctx, command, event, file, output).CombinedOutput()
+		cancel()
+		if err != nil {
+			return hookError(event, command, err, out)
+		}
+	}
+	return nil
+}
+
+// FilterBeforeSave passes content through the before_save hooks in turn and
+// returns what should be saved. A failing hook stops the save.
+func (h HooksConfig) FilterBeforeSave(file, content string) (string, error) {
+	for _, command := range h.BeforeSave {
+		ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+		cmd := hookCommand(ctx, command, "before_save", file, "")
+		var stdout, stderr bytes.Buffer
+		cmd.Stdin = strings.NewReader(content)
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		err := cmd.Run()
+		cancel()
+		if err != nil {
+			return content, hookError("before_save", command, err, stderr.Bytes())
+		}
+		content = stdout.String()
+	}
+	return content, nil
+}
+
+func hookError(event, command string, err error, output []byte) error {
+	if message := strings.TrimSpace(string(output)); message != "" {
+		lines := strings.Split(message, "\n")
+		return fmt.Errorf("%s hook %q failed: %s", event, command, lines[len(lines)-1])
+	}
+	return fmt.Errorf("%s hook %q failed: %v", event, command, err)
+}
+
+// hooksCmd runs hooks in the background; a failure ends up in the status line.
+func hooksCmd(commands []string, event, file, output string) tea.Cmd {
+	if len(commands) == 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		if err := RunHooks(commands, event, file, output); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// filterBeforeSave runs the before_save hooks for a save to file and puts
+// their result into the editor. It reports whether the save can go ahead.
+func (g *GUIApp) filterBeforeSave(file string) bool {
+	text := g.document()
+	content, err := g.config.Hooks.FilterBeforeSave(file, text)
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return false
+	}
+	if content != text {
+		row, col := g.editor.CursorRow, g.editor.CursorColumn
+		g.setDocument(content)
+		g.editor.CursorRow, g.editor.CursorColumn = row, col
+		g.editor.Refresh()
+	}
+	return true
+}
+
+// runHooks runs hooks in the background and reports a failure in a dialog.
+func (g *GUIApp) runHooks(commands []string, event, file, output string) {
+	if len(commands) == 0 {
+		return
+	}
+	go func() {
+		if err := RunHooks(commands, event, file, output); err != nil {
+			fyne.Do(func() {
+				dialog.ShowError(err, g.window)
+			})
+		}
+	}()
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"testing"
+)
+
+// TestHookQuotedArguments runs a quoted program with quoted arguments, which
+// the shell has to get as they are written.
+func TestHookQuotedArguments(t *testing.T) {
+	command, want := `"printf" '%s|' "a  b" 'c d'`, "a  b|c d|"
+	if runtime.GOOS == "windows" {
+		command, want = `"%COMSPEC%" /C echo "a  b"`, "\"a  b\"\r\n"
+	}
+	out, err := hookCommand(context.Background(), command, "test", "", "").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != want {
+		t.Errorf("printed %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// shellCommand runs command through cmd.exe. Arguments would be quoted the
+// way most programs read them, with backslashes before quotes, which
+// cmd.exe keeps as they are, so the command line is written out instead.
+// With /S, cmd.exe drops the outer quotes and runs what is between them
+// unchanged; /V:ON lets !NAME! expand variables.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "cmd")
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: `cmd /V:ON /S /C "` + command + `"`}
+	return cmd
+}
+
+// shellVar is the value of the environment variable name as one argument.
+// Unlike %NAME%, !NAME! expands once cmd.exe has read the command line, so
+// quotes, & or | in the value stay text.
+func shellVar(name string) string {
+	return `"!` + name + `!"`
+}
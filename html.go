@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const htmlBaseCSS = `body{max-width:46em;margin:2em auto;padding:0 1em;font-family:-apple-system,"Segoe UI",Helvetica,Arial,sans-serif;font-size:16px;line-height:1.6;}
+h1,h2,h3,h4,h5,h6{line-height:1.25;margin:1.6em 0 .6em;}
+h1{font-size:2em;}h2{font-size:1.5em;padding-bottom:.3em;}
+a{text-decoration:underline;}
+img{max-width:100%;height:auto;}
+pre{padding:1em;overflow:auto;border-radius:6px;font-size:.875em;line-height:1.45;}
+code{font-family:Consolas,Menlo,monospace;font-size:.875em;padding:.15em .3em;border-radius:4px;}
+pre code{padding:0;font-size:1em;background:none;}
+blockquote{margin:0 0 1em;padding:0 1em;border-left:4px solid;}
+table{border-collapse:collapse;margin:0 0 1em;}
+th,td{padding:6px 12px;border:1px solid;}
+hr{border:0;border-top:1px solid;margin:2em 0;}
+`
+
+var htmlThemes = map[string]string{
+	"light": `body{color:#24292f;background:#ffffff;}
+a{color:#7D56F4;}
+h2{border-bottom:1px solid #d8dee4;}
+pre,code{background:#f6f8fa;}
+blockquote{color:#57606a;border-color:#d0d7de;}
+th,td{border-color:#d0d7de;}th{background:#f6f8fa;}
+hr{border-color:#d8dee4;}
+`,
+	"dark": `body{color:#e6edf3;background:#0d1117;}
+a{color:#b39dff;}
+h2{border-bottom:1px solid #30363d;}
+pre,code{background:#161b22;}
+blockquote{color:#8b949e;border-color:#30363d;}
+th,td{border-color:#30363d;}th{background:#161b22;}
+hr{border-color:#30363d;}
+`,
+}
+
+var htmlCodeRe = regexp.MustCompile(`(?s)<pre><code class="language-([^"]+)">(.*?)</code></pre>`)
+
+// htmlThemeCSS returns the stylesheet for a built-in theme name or, when the
+// theme is a path to a .css file, that file's contents.
+func htmlThemeCSS(theme string) (string, error) {
+	if strings.HasSuffix(theme, ".css") {
+		data, err := os.ReadFile(theme)
+		if err != nil {
+			return "", fmt.Errorf("error reading theme: %v", err)
+		}
+		return string(data), nil
+	}
+	if theme == "" {
+		theme = "light"
+	}
+	css, ok := htmlThemes[theme]
+	if !ok {
+		return "", fmt.Errorf("unknown theme %q (available: light, dark or a .css file)", theme)
+	}
+
+	colors := syntaxColors
+	if theme == "light" {
+		colors = make(map[string]string, len(syntaxColors))
+		for class, color := range syntaxColors {
+			colors[class] = color
+		}
+		for class, color := range syntaxLightColors {
+			colors[class] = color
+		}
+		colors[syntaxPlain] = "#24292f"
+		colors[syntaxPunctuation] = "#24292f"
+	}
+	classes := make([]string, 0, len(colors))
+	for class := range colors {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		css += fmt.Sprintf(".tok-%s{color:%s;}\n", class, colors[class])
+	}
+	return css, nil
+}
+
+// ExportHTML renders a self-contained HTML page: the stylesheet is embedded,
+// code blocks are highlighted, qrcode, diagram, chart, timeline and calendar
+// blocks drawn as SVG and local images are inlined as data URIs.
+func ExportHTML(content string, opts ExportOptions) ([]byte, error) {
+	smp := opts.processor()
+	css, err := htmlThemeCSS(opts.Theme)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := smp.RenderHTML(content)
+	if err != nil {
+		return nil, err
+	}
+	body = ganttHTML(chartHTML(diagramHTML(qrHTML(body))))
+	body = calendarHTML(body, smp, time.Now())
+
+	body = htmlCodeRe.ReplaceAllStringFunc(body, func(match string) string {
+		parts := htmlCodeRe.FindStringSubmatch(match)
+		tokens, ok := smp.Highlight(html.UnescapeString(parts[2]), parts[1])
+		if !ok {
+			return match
+		}
+		var buf strings.Builder
+		fmt.Fprintf(&buf, `<pre><code class="language-%s">`, parts[1])
+		for _, token := range tokens {
+			fmt.Fprintf(&buf, `<span class="tok-%s">%s</span>`, token.Class, htmlEscape(token.Text))
+		}
+		buf.WriteString("</code></pre>")
+		return buf.String()
+	})
+
+	baseDir := "."
+	if opts.SourcePath != "" {
+		baseDir = filepath.Dir(opts.SourcePath)
+	}
+	body = emailImageRe.ReplaceAllStringFunc(body, func(match string) string {
+		parts := emailImageRe.FindStringSubmatch(match)
+		src := parts[2]
+		if isURL(src) {
+			return match
+		}
+
+		path := src
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, filepath.FromSlash(src))
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return match
+		}
+		contentType := mime.TypeByExtension(filepath.Ext(path))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		return parts[1] + "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data) + parts[3]
+	})
+
+	var buf strings.Builder
+	buf.WriteString("<!DOCTYPE html>\n<html>\n<head>\n")
+	buf.WriteString(`<meta charset="utf-8">` + "\n")
+	buf.WriteString(`<meta name="viewport" content="width=device-width, initial-scale=1.0">` + "\n")
+	fmt.Fprintf(&buf, "<title>%s</title>\n", htmlEscape(opts.Title))
+	buf.WriteString("<style>\n" + htmlBaseCSS + css + "</style>\n")
+	buf.WriteString("</head>\n<body>\n")
+	buf.WriteString(body)
+	buf.WriteString("</body>\n</html>\n")
+	return []byte(buf.String()), nil
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".webp"}
+
+// ImageOptions is the [images] table: how embedded images are scaled down
+// to MaxWidth pixels and re-compressed, unless Optimize is off.
+type ImageOptions struct {
+	Optimize bool   `toml:"optimize"`
+	MaxWidth int    `toml:"max_width"`
+	Quality  int    `toml:"quality"`
+	Format   string `toml:"format"` // "png", "jpeg" or "" to keep the source format
+}
+
+func DefaultImageOptions() ImageOptions {
+	return ImageOptions{
+		Optimize: true,
+		MaxWidth: 1600,
+		Quality:  85,
+	}
+}
+
+type EmbeddedImage struct {
+	Path         string
+	OriginalSize int64
+	FinalSize    int64
+}
+
+func (e EmbeddedImage) Markdown(alt string) string {
+	return fmt.Sprintf("![%s](%s)", alt, filepath.ToSlash(e.Path))
+}
+
+func (e EmbeddedImage) Report() string {
+	if e.FinalSize >= e.OriginalSize {
+		return fmt.Sprintf("Embedded %s (%s)", e.Path, formatBytes(e.FinalSize))
+	}
+	saved := e.OriginalSize - e.FinalSize
+	percent := float64(saved) * 100 / float64(e.OriginalSize)
+	return fmt.Sprintf("Embedded %s (%s → %s, saved %.0f%%)",
+		e.Path, formatBytes(e.OriginalSize), formatBytes(e.FinalSize), percent)
+}
+
+func IsImageFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, imageExt := range imageExtensions {
+		if ext == imageExt {
+			return true
+		}
+	}
+	return false
+}
+
+// EmbedImage copies src into an assets directory next to the document and
+// returns the path relative to the document.
+func EmbedImage(src, docPath string, opts ImageOptions) (EmbeddedImage, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return EmbeddedImage{}, fmt.Errorf("error reading image: %v", err)
+	}
+
+	out := data
+	ext := strings.ToLower(filepath.Ext(src))
+	if opts.Optimize {
+		if optimized, newExt, err := optimizeImage(data, ext, opts); err == nil && len(optimized) < len(data) {
+			out = optimized
+			ext = newExt
+		}
+	}
+
+	docDir := "."
+	if docPath != "" {
+		docDir = filepath.Dir(docPath)
+	}
+	assetsDir := filepath.Join(docDir, "assets")
+	if err := os.MkdirAll(assetsDir, 0755); err != nil {
+		return EmbeddedImage{}, fmt.Errorf("error creating assets directory: %v", err)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	dest := uniquePath(filepath.Join(assetsDir, base+ext))
+	if err := os.WriteFile(dest, out, 0644); err != nil {
+		return EmbeddedImage{}, fmt.Errorf("error writing image: %v", err)
+	}
+
+	rel, err := filepath.Rel(docDir, dest)
+	if err != nil {
+		rel = dest
+	}
+
+	return EmbeddedImage{
+		Path:         rel,
+		OriginalSize: int64(len(data)),
+		FinalSize:    int64(len(out)),
+	}, nil
+}
+
+func optimizeImage(data []byte, ext string, opts ImageOptions) ([]byte, string, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	switch format {
+	case "gif":
+		// Only the first frame would be left of an animation
+		if anim, err := gif.DecodeAll(bytes.NewReader(data)); err == nil && len(anim.Image) > 1 {
+			return data, ext, nil
+		}
+	case "jpeg":
+		// The encoder writes no EXIF, so the pixels are turned instead
+		img = orientImage(img, jpegOrientation(data))
+	}
+
+	bounds := img.Bounds()
+	if opts.MaxWidth > 0 && bounds.Dx() > opts.MaxWidth {
+		height := bounds.Dy() * opts.MaxWidth / bounds.Dx()
+		scaled := image.NewRGBA(image.Rect(0, 0, opts.MaxWidth, height))
+		draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, bounds, draw.Over, nil)
+		img = scaled
+	}
+
+	target := opts.Format
+	if target == "" {
+		target = format
+	}
+
+	var buf bytes.Buffer
+	switch target {
+	case "jpeg", "jpg":
+		quality := opts.Quality
+		if quality <= 0 || quality > 100 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+		ext = ".jpg"
+	default:
+		// gif and webp sources are re-encoded as png since the standard
+		// library has no encoder for them
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		err = encoder.Encode(&buf, img)
+		ext = ".png"
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), ext, nil
+}
+
+// jpegOrientation is the EXIF orientation of a JPEG, from 1 for upright to
+// 8, or 1 when it has none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xDA || size < 2 || i+2+size > len(data) {
+			// The image data starts, and no more metadata
+			break
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// exifOrientation finds the orientation tag in the first directory of the
+// TIFF structure that holds EXIF data.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	dir := int(order.Uint32(tiff[4:]))
+	if dir < 8 || dir+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[dir:]))
+	for entry := dir + 2; count > 0 && entry+12 <= len(tiff); entry, count = entry+12, count-1 {
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
+
+// orientImage turns and flips img upright as EXIF orientation says: 2 to 4
+// mirror or turn it half way, 5 to 8 swap its width and height.
+func orientImage(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if orientation >= 5 {
+		dst = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+	for y := range h {
+		for x := range w {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(x, y):][:4])
+		}
+	}
+	return dst
+}
+
+func uniquePath(path string) string {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return path
+	}
+
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, i, ext)
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+func formatBytes(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
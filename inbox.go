@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxInboxEntry is the most a single POST to the inbox may carry.
+const maxInboxEntry = 1 << 20
+
+// InboxConfig is the [inbox] table. With a token set, the preview server
+// takes POSTs to /inbox and appends their text to File, relative to the
+// working directory.
+type InboxConfig struct {
+	File  string `toml:"file"`
+	Token string `toml:"token"`
+}
+
+func (c InboxConfig) path() string {
+	return expandHome(c.File)
+}
+
+// inboxMu keeps entries that arrive together from interleaving.
+var inboxMu sync.Mutex
+
+// AppendInbox adds text to the inbox note under a heading with the time it
+// came in and its title, if any.
+func (c InboxConfig) AppendInbox(title, text string, now time.Time) error {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return fmt.Errorf("nothing to add to the inbox")
+	}
+	heading := "## " + now.Format("2006-01-02 15:04")
+	if title = strings.Join(strings.Fields(title), " "); title != "" {
+		heading += " " + title
+	}
+
+	inboxMu.Lock()
+	defer inboxMu.Unlock()
+	path := c.path()
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading inbox: %v", err)
+	}
+	var entry strings.Builder
+	if len(existing) > 0 {
+		if !strings.HasSuffix(string(existing), "\n") {
+			entry.WriteString("\n")
+		}
+		entry.WriteString("\n")
+	}
+	entry.WriteString(heading + "\n\n" + text + "\n")
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error writing inbox: %v", err)
+		}
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing inbox: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(entry.String()); err != nil {
+		return fmt.Errorf("error writing inbox: %v", err)
+	}
+	return nil
+}
+
+// bearerToken is the token of the Authorization header of r, if it has one.
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")), true
+}
+
+func (c InboxConfig) tokenMatches(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.Token)) == 1
+}
+
+// serveInbox takes a POST of text, plain or markdown, or of a form with a
+// text field and an optional title, and appends it to the inbox note. The
+// token comes as a bearer token, checked before the body is read, or as a
+// token field of the form for clients that cannot set headers; never in the
+// query, which ends up in logs and shell history.
+func (s *PreviewServer) serveInbox(w http.ResponseWriter, r *http.Request) {
+	if s.inbox.Token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "POST text to add it to the inbox", http.StatusMethodNotAllowed)
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	form := mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+	token, bearer := bearerToken(r)
+	if (bearer && !s.inbox.tokenMatches(token)) || (!bearer && !form) {
+		http.Error(w, "wrong or missing token", http.StatusUnauthorized)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxInboxEntry)
+
+	var title, text string
+	if form {
+		if err := r.ParseMultipartForm(maxInboxEntry); err != nil && err != http.ErrNotMultipart {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !bearer && !s.inbox.tokenMatches(r.PostForm.Get("token")) {
+			http.Error(w, "wrong or missing token", http.StatusUnauthorized)
+			return
+		}
+		title, text = r.PostForm.Get("title"), r.PostForm.Get("text")
+	} else {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		title, text = r.URL.Query().Get("title"), string(body)
+	}
+
+	if err := s.inbox.AppendInbox(title, text, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, "Added to %s\n", filepath.Base(s.inbox.path()))
+}
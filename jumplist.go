@@ -0,0 +1,57 @@
+package main
+
+// maxJumps is how many places a jump list holds on to; the oldest are
+// forgotten first.
+const maxJumps = 100
+
+// JumpPosition is a cursor position: a zero-based line and column.
+type JumpPosition struct {
+	Line   int
+	Column int
+}
+
+// JumpList is the trail of places the cursor jumped away from in one
+// document. Both editors Push the cursor before a jump, such as to a heading
+// from the outline, and Back and Forward retrace the trail the way the
+// buttons of a browser do. The zero JumpList is empty and ready to use.
+type JumpList struct {
+	positions []JumpPosition
+	// index is where Back goes next, plus one
+	index int
+}
+
+// Push records from as the place a jump leaves. Anything Forward could have
+// gone to is forgotten.
+func (j *JumpList) Push(from JumpPosition) {
+	j.positions = j.positions[:j.index]
+	if n := len(j.positions); n > 0 && j.positions[n-1] == from {
+		return
+	}
+	j.positions = append(j.positions, from)
+	if len(j.positions) > maxJumps {
+		j.positions = j.positions[len(j.positions)-maxJumps:]
+	}
+	j.index = len(j.positions)
+}
+
+// Back returns the place before the last jump. current is kept so that
+// Forward can come back to it.
+func (j *JumpList) Back(current JumpPosition) (JumpPosition, bool) {
+	if j.index == 0 {
+		return current, false
+	}
+	if j.index == len(j.positions) {
+		j.positions = append(j.positions, current)
+	}
+	j.index--
+	return j.positions[j.index], true
+}
+
+// Forward undoes a Back.
+func (j *JumpList) Forward() (JumpPosition, bool) {
+	if j.index >= len(j.positions)-1 {
+		return JumpPosition{}, false
+	}
+	j.index++
+	return j.positions[j.index], true
+}
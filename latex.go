@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+var latexListingsLanguages = map[string]string{
+	"bash":     "bash",
+	"sh":       "sh",
+	"shell":    "bash",
+	"c":        "C",
+	"cpp":      "C++",
+	"c++":      "C++",
+	"java":     "Java",
+	"python":   "Python",
+	"py":       "Python",
+	"ruby":     "Ruby",
+	"sql":      "SQL",
+	"html":     "HTML",
+	"xml":      "XML",
+	"perl":     "Perl",
+	"php":      "PHP",
+	"haskell":  "Haskell",
+	"tex":      "TeX",
+	"latex":    "TeX",
+	"make":     "make",
+	"makefile": "make",
+	"lua":      "Lua",
+	"r":        "R",
+	"matlab":   "Matlab",
+}
+
+var latexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+func latexEscape(text string) string {
+	return latexEscaper.Replace(text)
+}
+
+type latexWriter struct {
+	smp      *SharedMarkdownProcessor
+	source   []byte
+	out      []string
+	minted   bool
+	chapters bool
+	shift    int
+}
+
+// ExportLatex renders a standalone .tex document. Code blocks use the
+// listings package unless opts.LatexCode selects minted.
+func ExportLatex(content string, opts ExportOptions) ([]byte, error) {
+	smp := opts.processor()
+	doc, source := smp.Parse(content)
+
+	class := opts.LatexClass
+	if class == "" {
+		class = "article"
+	}
+
+	w := &latexWriter{
+		smp:      smp,
+		source:   source,
+		minted:   opts.LatexCode == "minted",
+		chapters: class == "book" || class == "report",
+	}
+
+	title := ""
+	h1Count := 0
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if heading, ok := n.(*ast.Heading); ok && heading.Level == 1 {
+			h1Count++
+		}
+	}
+	if first, ok := doc.FirstChild().(*ast.Heading); ok && first.Level == 1 && h1Count == 1 {
+		title = w.inline(first)
+		doc.RemoveChild(doc, first)
+		w.shift = 1
+	}
+
+	w.out = append(w.out,
+		fmt.Sprintf(`\documentclass{%s}`, class),
+		`\usepackage[utf8]{inputenc}`,
+		`\usepackage[T1]{fontenc}`,
+		`\usepackage{graphicx}`,
+		`\usepackage{booktabs}`,
+		`\usepackage{amssymb}`,
+		`\usepackage[normalem]{ulem}`,
+		`\usepackage{hyperref}`,
+	)
+	if w.minted {
+		w.out = append(w.out, `\usepackage{minted}`)
+	} else {
+		w.out = append(w.out,
+			`\usepackage{listings}`,
+			`\lstset{basicstyle=\ttfamily\small,breaklines=true,frame=single,columns=fullflexible}`)
+	}
+	if title != "" {
+		w.out = append(w.out, `\title{`+title+`}`, `\date{}`)
+	}
+	w.out = append(w.out, "", `\begin{document}`)
+	if title != "" {
+		w.out = append(w.out, `\maketitle`)
+	}
+	w.out = append(w.out, "")
+
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		w.block(n)
+	}
+
+	w.out = append(w.out, `\end{document}`)
+	return []byte(strings.Join(w.out, "\n") + "\n"), nil
+}
+
+func (w *latexWriter) block(n ast.Node) {
+	switch node := n.(type) {
+	case *ast.Heading:
+		commands := []string{`\section`, `\subsection`, `\subsubsection`, `\paragraph`, `\subparagraph`}
+		if w.chapters {
+			commands = append([]string{`\chapter`}, commands...)
+		}
+		level := max(node.Level-w.shift, 1)
+		command := commands[min(level, len(commands))-1]
+		w.out = append(w.out, command+"{"+w.inline(node)+"}", "")
+
+	case *ast.Paragraph:
+		if image, ok := soleImage(node); ok {
+			w.figure(image)
+			return
+		}
+		w.out = append(w.out, w.inline(node), "")
+
+	case *ast.TextBlock:
+		w.out = append(w.out, w.inline(node))
+
+	case *ast.List:
+		env := "itemize"
+		if node.IsOrdered() {
+			env = "enumerate"
+		}
+		w.out = append(w.out, `\begin{`+env+`}`)
+		if node.IsOrdered() && node.Start > 1 {
+			w.out = append(w.out, fmt.Sprintf(`\setcounter{enumi}{%d}`, node.Start-1))
+		}
+		for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+			marker := `\item `
+			if first := item.FirstChild(); first != nil {
+				if checkbox, ok := first.FirstChild().(*east.TaskCheckBox); ok {
+					marker = `\item[$\square$] `
+					if checkbox.IsChecked {
+						marker = `\item[$\boxtimes$] `
+					}
+				}
+			}
+			start := len(w.out)
+			for child := item.FirstChild(); child != nil; child = child.NextSibling() {
+				w.block(child)
+			}
+			if start < len(w.out) {
+				w.out[start] = marker + w.out[start]
+			} else {
+				w.out = append(w.out, marker)
+			}
+		}
+		w.out = append(w.out, `\end{`+env+`}`, "")
+
+	case *ast.FencedCodeBlock:
+		w.code(w.smp.CodeBlockText(node, w.source), strings.ToLower(string(node.Language(w.source))))
+
+	case *ast.CodeBlock:
+		w.code(w.smp.CodeBlockText(node, w.source), "")
+
+	case *ast.Blockquote:
+		w.out = append(w.out, `\begin{quote}`)
+		for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+			w.block(child)
+		}
+		w.out = append(w.out, `\end{quote}`, "")
+
+	case *ast.ThematicBreak:
+		w.out = append(w.out, `\noindent\rule{\linewidth}{0.4pt}`, "")
+
+	case *east.Table:
+		w.table(node)
+	}
+}
+
+func soleImage(p *ast.Paragraph) (*ast.Image, bool) {
+	if p.ChildCount() != 1 {
+		return nil, false
+	}
+	image, ok := p.FirstChild().(*ast.Image)
+	return image, ok
+}
+
+func (w *latexWriter) figure(image *ast.Image) {
+	caption := latexEscape(w.smp.PlainText(image, w.source))
+	w.out = append(w.out,
+		`\begin{figure}[htbp]`,
+		`\centering`,
+		`\includegraphics[width=\linewidth]{`+string(image.Destination)+`}`,
+	)
+	if caption != "" {
+		w.out = append(w.out, `\caption{`+caption+`}`)
+	}
+	w.out = append(w.out, `\end{figure}`, "")
+}
+
+func (w *latexWriter) code(code, lang string) {
+	if w.minted {
+		if lang == "" {
+			lang = "text"
+		}
+		w.out = append(w.out, `\begin{minted}{`+lang+`}`, code, `\end{minted}`, "")
+		return
+	}
+
+	begin := `\begin{lstlisting}`
+	if listingsLang, ok := latexListingsLanguages[lang]; ok {
+		begin += "[language=" + listingsLang + "]"
+	}
+	w.out = append(w.out, begin, code, `\end{lstlisting}`, "")
+}
+
+func (w *latexWriter) table(table *east.Table) {
+	spec := ""
+	for _, alignment := range table.Alignments {
+		switch alignment {
+		case east.AlignCenter:
+			spec += "c"
+		case east.AlignRight:
+			spec += "r"
+		default:
+			spec += "l"
+		}
+	}
+
+	w.out = append(w.out, `\begin{table}[htbp]`, `\centering`, `\begin{tabular}{`+spec+`}`, `\toprule`)
+	for row := table.FirstChild(); row != nil; row = row.NextSibling() {
+		var cells []string
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			text := w.inline(cell)
+			if _, ok := row.(*east.TableHeader); ok {
+				text = `\textbf{` + text + `}`
+			}
+			cells = append(cells, text)
+		}
+		w.out = append(w.out, strings.Join(cells, " & ")+` \\`)
+		if _, ok := row.(*east.TableHeader); ok {
+			w.out = append(w.out, `\midrule`)
+		}
+	}
+	w.out = append(w.out, `\bottomrule`, `\end{tabular}`, `\end{table}`, "")
+}
+
+func (w *latexWriter) inline(n ast.Node) string {
+	var buf strings.Builder
+	ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch node := child.(type) {
+		case *ast.Emphasis:
+			if entering {
+				if node.Level >= 2 {
+					buf.WriteString(`\textbf{`)
+				} else {
+					buf.WriteString(`\emph{`)
+				}
+			} else {
+				buf.WriteString("}")
+			}
+		case *east.Strikethrough:
+			if entering {
+				buf.WriteString(`\sout{`)
+			} else {
+				buf.WriteString("}")
+			}
+		case *ast.CodeSpan:
+			if entering {
+				buf.WriteString(`\texttt{` + latexEscape(w.smp.PlainText(node, w.source)) + `}`)
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.Link:
+			if entering {
+				buf.WriteString(`\href{` + latexURL(string(node.Destination)) + `}{`)
+			} else {
+				buf.WriteString("}")
+			}
+		case *ast.Image:
+			if entering {
+				buf.WriteString(`\includegraphics[height=1em]{` + string(node.Destination) + `}`)
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.AutoLink:
+			if entering {
+				buf.WriteString(`\url{` + latexURL(string(node.URL(w.source))) + `}`)
+			}
+		case *ast.Text:
+			if entering {
+				buf.WriteString(latexEscape(string(node.Segment.Value(w.source))))
+				if node.HardLineBreak() {
+					buf.WriteString(`\\` + "\n")
+				} else if node.SoftLineBreak() {
+					buf.WriteString("\n")
+				}
+			}
+		case *ast.String:
+			if entering {
+				buf.WriteString(latexEscape(string(node.Value)))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(buf.String())
+}
+
+// latexURL escapes the characters hyperref still chokes on inside \href.
+func latexURL(url string) string {
+	return strings.NewReplacer(`%`, `\%`, `#`, `\#`).Replace(url)
+}
@@ -0,0 +1,94 @@
+package main
+
+import "strings"
+
+// MoveLines moves lines first to last of content up (delta -1) or down
+// (delta 1) by one line. It reports false when they are already at the top
+// or bottom.
+func MoveLines(content string, first, last, delta int) (string, bool) {
+	lines := strings.Split(content, "\n")
+	if first < 0 || last >= len(lines) || first > last {
+		return content, false
+	}
+	if delta < 0 && first == 0 || delta > 0 && last == len(lines)-1 {
+		return content, false
+	}
+
+	block := append([]string{}, lines[first:last+1]...)
+	if delta < 0 {
+		// The line above drops below the block
+		above := lines[first-1]
+		copy(lines[first-1:], block)
+		lines[last] = above
+	} else {
+		below := lines[last+1]
+		copy(lines[first+1:], block)
+		lines[first] = below
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// DuplicateLines inserts a copy of lines first to last right after them.
+func DuplicateLines(content string, first, last int) string {
+	lines := strings.Split(content, "\n")
+	if first < 0 || last >= len(lines) || first > last {
+		return content
+	}
+	result := append([]string{}, lines[:last+1]...)
+	result = append(result, lines[first:last+1]...)
+	result = append(result, lines[last+1:]...)
+	return strings.Join(result, "\n")
+}
+
+// DeleteLines removes lines first to last, leaving a single empty line when
+// nothing else is left.
+func DeleteLines(content string, first, last int) string {
+	lines := strings.Split(content, "\n")
+	if first < 0 || last >= len(lines) || first > last {
+		return content
+	}
+	result := append(append([]string{}, lines[:first]...), lines[last+1:]...)
+	return strings.Join(result, "\n")
+}
+
+// editLine runs one of the line commands on the line under the cursor; the
+// terminal editor has no selection.
+func (m *model) editLine(edit func(content string, row int) (string, int)) {
+	info := m.textarea.LineInfo()
+	row, col := m.textarea.Line(), info.StartColumn+info.ColumnOffset
+	content := m.textarea.Value()
+
+	edited, newRow := edit(content, row)
+	if edited == content {
+		return
+	}
+	m.textarea.SetValue(edited)
+	moveCursorTo(&m.textarea, newRow, col)
+	m.content = edited
+	if m.mode == splitMode {
+		m.refreshPreview()
+		m.syncPreviewScroll()
+	}
+}
+
+func moveLineUp(content string, row int) (string, int) {
+	if moved, ok := MoveLines(content, row, row, -1); ok {
+		return moved, row - 1
+	}
+	return content, row
+}
+
+func moveLineDown(content string, row int) (string, int) {
+	if moved, ok := MoveLines(content, row, row, 1); ok {
+		return moved, row + 1
+	}
+	return content, row
+}
+
+func duplicateLine(content string, row int) (string, int) {
+	return DuplicateLines(content, row, row), row + 1
+}
+
+func deleteLine(content string, row int) (string, int) {
+	return DeleteLines(content, row, row), row
+}
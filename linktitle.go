@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// titleFetchLimit is how much of a page is read looking for its title.
+const titleFetchLimit = 1 << 20
+
+var (
+	pageTitleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	bareURLRe   = regexp.MustCompile(`^https?://\S+$`)
+)
+
+// FetchTitle downloads the page at url and returns its title. Slow or
+// unreachable servers give up after timeout.
+func FetchTitle(url string, timeout time.Duration) (string, error) {
+	client := &http.Client{Timeout: timeout}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("error fetching title: %v", err)
+	}
+	req.Header.Set("User-Agent", "parselt")
+	req.Header.Set("Accept", "text/html")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error fetching title: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error fetching title: %s", resp.Status)
+	}
+
+	page, err := io.ReadAll(io.LimitReader(resp.Body, titleFetchLimit))
+	if err != nil {
+		return "", fmt.Errorf("error fetching title: %v", err)
+	}
+	matches := pageTitleRe.FindSubmatch(page)
+	if matches == nil {
+		return "", fmt.Errorf("%s has no title", url)
+	}
+	title := strings.Join(strings.Fields(html.UnescapeString(string(matches[1]))), " ")
+	if title == "" {
+		return "", fmt.Errorf("%s has no title", url)
+	}
+	return title, nil
+}
+
+func isBareURL(text string) bool {
+	return bareURLRe.MatchString(text)
+}
+
+// titledLink formats a link, escaping the brackets of the title.
+func titledLink(title, url string) string {
+	title = strings.NewReplacer(`[`, `\[`, `]`, `\]`).Replace(title)
+	return "[" + title + "](" + url + ")"
+}
+
+// linkTitle is a fetched title to put into the document where original,
+// the bare URL or an empty link to it, was inserted at start.
+type linkTitle struct {
+	start    int
+	original string
+	url      string
+	title    string
+}
+
+// apply replaces the original text with the titled link and reports how
+// far the text after it moved. Nothing happens when the text was changed
+// while the title was being fetched.
+func (t linkTitle) apply(content string) (string, int, bool) {
+	end := t.start + len(t.original)
+	if end > len(content) || content[t.start:end] != t.original {
+		return content, 0, false
+	}
+	link := titledLink(t.title, t.url)
+	return content[:t.start] + link + content[end:], len(link) - len(t.original), true
+}
+
+// cursor moves a cursor offset along with the text after the link, and out
+// of the link when it was inside.
+func (t linkTitle) cursor(cursor, delta int) int {
+	end := t.start + len(t.original)
+	switch {
+	case cursor >= end:
+		return cursor + delta
+	case cursor > t.start:
+		return end + delta
+	}
+	return cursor
+}
+
+// linkTitleMsg delivers a fetched title to the terminal editor. history
+// identifies the buffer it belongs to.
+type linkTitleMsg struct {
+	linkTitle
+	history *History
+	err     error
+}
+
+// fetchLinkTitle fetches the title for a link inserted at start in the
+// background, when fetching titles is turned on.
+func (m *model) fetchLinkTitle(start int, original, url string) tea.Cmd {
+	if !m.links.FetchTitles {
+		return nil
+	}
+	history := m.history
+	timeout := m.links.TitleTimeout()
+	m.status = "Fetching title of " + url
+	return func() tea.Msg {
+		title, err := FetchTitle(url, timeout)
+		return linkTitleMsg{
+			linkTitle: linkTitle{start: start, original: original, url: url, title: title},
+			history:   history,
+			err:       err,
+		}
+	}
+}
+
+func (m *model) applyLinkTitle(msg linkTitleMsg) {
+	if msg.history != m.history {
+		// The buffer is no longer the active one
+		return
+	}
+	if msg.err != nil {
+		m.status = msg.err.Error()
+		return
+	}
+	content := m.textarea.Value()
+	titled, delta, ok := msg.apply(content)
+	if !ok {
+		return
+	}
+
+	cursor := msg.cursor(m.cursorOffset(), delta)
+	m.textarea.SetValue(titled)
+	row, col := rowColumn(titled, cursor)
+	moveCursorTo(&m.textarea, row, col)
+	m.content = titled
+	m.status = "Link title: " + msg.title
+	if m.mode == splitMode {
+		m.refreshPreview()
+	}
+}
+
+// pasteURL inserts a pasted bare URL and fetches the title for it.
+func (m *model) pasteURL(url string) tea.Cmd {
+	start := m.cursorOffset()
+	m.textarea.InsertString(url)
+	return m.fetchLinkTitle(start, url, url)
+}
+
+// LinkSelection makes the text selected in content a link to url. Blanks
+// around the selection stay outside the link. It returns the new content and
+// the offset just past the link.
+func LinkSelection(content string, sel TextRange, url string) (string, int) {
+	text := content[sel.Start:sel.End]
+	trimmed := strings.TrimSpace(text)
+	start := sel.Start + strings.Index(text, trimmed)
+	end := start + len(trimmed)
+	link := "[" + trimmed + "](" + url + ")"
+	return content[:start] + link + content[end:], start + len(link)
+}
+
+// pasteLink wraps the selection in a link to a pasted URL instead of
+// replacing it.
+func (m *model) pasteLink(url string) {
+	linked, cursor := LinkSelection(m.textarea.Value(), *m.selection, url)
+	m.clearSelection()
+	m.textarea.SetValue(linked)
+	row, col := rowColumn(linked, cursor)
+	moveCursorTo(&m.textarea, row, col)
+	m.content = linked
+	m.status = "Linked selection to " + url
+	if m.mode == splitMode {
+		m.refreshPreview()
+		m.syncPreviewScroll()
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type LintFix struct {
+	Line  int
+	Start int
+	End   int
+	Text  string
+}
+
+func (f LintFix) Apply(content string) string {
+	lines := strings.Split(content, "\n")
+	if f.Line < 0 || f.Line >= len(lines) {
+		return content
+	}
+
+	line := lines[f.Line]
+	if f.Start < 0 || f.End > len(line) || f.Start > f.End {
+		return content
+	}
+	lines[f.Line] = line[:f.Start] + f.Text + line[f.End:]
+	return strings.Join(lines, "\n")
+}
+
+type LintIssue struct {
+	Line    int
+	Column  int
+	Rule    string
+	Message string
+	Fix     *LintFix
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%d:%d %s (%s)", i.Line+1, i.Column+1, i.Message, i.Rule)
+}
+
+type LintRule func(lines []string, inCode []bool) []LintIssue
+
+// AltTextSuggester proposes alt text for an image. The default derives it
+// from the file name; smarter backends can be plugged in by replacing it.
+type AltTextSuggester func(src string) string
+
+type Linter struct {
+	rules        map[string]LintRule
+	SuggestAlt   AltTextSuggester
+	DisableRules map[string]bool
+}
+
+var (
+	markdownImageRe = regexp.MustCompile(`!\[([^\]]*)\]\(\s*<?([^)\s>]+)>?[^)]*\)`)
+	htmlImageRe     = regexp.MustCompile(`(?i)<img\b[^>]*>`)
+	htmlAltRe       = regexp.MustCompile(`(?i)\balt\s*=\s*("[^"]*"|'[^']*')`)
+	htmlSrcRe       = regexp.MustCompile(`(?i)\bsrc\s*=\s*("[^"]*"|'[^']*')`)
+	fenceRe         = regexp.MustCompile("^\\s*(```|~~~)")
+)
+
+func NewLinter() *Linter {
+	l := &Linter{
+		SuggestAlt:   SuggestAltFromFilename,
+		DisableRules: map[string]bool{},
+	}
+	l.rules = map[string]LintRule{
+		"image-alt-text": l.checkImageAltText,
+	}
+	return l
+}
+
+func (l *Linter) Lint(content string) []LintIssue {
+	lines := strings.Split(content, "\n")
+	inCode := codeBlockLines(lines)
+
+	names := make([]string, 0, len(l.rules))
+	for name := range l.rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var issues []LintIssue
+	for _, name := range names {
+		if l.DisableRules[name] {
+			continue
+		}
+		issues = append(issues, l.rules[name](lines, inCode)...)
+	}
+
+	sort.SliceStable(issues, func(a, b int) bool {
+		if issues[a].Line != issues[b].Line {
+			return issues[a].Line < issues[b].Line
+		}
+		return issues[a].Column < issues[b].Column
+	})
+	return issues
+}
+
+func codeBlockLines(lines []string) []bool {
+	inCode := make([]bool, len(lines))
+	fence := ""
+	for i, line := range lines {
+		if matches := fenceRe.FindStringSubmatch(line); matches != nil {
+			if fence == "" {
+				fence = matches[1]
+				inCode[i] = true
+				continue
+			}
+			if matches[1] == fence {
+				fence = ""
+				inCode[i] = true
+				continue
+			}
+		}
+		inCode[i] = fence != ""
+	}
+	return inCode
+}
+
+func (l *Linter) checkImageAltText(lines []string, inCode []bool) []LintIssue {
+	var issues []LintIssue
+
+	for i, line := range lines {
+		if inCode[i] {
+			continue
+		}
+
+		for _, loc := range markdownImageRe.FindAllStringSubmatchIndex(line, -1) {
+			alt := line[loc[2]:loc[3]]
+			if strings.TrimSpace(alt) != "" {
+				continue
+			}
+			src := line[loc[4]:loc[5]]
+			issue := LintIssue{
+				Line:    i,
+				Column:  utf8.RuneCountInString(line[:loc[0]]),
+				Rule:    "image-alt-text",
+				Message: fmt.Sprintf("image %s has no alt text", src),
+			}
+			if suggestion := l.suggestAlt(src); suggestion != "" {
+				issue.Fix = &LintFix{Line: i, Start: loc[2], End: loc[3], Text: suggestion}
+			}
+			issues = append(issues, issue)
+		}
+
+		for _, loc := range htmlImageRe.FindAllStringIndex(line, -1) {
+			tag := line[loc[0]:loc[1]]
+			if altMatch := htmlAltRe.FindStringSubmatch(tag); altMatch != nil && strings.Trim(altMatch[1], `"' `) != "" {
+				continue
+			}
+			src := ""
+			if srcMatch := htmlSrcRe.FindStringSubmatch(tag); srcMatch != nil {
+				src = strings.Trim(srcMatch[1], `"'`)
+			}
+			issues = append(issues, LintIssue{
+				Line:    i,
+				Column:  utf8.RuneCountInString(line[:loc[0]]),
+				Rule:    "image-alt-text",
+				Message: fmt.Sprintf("<img> %s has no alt attribute", src),
+			})
+		}
+	}
+	return issues
+}
+
+func (l *Linter) suggestAlt(src string) string {
+	if l.SuggestAlt == nil {
+		return ""
+	}
+	return l.SuggestAlt(src)
+}
+
+func SuggestAltFromFilename(src string) string {
+	name := path.Base(strings.ReplaceAll(src, "\\", "/"))
+	name = strings.TrimSuffix(name, path.Ext(name))
+	name = strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' {
+			return ' '
+		}
+		return r
+	}, name)
+
+	words := strings.Fields(name)
+	var kept []string
+	for _, word := range words {
+		// Drop hashes, timestamps and other machine-generated noise
+		if strings.IndexFunc(word, unicode.IsLetter) == -1 || len(word) > 24 {
+			continue
+		}
+		kept = append(kept, strings.ToLower(word))
+	}
+	if len(kept) == 0 {
+		return ""
+	}
+
+	sentence := strings.Join(kept, " ")
+	runes := []rune(sentence)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
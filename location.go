@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2/dialog"
+)
+
+// fileLineRe matches a file argument with a line, and maybe a column, the way
+// grep -n and compilers print them: notes.md:120 or notes.md:120:5.
+var fileLineRe = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?:?$`)
+
+// Location is where a file opens from the command line: a one-based line and
+// column, or a heading. The zero Location keeps the remembered position.
+type Location struct {
+	Line    int
+	Column  int
+	Heading string
+}
+
+// splitLocation takes a line and column off a file argument. A file whose
+// name really ends like that is left alone.
+func splitLocation(arg string) (string, Location) {
+	if _, err := os.Stat(arg); err == nil {
+		return arg, Location{}
+	}
+	matches := fileLineRe.FindStringSubmatch(arg)
+	if matches == nil {
+		return arg, Location{}
+	}
+	line, _ := strconv.Atoi(matches[2])
+	column, _ := strconv.Atoi(matches[3])
+	return matches[1], Location{Line: line, Column: column}
+}
+
+// fileArgs turns the file arguments into paths and where each one opens.
+// Patterns are expanded for shells that leave that to the program, such as
+// cmd.exe; one that matches nothing is kept as a new file. Files named twice
+// are only kept the first time.
+func fileArgs(args []string) ([]string, []Location) {
+	var files []string
+	var locs []Location
+	for _, arg := range args {
+		path, loc := splitLocation(arg)
+		matches := []string{path}
+		if _, err := os.Stat(path); err != nil && strings.ContainsAny(path, "*?[") {
+			if found, _ := filepath.Glob(path); len(found) > 0 {
+				matches = found
+			}
+		}
+	next:
+		for _, match := range matches {
+			for _, file := range files {
+				if sameFile(file, match) {
+					continue next
+				}
+			}
+			files = append(files, match)
+			locs = append(locs, loc)
+		}
+	}
+	return files, locs
+}
+
+// resolve finds the zero-based row and column of loc in content. A heading is
+// looked up without regard to case, first by its whole text and then by a
+// part of it.
+func (loc Location) resolve(smp *SharedMarkdownProcessor, content string) (int, int, error) {
+	if loc.Heading == "" {
+		lines := strings.Split(content, "\n")
+		row := min(max(loc.Line-1, 0), len(lines)-1)
+		return row, min(max(loc.Column-1, 0), len([]rune(lines[row]))), nil
+	}
+	want := strings.ToLower(strings.TrimSpace(strings.TrimLeft(loc.Heading, "#")))
+	headings := smp.Outline(content)
+	for _, heading := range headings {
+		if strings.ToLower(heading.Text) == want {
+			return heading.Line, 0, nil
+		}
+	}
+	for _, heading := range headings {
+		if strings.Contains(strings.ToLower(heading.Text), want) {
+			return heading.Line, 0, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("no heading %q in the document", loc.Heading)
+}
+
+// goTo puts the cursor at loc and the preview on the same line.
+func (m *model) goTo(loc Location) {
+	if loc == (Location{}) {
+		return
+	}
+	row, col, err := loc.resolve(m.mdProcessor, m.textarea.Value())
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	moveCursorTo(&m.textarea, row, col)
+	m.previewLine = row
+}
+
+func (g *GUIApp) goTo(loc Location) {
+	if loc == (Location{}) {
+		return
+	}
+	row, col, err := loc.resolve(g.mdProcessor, g.editor.Text)
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+	g.editor.CursorRow = row
+	g.editor.CursorColumn = col
+	g.editor.Refresh()
+	g.previewLine = row
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// LockPath is where the lock of docPath is kept, next to its swap file.
+// Untitled documents have none.
+func LockPath(docPath string) string {
+	return recoveryPath(docPath, ".lock")
+}
+
+// FileLock is who edits a document: the parselt that opened it first and
+// keeps it open. Its lock file has a single line:
+//
+//	parselt-lock <pid> <host> <unix time>
+type FileLock struct {
+	PID   int
+	Host  string
+	Since time.Time
+}
+
+func (l FileLock) String() string {
+	return fmt.Sprintf("pid %d on %s since %s", l.PID, l.Host, l.Since.Format("Jan 2 15:04"))
+}
+
+// Live tells whether the parselt holding the lock still runs. Locks from
+// other machines count as live: there is no telling.
+func (l FileLock) Live() bool {
+	return SwapInfo{PID: l.PID, Host: l.Host}.Live()
+}
+
+func (l FileLock) ours() bool {
+	host, _ := os.Hostname()
+	return l.PID == os.Getpid() && l.Host == host
+}
+
+// ReadLock returns the lock of docPath.
+func ReadLock(docPath string) (FileLock, error) {
+	path := LockPath(docPath)
+	if path == "" {
+		return FileLock{}, os.ErrNotExist
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return FileLock{}, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 4 || fields[0] != "parselt-lock" {
+		return FileLock{}, fmt.Errorf("error reading lock: %s is not one", path)
+	}
+	var lock FileLock
+	lock.PID, _ = strconv.Atoi(fields[1])
+	lock.Host = fields[2]
+	since, _ := strconv.ParseInt(fields[3], 10, 64)
+	lock.Since = time.Unix(since, 0)
+	return lock, nil
+}
+
+func ownLock() FileLock {
+	host, _ := os.Hostname()
+	return FileLock{PID: os.Getpid(), Host: host, Since: time.Now()}
+}
+
+func lockLine(lock FileLock) []byte {
+	return fmt.Appendf(nil, "parselt-lock %d %s %d\n", lock.PID, lock.Host, lock.Since.Unix())
+}
+
+// LockFile takes the lock of docPath. A lock left by a parselt that is no
+// longer running is taken over; one held by a running parselt is returned
+// with ErrFileLocked.
+func LockFile(docPath string) (FileLock, error) {
+	path := LockPath(docPath)
+	if path == "" {
+		return FileLock{}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return FileLock{}, fmt.Errorf("error locking %s: %v", docPath, err)
+	}
+	for range 2 {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			lock := ownLock()
+			_, err = file.Write(lockLine(lock))
+			file.Close()
+			if err != nil {
+				return FileLock{}, fmt.Errorf("error locking %s: %v", docPath, err)
+			}
+			return lock, nil
+		}
+		if !os.IsExist(err) {
+			return FileLock{}, fmt.Errorf("error locking %s: %v", docPath, err)
+		}
+		holder, err := ReadLock(docPath)
+		if err == nil && holder.ours() {
+			return holder, nil
+		}
+		if err == nil && holder.Live() {
+			return holder, fmt.Errorf("%s is %w (%s)", filepath.Base(docPath), ErrFileLocked, holder)
+		}
+		// Left by a crash
+		os.Remove(path)
+	}
+	return FileLock{}, fmt.Errorf("error locking %s: the lock keeps coming back", docPath)
+}
+
+// TakeOverLock takes the lock of docPath from whoever holds it. The parselt
+// that held it can no longer save the document.
+func TakeOverLock(docPath string) error {
+	path := LockPath(docPath)
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error taking over %s: %v", docPath, err)
+	}
+	if err := os.WriteFile(path, lockLine(ownLock()), 0644); err != nil {
+		return fmt.Errorf("error taking over %s: %v", docPath, err)
+	}
+	return nil
+}
+
+// Unlock gives up the lock of docPath, unless another parselt took it
+// over.
+func Unlock(docPath string) {
+	if lock, err := ReadLock(docPath); err == nil && lock.ours() {
+		os.Remove(LockPath(docPath))
+	}
+}
+
+// checkLock refuses to save docPath while another running parselt holds
+// its lock, which happens after that one took it over.
+func checkLock(docPath string) error {
+	holder, err := ReadLock(docPath)
+	if err != nil || holder.ours() || !holder.Live() {
+		return nil
+	}
+	return fmt.Errorf("error saving %s: %w (%s)", docPath, ErrFileLocked, holder)
+}
+
+// lockBuffer takes the lock of the active buffer and asks what to do when
+// another parselt has it. It reports whether the buffer is free to edit.
+func (m *model) lockBuffer() bool {
+	if m.filename == "" || m.readOnly {
+		return true
+	}
+	if !m.lockOn {
+		if pid, ok := swapOwner(m.filename); ok {
+			m.status = fmt.Sprintf("%s is also open in another parselt (pid %d)", filepath.Base(m.filename), pid)
+		}
+		return true
+	}
+	holder, err := LockFile(m.filename)
+	if errors.Is(err, ErrFileLocked) {
+		m.offerTakeover(holder)
+		return false
+	}
+	if err != nil {
+		m.status = err.Error()
+	}
+	return true
+}
+
+// offerTakeover asks whether to take over editing from the parselt holding
+// the lock, or to keep the buffer read-only.
+func (m *model) offerTakeover(holder FileLock) {
+	m.lockHolder = holder
+	m.overlay = overlayLock
+}
+
+func (m *model) updateLock(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "t":
+		if err := TakeOverLock(m.filename); err != nil {
+			m.status = err.Error()
+			return
+		}
+		m.readOnly = false
+		m.status = fmt.Sprintf("Took over editing %s", filepath.Base(m.filename))
+	case "r", "esc":
+		m.readOnly = true
+		m.status = fmt.Sprintf("%s is read-only here", filepath.Base(m.filename))
+	default:
+		return
+	}
+	m.overlay = overlayNone
+}
+
+func (m model) lockView() string {
+	text := fmt.Sprintf("%s is open in another parselt (%s).", filepath.Base(m.filename), m.lockHolder)
+	if m.lockHolder.PID == 0 {
+		text = fmt.Sprintf("%s was opened read-only.", filepath.Base(m.filename))
+	}
+	body := lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render("File Open Elsewhere"),
+		"",
+		text,
+		"Editing it in both would lose the changes of whichever saves first.",
+		"",
+		helpStyle.Render("r: read-only • t: take over editing"),
+	)
+	return pickerStyle.Render(body)
+}
+
+// lockCurrentFile gives up the lock of the file shown before and takes the
+// one of the current file, asking what to do when another parselt has it.
+func (g *GUIApp) lockCurrentFile() {
+	if g.lockedFile != "" && g.lockedFile != g.currentFile {
+		Unlock(g.lockedFile)
+	}
+	g.lockedFile, g.readOnly = "", false
+	if g.currentFile == "" {
+		return
+	}
+	if !g.config.Autosave.Lock {
+		if pid, ok := swapOwner(g.currentFile); ok {
+			dialog.ShowInformation("Open Elsewhere",
+				fmt.Sprintf("%s is also open in another parselt (pid %d).", filepath.Base(g.currentFile), pid), g.window)
+		}
+		return
+	}
+	holder, err := LockFile(g.currentFile)
+	switch {
+	case errors.Is(err, ErrFileLocked):
+		g.readOnly = true
+		g.offerTakeover(holder, nil)
+	case err != nil:
+		dialog.ShowError(err, g.window)
+	default:
+		g.lockedFile = g.currentFile
+	}
+	g.updateTitle()
+}
+
+// offerTakeover asks whether to take over editing from the parselt holding
+// the lock, then runs next, or to keep the document read-only.
+func (g *GUIApp) offerTakeover(holder FileLock, next func()) {
+	text := fmt.Sprintf("%s is open in another parselt (%s).", filepath.Base(g.currentFile), holder)
+	if holder.PID == 0 {
+		text = fmt.Sprintf("%s was opened read-only.", filepath.Base(g.currentFile))
+	}
+	label := widget.NewLabel(text + "\nEditing it in both would lose the changes of whichever saves first.")
+	label.Wrapping = fyne.TextWrapWord
+	confirm := dialog.NewCustomConfirm("File Open Elsewhere", "Take Over", "Read Only", label, func(takeOver bool) {
+		if !takeOver {
+			return
+		}
+		if err := TakeOverLock(g.currentFile); err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+		g.lockedFile, g.readOnly = g.currentFile, false
+		g.updateTitle()
+		if next != nil {
+			next()
+		}
+	}, g.window)
+	confirm.Resize(fyne.NewSize(480, 0))
+	confirm.Show()
+}
+
+// saveLocked checks that the document may be saved here and otherwise
+// offers to take over editing, saving afterwards.
+func (g *GUIApp) saveLocked(next func()) bool {
+	if g.readOnly {
+		g.offerTakeover(FileLock{}, func() { g.saveThen(next) })
+		return false
+	}
+	if !g.config.Autosave.Lock || checkLock(g.currentFile) == nil {
+		return true
+	}
+	holder, _ := ReadLock(g.currentFile)
+	g.offerTakeover(holder, func() { g.saveThen(next) })
+	return false
+}
@@ -3,47 +3,136 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
 	"os"
+	"runtime"
 )
 
 func main() {
 	var filename string
 	var useGUI bool
+	var serveAddr string
+	var create bool
+	var loc Location
+
+	migrateState()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			if err := runExport(os.Args[2:]); err != nil {
+				fatal("exporting", err)
+			}
+			return
+		case "tutorial":
+			if err := runTutorial(os.Args[2:]); err != nil {
+				fatal("running tutorial", err)
+			}
+			return
+		case "serve":
+			if err := runServe(os.Args[2:]); err != nil {
+				fatal("serving", err)
+			}
+			return
+		case "settings":
+			if err := runSettings(os.Args[2:]); err != nil {
+				fatal("with settings", err)
+			}
+			return
+		case "stats":
+			if err := runStats(os.Args[2:]); err != nil {
+				fatal("showing stats", err)
+			}
+			return
+		case "doctor":
+			if err := runDoctor(os.Args[2:]); err != nil {
+				fatal("running doctor", err)
+			}
+			return
+		case "org2md":
+			if err := runOrgToMarkdown(os.Args[2:]); err != nil {
+				fatal("converting", err)
+			}
+			return
+		}
+	}
 
 	flag.BoolVar(&useGUI, "gui", false, "Launch GUI version")
+	flag.StringVar(&ActiveProfile, "profile", "", "configuration profile to use")
+	flag.StringVar(&serveAddr, "serve", "", "also serve a live preview in the browser at this address, e.g. "+defaultServeAddr)
+	flag.BoolVar(&create, "create", false, "create the file right away instead of on the first save")
+	flag.StringVar(&loc.Heading, "heading", "", "open with the cursor on this heading")
 	flag.Parse()
 
-	args := flag.Args()
-	if len(os.Args) > 1 && os.Args[1] == "-gui" {
-		useGUI = true
+	// Flags may also follow the files, as in parselt notes.md -heading Usage
+	var args []string
+	for rest := flag.Args(); len(rest) > 0; rest = flag.Args() {
+		args = append(args, rest[0])
+		flag.CommandLine.Parse(rest[1:])
+	}
+	files, locs := fileArgs(args)
+	if len(files) > 0 {
+		filename = files[0]
+		if loc.Heading == "" {
+			loc = locs[0]
+		}
 	}
 
-	if useGUI {
-		gui := NewGUIApp()
-		gui.Run()
-		return
+	startLog()
+	defer logPanic()
+	log.Printf("parselt %s on %s, gui %v", versionInfo(), runtime.GOOS, useGUI)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fatal("loading config", err)
+	}
+	enableMetrics(cfg.Metrics)
+	defer flushMetrics()
+	if dir := cfg.StartDirectory(); dir != "" && filename == "" {
+		if err := os.Chdir(dir); err != nil {
+			fmt.Printf("Error changing to %s: %v\n", dir, err)
+		}
 	}
 
-	if len(args) > 0 {
-		filename = args[0]
-	} else if len(os.Args) > 1 && os.Args[1] != "-gui" {
-		filename = os.Args[1]
+	var server *PreviewServer
+	var serveURL string
+	if serveAddr != "" {
+		server, serveURL, err = StartPreviewServer(serveAddr, cfg.ExportOptions(filename), cfg.Inbox)
+		if err != nil {
+			fatal("serving", err)
+		}
+		fmt.Printf("Serving a live preview at %s\n", serveURL)
 	}
 
-	if filename != "" {
-		if _, err := os.Stat(filename); os.IsNotExist(err) {
-			file, err := os.Create(filename)
+	for i := 0; create && i < len(files); i++ {
+		if _, err := os.Stat(files[i]); os.IsNotExist(err) {
+			file, err := os.Create(files[i])
 			if err != nil {
-				fmt.Printf("Error creating file: %v\n", err)
-				os.Exit(1)
+				fatal("creating file", err)
 			}
 			file.Close()
 		}
 	}
 
+	if useGUI {
+		detachConsole()
+		gui := NewGUIApp()
+		gui.server = server
+		gui.Run(files, loc)
+		return
+	}
+
 	terminal := NewTerminalApp(filename)
+	terminal.model.goTo(loc)
+	if len(files) > 1 {
+		terminal.model.openFiles(files[1:], locs[1:])
+	}
+	if server != nil {
+		terminal.model.server = server
+		if terminal.model.status == "" {
+			terminal.model.status = fmt.Sprintf("Live preview at %s", serveURL)
+		}
+	}
 	if err := terminal.Run(); err != nil {
-		fmt.Printf("Error starting terminal app: %v\n", err)
-		os.Exit(1)
+		fatal("starting terminal app", err)
 	}
 }
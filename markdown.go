@@ -1,23 +1,63 @@
 package main
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 )
 
-type SharedMarkdownProcessor struct{}
+// Flavor selects the markdown dialect: "gfm" (the default) or "commonmark",
+// which leaves out tables, strikethrough, task lists and autolinks.
+type SharedMarkdownProcessor struct {
+	Flavor string
+	Code   CodeConfig
+
+	mu       sync.Mutex
+	md       goldmark.Markdown
+	mdFlavor string
+
+	terminalCache renderCache
+	fyneCache     renderCache
+	previewCache  previewCache
+}
 
 func NewSharedMarkdownProcessor() *SharedMarkdownProcessor {
 	return &SharedMarkdownProcessor{}
 }
 
-func (smp *SharedMarkdownProcessor) ConvertMarkdownToHTML(content string) string {
-	md := goldmark.New(
+// markdown returns the goldmark instance of the processor, built on first
+// use and again when the flavor changes.
+func (smp *SharedMarkdownProcessor) markdown() goldmark.Markdown {
+	smp.mu.Lock()
+	defer smp.mu.Unlock()
+	if smp.md == nil || smp.mdFlavor != smp.Flavor {
+		smp.md, smp.mdFlavor = smp.newGoldmark(), smp.Flavor
+	}
+	return smp.md
+}
+
+func (smp *SharedMarkdownProcessor) newGoldmark() goldmark.Markdown {
+	if smp.Flavor == "commonmark" {
+		return goldmark.New(
+			goldmark.WithParserOptions(
+				parser.WithAutoHeadingID(),
+			),
+			goldmark.WithRendererOptions(
+				html.WithHardWraps(),
+			),
+		)
+	}
+
+	return goldmark.New(
 		goldmark.WithExtensions(
 			extension.GFM,
 			extension.Table,
@@ -31,105 +71,111 @@ func (smp *SharedMarkdownProcessor) ConvertMarkdownToHTML(content string) string
 			html.WithHardWraps(),
 		),
 	)
+}
 
-	var buf strings.Builder
-	if err := md.Convert([]byte(content), &buf); err != nil {
+// ConvertMarkdownToHTML renders content as HTML, or returns it as it is if
+// it cannot be rendered.
+func (smp *SharedMarkdownProcessor) ConvertMarkdownToHTML(content string) string {
+	html, err := smp.RenderHTML(content)
+	if err != nil {
 		return content
 	}
-
-	return buf.String()
-}
-
-func (smp *SharedMarkdownProcessor) UnescapeHTML(text string) string {
-	text = strings.ReplaceAll(text, "&lt;", "<")
-	text = strings.ReplaceAll(text, "&gt;", ">")
-	text = strings.ReplaceAll(text, "&amp;", "&")
-	text = strings.ReplaceAll(text, "&quot;", `"`)
-	text = strings.ReplaceAll(text, "&#39;", "'")
-	return text
+	return html
 }
 
-func (smp *SharedMarkdownProcessor) ExtractCodeLanguage(line string) string {
-	langRe := regexp.MustCompile(`class="language-([^"]*)"`)
-	if matches := langRe.FindStringSubmatch(line); len(matches) > 1 {
-		return matches[1]
+// RenderHTML renders content as HTML, failing with ErrRenderFailed.
+func (smp *SharedMarkdownProcessor) RenderHTML(content string) (string, error) {
+	var buf strings.Builder
+	if err := smp.markdown().Convert([]byte(maskFrontMatter(content)), &buf); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrRenderFailed, err)
 	}
-	return ""
+	return buf.String(), nil
 }
 
-func (smp *SharedMarkdownProcessor) RemoveHTMLTags(text string) string {
-	anyTagRe := regexp.MustCompile(`<[^>]*>`)
-	return anyTagRe.ReplaceAllString(text, "")
+// Parse returns the goldmark document tree together with the source bytes
+// its segments point into. Front matter is blanked out of the source.
+func (smp *SharedMarkdownProcessor) Parse(content string) (ast.Node, []byte) {
+	source := []byte(maskFrontMatter(content))
+	doc := smp.markdown().Parser().Parse(text.NewReader(source))
+	return doc, source
 }
 
-func (smp *SharedMarkdownProcessor) ExtractHeaderContent(line string, level int) string {
-	var re *regexp.Regexp
-	switch level {
-	case 1:
-		re = regexp.MustCompile(`<h1[^>]*>(.*?)</h1>`)
-	case 2:
-		re = regexp.MustCompile(`<h2[^>]*>(.*?)</h2>`)
-	case 3:
-		re = regexp.MustCompile(`<h3[^>]*>(.*?)</h3>`)
-	case 4:
-		re = regexp.MustCompile(`<h4[^>]*>(.*?)</h4>`)
-	default:
-		return ""
+// textValue is what a Text node reads as: backslash escapes and entity and
+// numeric character references are resolved, as goldmark's HTML renderer
+// resolves them. The text after an escaped punctuation mark is resolved
+// apart from it, so that `\&amp;` stays as written.
+func textValue(node *ast.Text, source []byte) string {
+	value := node.Segment.Value(source)
+	if node.IsRaw() {
+		return string(value)
 	}
-
-	if matches := re.FindStringSubmatch(line); len(matches) > 1 {
-		return strings.TrimSpace(matches[1])
+	resolve := func(b []byte) []byte {
+		return util.ResolveEntityNames(util.ResolveNumericReferences(b))
 	}
-	return ""
-}
-
-func (smp *SharedMarkdownProcessor) ProcessInlineCode(content string) (string, []string) {
-	codeRe := regexp.MustCompile(`<code[^>]*>(.*?)</code>`)
-	var codeSnippets []string
-
-	content = codeRe.ReplaceAllStringFunc(content, func(match string) string {
-		matches := codeRe.FindStringSubmatch(match)
-		if len(matches) > 1 {
-			codeContent := strings.TrimSpace(matches[1])
-			codeSnippets = append(codeSnippets, codeContent)
-			return "{{CODE_PLACEHOLDER}}"
+	var buf []byte
+	start := 0
+	for i := 0; i+1 < len(value); i++ {
+		if value[i] == '\\' && util.IsPunct(value[i+1]) {
+			buf = append(buf, resolve(value[start:i])...)
+			buf = append(buf, util.UnescapePunctuations(value[i:i+2])...)
+			i++
+			start = i + 1
 		}
-		return match
-	})
-
-	return content, codeSnippets
+	}
+	return string(append(buf, resolve(value[start:])...))
 }
 
-func (smp *SharedMarkdownProcessor) ProcessInlineBold(content string) (string, []string) {
-	strongRe := regexp.MustCompile(`<strong[^>]*>(.*?)</strong>`)
-	var boldSnippets []string
-
-	content = strongRe.ReplaceAllStringFunc(content, func(match string) string {
-		matches := strongRe.FindStringSubmatch(match)
-		if len(matches) > 1 {
-			boldContent := strings.TrimSpace(matches[1])
-			boldSnippets = append(boldSnippets, boldContent)
-			return "{{BOLD_PLACEHOLDER}}"
+// PlainText flattens the inline content of a node, dropping all formatting.
+func (smp *SharedMarkdownProcessor) PlainText(n ast.Node, source []byte) string {
+	var buf strings.Builder
+	ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
 		}
-		return match
+		switch node := child.(type) {
+		case *ast.Text:
+			buf.WriteString(textValue(node, source))
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				buf.WriteString(" ")
+			}
+		case *ast.String:
+			buf.Write(node.Value)
+		case *ast.CodeSpan:
+			for c := node.FirstChild(); c != nil; c = c.NextSibling() {
+				if t, ok := c.(*ast.Text); ok {
+					buf.Write(t.Segment.Value(source))
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.AutoLink:
+			buf.Write(node.Label(source))
+		}
+		return ast.WalkContinue, nil
 	})
-
-	return content, boldSnippets
+	return strings.TrimSpace(buf.String())
 }
 
-func (smp *SharedMarkdownProcessor) ProcessInlineItalic(content string) (string, []string) {
-	emRe := regexp.MustCompile(`<em[^>]*>(.*?)</em>`)
-	var italicSnippets []string
-
-	content = emRe.ReplaceAllStringFunc(content, func(match string) string {
-		matches := emRe.FindStringSubmatch(match)
-		if len(matches) > 1 {
-			italicContent := strings.TrimSpace(matches[1])
-			italicSnippets = append(italicSnippets, italicContent)
-			return "{{ITALIC_PLACEHOLDER}}"
-		}
-		return match
-	})
+// CodeBlockText returns the raw lines of a fenced or indented code block.
+func (smp *SharedMarkdownProcessor) CodeBlockText(n ast.Node, source []byte) string {
+	var buf strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		buf.Write(segment.Value(source))
+	}
+	return strings.TrimRight(buf.String(), "\n")
+}
 
-	return content, italicSnippets
+// DocumentTitle is the title from the front matter, or else the first level
+// one heading.
+func (smp *SharedMarkdownProcessor) DocumentTitle(content string) string {
+	if fm := ParseFrontMatter(content); fm != nil && fm.Title != "" {
+		return fm.Title
+	}
+	titleRe := regexp.MustCompile(`(?m)^#\s+(.+?)\s*#*\s*$`)
+	if matches := titleRe.FindStringSubmatch(maskFrontMatter(content)); len(matches) > 1 {
+		title := strings.NewReplacer("**", "", "__", "", "*", "", "`", "").Replace(matches[1])
+		return strings.TrimSpace(title)
+	}
+	return ""
 }
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// actionItemsHeading is the section of a meeting note that holds the action
+// items, matched without regard to case.
+const actionItemsHeading = "action items"
+
+var (
+	meetingSlugRe = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+	actionItemRe  = regexp.MustCompile(`^[-*+]\s+(?:\[([ xX])\](?:\s+|$))?(.*)$`)
+)
+
+const defaultMeetingTemplate = `---
+title: {{printf "%q" .Title}}
+date: {{.Date.Format "2006-01-02"}}
+---
+# {{.Title}}
+
+## Attendees
+
+{{range .Attendees}}- {{.}}
+{{else}}-
+{{end}}
+## Agenda
+
+-
+
+## Notes
+
+## Decisions
+
+-
+
+## Action Items
+
+- [ ]
+`
+
+// MeetingsConfig is the [meetings] table. Directory is where new meeting
+// notes go and Tasks the file their action items are sent to, both
+// relative to the working directory. People is the directory of contact
+// notes: an attendee with a note there, such as people/ada-lovelace.md, is
+// linked to it. Template is a file, or the name of one in the templates
+// directory, where meeting.md is used without being named.
+type MeetingsConfig struct {
+	Directory string `toml:"directory"`
+	Tasks     string `toml:"tasks"`
+	People    string `toml:"people"`
+	Template  string `toml:"template"`
+}
+
+// MeetingNote is what a meeting note template gets. Attendees print as
+// links to their contact notes, or as their names.
+type MeetingNote struct {
+	Title     string
+	Date      time.Time
+	Attendees []string
+}
+
+func meetingSlug(s string) string {
+	return strings.Trim(meetingSlugRe.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// Path is the file of a meeting named title held on date.
+func (c MeetingsConfig) Path(title string, date time.Time) string {
+	name := date.Format("2006-01-02")
+	if slug := meetingSlug(title); slug != "" {
+		name += "-" + slug
+	}
+	return filepath.Join(c.Directory, name+".md")
+}
+
+// attendee links name to its contact note when there is one.
+func (c MeetingsConfig) attendee(name, from string) string {
+	if c.People == "" {
+		return name
+	}
+	path := filepath.Join(c.People, meetingSlug(name)+".md")
+	if _, err := os.Stat(path); err != nil {
+		return name
+	}
+	return NoteLink{Name: name, Path: relativeLink(from, path)}.String()
+}
+
+func (c MeetingsConfig) template() (string, error) {
+	var candidates []string
+	name := c.Template
+	if name == "" {
+		name = "meeting"
+	} else {
+		candidates = append(candidates, name)
+	}
+	if dir := TemplatesDir(); dir != "" && !filepath.IsAbs(name) {
+		candidates = append(candidates, filepath.Join(dir, name), filepath.Join(dir, name+".md"))
+	}
+	for _, path := range candidates {
+		if data, err := os.ReadFile(path); err == nil {
+			return string(data), nil
+		}
+	}
+	if c.Template != "" {
+		return "", fmt.Errorf("meeting template %q not found (looked in %s)", name, TemplatesDir())
+	}
+	return defaultMeetingTemplate, nil
+}
+
+// NewMeetingNote creates the note of a meeting named title with the
+// comma-separated attendees, held at now, and returns its path. A note that
+// is already there is left as it is.
+func (c MeetingsConfig) NewMeetingNote(title, attendees string, now time.Time) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", fmt.Errorf("a meeting needs a title")
+	}
+	path := c.Path(title, now)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	text, err := c.template()
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New("meeting").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("error in meeting template: %v", err)
+	}
+	note := MeetingNote{Title: title, Date: now}
+	for _, name := range strings.Split(attendees, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			note.Attendees = append(note.Attendees, c.attendee(name, filepath.Dir(path)))
+		}
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, note); err != nil {
+		return "", fmt.Errorf("error in meeting template: %v", err)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("error creating meeting note: %v", err)
+		}
+	}
+	if err := os.WriteFile(path, []byte(out.String()), 0644); err != nil {
+		return "", fmt.Errorf("error creating meeting note: %v", err)
+	}
+	return path, nil
+}
+
+// ActionItems lists the open items of the Action Items section of content,
+// task list items or plain ones, without their markers. Ticked and empty
+// items are left out.
+func (smp *SharedMarkdownProcessor) ActionItems(content string) []string {
+	headings := smp.Outline(content)
+	lines := strings.Split(content, "\n")
+	var items []string
+	for i, heading := range headings {
+		if !strings.EqualFold(strings.TrimSpace(heading.Text), actionItemsHeading) {
+			continue
+		}
+		end := len(lines)
+		for _, next := range headings[i+1:] {
+			if next.Level <= heading.Level {
+				end = next.Line
+				break
+			}
+		}
+		for _, line := range lines[heading.Line+1 : end] {
+			m := actionItemRe.FindStringSubmatch(line)
+			if m == nil || m[1] == "x" || m[1] == "X" || strings.TrimSpace(m[2]) == "" {
+				continue
+			}
+			items = append(items, strings.TrimSpace(m[2]))
+		}
+	}
+	return items
+}
+
+// SendActionItems appends the open action items of the meeting note at
+// path, holding content, to the tasks file as tasks that link back to the
+// meeting. Items the tasks file already has are skipped, so sending twice
+// adds nothing. It returns how many were added.
+func (c MeetingsConfig) SendActionItems(smp *SharedMarkdownProcessor, path, content string) (int, error) {
+	items := smp.ActionItems(content)
+	if len(items) == 0 {
+		return 0, fmt.Errorf("no open action items under an Action Items heading")
+	}
+	tasksFile := c.Tasks
+	existing, err := os.ReadFile(tasksFile)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("error reading %s: %v", tasksFile, err)
+	}
+	title := filepath.Base(path)
+	if fm := ParseFrontMatter(content); fm != nil && fm.Title != "" {
+		title = fm.Title
+	}
+	source := " (" + NoteLink{Name: title, Path: relativeLink(filepath.Dir(tasksFile), path)}.String() + ")"
+	known := map[string]bool{}
+	for _, line := range strings.Split(string(existing), "\n") {
+		if m := actionItemRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			known[strings.TrimSuffix(strings.TrimSpace(m[2]), source)] = true
+		}
+	}
+
+	var added strings.Builder
+	count := 0
+	for _, item := range items {
+		if known[item] {
+			continue
+		}
+		known[item] = true
+		added.WriteString("- [ ] " + item + source + "\n")
+		count++
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	text := string(existing)
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	if dir := filepath.Dir(tasksFile); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return 0, fmt.Errorf("error writing %s: %v", tasksFile, err)
+		}
+	}
+	if err := os.WriteFile(tasksFile, []byte(text+added.String()), 0644); err != nil {
+		return 0, fmt.Errorf("error writing %s: %v", tasksFile, err)
+	}
+	return count, nil
+}
+
+// relativeLink is the path of target as a link from a file in dir.
+func relativeLink(dir, target string) string {
+	from, err := filepath.Abs(dir)
+	if err != nil {
+		return target
+	}
+	to, err := filepath.Abs(target)
+	if err != nil {
+		return target
+	}
+	if rel, err := filepath.Rel(from, to); err == nil {
+		return rel
+	}
+	return target
+}
+
+func actionItemsStatus(count int, tasksFile string) string {
+	switch count {
+	case 0:
+		return "Every action item is already in " + tasksFile
+	case 1:
+		return "Sent 1 action item to " + tasksFile
+	}
+	return fmt.Sprintf("Sent %d action items to %s", count, tasksFile)
+}
+
+// meetingForm asks the terminal app for the title and attendees of a new
+// meeting; tab moves between the two.
+type meetingForm struct {
+	title     string
+	attendees string
+	field     int
+}
+
+func (m *model) openMeetingForm() {
+	m.overlay = overlayMeeting
+	m.meeting = &meetingForm{}
+}
+
+func (m *model) updateMeetingForm(msg tea.KeyMsg) {
+	f := m.meeting
+	input := &f.title
+	if f.field == 1 {
+		input = &f.attendees
+	}
+	switch msg.String() {
+	case "esc":
+		m.overlay = overlayNone
+		m.meeting = nil
+	case "tab", "shift+tab":
+		f.field = 1 - f.field
+	case "enter":
+		if f.field == 0 {
+			f.field = 1
+			return
+		}
+		m.overlay = overlayNone
+		m.meeting = nil
+		path, err := m.meetings.NewMeetingNote(f.title, f.attendees, time.Now())
+		if err != nil {
+			m.status = err.Error()
+			return
+		}
+		m.openBuffer(path)
+	case "backspace":
+		if runes := []rune(*input); len(runes) > 0 {
+			*input = string(runes[:len(runes)-1])
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			*input += string(msg.Runes)
+		}
+	}
+}
+
+func (m model) meetingFormView() string {
+	f := m.meeting
+	fields := []string{"Title:     " + f.title, "Attendees: " + f.attendees}
+	fields[f.field] = pickerSelectedStyle.Render(fields[f.field] + "█")
+	body := lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render("New Meeting Note"),
+		"",
+		fields[0],
+		fields[1],
+		"",
+		helpStyle.Render("attendees separated by commas • tab: next field • enter: create • esc: cancel"),
+	)
+	return pickerStyle.Render(body)
+}
+
+func (m *model) sendActionItems() {
+	path := m.filename
+	if path == "" {
+		m.status = "Save the meeting note first"
+		return
+	}
+	count, err := m.meetings.SendActionItems(m.mdProcessor, path, m.textarea.Value())
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	m.status = actionItemsStatus(count, m.meetings.Tasks)
+}
+
+func (g *GUIApp) newMeetingNote() {
+	title := widget.NewEntry()
+	title.SetPlaceHolder("Weekly sync")
+	attendees := widget.NewEntry()
+	attendees.SetPlaceHolder("Ada Lovelace, Charles Babbage")
+
+	dialog.ShowForm("New Meeting Note", "Create", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Title", title), widget.NewFormItem("Attendees", attendees)},
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			path, err := g.config.Meetings.NewMeetingNote(title.Text, attendees.Text, time.Now())
+			if err != nil {
+				dialog.ShowError(err, g.window)
+				return
+			}
+			g.confirmDiscard(func() { g.openPath(path, Location{}) })
+		}, g.window)
+}
+
+func (g *GUIApp) sendActionItems() {
+	if g.currentFile == "" {
+		dialog.ShowInformation("Send Action Items", "Save the meeting note first.", g.window)
+		return
+	}
+	count, err := g.config.Meetings.SendActionItems(g.mdProcessor, g.currentFile, g.editor.Text)
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+	dialog.ShowInformation("Send Action Items", actionItemsStatus(count, g.config.Meetings.Tasks)+".", g.window)
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// MetricsConfig is the [metrics] table. With Enabled set, parselt counts
+// the features you use and times its rendering in metrics.toml of the state
+// directory. Nothing is ever sent anywhere.
+type MetricsConfig struct {
+	Enabled bool `toml:"enabled"`
+}
+
+// RenderTiming sums up how long one kind of rendering took.
+type RenderTiming struct {
+	Count   int     `toml:"count" json:"count"`
+	TotalMS float64 `toml:"total_ms" json:"total_ms"`
+	MaxMS   float64 `toml:"max_ms" json:"max_ms"`
+}
+
+func (t RenderTiming) averageMS() float64 {
+	if t.Count == 0 {
+		return 0
+	}
+	return t.TotalMS / float64(t.Count)
+}
+
+// UsageMetrics is what metrics.toml holds: how often each feature was used
+// and the timings of each kind of rendering since Since.
+type UsageMetrics struct {
+	Since    time.Time                `toml:"since" json:"since"`
+	Features map[string]int           `toml:"features" json:"features"`
+	Timings  map[string]*RenderTiming `toml:"timings" json:"timings"`
+}
+
+func newUsageMetrics() *UsageMetrics {
+	return &UsageMetrics{Features: map[string]int{}, Timings: map[string]*RenderTiming{}}
+}
+
+func (u *UsageMetrics) add(other *UsageMetrics) {
+	if u.Since.IsZero() || (!other.Since.IsZero() && other.Since.Before(u.Since)) {
+		u.Since = other.Since
+	}
+	for name, count := range other.Features {
+		u.Features[name] += count
+	}
+	for name, t := range other.Timings {
+		sum := u.Timings[name]
+		if sum == nil {
+			sum = &RenderTiming{}
+			u.Timings[name] = sum
+		}
+		sum.Count += t.Count
+		sum.TotalMS += t.TotalMS
+		sum.MaxMS = max(sum.MaxMS, t.MaxMS)
+	}
+}
+
+// metrics gathers what this run records until flushMetrics adds it to the
+// file. It stays nil unless metrics are enabled.
+var (
+	metricsMu sync.Mutex
+	metrics   *UsageMetrics
+)
+
+func metricsPath() string {
+	return filepath.Join(stateDir(), "metrics.toml")
+}
+
+// enableMetrics starts recording when cfg asks for it.
+func enableMetrics(cfg MetricsConfig) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	if cfg.Enabled && metrics == nil {
+		metrics = newUsageMetrics()
+		metrics.Since = time.Now()
+	}
+}
+
+// countFeature notes one use of the feature name.
+func countFeature(name string) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	if metrics != nil {
+		metrics.Features[name]++
+	}
+}
+
+// timeRender notes a rendering of kind that began at start; defer it with
+// time.Now() as start.
+func timeRender(kind string, start time.Time) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	if metrics == nil {
+		return
+	}
+	ms := float64(time.Since(start).Microseconds()) / 1000
+	t := metrics.Timings[kind]
+	if t == nil {
+		t = &RenderTiming{}
+		metrics.Timings[kind] = t
+	}
+	t.Count++
+	t.TotalMS += ms
+	t.MaxMS = max(t.MaxMS, ms)
+}
+
+func loadMetrics() (*UsageMetrics, error) {
+	stored := newUsageMetrics()
+	if _, err := os.Stat(metricsPath()); os.IsNotExist(err) {
+		return stored, nil
+	}
+	if _, err := toml.DecodeFile(metricsPath(), stored); err != nil {
+		return stored, fmt.Errorf("error reading metrics: %v", err)
+	}
+	if stored.Features == nil {
+		stored.Features = map[string]int{}
+	}
+	if stored.Timings == nil {
+		stored.Timings = map[string]*RenderTiming{}
+	}
+	return stored, nil
+}
+
+func (u *UsageMetrics) save() error {
+	if err := os.MkdirAll(filepath.Dir(metricsPath()), 0755); err != nil {
+		return fmt.Errorf("error saving metrics: %v", err)
+	}
+	file, err := os.Create(metricsPath())
+	if err != nil {
+		return fmt.Errorf("error saving metrics: %v", err)
+	}
+	defer file.Close()
+	if err := toml.NewEncoder(file).Encode(u); err != nil {
+		return fmt.Errorf("error saving metrics: %v", err)
+	}
+	return nil
+}
+
+// flushMetrics adds what this run recorded to metrics.toml.
+func flushMetrics() {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	if metrics == nil || (len(metrics.Features) == 0 && len(metrics.Timings) == 0) {
+		return
+	}
+	stored, err := loadMetrics()
+	if err == nil {
+		stored.add(metrics)
+		err = stored.save()
+	}
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	since := metrics.Since
+	metrics = newUsageMetrics()
+	metrics.Since = since
+}
+
+// countKey notes the use of the terminal binding msg matches, by its
+// [keys] name.
+func (k *keyMap) countKey(msg tea.KeyMsg) {
+	metricsMu.Lock()
+	on := metrics != nil
+	metricsMu.Unlock()
+	if !on {
+		return
+	}
+	for name, binding := range k.byName() {
+		if key.Matches(msg, *binding) {
+			countFeature(name)
+			return
+		}
+	}
+}
+
+// countMenuItems makes the items of menus count their use by label.
+func countMenuItems(menus []*fyne.Menu) {
+	var wrap func(items []*fyne.MenuItem)
+	wrap = func(items []*fyne.MenuItem) {
+		for _, item := range items {
+			if item.ChildMenu != nil {
+				wrap(item.ChildMenu.Items)
+			}
+			if action, label := item.Action, item.Label; action != nil {
+				item.Action = func() {
+					countFeature(label)
+					action()
+				}
+			}
+		}
+	}
+	for _, menu := range menus {
+		wrap(menu.Items)
+	}
+}
+
+// MetricsReport lays out u for the terminal.
+func MetricsReport(u *UsageMetrics) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Usage since %s\n", u.Since.Format("2006-01-02"))
+
+	names := make([]string, 0, len(u.Features))
+	for name := range u.Features {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := u.Features[names[i]], u.Features[names[j]]
+		return a > b || a == b && names[i] < names[j]
+	})
+	if len(names) > 0 {
+		fmt.Fprintln(w, "\nFeature\tUses")
+		for _, name := range names {
+			fmt.Fprintf(w, "%s\t%d\n", name, u.Features[name])
+		}
+	}
+
+	kinds := make([]string, 0, len(u.Timings))
+	for kind := range u.Timings {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	if len(kinds) > 0 {
+		fmt.Fprintln(w, "\nRendering\tCount\tAverage\tSlowest")
+		for _, kind := range kinds {
+			t := u.Timings[kind]
+			fmt.Fprintf(w, "%s\t%d\t%.1f ms\t%.1f ms\n", kind, t.Count, t.averageMS(), t.MaxMS)
+		}
+	}
+	w.Flush()
+	return b.String()
+}
+
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	app := fs.Bool("app", false, "show the usage metrics of parselt instead of document statistics")
+	asJSON := fs.Bool("json", false, "print the statistics, or the usage metrics with -app, as JSON")
+	reset := fs.Bool("reset", false, "delete the usage metrics recorded so far")
+	fs.StringVar(&ActiveProfile, "profile", "", "configuration profile to use")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: parselt stats [-json] file.md...\n       parselt stats [-json] directory\n       parselt stats -app [-json] [-reset]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*app && !*reset {
+		if fs.NArg() == 0 {
+			fs.Usage()
+			return fmt.Errorf("expected at least one file, or -app for the usage metrics")
+		}
+		if fs.NArg() == 1 {
+			if info, err := os.Stat(fs.Arg(0)); err == nil && info.IsDir() {
+				return printWorkspaceStats(fs.Arg(0), *asJSON)
+			}
+		}
+		return printDocumentStats(fs.Args(), *asJSON)
+	}
+
+	if *reset {
+		if err := os.Remove(metricsPath()); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error deleting metrics: %v", err)
+		}
+		fmt.Println("Deleted the usage metrics")
+		return nil
+	}
+	stored, err := loadMetrics()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		data, err := json.MarshalIndent(stored, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	if len(stored.Features) == 0 && len(stored.Timings) == 0 {
+		cfg, _ := LoadConfig()
+		if !cfg.Metrics.Enabled {
+			fmt.Printf("No usage recorded. Set enabled = true in the [metrics] table of %s to start.\n", ConfigPath())
+			return nil
+		}
+		fmt.Println("No usage recorded yet.")
+		return nil
+	}
+	fmt.Print(MetricsReport(stored))
+	return nil
+}
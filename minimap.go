@@ -0,0 +1,329 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// MapMark is what a line of a document is, as the document map shows it.
+// When several lines share a row of the map the greatest mark is shown.
+type MapMark int
+
+const (
+	MarkBlank MapMark = iota
+	MarkText
+	MarkCode
+	MarkSubheading
+	MarkHeading
+	MarkMatch
+)
+
+// mapWidth is how many columns the terminal document map takes.
+const mapWidth = 2
+
+// guiMapWidth is the width of the GUI document map in pixels.
+const guiMapWidth = 48
+
+// DocumentMap marks every line of content as blank, text, code or a
+// heading. Headings of the top two levels are MarkHeading, deeper ones
+// MarkSubheading.
+func (smp *SharedMarkdownProcessor) DocumentMap(content string) []MapMark {
+	lines := strings.Split(content, "\n")
+	marks := make([]MapMark, len(lines))
+	fence := ""
+	for i, line := range lines {
+		matches := fenceRe.FindStringSubmatch(line)
+		switch {
+		case fence != "":
+			marks[i] = MarkCode
+			if matches != nil && matches[1] == fence {
+				fence = ""
+			}
+		case matches != nil:
+			marks[i], fence = MarkCode, matches[1]
+		case strings.TrimSpace(line) != "":
+			marks[i] = MarkText
+		}
+	}
+	for _, heading := range smp.Outline(content) {
+		if heading.Line >= len(marks) {
+			continue
+		}
+		marks[heading.Line] = MarkSubheading
+		if heading.Level <= 2 {
+			marks[heading.Line] = MarkHeading
+		}
+	}
+	return marks
+}
+
+// MarkMatches returns marks with the lines of content that contain query,
+// in any case, marked as matches. marks is left alone.
+func MarkMatches(marks []MapMark, content, query string) []MapMark {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" || strings.Contains(query, "\n") {
+		return marks
+	}
+	marked := append([]MapMark(nil), marks...)
+	for i, line := range strings.Split(content, "\n") {
+		if i < len(marked) && strings.Contains(strings.ToLower(line), query) {
+			marked[i] = MarkMatch
+		}
+	}
+	return marked
+}
+
+// mapRows shrinks marks to rows, each row showing the greatest mark of the
+// lines it stands for. A document shorter than rows takes a row per line.
+func mapRows(marks []MapMark, rows int) []MapMark {
+	if len(marks) <= rows {
+		return marks
+	}
+	shrunk := make([]MapMark, rows)
+	for i, mark := range marks {
+		row := i * rows / len(marks)
+		shrunk[row] = max(shrunk[row], mark)
+	}
+	return shrunk
+}
+
+// mapLine is the first line of a document of lines lines that row of a map
+// rows high stands for.
+func mapLine(row, rows, lines int) int {
+	if lines <= rows {
+		return min(row, lines-1)
+	}
+	return (row*lines + rows - 1) / rows
+}
+
+var (
+	mapTextStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#626262"))
+	mapCodeStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#5F87AF"))
+	mapHeadingStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#7D56F4"))
+	mapMatchStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFD75F"))
+	mapCursorStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575"))
+)
+
+// toggleDocMap shows or hides the document map beside the editor.
+func (m *model) toggleDocMap() {
+	m.docMap = !m.docMap
+	if m.docMap {
+		m.mapMarks = m.mdProcessor.DocumentMap(m.textarea.Value())
+	}
+	m.layout()
+}
+
+// docMapWidth is how many columns the document map takes from the editor.
+func (m model) docMapWidth() int {
+	if !m.docMap || m.mode == previewMode {
+		return 0
+	}
+	return mapWidth
+}
+
+// mapQuery is the text the map marks the matches of: the selection.
+func (m model) mapQuery() string {
+	if m.selection == nil {
+		return ""
+	}
+	return m.textarea.Value()[m.selection.Start:m.selection.End]
+}
+
+// withDocMap puts the document map right of the editor, as tall as it. The
+// row of the cursor is green.
+func (m model) withDocMap(editor string) string {
+	if m.docMapWidth() == 0 {
+		return editor
+	}
+	rows := lipgloss.Height(editor)
+	marks := mapRows(MarkMatches(m.mapMarks, m.textarea.Value(), m.mapQuery()), rows)
+	lines := max(len(m.mapMarks), 1)
+	cursor := m.textarea.Line() * min(rows, lines) / lines
+
+	column := make([]string, rows)
+	for row := range column {
+		mark := MarkBlank
+		if row < len(marks) {
+			mark = marks[row]
+		}
+		var glyph string
+		style := mapTextStyle
+		switch mark {
+		case MarkBlank:
+			glyph = "  "
+		case MarkText:
+			glyph = "░░"
+		case MarkCode:
+			glyph, style = "▒▒", mapCodeStyle
+		case MarkSubheading:
+			glyph, style = "▓▓", mapHeadingStyle
+		case MarkHeading:
+			glyph, style = "██", mapHeadingStyle
+		case MarkMatch:
+			glyph, style = "██", mapMatchStyle
+		}
+		if row == cursor {
+			style = mapCursorStyle
+			if mark <= MarkText {
+				glyph = "━━"
+			}
+		}
+		column[row] = style.Render(glyph)
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, editor, strings.Join(column, "\n"))
+}
+
+// clickDocMap jumps to the part of the document clicked on in the map. It
+// reports false when screen cell x, y is not in the map.
+func (m *model) clickDocMap(x, y int) bool {
+	if m.docMapWidth() == 0 {
+		return false
+	}
+	left, _ := m.sideWidths()
+	end := left + m.mainWidth()
+	if m.mode == splitMode && !m.splitStacked() {
+		end = left + m.editorColumns()
+	}
+	row, rows := y-lipgloss.Height(m.headerView()), m.editorHeight()
+	if x < end-mapWidth || x >= end || row < 0 || row >= rows {
+		return false
+	}
+	m.jumpTo(mapLine(row, rows, m.textarea.LineCount()), 0)
+	return true
+}
+
+// docMapStrip is the GUI document map: a strip of bars, one per line or
+// group of lines, that moves the cursor to the line clicked or dragged to.
+type docMapStrip struct {
+	widget.BaseWidget
+	raster   *canvas.Raster
+	marks    []MapMark
+	cursor   int
+	rows     int
+	dragging bool
+	// jump moves the cursor to line, remembering where it was in the jump
+	// list when push is set
+	jump func(line int, push bool)
+}
+
+func newDocMapStrip(jump func(line int, push bool)) *docMapStrip {
+	s := &docMapStrip{jump: jump}
+	s.raster = canvas.NewRaster(s.draw)
+	s.raster.SetMinSize(fyne.NewSize(guiMapWidth, 0))
+	s.ExtendBaseWidget(s)
+	return s
+}
+
+func (s *docMapStrip) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(s.raster)
+}
+
+// update shows marks, with the cursor on line cursor.
+func (s *docMapStrip) update(marks []MapMark, cursor int) {
+	s.marks, s.cursor = marks, cursor
+	s.raster.Refresh()
+}
+
+// draw gives each row of the map 3 pixels, a line of the document to a row
+// until the document is taller than the strip.
+func (s *docMapStrip) draw(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	s.rows = max(h/3, 1)
+	if len(s.marks) == 0 {
+		return img
+	}
+	th := s.Theme()
+	variant := fyne.CurrentApp().Settings().ThemeVariant()
+	rowHeight := float32(h) / float32(s.rows)
+	fill := func(row int, width float32, c color.Color) {
+		for y := int(float32(row) * rowHeight); y < int(float32(row+1)*rowHeight) && y < h; y++ {
+			for x := 0; x < int(float32(w)*width); x++ {
+				img.Set(x, y, c)
+			}
+		}
+	}
+
+	fill(s.cursor*min(s.rows, len(s.marks))/len(s.marks), 1, th.Color(theme.ColorNameSelection, variant))
+	for row, mark := range mapRows(s.marks, s.rows) {
+		switch mark {
+		case MarkText:
+			fill(row, 0.5, th.Color(theme.ColorNameDisabled, variant))
+		case MarkCode:
+			fill(row, 0.7, th.Color(theme.ColorNamePlaceHolder, variant))
+		case MarkSubheading:
+			fill(row, 0.85, th.Color(theme.ColorNamePrimary, variant))
+		case MarkHeading:
+			fill(row, 1, th.Color(theme.ColorNamePrimary, variant))
+		case MarkMatch:
+			fill(row, 1, th.Color(theme.ColorNameWarning, variant))
+		}
+	}
+	return img
+}
+
+func (s *docMapStrip) Tapped(e *fyne.PointEvent) {
+	s.jumpTo(e.Position.Y, true)
+}
+
+// Dragged keeps only the start of the drag in the jump list.
+func (s *docMapStrip) Dragged(e *fyne.DragEvent) {
+	s.jumpTo(e.Position.Y, !s.dragging)
+	s.dragging = true
+}
+
+func (s *docMapStrip) DragEnd() {
+	s.dragging = false
+}
+
+func (s *docMapStrip) jumpTo(y float32, push bool) {
+	if len(s.marks) == 0 || s.rows == 0 || s.Size().Height <= 0 {
+		return
+	}
+	row := int(y / s.Size().Height * float32(s.rows))
+	s.jump(mapLine(min(max(row, 0), s.rows-1), s.rows, len(s.marks)), push)
+}
+
+// toggleDocMap shows or hides the document map right of the editor.
+func (g *GUIApp) toggleDocMap() {
+	if g.docMap.Visible() {
+		g.docMap.Hide()
+	} else {
+		g.docMap.Show()
+		g.mapMarks = g.mdProcessor.DocumentMap(g.editor.Text)
+		g.updateDocMap()
+	}
+	g.docMapItem.Checked = g.docMap.Visible()
+}
+
+// updateDocMap draws the map again with the cursor where it is, marking the
+// matches of the selection. The marks of the lines are those of the last
+// count.
+func (g *GUIApp) updateDocMap() {
+	if !g.docMap.Visible() {
+		return
+	}
+	g.docMap.update(MarkMatches(g.mapMarks, g.editor.Text, g.editor.SelectedText()), g.editor.CursorRow)
+}
+
+// mapJump moves the editor cursor to a line picked on the map.
+func (g *GUIApp) mapJump(line int, push bool) {
+	if line == g.editor.CursorRow {
+		return
+	}
+	if push {
+		g.pushJump()
+	}
+	g.editor.CursorRow, g.editor.CursorColumn = line, 0
+	g.editor.Refresh()
+	g.window.Canvas().Focus(g.editor)
+	if g.previewSyncing() {
+		g.scrollPreviewTo(line)
+	}
+}
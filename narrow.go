@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2/dialog"
+)
+
+// Narrowing is a document cut down to the section under one heading for
+// editing. Before and After are the text around the section, kept as they
+// were; put back around the section they make the whole document again.
+// Line is the line of the heading in the whole document.
+type Narrowing struct {
+	Before string
+	After  string
+	Title  string
+	Line   int
+}
+
+// NarrowSection cuts content down to the section of the heading on row or
+// the nearest one above it: the heading and the lines up to the next
+// heading of the same or a higher level. It returns false above the first
+// heading.
+func (smp *SharedMarkdownProcessor) NarrowSection(content string, row int) (*Narrowing, string, bool) {
+	headings := smp.Outline(content)
+	found := -1
+	for i, heading := range headings {
+		if heading.Line > row {
+			break
+		}
+		found = i
+	}
+	if found < 0 {
+		return nil, "", false
+	}
+
+	lines := strings.Split(content, "\n")
+	heading := headings[found]
+	end := len(lines)
+	for _, next := range headings[found+1:] {
+		if next.Level <= heading.Level {
+			end = next.Line
+			break
+		}
+	}
+	n := &Narrowing{Title: heading.Text, Line: heading.Line}
+	if heading.Line > 0 {
+		n.Before = strings.Join(lines[:heading.Line], "\n") + "\n"
+	}
+	if end < len(lines) {
+		n.After = "\n" + strings.Join(lines[end:], "\n")
+	}
+	return n, strings.Join(lines[heading.Line:end], "\n"), true
+}
+
+// Widen puts section back in its place in the whole document.
+func (n *Narrowing) Widen(section string) string {
+	if n == nil {
+		return section
+	}
+	return n.Before + section + n.After
+}
+
+// Section is what of document lies between the text kept around the
+// section, or false when an undo or a change from outside touched that
+// text too.
+func (n *Narrowing) Section(document string) (string, bool) {
+	if len(document) < len(n.Before)+len(n.After) ||
+		!strings.HasPrefix(document, n.Before) || !strings.HasSuffix(document, n.After) {
+		return "", false
+	}
+	return document[len(n.Before) : len(document)-len(n.After)], true
+}
+
+// lineOffset is how far the lines of the section are from those of the
+// whole document.
+func (n *Narrowing) lineOffset() int {
+	if n == nil {
+		return 0
+	}
+	return n.Line
+}
+
+// narrowed is what the editor shows of document: the section of n when
+// document still has the text around it, and otherwise the whole document,
+// widened.
+func narrowed(n *Narrowing, document string) (string, *Narrowing) {
+	if n == nil {
+		return document, nil
+	}
+	if section, ok := n.Section(document); ok {
+		return section, n
+	}
+	return document, nil
+}
+
+// document is the whole text of the buffer, narrowed or not.
+func (b buffer) document() string {
+	return b.narrow.Widen(b.textarea.Value())
+}
+
+// document is the whole text of the active buffer, narrowed or not.
+func (m model) document() string {
+	return m.narrow.Widen(m.textarea.Value())
+}
+
+// setDocument replaces the whole text of the active buffer, staying
+// narrowed when the text around the section is the same.
+func (m *model) setDocument(document string) {
+	text, narrow := narrowed(m.narrow, document)
+	if narrow == nil && m.narrow != nil {
+		m.status = "Widened: the change reached outside the section"
+	}
+	m.narrow = narrow
+	m.textarea.SetValue(text)
+	m.content = text
+}
+
+// toggleNarrow narrows the editor to the section the cursor is in, or
+// widens it back to the whole document.
+func (m *model) toggleNarrow() {
+	m.clearSelection()
+	row := m.textarea.Line()
+	info := m.textarea.LineInfo()
+	col := info.StartColumn + info.ColumnOffset
+	if m.narrow != nil {
+		row += m.narrow.Line
+		m.textarea.SetValue(m.document())
+		m.narrow = nil
+		m.status = "Widened to the whole document"
+	} else {
+		narrow, section, ok := m.mdProcessor.NarrowSection(m.textarea.Value(), row)
+		if !ok {
+			m.status = "No section here: narrowing starts at a heading"
+			return
+		}
+		row -= narrow.Line
+		m.narrow = narrow
+		m.textarea.SetValue(section)
+		m.status = fmt.Sprintf("Narrowed to %s", narrow.Title)
+	}
+	moveCursorTo(&m.textarea, row, col)
+	m.content = m.textarea.Value()
+	m.jumps = JumpList{}
+	if m.mode != editMode {
+		m.refreshPreview()
+	}
+}
+
+// document is the whole text of the GUI editor, narrowed or not.
+func (g *GUIApp) document() string {
+	return g.narrow.Widen(g.editor.Text)
+}
+
+// showDocument puts a document that was just opened, reloaded or restored
+// in the editor, dropping any narrowing.
+func (g *GUIApp) showDocument(text string) {
+	g.narrow = nil
+	g.narrowItems()
+	g.editor.SetText(text)
+}
+
+// setDocument replaces the whole text of the editor, staying narrowed when
+// the text around the section is the same.
+func (g *GUIApp) setDocument(document string) {
+	text, narrow := narrowed(g.narrow, document)
+	g.narrow = narrow
+	g.narrowItems()
+	g.editor.SetText(text)
+}
+
+// toggleNarrow narrows the editor to the section the cursor is in, or
+// widens it back to the whole document.
+func (g *GUIApp) toggleNarrow() {
+	row, col := g.editor.CursorRow, g.editor.CursorColumn
+	var text string
+	if g.narrow != nil {
+		row += g.narrow.Line
+		text = g.document()
+		g.narrow = nil
+	} else {
+		narrow, section, ok := g.mdProcessor.NarrowSection(g.editor.Text, row)
+		if !ok {
+			dialog.ShowInformation("Narrow to Section", "Narrowing starts at a heading; move the cursor below one.", g.window)
+			g.narrowItems()
+			return
+		}
+		row -= narrow.Line
+		g.narrow = narrow
+		text = section
+	}
+	g.narrowItems()
+	g.editor.SetText(text)
+	g.editor.CursorRow, g.editor.CursorColumn = row, col
+	g.editor.Refresh()
+	g.jumps = JumpList{}
+}
+
+// narrowItems checks the menu item while the editor is narrowed.
+func (g *GUIApp) narrowItems() {
+	if g.narrowItem != nil {
+		g.narrowItem.Checked = g.narrow != nil
+	}
+}
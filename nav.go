@@ -0,0 +1,208 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var navTagRe = regexp.MustCompile(`<(/?)([A-Za-z][A-Za-z0-9-]*)(?:\s[^<>]*)?>`)
+
+// navVoidTags never have a closing tag.
+var navVoidTags = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// navKinds are the constructs whose ends MatchingElement jumps between.
+var navKinds = map[string]bool{
+	"code block": true,
+	"list item":  true,
+	"quote":      true,
+	"html":       true,
+}
+
+// MatchingElement returns the byte offset to jump to from pos: the other tag
+// of an HTML tag pair when pos is on a tag, or else the other end of the
+// innermost code block, list item, blockquote or HTML block around pos. From
+// the first line of a construct it goes to its last line, and from anywhere
+// else back to its start.
+func (smp *SharedMarkdownProcessor) MatchingElement(content string, pos int) (int, bool) {
+	if target, ok := matchingTag(content, pos); ok {
+		return target, true
+	}
+
+	for _, r := range smp.selectionRanges(content, pos) {
+		if !navKinds[r.Kind] || pos < r.Start || pos > r.End {
+			continue
+		}
+		lastLine := strings.LastIndex(content[:r.End], "\n") + 1
+		firstLineEnd := r.End
+		if i := strings.Index(content[r.Start:r.End], "\n"); i >= 0 {
+			firstLineEnd = r.Start + i
+		}
+		switch {
+		case pos > firstLineEnd:
+			return r.Start, true
+		case lastLine > r.Start:
+			return lastLine, true
+		case pos != r.End:
+			// A construct on a single line
+			return r.End, true
+		default:
+			return r.Start, true
+		}
+	}
+	return pos, false
+}
+
+// matchingTag finds the tag pos is on and returns the start of its partner,
+// skipping nested tags of the same name.
+func matchingTag(content string, pos int) (int, bool) {
+	tags := navTagRe.FindAllStringSubmatchIndex(content, -1)
+	for i, tag := range tags {
+		if pos < tag[0] || pos >= tag[1] {
+			continue
+		}
+		name := strings.ToLower(content[tag[4]:tag[5]])
+		closing := tag[3] > tag[2]
+		if navVoidTags[name] || strings.HasSuffix(content[tag[0]:tag[1]], "/>") {
+			return pos, false
+		}
+
+		depth := 0
+		step := 1
+		if closing {
+			step = -1
+		}
+		for j := i; j >= 0 && j < len(tags); j += step {
+			other := tags[j]
+			if strings.ToLower(content[other[4]:other[5]]) != name {
+				continue
+			}
+			if (other[3] > other[2]) == closing {
+				depth++
+			} else {
+				depth--
+			}
+			if depth == 0 {
+				return other[0], true
+			}
+		}
+		return pos, false
+	}
+	return pos, false
+}
+
+// adjacentLine returns the first of lines after row (delta 1) or the last
+// before it (delta -1). lines must be in order.
+func adjacentLine(lines []int, row, delta int) (int, bool) {
+	if delta > 0 {
+		for _, line := range lines {
+			if line > row {
+				return line, true
+			}
+		}
+		return row, false
+	}
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i] < row {
+			return lines[i], true
+		}
+	}
+	return row, false
+}
+
+// HeadingLine returns the line of the next or previous heading from row.
+func (smp *SharedMarkdownProcessor) HeadingLine(content string, row, delta int) (int, bool) {
+	var lines []int
+	for _, heading := range smp.Outline(content) {
+		lines = append(lines, heading.Line)
+	}
+	return adjacentLine(lines, row, delta)
+}
+
+// CodeBlockLine returns the opening fence line of the next or previous code
+// block from row.
+func CodeBlockLine(content string, row, delta int) (int, bool) {
+	var starts []int
+	fence := ""
+	for i, line := range strings.Split(content, "\n") {
+		matches := fenceRe.FindStringSubmatch(line)
+		switch {
+		case matches == nil:
+		case fence == "":
+			fence = matches[1]
+			starts = append(starts, i)
+		case matches[1] == fence:
+			fence = ""
+		}
+	}
+	return adjacentLine(starts, row, delta)
+}
+
+func (m *model) jumpToMatch() {
+	content := m.textarea.Value()
+	target, ok := m.mdProcessor.MatchingElement(content, m.cursorOffset())
+	if !ok {
+		m.status = "No matching element"
+		return
+	}
+	row, col := rowColumn(content, target)
+	m.jumpTo(row, col)
+}
+
+func (m *model) jumpHeading(delta int) {
+	line, ok := m.mdProcessor.HeadingLine(m.textarea.Value(), m.textarea.Line(), delta)
+	if !ok {
+		m.status = "No more headings"
+		return
+	}
+	m.jumpTo(line, 0)
+}
+
+func (m *model) jumpCodeBlock(delta int) {
+	line, ok := CodeBlockLine(m.textarea.Value(), m.textarea.Line(), delta)
+	if !ok {
+		m.status = "No more code blocks"
+		return
+	}
+	m.jumpTo(line, 0)
+}
+
+// jumpTo moves the cursor and remembers where it was in the jump list.
+func (m *model) jumpTo(row, col int) {
+	m.jumps.Push(m.jumpPosition())
+	m.moveTo(row, col)
+}
+
+func (m *model) moveTo(row, col int) {
+	m.clearSelection()
+	moveCursorTo(&m.textarea, row, col)
+	if m.mode == splitMode {
+		m.syncPreviewScroll()
+	}
+}
+
+// jumpPosition is where the cursor is, as the jump list keeps it.
+func (m *model) jumpPosition() JumpPosition {
+	info := m.textarea.LineInfo()
+	return JumpPosition{Line: m.textarea.Line(), Column: info.StartColumn + info.ColumnOffset}
+}
+
+func (m *model) jumpBack() {
+	pos, ok := m.jumps.Back(m.jumpPosition())
+	if !ok {
+		m.status = "No earlier jump"
+		return
+	}
+	m.moveTo(pos.Line, pos.Column)
+}
+
+func (m *model) jumpForward() {
+	pos, ok := m.jumps.Forward()
+	if !ok {
+		m.status = "No later jump"
+		return
+	}
+	m.moveTo(pos.Line, pos.Column)
+}
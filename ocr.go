@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// OCRConfig is the [ocr] table. Languages are the tesseract languages to
+// read, such as "eng+deu"; empty leaves it to tesseract.
+type OCRConfig struct {
+	Languages string `toml:"languages"`
+}
+
+// RecognizeImage reads the text of the image at path with tesseract, which
+// has to be installed.
+func RecognizeImage(path string, cfg OCRConfig) (string, error) {
+	args := []string{path, "stdout"}
+	if cfg.Languages != "" {
+		args = append(args, "-l", cfg.Languages)
+	}
+	out, err := exec.Command("tesseract", args...).Output()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return "", fmt.Errorf("recognizing text needs tesseract, which comes with tesseract-ocr")
+	case errors.As(err, &exitErr):
+		return "", fmt.Errorf("error recognizing text in %s: %s", filepath.Base(path), strings.TrimSpace(string(exitErr.Stderr)))
+	case err != nil:
+		return "", fmt.Errorf("error recognizing text in %s: %v", filepath.Base(path), err)
+	}
+	return string(out), nil
+}
+
+// clipboardImage saves the image on the clipboard, such as a screenshot, as
+// a PNG file at path. Outside Windows it needs pngpaste on macOS, and
+// wl-paste or xclip on Linux.
+func clipboardImage(path string) error {
+	var cmd *exec.Cmd
+	tool, stdout := "", true
+	switch {
+	case runtime.GOOS == "windows":
+		cmd, stdout = exec.Command("powershell", "-NoProfile", "-Command",
+			"Add-Type -AssemblyName System.Windows.Forms; $i = [System.Windows.Forms.Clipboard]::GetImage(); "+
+				"if ($i -eq $null) { exit 1 }; $i.Save('"+strings.ReplaceAll(path, "'", "''")+"')"), false
+	case runtime.GOOS == "darwin":
+		cmd, tool, stdout = exec.Command("pngpaste", path), "pngpaste", false
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		cmd, tool = exec.Command("wl-paste", "--no-newline", "--type", "image/png"), "wl-paste"
+	default:
+		cmd, tool = exec.Command("xclip", "-selection", "clipboard", "-target", "image/png", "-out"), "xclip"
+	}
+	if stdout {
+		out, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("error saving the clipboard image: %v", err)
+		}
+		defer out.Close()
+		cmd.Stdout = out
+	}
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("reading images from the clipboard needs %s", tool)
+	}
+	if info, statErr := os.Stat(path); err != nil || statErr != nil || info.Size() == 0 {
+		return fmt.Errorf("no image on the clipboard")
+	}
+	return nil
+}
+
+// imageRef is a local image linked in a document.
+type imageRef struct {
+	link TextRange
+	path string
+}
+
+// imageAt is the image link at byte offset pos of content, or nil when there
+// is none. Images are looked up relative to the document at docPath.
+func imageAt(content string, pos int, docPath string) (*imageRef, error) {
+	start := strings.LastIndex(content[:pos], "\n") + 1
+	end := len(content)
+	if i := strings.Index(content[pos:], "\n"); i >= 0 {
+		end = pos + i
+	}
+	for _, m := range peekImageRe.FindAllStringSubmatchIndex(content[start:end], -1) {
+		if m[0] > pos-start || pos-start > m[1] {
+			continue
+		}
+		src := linkDestination(content[start+m[4] : start+m[5]])
+		if strings.Contains(src, "://") {
+			return nil, fmt.Errorf("text can only be recognized in local images")
+		}
+		return &imageRef{link: TextRange{Start: start + m[0], End: start + m[1]}, path: peekPath(src, docPath)}, nil
+	}
+	return nil, nil
+}
+
+// ocrBullets are the list markers tesseract reads at the start of a line.
+var ocrBullets = []string{"• ", "· ", "▪ ", "- ", "* "}
+
+// OCRMarkdown turns the text tesseract read into markdown: the lines of a
+// paragraph are joined, words it hyphenated at the end of a line are put
+// back together, bulleted lines become list items, and everything else is
+// escaped so that it shows as it was read.
+func OCRMarkdown(text string) string {
+	type block struct {
+		bullet bool
+		text   string
+	}
+	text = blankLinesRe.ReplaceAllString(strings.ReplaceAll(strings.TrimSpace(text), "\f", "\n\n"), "\n\n")
+	var paragraphs []string
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		var blocks []block
+		for _, line := range strings.Split(paragraph, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			bullet := false
+			for _, marker := range ocrBullets {
+				if strings.HasPrefix(line, marker) {
+					line, bullet = strings.TrimSpace(line[len(marker):]), true
+					break
+				}
+			}
+			if bullet || len(blocks) == 0 {
+				blocks = append(blocks, block{bullet: bullet, text: line})
+				continue
+			}
+			last := &blocks[len(blocks)-1]
+			if r, _ := utf8.DecodeRuneInString(line); strings.HasSuffix(last.text, "-") && unicode.IsLower(r) {
+				last.text = strings.TrimSuffix(last.text, "-") + line
+			} else {
+				last.text += " " + line
+			}
+		}
+		lines := make([]string, len(blocks))
+		for i, b := range blocks {
+			lines[i] = escapeMarkdown(b.text)
+			if b.bullet {
+				lines[i] = "- " + lines[i]
+			}
+		}
+		if len(lines) > 0 {
+			paragraphs = append(paragraphs, strings.Join(lines, "\n"))
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// OCRQuote is markdown as a blockquote, below image when that is not empty.
+func OCRQuote(markdown, image string) string {
+	if image != "" {
+		markdown = image + "\n\n" + markdown
+	}
+	lines := strings.Split(markdown, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RecognizeText reads the text of the image linked at byte offset pos of
+// content, or else of the image on the clipboard, and returns the markdown
+// to put in and the range of content it replaces. Recognized text from a
+// linked image goes below the line of the image; with quote it is a
+// blockquote that the image moves into when it has a line of its own. A
+// clipboard image goes in at pos, copied next to the document at docPath
+// when it is quoted.
+func RecognizeText(content string, pos int, docPath string, cfg OCRConfig, opts ImageOptions, quote bool) (TextRange, string, error) {
+	img, err := imageAt(content, pos, docPath)
+	if err != nil {
+		return TextRange{}, "", err
+	}
+	if img != nil {
+		text, err := RecognizeImage(img.path, cfg)
+		if err != nil {
+			return TextRange{}, "", err
+		}
+		markdown := OCRMarkdown(text)
+		if markdown == "" {
+			return TextRange{}, "", fmt.Errorf("no text found in %s", filepath.Base(img.path))
+		}
+		lineStart := strings.LastIndex(content[:img.link.Start], "\n") + 1
+		lineEnd := len(content)
+		if i := strings.Index(content[img.link.End:], "\n"); i >= 0 {
+			lineEnd = img.link.End + i
+		}
+		link := content[img.link.Start:img.link.End]
+		if quote && strings.TrimSpace(content[lineStart:lineEnd]) == link {
+			return TextRange{Start: lineStart, End: lineEnd}, OCRQuote(markdown, link), nil
+		}
+		if quote {
+			markdown = OCRQuote(markdown, "")
+		}
+		return TextRange{Start: lineEnd, End: lineEnd}, "\n\n" + markdown, nil
+	}
+
+	dir, err := os.MkdirTemp("", "parselt-ocr")
+	if err != nil {
+		return TextRange{}, "", fmt.Errorf("error saving the clipboard image: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	shot := filepath.Join(dir, "screenshot.png")
+	if err := clipboardImage(shot); err != nil {
+		return TextRange{}, "", fmt.Errorf("no image at the cursor and %v", err)
+	}
+	text, err := RecognizeImage(shot, cfg)
+	if err != nil {
+		return TextRange{}, "", err
+	}
+	markdown := OCRMarkdown(text)
+	if markdown == "" {
+		return TextRange{}, "", fmt.Errorf("no text found in the clipboard image")
+	}
+	if quote {
+		embedded, err := EmbedImage(shot, docPath, opts)
+		if err != nil {
+			return TextRange{}, "", err
+		}
+		markdown = OCRQuote(markdown, embedded.Markdown(""))
+	}
+	if pos > 0 && content[pos-1] != '\n' {
+		// The text starts a paragraph of its own
+		markdown = "\n\n" + markdown
+	}
+	return TextRange{Start: pos, End: pos}, markdown, nil
+}
+
+// ocrDoneMsg brings back recognized text. content is the document when
+// recognizing started.
+type ocrDoneMsg struct {
+	target   TextRange
+	markdown string
+	err      error
+	content  string
+}
+
+// recognizeText recognizes the text of the image at the cursor, or on the
+// clipboard, in the background.
+func (m *model) recognizeText(quote bool) tea.Cmd {
+	content, pos := m.textarea.Value(), m.cursorOffset()
+	file, cfg, opts := m.filename, m.ocr, m.imageOpts
+	m.status = "Recognizing text ..."
+	return func() tea.Msg {
+		target, markdown, err := RecognizeText(content, pos, file, cfg, opts, quote)
+		return ocrDoneMsg{target: target, markdown: markdown, err: err, content: content}
+	}
+}
+
+func (m *model) ocrDone(msg ocrDoneMsg) {
+	if msg.err != nil {
+		m.status = msg.err.Error()
+		return
+	}
+	target := msg.target
+	if m.textarea.Value() != msg.content {
+		// Edited in the meantime: the cursor is the only safe place
+		target = TextRange{Start: m.cursorOffset(), End: m.cursorOffset()}
+	}
+	m.clearSelection()
+	m.replaceRange(target, msg.markdown)
+	m.status = "Inserted " + clipSize(strings.TrimLeft(msg.markdown, "\n")) + " of recognized text"
+}
+
+// recognizeText recognizes the text of the image link at the cursor, or of
+// the image on the clipboard, and inserts it.
+func (g *GUIApp) recognizeText(quote bool) {
+	content := g.editor.Text
+	pos := runeOffset(content, g.editor.CursorRow, g.editor.CursorColumn)
+	file, cfg, opts := g.currentFile, g.config.OCR, g.imageOpts
+	go func() {
+		target, markdown, err := RecognizeText(content, pos, file, cfg, opts, quote)
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, g.window)
+				return
+			}
+			current := g.editor.Text
+			if current != content {
+				cursor := runeOffset(current, g.editor.CursorRow, g.editor.CursorColumn)
+				target = TextRange{Start: cursor, End: cursor}
+			}
+			g.editor.SetText(current[:target.Start] + markdown + current[target.End:])
+			cursor := target.Start + len(markdown)
+			g.selectRange(TextRange{Start: cursor, End: cursor})
+			g.window.Canvas().Focus(g.editor)
+		})
+	}()
+}
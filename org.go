@@ -0,0 +1,198 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var orgTodoKeywords = []string{"TODO", "NEXT", "WAITING", "DONE", "CANCELLED"}
+
+var (
+	orgHeadlineRe = regexp.MustCompile(`^(\*+)\s+(.*)$`)
+	orgTagsRe     = regexp.MustCompile(`\s+(:[\w@:]+:)\s*$`)
+	orgListRe     = regexp.MustCompile(`^(\s*)([-+]|\d+[.)])\s+(\[[ xX-]\]\s+)?(.*)$`)
+	orgKeywordRe  = regexp.MustCompile(`^#\+(\w+):\s*(.*)$`)
+	orgBeginRe    = regexp.MustCompile(`(?i)^\s*#\+begin_(\w+)\s*(\S*)`)
+	orgEndRe      = regexp.MustCompile(`(?i)^\s*#\+end_(\w+)`)
+	orgPlanningRe = regexp.MustCompile(`^\s*(SCHEDULED|DEADLINE|CLOSED):`)
+	orgDrawerRe   = regexp.MustCompile(`^\s*:([A-Z]+):\s*$`)
+
+	orgLinkRe   = regexp.MustCompile(`\[\[([^\]]+)\]\[([^\]]+)\]\]`)
+	orgBareRe   = regexp.MustCompile(`\[\[([^\]]+)\]\]`)
+	orgBoldRe   = regexp.MustCompile(`(^|[\s(])\*([^\s*](?:[^*]*[^\s*])?)\*([\s.,;:!?)]|$)`)
+	orgItalicRe = regexp.MustCompile(`(^|[\s(])/([^\s/](?:[^/]*[^\s/])?)/([\s.,;:!?)]|$)`)
+	orgCodeRe   = regexp.MustCompile(`(^|[\s(])[=~]([^\s=~](?:[^=~]*[^\s=~])?)[=~]([\s.,;:!?)]|$)`)
+	orgStrikeRe = regexp.MustCompile(`(^|[\s(])\+([^\s+](?:[^+]*[^\s+])?)\+([\s.,;:!?)]|$)`)
+)
+
+func isOrgFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".org")
+}
+
+// OrgToMarkdown converts the supported org-mode subset (headlines with TODO
+// keywords and tags, lists, checkboxes, blocks and inline markup) to markdown.
+func OrgToMarkdown(content string) string {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	var out []string
+	block := ""
+	inDrawer := false
+
+	for _, line := range lines {
+		if block != "" {
+			if matches := orgEndRe.FindStringSubmatch(line); matches != nil && strings.EqualFold(matches[1], block) {
+				if !strings.EqualFold(block, "quote") {
+					out = append(out, "```")
+				}
+				block = ""
+				continue
+			}
+			if strings.EqualFold(block, "quote") {
+				out = append(out, "> "+orgInline(strings.TrimSpace(line)))
+			} else {
+				out = append(out, line)
+			}
+			continue
+		}
+
+		if inDrawer {
+			if strings.TrimSpace(line) == ":END:" {
+				inDrawer = false
+			}
+			continue
+		}
+
+		if matches := orgBeginRe.FindStringSubmatch(line); matches != nil {
+			block = matches[1]
+			switch strings.ToLower(block) {
+			case "src":
+				out = append(out, "```"+matches[2])
+			case "quote":
+			default:
+				out = append(out, "```")
+			}
+			continue
+		}
+
+		if orgDrawerRe.MatchString(line) && strings.TrimSpace(line) != ":END:" {
+			inDrawer = true
+			continue
+		}
+
+		if matches := orgKeywordRe.FindStringSubmatch(line); matches != nil {
+			if strings.EqualFold(matches[1], "title") {
+				out = append(out, "# "+orgInline(matches[2]), "")
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "# ") || line == "#" {
+			continue // org comment
+		}
+
+		if matches := orgHeadlineRe.FindStringSubmatch(line); matches != nil {
+			out = append(out, orgHeadline(len(matches[1]), matches[2]))
+			continue
+		}
+
+		if orgPlanningRe.MatchString(line) {
+			out = append(out, "*"+strings.TrimSpace(line)+"*")
+			continue
+		}
+
+		if matches := orgListRe.FindStringSubmatch(line); matches != nil {
+			indent, marker, checkbox, text := matches[1], matches[2], matches[3], matches[4]
+			if marker == "+" {
+				marker = "-"
+			} else if strings.HasSuffix(marker, ")") {
+				marker = strings.TrimSuffix(marker, ")") + "."
+			}
+			if checkbox != "" {
+				checked := strings.ContainsAny(checkbox, "xX")
+				checkbox = "[ ] "
+				if checked {
+					checkbox = "[x] "
+				}
+			}
+			out = append(out, indent+marker+" "+checkbox+orgInline(text))
+			continue
+		}
+
+		out = append(out, orgInline(line))
+	}
+
+	if block != "" && !strings.EqualFold(block, "quote") {
+		out = append(out, "```")
+	}
+
+	return strings.Join(out, "\n")
+}
+
+func orgHeadline(level int, text string) string {
+	tags := ""
+	if matches := orgTagsRe.FindStringSubmatch(text); matches != nil {
+		text = strings.TrimSuffix(text, matches[0])
+		for _, tag := range strings.Split(strings.Trim(matches[1], ":"), ":") {
+			tags += " `" + tag + "`"
+		}
+	}
+
+	keyword := ""
+	for _, kw := range orgTodoKeywords {
+		if text == kw || strings.HasPrefix(text, kw+" ") {
+			keyword = "**" + kw + "** "
+			text = strings.TrimSpace(strings.TrimPrefix(text, kw))
+			break
+		}
+	}
+
+	if level > 6 {
+		level = 6
+	}
+	return strings.Repeat("#", level) + " " + keyword + orgInline(text) + tags
+}
+
+func orgInline(text string) string {
+	text = orgLinkRe.ReplaceAllString(text, "[$2]($1)")
+	text = orgBareRe.ReplaceAllString(text, "<$1>")
+	text = orgCodeRe.ReplaceAllString(text, "$1`$2`$3")
+	text = orgBoldRe.ReplaceAllString(text, "$1**$2**$3")
+	text = orgItalicRe.ReplaceAllString(text, "$1*$2*$3")
+	text = orgStrikeRe.ReplaceAllString(text, "$1~~$2~~$3")
+	return text
+}
+
+func runOrgToMarkdown(args []string) error {
+	fs := flag.NewFlagSet("org2md", flag.ContinueOnError)
+	output := fs.String("o", "", "output file (defaults to the input name with .md)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: parselt org2md [-o output.md] input.org")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one input file")
+	}
+
+	input := fs.Arg(0)
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+
+	target := *output
+	if target == "" {
+		target = strings.TrimSuffix(input, filepath.Ext(input)) + ".md"
+	}
+	if err := os.WriteFile(target, []byte(OrgToMarkdown(string(data))), 0644); err != nil {
+		return fmt.Errorf("error writing file: %v", err)
+	}
+	fmt.Printf("Converted %s to %s\n", input, target)
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+type OutlineHeading struct {
+	Level int
+	Text  string
+	Line  int
+}
+
+// Outline lists the document headings with the zero-based source line each
+// one starts on.
+func (smp *SharedMarkdownProcessor) Outline(content string) []OutlineHeading {
+	doc, source := smp.Parse(content)
+	var headings []OutlineHeading
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		heading, ok := n.(*ast.Heading)
+		if !ok || heading.Lines().Len() == 0 {
+			continue
+		}
+		start := heading.Lines().At(0).Start
+		headings = append(headings, OutlineHeading{
+			Level: heading.Level,
+			Text:  smp.PlainText(heading, source),
+			Line:  bytes.Count(source[:start], []byte("\n")),
+		})
+	}
+	return headings
+}
+
+// outlineTree maps every heading to the headings nested directly under it,
+// keyed by index as a string. The root is keyed by "".
+func outlineTree(headings []OutlineHeading) map[string][]string {
+	tree := map[string][]string{}
+	var stack []int
+	for i, heading := range headings {
+		for len(stack) > 0 && headings[stack[len(stack)-1]].Level >= heading.Level {
+			stack = stack[:len(stack)-1]
+		}
+		parent := ""
+		if len(stack) > 0 {
+			parent = strconv.Itoa(stack[len(stack)-1])
+		}
+		tree[parent] = append(tree[parent], strconv.Itoa(i))
+		stack = append(stack, i)
+	}
+	return tree
+}
+
+func outlineItems(headings []OutlineHeading, targets []SectionTarget) []pickerItem {
+	items := make([]pickerItem, len(headings))
+	for i, heading := range headings {
+		items[i] = pickerItem{
+			title:  strings.Repeat("  ", heading.Level-1) + heading.Text,
+			detail: "line " + strconv.Itoa(heading.Line+1),
+			index:  i,
+		}
+		if progress := targetProgress(targets, i); progress != "" {
+			items[i].detail += " · " + progress + " words"
+		}
+	}
+	return items
+}
+
+// headingPath returns the indexes of the headings that line is under, the
+// outermost first.
+func headingPath(headings []OutlineHeading, line int) []int {
+	var path []int
+	for i, heading := range headings {
+		if heading.Line > line {
+			break
+		}
+		for len(path) > 0 && headings[path[len(path)-1]].Level >= heading.Level {
+			path = path[:len(path)-1]
+		}
+		path = append(path, i)
+	}
+	return path
+}
+
+// breadcrumb joins the headings of path as H1 › H2 › H3.
+func breadcrumb(headings []OutlineHeading, path []int) string {
+	texts := make([]string, len(path))
+	for i, index := range path {
+		texts[i] = headings[index].Text
+	}
+	return strings.Join(texts, " › ")
+}
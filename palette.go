@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ColorConfig is the terminal color palette. Empty fields keep the color of
+// the selected preset.
+type ColorConfig struct {
+	Accent               string `toml:"accent"`
+	H1                   string `toml:"h1"`
+	H1Background         string `toml:"h1_background"`
+	H2                   string `toml:"h2"`
+	H3                   string `toml:"h3"`
+	H4                   string `toml:"h4"`
+	Text                 string `toml:"text"`
+	List                 string `toml:"list"`
+	Quote                string `toml:"quote"`
+	QuoteBorder          string `toml:"quote_border"`
+	Code                 string `toml:"code"`
+	CodeBackground       string `toml:"code_background"`
+	InlineCode           string `toml:"inline_code"`
+	InlineCodeBackground string `toml:"inline_code_background"`
+	Link                 string `toml:"link"`
+	Muted                string `toml:"muted"`
+	Strong               string `toml:"strong"`
+	Emphasis             string `toml:"emphasis"`
+	Border               string `toml:"border"`
+}
+
+var termPalettes = map[string]ColorConfig{
+	"dark": {
+		Accent:               "#7D56F4",
+		H1:                   "#FF0000",
+		H1Background:         "#2A0A0A",
+		H2:                   "#00FFFF",
+		H3:                   "#FFFF00",
+		H4:                   "#96CEB4",
+		Text:                 "#E6E6E6",
+		List:                 "#FFEAA7",
+		Quote:                "#888888",
+		QuoteBorder:          "#666666",
+		Code:                 "#00FF41",
+		CodeBackground:       "#1a1a1a",
+		InlineCode:           "#00FF00",
+		InlineCodeBackground: "#333333",
+		Link:                 "#5FAFFF",
+		Muted:                "#777777",
+		Strong:               "#FFFFFF",
+		Emphasis:             "#DDDDDD",
+		Border:               "#555555",
+	},
+	"light": {
+		Accent:               "#7D56F4",
+		H1:                   "#CF222E",
+		H1Background:         "#FFEBE9",
+		H2:                   "#0550AE",
+		H3:                   "#8250DF",
+		H4:                   "#116329",
+		Text:                 "#24292F",
+		List:                 "#953800",
+		Quote:                "#57606A",
+		QuoteBorder:          "#D0D7DE",
+		Code:                 "#116329",
+		CodeBackground:       "#F6F8FA",
+		InlineCode:           "#0550AE",
+		InlineCodeBackground: "#EAEEF2",
+		Link:                 "#0969DA",
+		Muted:                "#6E7781",
+		Strong:               "#000000",
+		Emphasis:             "#32383F",
+		Border:               "#D0D7DE",
+	},
+}
+
+// termSyntaxColors are the code highlighting colors of the active palette.
+var termSyntaxColors = syntaxColors
+
+// Palette starts from the preset named by the theme, dark unless the theme
+// is light, and applies the [colors] overrides on top.
+func (c *Config) Palette() (ColorConfig, error) {
+	name := c.Theme
+	if name == "" {
+		name = "dark"
+	}
+	palette, ok := termPalettes[name]
+	if !ok {
+		return termPalettes["dark"], fmt.Errorf("unknown theme %q (available: dark, light)", c.Theme)
+	}
+
+	override := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	override(&palette.Accent, c.Colors.Accent)
+	override(&palette.H1, c.Colors.H1)
+	override(&palette.H1Background, c.Colors.H1Background)
+	override(&palette.H2, c.Colors.H2)
+	override(&palette.H3, c.Colors.H3)
+	override(&palette.H4, c.Colors.H4)
+	override(&palette.Text, c.Colors.Text)
+	override(&palette.List, c.Colors.List)
+	override(&palette.Quote, c.Colors.Quote)
+	override(&palette.QuoteBorder, c.Colors.QuoteBorder)
+	override(&palette.Code, c.Colors.Code)
+	override(&palette.CodeBackground, c.Colors.CodeBackground)
+	override(&palette.InlineCode, c.Colors.InlineCode)
+	override(&palette.InlineCodeBackground, c.Colors.InlineCodeBackground)
+	override(&palette.Link, c.Colors.Link)
+	override(&palette.Muted, c.Colors.Muted)
+	override(&palette.Strong, c.Colors.Strong)
+	override(&palette.Emphasis, c.Colors.Emphasis)
+	override(&palette.Border, c.Colors.Border)
+	return palette, nil
+}
+
+// applyPalette recolors the terminal styles. Only colors change, layout and
+// decorations stay as defined next to each style.
+func applyPalette(p ColorConfig, light bool) {
+	termH1Style = termH1Style.Foreground(lipgloss.Color(p.H1)).Background(lipgloss.Color(p.H1Background))
+	termH2Style = termH2Style.Foreground(lipgloss.Color(p.H2))
+	termH3Style = termH3Style.Foreground(lipgloss.Color(p.H3))
+	termH4Style = termH4Style.Foreground(lipgloss.Color(p.H4))
+	termParagraphStyle = termParagraphStyle.Foreground(lipgloss.Color(p.Text))
+	termListStyle = termListStyle.Foreground(lipgloss.Color(p.List))
+	termQuoteStyle = termQuoteStyle.Foreground(lipgloss.Color(p.Quote))
+	termQuoteBorderStyle = termQuoteBorderStyle.BorderForeground(lipgloss.Color(p.QuoteBorder))
+	termCodeHeaderStyle = termCodeHeaderStyle.Foreground(lipgloss.Color(p.Code)).Background(lipgloss.Color(p.CodeBackground))
+	termCodeBlockStyle = termCodeBlockStyle.Foreground(lipgloss.Color(p.Code)).Background(lipgloss.Color(p.CodeBackground)).BorderForeground(lipgloss.Color(p.Border))
+	termInlineCodeStyle = termInlineCodeStyle.Foreground(lipgloss.Color(p.InlineCode)).Background(lipgloss.Color(p.InlineCodeBackground))
+	termLinkColor = lipgloss.Color(p.Link)
+	termMutedColor = lipgloss.Color(p.Muted)
+	termStrongColor = lipgloss.Color(p.Strong)
+	termEmColor = lipgloss.Color(p.Emphasis)
+	termBorderColor = lipgloss.Color(p.Border)
+
+	titleStyle = titleStyle.Background(lipgloss.Color(p.Accent))
+	pickerStyle = pickerStyle.BorderForeground(lipgloss.Color(p.Accent))
+	pickerSelectedStyle = pickerSelectedStyle.Background(lipgloss.Color(p.Accent))
+
+	termSyntaxColors = make(map[string]string, len(syntaxColors))
+	for class, hex := range syntaxColors {
+		termSyntaxColors[class] = hex
+	}
+	if light {
+		for class, hex := range syntaxLightColors {
+			termSyntaxColors[class] = hex
+		}
+		termSyntaxColors[syntaxPlain] = p.Text
+		termSyntaxColors[syntaxPunctuation] = p.Text
+	}
+}
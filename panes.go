@@ -0,0 +1,404 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// pane is a part of the terminal screen that can have the keyboard.
+type pane int
+
+const (
+	paneEditor pane = iota
+	panePreview
+	paneFiles
+	paneOutline
+)
+
+const (
+	defaultSideWidth = 30
+	minSideWidth     = 16
+	// minMainWidth is kept for the editor and preview however wide the
+	// side panes are made
+	minMainWidth = 40
+	sideStep     = 4
+	splitStep    = 5
+)
+
+// paneLayout is how the terminal screen is shared: the file tree left of
+// the editor and preview, the outline right of them, which of them has the
+// keyboard and how wide each is. split is the share of the editor in split
+// mode, in percent.
+type paneLayout struct {
+	files        bool
+	outline      bool
+	focus        pane
+	filesWidth   int
+	outlineWidth int
+	split        int
+	// height is the height of the editor and preview inside their borders
+	height     int
+	fileList   []string
+	fileCursor int
+	// headingCursor indexes model.crumbHeadings
+	headingCursor int
+}
+
+func defaultPaneLayout() paneLayout {
+	return paneLayout{filesWidth: defaultSideWidth, outlineWidth: defaultSideWidth, split: 50}
+}
+
+var (
+	sidePaneStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#626262")).
+			Padding(0, 1)
+
+	sidePaneFocusStyle = sidePaneStyle.BorderForeground(lipgloss.Color("#04B575"))
+
+	sideCurrentStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("#04B575"))
+)
+
+// sideWidths are the widths of the shown side panes, borders included,
+// narrowed when the screen leaves too little for the rest.
+func (m model) sideWidths() (left, right int) {
+	if m.panes.files {
+		left = m.panes.filesWidth
+	}
+	if m.panes.outline {
+		right = m.panes.outlineWidth
+	}
+	if spare := m.width - minMainWidth; left+right > spare {
+		if spare < 2*minSideWidth {
+			return 0, 0
+		}
+		if left > 0 && right > 0 {
+			left, right = spare/2, spare-spare/2
+		} else {
+			left, right = min(left, spare), min(right, spare)
+		}
+	}
+	return left, right
+}
+
+// mainWidth is what the side panes leave for the editor and preview.
+func (m model) mainWidth() int {
+	left, right := m.sideWidths()
+	return m.width - left - right
+}
+
+// focusedPane is the pane with the keyboard. A pane that was closed, or
+// hidden by switching modes, hands it to the editor or the preview.
+func (m model) focusedPane() pane {
+	switch m.panes.focus {
+	case paneFiles:
+		if left, _ := m.sideWidths(); left > 0 {
+			return paneFiles
+		}
+	case paneOutline:
+		if _, right := m.sideWidths(); right > 0 {
+			return paneOutline
+		}
+	case panePreview:
+		if m.mode != editMode {
+			return panePreview
+		}
+	}
+	if m.mode == previewMode {
+		return panePreview
+	}
+	return paneEditor
+}
+
+// visiblePanes lists the panes on screen from left to right.
+func (m model) visiblePanes() []pane {
+	var panes []pane
+	left, right := m.sideWidths()
+	if left > 0 {
+		panes = append(panes, paneFiles)
+	}
+	if m.mode != previewMode {
+		panes = append(panes, paneEditor)
+	}
+	if m.mode != editMode {
+		panes = append(panes, panePreview)
+	}
+	if right > 0 {
+		panes = append(panes, paneOutline)
+	}
+	return panes
+}
+
+// focusPane gives p the keyboard; only the editor shows a cursor.
+func (m *model) focusPane(p pane) {
+	m.panes.focus = p
+	m.syncFocus()
+}
+
+// syncFocus blinks the editor cursor only while the editor has the keyboard.
+func (m *model) syncFocus() {
+	if m.focusedPane() == paneEditor {
+		m.textarea.Focus()
+	} else {
+		m.textarea.Blur()
+	}
+}
+
+// nextPane moves the keyboard to the next pane on screen, wrapping around.
+func (m *model) nextPane() {
+	panes := m.visiblePanes()
+	focus := m.focusedPane()
+	for i, p := range panes {
+		if p == focus {
+			m.focusPane(panes[(i+1)%len(panes)])
+			return
+		}
+	}
+}
+
+// toggleFilesPane shows the file tree and gives it the keyboard, or hides
+// it.
+func (m *model) toggleFilesPane() {
+	m.panes.files = !m.panes.files
+	if m.panes.files {
+		m.loadFileList()
+		m.panes.focus = paneFiles
+	}
+	m.resizePanes()
+}
+
+// loadFileList reads the file tree afresh, with the active buffer selected.
+func (m *model) loadFileList() {
+	m.panes.fileList = browserFiles()
+	m.panes.fileCursor = max(m.fileCurrent(), 0)
+}
+
+// toggleOutlinePane shows the outline, with the heading the cursor is under
+// selected, and gives it the keyboard, or hides it.
+func (m *model) toggleOutlinePane() {
+	m.panes.outline = !m.panes.outline
+	if m.panes.outline {
+		m.loadHeadings()
+		m.panes.headingCursor = 0
+		if path := headingPath(m.crumbHeadings, m.sourceLine()); len(path) > 0 {
+			m.panes.headingCursor = path[len(path)-1]
+		}
+		m.panes.focus = paneOutline
+	}
+	m.resizePanes()
+}
+
+// resizeFocused widens the pane with the keyboard by delta steps, or narrows
+// it when delta is negative. The editor and preview share the width left
+// over, so widening one of them narrows the other.
+func (m *model) resizeFocused(delta int) {
+	switch m.focusedPane() {
+	case paneFiles:
+		m.panes.filesWidth = max(m.panes.filesWidth+delta*sideStep, minSideWidth)
+	case paneOutline:
+		m.panes.outlineWidth = max(m.panes.outlineWidth+delta*sideStep, minSideWidth)
+	case paneEditor:
+		m.panes.split = min(max(m.panes.split+delta*splitStep, 20), 80)
+	case panePreview:
+		m.panes.split = min(max(m.panes.split-delta*splitStep, 20), 80)
+	}
+	m.resizePanes()
+}
+
+// resizePanes lays the screen out again after a pane changed size.
+func (m *model) resizePanes() {
+	m.layout()
+	m.syncFocus()
+	if m.mode != editMode {
+		m.content = m.textarea.Value()
+		m.refreshPreview()
+	}
+}
+
+// updateSidePane handles the keys for moving through the file tree and the
+// outline. It reports false for keys that are not its own.
+func (m *model) updateSidePane(msg tea.KeyMsg) bool {
+	focus := m.focusedPane()
+	cursor, count := &m.panes.fileCursor, len(m.panes.fileList)
+	if focus == paneOutline {
+		cursor, count = &m.panes.headingCursor, len(m.crumbHeadings)
+	}
+	rows := max(m.sidePaneRows(), 1)
+
+	switch msg.String() {
+	case "up", "k":
+		*cursor--
+	case "down", "j":
+		*cursor++
+	case "pgup":
+		*cursor -= rows
+	case "pgdown":
+		*cursor += rows
+	case "home", "g":
+		*cursor = 0
+	case "end", "G":
+		*cursor = count - 1
+	case "esc":
+		m.focusPane(paneEditor)
+		return true
+	case "enter":
+		m.openSideItem(focus, *cursor)
+		return true
+	default:
+		return false
+	}
+	*cursor = max(min(*cursor, count-1), 0)
+	return true
+}
+
+// openSideItem opens file i of the file tree, or jumps to heading i of the
+// outline, and gives the keyboard back to the document.
+func (m *model) openSideItem(p pane, i int) {
+	switch {
+	case p == paneFiles && i < len(m.panes.fileList):
+		m.openBuffer(m.panes.fileList[i])
+	case p == paneOutline && i < len(m.crumbHeadings):
+		m.headings = m.crumbHeadings
+		m.jumpToHeading(i)
+	default:
+		return
+	}
+	m.focusPane(paneEditor)
+}
+
+// clickSidePane focuses the side pane at screen cell x, y and opens the
+// entry clicked on. It reports false when x, y is not in a side pane.
+func (m *model) clickSidePane(x, y int) bool {
+	left, right := m.sideWidths()
+	p, cursor, count := paneFiles, m.panes.fileCursor, len(m.panes.fileList)
+	switch {
+	case x < left:
+	case right > 0 && x >= m.width-right:
+		p, cursor, count = paneOutline, m.panes.headingCursor, len(m.crumbHeadings)
+	default:
+		return false
+	}
+
+	// Below the border and the title of the pane
+	row := y - lipgloss.Height(m.headerView()) - 2
+	if row < 0 || row >= m.sidePaneRows() {
+		m.focusPane(p)
+		return true
+	}
+	i := listStart(cursor, count, m.sidePaneRows()) + row
+	if i >= count {
+		m.focusPane(p)
+		return true
+	}
+	if p == paneFiles {
+		m.panes.fileCursor = i
+	} else {
+		m.panes.headingCursor = i
+	}
+	m.openSideItem(p, i)
+	return true
+}
+
+// sidePaneRows is how many entries a side pane has room for below its
+// title.
+func (m model) sidePaneRows() int {
+	return m.panes.height - 1
+}
+
+// listStart is the first of count entries shown in rows lines, keeping the
+// cursor near the middle.
+func listStart(cursor, count, rows int) int {
+	return max(min(cursor-rows/2, count-rows), 0)
+}
+
+// withSidePanes puts the shown side panes next to the editor and preview.
+func (m model) withSidePanes(main string) string {
+	left, right := m.sideWidths()
+	if left == 0 && right == 0 {
+		return main
+	}
+	height := m.panes.height
+	focus := m.focusedPane()
+	parts := []string{}
+	if left > 0 {
+		parts = append(parts, m.sidePaneView("Files", m.fileEntries(), m.panes.fileCursor, m.fileCurrent(),
+			left, height, focus == paneFiles))
+	}
+	parts = append(parts, main)
+	if right > 0 {
+		current := -1
+		if path := headingPath(m.crumbHeadings, m.sourceLine()); len(path) > 0 {
+			current = path[len(path)-1]
+		}
+		entries := m.headingEntries(right - sidePaneStyle.GetHorizontalFrameSize())
+		parts = append(parts, m.sidePaneView("Outline", entries, m.panes.headingCursor, current,
+			right, height, focus == paneOutline))
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, parts...)
+}
+
+// sidePaneView draws a side pane width cells wide with height lines inside
+// its border: the title and then the entries around the cursor. The entry
+// the document is at is highlighted, and the cursor is shown while the pane
+// has the keyboard.
+func (m model) sidePaneView(title string, entries []string, cursor, current, width, height int, focused bool) string {
+	style := sidePaneStyle
+	if focused {
+		style = sidePaneFocusStyle
+	}
+	inner := max(width-style.GetHorizontalFrameSize(), 1)
+	rows := height - 1
+	lines := []string{titleStyle.Render(title)}
+	start := listStart(cursor, len(entries), rows)
+	for i := start; i < len(entries) && i < start+rows; i++ {
+		line := ansi.Truncate(entries[i], inner, "…")
+		switch {
+		case focused && i == cursor:
+			line = pickerSelectedStyle.Render(line)
+		case i == current:
+			line = sideCurrentStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return style.Width(width - style.GetHorizontalBorderSize()).Height(height).Render(strings.Join(lines, "\n"))
+}
+
+// fileEntries are the files of the tree, indented by directory.
+func (m model) fileEntries() []string {
+	entries := make([]string, len(m.panes.fileList))
+	for i, path := range m.panes.fileList {
+		depth := strings.Count(filepath.ToSlash(path), "/")
+		entries[i] = strings.Repeat("  ", depth) + filepath.Base(path)
+	}
+	return entries
+}
+
+// fileCurrent is the index of the active buffer in the file tree, or -1.
+func (m model) fileCurrent() int {
+	for i, path := range m.panes.fileList {
+		if m.filename != "" && sameFile(path, m.filename) {
+			return i
+		}
+	}
+	return -1
+}
+
+// headingEntries are the headings of the outline, indented by level, with
+// the progress of their word targets at the right of width columns.
+func (m model) headingEntries(width int) []string {
+	entries := make([]string, len(m.crumbHeadings))
+	for i, heading := range m.crumbHeadings {
+		entries[i] = strings.Repeat("  ", heading.Level-1) + heading.Text
+		if progress := targetProgress(m.targets, i); progress != "" {
+			text := ansi.Truncate(entries[i], width-ansi.StringWidth(progress)-1, "…")
+			entries[i] = text + strings.Repeat(" ", max(width-ansi.StringWidth(text)-ansi.StringWidth(progress), 1)) + progress
+		}
+	}
+	return entries
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"fyne.io/fyne/v2"
+)
+
+// driveLetterRe matches a Windows path that starts with a drive, in either
+// slash direction.
+var driveLetterRe = regexp.MustCompile(`^[A-Za-z]:[\\/]`)
+
+// isURL tells a link or image destination with a scheme from a file path.
+// Windows paths such as C:/pics/cat.png have a colon too.
+func isURL(dest string) bool {
+	return strings.Contains(dest, ":") && !driveLetterRe.MatchString(dest)
+}
+
+// normalizePath is the absolute, cleaned form of path that files are
+// compared and remembered by. On Windows the drive letter is upper case, as
+// paths from dialogs and the command line differ there.
+func normalizePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS == "windows" && driveLetterRe.MatchString(abs) {
+		abs = strings.ToUpper(abs[:1]) + abs[1:]
+	}
+	return abs, nil
+}
+
+// uriPath is the file path of a URI from a file dialog. Fyne reports
+// Windows paths with forward slashes, which would not match the same file
+// opened from the command line.
+func uriPath(uri fyne.URI) string {
+	path := uri.Path()
+	if runtime.GOOS != "windows" {
+		return path
+	}
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+// droppedPath is the file path in text pasted or dropped into the terminal:
+// terminals quote it, send it as a file:// URL or escape its spaces with
+// backslashes, which are separators on Windows instead.
+func droppedPath(text string) string {
+	path := strings.Trim(strings.TrimSpace(text), `"'`)
+	if strings.HasPrefix(path, "file://") {
+		if u, err := url.Parse(path); err == nil {
+			path = u.Path
+			if runtime.GOOS == "windows" {
+				path = strings.TrimPrefix(path, "/")
+			}
+		}
+	}
+	if runtime.GOOS != "windows" {
+		path = strings.ReplaceAll(path, `\ `, " ")
+	}
+	return filepath.FromSlash(path)
+}
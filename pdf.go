@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+const (
+	pdfFont     = "Helvetica"
+	pdfMonoFont = "Courier"
+	pdfFontSize = 11
+	pdfMargin   = 20
+)
+
+var pdfHeadingSizes = []float64{20, 16, 14, 12, 11, 11}
+
+type pdfStyle struct {
+	bold, italic, strike, mono bool
+	link                       string
+}
+
+func (s pdfStyle) font() (string, string) {
+	family := pdfFont
+	if s.mono {
+		family = pdfMonoFont
+	}
+	style := ""
+	if s.bold {
+		style += "B"
+	}
+	if s.italic {
+		style += "I"
+	}
+	if s.link != "" {
+		style += "U"
+	}
+	if s.strike {
+		style += "S"
+	}
+	return family, style
+}
+
+type pdfWriter struct {
+	smp     *SharedMarkdownProcessor
+	source  []byte
+	pdf     *fpdf.Fpdf
+	tr      func(string) string
+	baseDir string
+	size    float64
+	color   [3]int
+}
+
+// ExportPDF lays the document out with the PDF core fonts, which cover the
+// Windows-1252 character set.
+func ExportPDF(content string, opts ExportOptions) ([]byte, error) {
+	smp := opts.processor()
+	doc, source := smp.Parse(content)
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin)
+	pdf.SetTitle(opts.Title, true)
+	pdf.SetCreator("parselt", true)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont(pdfFont, "", 8)
+		pdf.SetTextColor(128, 128, 128)
+		pdf.CellFormat(0, 10, fmt.Sprintf("%d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	w := &pdfWriter{
+		smp:     smp,
+		source:  source,
+		pdf:     pdf,
+		tr:      pdf.UnicodeTranslatorFromDescriptor(""),
+		baseDir: ".",
+		size:    pdfFontSize,
+		color:   [3]int{36, 41, 47},
+	}
+	if opts.SourcePath != "" {
+		w.baseDir = filepath.Dir(opts.SourcePath)
+	}
+
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		w.block(n)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("error writing pdf: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (w *pdfWriter) lineHeight() float64 {
+	return w.size * 0.5
+}
+
+func (w *pdfWriter) resetText() {
+	w.pdf.SetFont(pdfFont, "", w.size)
+	w.pdf.SetTextColor(w.color[0], w.color[1], w.color[2])
+}
+
+func (w *pdfWriter) block(n ast.Node) {
+	pdf := w.pdf
+	left, _, right, _ := pdf.GetMargins()
+	pageWidth, _ := pdf.GetPageSize()
+
+	switch node := n.(type) {
+	case *ast.Heading:
+		size := pdfHeadingSizes[min(node.Level, 6)-1]
+		pdf.Ln(3)
+		w.paragraph(node, pdfStyle{bold: true}, size)
+		if node.Level <= 2 {
+			y := pdf.GetY() + 1
+			pdf.SetDrawColor(216, 222, 228)
+			pdf.Line(left, y, pageWidth-right, y)
+			pdf.Ln(2)
+		}
+		pdf.Ln(2)
+
+	case *ast.Paragraph:
+		if image, ok := soleImage(node); ok {
+			w.image(image)
+			return
+		}
+		w.paragraph(node, pdfStyle{}, pdfFontSize)
+		pdf.Ln(2)
+
+	case *ast.TextBlock:
+		w.paragraph(node, pdfStyle{}, pdfFontSize)
+
+	case *ast.List:
+		number := node.Start
+		for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+			marker := "•"
+			if node.IsOrdered() {
+				marker = fmt.Sprintf("%d.", number)
+				number++
+			}
+			if first := item.FirstChild(); first != nil {
+				if checkbox, ok := first.FirstChild().(*east.TaskCheckBox); ok {
+					marker = "[ ]"
+					if checkbox.IsChecked {
+						marker = "[x]"
+					}
+				}
+			}
+
+			w.size = pdfFontSize
+			w.resetText()
+			pdf.SetX(left)
+			pdf.Write(w.lineHeight(), w.tr(marker))
+			pdf.SetLeftMargin(left + 7)
+			pdf.SetX(left + 7)
+			for child := item.FirstChild(); child != nil; child = child.NextSibling() {
+				w.block(child)
+			}
+			pdf.SetLeftMargin(left)
+		}
+		pdf.Ln(2)
+
+	case *ast.FencedCodeBlock:
+		code, lang := w.smp.CodeBlockText(node, w.source), fenceLanguage(fenceInfo(node, w.source))
+		if diagramLanguages[lang] {
+			w.diagram(ParseDiagram(code))
+			break
+		}
+		if lang == "chart" {
+			if c, err := ParseChart(code); err == nil {
+				w.drawing(c.layout())
+				break
+			}
+		}
+		if lang == "calendar" {
+			if c, err := ParseCalendar(code, time.Now(), func() []DueTask { return WorkspaceDueTasks(w.smp) }); err == nil {
+				w.drawing(c.layout())
+				break
+			}
+		}
+		if native, ok := ganttSyntax(lang, code); ok {
+			if g, err := ParseGantt(code, native); err == nil {
+				w.drawing(g.layout())
+				break
+			}
+		}
+		w.code(code)
+
+	case *ast.CodeBlock:
+		w.code(w.smp.CodeBlockText(node, w.source))
+
+	case *ast.Blockquote:
+		startPage, startY := pdf.PageNo(), pdf.GetY()
+		saved := w.color
+		w.color = [3]int{87, 96, 106}
+		pdf.SetLeftMargin(left + 8)
+		pdf.SetX(left + 8)
+		for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+			w.block(child)
+		}
+		pdf.SetLeftMargin(left)
+		w.color = saved
+		if pdf.PageNo() == startPage {
+			pdf.SetDrawColor(208, 215, 222)
+			pdf.SetLineWidth(1)
+			pdf.Line(left+2, startY, left+2, pdf.GetY()-2)
+			pdf.SetLineWidth(0.2)
+		}
+
+	case *ast.ThematicBreak:
+		pdf.Ln(3)
+		pdf.SetDrawColor(216, 222, 228)
+		pdf.Line(left, pdf.GetY(), pageWidth-right, pdf.GetY())
+		pdf.Ln(5)
+
+	case *east.Table:
+		w.table(node)
+	}
+}
+
+func (w *pdfWriter) paragraph(n ast.Node, style pdfStyle, size float64) {
+	w.size = size
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		w.inline(child, style)
+	}
+	w.pdf.Ln(w.lineHeight())
+}
+
+func (w *pdfWriter) text(text string, style pdfStyle) {
+	pdf := w.pdf
+	family, fontStyle := style.font()
+	size := w.size
+	if style.mono {
+		size--
+	}
+	pdf.SetFont(family, fontStyle, size)
+	if style.link != "" {
+		pdf.SetTextColor(125, 86, 244)
+		pdf.WriteLinkString(w.lineHeight(), w.tr(text), style.link)
+	} else {
+		pdf.SetTextColor(w.color[0], w.color[1], w.color[2])
+		pdf.Write(w.lineHeight(), w.tr(text))
+	}
+}
+
+func (w *pdfWriter) inline(n ast.Node, style pdfStyle) {
+	switch node := n.(type) {
+	case *ast.Text:
+		w.text(string(node.Segment.Value(w.source)), style)
+		if node.HardLineBreak() {
+			w.pdf.Ln(w.lineHeight())
+		} else if node.SoftLineBreak() {
+			w.text(" ", style)
+		}
+		return
+	case *ast.String:
+		w.text(string(node.Value), style)
+		return
+	case *ast.CodeSpan:
+		style.mono = true
+		w.text(w.smp.PlainText(node, w.source), style)
+		return
+	case *ast.AutoLink:
+		style.link = string(node.URL(w.source))
+		w.text(string(node.Label(w.source)), style)
+		return
+	case *ast.Image:
+		style.italic = true
+		w.text("["+w.smp.PlainText(node, w.source)+"]", style)
+		return
+	case *ast.Emphasis:
+		if node.Level >= 2 {
+			style.bold = true
+		} else {
+			style.italic = true
+		}
+	case *east.Strikethrough:
+		style.strike = true
+	case *ast.Link:
+		style.link = string(node.Destination)
+	case *east.TaskCheckBox, *ast.RawHTML:
+		return
+	}
+
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		w.inline(child, style)
+	}
+}
+
+func (w *pdfWriter) code(code string) {
+	pdf := w.pdf
+	pdf.SetFont(pdfMonoFont, "", 9)
+	pdf.SetTextColor(36, 41, 47)
+	pdf.SetFillColor(246, 248, 250)
+	pdf.SetCellMargin(3)
+	pdf.MultiCell(0, 4.5, w.tr(strings.ReplaceAll(code, "\t", "    ")), "", "L", true)
+	pdf.SetCellMargin(1)
+	pdf.Ln(3)
+}
+
+func (w *pdfWriter) table(table *east.Table) {
+	pdf := w.pdf
+	left, _, right, bottom := pdf.GetMargins()
+	pageWidth, pageHeight := pdf.GetPageSize()
+	columns := len(table.Alignments)
+	if columns == 0 {
+		return
+	}
+	colWidth := (pageWidth - left - right) / float64(columns)
+	lineHeight := 5.0
+
+	pdf.SetDrawColor(208, 215, 222)
+	for row := table.FirstChild(); row != nil; row = row.NextSibling() {
+		_, header := row.(*east.TableHeader)
+		fontStyle := ""
+		if header {
+			fontStyle = "B"
+		}
+		pdf.SetFont(pdfFont, fontStyle, 10)
+		pdf.SetTextColor(36, 41, 47)
+
+		var cells []string
+		lines := 1
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			text := w.tr(w.smp.PlainText(cell, w.source))
+			cells = append(cells, text)
+			lines = max(lines, len(pdf.SplitLines([]byte(text), colWidth-2)))
+		}
+		height := float64(lines)*lineHeight + 2
+
+		if pdf.GetY()+height > pageHeight-bottom {
+			pdf.AddPage()
+		}
+		y := pdf.GetY()
+		for i, text := range cells {
+			x := left + float64(i)*colWidth
+			if header {
+				pdf.SetFillColor(246, 248, 250)
+				pdf.Rect(x, y, colWidth, height, "FD")
+			} else {
+				pdf.Rect(x, y, colWidth, height, "D")
+			}
+			align := "L"
+			if i < columns {
+				switch table.Alignments[i] {
+				case east.AlignCenter:
+					align = "C"
+				case east.AlignRight:
+					align = "R"
+				}
+			}
+			pdf.SetXY(x, y+1)
+			pdf.MultiCell(colWidth, lineHeight, text, "", align, false)
+		}
+		pdf.SetXY(left, y+height)
+	}
+	pdf.Ln(4)
+}
+
+// image places a standalone image scaled to the text width. Formats the PDF
+// core cannot embed fall back to their alt text.
+func (w *pdfWriter) image(node *ast.Image) {
+	pdf := w.pdf
+	alt := w.smp.PlainText(node, w.source)
+	path := string(node.Destination)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(w.baseDir, filepath.FromSlash(path))
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		w.paragraph(node, pdfStyle{italic: true}, pdfFontSize)
+		return
+	}
+	config, format, err := image.DecodeConfig(file)
+	file.Close()
+	if err != nil || (format != "jpeg" && format != "png" && format != "gif") {
+		w.paragraph(node, pdfStyle{italic: true}, pdfFontSize)
+		return
+	}
+
+	left, _, right, bottom := pdf.GetMargins()
+	pageWidth, pageHeight := pdf.GetPageSize()
+	width := min(float64(config.Width)*25.4/96, pageWidth-left-right)
+	height := width * float64(config.Height) / float64(config.Width)
+	if pdf.GetY()+height > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	y := pdf.GetY()
+	x := left + (pageWidth-left-right-width)/2
+	pdf.ImageOptions(path, x, y, width, height, false, fpdf.ImageOptions{ImageType: strings.ToUpper(format)}, 0, "")
+	pdf.SetY(y + height + 2)
+
+	if alt != "" {
+		pdf.SetFont(pdfFont, "I", 9)
+		pdf.SetTextColor(87, 96, 106)
+		pdf.MultiCell(0, 4.5, w.tr(alt), "", "C", false)
+	}
+	pdf.Ln(3)
+}
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// maxPeekLines is how much of a target a peek shows.
+const maxPeekLines = 20
+
+// peekDelay is how long the mouse rests on a link in the GUI preview before
+// its target pops up.
+const peekDelay = 600 * time.Millisecond
+
+var (
+	peekImageRe    = regexp.MustCompile(`!\[([^\]]*)\]\(([^)]*)\)`)
+	peekWikiRe     = regexp.MustCompile(`\[\[([^\[\]]+)\]\]`)
+	peekFootnoteRe = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+	peekLinkRe     = regexp.MustCompile(`\[([^\]]*)\]\(([^)]*)\)`)
+)
+
+var errNothingToPeek = errors.New("No link, footnote or image at the cursor")
+
+// Peek is what a link, footnote reference or image points to: markdown to
+// show, or the path or URL of an image.
+type Peek struct {
+	Title    string
+	Markdown string
+	Image    string
+}
+
+// PeekAt finds the wiki link ([[page#heading|label]]), footnote reference,
+// image or link at byte offset pos of content and what it points to. Paths
+// are relative to the document at docPath.
+func (smp *SharedMarkdownProcessor) PeekAt(content string, pos int, docPath string) (Peek, error) {
+	start := strings.LastIndex(content[:pos], "\n") + 1
+	end := len(content)
+	if i := strings.Index(content[pos:], "\n"); i >= 0 {
+		end = pos + i
+	}
+	line, col := content[start:end], pos-start
+	at := func(re *regexp.Regexp) []string {
+		for _, m := range re.FindAllStringSubmatchIndex(line, -1) {
+			if m[0] <= col && col <= m[1] {
+				groups := make([]string, len(m)/2)
+				for i := range groups {
+					if m[2*i] >= 0 {
+						groups[i] = line[m[2*i]:m[2*i+1]]
+					}
+				}
+				return groups
+			}
+		}
+		return nil
+	}
+
+	if m := at(peekImageRe); m != nil {
+		src := linkDestination(m[2])
+		if strings.Contains(src, "://") {
+			return Peek{Title: m[1], Image: src}, nil
+		}
+		return Peek{Title: m[1], Image: peekPath(src, docPath)}, nil
+	}
+	if m := at(peekWikiRe); m != nil {
+		target, _, _ := strings.Cut(m[1], "|")
+		page, anchor, _ := strings.Cut(target, "#")
+		if page == "" {
+			return smp.peekSection(content, anchor, "")
+		}
+		return smp.peekFile(wikiPath(strings.TrimSpace(page), docPath), anchor)
+	}
+	if m := at(peekFootnoteRe); m != nil {
+		return peekFootnote(content, m[1])
+	}
+	if m := at(peekLinkRe); m != nil {
+		return smp.PeekTarget(content, linkDestination(m[2]), docPath)
+	}
+	return Peek{}, errNothingToPeek
+}
+
+// PeekTarget finds what a link destination points to: a section of content
+// for "#heading", or a markdown file, a section of one or an image.
+func (smp *SharedMarkdownProcessor) PeekTarget(content, dest, docPath string) (Peek, error) {
+	if dest == "" {
+		return Peek{}, errNothingToPeek
+	}
+	if strings.Contains(dest, "://") || strings.HasPrefix(dest, "mailto:") {
+		return Peek{}, fmt.Errorf("%s is not a local file", dest)
+	}
+	path, anchor, _ := strings.Cut(dest, "#")
+	if path == "" {
+		return smp.peekSection(content, anchor, "")
+	}
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		path = unescaped
+	}
+	path = peekPath(path, docPath)
+	if IsImageFile(path) {
+		return Peek{Title: filepath.Base(path), Image: path}, nil
+	}
+	return smp.peekFile(path, anchor)
+}
+
+// linkDestination drops the title and angle brackets from the part of a
+// link in parentheses.
+func linkDestination(text string) string {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "<") {
+		if end := strings.Index(text, ">"); end > 0 {
+			return text[1:end]
+		}
+	}
+	if fields := strings.Fields(text); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+func peekPath(path, docPath string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(docPath), filepath.FromSlash(path))
+}
+
+// wikiPath finds the file a wiki link names: next to the document, with .md
+// added when the name has no extension, or else any file of that name below
+// the working directory.
+func wikiPath(page, docPath string) string {
+	name := page
+	if filepath.Ext(name) == "" {
+		name += ".md"
+	}
+	path := peekPath(name, docPath)
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	for _, file := range browserFiles() {
+		if strings.EqualFold(filepath.Base(file), filepath.Base(name)) {
+			return file
+		}
+	}
+	return path
+}
+
+func (smp *SharedMarkdownProcessor) peekFile(path, anchor string) (Peek, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown", ".org":
+	default:
+		return Peek{}, fmt.Errorf("Cannot show %s", filepath.Base(path))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Peek{}, fmt.Errorf("%s does not exist", path)
+	}
+	content := string(data)
+	if isOrgFile(path) {
+		content = OrgToMarkdown(content)
+	}
+	return smp.peekSection(content, anchor, filepath.Base(path))
+}
+
+// peekSection is the beginning of the section under the heading anchor
+// names, or of the whole document without an anchor. The anchor is the id
+// of a heading, or a part of its text. title names the file the section is
+// in; it is empty for the document being edited.
+func (smp *SharedMarkdownProcessor) peekSection(content, anchor, title string) (Peek, error) {
+	if fm := ParseFrontMatter(content); fm != nil {
+		content = content[fm.End:]
+	}
+	lines := strings.Split(content, "\n")
+	if anchor == "" {
+		return Peek{Title: title, Markdown: peekExcerpt(lines)}, nil
+	}
+
+	headings := smp.Outline(content)
+	found := -1
+	for i, heading := range headings {
+		if helpAnchor(heading.Text) == strings.ToLower(anchor) {
+			found = i
+			break
+		}
+	}
+	if found < 0 {
+		row, _, err := Location{Heading: strings.ReplaceAll(anchor, "-", " ")}.resolve(smp, content)
+		if err != nil {
+			return Peek{}, err
+		}
+		for i, heading := range headings {
+			if heading.Line == row {
+				found = i
+			}
+		}
+	}
+	heading := headings[found]
+	end := len(lines)
+	for _, next := range headings[found+1:] {
+		if next.Level <= heading.Level {
+			end = next.Line
+			break
+		}
+	}
+	return Peek{Title: title, Markdown: peekExcerpt(lines[heading.Line:end])}, nil
+}
+
+// peekExcerpt keeps the first maxPeekLines lines, closing a code block that
+// was cut short.
+func peekExcerpt(lines []string) string {
+	cut := len(lines) > maxPeekLines
+	if cut {
+		lines = lines[:maxPeekLines]
+	}
+	excerpt := strings.TrimSpace(strings.Join(lines, "\n"))
+	fences := 0
+	for _, line := range lines {
+		if fenceRe.MatchString(line) {
+			fences++
+		}
+	}
+	if fences%2 == 1 {
+		excerpt += "\n```"
+	}
+	if cut {
+		excerpt += "\n\n…"
+	}
+	return excerpt
+}
+
+// peekFootnote finds the definition of footnote id, with its indented
+// continuation lines.
+func peekFootnote(content, id string) (Peek, error) {
+	defRe := regexp.MustCompile(`(?m)^\[\^` + regexp.QuoteMeta(id) + `\]:[ \t]*`)
+	loc := defRe.FindStringIndex(content)
+	if loc == nil {
+		return Peek{}, fmt.Errorf("No definition of footnote [^%s]", id)
+	}
+	lines := strings.Split(content[loc[1]:], "\n")
+	end := 1
+	for end < len(lines) {
+		line := lines[end]
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, "    ") && !strings.HasPrefix(line, "\t") {
+			break
+		}
+		end++
+	}
+	for i := 1; i < end; i++ {
+		lines[i] = strings.TrimPrefix(strings.TrimPrefix(lines[i], "    "), "\t")
+	}
+	return Peek{Title: "Footnote " + id, Markdown: peekExcerpt(lines[:end])}, nil
+}
+
+// imageSummary describes an image for the terminal, which cannot show it.
+func imageSummary(path string) string {
+	if strings.Contains(path, "://") {
+		return "An image on the web: " + path
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return path + " does not exist"
+	}
+	defer file.Close()
+	summary := path
+	if info, err := file.Stat(); err == nil {
+		summary += ", " + formatBytes(info.Size())
+	}
+	if config, format, err := image.DecodeConfig(file); err == nil {
+		summary += fmt.Sprintf(", %d×%d %s", config.Width, config.Height, strings.ToUpper(format))
+	}
+	return summary
+}
+
+var peekStyle = lipgloss.NewStyle().
+	Border(lipgloss.RoundedBorder()).
+	BorderForeground(lipgloss.Color("#7D56F4")).
+	Padding(0, 1)
+
+// peekPopup is a peek rendered for the terminal.
+type peekPopup struct {
+	title string
+	body  string
+}
+
+// openPeek shows what the link, footnote or image at the cursor points to
+// until the next key.
+func (m *model) openPeek() {
+	peek, err := m.mdProcessor.PeekAt(m.textarea.Value(), m.cursorOffset(), m.filename)
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	width := m.peekWidth() - peekStyle.GetHorizontalFrameSize()
+	body := peek.Markdown
+	if peek.Image != "" {
+		body = imageSummary(peek.Image)
+		if peek.Title == "" {
+			peek.Title = filepath.Base(peek.Image)
+		}
+		body = lipgloss.NewStyle().Width(width).Render(body)
+	} else {
+		body = strings.Trim(m.mdProcessor.RenderTerminal(body, width), "\n")
+	}
+	m.peek = &peekPopup{title: peek.Title, body: body}
+}
+
+func (m model) peekWidth() int {
+	return max(min(m.mainWidth()-8, 80), 20)
+}
+
+// closePeek hides the peek. Keys other than esc and the peek key go on to
+// do what they usually do.
+func (m *model) closePeek(msg tea.KeyMsg) bool {
+	m.peek = nil
+	return msg.String() == "esc" || key.Matches(msg, m.keys.peek)
+}
+
+// withPeek draws the peek over the bottom of the editor and preview.
+func (m model) withPeek(content string) string {
+	if m.peek == nil {
+		return content
+	}
+	width := m.peekWidth()
+	maxLines := max(m.panes.height/2, 3)
+	lines := strings.Split(m.peek.body, "\n")
+	if len(lines) > maxLines {
+		lines = append(lines[:maxLines-1], helpStyle.Render("…"))
+	}
+	body := titleStyle.Render(ansi.Truncate(m.peek.title, width-6, "…"))
+	if m.peek.title == "" {
+		body = ""
+	} else {
+		body += "\n"
+	}
+	box := peekStyle.Width(width - peekStyle.GetHorizontalBorderSize()).Render(body + strings.Join(lines, "\n"))
+
+	left, _ := m.sideWidths()
+	return placeOver(content, box, left+4, max(lipgloss.Height(content)-lipgloss.Height(box)-1, 0))
+}
+
+// placeOver draws box over base with its top left corner at cell x, y.
+func placeOver(base, box string, x, y int) string {
+	lines := strings.Split(base, "\n")
+	for i, boxLine := range strings.Split(box, "\n") {
+		row := y + i
+		if row >= len(lines) {
+			lines = append(lines, "")
+		}
+		line := lines[row]
+		left := ansi.Truncate(line, x, "")
+		if pad := x - ansi.StringWidth(left); pad > 0 {
+			left += strings.Repeat(" ", pad)
+		}
+		right := ansi.TruncateLeft(line, x+ansi.StringWidth(boxLine), "")
+		lines[row] = left + "\x1b[0m" + boxLine + "\x1b[0m" + right
+	}
+	return strings.Join(lines, "\n")
+}
+
+// peekAtCursor pops up what the link, footnote or image at the editor
+// cursor points to.
+func (g *GUIApp) peekAtCursor() {
+	content := g.editor.Text
+	peek, err := g.mdProcessor.PeekAt(content, runeOffset(content, g.editor.CursorRow, g.editor.CursorColumn), g.currentFile)
+	if err != nil {
+		dialog.ShowInformation("Peek", err.Error(), g.window)
+		return
+	}
+	g.showPeek(peek, fyne.NewPos(theme.Padding()*4, theme.Padding()*4), g.editorPane)
+}
+
+// peekLink pops up the target of a link hovered in the preview next to it.
+func (g *GUIApp) peekLink(link *url.URL, over fyne.CanvasObject) {
+	if link == nil || link.Scheme != "" {
+		return
+	}
+	peek, err := g.mdProcessor.PeekTarget(g.editor.Text, link.String(), g.currentFile)
+	if err != nil {
+		return
+	}
+	g.showPeek(peek, fyne.NewPos(0, over.Size().Height), over)
+}
+
+// showPeek shows peek in a pop-up at pos relative to over. Only one is
+// shown at a time.
+func (g *GUIApp) showPeek(peek Peek, pos fyne.Position, over fyne.CanvasObject) {
+	g.hidePeek()
+	var body fyne.CanvasObject
+	if peek.Image != "" {
+		path := peek.Image
+		if strings.Contains(path, "://") {
+			remote := fetchRemoteImage(path, func() {})
+			if !remote.done || remote.err != nil {
+				body = widget.NewLabel("Loading " + path + " …")
+			}
+			path = remote.path
+		}
+		if body == nil {
+			img := canvas.NewImageFromURI(storage.NewFileURI(path))
+			img.FillMode = canvas.ImageFillContain
+			img.SetMinSize(fyne.NewSize(360, 240))
+			body = img
+		}
+	} else {
+		text := widget.NewRichText()
+		text.Wrapping = fyne.TextWrapWord
+		g.mdProcessor.RenderFynePreview(text, peek.Markdown, nil, nil, nil)
+		scroll := container.NewVScroll(text)
+		scroll.SetMinSize(fyne.NewSize(420, 240))
+		body = scroll
+	}
+	if peek.Title != "" {
+		title := widget.NewLabel(peek.Title)
+		title.TextStyle = fyne.TextStyle{Bold: true}
+		body = container.NewBorder(title, nil, nil, nil, body)
+	}
+	g.peekPopUp = widget.NewPopUp(body, g.window.Canvas())
+	g.peekPopUp.ShowAtRelativePosition(pos, over)
+}
+
+func (g *GUIApp) hidePeek() {
+	if g.peekPopUp != nil {
+		g.peekPopUp.Hide()
+		g.peekPopUp = nil
+	}
+}
+
+// peekLinkSegment is a preview link that pops up its target while the mouse
+// rests on it.
+type peekLinkSegment struct {
+	*widget.HyperlinkSegment
+	peek   func(link *url.URL, over fyne.CanvasObject)
+	unpeek func()
+}
+
+func (s *peekLinkSegment) Visual() fyne.CanvasObject {
+	visual := s.HyperlinkSegment.Visual().(*fyne.Container)
+	link := &peekLink{peek: s.peek, unpeek: s.unpeek}
+	link.ExtendBaseWidget(link)
+	visual.Objects[0] = link
+	s.Update(visual)
+	return visual
+}
+
+func (s *peekLinkSegment) Update(o fyne.CanvasObject) {
+	link := o.(*fyne.Container).Objects[0].(*peekLink)
+	link.Text = s.Text
+	link.URL = s.URL
+	link.Alignment = s.Alignment
+	link.OnTapped = s.OnTapped
+	link.Refresh()
+}
+
+type peekLink struct {
+	widget.Hyperlink
+	peek   func(link *url.URL, over fyne.CanvasObject)
+	unpeek func()
+	timer  *time.Timer
+}
+
+func (l *peekLink) MouseIn(e *desktop.MouseEvent) {
+	l.Hyperlink.MouseIn(e)
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.timer = time.AfterFunc(peekDelay, func() {
+		fyne.Do(func() { l.peek(l.URL, l) })
+	})
+}
+
+func (l *peekLink) MouseOut() {
+	l.Hyperlink.MouseOut()
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.unpeek()
+}
+
+// hookPeekLinks makes the links of the preview pop up their targets.
+func hookPeekLinks(segments []widget.RichTextSegment, peek func(*url.URL, fyne.CanvasObject), unpeek func()) {
+	for i, segment := range segments {
+		switch seg := segment.(type) {
+		case *widget.HyperlinkSegment:
+			segments[i] = &peekLinkSegment{HyperlinkSegment: seg, peek: peek, unpeek: unpeek}
+		case *widget.ParagraphSegment:
+			hookPeekLinks(seg.Texts, peek, unpeek)
+		case *widget.ListSegment:
+			hookPeekLinks(seg.Items, peek, unpeek)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+)
+
+// NotePeriod is the span of time a periodic note covers.
+type NotePeriod int
+
+const (
+	periodDay NotePeriod = iota
+	periodWeek
+	periodMonth
+)
+
+var notePeriods = []NotePeriod{periodDay, periodWeek, periodMonth}
+
+var (
+	dayNoteRe   = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})$`)
+	weekNoteRe  = regexp.MustCompile(`^(\d{4})-W(\d{2})$`)
+	monthNoteRe = regexp.MustCompile(`^(\d{4}-\d{2})$`)
+)
+
+// Built-in templates for the periods without one of their own. A daily note
+// links to its week, a weekly note to its month and days.
+var defaultPeriodicTemplates = map[NotePeriod]string{
+	periodDay:   "# {{.Title}}\n\n{{.Previous}} · {{.Week}} · {{.Next}}\n\n",
+	periodWeek:  "# {{.Title}}\n\n{{.Previous}} · {{.Month}} · {{.Next}}\n\n{{range .Days}}- {{.}}\n{{end}}\n",
+	periodMonth: "# {{.Title}}\n\n{{.Previous}} · {{.Next}}\n\n",
+}
+
+func (p NotePeriod) String() string {
+	return [...]string{"daily", "weekly", "monthly"}[p]
+}
+
+// start is the first day of the period t is in. Weeks start on Monday, as
+// ISO weeks do.
+func (p NotePeriod) start(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch p {
+	case periodWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case periodMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+	return day
+}
+
+// add moves t by n periods.
+func (p NotePeriod) add(t time.Time, n int) time.Time {
+	switch p {
+	case periodWeek:
+		return t.AddDate(0, 0, 7*n)
+	case periodMonth:
+		return t.AddDate(0, n, 0)
+	}
+	return t.AddDate(0, 0, n)
+}
+
+// name is the file name of the note for the period starting at start,
+// without the extension: 2025-03-14, 2025-W11 or 2025-03.
+func (p NotePeriod) name(start time.Time) string {
+	switch p {
+	case periodWeek:
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case periodMonth:
+		return start.Format("2006-01")
+	}
+	return start.Format("2006-01-02")
+}
+
+func (p NotePeriod) title(start time.Time) string {
+	switch p {
+	case periodWeek:
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("Week %d, %d", week, year)
+	case periodMonth:
+		return start.Format("January 2006")
+	}
+	return start.Format("Monday, January 2, 2006")
+}
+
+// parsePeriodicName reads the period and start of a note from its file name.
+func parsePeriodicName(path string) (NotePeriod, time.Time, bool) {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if m := dayNoteRe.FindStringSubmatch(base); m != nil {
+		t, err := time.ParseInLocation("2006-01-02", m[1], time.Local)
+		return periodDay, t, err == nil
+	}
+	if m := weekNoteRe.FindStringSubmatch(base); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		week, _ := strconv.Atoi(m[2])
+		// January 4 is always in week 1
+		jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.Local)
+		t := periodWeek.start(jan4).AddDate(0, 0, 7*(week-1))
+		if y, w := t.ISOWeek(); week < 1 || y != year || w != week {
+			return 0, time.Time{}, false
+		}
+		return periodWeek, t, true
+	}
+	if m := monthNoteRe.FindStringSubmatch(base); m != nil {
+		t, err := time.ParseInLocation("2006-01", m[1], time.Local)
+		return periodMonth, t, err == nil
+	}
+	return 0, time.Time{}, false
+}
+
+// PeriodicConfig is the [periodic] table. Directory is where periodic notes
+// go, relative to the working directory; each period can have a directory
+// and template of its own. A template is a file, or the name of one in the
+// templates directory, where daily.md, weekly.md and monthly.md are used
+// without being named.
+type PeriodicConfig struct {
+	Directory string       `toml:"directory"`
+	Daily     PeriodConfig `toml:"daily"`
+	Weekly    PeriodConfig `toml:"weekly"`
+	Monthly   PeriodConfig `toml:"monthly"`
+}
+
+type PeriodConfig struct {
+	Directory string `toml:"directory"`
+	Template  string `toml:"template"`
+}
+
+func (c PeriodicConfig) period(p NotePeriod) PeriodConfig {
+	return [...]PeriodConfig{c.Daily, c.Weekly, c.Monthly}[p]
+}
+
+func (c PeriodicConfig) dir(p NotePeriod) string {
+	if dir := c.period(p).Directory; dir != "" {
+		return dir
+	}
+	return c.Directory
+}
+
+// Path is the file of the note for the period starting at start.
+func (c PeriodicConfig) Path(p NotePeriod, start time.Time) string {
+	return filepath.Join(c.dir(p), p.name(start)+".md")
+}
+
+// template reads the template of p, or the built-in one when there is none.
+func (c PeriodicConfig) template(p NotePeriod) (string, error) {
+	name := c.period(p).Template
+	var candidates []string
+	if name == "" {
+		name = p.String()
+	} else {
+		candidates = append(candidates, name)
+	}
+	if dir := TemplatesDir(); dir != "" && !filepath.IsAbs(name) {
+		candidates = append(candidates, filepath.Join(dir, name), filepath.Join(dir, name+".md"))
+	}
+	for _, path := range candidates {
+		if data, err := os.ReadFile(path); err == nil {
+			return string(data), nil
+		}
+	}
+	if c.period(p).Template != "" {
+		return "", fmt.Errorf("%s note template %q not found (looked in %s)", p, name, TemplatesDir())
+	}
+	return defaultPeriodicTemplates[p], nil
+}
+
+// NoteLink is a link from one periodic note to another. It prints as a
+// markdown link.
+type NoteLink struct {
+	Name string
+	Path string
+}
+
+func (l NoteLink) String() string {
+	return "[" + l.Name + "](" + filepath.ToSlash(l.Path) + ")"
+}
+
+// PeriodicNote is what a periodic note template gets: {{.Title}},
+// {{.Previous}} and so on. Week is empty for weekly and monthly notes, Month
+// for monthly ones, and Days lists the days of a week or month.
+type PeriodicNote struct {
+	Period   string
+	Name     string
+	Title    string
+	Start    time.Time
+	End      time.Time
+	Previous NoteLink
+	Next     NoteLink
+	Week     *NoteLink
+	Month    *NoteLink
+	Days     []NoteLink
+}
+
+func (c PeriodicConfig) note(p NotePeriod, start time.Time) PeriodicNote {
+	from := filepath.Dir(c.Path(p, start))
+	link := func(q NotePeriod, t time.Time) NoteLink {
+		path := c.Path(q, t)
+		if rel, err := filepath.Rel(from, path); err == nil {
+			path = rel
+		}
+		return NoteLink{Name: q.name(t), Path: path}
+	}
+	next := p.add(start, 1)
+	note := PeriodicNote{
+		Period:   p.String(),
+		Name:     p.name(start),
+		Title:    p.title(start),
+		Start:    start,
+		End:      next.AddDate(0, 0, -1),
+		Previous: link(p, p.add(start, -1)),
+		Next:     link(p, next),
+	}
+	if p == periodDay {
+		week := link(periodWeek, periodWeek.start(start))
+		note.Week = &week
+	}
+	if p != periodMonth {
+		month := link(periodMonth, periodMonth.start(start))
+		note.Month = &month
+	}
+	if p != periodDay {
+		for day := start; day.Before(next); day = day.AddDate(0, 0, 1) {
+			note.Days = append(note.Days, link(periodDay, day))
+		}
+	}
+	return note
+}
+
+// render fills in the template of p for the period starting at start. A
+// daily note always links to its week: when the template leaves the link
+// out, it goes below the first heading.
+func (c PeriodicConfig) render(p NotePeriod, start time.Time) (string, error) {
+	text, err := c.template(p)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New(p.String()).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("error in %s note template: %v", p, err)
+	}
+	note := c.note(p, start)
+	var out strings.Builder
+	if err := tmpl.Execute(&out, note); err != nil {
+		return "", fmt.Errorf("error in %s note template: %v", p, err)
+	}
+	content := out.String()
+	if note.Week != nil && !strings.Contains(content, "("+filepath.ToSlash(note.Week.Path)+")") {
+		weekLine := "Week: " + note.Week.String() + "\n\n"
+		if first, rest, ok := strings.Cut(content, "\n"); ok && strings.HasPrefix(first, "#") {
+			content = first + "\n\n" + weekLine + strings.TrimLeft(rest, "\n")
+		} else {
+			content = weekLine + content
+		}
+	}
+	return content, nil
+}
+
+// OpenPeriodicNote returns the note of p for the period t is in, creating it
+// from its template when it does not exist yet.
+func (c PeriodicConfig) OpenPeriodicNote(p NotePeriod, t time.Time) (string, error) {
+	start := p.start(t)
+	path := c.Path(p, start)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	content, err := c.render(p, start)
+	if err != nil {
+		return "", err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("error creating %s note: %v", p, err)
+		}
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("error creating %s note: %v", p, err)
+	}
+	return path, nil
+}
+
+// AdjacentPeriodicNote is the closest existing note of the same period
+// before (step -1) or after (step 1) the periodic note at path.
+func (c PeriodicConfig) AdjacentPeriodicNote(path string, step int) (string, error) {
+	p, start, ok := parsePeriodicName(path)
+	if !ok {
+		return "", fmt.Errorf("%s is not a periodic note", filepath.Base(path))
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return "", fmt.Errorf("error listing %s notes: %v", p, err)
+	}
+	var starts []time.Time
+	for _, entry := range entries {
+		if q, t, ok := parsePeriodicName(entry.Name()); ok && q == p && !entry.IsDir() && filepath.Ext(entry.Name()) == ".md" {
+			starts = append(starts, t)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	i := sort.Search(len(starts), func(i int) bool { return !starts[i].Before(start) })
+	if step < 0 {
+		i--
+	} else if i < len(starts) && starts[i].Equal(start) {
+		i++
+	}
+	if i < 0 || i >= len(starts) {
+		direction := "later"
+		if step < 0 {
+			direction = "earlier"
+		}
+		return "", fmt.Errorf("no %s %s note", direction, p)
+	}
+	return filepath.Join(filepath.Dir(path), p.name(starts[i])+".md"), nil
+}
+
+func (m *model) openPeriodicPicker() {
+	now := time.Now()
+	items := make([]pickerItem, len(notePeriods))
+	for i, p := range notePeriods {
+		title := [...]string{"Today", "This week", "This month"}[p]
+		items[i] = pickerItem{title: title, detail: m.periodic.Path(p, p.start(now)), index: i}
+	}
+	m.overlay = overlayPeriodic
+	m.picker = newPicker("Periodic Notes", items)
+	m.picker.hint = "enter: open"
+}
+
+func (m *model) openPeriodicNote(p NotePeriod) {
+	path, err := m.periodic.OpenPeriodicNote(p, time.Now())
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	m.openBuffer(path)
+}
+
+func (m *model) openAdjacentNote(step int) {
+	path, err := m.periodic.AdjacentPeriodicNote(m.filename, step)
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	m.openBuffer(path)
+}
+
+func (g *GUIApp) openPeriodicNote(p NotePeriod) {
+	path, err := g.config.Periodic.OpenPeriodicNote(p, time.Now())
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+	g.confirmDiscard(func() { g.openPath(path, Location{}) })
+}
+
+func (g *GUIApp) openAdjacentNote(step int) {
+	path, err := g.config.Periodic.AdjacentPeriodicNote(g.currentFile, step)
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+	g.confirmDiscard(func() { g.openPath(path, Location{}) })
+}
+
+// periodicMenu is File → Periodic Notes.
+func (g *GUIApp) periodicMenu() *fyne.Menu {
+	today := fyne.NewMenuItem("Today", func() { g.openPeriodicNote(periodDay) })
+	today.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyT, Modifier: fyne.KeyModifierAlt}
+	week := fyne.NewMenuItem("This Week", func() { g.openPeriodicNote(periodWeek) })
+	month := fyne.NewMenuItem("This Month", func() { g.openPeriodicNote(periodMonth) })
+	previous := fyne.NewMenuItem("Previous Period", func() { g.openAdjacentNote(-1) })
+	previous.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyComma, Modifier: fyne.KeyModifierAlt}
+	next := fyne.NewMenuItem("Next Period", func() { g.openAdjacentNote(1) })
+	next.Shortcut = &desktop.CustomShortcut{KeyName: fyne.KeyPeriod, Modifier: fyne.KeyModifierAlt}
+	return fyne.NewMenu("", today, week, month, fyne.NewMenuItemSeparator(), previous, next)
+}
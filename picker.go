@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+type overlayKind int
+
+const (
+	overlayNone overlayKind = iota
+	overlayLint
+	overlayOutline
+	overlayFiles
+	overlayReplace
+	overlayHelp
+	overlayKeys
+	overlayConfirm
+	overlayRecover
+	overlaySort
+	overlayReload
+	overlayStats
+	overlayLanguage
+	overlayRename
+	overlayTrash
+	overlayTools
+	overlayToolOutput
+	overlayCommand
+	overlayClips
+	overlayFlashcards
+	overlayResurface
+	overlayPeriodic
+	overlayMeeting
+	overlayBlank
+	overlayRunbook
+	overlayLock
+	overlayChanges
+	overlayBookmarks
+	overlaySaveUntitled
+	overlaySecrets
+	overlayQuickFix
+)
+
+type pickerItem struct {
+	title  string
+	detail string
+	index  int
+}
+
+type picker struct {
+	title    string
+	items    []pickerItem
+	query    string
+	filtered []int
+	cursor   int
+	// hint lists keys the picker has besides the usual ones
+	hint string
+}
+
+var (
+	pickerStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#7D56F4")).
+			Padding(0, 1)
+
+	pickerSelectedStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#FAFAFA")).
+				Background(lipgloss.Color("#7D56F4"))
+
+	pickerDetailStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#888888"))
+)
+
+func newPicker(title string, items []pickerItem) *picker {
+	p := &picker{
+		title: title,
+		items: items,
+	}
+	p.filter()
+	return p
+}
+
+func (p *picker) filter() {
+	p.filtered = p.filtered[:0]
+	query := strings.ToLower(p.query)
+	for i, item := range p.items {
+		if query == "" || strings.Contains(strings.ToLower(item.title+" "+item.detail), query) {
+			p.filtered = append(p.filtered, i)
+		}
+	}
+	if p.cursor >= len(p.filtered) {
+		p.cursor = len(p.filtered) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+}
+
+func (p *picker) selected() (pickerItem, bool) {
+	if len(p.filtered) == 0 {
+		return pickerItem{}, false
+	}
+	return p.items[p.filtered[p.cursor]], true
+}
+
+// update handles a key press and reports whether the picker was closed and,
+// if so, whether an item was chosen.
+func (p *picker) update(msg tea.KeyMsg) (closed bool, chosen bool) {
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		return true, false
+	case "enter":
+		_, ok := p.selected()
+		return true, ok
+	case "up", "ctrl+k":
+		if p.cursor > 0 {
+			p.cursor--
+		}
+	case "down", "ctrl+j":
+		if p.cursor < len(p.filtered)-1 {
+			p.cursor++
+		}
+	case "pgup":
+		p.cursor = max(p.cursor-10, 0)
+	case "pgdown":
+		p.cursor = max(min(p.cursor+10, len(p.filtered)-1), 0)
+	case "backspace":
+		if p.query != "" {
+			runes := []rune(p.query)
+			p.query = string(runes[:len(runes)-1])
+			p.filter()
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			p.query += string(msg.Runes)
+			p.filter()
+		}
+	}
+	return false, false
+}
+
+func (p *picker) view(width, height int) string {
+	var lines []string
+	lines = append(lines, titleStyle.Render(p.title))
+	lines = append(lines, helpStyle.Render("> "+p.query))
+
+	visible := height - 6
+	if visible < 3 {
+		visible = 3
+	}
+	start := 0
+	if p.cursor >= visible {
+		start = p.cursor - visible + 1
+	}
+
+	if len(p.filtered) == 0 {
+		lines = append(lines, pickerDetailStyle.Render("no matches"))
+	}
+	for i := start; i < len(p.filtered) && i < start+visible; i++ {
+		item := p.items[p.filtered[i]]
+		text := item.title
+		if i == p.cursor {
+			text = pickerSelectedStyle.Render(item.title)
+		}
+		if item.detail != "" {
+			text += "  " + pickerDetailStyle.Render(item.detail)
+		}
+		lines = append(lines, ansi.Truncate(text, width-6, "…"))
+	}
+
+	keys := "enter: select • esc: close"
+	if p.hint != "" {
+		keys = p.hint + " • esc: close"
+	}
+	lines = append(lines, helpStyle.Render(fmt.Sprintf("%d/%d • %s", len(p.filtered), len(p.items), keys)))
+
+	return pickerStyle.Width(width - 4).Render(strings.Join(lines, "\n"))
+}
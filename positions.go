@@ -0,0 +1,486 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/BurntSushi/toml"
+)
+
+// maxPositions is how many files the position memory holds on to; the ones
+// opened longest ago are forgotten first.
+const maxPositions = 500
+
+// PositionsConfig is the [positions] table. With Workspace set, where the
+// files under the working directory were left and their bookmarks are also
+// kept in .parselt/positions, so that syncing the workspace carries them to
+// other machines.
+type PositionsConfig struct {
+	Workspace bool `toml:"workspace"`
+}
+
+// FilePosition is where a file was left: the cursor, and the source line at
+// the top of the preview so that the same spot shows in either app. Seen is
+// when it was left, and Marked when its bookmarks last changed.
+type FilePosition struct {
+	Line      int        `toml:"line"`
+	Column    int        `toml:"column"`
+	Preview   int        `toml:"preview"`
+	Seen      time.Time  `toml:"seen"`
+	Bookmarks []Bookmark `toml:"bookmarks,omitempty"`
+	Marked    time.Time  `toml:"marked,omitempty"`
+}
+
+// Bookmark is a line of a file to come back to. Text is what the line said,
+// to find it again after the lines above it changed.
+type Bookmark struct {
+	Line int    `toml:"line"`
+	Text string `toml:"text"`
+}
+
+type positionStore struct {
+	Files map[string]FilePosition `toml:"files"`
+}
+
+func positionsPath() string {
+	return filepath.Join(stateDir(), "positions.toml")
+}
+
+// workspacePositions holds the positions kept in the workspace, a file per
+// machine. Each machine only writes its own, so a sync never has two
+// versions of one file to reconcile.
+var workspacePositions = filepath.Join(workspaceDir, "positions")
+
+func hostPositionsPath() string {
+	host, _ := os.Hostname()
+	host = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, host)
+	if host == "" {
+		host = "local"
+	}
+	return filepath.Join(workspacePositions, host+".toml")
+}
+
+// workspaceKey is how the workspace positions name the file at abs: its
+// path from the working directory, with forward slashes. Files outside
+// the working directory have none.
+func workspaceKey(abs string) (string, bool) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+func loadPositions(path string) (*positionStore, error) {
+	store := &positionStore{Files: map[string]FilePosition{}}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return store, nil
+	}
+	if _, err := toml.DecodeFile(path, store); err != nil {
+		return store, fmt.Errorf("error reading file positions: %v", err)
+	}
+	if store.Files == nil {
+		store.Files = map[string]FilePosition{}
+	}
+	return store, nil
+}
+
+// writePositions saves store to path, forgetting the files opened longest
+// ago when it holds too many.
+func writePositions(path string, store *positionStore) error {
+	if len(store.Files) > maxPositions {
+		paths := make([]string, 0, len(store.Files))
+		for p := range store.Files {
+			paths = append(paths, p)
+		}
+		sort.Slice(paths, func(i, j int) bool {
+			return store.Files[paths[i]].Seen.Before(store.Files[paths[j]].Seen)
+		})
+		for _, p := range paths[:len(paths)-maxPositions] {
+			delete(store.Files, p)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error saving file position: %v", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error saving file position: %v", err)
+	}
+	defer file.Close()
+	if err := toml.NewEncoder(file).Encode(store); err != nil {
+		return fmt.Errorf("error saving file position: %v", err)
+	}
+	return nil
+}
+
+// mergePositions makes one position of two records of a file: the cursor
+// of the one left last, and the bookmarks of the one marked last.
+func mergePositions(a, b FilePosition) FilePosition {
+	merged := a
+	if b.Seen.After(a.Seen) {
+		merged = b
+		merged.Bookmarks, merged.Marked = a.Bookmarks, a.Marked
+	}
+	if b.Marked.After(a.Marked) {
+		merged.Bookmarks, merged.Marked = b.Bookmarks, b.Marked
+	}
+	return merged
+}
+
+// LoadPosition returns where path was left the last time it was open, here
+// or, with the workspace positions on, on any machine sharing them.
+func LoadPosition(path string, cfg PositionsConfig) (FilePosition, bool) {
+	abs, err := normalizePath(path)
+	if err != nil {
+		return FilePosition{}, false
+	}
+	store, err := loadPositions(positionsPath())
+	if err != nil {
+		return FilePosition{}, false
+	}
+	pos, ok := store.Files[abs]
+	if !cfg.Workspace {
+		return pos, ok
+	}
+	key, inside := workspaceKey(abs)
+	if !inside {
+		return pos, ok
+	}
+	files, _ := filepath.Glob(filepath.Join(workspacePositions, "*.toml"))
+	for _, file := range files {
+		shared, err := loadPositions(file)
+		if err != nil {
+			continue
+		}
+		if other, found := shared.Files[key]; found {
+			if ok {
+				other = mergePositions(pos, other)
+			}
+			pos, ok = other, true
+		}
+	}
+	return pos, ok
+}
+
+// updatePosition changes what is remembered of path, starting from what
+// LoadPosition returns, and keeps it in the workspace too when cfg asks.
+func updatePosition(path string, cfg PositionsConfig, change func(*FilePosition)) error {
+	abs, err := normalizePath(path)
+	if err != nil {
+		return fmt.Errorf("error saving file position: %v", err)
+	}
+	pos, _ := LoadPosition(path, cfg)
+	change(&pos)
+
+	store, err := loadPositions(positionsPath())
+	if err != nil {
+		return err
+	}
+	store.Files[abs] = pos
+	if err := writePositions(positionsPath(), store); err != nil {
+		return err
+	}
+
+	key, inside := workspaceKey(abs)
+	if !cfg.Workspace || !inside {
+		return nil
+	}
+	shared, err := loadPositions(hostPositionsPath())
+	if err != nil {
+		return err
+	}
+	shared.Files[key] = pos
+	return writePositions(hostPositionsPath(), shared)
+}
+
+// SavePosition remembers pos for path, keeping its bookmarks.
+func SavePosition(path string, pos FilePosition, cfg PositionsConfig) error {
+	return updatePosition(path, cfg, func(p *FilePosition) {
+		p.Line, p.Column, p.Preview = pos.Line, pos.Column, pos.Preview
+		p.Seen = time.Now()
+	})
+}
+
+// placeBookmarks finds marks in content: each stays on its line while that
+// still says the same, and otherwise moves to the nearest line that does.
+// Marks whose line is gone stay where they were, within the document.
+func placeBookmarks(marks []Bookmark, content string) []Bookmark {
+	lines := strings.Split(content, "\n")
+	placed := make([]Bookmark, 0, len(marks))
+	taken := map[int]bool{}
+	for _, mark := range marks {
+		line := min(max(mark.Line, 0), len(lines)-1)
+		if strings.TrimSpace(lines[line]) != mark.Text {
+			for d := 1; d < len(lines); d++ {
+				if up := line - d; up >= 0 && strings.TrimSpace(lines[up]) == mark.Text {
+					line = up
+					break
+				}
+				if down := line + d; down < len(lines) && strings.TrimSpace(lines[down]) == mark.Text {
+					line = down
+					break
+				}
+			}
+		}
+		if taken[line] {
+			continue
+		}
+		taken[line] = true
+		placed = append(placed, Bookmark{Line: line, Text: strings.TrimSpace(lines[line])})
+	}
+	sort.Slice(placed, func(i, j int) bool { return placed[i].Line < placed[j].Line })
+	return placed
+}
+
+// LoadBookmarks returns the bookmarks of path, placed in its content.
+func LoadBookmarks(path, content string, cfg PositionsConfig) []Bookmark {
+	pos, _ := LoadPosition(path, cfg)
+	return placeBookmarks(pos.Bookmarks, content)
+}
+
+// ToggleBookmark bookmarks line of path, whose text is content, or removes
+// the bookmark there. It reports whether the line is bookmarked now.
+func ToggleBookmark(path, content string, line int, cfg PositionsConfig) (bool, error) {
+	added := false
+	err := updatePosition(path, cfg, func(p *FilePosition) {
+		marks := placeBookmarks(p.Bookmarks, content)
+		i := slices.IndexFunc(marks, func(mark Bookmark) bool { return mark.Line == line })
+		if i >= 0 {
+			marks = slices.Delete(marks, i, i+1)
+		} else {
+			marks = placeBookmarks(append(marks, Bookmark{Line: line, Text: strings.TrimSpace(strings.Split(content, "\n")[line])}), content)
+			added = true
+		}
+		p.Bookmarks, p.Marked = marks, time.Now()
+	})
+	return added, err
+}
+
+// restorePosition puts the cursor of the active buffer back where its file
+// was left. The preview follows once it is rendered.
+func (m *model) restorePosition() {
+	if m.filename == "" {
+		return
+	}
+	pos, ok := LoadPosition(m.filename, m.positions)
+	if !ok {
+		return
+	}
+	row := min(pos.Line, m.textarea.LineCount()-1)
+	moveCursorTo(&m.textarea, row, pos.Column)
+	m.previewLine = pos.Preview
+}
+
+func (m *model) savePosition() {
+	if m.filename == "" {
+		return
+	}
+	info := m.textarea.LineInfo()
+	pos := FilePosition{
+		Line:    m.textarea.Line() + m.narrow.lineOffset(),
+		Column:  info.StartColumn + info.ColumnOffset,
+		Preview: m.previewLine,
+	}
+	if m.mode != editMode && m.renderedMD != "" {
+		pos.Preview = m.scrollMap.SourceLine(m.viewport.YOffset)
+	} else if pos.Preview < 0 {
+		// The preview was not shown; keep where it was before
+		previous, _ := LoadPosition(m.filename, m.positions)
+		pos.Preview = previous.Preview
+	}
+	if err := SavePosition(m.filename, pos, m.positions); err != nil {
+		m.status = err.Error()
+	}
+}
+
+// restorePosition puts the editor cursor and the preview back where the
+// current file was left.
+func (g *GUIApp) restorePosition() {
+	if g.currentFile == "" {
+		return
+	}
+	pos, ok := LoadPosition(g.currentFile, g.config.Positions)
+	if !ok {
+		return
+	}
+	lines := strings.Split(g.editor.Text, "\n")
+	row := min(pos.Line, len(lines)-1)
+	g.editor.CursorRow = row
+	g.editor.CursorColumn = min(pos.Column, len([]rune(lines[row])))
+	g.editor.Refresh()
+	g.previewLine = pos.Preview
+}
+
+// restorePreview scrolls a freshly rendered preview to the line restored
+// with the file. While the panes scroll together the cursor decides instead.
+func (g *GUIApp) restorePreview() {
+	if g.previewLine < 0 || g.preview.Size().Width <= 0 {
+		return
+	}
+	line := g.previewLine
+	g.previewLine = -1
+	if g.previewSyncing() {
+		g.scrollPreviewTo(g.editor.CursorRow)
+		return
+	}
+	scrollable := g.preview.MinSize().Height - g.previewScroll.Size().Height
+	if scrollable <= 0 {
+		return
+	}
+	y := float32(line)
+	if positions := g.previewPositions(); positions != nil {
+		y = float32(positions.RenderedLine(line))
+	} else if lines := sourceLineCount(g.editor.Text); lines > 1 {
+		y = scrollable * float32(line) / float32(lines-1)
+	}
+	g.previewScroll.ScrollToOffset(fyne.NewPos(0, min(max(y, 0), scrollable)))
+}
+
+func (g *GUIApp) savePosition() {
+	if g.currentFile == "" {
+		return
+	}
+	pos := FilePosition{Line: g.editor.CursorRow + g.narrow.lineOffset(), Column: g.editor.CursorColumn, Preview: g.previewLine}
+	if pos.Preview < 0 {
+		pos.Preview = g.previewTopLine()
+	}
+	if err := SavePosition(g.currentFile, pos, g.config.Positions); err != nil {
+		log.Printf("Error saving position: %v", err)
+	}
+}
+
+// previewTopLine is the source line of what shows at the top of the preview.
+func (g *GUIApp) previewTopLine() int {
+	offset := g.previewScroll.Offset.Y
+	if positions := g.previewPositions(); positions != nil {
+		return positions.SourceLine(int(offset))
+	}
+	if scrollable := g.preview.MinSize().Height - g.previewScroll.Size().Height; scrollable > 0 {
+		return int(offset / scrollable * float32(sourceLineCount(g.editor.Text)-1))
+	}
+	return 0
+}
+
+// toggleBookmark bookmarks the line under the cursor, or removes its
+// bookmark.
+func (m *model) toggleBookmark() {
+	if m.filename == "" {
+		m.status = "Save the document first, bookmarks are kept by file"
+		return
+	}
+	line := m.textarea.Line() + m.narrow.lineOffset()
+	added, err := ToggleBookmark(m.filename, m.document(), line, m.positions)
+	switch {
+	case err != nil:
+		m.status = err.Error()
+	case added:
+		m.status = fmt.Sprintf("Bookmarked line %d", line+1)
+	default:
+		m.status = fmt.Sprintf("Removed the bookmark on line %d", line+1)
+	}
+}
+
+// openBookmarks lists the bookmarks of the active buffer.
+func (m *model) openBookmarks() {
+	if m.filename == "" {
+		m.status = "Save the document first, bookmarks are kept by file"
+		return
+	}
+	marks := LoadBookmarks(m.filename, m.document(), m.positions)
+	if len(marks) == 0 {
+		m.status = fmt.Sprintf("No bookmarks in %s: %s bookmarks a line", filepath.Base(m.filename), m.keys.bookmark.Help().Key)
+		return
+	}
+	m.bookmarkList = marks
+	items := make([]pickerItem, len(marks))
+	for i, mark := range marks {
+		items[i] = pickerItem{title: bookmarkTitle(mark), detail: fmt.Sprintf("line %d", mark.Line+1), index: i}
+	}
+	m.overlay = overlayBookmarks
+	m.picker = newPicker("Bookmarks in "+filepath.Base(m.filename), items)
+	m.picker.hint = "enter: go to line"
+}
+
+func (m *model) jumpToBookmark(mark Bookmark) {
+	row := mark.Line - m.narrow.lineOffset()
+	if row < 0 || row >= m.textarea.LineCount() {
+		m.status = "That bookmark is outside the narrowed section"
+		return
+	}
+	m.jumpTo(row, 0)
+}
+
+func bookmarkTitle(mark Bookmark) string {
+	if mark.Text == "" {
+		return "(blank line)"
+	}
+	return mark.Text
+}
+
+// toggleBookmark bookmarks the line of the cursor, or removes its bookmark.
+func (g *GUIApp) toggleBookmark() {
+	if g.currentFile == "" {
+		dialog.ShowInformation("Bookmarks", "Save the document first, bookmarks are kept by file.", g.window)
+		return
+	}
+	line := g.editor.CursorRow + g.narrow.lineOffset()
+	if _, err := ToggleBookmark(g.currentFile, g.document(), line, g.config.Positions); err != nil {
+		dialog.ShowError(err, g.window)
+	}
+}
+
+// showBookmarks lists the bookmarks of the document; choosing one goes to
+// its line.
+func (g *GUIApp) showBookmarks() {
+	if g.currentFile == "" {
+		dialog.ShowInformation("Bookmarks", "Save the document first, bookmarks are kept by file.", g.window)
+		return
+	}
+	marks := LoadBookmarks(g.currentFile, g.document(), g.config.Positions)
+	if len(marks) == 0 {
+		dialog.ShowInformation("Bookmarks", fmt.Sprintf("No bookmarks in %s. Go → Toggle Bookmark bookmarks the line of the cursor.", filepath.Base(g.currentFile)), g.window)
+		return
+	}
+
+	var d dialog.Dialog
+	list := widget.NewList(
+		func() int { return len(marks) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(fmt.Sprintf("%d   %s", marks[id].Line+1, bookmarkTitle(marks[id])))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		d.Hide()
+		row := marks[id].Line - g.narrow.lineOffset()
+		if row < 0 || row >= sourceLineCount(g.editor.Text) {
+			dialog.ShowInformation("Bookmarks", "That bookmark is outside the narrowed section.", g.window)
+			return
+		}
+		g.jumpToLine(row)
+	}
+	d = dialog.NewCustom("Bookmarks in "+filepath.Base(g.currentFile), "Close", list, g.window)
+	d.Resize(fyne.NewSize(560, 360))
+	d.Show()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+// tapArea passes taps on content that no widget inside it takes, such as a
+// link or a task checkbox, to onTapped with the position within content.
+type tapArea struct {
+	widget.BaseWidget
+	content  fyne.CanvasObject
+	onTapped func(fyne.Position)
+}
+
+func newTapArea(content fyne.CanvasObject, onTapped func(fyne.Position)) *tapArea {
+	t := &tapArea{content: content, onTapped: onTapped}
+	t.ExtendBaseWidget(t)
+	return t
+}
+
+func (t *tapArea) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(t.content)
+}
+
+func (t *tapArea) Tapped(event *fyne.PointEvent) {
+	t.onTapped(event.Position)
+}
+
+// showSource puts the editor cursor on the source line of what was clicked at
+// pos in the preview, through the same map that keeps the panes scrolling
+// together.
+func (g *GUIApp) showSource(pos fyne.Position) {
+	lines := strings.Split(g.editor.Text, "\n")
+	line := 0
+	if positions := g.previewPositions(); positions != nil {
+		line = positions.SourceLine(int(pos.Y))
+	} else if height := g.preview.MinSize().Height; height > 0 {
+		line = int(pos.Y / height * float32(len(lines)-1))
+	}
+	// Between two blocks the map runs through the blank lines separating them
+	line = min(max(line, 0), len(lines)-1)
+	for line > 0 && strings.TrimSpace(lines[line]) == "" {
+		line--
+	}
+
+	g.scrollSyncing = true
+	g.editor.CursorRow, g.editor.CursorColumn = line, 0
+	g.editor.Refresh()
+	g.scrollSyncing = false
+	if g.splitPanel.Offset > 0.05 {
+		g.window.Canvas().Focus(g.editor)
+	}
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// quickFixSuggestions is how many replacements the spelling and link
+// checks offer at most.
+const quickFixSuggestions = 5
+
+var spellWordRe = regexp.MustCompile(`\p{L}+(?:['’]\p{L}+)*`)
+
+// QuickFix is a change offered for the issue under the cursor: Title says
+// what it does and Source which check found the issue, "spelling", "lint"
+// or "link". Text replaces Range of the document.
+type QuickFix struct {
+	Source string
+	Title  string
+	Range  TextRange
+	Text   string
+}
+
+func (f QuickFix) Apply(content string) string {
+	return content[:f.Range.Start] + f.Text + content[f.Range.End:]
+}
+
+// QuickFixes gathers what can be done about the issues at byte offset pos
+// of content, the document at docPath: the word there when it is spelled
+// wrong, the lint issues of the line that have a fix, and a link there that
+// leads to a file or heading that is not there.
+func (smp *SharedMarkdownProcessor) QuickFixes(content string, pos int, docPath string, linter *Linter) []QuickFix {
+	start := strings.LastIndex(content[:pos], "\n") + 1
+	end := len(content)
+	if i := strings.Index(content[pos:], "\n"); i >= 0 {
+		end = pos + i
+	}
+	line, col := content[start:end], pos-start
+	row := strings.Count(content[:start], "\n")
+	inCode := codeBlockLines(strings.Split(content, "\n"))[row]
+
+	var fixes []QuickFix
+	if !inCode {
+		fixes = append(fixes, spellingFixes(line, col)...)
+	}
+	for _, issue := range linter.Lint(content) {
+		if issue.Line != row || issue.Fix == nil {
+			continue
+		}
+		fixes = append(fixes, QuickFix{
+			Source: "lint",
+			Title:  fmt.Sprintf("Use %q: %s", issue.Fix.Text, issue.Message),
+			Range:  TextRange{Start: issue.Fix.Start, End: issue.Fix.End},
+			Text:   issue.Fix.Text,
+		})
+	}
+	if !inCode {
+		fixes = append(fixes, smp.linkFixes(content, line, col, docPath)...)
+	}
+
+	// The checks work on the line; the fixes are made to the document
+	for i := range fixes {
+		fixes[i].Range.Start += start
+		fixes[i].Range.End += start
+	}
+	return fixes
+}
+
+// spellingFixes offers the spellings aspell or hunspell suggest for the
+// word at col of line, leaving alone inline code, URLs and what links lead
+// to.
+func spellingFixes(line string, col int) []QuickFix {
+	var word []int
+	for _, m := range spellWordRe.FindAllStringIndex(line, -1) {
+		if m[0] <= col && col <= m[1] {
+			word = m
+			break
+		}
+	}
+	if word == nil || strings.Count(line[:word[0]], "`")%2 == 1 {
+		return nil
+	}
+	for _, re := range []*regexp.Regexp{peekLinkRe, peekImageRe} {
+		for _, m := range re.FindAllStringSubmatchIndex(line, -1) {
+			if word[0] >= m[4] && word[1] <= m[5] {
+				return nil
+			}
+		}
+	}
+	for _, m := range peekWikiRe.FindAllStringIndex(line, -1) {
+		if word[0] >= m[0] && word[1] <= m[1] {
+			return nil
+		}
+	}
+	fieldStart := strings.LastIndexAny(line[:word[0]], " \t") + 1
+	fieldEnd := len(line)
+	if i := strings.IndexAny(line[word[1]:], " \t"); i >= 0 {
+		fieldEnd = word[1] + i
+	}
+	if strings.Contains(line[fieldStart:fieldEnd], "://") {
+		return nil
+	}
+
+	text := line[word[0]:word[1]]
+	wrong, suggestions := spellCheck(text)
+	if !wrong {
+		return nil
+	}
+	var fixes []QuickFix
+	for _, suggestion := range suggestions[:min(len(suggestions), quickFixSuggestions)] {
+		fixes = append(fixes, QuickFix{
+			Source: "spelling",
+			Title:  fmt.Sprintf("Change %q to %q", text, suggestion),
+			Range:  TextRange{Start: word[0], End: word[1]},
+			Text:   suggestion,
+		})
+	}
+	return fixes
+}
+
+// spellCheck asks aspell, or hunspell, whether word is spelled wrong and
+// what it might be instead, best first. Without either of them installed
+// every word is taken to be right.
+func spellCheck(word string) (bool, []string) {
+	for _, tool := range []string{"aspell", "hunspell"} {
+		cmd := exec.Command(tool, "-a")
+		cmd.Stdin = strings.NewReader("^" + word + "\n")
+		out, err := cmd.Output()
+		if errors.Is(err, exec.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, nil
+		}
+		// After a banner, "*" is a word it knows, "& word count offset:
+		// one, two" one it does not with suggestions and "# word offset"
+		// one without
+		for _, reply := range strings.Split(string(out), "\n") {
+			switch {
+			case strings.HasPrefix(reply, "& "):
+				_, list, _ := strings.Cut(reply, ": ")
+				return true, strings.Split(list, ", ")
+			case strings.HasPrefix(reply, "# "):
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, nil
+}
+
+// linkFixes offers the nearest notes or headings for the markdown or wiki
+// link at col of line when what it leads to is not there, and to unlink it
+// keeping its text.
+func (smp *SharedMarkdownProcessor) linkFixes(content, line string, col int, docPath string) []QuickFix {
+	var span TextRange
+	var target, text string
+	var wiki bool
+	for _, m := range peekWikiRe.FindAllStringSubmatchIndex(line, -1) {
+		if m[0] <= col && col <= m[1] {
+			inner := line[m[2]:m[3]]
+			target, text, _ = strings.Cut(inner, "|")
+			if text == "" {
+				text = target
+			}
+			span = TextRange{Start: m[2], End: m[2] + len(target)}
+			target = strings.TrimSpace(target)
+			span.Start += strings.Index(line[span.Start:span.End], target)
+			span.End = span.Start + len(target)
+			wiki = true
+			break
+		}
+	}
+	if !wiki {
+		for _, m := range peekLinkRe.FindAllStringSubmatchIndex(line, -1) {
+			if m[0] <= col && col <= m[1] && (m[0] == 0 || line[m[0]-1] != '!') {
+				inner := line[m[4]:m[5]]
+				target, text = linkDestination(inner), line[m[2]:m[3]]
+				span.Start = m[4] + strings.Index(inner, target)
+				span.End = span.Start + len(target)
+				break
+			}
+		}
+	}
+	if target == "" || (!wiki && isURL(target)) {
+		return nil
+	}
+
+	file, anchor, _ := strings.Cut(target, "#")
+	var targets []string
+	switch {
+	case file == "":
+		missing, anchors := smp.missingAnchor(content, anchor)
+		if !missing {
+			return nil
+		}
+		for _, a := range anchors {
+			targets = append(targets, "#"+a)
+		}
+	default:
+		var path string
+		if wiki {
+			path = wikiPath(strings.TrimSpace(file), docPath)
+		} else {
+			name := file
+			if unescaped, err := url.PathUnescape(file); err == nil {
+				name = unescaped
+			}
+			path = peekPath(name, docPath)
+		}
+		if _, err := os.Stat(path); err != nil {
+			for _, note := range nearNotes(path) {
+				link := noteLinkTarget(note, docPath, wiki)
+				if anchor != "" {
+					link += "#" + anchor
+				}
+				targets = append(targets, link)
+			}
+			break
+		}
+		if anchor == "" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		doc := string(data)
+		if isOrgFile(path) {
+			doc = OrgToMarkdown(doc)
+		}
+		missing, anchors := smp.missingAnchor(doc, anchor)
+		if !missing {
+			return nil
+		}
+		for _, a := range anchors {
+			targets = append(targets, file+"#"+a)
+		}
+	}
+
+	var fixes []QuickFix
+	for _, link := range targets {
+		fixes = append(fixes, QuickFix{Source: "link", Title: "Link to " + link, Range: span, Text: link})
+	}
+	// The whole link, brackets and all, gives way to its text
+	for _, re := range []*regexp.Regexp{peekWikiRe, peekLinkRe} {
+		for _, m := range re.FindAllStringIndex(line, -1) {
+			if m[0] <= span.Start && span.End <= m[1] {
+				return append(fixes, QuickFix{Source: "link", Title: "Remove the link, keeping its text",
+					Range: TextRange{Start: m[0], End: m[1]}, Text: strings.TrimSpace(text)})
+			}
+		}
+	}
+	return fixes
+}
+
+// missingAnchor tells whether anchor names no heading of content, as a link
+// resolves it, and if so the anchors of the headings closest to it.
+func (smp *SharedMarkdownProcessor) missingAnchor(content, anchor string) (bool, []string) {
+	if anchor == "" {
+		return false, nil
+	}
+	headings := smp.Outline(content)
+	anchors := make([]string, len(headings))
+	for i, heading := range headings {
+		anchors[i] = helpAnchor(heading.Text)
+		if anchors[i] == strings.ToLower(anchor) {
+			return false, nil
+		}
+	}
+	if _, _, err := (Location{Heading: strings.ReplaceAll(anchor, "-", " ")}).resolve(smp, content); err == nil {
+		return false, nil
+	}
+	return true, closest(strings.ToLower(anchor), anchors, func(s string) string { return s })
+}
+
+// nearNotes are the notes below the working directory with the names
+// closest to that of path, which is not there.
+func nearNotes(path string) []string {
+	return closest(noteName(path), browserFiles(), noteName)
+}
+
+func noteName(path string) string {
+	return strings.ToLower(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+}
+
+// noteLinkTarget is how a link from the document at docPath names note: by
+// its name in a wiki link, or by its path from the document otherwise.
+func noteLinkTarget(note, docPath string, wiki bool) string {
+	if wiki {
+		return strings.TrimSuffix(filepath.Base(note), ".md")
+	}
+	rel := note
+	if dir, err := filepath.Abs(filepath.Dir(docPath)); err == nil {
+		if abs, err := filepath.Abs(note); err == nil {
+			if r, err := filepath.Rel(dir, abs); err == nil {
+				rel = r
+			}
+		}
+	}
+	return strings.ReplaceAll(filepath.ToSlash(rel), " ", "%20")
+}
+
+// closest picks the candidates whose names are near enough to target to be
+// what was meant, nearest first.
+func closest(target string, candidates []string, name func(string) string) []string {
+	type near struct {
+		candidate string
+		distance  int
+	}
+	limit := max(len([]rune(target))/3, 2)
+	var found []near
+	seen := map[string]bool{}
+	for _, candidate := range candidates {
+		if seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+		if d := editDistance(target, name(candidate)); d <= limit {
+			found = append(found, near{candidate, d})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].distance < found[j].distance })
+	var names []string
+	for _, n := range found[:min(len(found), quickFixSuggestions)] {
+		names = append(names, n.candidate)
+	}
+	return names
+}
+
+// editDistance is how many runes have to be inserted, removed or replaced
+// to turn a into b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		next := make([]int, len(rb)+1)
+		next[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			next[j] = min(prev[j]+1, next[j-1]+1, prev[j-1]+cost)
+		}
+		prev = next
+	}
+	return prev[len(rb)]
+}
+
+// openQuickFix offers the quick fixes for the issue under the cursor in a
+// menu next to it.
+func (m *model) openQuickFix() {
+	if m.mode == previewMode {
+		m.status = "Switch to the editor for quick fixes"
+		return
+	}
+	m.quickFixes = m.mdProcessor.QuickFixes(m.textarea.Value(), m.cursorOffset(), m.filename, m.linter)
+	if len(m.quickFixes) == 0 {
+		m.status = "No quick fixes here"
+		return
+	}
+	items := make([]pickerItem, len(m.quickFixes))
+	for i, fix := range m.quickFixes {
+		items[i] = pickerItem{title: fix.Title, detail: fix.Source, index: i}
+	}
+	m.overlay = overlayQuickFix
+	m.picker = newPicker("Quick Fix", items)
+}
+
+func (m *model) applyQuickFix(fix QuickFix) {
+	m.replaceRange(fix.Range, fix.Text)
+	m.status = fix.Title
+}
+
+// withQuickFix draws the quick fix menu over the editor, under the cursor
+// line or above it when there is no room below.
+func (m model) withQuickFix(content string) string {
+	width := min(max(m.mainWidth()/2, 48), lipgloss.Width(content))
+	box := m.picker.view(width, len(m.quickFixes)+6)
+	height := lipgloss.Height(box)
+
+	left, _ := m.sideWidths()
+	row, col := m.cursorCell()
+	x := min(left+col, max(lipgloss.Width(content)-lipgloss.Width(box), 0))
+	y := row + 1
+	if y+height > lipgloss.Height(content) {
+		y = max(row-height, 0)
+	}
+	return placeOver(content, box, x, y)
+}
+
+// cursorCell is where the editor cursor is drawn, counted from the top left
+// corner of the editor border. The textarea keeps how far it has scrolled
+// to itself, so the cursor is drawn as a marker to be found.
+func (m model) cursorCell() (int, int) {
+	ta := m.textarea
+	marker := func(string) string { return "\x00" }
+	ta.Cursor.Style = ta.Cursor.Style.Transform(marker)
+	ta.Cursor.TextStyle = ta.Cursor.TextStyle.Transform(marker)
+	top := 0
+	if m.twin != nil && m.twinFirst {
+		top = m.twin.Height() + 2
+	}
+	for row, line := range strings.Split(editorStyle.Render(ta.View()), "\n") {
+		if i := strings.IndexByte(line, 0); i >= 0 {
+			return top + row, ansi.StringWidth(line[:i])
+		}
+	}
+	return top, 0
+}
+
+// showQuickFix offers the quick fixes for the issue under the cursor in a
+// menu over the editor. While the scratch pad has the keyboard the same
+// shortcut moves it into the document instead.
+func (g *GUIApp) showQuickFix() {
+	if g.scratch != nil && g.window.Canvas().Focused() == g.scratch {
+		g.moveScratch()
+		return
+	}
+	content := g.editor.Text
+	fixes := g.mdProcessor.QuickFixes(content, runeOffset(content, g.editor.CursorRow, g.editor.CursorColumn), g.currentFile, g.linter)
+	if len(fixes) == 0 {
+		dialog.ShowInformation("Quick Fix", "There are no quick fixes for what is under the cursor.", g.window)
+		return
+	}
+	items := make([]*fyne.MenuItem, len(fixes))
+	for i, fix := range fixes {
+		items[i] = fyne.NewMenuItem(fmt.Sprintf("%s (%s)", fix.Title, fix.Source), func() { g.applyQuickFix(fix) })
+	}
+	widget.ShowPopUpMenuAtRelativePosition(fyne.NewMenu("", items...), g.window.Canvas(),
+		fyne.NewPos(theme.Padding()*4, theme.Padding()*4), g.editorPane)
+}
+
+func (g *GUIApp) applyQuickFix(fix QuickFix) {
+	g.editor.SetText(fix.Apply(g.editor.Text))
+	cursor := fix.Range.Start + len(fix.Text)
+	g.selectRange(TextRange{Start: cursor, End: cursor})
+	g.window.Canvas().Focus(g.editor)
+}
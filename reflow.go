@@ -0,0 +1,191 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	reflowHeadingRe = regexp.MustCompile(`^\s{0,3}#{1,6}(\s|$)`)
+	reflowRuleRe    = regexp.MustCompile(`^\s{0,3}(=+|-+|(\*\s*){3,}|(_\s*){3,})\s*$`)
+	reflowBlockRe   = regexp.MustCompile(`^\s*(>|\||<|\[\^?[^\]]+\]:)`)
+)
+
+// sentenceAbbreviations end in a period without ending the sentence.
+var sentenceAbbreviations = map[string]bool{
+	"e.g": true, "i.e": true, "vs": true, "cf": true, "mr": true, "mrs": true,
+	"ms": true, "dr": true, "prof": true, "st": true, "fig": true, "no": true,
+}
+
+// proseLines marks the lines that belong to an ordinary paragraph. Code,
+// lists, headings, quotes, tables, HTML and link definitions are left
+// alone, and so is the text line of a setext heading.
+func proseLines(lines []string) []bool {
+	inCode := codeBlockLines(lines)
+	prose := make([]bool, len(lines))
+	for i, line := range lines {
+		switch {
+		case inCode[i], strings.TrimSpace(line) == "":
+		case indentWidth(line) >= 4 && (i == 0 || strings.TrimSpace(lines[i-1]) == ""):
+			// Indented code block
+		case indentWidth(line) >= 2, sortListItemRe.MatchString(line):
+			// List items and their continuation lines
+		case reflowHeadingRe.MatchString(line), reflowRuleRe.MatchString(line), reflowBlockRe.MatchString(line):
+		case i+1 < len(lines) && reflowRuleRe.MatchString(lines[i+1]) && !strings.Contains(lines[i+1], "*") && !strings.Contains(lines[i+1], "_"):
+			// Setext heading text
+		default:
+			prose[i] = true
+		}
+	}
+	return prose
+}
+
+func hardBreak(line string) bool {
+	return strings.HasSuffix(line, "  ") || strings.HasSuffix(line, "\\")
+}
+
+// paragraphRuns calls fn with every run of prose lines between first and
+// last that makes up one paragraph, splitting runs at hard line breaks, and
+// returns content with each run replaced by what fn returns.
+func paragraphRuns(content string, first, last int, fn func(run []string) []string) string {
+	lines := strings.Split(content, "\n")
+	first = max(first, 0)
+	last = min(last, len(lines)-1)
+	prose := proseLines(lines)
+
+	var result []string
+	result = append(result, lines[:first]...)
+	for i := first; i <= last; {
+		if !prose[i] {
+			result = append(result, lines[i])
+			i++
+			continue
+		}
+		end := i
+		for end < last && prose[end+1] && !hardBreak(lines[end]) {
+			end++
+		}
+		result = append(result, fn(lines[i:end+1])...)
+		i = end + 1
+	}
+	result = append(result, lines[last+1:]...)
+	return strings.Join(result, "\n")
+}
+
+func joinRun(run []string) string {
+	parts := make([]string, len(run))
+	for i, line := range run {
+		parts[i] = strings.TrimSpace(line)
+		if i == 0 {
+			parts[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+		}
+	}
+	joined := strings.Join(parts, " ")
+	// A trailing hard break belongs to the end of the paragraph
+	if hardBreak(run[len(run)-1]) && !hardBreak(joined) {
+		joined += "  "
+	}
+	return joined
+}
+
+// JoinLines merges the hard-wrapped lines of every paragraph between first
+// and last into a single line.
+func JoinLines(content string, first, last int) string {
+	return paragraphRuns(content, first, last, func(run []string) []string {
+		return []string{joinRun(run)}
+	})
+}
+
+// SplitSentences rewraps every paragraph between first and last so that
+// each sentence is on a line of its own.
+func SplitSentences(content string, first, last int) string {
+	return paragraphRuns(content, first, last, func(run []string) []string {
+		joined := joinRun(run)
+		indent := joined[:len(joined)-len(strings.TrimLeft(joined, " "))]
+		var lines []string
+		for i, sentence := range splitSentences(strings.TrimLeft(joined, " ")) {
+			if i == 0 {
+				sentence = indent + sentence
+			}
+			lines = append(lines, sentence)
+		}
+		return lines
+	})
+}
+
+// splitSentences breaks text after ., ! or ? followed by whitespace and the
+// start of a new sentence. Periods in code spans, after known abbreviations
+// and after single-letter initials do not count.
+func splitSentences(text string) []string {
+	var sentences []string
+	for _, bounds := range sentenceBounds(text) {
+		sentences = append(sentences, text[bounds[0]:bounds[1]])
+	}
+	return sentences
+}
+
+// sentenceBounds returns the byte offsets of every sentence of text, without
+// the whitespace between them.
+func sentenceBounds(text string) [][2]int {
+	var bounds [][2]int
+	start := 0
+	inCode := false
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		if r == '`' {
+			inCode = !inCode
+			continue
+		}
+		if inCode || (r != '.' && r != '!' && r != '?') {
+			continue
+		}
+
+		end := i
+		for end < len(text) {
+			c, size := utf8.DecodeRuneInString(text[end:])
+			if !strings.ContainsRune(`)]"'”’*_`, c) {
+				break
+			}
+			end += size
+		}
+		next := end + len(text[end:]) - len(strings.TrimLeftFunc(text[end:], unicode.IsSpace))
+		if next == end || next == len(text) {
+			continue
+		}
+		if c, _ := utf8.DecodeRuneInString(text[next:]); !unicode.IsUpper(c) && !unicode.IsDigit(c) && !strings.ContainsRune(`"'“‘([*_`+"`", c) {
+			continue
+		}
+		if r == '.' {
+			word := text[start : i-1]
+			if space := strings.LastIndexFunc(word, unicode.IsSpace); space >= 0 {
+				_, size := utf8.DecodeRuneInString(word[space:])
+				word = word[space+size:]
+			}
+			word = strings.TrimLeft(word, `("'“‘*_[`)
+			if utf8.RuneCountInString(word) == 1 && unicode.IsLetter([]rune(word)[0]) || sentenceAbbreviations[strings.ToLower(word)] {
+				continue
+			}
+		}
+
+		bounds = append(bounds, [2]int{start, start + len(strings.TrimRightFunc(text[start:end], unicode.IsSpace))})
+		start = next
+		i = next
+	}
+	if trimmed := strings.TrimRightFunc(text[start:], unicode.IsSpace); trimmed != "" {
+		bounds = append(bounds, [2]int{start, start + len(trimmed)})
+	}
+	return bounds
+}
+
+func joinParagraph(content string, row int) (string, int) {
+	first, last := ParagraphAt(content, row)
+	return JoinLines(content, first, last), first
+}
+
+func splitParagraph(content string, row int) (string, int) {
+	first, last := ParagraphAt(content, row)
+	return SplitSentences(content, first, last), first
+}
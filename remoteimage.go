@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// remoteImageTimeout bounds the download of an image for the GUI preview.
+const remoteImageTimeout = 20 * time.Second
+
+// maxRemoteImageSize is the largest image the GUI preview downloads.
+const maxRemoteImageSize = 20 << 20
+
+// remoteImage is an image from the web that the GUI preview shows from a
+// downloaded copy. Until done it is still loading.
+type remoteImage struct {
+	path string
+	err  error
+	done bool
+}
+
+// remoteImages are the images downloaded in this session, by URL. Each one is
+// downloaded once per session and kept in the cache directory.
+var remoteImages = struct {
+	sync.Mutex
+	byURL map[string]*remoteImage
+}{byURL: map[string]*remoteImage{}}
+
+// fetchRemoteImage returns the image at src once it is downloaded, and
+// otherwise starts downloading it in the background and calls loaded when
+// that is over, whether it worked or not.
+func fetchRemoteImage(src string, loaded func()) remoteImage {
+	remoteImages.Lock()
+	defer remoteImages.Unlock()
+	if image, ok := remoteImages.byURL[src]; ok {
+		return *image
+	}
+	image := &remoteImage{}
+	remoteImages.byURL[src] = image
+	go func() {
+		path, err := downloadImage(src)
+		remoteImages.Lock()
+		image.path, image.err, image.done = path, err, true
+		remoteImages.Unlock()
+		loaded()
+	}()
+	return *image
+}
+
+func downloadImage(src string) (string, error) {
+	client := &http.Client{Timeout: remoteImageTimeout}
+	resp, err := client.Get(src)
+	if err != nil {
+		return "", fmt.Errorf("error downloading image: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error downloading image: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteImageSize+1))
+	if err != nil {
+		return "", fmt.Errorf("error downloading image: %v", err)
+	}
+	if len(data) > maxRemoteImageSize {
+		return "", fmt.Errorf("error downloading image: larger than %s", formatBytes(maxRemoteImageSize))
+	}
+
+	// The extension tells SVG images apart
+	sum := sha256.Sum256([]byte(src))
+	name := hex.EncodeToString(sum[:8])
+	if u, err := url.Parse(src); err == nil {
+		name += path.Ext(u.Path)
+	}
+	file := filepath.Join(cacheDir(), "images", name)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", fmt.Errorf("error saving image: %v", err)
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return "", fmt.Errorf("error saving image: %v", err)
+	}
+	return file, nil
+}
+
+// remoteImageSegments shows the images of segments that come from the web
+// from their downloaded copies, so that rendering never waits for the
+// network. An image still loading is a placeholder until loaded is called;
+// pending tells whether there is one.
+func remoteImageSegments(segments []widget.RichTextSegment, loaded func()) (out []widget.RichTextSegment, pending bool) {
+	for _, segment := range segments {
+		switch seg := segment.(type) {
+		case *widget.ImageSegment:
+			if scheme := seg.Source.Scheme(); scheme != "http" && scheme != "https" {
+				break
+			}
+			image := fetchRemoteImage(seg.Source.String(), loaded)
+			switch {
+			case !image.done:
+				pending = true
+				segment = imagePlaceholder("Loading " + seg.Source.Name() + " ...")
+			case image.err != nil:
+				segment = imagePlaceholder("[image unavailable: " + seg.Source.Name() + "]")
+			default:
+				seg.Source = storage.NewFileURI(image.path)
+			}
+		case *widget.ListSegment:
+			for i, item := range seg.Items {
+				items, itemPending := remoteImageSegments([]widget.RichTextSegment{item}, loaded)
+				if len(items) == 1 {
+					seg.Items[i] = items[0]
+				}
+				pending = pending || itemPending
+			}
+		case *widget.ParagraphSegment:
+			var textsPending bool
+			seg.Texts, textsPending = remoteImageSegments(seg.Texts, loaded)
+			pending = pending || textsPending
+		}
+		out = append(out, segment)
+	}
+	return out, pending
+}
+
+func imagePlaceholder(text string) *widget.TextSegment {
+	style := widget.RichTextStyleParagraph
+	style.TextStyle.Italic = true
+	style.ColorName = theme.ColorNamePlaceHolder
+	return &widget.TextSegment{Style: style, Text: text}
+}
@@ -0,0 +1,772 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2/widget"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+var (
+	termH1Style = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("#FF0000")).
+			Background(lipgloss.Color("#2A0A0A")).
+			Padding(0, 2)
+
+	termH2Style = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("#00FFFF"))
+
+	termH3Style = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("#FFFF00"))
+
+	termH4Style = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("#96CEB4"))
+
+	termParagraphStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#E6E6E6"))
+
+	termListStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFEAA7"))
+
+	termQuoteStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#888888")).
+			Italic(true)
+
+	termQuoteBorderStyle = lipgloss.NewStyle().
+				BorderLeft(true).
+				BorderStyle(lipgloss.ThickBorder()).
+				BorderForeground(lipgloss.Color("#666666")).
+				PaddingLeft(2)
+
+	termCodeHeaderStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("#00FF00")).
+				Background(lipgloss.Color("#1a1a1a")).
+				Padding(0, 1)
+
+	termCodeBlockStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#00FF41")).
+				Background(lipgloss.Color("#1a1a1a")).
+				Border(lipgloss.RoundedBorder()).
+				BorderForeground(lipgloss.Color("#555555")).
+				Padding(1, 2)
+
+	termInlineCodeStyle = lipgloss.NewStyle().
+				Background(lipgloss.Color("#333333")).
+				Foreground(lipgloss.Color("#00FF00"))
+
+	termLinkColor   = lipgloss.Color("#5FAFFF")
+	termMutedColor  = lipgloss.Color("#777777")
+	termStrongColor = lipgloss.Color("#FFFFFF")
+	termEmColor     = lipgloss.Color("#DDDDDD")
+	termBorderColor = lipgloss.Color("#555555")
+)
+
+type terminalRenderer struct {
+	smp     *SharedMarkdownProcessor
+	source  []byte
+	line    func(offset int) int
+	anchors ScrollMap
+	keys    map[ast.Node]string
+}
+
+// TerminalPreview is a rendered terminal preview with the positions needed
+// to interact with it.
+type TerminalPreview struct {
+	Text string
+	// TaskLines are the lines of the task list checkboxes, in the order of
+	// TaskItems
+	TaskLines []int
+	// FieldLines are the lines of the form fields, in the order of
+	// FormFields
+	FieldLines []int
+	Scroll     ScrollMap
+}
+
+// RenderTerminal walks the markdown AST and produces lipgloss-styled output
+// wrapped to the given terminal width.
+func (smp *SharedMarkdownProcessor) RenderTerminal(content string, width int) string {
+	return smp.RenderTerminalPreview(content, width).Text
+}
+
+// RenderTerminalPreview is RenderTerminal that also finds the task list
+// checkboxes and maps the source lines to the output lines.
+func (smp *SharedMarkdownProcessor) RenderTerminalPreview(content string, width int) TerminalPreview {
+	availableWidth := width - 8
+	if availableWidth < 40 {
+		availableWidth = 40
+	}
+
+	hash := sha256.Sum256([]byte(smp.Flavor + "\x00" + content))
+	if preview, ok := smp.previewCache.get(hash, availableWidth); ok {
+		return preview
+	}
+
+	doc, source := smp.Parse(content)
+	r := &terminalRenderer{smp: smp, source: source, line: lineIndex(source)}
+	r.keys = blockKeys(doc, source, fmt.Sprintf("%s %d\x00", smp.Flavor, availableWidth))
+	smp.terminalCache.next()
+	lines := r.blocks(doc, availableWidth, termParagraphStyle, false)
+	if fm := ParseFrontMatter(content); fm != nil {
+		if header := fm.terminalHeader(availableWidth); header != nil {
+			lines = append(append(header, ""), lines...)
+			for i := range r.anchors {
+				r.anchors[i].Rendered += len(header) + 1
+			}
+		}
+	}
+
+	preview := TerminalPreview{
+		Scroll: append(append(ScrollMap{{}}, r.anchors...), ScrollAnchor{Source: sourceLineCount(content), Rendered: len(lines)}),
+	}
+	preview.Text, preview.TaskLines = terminalTasks(strings.Join(lines, "\n"))
+	preview.Text, preview.FieldLines = terminalFields(preview.Text)
+	smp.previewCache.put(hash, availableWidth, preview)
+	return preview
+}
+
+// blocks renders the children of parent, separating them with blank lines
+// unless tight is set (as inside list items).
+func (r *terminalRenderer) blocks(parent ast.Node, width int, base lipgloss.Style, tight bool) []string {
+	var lines []string
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		rendered := r.cachedBlock(n, width, base)
+		if len(rendered) == 0 {
+			continue
+		}
+		if len(lines) > 0 && !tight {
+			lines = append(lines, "")
+		}
+		if parent.Kind() == ast.KindDocument {
+			if line, ok := blockLine(n, r.line); ok {
+				r.anchors = append(r.anchors, ScrollAnchor{Source: line, Rendered: len(lines)})
+			}
+		}
+		lines = append(lines, rendered...)
+	}
+	return lines
+}
+
+// cachedBlock is block, taken from the cache for the top level blocks whose
+// source has not changed since the last render.
+func (r *terminalRenderer) cachedBlock(n ast.Node, width int, base lipgloss.Style) []string {
+	key, ok := r.keys[n]
+	if !ok {
+		return r.block(n, width, base)
+	}
+	if lines, ok := r.smp.terminalCache.get(key); ok {
+		return lines.([]string)
+	}
+	lines := r.block(n, width, base)
+	r.smp.terminalCache.put(key, lines)
+	return lines
+}
+
+func (r *terminalRenderer) block(n ast.Node, width int, base lipgloss.Style) []string {
+	switch node := n.(type) {
+	case *ast.Heading:
+		return r.heading(node)
+
+	case *ast.Paragraph, *ast.TextBlock:
+		return strings.Split(ansi.Wrap(r.inline(node, base), width, ""), "\n")
+
+	case *ast.List:
+		return r.list(node, width, 0)
+
+	case *ast.FencedCodeBlock:
+		info, code := fenceInfo(node, r.source), r.smp.CodeBlockText(node, r.source)
+		switch lang := fenceLanguage(info); lang {
+		case "qrcode":
+			return r.qrBlock(code, info, width)
+		case "chart":
+			return r.chartBlock(code, info, width)
+		case "calendar":
+			return r.calendarBlock(code, info, width)
+		default:
+			if native, ok := ganttSyntax(lang, code); ok {
+				return r.ganttBlock(code, info, native, width)
+			}
+		}
+		return r.codeBlock(code, info, width)
+
+	case *ast.CodeBlock:
+		return r.codeBlock(r.smp.CodeBlockText(node, r.source), "", width)
+
+	case *ast.Blockquote:
+		inner := r.blocks(node, width-4, termQuoteStyle, false)
+		if len(inner) == 0 {
+			return nil
+		}
+		if termGlyphs.Quote != "" {
+			inner[0] = termQuoteStyle.Render(termGlyphs.Quote) + inner[0]
+		}
+		return strings.Split(termQuoteBorderStyle.Render(strings.Join(inner, "\n")), "\n")
+
+	case *ast.ThematicBreak:
+		return []string{lipgloss.NewStyle().Foreground(termMutedColor).Render(strings.Repeat("─", width))}
+
+	case *ast.HTMLBlock:
+		var lines []string
+		for i := 0; i < node.Lines().Len(); i++ {
+			segment := node.Lines().At(i)
+			line := strings.TrimRight(string(segment.Value(r.source)), "\n")
+			lines = append(lines, lipgloss.NewStyle().Foreground(termMutedColor).Render(line))
+		}
+		return lines
+
+	case *east.Table:
+		return r.table(node, width)
+	}
+	return nil
+}
+
+func (r *terminalRenderer) heading(node *ast.Heading) []string {
+	content := r.smp.PlainText(node, r.source)
+	if content == "" {
+		return nil
+	}
+
+	mark := termGlyphs.heading(node.Level)
+	switch node.Level {
+	case 1:
+		return []string{termH1Style.Render(mark + strings.ToUpper(content) + termGlyphs.TitleEnd)}
+	case 2:
+		underline := lipgloss.NewStyle().
+			Foreground(termH2Style.GetForeground()).
+			Render(strings.Repeat("═", lipgloss.Width(mark+content)))
+		return []string{termH2Style.Render(mark + content), underline}
+	case 3:
+		return []string{termH3Style.Render(mark + content)}
+	default:
+		return []string{termH4Style.Render(mark + content)}
+	}
+}
+
+func (r *terminalRenderer) list(node *ast.List, width int, depth int) []string {
+	indent := strings.Repeat("  ", depth)
+	number := node.Start
+
+	var lines []string
+	for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+		marker := termGlyphs.bullet(depth)
+		if node.IsOrdered() {
+			marker = fmt.Sprintf("%d.", number)
+			number++
+		}
+		prefix := indent + marker + " "
+		hanging := strings.Repeat(" ", lipgloss.Width(prefix))
+		contentWidth := width - lipgloss.Width(prefix)
+
+		first := true
+		for child := item.FirstChild(); child != nil; child = child.NextSibling() {
+			if sublist, ok := child.(*ast.List); ok {
+				lines = append(lines, r.list(sublist, width, depth+1)...)
+				continue
+			}
+			for _, line := range r.block(child, contentWidth, termListStyle) {
+				if first {
+					lines = append(lines, termListStyle.Render(prefix)+line)
+					first = false
+				} else {
+					lines = append(lines, hanging+line)
+				}
+			}
+		}
+		if first {
+			lines = append(lines, termListStyle.Render(prefix))
+		}
+	}
+	return lines
+}
+
+// codeBlock draws a code block with the language and highlighted lines of
+// its fence info string. Long lines wrap behind the gutter of the line they
+// belong to.
+func (r *terminalRenderer) codeBlock(code, info string, width int) []string {
+	lang := fenceLanguage(info)
+	codeHeader := "Code"
+	if lang != "" {
+		codeHeader = strings.ToUpper(lang)
+	}
+
+	header := termCodeHeaderStyle.Render("┌─ " + codeHeader + " ─┐")
+	highlighted := fenceHighlights(info)
+	codeLines := strings.Split(code, "\n")
+	if !r.smp.Code.LineNumbers && len(highlighted) == 0 {
+		wrapped := wrapCodeBlock(codeLines, width-6)
+		if tokens, ok := r.smp.Highlight(wrapped, lang); ok {
+			wrapped = highlightTerminal(tokens, r.smp.codeScheme(lang))
+		}
+		body := termCodeBlockStyle.Render(wrapped)
+		return append([]string{header}, strings.Split(body, "\n")...)
+	}
+
+	// The gutter goes on after highlighting, which has to see the code alone
+	gutterWidth := ansi.StringWidth(r.smp.codeGutter(0, len(codeLines), false))
+	var wrappedLines []string
+	var gutters []string
+	for i, line := range codeLines {
+		for j, part := range strings.Split(wrapCodeBlock([]string{line}, width-6-gutterWidth), "\n") {
+			gutter := strings.Repeat(" ", gutterWidth)
+			if j == 0 {
+				gutter = r.smp.codeGutter(i, len(codeLines), highlighted[i])
+			}
+			style := lipgloss.NewStyle().Foreground(termMutedColor).Background(termCodeBlockStyle.GetBackground())
+			if highlighted[i] {
+				style = style.Foreground(termStrongColor).Bold(true)
+			}
+			wrappedLines = append(wrappedLines, part)
+			gutters = append(gutters, style.Render(gutter))
+		}
+	}
+	wrapped := strings.Join(wrappedLines, "\n")
+	if tokens, ok := r.smp.Highlight(wrapped, lang); ok {
+		wrapped = highlightTerminal(tokens, r.smp.codeScheme(lang))
+	} else {
+		plain := lipgloss.NewStyle().Foreground(termCodeBlockStyle.GetForeground()).Background(termCodeBlockStyle.GetBackground())
+		for i, line := range wrappedLines {
+			wrappedLines[i] = plain.Render(line)
+		}
+		wrapped = strings.Join(wrappedLines, "\n")
+	}
+	lines := strings.Split(wrapped, "\n")
+	for i := range lines {
+		if i < len(gutters) {
+			lines[i] = gutters[i] + lines[i]
+		}
+	}
+	body := termCodeBlockStyle.Render(strings.Join(lines, "\n"))
+	return append([]string{header}, strings.Split(body, "\n")...)
+}
+
+func (r *terminalRenderer) table(node *east.Table, width int) []string {
+	var headers []string
+	var rows [][]string
+	for row := node.FirstChild(); row != nil; row = row.NextSibling() {
+		var cells []string
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			cells = append(cells, r.inline(cell, termParagraphStyle))
+		}
+		if _, ok := row.(*east.TableHeader); ok {
+			headers = cells
+		} else {
+			rows = append(rows, cells)
+		}
+	}
+
+	t := table.New().
+		Border(lipgloss.RoundedBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(termBorderColor)).
+		Headers(headers...).
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			style := lipgloss.NewStyle().Padding(0, 1)
+			if col < len(node.Alignments) {
+				switch node.Alignments[col] {
+				case east.AlignCenter:
+					style = style.Align(lipgloss.Center)
+				case east.AlignRight:
+					style = style.Align(lipgloss.Right)
+				}
+			}
+			if row == table.HeaderRow {
+				style = style.Bold(true).Foreground(termH2Style.GetForeground())
+			}
+			return style
+		})
+
+	rendered := t.Render()
+	if lipgloss.Width(rendered) > width {
+		rendered = t.Width(width).Render()
+	}
+	return strings.Split(rendered, "\n")
+}
+
+func (r *terminalRenderer) inline(n ast.Node, style lipgloss.Style) string {
+	var buf strings.Builder
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		r.inlineNode(child, style, &buf)
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+func (r *terminalRenderer) inlineNode(n ast.Node, style lipgloss.Style, buf *strings.Builder) {
+	switch node := n.(type) {
+	case *ast.Text:
+		buf.WriteString(style.Render(textValue(node, r.source)))
+		if node.HardLineBreak() || node.SoftLineBreak() {
+			buf.WriteString("\n")
+		}
+	case *ast.String:
+		buf.WriteString(style.Render(string(node.Value)))
+	case *ast.CodeSpan:
+		buf.WriteString(termInlineCodeStyle.Render("`" + r.smp.PlainText(node, r.source) + "`"))
+	case *ast.Emphasis:
+		if node.Level >= 2 {
+			style = style.Bold(true).Foreground(termStrongColor)
+		} else {
+			style = style.Italic(true).Foreground(termEmColor)
+		}
+		for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+			r.inlineNode(child, style, buf)
+		}
+	case *east.Strikethrough:
+		style = style.Strikethrough(true)
+		for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+			r.inlineNode(child, style, buf)
+		}
+	case *ast.Link:
+		linkStyle := style.Underline(true).Foreground(termLinkColor)
+		for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+			r.inlineNode(child, linkStyle, buf)
+		}
+		if url := string(node.Destination); url != r.smp.PlainText(node, r.source) {
+			buf.WriteString(lipgloss.NewStyle().Foreground(termMutedColor).Render(" (" + url + ")"))
+		}
+	case *ast.AutoLink:
+		buf.WriteString(style.Underline(true).Foreground(termLinkColor).Render(string(node.URL(r.source))))
+	case *ast.Image:
+		alt := r.smp.PlainText(node, r.source)
+		if alt == "" {
+			alt = string(node.Destination)
+		}
+		buf.WriteString(style.Italic(true).Foreground(termMutedColor).Render("[image: " + alt + "]"))
+	case *east.TaskCheckBox:
+		if node.IsChecked {
+			buf.WriteString(style.Render(string(taskChecked) + " "))
+		} else {
+			buf.WriteString(style.Render(string(taskUnchecked) + " "))
+		}
+	case *ast.RawHTML:
+		// Inline HTML has no terminal equivalent
+	default:
+		for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+			r.inlineNode(child, style, buf)
+		}
+	}
+}
+
+func wrapCodeBlock(codeLines []string, maxWidth int) string {
+	if maxWidth <= 20 {
+		return strings.Join(codeLines, "\n")
+	}
+
+	var wrappedLines []string
+
+	for _, line := range codeLines {
+		if len(line) <= maxWidth {
+			wrappedLines = append(wrappedLines, line)
+		} else {
+			wrapped := wrapCodeLine(line, maxWidth)
+			wrappedLines = append(wrappedLines, wrapped...)
+		}
+	}
+
+	return strings.Join(wrappedLines, "\n")
+}
+
+func wrapCodeLine(line string, maxWidth int) []string {
+	if len(line) <= maxWidth {
+		return []string{line}
+	}
+
+	leadingSpaces := 0
+	for _, char := range line {
+		if char == ' ' || char == '\t' {
+			if char == '\t' {
+				leadingSpaces += 4
+			} else {
+				leadingSpaces++
+			}
+		} else {
+			break
+		}
+	}
+
+	contIndent := strings.Repeat(" ", leadingSpaces+2)
+
+	var lines []string
+	remaining := line
+
+	for len(remaining) > maxWidth {
+		breakPoint := findCodeBreakPoint(remaining, maxWidth)
+
+		if breakPoint <= leadingSpaces {
+			breakPoint = maxWidth - 3 // Leave room for "..."
+			lines = append(lines, remaining[:breakPoint]+"...")
+			remaining = contIndent + "..." + remaining[breakPoint:]
+		} else {
+			lines = append(lines, remaining[:breakPoint])
+			remaining = contIndent + strings.TrimLeft(remaining[breakPoint:], " ")
+		}
+
+		maxWidth = maxWidth - len(contIndent)
+		if maxWidth < 20 {
+			lines = append(lines, remaining)
+			break
+		}
+	}
+
+	if remaining != "" {
+		lines = append(lines, remaining)
+	}
+
+	return lines
+}
+
+func findCodeBreakPoint(line string, maxWidth int) int {
+	if maxWidth >= len(line) {
+		return len(line)
+	}
+
+	breakChars := []string{" ", ",", ";", ".", ")", "}", "]", ">", "|", "&", "+", "-", "="}
+
+	for i := maxWidth - 1; i > maxWidth/2; i-- {
+		if i >= len(line) {
+			continue
+		}
+
+		char := string(line[i])
+		for _, breakChar := range breakChars {
+			if char == breakChar {
+				if breakChar == " " {
+					return i // Break before space
+				} else {
+					return i + 1 // Break after punctuation
+				}
+			}
+		}
+	}
+	return maxWidth / 2
+}
+
+// fyneLiteralBase starts the private use runes that stand in for the marks
+// in text Fyne would otherwise take for markdown again, such as a `*` that
+// was written `\*`. Fyne shows text as written, escapes and all, so they
+// are put back once it has parsed the markdown.
+const fyneLiteralBase = '\ue100'
+
+const fyneLiteralMarks = "\\`*_[]<>&#!~|-+="
+
+// fyneLiteral hides the markdown marks of text from Fyne.
+func fyneLiteral(text string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(fyneLiteralMarks, r) {
+			return fyneLiteralBase + r
+		}
+		return r
+	}, text)
+}
+
+// restoreFyneLiterals puts back the marks fyneLiteral hid in segments.
+func restoreFyneLiterals(segments []widget.RichTextSegment) {
+	restore := func(text string) string {
+		return strings.Map(func(r rune) rune {
+			if r > fyneLiteralBase && r < fyneLiteralBase+128 {
+				return r - fyneLiteralBase
+			}
+			return r
+		}, text)
+	}
+	for _, segment := range segments {
+		switch seg := segment.(type) {
+		case *widget.TextSegment:
+			seg.Text = restore(seg.Text)
+		case *widget.HyperlinkSegment:
+			seg.Text = restore(seg.Text)
+		case *widget.ListSegment:
+			restoreFyneLiterals(seg.Items)
+		case *widget.ParagraphSegment:
+			restoreFyneLiterals(seg.Texts)
+		}
+	}
+}
+
+type fyneMarkdownWriter struct {
+	smp    *SharedMarkdownProcessor
+	source []byte
+	lines  []string
+	blocks []fyneCodeBlock
+}
+
+// fyneBlock is the markdown of one top level block, the source line it
+// starts on (or -1 when that is not known) and its code blocks.
+type fyneBlock struct {
+	line     int
+	markdown string
+	code     []fyneCodeBlock
+}
+
+type fyneCodeBlock struct {
+	lang        string
+	code        string
+	highlighted map[int]bool
+	table       bool
+	used        bool
+}
+
+// RenderFyneMarkdown re-serialises the document into the markdown subset
+// Fyne's RichText understands, replacing GFM-only constructs (tables, task
+// lists, strikethrough) with equivalents it can display. The code blocks are
+// returned alongside so they can be highlighted after parsing.
+func (smp *SharedMarkdownProcessor) RenderFyneMarkdown(content string) (string, []fyneCodeBlock) {
+	blocks, code := smp.renderFyneBlocks(content)
+	var lines []string
+	for _, block := range blocks {
+		lines = append(lines, block.markdown, "")
+	}
+	return strings.TrimSpace(strings.Join(collapseBlankLines(strings.Split(strings.Join(lines, "\n"), "\n")), "\n")), code
+}
+
+// renderFyneBlocks is RenderFyneMarkdown a top level block at a time.
+func (smp *SharedMarkdownProcessor) renderFyneBlocks(content string) ([]fyneBlock, []fyneCodeBlock) {
+	doc, source := smp.Parse(content)
+	line := lineIndex(source)
+	w := &fyneMarkdownWriter{smp: smp, source: source}
+	var blocks []fyneBlock
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		w.lines = nil
+		code := len(w.blocks)
+		w.block(n, "")
+		if len(w.lines) == 0 {
+			continue
+		}
+		block := fyneBlock{line: -1, markdown: strings.Join(w.lines, "\n"), code: w.blocks[code:]}
+		if l, ok := blockLine(n, line); ok {
+			block.line = l
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, w.blocks
+}
+
+func (w *fyneMarkdownWriter) block(n ast.Node, indent string) {
+	switch node := n.(type) {
+	case *ast.Heading:
+		w.lines = append(w.lines, indent+strings.Repeat("#", node.Level)+" "+fyneLiteral(w.smp.PlainText(node, w.source)))
+
+	case *ast.Paragraph, *ast.TextBlock:
+		for _, line := range strings.Split(w.inline(node), "\n") {
+			w.lines = append(w.lines, indent+line)
+		}
+
+	case *ast.List:
+		number := node.Start
+		for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+			marker := "- "
+			if node.IsOrdered() {
+				marker = fmt.Sprintf("%d. ", number)
+				number++
+			}
+			start := len(w.lines)
+			childIndent := indent + strings.Repeat(" ", len(marker))
+			for child := item.FirstChild(); child != nil; child = child.NextSibling() {
+				w.block(child, childIndent)
+			}
+			if start < len(w.lines) {
+				w.lines[start] = indent + marker + strings.TrimPrefix(w.lines[start], childIndent)
+			} else {
+				w.lines = append(w.lines, indent+marker)
+			}
+		}
+
+	case *ast.FencedCodeBlock:
+		w.fence(fenceInfo(node, w.source), w.smp.CodeBlockText(node, w.source), indent)
+
+	case *ast.CodeBlock:
+		w.fence("", w.smp.CodeBlockText(node, w.source), indent)
+
+	case *ast.Blockquote:
+		for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+			w.block(child, indent+"> ")
+		}
+
+	case *ast.ThematicBreak:
+		w.lines = append(w.lines, indent+"---")
+
+	case *east.Table:
+		var rows [][]string
+		for row := node.FirstChild(); row != nil; row = row.NextSibling() {
+			var cells []string
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, w.smp.PlainText(cell, w.source))
+			}
+			rows = append(rows, cells)
+		}
+		w.fence("", strings.Join(formatTextTable(rows), "\n"), indent)
+		w.blocks[len(w.blocks)-1].table = true
+	}
+}
+
+// fence writes a code block. The whole info string goes on the fence, so
+// that the block is parsed again when its highlighted lines change.
+func (w *fyneMarkdownWriter) fence(info, code, indent string) {
+	w.blocks = append(w.blocks, fyneCodeBlock{lang: fenceLanguage(info), code: code, highlighted: fenceHighlights(info)})
+	w.lines = append(w.lines, indent+"```"+info)
+	for _, line := range strings.Split(code, "\n") {
+		w.lines = append(w.lines, indent+line)
+	}
+	w.lines = append(w.lines, indent+"```")
+}
+
+func (w *fyneMarkdownWriter) inline(n ast.Node) string {
+	var buf strings.Builder
+	ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch node := child.(type) {
+		case *ast.Emphasis:
+			buf.WriteString(strings.Repeat("*", node.Level))
+		case *ast.CodeSpan:
+			if entering {
+				buf.WriteString("`" + w.smp.PlainText(node, w.source) + "`")
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.Link:
+			if entering {
+				fmt.Fprintf(&buf, "[%s](%s)", fyneLiteral(w.smp.PlainText(node, w.source)), node.Destination)
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.AutoLink:
+			if entering {
+				url := string(node.URL(w.source))
+				fmt.Fprintf(&buf, "[%s](%s)", url, url)
+			}
+		case *ast.Image:
+			if entering {
+				buf.WriteString("*[image: " + fyneLiteral(w.smp.PlainText(node, w.source)) + "]*")
+			}
+			return ast.WalkSkipChildren, nil
+		case *east.TaskCheckBox:
+			if entering {
+				if node.IsChecked {
+					buf.WriteRune(taskChecked)
+				} else {
+					buf.WriteRune(taskUnchecked)
+				}
+				buf.WriteString(" ")
+			}
+		case *ast.Text:
+			if entering {
+				buf.WriteString(fyneLiteral(textValue(node, w.source)))
+				if node.HardLineBreak() || node.SoftLineBreak() {
+					buf.WriteString("\n")
+				}
+			}
+		case *ast.String:
+			if entering {
+				buf.Write(node.Value)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(buf.String())
+}
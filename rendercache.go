@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/sha256"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// linkRefDefRe finds link reference definitions. Blocks that use them render
+// differently when they change, so they are part of every cache key.
+var linkRefDefRe = regexp.MustCompile(`(?m)^ {0,3}\[[^\]]+\]:.*$`)
+
+// renderCache keeps the renderings of top level blocks from one render of a
+// document to the next, so that typing in a long document only renders the
+// block being typed in. Each render starts a new generation; what the one
+// before did not use is dropped, which keeps the cache the size of the
+// document.
+type renderCache struct {
+	mu       sync.Mutex
+	previous map[string]any
+	current  map[string]any
+}
+
+// next starts the generation of a new render.
+func (c *renderCache) next() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.previous, c.current = c.current, map[string]any{}
+}
+
+func (c *renderCache) get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if value, ok := c.current[key]; ok {
+		return value, true
+	}
+	value, ok := c.previous[key]
+	if ok {
+		c.current[key] = value
+	}
+	return value, ok
+}
+
+func (c *renderCache) put(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current == nil {
+		c.current = map[string]any{}
+	}
+	c.current[key] = value
+}
+
+// maxPreviewWidths is how many widths of the same document the preview cache
+// keeps, enough for the preview and split modes and a resize back and forth.
+const maxPreviewWidths = 4
+
+// previewCache keeps whole terminal previews of one document at the widths
+// it was last rendered at, so that switching between modes or resizing back
+// to an earlier width shows the preview without rendering it again. The
+// first render of other content empties it.
+type previewCache struct {
+	mu       sync.Mutex
+	hash     [sha256.Size]byte
+	widths   []int
+	previews map[int]TerminalPreview
+}
+
+func (c *previewCache) get(hash [sha256.Size]byte, width int) (TerminalPreview, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hash != c.hash {
+		return TerminalPreview{}, false
+	}
+	preview, ok := c.previews[width]
+	return preview, ok
+}
+
+func (c *previewCache) put(hash [sha256.Size]byte, width int, preview TerminalPreview) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hash != c.hash || c.previews == nil {
+		c.hash, c.widths, c.previews = hash, nil, map[int]TerminalPreview{}
+	}
+	if i := slices.Index(c.widths, width); i >= 0 {
+		c.widths = slices.Delete(c.widths, i, i+1)
+	}
+	if len(c.widths) == maxPreviewWidths {
+		delete(c.previews, c.widths[0])
+		c.widths = c.widths[1:]
+	}
+	c.widths = append(c.widths, width)
+	c.previews[width] = preview
+}
+
+// blockKeys returns the cache key of every top level block of doc that
+// starts on a known line: the source from where it starts to where the next
+// one does, together with what else the rendering depends on.
+func blockKeys(doc ast.Node, source []byte, prefix string) map[ast.Node]string {
+	line := lineIndex(source)
+	starts := lineStarts(source)
+	prefix += strings.Join(linkRefDefRe.FindAllString(string(source), -1), "\n") + "\x00"
+
+	keys := map[ast.Node]string{}
+	var last ast.Node
+	lastStart := 0
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		l, ok := blockLine(n, line)
+		if !ok || starts[l] < lastStart {
+			continue
+		}
+		if last != nil {
+			keys[last] = prefix + string(source[lastStart:starts[l]])
+		}
+		last, lastStart = n, starts[l]
+	}
+	if last != nil {
+		keys[last] = prefix + string(source[lastStart:])
+	}
+	return keys
+}
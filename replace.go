@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ReplaceMatch is one pending replacement. Start and End are byte offsets
+// into the file content the match was found in.
+type ReplaceMatch struct {
+	File        string
+	Line        int
+	Start, End  int
+	Replacement string
+	Before      string
+	After       string
+	Excluded    bool
+}
+
+// FindReplacements runs re over every file and expands the replacement,
+// including $1 or ${name} capture group references, for each match.
+func FindReplacements(re *regexp.Regexp, replacement string, files map[string]string) []ReplaceMatch {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var matches []ReplaceMatch
+	for _, name := range names {
+		content := files[name]
+		for _, loc := range re.FindAllStringSubmatchIndex(content, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			expanded := string(re.ExpandString(nil, replacement, content, loc))
+
+			lineStart := strings.LastIndex(content[:loc[0]], "\n") + 1
+			lineEnd := len(content)
+			if i := strings.Index(content[loc[1]:], "\n"); i >= 0 {
+				lineEnd = loc[1] + i
+			}
+			matches = append(matches, ReplaceMatch{
+				File:        name,
+				Line:        strings.Count(content[:loc[0]], "\n"),
+				Start:       loc[0],
+				End:         loc[1],
+				Replacement: expanded,
+				Before:      content[lineStart:lineEnd],
+				After:       content[lineStart:loc[0]] + expanded + content[loc[1]:lineEnd],
+			})
+		}
+	}
+	return matches
+}
+
+// ApplyReplacements rewrites content with the matches of one file that were
+// not excluded.
+func ApplyReplacements(content string, matches []ReplaceMatch) string {
+	var buf strings.Builder
+	last := 0
+	for _, match := range matches {
+		if match.Excluded || match.Start < last {
+			continue
+		}
+		buf.WriteString(content[last:match.Start])
+		buf.WriteString(match.Replacement)
+		last = match.End
+	}
+	buf.WriteString(content[last:])
+	return buf.String()
+}
+
+var (
+	diffRemovedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5F5F"))
+	diffAddedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#5FD75F"))
+)
+
+// replacer is the project-wide search and replace overlay. It first asks for
+// the pattern and replacement, then lists every match as a small diff. With
+// links set it starts out with the links to renamed headings instead, and
+// with rewrite, the title of an automatic rewrite such as the formatting of
+// a save, with its changes.
+type replacer struct {
+	links       bool
+	rewrite     string
+	saving      bool
+	pattern     string
+	replacement string
+	field       int
+	reviewing   bool
+	matches     []ReplaceMatch
+	contents    map[string]string
+	cursor      int
+	err         string
+}
+
+// update handles a key press and reports whether the overlay was closed and,
+// if so, whether the replacements should be applied.
+func (r *replacer) update(msg tea.KeyMsg, files func() map[string]string) (closed bool, apply bool) {
+	if r.reviewing {
+		switch msg.String() {
+		case "esc":
+			if r.links || r.rewrite != "" {
+				return true, false
+			}
+			r.reviewing = false
+		case "up", "k":
+			r.cursor = max(r.cursor-1, 0)
+		case "down", "j":
+			r.cursor = min(r.cursor+1, len(r.matches)-1)
+		case " ", "x":
+			if len(r.matches) > 0 {
+				r.matches[r.cursor].Excluded = !r.matches[r.cursor].Excluded
+			}
+		case "a":
+			exclude := true
+			for _, match := range r.matches {
+				exclude = exclude && !match.Excluded
+			}
+			for i := range r.matches {
+				r.matches[i].Excluded = exclude
+			}
+		case "enter":
+			return true, true
+		}
+		return false, false
+	}
+
+	field := &r.pattern
+	if r.field == 1 {
+		field = &r.replacement
+	}
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		return true, false
+	case "tab", "shift+tab", "up", "down":
+		r.field = 1 - r.field
+	case "backspace":
+		if *field != "" {
+			runes := []rune(*field)
+			*field = string(runes[:len(runes)-1])
+		}
+	case "enter":
+		re, err := regexp.Compile(r.pattern)
+		if err != nil {
+			r.err = err.Error()
+			return false, false
+		}
+		if r.pattern == "" {
+			r.err = "enter a pattern"
+			return false, false
+		}
+		r.contents = files()
+		r.matches = FindReplacements(re, r.replacement, r.contents)
+		r.cursor = 0
+		r.err = ""
+		if len(r.matches) == 0 {
+			r.err = "no matches"
+			return false, false
+		}
+		r.reviewing = true
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			*field += string(msg.Runes)
+		}
+	}
+	return false, false
+}
+
+// changes groups the included matches by file and returns the new content
+// of every file that changes.
+func (r *replacer) changes() map[string]string {
+	byFile := map[string][]ReplaceMatch{}
+	for _, match := range r.matches {
+		if !match.Excluded {
+			byFile[match.File] = append(byFile[match.File], match)
+		}
+	}
+	changed := map[string]string{}
+	for file, matches := range byFile {
+		changed[file] = ApplyReplacements(r.contents[file], matches)
+	}
+	return changed
+}
+
+func (r *replacer) view(width, height int) string {
+	var lines []string
+	if r.links {
+		lines = append(lines, titleStyle.Render("Update Links"))
+	} else if r.rewrite != "" {
+		lines = append(lines, titleStyle.Render(r.rewrite))
+	} else {
+		lines = append(lines, titleStyle.Render("Replace in Files"))
+	}
+
+	if !r.reviewing {
+		label := func(i int, name, value string) string {
+			text := fmt.Sprintf("%-12s %s", name, value)
+			if r.field == i {
+				return pickerSelectedStyle.Render(text + "█")
+			}
+			return text
+		}
+		lines = append(lines, "", label(0, "Pattern:", r.pattern), label(1, "Replace:", r.replacement), "")
+		if r.err != "" {
+			lines = append(lines, diffRemovedStyle.Render(r.err))
+		}
+		lines = append(lines, helpStyle.Render("Go regular expression; $1 or ${name} insert capture groups"))
+		lines = append(lines, helpStyle.Render("tab: switch field • enter: find matches • esc: cancel"))
+		return pickerStyle.Width(width - 2).Render(strings.Join(lines, "\n"))
+	}
+
+	included := 0
+	files := map[string]bool{}
+	for _, match := range r.matches {
+		if !match.Excluded {
+			included++
+			files[match.File] = true
+		}
+	}
+	if r.links {
+		lines = append(lines, helpStyle.Render(fmt.Sprintf("%d of %d links to renamed headings in %d files will be updated", included, len(r.matches), len(files))))
+	} else if r.rewrite != "" {
+		lines = append(lines, helpStyle.Render(fmt.Sprintf("%d of %d changes will be applied", included, len(r.matches))))
+	} else {
+		lines = append(lines, helpStyle.Render(fmt.Sprintf("%d of %d matches in %d files will be replaced", included, len(r.matches), len(files))))
+	}
+
+	// Each match takes three lines: location, removed and added
+	visible := max((height-6)/3, 1)
+	start := max(0, r.cursor-visible+1)
+	innerWidth := width - 6
+	for i := start; i < len(r.matches) && i < start+visible; i++ {
+		match := r.matches[i]
+		check := "[x]"
+		if match.Excluded {
+			check = "[ ]"
+		}
+		location := fmt.Sprintf("%s %s:%d", check, match.File, match.Line+1)
+		if i == r.cursor {
+			location = pickerSelectedStyle.Render(location)
+		}
+		before := truncate("- "+strings.TrimSpace(match.Before), innerWidth)
+		after := truncate("+ "+strings.TrimSpace(match.After), innerWidth)
+		if match.Excluded {
+			lines = append(lines, location, helpStyle.Render(before), helpStyle.Render(after))
+		} else {
+			lines = append(lines, location, diffRemovedStyle.Render(before), diffAddedStyle.Render(after))
+		}
+	}
+	if r.links {
+		lines = append(lines, helpStyle.Render("space: include/exclude • a: toggle all • enter: update • esc: skip"))
+	} else if r.saving {
+		lines = append(lines, helpStyle.Render("space: accept/skip • a: toggle all • enter: save • esc: cancel the save"))
+	} else if r.rewrite != "" {
+		lines = append(lines, helpStyle.Render("space: accept/skip • a: toggle all • enter: apply • esc: cancel"))
+	} else {
+		lines = append(lines, helpStyle.Render("space: include/exclude • a: toggle all • enter: apply • esc: back"))
+	}
+	return pickerStyle.Width(width - 2).Render(strings.Join(lines, "\n"))
+}
+
+func truncate(text string, width int) string {
+	runes := []rune(text)
+	if width <= 1 || len(runes) <= width {
+		return text
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// replaceSources collects the text of every project file, taking open
+// buffers from memory so unsaved edits are searched too.
+func (m *model) replaceSources() map[string]string {
+	m.stashBuffer()
+	files := map[string]string{}
+	for _, path := range browserFiles() {
+		if data, err := os.ReadFile(path); err == nil {
+			files[path] = string(data)
+		}
+	}
+	for _, b := range m.buffers {
+		if b.filename == "" {
+			continue
+		}
+		for path := range files {
+			if sameFile(path, b.filename) {
+				delete(files, path)
+			}
+		}
+		files[b.filename] = b.document()
+	}
+	return files
+}
+
+// applyReplacements writes the changes. Files open in a buffer are changed
+// in the buffer and left unsaved so the edit can still be reviewed.
+func (m *model) applyReplacements() {
+	changed := m.replacer.changes()
+	m.stashBuffer()
+	count := 0
+	for _, match := range m.replacer.matches {
+		if !match.Excluded {
+			count++
+		}
+	}
+
+	for path, content := range changed {
+		open := false
+		for i, b := range m.buffers {
+			if b.filename == path {
+				text, narrow := narrowed(b.narrow, content)
+				m.buffers[i].textarea.SetValue(text)
+				m.buffers[i].narrow = narrow
+				m.buffers[i].history.Record(m.buffers[i].document())
+				open = true
+			}
+		}
+		if open {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			m.status = fmt.Sprintf("error writing %s: %v", path, err)
+			return
+		}
+	}
+
+	m.loadBuffer(m.active)
+	if m.replacer.links {
+		m.status = fmt.Sprintf("Updated %d links in %d files", count, len(changed))
+		return
+	}
+	m.status = fmt.Sprintf("Replaced %d matches in %d files", count, len(changed))
+}
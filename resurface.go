@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// defaultReviewDays is how long a note goes unopened before it resurfaces
+// when the config does not say.
+const defaultReviewDays = 30
+
+var noteTagRe = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_/-]*\p{L}[\p{L}\p{N}_/-]*)`)
+
+// ReviewConfig is the [review] table. Days is how long a note goes unopened
+// before it resurfaces. Exclude keeps folders of the workspace, such as
+// "archive", and notes with a tag, such as "#private", out of both the
+// random note and the resurfaced ones.
+type ReviewConfig struct {
+	Days    int      `toml:"days"`
+	Exclude []string `toml:"exclude"`
+}
+
+func (c ReviewConfig) days() int {
+	if c.Days <= 0 {
+		return defaultReviewDays
+	}
+	return c.Days
+}
+
+// excludes reports whether path is left out of note review. The file is
+// only read when there are tags to look for.
+func (c ReviewConfig) excludes(path string) bool {
+	slashed := filepath.ToSlash(filepath.Clean(path))
+	var tags []string
+	for _, entry := range c.Exclude {
+		entry = strings.TrimSpace(entry)
+		if tag, ok := strings.CutPrefix(entry, "#"); ok {
+			tags = append(tags, strings.ToLower(tag))
+			continue
+		}
+		folder := strings.Trim(filepath.ToSlash(filepath.Clean(entry)), "/")
+		if folder != "" && folder != "." && (slashed == folder || strings.HasPrefix(slashed, folder+"/")) {
+			return true
+		}
+	}
+	if len(tags) == 0 {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	for _, tag := range noteTags(string(data)) {
+		for _, excluded := range tags {
+			if strings.EqualFold(tag, excluded) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// noteTags is the tags of a note: those of its front matter and the
+// #hashtags of its text, code blocks left out.
+func noteTags(content string) []string {
+	var tags []string
+	if fm := ParseFrontMatter(content); fm != nil {
+		tags = append(tags, fm.Tags...)
+		content = content[fm.End:]
+	}
+	code := map[int]bool{}
+	for _, fence := range codeFences(content) {
+		for i := fence.Open; i <= fence.Close; i++ {
+			code[i] = true
+		}
+	}
+	for i, line := range strings.Split(content, "\n") {
+		if code[i] {
+			continue
+		}
+		for _, m := range noteTagRe.FindAllStringSubmatch(line, -1) {
+			tags = append(tags, m[1])
+		}
+	}
+	return tags
+}
+
+// ReviewNote is a note of the workspace and when it was last open, or, for
+// a note parselt has not opened, last changed.
+type ReviewNote struct {
+	Path   string
+	Seen   time.Time
+	Opened bool
+}
+
+// age says how long ago the note was seen, for the lists.
+func (n ReviewNote) age(now time.Time) string {
+	verb := "changed"
+	if n.Opened {
+		verb = "opened"
+	}
+	days := int(now.Sub(n.Seen).Hours() / 24)
+	switch days {
+	case 0:
+		return verb + " today"
+	case 1:
+		return verb + " yesterday"
+	}
+	return fmt.Sprintf("%s %d days ago", verb, days)
+}
+
+// ReviewNotes is the notes of the workspace that cfg does not exclude.
+func ReviewNotes(cfg ReviewConfig) []ReviewNote {
+	store, _ := loadPositions(positionsPath())
+	var notes []ReviewNote
+	for _, path := range browserFiles() {
+		if cfg.excludes(path) {
+			continue
+		}
+		note := ReviewNote{Path: path}
+		if abs, err := normalizePath(path); err == nil {
+			if pos, ok := store.Files[abs]; ok && !pos.Seen.IsZero() {
+				note.Seen, note.Opened = pos.Seen, true
+			}
+		}
+		if !note.Opened {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			note.Seen = info.ModTime()
+		}
+		notes = append(notes, note)
+	}
+	return notes
+}
+
+// RandomNote picks a note of the workspace other than current.
+func RandomNote(cfg ReviewConfig, current string) (string, bool) {
+	var paths []string
+	for _, note := range ReviewNotes(cfg) {
+		if current == "" || !sameFile(note.Path, current) {
+			paths = append(paths, note.Path)
+		}
+	}
+	if len(paths) == 0 {
+		return "", false
+	}
+	return paths[rand.Intn(len(paths))], true
+}
+
+// ResurfaceNotes is the notes not open for the days of cfg at now, those
+// forgotten longest first.
+func ResurfaceNotes(cfg ReviewConfig, now time.Time) []ReviewNote {
+	cutoff := now.AddDate(0, 0, -cfg.days())
+	var old []ReviewNote
+	for _, note := range ReviewNotes(cfg) {
+		if note.Seen.Before(cutoff) {
+			old = append(old, note)
+		}
+	}
+	sort.SliceStable(old, func(i, j int) bool { return old[i].Seen.Before(old[j].Seen) })
+	return old
+}
+
+func (m *model) openRandomNote() {
+	path, ok := RandomNote(m.review, m.filename)
+	if !ok {
+		m.status = "No other notes in this directory"
+		return
+	}
+	m.openBuffer(path)
+}
+
+func (m *model) openResurface() {
+	now := time.Now()
+	m.oldNotes = ResurfaceNotes(m.review, now)
+	if len(m.oldNotes) == 0 {
+		m.status = fmt.Sprintf("Every note was open in the last %d days", m.review.days())
+		return
+	}
+	items := make([]pickerItem, len(m.oldNotes))
+	for i, note := range m.oldNotes {
+		items[i] = pickerItem{title: note.Path, detail: note.age(now), index: i}
+	}
+	m.overlay = overlayResurface
+	m.picker = newPicker(fmt.Sprintf("Not Opened in %d Days", m.review.days()), items)
+	m.picker.hint = "enter: open"
+}
+
+func (g *GUIApp) openRandomNote() {
+	path, ok := RandomNote(g.config.Review, g.currentFile)
+	if !ok {
+		dialog.ShowInformation("Random Note", "There are no other notes in the working directory.", g.window)
+		return
+	}
+	g.confirmDiscard(func() { g.openPath(path, Location{}) })
+}
+
+func (g *GUIApp) resurfaceNotes() {
+	now := time.Now()
+	notes := ResurfaceNotes(g.config.Review, now)
+	title := fmt.Sprintf("Not Opened in %d Days", g.config.Review.days())
+	if len(notes) == 0 {
+		dialog.ShowInformation(title, fmt.Sprintf("Every note was open in the last %d days.", g.config.Review.days()), g.window)
+		return
+	}
+	var d dialog.Dialog
+	list := widget.NewList(
+		func() int { return len(notes) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(notes[id].Path + " (" + notes[id].age(now) + ")")
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		d.Hide()
+		g.confirmDiscard(func() { g.openPath(notes[id].Path, Location{}) })
+	}
+	d = dialog.NewCustom(title, "Cancel", container.NewStack(list), g.window)
+	d.Resize(fyne.NewSize(480, 420))
+	d.Show()
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxRewriteCells bounds the table RewriteChanges matches lines with; a
+// rewrite of more lines than that is one change.
+const maxRewriteCells = 1 << 22
+
+// rewriteLine shows the lines of a change on one line of the review.
+func rewriteLine(text string) string {
+	return strings.ReplaceAll(strings.TrimSuffix(text, "\n"), "\n", " ⏎ ")
+}
+
+// RewriteChanges lists how after, a rewrite of before, the text of file,
+// differs from it: one change per run of changed lines, as replacements
+// that ApplyReplacements puts into before.
+func RewriteChanges(file, before, after string) []ReplaceMatch {
+	old, updated := strings.SplitAfter(before, "\n"), strings.SplitAfter(after, "\n")
+	offsets := make([]int, len(old)+1)
+	for i, line := range old {
+		offsets[i+1] = offsets[i] + len(line)
+	}
+	prefix := 0
+	for prefix < len(old) && prefix < len(updated) && old[prefix] == updated[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(updated)-prefix && old[len(old)-1-suffix] == updated[len(updated)-1-suffix] {
+		suffix++
+	}
+	a, b := old[prefix:len(old)-suffix], updated[prefix:len(updated)-suffix]
+
+	var matches []ReplaceMatch
+	change := func(i1, i2, j1, j2 int) {
+		if i1 == i2 && j1 == j2 {
+			return
+		}
+		from, to := rewriteLine(strings.Join(a[i1:i2], "")), rewriteLine(strings.Join(b[j1:j2], ""))
+		if strings.TrimSpace(from) == strings.TrimSpace(to) {
+			to += "  (whitespace)"
+		}
+		matches = append(matches, ReplaceMatch{
+			File:        file,
+			Line:        prefix + i1,
+			Start:       offsets[prefix+i1],
+			End:         offsets[prefix+i2],
+			Replacement: strings.Join(b[j1:j2], ""),
+			Before:      from,
+			After:       to,
+		})
+	}
+	if (len(a)+1)*(len(b)+1) > maxRewriteCells {
+		change(0, len(a), 0, len(b))
+		return matches
+	}
+
+	// common[i*width+j] is how many lines a[i:] and b[j:] have in common
+	width := len(b) + 1
+	common := make([]int32, (len(a)+1)*width)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i*width+j] = common[(i+1)*width+j+1] + 1
+			} else {
+				common[i*width+j] = max(common[(i+1)*width+j], common[i*width+j+1])
+			}
+		}
+	}
+	i, j, i1, j1 := 0, 0, 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			change(i1, i, j1, j)
+			i, j = i+1, j+1
+			i1, j1 = i, j
+		case j == len(b) || (i < len(a) && common[(i+1)*width+j] >= common[i*width+j+1]):
+			i++
+		default:
+			j++
+		}
+	}
+	change(i1, i, j1, j)
+	return matches
+}
+
+// LintFixChanges lists the suggested fixes of issues, found in content, as
+// replacements for review.
+func LintFixChanges(file, content string, issues []LintIssue) []ReplaceMatch {
+	lines := strings.Split(content, "\n")
+	offsets := make([]int, len(lines))
+	for i := 1; i < len(lines); i++ {
+		offsets[i] = offsets[i-1] + len(lines[i-1]) + 1
+	}
+	var matches []ReplaceMatch
+	for _, issue := range issues {
+		fix := issue.Fix
+		if fix == nil || fix.Line < 0 || fix.Line >= len(lines) {
+			continue
+		}
+		line := lines[fix.Line]
+		if fix.Start < 0 || fix.End > len(line) || fix.Start > fix.End {
+			continue
+		}
+		matches = append(matches, ReplaceMatch{
+			File:        file,
+			Line:        fix.Line,
+			Start:       offsets[fix.Line] + fix.Start,
+			End:         offsets[fix.Line] + fix.End,
+			Replacement: fix.Text,
+			Before:      line,
+			After:       line[:fix.Start] + fix.Text + line[fix.End:],
+		})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Start < matches[j].Start })
+	return matches
+}
+
+// formattedMsg brings back the document as the before_save hooks rewrote
+// it, for review before it is saved.
+type formattedMsg struct {
+	filename string
+	before   string
+	content  string
+	err      error
+}
+
+// formatForReview runs the before_save hooks over the document in the
+// background, to show what they change before saving.
+func (m model) formatForReview() tea.Cmd {
+	filename := m.filename
+	if filename == "" {
+		filename = "untitled.md"
+	}
+	before := m.document()
+	hooks := m.hooks
+	return func() tea.Msg {
+		content, err := hooks.FilterBeforeSave(filename, before)
+		return formattedMsg{filename: filename, before: before, content: content, err: err}
+	}
+}
+
+// reviewFormatting lists the changes of the hooks to accept or skip before
+// saving, or saves right away when they changed nothing.
+func (m *model) reviewFormatting(msg formattedMsg) tea.Cmd {
+	if msg.err != nil {
+		m.status = msg.err.Error()
+		return nil
+	}
+	if (m.filename != msg.filename && m.filename != "") || m.document() != msg.before || m.overlay != overlayNone {
+		m.status = "The document changed while it was formatted, save again"
+		return nil
+	}
+	if msg.content == msg.before {
+		return m.saveCmd(false)
+	}
+	m.reviewRewrite("Format on Save", msg.filename, msg.before, RewriteChanges(msg.filename, msg.before, msg.content), true)
+	return nil
+}
+
+// reviewRewrite shows the changes of an automatic rewrite of before in the
+// replace overlay, to accept or skip one by one. saving rewrites the whole
+// document and saves it afterwards; otherwise before is the editor text.
+func (m *model) reviewRewrite(title, file, before string, matches []ReplaceMatch, saving bool) {
+	m.replacer = &replacer{rewrite: title, saving: saving, reviewing: true, matches: matches, contents: map[string]string{file: before}}
+	m.overlay = overlayReplace
+}
+
+// applyRewrite puts the accepted changes of the reviewed rewrite into the
+// editor, and saves when the rewrite was a save's.
+func (m *model) applyRewrite() tea.Cmd {
+	r := m.replacer
+	var before string
+	for _, text := range r.contents {
+		before = text
+	}
+	current := m.textarea.Value()
+	if r.saving {
+		current = m.document()
+	}
+	if current != before {
+		m.status = "The document changed in the meantime, nothing was applied"
+		return nil
+	}
+	accepted := 0
+	for _, match := range r.matches {
+		if !match.Excluded {
+			accepted++
+		}
+	}
+	content := ApplyReplacements(before, r.matches)
+	if content != before {
+		row, col := m.textarea.Line(), m.textarea.LineInfo().StartColumn+m.textarea.LineInfo().ColumnOffset
+		if r.saving {
+			m.setDocument(content)
+		} else {
+			m.textarea.SetValue(content)
+			m.content = content
+		}
+		moveCursorTo(&m.textarea, row, col)
+		if m.mode != editMode {
+			m.refreshPreview()
+		}
+	}
+	m.status = fmt.Sprintf("Applied %d of %d changes", accepted, len(r.matches))
+	if r.saving {
+		return m.saveCmd(false)
+	}
+	return nil
+}
+
+// fixAllLint reviews every suggested fix of the lint list at once.
+func (m *model) fixAllLint() {
+	matches := LintFixChanges(m.filename, m.textarea.Value(), m.lintIssues)
+	if len(matches) == 0 {
+		m.status = "None of these issues has a suggested fix"
+		return
+	}
+	m.picker = nil
+	m.reviewRewrite("Lint Fixes", m.filename, m.textarea.Value(), matches, false)
+}
+
+// updateLint handles the keys the lint list has besides those of a picker.
+func (m *model) updateLint(msg tea.KeyMsg) bool {
+	if msg.String() != "ctrl+f" {
+		return false
+	}
+	m.fixAllLint()
+	return true
+}
+
+// reviewRewrite shows the changes of an automatic rewrite of before with a
+// check box each, and calls done with before and the accepted changes in
+// it when confirmed.
+func (g *GUIApp) reviewRewrite(title, confirm, before string, matches []ReplaceMatch, done func(content string)) {
+	list := container.NewVBox()
+	for i, match := range matches {
+		check := widget.NewCheck(fmt.Sprintf("Line %d", match.Line+1), func(on bool) {
+			matches[i].Excluded = !on
+		})
+		check.SetChecked(true)
+		diff := widget.NewLabel("- " + strings.TrimSpace(match.Before) + "\n+ " + strings.TrimSpace(match.After))
+		diff.TextStyle = fyne.TextStyle{Monospace: true}
+		diff.Truncation = fyne.TextTruncateEllipsis
+		list.Add(container.NewBorder(nil, nil, check, nil, diff))
+	}
+	scroll := container.NewVScroll(list)
+	scroll.SetMinSize(fyne.NewSize(640, 280))
+	label := widget.NewLabel(fmt.Sprintf("%d changes; untick those to skip.", len(matches)))
+	d := dialog.NewCustomConfirm(title, confirm, "Cancel", container.NewBorder(label, nil, nil, nil, scroll), func(ok bool) {
+		if ok {
+			done(ApplyReplacements(before, matches))
+		}
+	}, g.window)
+	d.Show()
+}
+
+// reviewFormatting shows what the before_save hooks change in the
+// document, then saves with the changes accepted and runs next. It returns
+// false while the review is open; true means the save can go on as is.
+func (g *GUIApp) reviewFormatting(next func()) bool {
+	before := g.document()
+	content, err := g.config.Hooks.FilterBeforeSave(g.currentFile, before)
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return false
+	}
+	if content == before {
+		return true
+	}
+	g.reviewRewrite("Format on Save", "Save", before, RewriteChanges(g.currentFile, before, content), func(accepted string) {
+		if g.document() != before {
+			dialog.ShowInformation("Format on Save", "The document changed in the meantime, save again.", g.window)
+			return
+		}
+		row, col := g.editor.CursorRow, g.editor.CursorColumn
+		g.setDocument(accepted)
+		g.editor.CursorRow, g.editor.CursorColumn = min(row, sourceLineCount(g.editor.Text)-1), col
+		g.editor.Refresh()
+		g.reviewed = &accepted
+		g.saveThen(next)
+	})
+	return false
+}
+
+// formatBeforeSave passes the document through the before_save hooks, by
+// way of a review when [hooks] asks for one. It returns false when the save
+// has to wait or stop.
+func (g *GUIApp) formatBeforeSave(next func()) bool {
+	if reviewed := g.reviewed; reviewed != nil {
+		g.reviewed = nil
+		if *reviewed == g.document() {
+			return true
+		}
+	}
+	if g.config.Hooks.Review && len(g.config.Hooks.BeforeSave) > 0 {
+		return g.reviewFormatting(next)
+	}
+	return g.filterBeforeSave(g.currentFile)
+}
+
+// fixAllLint reviews every suggested fix of issues, found in the editor
+// text, at once.
+func (g *GUIApp) fixAllLint(issues []LintIssue) {
+	before := g.editor.Text
+	matches := LintFixChanges(g.currentFile, before, issues)
+	if len(matches) == 0 {
+		dialog.ShowInformation("Lint", "None of these issues has a suggested fix.", g.window)
+		return
+	}
+	g.reviewRewrite("Lint Fixes", "Apply", before, matches, func(accepted string) {
+		if g.editor.Text != before {
+			dialog.ShowInformation("Lint Fixes", "The document changed in the meantime, lint it again.", g.window)
+			return
+		}
+		g.editor.SetText(accepted)
+	})
+}
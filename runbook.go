@@ -0,0 +1,426 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// runbookStampLayout is how a step is marked done: a check mark and the
+// time, at the end of its line.
+const runbookStampLayout = "2006-01-02 15:04"
+
+var (
+	// numberedHeadingRe matches headings that are steps: "1. Backup",
+	// "2) Drain" or "Step 3: Deploy".
+	numberedHeadingRe = regexp.MustCompile(`(?i)^(?:\d+[.)]|step\s+\d+\b)`)
+	runbookStampRe    = regexp.MustCompile(` ✓ \d{4}-\d\d-\d\d \d\d:\d\d$`)
+	runbookTaskRe     = regexp.MustCompile(`^(\s*(?:[-*+]|\d+[.)])\s+\[) (\])`)
+
+	// runbookLanguages are the code blocks a step runs.
+	runbookLanguages = map[string]bool{"sh": true, "bash": true, "shell": true, "zsh": true}
+)
+
+// RunbookConfig is the [runbook] table. With Sidecar set, steps are not
+// stamped in the document; the time each was done, and the output of what
+// it ran, go to a log next to it instead, deploy.runbook.log for
+// deploy.md. Tasks are ticked either way.
+type RunbookConfig struct {
+	Sidecar bool `toml:"sidecar"`
+}
+
+// RunbookStep is a step of a runbook: a task list item or a numbered
+// heading, with the lines up to the next step. Commands are the code of its
+// shell blocks.
+type RunbookStep struct {
+	Line     int
+	End      int
+	Title    string
+	Task     bool
+	Done     bool
+	Commands []string
+}
+
+// RunbookSteps lists the steps of content in document order.
+func (smp *SharedMarkdownProcessor) RunbookSteps(content string) []RunbookStep {
+	lines := strings.Split(content, "\n")
+	headings := smp.Outline(content)
+	var steps []RunbookStep
+	for _, heading := range headings {
+		if numberedHeadingRe.MatchString(heading.Text) {
+			steps = append(steps, RunbookStep{Line: heading.Line, Title: heading.Text})
+		}
+	}
+	for _, task := range smp.TaskItems(content) {
+		steps = append(steps, RunbookStep{Line: task.Line, Title: task.Text, Task: true, Done: task.Checked})
+	}
+	sort.Slice(steps, func(i, j int) bool { return steps[i].Line < steps[j].Line })
+
+	fences := codeFences(content)
+	for i := range steps {
+		step := &steps[i]
+		step.End = len(lines)
+		if i+1 < len(steps) {
+			step.End = steps[i+1].Line
+		}
+		// A section ends at the next heading as high as its own, and a task
+		// at any heading
+		level := 7
+		for _, h := range headings {
+			if h.Line == step.Line {
+				level = h.Level
+			}
+		}
+		for _, h := range headings {
+			if h.Line > step.Line && h.Line < step.End && (step.Task || h.Level <= level) {
+				step.End = h.Line
+				break
+			}
+		}
+		step.Title = strings.TrimSpace(runbookStampRe.ReplaceAllString(step.Title, ""))
+		if !step.Task {
+			step.Done = runbookStampRe.MatchString(lines[step.Line])
+		}
+		for _, fence := range fences {
+			if fence.Open > step.Line && fence.Open < step.End && runbookLanguages[strings.ToLower(fence.Lang)] {
+				step.Commands = append(step.Commands, strings.Join(lines[fence.Open+1:min(fence.Close, len(lines))], "\n"))
+			}
+		}
+	}
+	return steps
+}
+
+// MarkStepDone ticks the task of step and, unless stamp is off, puts the
+// time at the end of its line.
+func MarkStepDone(content string, step RunbookStep, now time.Time, stamp bool) string {
+	lines := strings.Split(content, "\n")
+	if step.Line >= len(lines) {
+		return content
+	}
+	line := lines[step.Line]
+	if step.Task {
+		line = runbookTaskRe.ReplaceAllString(line, "${1}x$2")
+	}
+	if stamp {
+		line = runbookStampRe.ReplaceAllString(strings.TrimRight(line, " \t"), "") + " ✓ " + now.Format(runbookStampLayout)
+	}
+	lines[step.Line] = line
+	return strings.Join(lines, "\n")
+}
+
+// runbookLogPath is the sidecar log of the runbook at path.
+func runbookLogPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".runbook.log"
+}
+
+// LogRunbook appends an entry about step to the sidecar log of path.
+// Output, if any, follows it indented.
+func LogRunbook(path string, step RunbookStep, event, output string, now time.Time) error {
+	if path == "" {
+		return fmt.Errorf("save the runbook first, the log goes next to it")
+	}
+	entry := fmt.Sprintf("%s  %s  %s\n", now.Format("2006-01-02 15:04:05"), event, step.Title)
+	if output = strings.TrimRight(output, "\n"); output != "" {
+		entry += "    " + strings.ReplaceAll(output, "\n", "\n    ") + "\n"
+	}
+	file, err := os.OpenFile(runbookLogPath(path), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing runbook log: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(entry); err != nil {
+		return fmt.Errorf("error writing runbook log: %v", err)
+	}
+	return nil
+}
+
+// RunStep runs the shell blocks of step one after the other in the
+// directory of file, stopping at the first that fails, and returns what
+// they printed.
+func RunStep(step RunbookStep, file string) (string, error) {
+	var output strings.Builder
+	for _, command := range step.Commands {
+		out, err := runCommand("step", command, "runbook", file)
+		output.WriteString(out)
+		if err != nil {
+			return output.String(), err
+		}
+	}
+	return output.String(), nil
+}
+
+// firstOpenStep is the index of the first step not done, or the last step.
+func firstOpenStep(steps []RunbookStep) int {
+	for i, step := range steps {
+		if !step.Done {
+			return i
+		}
+	}
+	return len(steps) - 1
+}
+
+// stepSource is the text of the lines of step after its own, trimmed of
+// blank lines, for the step views.
+func stepSource(content string, step RunbookStep) string {
+	lines := strings.Split(content, "\n")
+	body := lines[min(step.Line+1, len(lines)):min(step.End, len(lines))]
+	return strings.Trim(strings.Join(body, "\n"), "\n")
+}
+
+// runbookSession is the runbook mode of the terminal app.
+type runbookSession struct {
+	step    int
+	output  string
+	running bool
+}
+
+// runbookRunMsg brings back what a step ran.
+type runbookRunMsg struct {
+	step   RunbookStep
+	output string
+	err    error
+}
+
+func (m *model) openRunbook() {
+	steps := m.mdProcessor.RunbookSteps(m.textarea.Value())
+	if len(steps) == 0 {
+		m.status = "No steps: a runbook has task list items or numbered headings"
+		return
+	}
+	m.overlay = overlayRunbook
+	m.runbookRun = &runbookSession{step: firstOpenStep(steps)}
+}
+
+func (m *model) updateRunbook(msg tea.KeyMsg) tea.Cmd {
+	r := m.runbookRun
+	steps := m.mdProcessor.RunbookSteps(m.textarea.Value())
+	if len(steps) == 0 {
+		m.overlay, m.runbookRun = overlayNone, nil
+		return nil
+	}
+	r.step = min(r.step, len(steps)-1)
+	step := steps[r.step]
+	switch msg.String() {
+	case "esc", "q":
+		m.overlay, m.runbookRun = overlayNone, nil
+	case "left", "p", "shift+tab":
+		r.step, r.output = max(r.step-1, 0), ""
+	case "right", "n", "tab":
+		r.step, r.output = min(r.step+1, len(steps)-1), ""
+	case "enter", " ":
+		if !step.Done {
+			now := time.Now()
+			m.setFormContent(MarkStepDone(m.textarea.Value(), step, now, !m.runbook.Sidecar))
+			if m.runbook.Sidecar {
+				if err := LogRunbook(m.filename, step, "done", "", now); err != nil {
+					m.status = err.Error()
+				}
+			}
+		}
+		if r.step == len(steps)-1 {
+			m.overlay, m.runbookRun = overlayNone, nil
+			m.status = "Runbook done"
+			return nil
+		}
+		r.step, r.output = r.step+1, ""
+	case "r":
+		if len(step.Commands) == 0 {
+			r.output = "This step has no sh or bash block to run."
+			return nil
+		}
+		if r.running {
+			return nil
+		}
+		r.running, r.output = true, "Running..."
+		file := m.filename
+		return func() tea.Msg {
+			output, err := RunStep(step, file)
+			return runbookRunMsg{step: step, output: output, err: err}
+		}
+	}
+	return nil
+}
+
+func (m *model) runbookRan(msg runbookRunMsg) {
+	output := msg.output
+	if msg.err != nil {
+		output = strings.TrimRight(output, "\n") + "\n" + msg.err.Error()
+	}
+	if m.runbook.Sidecar {
+		event := "ran"
+		if msg.err != nil {
+			event = "failed"
+		}
+		if err := LogRunbook(m.filename, msg.step, event, output, time.Now()); err != nil {
+			m.status = err.Error()
+		}
+	}
+	if m.runbookRun == nil {
+		return
+	}
+	m.runbookRun.running = false
+	m.runbookRun.output = strings.Trim(output, "\n")
+	if m.runbookRun.output == "" {
+		m.runbookRun.output = "(no output)"
+	}
+}
+
+func (m model) runbookView() string {
+	r := m.runbookRun
+	content := m.textarea.Value()
+	steps := m.mdProcessor.RunbookSteps(content)
+	if len(steps) == 0 {
+		return ""
+	}
+	step := steps[min(r.step, len(steps)-1)]
+	width := max(m.width*3/4, 40)
+	box := taskBoxes[0]
+	if step.Done {
+		box = taskBoxes[1]
+	}
+
+	parts := []string{
+		titleStyle.Render(fmt.Sprintf("Runbook · Step %d of %d", min(r.step, len(steps)-1)+1, len(steps))),
+		"",
+		pickerSelectedStyle.Render(box + " " + step.Title),
+	}
+	if source := stepSource(content, step); source != "" {
+		lines := strings.Split(source, "\n")
+		if len(lines) > 12 {
+			lines = append(lines[:12], "…")
+		}
+		parts = append(parts, "", lipgloss.NewStyle().MaxWidth(width).Render(strings.Join(lines, "\n")))
+	}
+	if r.output != "" {
+		lines := strings.Split(r.output, "\n")
+		if len(lines) > 8 {
+			lines = append([]string{"…"}, lines[len(lines)-8:]...)
+		}
+		parts = append(parts, "", helpStyle.MaxWidth(width).Render(strings.Join(lines, "\n")))
+	}
+	hint := "enter: done and next • ←/→: previous/next • esc: close"
+	if len(step.Commands) > 0 {
+		hint = "enter: done and next • r: run • ←/→: previous/next • esc: close"
+	}
+	parts = append(parts, "", helpStyle.Render(hint))
+	return pickerStyle.Render(lipgloss.JoinVertical(lipgloss.Left, parts...))
+}
+
+// runbook steps through the runbook in a dialog.
+func (g *GUIApp) runbook() {
+	steps := g.mdProcessor.RunbookSteps(g.editor.Text)
+	if len(steps) == 0 {
+		dialog.ShowInformation("Runbook", "There are no steps: a runbook has task list items or numbered headings.", g.window)
+		return
+	}
+	current := firstOpenStep(steps)
+
+	title := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	title.Wrapping = fyne.TextWrapWord
+	source := widget.NewLabel("")
+	source.Wrapping = fyne.TextWrapWord
+	output := widget.NewLabel("")
+	output.TextStyle = fyne.TextStyle{Monospace: true}
+	output.Wrapping = fyne.TextWrapWord
+	counter := widget.NewLabel("")
+
+	var prev, next, run, done *widget.Button
+	show := func() {
+		steps = g.mdProcessor.RunbookSteps(g.editor.Text)
+		if len(steps) == 0 {
+			return
+		}
+		current = min(current, len(steps)-1)
+		step := steps[current]
+		mark := "☐ "
+		if step.Done {
+			mark = "☑ "
+		}
+		title.SetText(mark + step.Title)
+		source.SetText(stepSource(g.editor.Text, step))
+		counter.SetText(fmt.Sprintf("Step %d of %d", current+1, len(steps)))
+		prev.Disable()
+		if current > 0 {
+			prev.Enable()
+		}
+		next.Disable()
+		if current < len(steps)-1 {
+			next.Enable()
+		}
+		run.Disable()
+		if len(step.Commands) > 0 {
+			run.Enable()
+		}
+		done.Disable()
+		if !step.Done {
+			done.Enable()
+		}
+	}
+	prev = widget.NewButton("Previous", func() { current--; output.SetText(""); show() })
+	next = widget.NewButton("Next", func() { current++; output.SetText(""); show() })
+	run = widget.NewButton("Run", func() {
+		step := steps[current]
+		run.Disable()
+		output.SetText("Running...")
+		go func() {
+			out, err := RunStep(step, g.currentFile)
+			fyne.Do(func() {
+				event := "ran"
+				if err != nil {
+					out, event = strings.TrimRight(out, "\n")+"\n"+err.Error(), "failed"
+				}
+				if g.config.Runbook.Sidecar {
+					if err := LogRunbook(g.currentFile, step, event, out, time.Now()); err != nil {
+						dialog.ShowError(err, g.window)
+					}
+				}
+				if out = strings.Trim(out, "\n"); out == "" {
+					out = "(no output)"
+				}
+				output.SetText(out)
+				show()
+			})
+		}()
+	})
+	done = widget.NewButton("Done", func() {
+		step := steps[current]
+		now := time.Now()
+		row, col := g.editor.CursorRow, g.editor.CursorColumn
+		g.editor.SetText(MarkStepDone(g.editor.Text, step, now, !g.config.Runbook.Sidecar))
+		g.editor.CursorRow, g.editor.CursorColumn = row, col
+		g.editor.Refresh()
+		if g.config.Runbook.Sidecar {
+			if err := LogRunbook(g.currentFile, step, "done", "", now); err != nil {
+				dialog.ShowError(err, g.window)
+			}
+		}
+		if current < len(steps)-1 {
+			current++
+		}
+		output.SetText("")
+		show()
+	})
+	done.Importance = widget.HighImportance
+	show()
+
+	body := container.NewBorder(
+		container.NewVBox(counter, title),
+		container.NewHBox(prev, next, run, done),
+		nil, nil,
+		container.NewVScroll(container.NewVBox(source, output)),
+	)
+	d := dialog.NewCustom("Runbook", "Close", body, g.window)
+	d.Resize(fyne.NewSize(620, 480))
+	d.Show()
+}
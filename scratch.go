@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// scratchPath is where the scratch pad is kept between runs.
+func scratchPath() string {
+	return filepath.Join(stateDir(), "scratch.md")
+}
+
+// LoadScratch returns what was left on the scratch pad.
+func LoadScratch() string {
+	data, err := os.ReadFile(scratchPath())
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// SaveScratch keeps text on the scratch pad for the next run.
+func SaveScratch(text string) error {
+	if err := os.MkdirAll(stateDir(), 0755); err != nil {
+		return fmt.Errorf("error saving scratch pad: %v", err)
+	}
+	if err := os.WriteFile(scratchPath(), []byte(text), 0644); err != nil {
+		return fmt.Errorf("error saving scratch pad: %v", err)
+	}
+	return nil
+}
+
+// scratchHeight is how many lines of the scratch pad the terminal shows.
+func (m model) scratchHeight() int {
+	return max(m.panes.height/2, 3)
+}
+
+// toggleScratch opens the scratch pad over the bottom of the screen, with
+// the keyboard, or closes it.
+func (m *model) toggleScratch() {
+	if m.scratch != nil {
+		m.closeScratch()
+		return
+	}
+	ta := textarea.New()
+	ta.Placeholder = "Jot down anything; it is kept until you clear it"
+	ta.ShowLineNumbers = false
+	ta.Cursor.SetMode(cursor.CursorStatic)
+	ta.SetWidth(m.peekWidth() - peekStyle.GetHorizontalFrameSize())
+	ta.SetHeight(m.scratchHeight())
+	ta.SetValue(LoadScratch())
+	ta.Focus()
+	m.scratch = &ta
+	m.textarea.Blur()
+}
+
+// closeScratch saves and hides the scratch pad.
+func (m *model) closeScratch() {
+	if err := SaveScratch(m.scratch.Value()); err != nil {
+		m.status = err.Error()
+	}
+	m.scratch = nil
+	m.syncFocus()
+}
+
+// updateScratch gives msg to the open scratch pad. esc and the scratch key
+// close it; the quit key closes it and goes on to quit, so it reports false.
+func (m *model) updateScratch(msg tea.KeyMsg) bool {
+	switch {
+	case key.Matches(msg, m.keys.quit):
+		m.closeScratch()
+		return false
+	case msg.String() == "esc" || key.Matches(msg, m.keys.scratch):
+		m.closeScratch()
+	case key.Matches(msg, m.keys.scratchTo):
+		m.moveScratch()
+	default:
+		*m.scratch, _ = m.scratch.Update(msg)
+	}
+	return true
+}
+
+// moveScratch moves the text of the scratch pad into the document at the
+// cursor, leaving the pad empty. It works with the pad open or closed.
+func (m *model) moveScratch() {
+	text := LoadScratch()
+	if m.scratch != nil {
+		text = m.scratch.Value()
+	}
+	text = strings.Trim(text, "\n")
+	if strings.TrimSpace(text) == "" {
+		m.status = "The scratch pad is empty"
+		return
+	}
+	if m.mode == previewMode {
+		m.status = "Switch to the editor to move the scratch pad into the document"
+		return
+	}
+	if m.scratch != nil {
+		m.scratch.Reset()
+		m.closeScratch()
+	} else if err := SaveScratch(""); err != nil {
+		m.status = err.Error()
+		return
+	}
+	m.replaceRange(TextRange{Start: m.cursorOffset(), End: m.cursorOffset()}, text)
+	m.status = "Moved " + clipSize(text) + " from the scratch pad"
+}
+
+// withScratch draws the open scratch pad over the bottom of the editor and
+// preview.
+func (m model) withScratch(content string) string {
+	if m.scratch == nil {
+		return content
+	}
+	hint := helpStyle.Render(fmt.Sprintf("%s: move to document • esc: close", m.keys.scratchTo.Help().Key))
+	body := titleStyle.Render("Scratch Pad") + "\n" + m.scratch.View() + "\n" + hint
+	box := peekStyle.Width(m.peekWidth() - peekStyle.GetHorizontalBorderSize()).Render(body)
+
+	left, _ := m.sideWidths()
+	return placeOver(content, box, left+4, max(lipgloss.Height(content)-lipgloss.Height(box)-1, 0))
+}
+
+// setupScratch builds the scratch pad shown below the editor. It starts
+// hidden and is toggled from the View menu.
+func (g *GUIApp) setupScratch() {
+	g.scratch = widget.NewMultiLineEntry()
+	g.scratch.Wrapping = fyne.TextWrapWord
+	g.scratch.SetPlaceHolder("Jot down anything; it is kept until you clear it")
+	g.scratch.SetText(LoadScratch())
+	g.scratch.SetMinRowsVisible(6)
+	move := widget.NewButton("Move to Document", g.moveScratch)
+	g.scratchPanel = container.NewBorder(
+		container.NewBorder(nil, nil, nil, move, widget.NewLabel("Scratch Pad")), nil, nil, nil,
+		g.scratch,
+	)
+	g.scratchPanel.Hide()
+}
+
+// toggleScratch shows the scratch pad, with the keyboard, or saves and hides
+// it.
+func (g *GUIApp) toggleScratch() {
+	if g.scratchPanel.Visible() {
+		g.scratchPanel.Hide()
+		g.saveScratch()
+		g.window.Canvas().Focus(g.editor)
+	} else {
+		g.scratchPanel.Show()
+		g.window.Canvas().Focus(g.scratch)
+	}
+	g.scratchItem.Checked = g.scratchPanel.Visible()
+}
+
+func (g *GUIApp) saveScratch() {
+	if err := SaveScratch(g.scratch.Text); err != nil {
+		dialog.ShowError(err, g.window)
+	}
+}
+
+// moveScratch moves the text of the scratch pad into the document, over the
+// selection, and empties the pad.
+func (g *GUIApp) moveScratch() {
+	text := strings.Trim(g.scratch.Text, "\n")
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	content := g.editor.Text
+	runes := []rune(content)
+	start, end := g.selectedRange()
+	sel := TextRange{Start: len(string(runes[:start])), End: len(string(runes[:end]))}
+	g.editor.SetText(content[:sel.Start] + text + content[sel.End:])
+	cursor := sel.Start + len(text)
+	g.selectRange(TextRange{Start: cursor, End: cursor})
+	g.scratch.SetText("")
+	g.saveScratch()
+	g.window.Canvas().Focus(g.editor)
+}
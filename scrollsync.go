@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/yuin/goldmark/ast"
+)
+
+// ScrollAnchor ties a line of the markdown source to where it starts in a
+// rendered preview: a line of the terminal preview, a segment of the GUI
+// preview, or a pixel offset once the segments have been measured.
+type ScrollAnchor struct {
+	Source   int
+	Rendered int
+}
+
+// ScrollMap holds the anchors of a preview in document order, one per top
+// level block. Positions between two anchors are interpolated.
+type ScrollMap []ScrollAnchor
+
+// RenderedLine is where source line source ends up in the preview.
+func (s ScrollMap) RenderedLine(source int) int {
+	return s.interpolate(source,
+		func(a ScrollAnchor) int { return a.Source },
+		func(a ScrollAnchor) int { return a.Rendered })
+}
+
+// SourceLine is the source line that rendered position rendered came from.
+func (s ScrollMap) SourceLine(rendered int) int {
+	return s.interpolate(rendered,
+		func(a ScrollAnchor) int { return a.Rendered },
+		func(a ScrollAnchor) int { return a.Source })
+}
+
+func (s ScrollMap) interpolate(x int, from, to func(ScrollAnchor) int) int {
+	if len(s) == 0 {
+		return x
+	}
+	// The first anchor past x; the one before it is where x starts from
+	i := sort.Search(len(s), func(i int) bool { return from(s[i]) > x })
+	if i == 0 {
+		return to(s[0])
+	}
+	a := s[i-1]
+	if i == len(s) {
+		return to(a)
+	}
+	b := s[i]
+	return to(a) + (x-from(a))*(to(b)-to(a))/(from(b)-from(a))
+}
+
+// lineStarts returns the byte offset of every line of source.
+func lineStarts(source []byte) []int {
+	starts := []int{0}
+	for i, c := range source {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// lineIndex returns a lookup from byte offsets of source to line numbers.
+func lineIndex(source []byte) func(offset int) int {
+	starts := lineStarts(source)
+	return func(offset int) int {
+		return sort.SearchInts(starts, offset+1) - 1
+	}
+}
+
+// blockLine is the source line block n starts on, when the tree records it.
+func blockLine(n ast.Node, line func(offset int) int) (int, bool) {
+	if code, ok := n.(*ast.FencedCodeBlock); ok && code.Info == nil {
+		// Only the code has a position, the opening fence is the line before
+		if code.Lines().Len() == 0 {
+			return 0, false
+		}
+		return max(line(code.Lines().At(0).Start)-1, 0), true
+	}
+	offset, ok := blockOffset(n)
+	if !ok {
+		return 0, false
+	}
+	return line(offset), true
+}
+
+func blockOffset(n ast.Node) (int, bool) {
+	switch node := n.(type) {
+	case *ast.FencedCodeBlock:
+		if node.Info != nil {
+			return node.Info.Segment.Start, true
+		}
+	case *ast.Text:
+		return node.Segment.Start, true
+	}
+	if n.Type() == ast.TypeBlock && n.Lines().Len() > 0 {
+		return n.Lines().At(0).Start, true
+	}
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		if offset, ok := blockOffset(child); ok {
+			return offset, true
+		}
+	}
+	return 0, false
+}
+
+func sourceLineCount(content string) int {
+	return bytes.Count([]byte(content), []byte("\n")) + 1
+}
+
+// syncEditorScroll moves the editor cursor, and with it the editor, to the
+// source of what the split preview shows. It is the reverse of
+// syncPreviewScroll.
+func (m *model) syncEditorScroll() {
+	line := 0
+	if m.scrollMap != nil {
+		line = m.scrollMap.SourceLine(m.viewport.YOffset + m.viewport.Height/3)
+	} else if scrollable := m.viewport.TotalLineCount() - m.viewport.Height; scrollable > 0 {
+		line = m.viewport.YOffset * (m.textarea.LineCount() - 1) / scrollable
+	}
+	m.clearSelection()
+	moveCursorTo(&m.textarea, line, 0)
+}
+
+// scrollSplit scrolls the split pane under the mouse and brings the other
+// one along. The editor only scrolls with its cursor, so the wheel moves that.
+func (m *model) scrollSplit(msg tea.MouseMsg) {
+	if m.overPreview(msg.X, msg.Y) {
+		m.viewport, _ = m.viewport.Update(msg)
+		m.syncEditorScroll()
+		return
+	}
+	m.clearSelection()
+	for i := 0; i < m.viewport.MouseWheelDelta; i++ {
+		if msg.Button == tea.MouseButtonWheelUp {
+			m.textarea.CursorUp()
+		} else {
+			m.textarea.CursorDown()
+		}
+	}
+	m.syncPreviewScroll()
+}
+
+// overPreview tells whether screen cell x, y is in the split preview rather
+// than the editor.
+func (m *model) overPreview(x, y int) bool {
+	if m.splitStacked() {
+		// Below the editor and its border
+		return y >= lipgloss.Height(m.headerView())+m.editorHeight()
+	}
+	left, _ := m.sideWidths()
+	return x >= left+m.editorColumns()
+}
+
+// previewPositions turns the preview anchors from segment indices into pixel
+// offsets. Fyne does not say where it laid out a segment, so each block is
+// measured on its own at the width of the preview and the sum is scaled to
+// the height of the whole.
+func (g *GUIApp) previewPositions() ScrollMap {
+	width := g.preview.Size().Width
+	if g.previewPixels != nil && g.previewPixelWidth == width {
+		return g.previewPixels
+	}
+	if len(g.previewAnchors) == 0 || width <= 0 {
+		return nil
+	}
+
+	measure := widget.NewRichText()
+	measure.Wrapping = g.preview.Wrapping
+	positions := make(ScrollMap, len(g.previewAnchors))
+	var y float32
+	for i, anchor := range g.previewAnchors {
+		positions[i] = ScrollAnchor{Source: anchor.Source, Rendered: int(y)}
+		if i+1 == len(g.previewAnchors) {
+			break
+		}
+		end := min(g.previewAnchors[i+1].Rendered, len(g.preview.Segments))
+		if anchor.Rendered >= end {
+			continue
+		}
+		measure.Segments = g.preview.Segments[anchor.Rendered:end]
+		measure.Resize(fyne.NewSize(width, 0))
+		measure.Refresh()
+		y += measure.MinSize().Height
+	}
+	if total := g.preview.MinSize().Height; y > 0 {
+		for i := range positions {
+			positions[i].Rendered = int(float32(positions[i].Rendered) * total / y)
+		}
+	}
+
+	g.previewPixels, g.previewPixelWidth = positions, width
+	return positions
+}
+
+// previewSyncing tells whether both panes are showing, so that scrolling one
+// should scroll the other.
+func (g *GUIApp) previewSyncing() bool {
+	return g.splitPanel.Offset > 0.05 && g.splitPanel.Offset < 0.95
+}
+
+// scrollPreviewTo brings the rendering of source line line to the upper part
+// of the preview.
+func (g *GUIApp) scrollPreviewTo(line int) {
+	height := g.previewScroll.Size().Height
+	scrollable := g.preview.MinSize().Height - height
+	if scrollable <= 0 {
+		g.previewScroll.ScrollToTop()
+		return
+	}
+	var y float32
+	if positions := g.previewPositions(); positions != nil {
+		y = float32(positions.RenderedLine(line)) - height/3
+	} else if lines := sourceLineCount(g.editor.Text); lines > 1 {
+		y = scrollable * float32(line) / float32(lines-1)
+	}
+	g.previewScroll.ScrollToOffset(fyne.NewPos(0, min(max(y, 0), scrollable)))
+}
+
+// syncEditorScroll moves the editor cursor to the source of what the preview
+// shows, which scrolls the editor along.
+func (g *GUIApp) syncEditorScroll(offset fyne.Position) {
+	if !g.previewSyncing() {
+		return
+	}
+	line := 0
+	if positions := g.previewPositions(); positions != nil {
+		line = positions.SourceLine(int(offset.Y + g.previewScroll.Size().Height/3))
+	} else if scrollable := g.preview.MinSize().Height - g.previewScroll.Size().Height; scrollable > 0 {
+		line = int(offset.Y / scrollable * float32(sourceLineCount(g.editor.Text)-1))
+	}
+	if line == g.editor.CursorRow {
+		return
+	}
+	g.scrollSyncing = true
+	g.editor.CursorRow, g.editor.CursorColumn = line, 0
+	g.editor.Refresh()
+	g.scrollSyncing = false
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/yuin/goldmark/ast"
+)
+
+// SecretsConfig is the [secrets] table. With Scan set, what is about to be
+// exported, emailed or turned into an image is first searched for API keys,
+// tokens, private keys, passwords and email addresses, along with the local
+// files it links to, and anything found has to be confirmed. Allow lists
+// regular expressions for what is fine to publish, such as your own address.
+type SecretsConfig struct {
+	Scan  bool     `toml:"scan"`
+	Allow []string `toml:"allow"`
+}
+
+// maxSecretFile is the largest linked file that is searched for secrets.
+const maxSecretFile = 1 << 20
+
+// Secret is something that looks like it should not be published: Kind
+// says what, on Line of File, which is empty for the document itself.
+type Secret struct {
+	File string
+	Line int
+	Kind string
+	Text string
+}
+
+func (s Secret) String() string {
+	where := fmt.Sprintf("line %d", s.Line+1)
+	if s.File != "" {
+		where = fmt.Sprintf("%s:%d", s.File, s.Line+1)
+	}
+	return fmt.Sprintf("%s: %s %s", where, s.Kind, s.Text)
+}
+
+var secretPatterns = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	{"AWS access key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{"Stripe key", regexp.MustCompile(`\b[rs]k_live_[A-Za-z0-9]{16,}`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`)},
+	{"API key", regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`)},
+	{"JSON web token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{"password or secret", regexp.MustCompile("(?i)\\b(?:password|passwd|pwd|secret|api[_-]?key|access[_-]?token|auth[_-]?token|token)\\s*[:=]\\s*[\"']?([^\\s\"'`]{8,})")},
+	{"email address", regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+}
+
+// exampleEmailRe matches the addresses of the domains set aside for
+// examples, which are never anyone's.
+var exampleEmailRe = regexp.MustCompile(`(?i)@example\.(?:com|org|net)$`)
+
+// maskSecret shows enough of a secret to recognize it in the list, and not
+// enough to leak it there. Email addresses show in full.
+func maskSecret(kind, text string) string {
+	if kind == "email address" {
+		return text
+	}
+	runes := []rune(text)
+	if len(runes) <= 8 {
+		return strings.Repeat("•", len(runes))
+	}
+	return string(runes[:4]) + "…" + fmt.Sprintf(" (%d characters)", len(runes))
+}
+
+func (c SecretsConfig) allowed() []*regexp.Regexp {
+	var allow []*regexp.Regexp
+	for _, pattern := range c.Allow {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			re = regexp.MustCompile(regexp.QuoteMeta(pattern))
+		}
+		allow = append(allow, re)
+	}
+	return allow
+}
+
+// findSecrets searches the text of file for secrets.
+func findSecrets(file, text string, allow []*regexp.Regexp) []Secret {
+	var found []Secret
+	for row, line := range strings.Split(text, "\n") {
+		var taken [][]int
+		for _, pattern := range secretPatterns {
+			for _, loc := range pattern.re.FindAllStringSubmatchIndex(line, -1) {
+				start, end := loc[0], loc[1]
+				if len(loc) > 2 && loc[2] >= 0 {
+					start, end = loc[2], loc[3]
+				}
+				match := line[start:end]
+				overlaps := false
+				for _, span := range taken {
+					overlaps = overlaps || (start < span[1] && span[0] < end)
+				}
+				if overlaps || (pattern.kind == "email address" && exampleEmailRe.MatchString(match)) {
+					continue
+				}
+				ok := false
+				for _, re := range allow {
+					ok = ok || re.MatchString(match)
+				}
+				taken = append(taken, []int{start, end})
+				if !ok {
+					found = append(found, Secret{File: file, Line: row, Kind: pattern.kind, Text: maskSecret(pattern.kind, match)})
+				}
+			}
+		}
+	}
+	return found
+}
+
+// linkedFiles are the local files content, the document at path, links to,
+// and with images those it shows too.
+func (smp *SharedMarkdownProcessor) linkedFiles(path, content string, images bool) []string {
+	doc, _ := smp.Parse(content)
+	dir := "."
+	if path != "" {
+		dir = filepath.Dir(path)
+	}
+	seen := map[string]bool{}
+	var files []string
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		var dest string
+		switch node := n.(type) {
+		case *ast.Link:
+			dest = string(node.Destination)
+		case *ast.Image:
+			if !images {
+				return ast.WalkSkipChildren, nil
+			}
+			dest = string(node.Destination)
+		default:
+			return ast.WalkContinue, nil
+		}
+		dest, _, _ = strings.Cut(dest, "#")
+		if dest == "" || isURL(dest) || (!images && IsImageFile(dest)) {
+			return ast.WalkContinue, nil
+		}
+		if unescaped, err := url.PathUnescape(dest); err == nil {
+			dest = unescaped
+		}
+		if !filepath.IsAbs(dest) {
+			dest = filepath.Join(dir, dest)
+		}
+		if !seen[dest] {
+			seen[dest] = true
+			files = append(files, dest)
+		}
+		return ast.WalkContinue, nil
+	})
+	return files
+}
+
+// ScanSecrets searches content, which is published from the document at
+// path, and the local text files it links to for secrets.
+func (smp *SharedMarkdownProcessor) ScanSecrets(path, content string, cfg SecretsConfig) []Secret {
+	if !cfg.Scan {
+		return nil
+	}
+	allow := cfg.allowed()
+	found := findSecrets("", content, allow)
+	for _, file := range smp.linkedFiles(path, content, false) {
+		info, err := os.Stat(file)
+		if err != nil || info.IsDir() || info.Size() > maxSecretFile {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil || !utf8.Valid(data) {
+			continue
+		}
+		found = append(found, findSecrets(file, string(data), allow)...)
+	}
+	return found
+}
+
+// secretsError lists what the scan found, for the command line.
+func secretsError(path string, found []Secret) error {
+	lines := make([]string, len(found))
+	for i, secret := range found {
+		lines[i] = "  " + secret.String()
+	}
+	return fmt.Errorf("%s may hold secrets:\n%s\nexport it anyway with -allow-secrets, or list what is fine under allow in [secrets]",
+		path, strings.Join(lines, "\n"))
+}
+
+// guardSecrets runs publish unless source, about to leave the machine,
+// holds something that looks like a secret; then it lists what was found
+// and waits for p to publish anyway.
+func (m *model) guardSecrets(source string, publish func(*model)) {
+	found := m.mdProcessor.ScanSecrets(m.filename, source, m.secrets)
+	if len(found) == 0 {
+		publish(m)
+		return
+	}
+	m.secretsFound = found
+	m.publish = publish
+	m.overlay = overlaySecrets
+}
+
+func (m *model) updateSecrets(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "p":
+		publish := m.publish
+		m.overlay, m.publish, m.secretsFound = overlayNone, nil, nil
+		publish(m)
+	case "esc", "n":
+		m.overlay, m.publish, m.secretsFound = overlayNone, nil, nil
+		m.status = "Nothing was published"
+	}
+}
+
+func (m model) secretsView() string {
+	lines := []string{
+		titleStyle.Render("Possible Secrets"),
+		"",
+		fmt.Sprintf("What you are about to publish may hold %d secrets:", len(m.secretsFound)),
+		"",
+	}
+	for i, secret := range m.secretsFound {
+		if i == 8 {
+			lines = append(lines, helpStyle.Render(fmt.Sprintf("and %d more", len(m.secretsFound)-i)))
+			break
+		}
+		lines = append(lines, diffRemovedStyle.Render(secret.String()))
+	}
+	lines = append(lines, "", helpStyle.Render("p: publish anyway • esc: cancel"))
+	return pickerStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+// guardSecrets runs publish unless source, about to leave the machine,
+// holds something that looks like a secret; then it asks first.
+func (g *GUIApp) guardSecrets(source string, publish func()) {
+	found := g.mdProcessor.ScanSecrets(g.currentFile, source, g.config.Secrets)
+	if len(found) == 0 {
+		publish()
+		return
+	}
+	list := container.NewVBox()
+	for _, secret := range found {
+		list.Add(widget.NewLabel(secret.String()))
+	}
+	scroll := container.NewVScroll(list)
+	scroll.SetMinSize(fyne.NewSize(560, 200))
+	label := widget.NewLabel(fmt.Sprintf("What you are about to publish may hold %d secrets.", len(found)))
+	dialog.ShowCustomConfirm("Possible Secrets", "Publish Anyway", "Cancel", container.NewBorder(label, nil, nil, nil, scroll), func(ok bool) {
+		if ok {
+			publish()
+		}
+	}, g.window)
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+var selectionWordRe = regexp.MustCompile(`[\p{L}\p{N}_']+`)
+
+// TextRange is a byte range of a document, End exclusive, together with the
+// kind of element it covers.
+type TextRange struct {
+	Start, End int
+	Kind       string
+}
+
+func (r TextRange) contains(other TextRange) bool {
+	return r.Start <= other.Start && r.End >= other.End
+}
+
+func (r TextRange) same(other TextRange) bool {
+	return r.Start == other.Start && r.End == other.End
+}
+
+// ExpandSelection grows sel to the smallest element of the document that
+// encloses it: word, inline element, sentence, block, section and finally the
+// whole document. An empty sel is the cursor position.
+func (smp *SharedMarkdownProcessor) ExpandSelection(content string, sel TextRange) (TextRange, bool) {
+	var best TextRange
+	found := false
+	for _, candidate := range smp.selectionRanges(content, sel.Start) {
+		if !candidate.contains(sel) || candidate.End-candidate.Start <= sel.End-sel.Start {
+			continue
+		}
+		if !found || candidate.End-candidate.Start < best.End-best.Start {
+			best = candidate
+			found = true
+		}
+	}
+	return best, found
+}
+
+// selectionRanges lists every element around pos, innermost first.
+func (smp *SharedMarkdownProcessor) selectionRanges(content string, pos int) []TextRange {
+	var ranges []TextRange
+
+	lineStart := strings.LastIndex(content[:pos], "\n") + 1
+	lineEnd := len(content)
+	if i := strings.Index(content[pos:], "\n"); i >= 0 {
+		lineEnd = pos + i
+	}
+	for _, loc := range selectionWordRe.FindAllStringIndex(content[lineStart:lineEnd], -1) {
+		ranges = append(ranges, TextRange{lineStart + loc[0], lineStart + loc[1], "word"})
+	}
+
+	doc, _ := smp.Parse(content)
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || n == doc {
+			return ast.WalkContinue, nil
+		}
+		ranges = append(ranges, nodeSelections(n, content)...)
+		return ast.WalkContinue, nil
+	})
+
+	// Sections run from a heading to the next heading of the same or a
+	// higher level
+	headings := smp.Outline(content)
+	lineOffsets := []int{0}
+	for i, c := range content {
+		if c == '\n' {
+			lineOffsets = append(lineOffsets, i+1)
+		}
+	}
+	for i, heading := range headings {
+		end := len(content)
+		for _, next := range headings[i+1:] {
+			if next.Level <= heading.Level {
+				end = lineOffsets[next.Line]
+				break
+			}
+		}
+		ranges = append(ranges, trimRange(content, TextRange{lineOffsets[heading.Line], end, "section"}))
+	}
+
+	ranges = append(ranges, trimRange(content, TextRange{0, len(content), "document"}))
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].End-ranges[i].Start < ranges[j].End-ranges[j].Start
+	})
+	return ranges
+}
+
+// nodeSelections returns the ranges a node offers: inline elements with and
+// without their markup, blocks from the start of their first line, and the
+// sentences of a paragraph.
+func nodeSelections(n ast.Node, content string) []TextRange {
+	inner, ok := nodeRange(n)
+	if !ok {
+		return nil
+	}
+
+	switch node := n.(type) {
+	case *ast.Emphasis:
+		kind := "emphasis"
+		if node.Level == 2 {
+			kind = "strong"
+		}
+		outer := inner
+		for i := 0; i < node.Level && outer.Start > 0 && outer.End < len(content); i++ {
+			outer.Start--
+			outer.End++
+		}
+		return []TextRange{{inner.Start, inner.End, kind}, {outer.Start, outer.End, kind}}
+
+	case *east.Strikethrough:
+		outer := inner
+		for outer.Start > 0 && content[outer.Start-1] == '~' {
+			outer.Start--
+		}
+		for outer.End < len(content) && content[outer.End] == '~' {
+			outer.End++
+		}
+		return []TextRange{{inner.Start, inner.End, "strikethrough"}, {outer.Start, outer.End, "strikethrough"}}
+
+	case *ast.CodeSpan:
+		// The padding space of `` `x` `` belongs to the markup
+		start := inner.Start
+		for start > 0 && content[start-1] == ' ' {
+			start--
+		}
+		for start > 0 && content[start-1] == '`' {
+			start--
+		}
+		end := inner.End
+		for end < len(content) && content[end] == ' ' {
+			end++
+		}
+		for end < len(content) && content[end] == '`' {
+			end++
+		}
+		return []TextRange{{inner.Start, inner.End, "code span"}, {start, end, "code span"}}
+
+	case *ast.Link, *ast.Image:
+		kind := "link"
+		if _, image := n.(*ast.Image); image {
+			kind = "image"
+		}
+		outer := inner
+		if outer.Start > 0 && content[outer.Start-1] == '[' {
+			outer.Start--
+		}
+		if kind == "image" && outer.Start > 0 && content[outer.Start-1] == '!' {
+			outer.Start--
+		}
+		outer.End = linkEnd(content, outer.End)
+		return []TextRange{{inner.Start, inner.End, kind}, {outer.Start, outer.End, kind}}
+
+	case *ast.Paragraph:
+		inner = trimRange(content, inner)
+		ranges := []TextRange{{inner.Start, inner.End, "paragraph"}}
+		for _, bounds := range sentenceBounds(content[inner.Start:inner.End]) {
+			ranges = append(ranges, TextRange{inner.Start + bounds[0], inner.Start + bounds[1], "sentence"})
+		}
+		return ranges
+
+	case *ast.FencedCodeBlock:
+		// Take in the fence lines around the code
+		start := strings.LastIndex(content[:max(inner.Start-1, 0)], "\n") + 1
+		end := inner.End
+		if i := strings.Index(content[min(end+1, len(content)):], "\n"); i >= 0 {
+			end = end + 1 + i
+		} else {
+			end = len(content)
+		}
+		return []TextRange{{inner.Start, inner.End, "code"}, trimRange(content, TextRange{start, end, "code block"})}
+	}
+
+	if n.Type() != ast.TypeBlock {
+		return nil
+	}
+	kinds := map[ast.NodeKind]string{
+		ast.KindHeading:    "heading",
+		ast.KindListItem:   "list item",
+		ast.KindList:       "list",
+		ast.KindBlockquote: "quote",
+		ast.KindCodeBlock:  "code block",
+		ast.KindHTMLBlock:  "html",
+		east.KindTable:     "table",
+		east.KindTableRow:  "table row",
+	}
+	kind, ok := kinds[n.Kind()]
+	if !ok {
+		return nil
+	}
+	// Blocks start at the beginning of the line to include list and quote
+	// markers
+	start := strings.LastIndex(content[:inner.Start], "\n") + 1
+	return []TextRange{trimRange(content, TextRange{start, inner.End, kind})}
+}
+
+// nodeRange spans the source of a node: the lines of a leaf block, or the
+// text of all descendants.
+func nodeRange(n ast.Node) (TextRange, bool) {
+	if n.Type() == ast.TypeBlock && n.Lines().Len() > 0 {
+		lines := n.Lines()
+		return TextRange{Start: lines.At(0).Start, End: lines.At(lines.Len() - 1).Stop}, true
+	}
+	if text, ok := n.(*ast.Text); ok {
+		return TextRange{Start: text.Segment.Start, End: text.Segment.Stop}, true
+	}
+
+	var r TextRange
+	found := false
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		child, ok := nodeRange(c)
+		if !ok {
+			continue
+		}
+		if !found {
+			r = child
+			found = true
+			continue
+		}
+		r.Start = min(r.Start, child.Start)
+		r.End = max(r.End, child.End)
+	}
+	return r, found
+}
+
+// linkEnd finds the end of the destination or reference that follows the
+// text of a link ending at end.
+func linkEnd(content string, end int) int {
+	if end >= len(content) || content[end] != ']' {
+		return end
+	}
+	end++
+	if end >= len(content) {
+		return end
+	}
+	closer := map[byte]byte{'(': ')', '[': ']'}[content[end]]
+	if closer == 0 {
+		return end
+	}
+	depth := 0
+	for i := end; i < len(content); i++ {
+		switch content[i] {
+		case content[end]:
+			depth++
+		case closer:
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '\n':
+			return end
+		}
+	}
+	return end
+}
+
+func trimRange(content string, r TextRange) TextRange {
+	text := content[r.Start:r.End]
+	r.End = r.Start + len(strings.TrimRightFunc(text, unicode.IsSpace))
+	return r
+}
+
+// runeOffset converts a row and column in runes, as the editors report the
+// cursor, to a byte offset into content.
+func runeOffset(content string, row, col int) int {
+	offset := 0
+	for i := 0; i < row; i++ {
+		next := strings.Index(content[offset:], "\n")
+		if next < 0 {
+			return len(content)
+		}
+		offset += next + 1
+	}
+	for i := 0; i < col && offset < len(content) && content[offset] != '\n'; i++ {
+		_, size := utf8.DecodeRuneInString(content[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// rowColumn is the inverse of runeOffset.
+func rowColumn(content string, offset int) (int, int) {
+	before := content[:offset]
+	row := strings.Count(before, "\n")
+	return row, utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:])
+}
+
+// cursorOffset is the byte offset of the terminal editor's cursor.
+func (m *model) cursorOffset() int {
+	info := m.textarea.LineInfo()
+	return runeOffset(m.textarea.Value(), m.textarea.Line(), info.StartColumn+info.ColumnOffset)
+}
+
+// expandSelection grows the selection one step. The textarea cannot
+// highlight text, so the selection is shown in the status line and the
+// cursor moves to its end.
+func (m *model) expandSelection() {
+	content := m.textarea.Value()
+	current := TextRange{Start: m.cursorOffset(), End: m.cursorOffset()}
+	if m.selection != nil {
+		current = *m.selection
+	}
+	next, ok := m.mdProcessor.ExpandSelection(content, current)
+	if !ok {
+		return
+	}
+	if m.selection != nil {
+		m.selections = append(m.selections, current)
+	}
+	m.selectRange(next)
+}
+
+// shrinkSelection goes back to the previous, smaller selection.
+func (m *model) shrinkSelection() {
+	if len(m.selections) == 0 {
+		m.clearSelection()
+		m.status = ""
+		return
+	}
+	previous := m.selections[len(m.selections)-1]
+	m.selections = m.selections[:len(m.selections)-1]
+	m.selectRange(previous)
+}
+
+func (m *model) selectRange(r TextRange) {
+	content := m.textarea.Value()
+	m.selection = &r
+	row, col := rowColumn(content, r.End)
+	moveCursorTo(&m.textarea, row, col)
+	m.status = fmt.Sprintf("Selected %s: %s", r.Kind, truncate(strings.Join(strings.Fields(content[r.Start:r.End]), " "), 40))
+}
+
+func (m *model) clearSelection() {
+	m.selection = nil
+	m.selections = nil
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultServeAddr = "localhost:4000"
+
+// serveReloadScript swaps in the new page body whenever the server reports a
+// change, keeping the scroll position.
+const serveReloadScript = `<script>
+new EventSource("/events").onmessage = function() {
+  fetch("/").then(function(response) { return response.text(); }).then(function(page) {
+    var y = window.scrollY;
+    document.body.innerHTML = new DOMParser().parseFromString(page, "text/html").body.innerHTML;
+    window.scrollTo(0, y);
+  });
+};
+</script>
+`
+
+// PreviewServer serves the rendered document over HTTP and tells connected
+// browsers to refresh through server-sent events whenever it changes. Of the
+// other local files only those the document links to or shows are served,
+// so relative links and images work. Requests must name this machine as
+// their host, which keeps pages from other sites that resolve their own
+// name to it from reading the document.
+type PreviewServer struct {
+	opts  ExportOptions
+	inbox InboxConfig
+	host  string
+	port  string
+
+	mu      sync.Mutex
+	path    string
+	content string
+	clients map[chan struct{}]bool
+}
+
+func NewPreviewServer(opts ExportOptions) *PreviewServer {
+	return &PreviewServer{
+		opts:    opts,
+		clients: map[chan struct{}]bool{},
+	}
+}
+
+// StartPreviewServer listens on addr and serves in the background. Listening
+// happens right away so that a busy port is reported before the editor
+// starts. inbox turns on /inbox when it has a token, and only with a token
+// may addr be reachable from other machines.
+func StartPreviewServer(addr string, opts ExportOptions, inbox InboxConfig) (*PreviewServer, string, error) {
+	if inbox.Token == "" && !loopbackAddr(addr) {
+		return nil, "", fmt.Errorf("error starting preview server: %s is reachable from other machines, set a token in the [inbox] table first", addr)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, "", fmt.Errorf("error starting preview server: %v", err)
+	}
+	server := NewPreviewServer(opts)
+	server.inbox = inbox
+	server.host, _, _ = net.SplitHostPort(addr)
+	server.host = strings.ToLower(server.host)
+	_, server.port, _ = net.SplitHostPort(listener.Addr().String())
+	go http.Serve(listener, server)
+	return server, "http://" + listener.Addr().String(), nil
+}
+
+// Update replaces the document being served. Browsers are only told to
+// refresh when something changed.
+func (s *PreviewServer) Update(path, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if path == s.path && content == s.content {
+		return
+	}
+	s.path = path
+	s.content = content
+	for client := range s.clients {
+		select {
+		case client <- struct{}{}:
+		default:
+			// A refresh is already pending for this client
+		}
+	}
+}
+
+func (s *PreviewServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.allowedHost(r.Host) {
+		http.Error(w, "unknown host", http.StatusMisdirectedRequest)
+		return
+	}
+	switch r.URL.Path {
+	case "/":
+		s.servePage(w)
+	case "/events":
+		s.serveEvents(w, r)
+	case "/inbox":
+		s.serveInbox(w, r)
+	default:
+		s.serveLinked(w, r)
+	}
+}
+
+// loopbackAddr tells whether addr only listens on this machine.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// allowedHost tells whether a request for hostport is meant for this
+// server: its port, and a name or address of this machine.
+func (s *PreviewServer) allowedHost(hostport string) bool {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host, port = hostport, "80"
+	}
+	if port != s.port {
+		return false
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || (host == s.host && net.ParseIP(host) == nil) {
+		return true
+	}
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		if ip.IsLoopback() {
+			return true
+		}
+		addrs, _ := net.InterfaceAddrs()
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	name, err := os.Hostname()
+	if err != nil {
+		return false
+	}
+	name = strings.ToLower(name)
+	short, _, _ := strings.Cut(name, ".")
+	return host == name || host == short || host == short+".local"
+}
+
+// serveLinked serves a local file the document links to or shows; the rest
+// of its directory is not shared.
+func (s *PreviewServer) serveLinked(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	doc, content := s.path, s.content
+	s.mu.Unlock()
+	if isOrgFile(doc) {
+		content = OrgToMarkdown(content)
+	}
+	dir := "."
+	if doc != "" {
+		dir = filepath.Dir(doc)
+	}
+	name := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(path.Clean(r.URL.Path), "/")))
+	for _, file := range s.opts.processor().linkedFiles(doc, content, true) {
+		if filepath.Clean(file) == name {
+			http.ServeFile(w, r, name)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func (s *PreviewServer) servePage(w http.ResponseWriter) {
+	s.mu.Lock()
+	path, content := s.path, s.content
+	s.mu.Unlock()
+
+	opts := s.opts
+	opts.SourcePath = path
+	opts.Title = "untitled.md"
+	if path != "" {
+		opts.Title = filepath.Base(path)
+	}
+	if isOrgFile(path) {
+		content = OrgToMarkdown(content)
+	}
+	page, err := ExportHTML(ExpandVars(content, time.Now()), opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(strings.Replace(string(page), "</body>", serveReloadScript+"</body>", 1)))
+}
+
+func (s *PreviewServer) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	client := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.clients[client] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, client)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-client:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", defaultServeAddr, "address to listen on")
+	theme := fs.String("theme", "", "page theme: light, dark or a .css file")
+	fs.StringVar(&ActiveProfile, "profile", "", "configuration profile to use")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: parselt serve [-addr host:port] [-theme name] file.md")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one input file")
+	}
+
+	input := fs.Arg(0)
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+	cfg, err := LoadConfigFor(input)
+	if err != nil {
+		return err
+	}
+	opts := cfg.ExportOptions(input)
+	if *theme != "" {
+		opts.Theme = *theme
+	}
+	if _, err := htmlThemeCSS(opts.Theme); err != nil {
+		return err
+	}
+
+	watcher, err := NewFileWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Watch(input); err != nil {
+		return err
+	}
+
+	server, url, err := StartPreviewServer(*addr, opts, cfg.Inbox)
+	if err != nil {
+		return err
+	}
+	server.Update(input, string(data))
+	fmt.Printf("Serving %s at %s (ctrl+c to stop)\n", input, url)
+
+	for range watcher.Changes() {
+		if data, err := os.ReadFile(input); err == nil {
+			server.Update(input, string(data))
+		}
+	}
+	return nil
+}
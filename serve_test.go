@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestServer serves notes.md, which shows one image of its directory and
+// links to nothing else there, on localhost:4000.
+func newTestServer(t *testing.T, token string) *PreviewServer {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	for name, content := range map[string]string{
+		"notes.md":   "# Notes\n\n![map](map.png)\n",
+		"map.png":    "png",
+		"secret.txt": "hunter2",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := NewPreviewServer(DefaultConfig().ExportOptions("notes.md"))
+	server.inbox = InboxConfig{File: filepath.Join(dir, "inbox.md"), Token: token}
+	server.host, server.port = "localhost", "4000"
+	server.Update(filepath.Join(dir, "notes.md"), "# Notes\n\n![map](map.png)\n")
+	return server
+}
+
+func serve(server *PreviewServer, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	return w
+}
+
+func TestServeOnlyLinkedFiles(t *testing.T) {
+	server := newTestServer(t, "")
+
+	for path, want := range map[string]int{
+		"/":           http.StatusOK,
+		"/map.png":    http.StatusOK,
+		"/secret.txt": http.StatusNotFound,
+		"/notes.md":   http.StatusNotFound,
+	} {
+		if got := serve(server, httptest.NewRequest("GET", "http://localhost:4000"+path, nil)).Code; got != want {
+			t.Errorf("GET %s = %d, want %d", path, got, want)
+		}
+	}
+}
+
+func TestServeRejectsOtherHosts(t *testing.T) {
+	server := newTestServer(t, "")
+
+	for host, want := range map[string]int{
+		"localhost:4000":     http.StatusOK,
+		"127.0.0.1:4000":     http.StatusOK,
+		"evil.example:4000":  http.StatusMisdirectedRequest,
+		"localhost:4001":     http.StatusMisdirectedRequest,
+		"203.0.113.200:4000": http.StatusMisdirectedRequest,
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Host = host
+		if got := serve(server, r).Code; got != want {
+			t.Errorf("GET / on %s = %d, want %d", host, got, want)
+		}
+	}
+}
+
+func TestServeRefusesOpenAddressWithoutToken(t *testing.T) {
+	if _, _, err := StartPreviewServer("0.0.0.0:0", ExportOptions{}, InboxConfig{}); err == nil {
+		t.Error("listened on every interface without an inbox token")
+	}
+}
+
+func TestInboxToken(t *testing.T) {
+	server := newTestServer(t, "s3cret")
+
+	const inbox = "http://localhost:4000/inbox"
+	const form = "application/x-www-form-urlencoded"
+	for _, c := range []struct {
+		name, url, contentType, body, bearer string
+		want                                 int
+	}{
+		{"bearer", inbox, "text/plain", "idea", "s3cret", http.StatusCreated},
+		{"wrong bearer", inbox, "text/plain", "idea", "guess", http.StatusUnauthorized},
+		{"no token", inbox, "text/plain", "idea", "", http.StatusUnauthorized},
+		{"form token", inbox, form, "token=s3cret&text=idea", "", http.StatusCreated},
+		{"wrong form token", inbox, form, "token=guess&text=idea", "", http.StatusUnauthorized},
+		{"query token", inbox + "?token=s3cret", form, "text=idea", "", http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest("POST", c.url, strings.NewReader(c.body))
+		r.Header.Set("Content-Type", c.contentType)
+		if c.bearer != "" {
+			r.Header.Set("Authorization", "Bearer "+c.bearer)
+		}
+		if got := serve(server, r).Code; got != c.want {
+			t.Errorf("%s: %d, want %d", c.name, got, c.want)
+		}
+	}
+}
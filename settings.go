@@ -0,0 +1,265 @@
+package main
+
+import (
+	"archive/zip"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"github.com/BurntSushi/toml"
+)
+
+// settingsBundleFile is the default name of an exported settings bundle.
+const settingsBundleFile = "parselt-settings.zip"
+
+// settingsFile tells whether name, a slash separated path relative to the
+// config directory, belongs in a settings bundle: the config file with its
+// keys, colors and profiles, and the export templates. What parselt
+// remembers on its own, such as file positions, stays on each machine.
+// Names that could reach outside the config directory on any system, with
+// a backslash, a .. element or a drive, never belong.
+func settingsFile(name string) bool {
+	if strings.Contains(name, `\`) || slices.Contains(strings.Split(name, "/"), "..") || !filepath.IsLocal(filepath.FromSlash(name)) {
+		return false
+	}
+	if name == "config.toml" {
+		return true
+	}
+	dir, file := path.Split(name)
+	return dir == "templates/" && file != "" && !strings.ContainsAny(file, `/\:`) && strings.HasSuffix(file, ".tmpl")
+}
+
+// ExportSettings writes the settings in configDir to a zip archive at target
+// and returns the names of the files in it.
+func ExportSettings(configDir, target string) ([]string, error) {
+	var names []string
+	candidates := []string{"config.toml"}
+	templates, _ := filepath.Glob(filepath.Join(configDir, "templates", "*.tmpl"))
+	for _, template := range templates {
+		candidates = append(candidates, "templates/"+filepath.Base(template))
+	}
+
+	file, err := os.Create(target)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting settings: %v", err)
+	}
+	defer file.Close()
+	archive := zip.NewWriter(file)
+	for _, name := range candidates {
+		data, err := os.ReadFile(filepath.Join(configDir, filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error exporting settings: %v", err)
+		}
+		w, err := archive.Create(name)
+		if err != nil {
+			return nil, fmt.Errorf("error exporting settings: %v", err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("error exporting settings: %v", err)
+		}
+		names = append(names, name)
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("error exporting settings: %v", err)
+	}
+	if len(names) == 0 {
+		os.Remove(target)
+		return nil, fmt.Errorf("error exporting settings: nothing to export in %s", configDir)
+	}
+	return names, nil
+}
+
+// ImportSettings unpacks a bundle made by ExportSettings into configDir.
+// Files it replaces are kept with a .bak suffix. Anything in the archive that
+// is not a setting is skipped, and a config file that does not parse stops
+// the import before anything is written.
+func ImportSettings(configDir, source string) ([]string, error) {
+	archive, err := zip.OpenReader(source)
+	if err != nil {
+		return nil, fmt.Errorf("error importing settings: %v", err)
+	}
+	defer archive.Close()
+
+	files := map[string][]byte{}
+	for _, entry := range archive.File {
+		if !settingsFile(entry.Name) {
+			continue
+		}
+		r, err := entry.Open()
+		if err != nil {
+			return nil, fmt.Errorf("error importing settings: %v", err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error importing settings: %v", err)
+		}
+		files[entry.Name] = data
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("error importing settings: %s holds no settings", source)
+	}
+	if data, ok := files["config.toml"]; ok {
+		if _, err := toml.Decode(string(data), DefaultConfig()); err != nil {
+			return nil, fmt.Errorf("error importing settings: config.toml: %v", err)
+		}
+	}
+
+	order := make([]string, 0, len(files))
+	for name := range files {
+		order = append(order, name)
+	}
+	sort.Strings(order)
+
+	var names []string
+	for _, name := range order {
+		data := files[name]
+		target := filepath.Join(configDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return names, fmt.Errorf("error importing settings: %v", err)
+		}
+		if current, err := os.ReadFile(target); err == nil {
+			if string(current) == string(data) {
+				continue
+			}
+			if err := os.WriteFile(target+".bak", current, 0644); err != nil {
+				return names, fmt.Errorf("error importing settings: %v", err)
+			}
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return names, fmt.Errorf("error importing settings: %v", err)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func runSettings(args []string) error {
+	usage := func(output io.Writer) {
+		fmt.Fprintln(output, "Usage: parselt settings export [-o bundle.zip]")
+		fmt.Fprintln(output, "       parselt settings import bundle.zip")
+	}
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return fmt.Errorf("expected export or import")
+	}
+	configPath := ConfigPath()
+	if configPath == "" {
+		return fmt.Errorf("no config directory on this system")
+	}
+	configDir := filepath.Dir(configPath)
+
+	fs := flag.NewFlagSet("settings "+args[0], flag.ContinueOnError)
+	fs.Usage = func() {
+		usage(fs.Output())
+		fs.PrintDefaults()
+	}
+	switch args[0] {
+	case "export":
+		output := fs.String("o", settingsBundleFile, "archive to write")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		names, err := ExportSettings(configDir, *output)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Exported %s to %s\n", strings.Join(names, ", "), *output)
+	case "import":
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return fmt.Errorf("expected exactly one bundle")
+		}
+		names, err := ImportSettings(configDir, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			fmt.Println("Settings are already up to date")
+			return nil
+		}
+		fmt.Printf("Imported %s into %s; replaced files were kept as .bak\n", strings.Join(names, ", "), configDir)
+	default:
+		usage(os.Stderr)
+		return fmt.Errorf("unknown settings command %q", args[0])
+	}
+	return nil
+}
+
+func (g *GUIApp) exportSettings() {
+	configPath := ConfigPath()
+	if configPath == "" {
+		dialog.ShowError(fmt.Errorf("no config directory on this system"), g.window)
+		return
+	}
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		writer.Close()
+
+		names, err := ExportSettings(filepath.Dir(configPath), uriPath(writer.URI()))
+		if err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+		dialog.ShowInformation("Settings Exported",
+			fmt.Sprintf("Exported %s to %s", strings.Join(names, ", "), uriPath(writer.URI())), g.window)
+	}, g.window)
+	saveDialog.SetFileName(settingsBundleFile)
+	saveDialog.Show()
+}
+
+// importSettings unpacks a settings bundle and applies it right away.
+func (g *GUIApp) importSettings() {
+	configPath := ConfigPath()
+	if configPath == "" {
+		dialog.ShowError(fmt.Errorf("no config directory on this system"), g.window)
+		return
+	}
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		reader.Close()
+
+		names, err := ImportSettings(filepath.Dir(configPath), uriPath(reader.URI()))
+		if err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+		if len(names) == 0 {
+			dialog.ShowInformation("Settings Imported", "Settings are already up to date", g.window)
+			return
+		}
+		g.loadConfig()
+		g.updatePreview(g.editor.Text)
+		dialog.ShowInformation("Settings Imported",
+			fmt.Sprintf("Imported %s; replaced files were kept as .bak", strings.Join(names, ", ")), g.window)
+	}, g.window)
+	openDialog.SetFilter(storage.NewExtensionFileFilter([]string{".zip"}))
+	openDialog.Show()
+}
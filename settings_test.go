@@ -0,0 +1,56 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestImportSettingsStaysInConfigDir imports a bundle whose names try to
+// reach outside the config directory, which only the real settings get past.
+func TestImportSettingsStaysInConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	configDir := filepath.Join(dir, "parselt")
+	bundle := filepath.Join(dir, "bundle.zip")
+
+	file, err := os.Create(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := zip.NewWriter(file)
+	for _, name := range []string{
+		"config.toml",
+		"templates/post.tmpl",
+		"../evil.tmpl",
+		"templates/../../evil.tmpl",
+		`templates/..\..\evil.tmpl`,
+		`templates\evil.tmpl`,
+		"templates/C:evil.tmpl",
+		"/templates/evil.tmpl",
+	} {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("flavor = \"gfm\"\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	names, err := ImportSettings(configDir, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"config.toml", "templates/post.tmpl"}; !slices.Equal(names, want) {
+		t.Errorf("imported %q, want %q", names, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil.tmpl")); err == nil {
+		t.Error("the import wrote outside the config directory")
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+)
+
+// SnippetConfig is the [snippet] table: how code blocks look as snippet
+// images. Background is the color around the window, or "transparent";
+// Padding is how much of it shows, in pixels. Font is a TrueType or
+// OpenType file to draw the code in instead of Go Mono. Chrome draws the
+// title bar of a window, with its three buttons.
+type SnippetConfig struct {
+	Theme       string  `toml:"theme"`
+	Background  string  `toml:"background"`
+	Padding     int     `toml:"padding"`
+	Font        string  `toml:"font"`
+	FontSize    float64 `toml:"font_size"`
+	Chrome      bool    `toml:"chrome"`
+	LineNumbers bool    `toml:"line_numbers"`
+}
+
+func (c SnippetConfig) fontPath() string {
+	return expandHome(c.Font)
+}
+
+const (
+	snippetInset  = 20 // between the edge of the window and the code
+	snippetBar    = 36
+	snippetRadius = 10
+)
+
+// snippetButtons are the close, minimize and zoom buttons of the chrome.
+var snippetButtons = []string{"#FF5F56", "#FFBD2E", "#27C93F"}
+
+// RenderSnippet draws code as a PNG of an editor window floating on a
+// backdrop, highlighted as lang.
+func RenderSnippet(code, lang string, cfg SnippetConfig) ([]byte, error) {
+	cardMu.Lock()
+	defer cardMu.Unlock()
+
+	c := &cardLayout{theme: cardThemeFor(cfg.Theme)}
+	if cfg.Font != "" {
+		data, err := os.ReadFile(cfg.fontPath())
+		if err != nil {
+			return nil, fmt.Errorf("error reading snippet font: %v", err)
+		}
+		if c.mono, err = opentype.Parse(data); err != nil {
+			return nil, fmt.Errorf("error reading snippet font %s: %v", cfg.Font, err)
+		}
+	}
+	size := cfg.FontSize
+	if size <= 0 {
+		size = 14
+	}
+	pad := float64(max(cfg.Padding, 0))
+
+	tokens, ok := (&SharedMarkdownProcessor{}).Highlight(code, lang)
+	if !ok {
+		tokens = []HighlightToken{{Text: code}}
+	}
+	lines := [][]HighlightToken{nil}
+	columns := 0
+	width := 0
+	for _, token := range tokens {
+		for i, part := range strings.Split(strings.ReplaceAll(token.Text, "\t", "    "), "\n") {
+			if i > 0 {
+				lines = append(lines, nil)
+				width = 0
+			}
+			if part != "" {
+				lines[len(lines)-1] = append(lines[len(lines)-1], HighlightToken{Text: part, Class: token.Class})
+				width += len([]rune(part))
+				columns = max(columns, width)
+			}
+		}
+	}
+
+	advance := float64(font.MeasureString(c.face(cardMono, size), "0")) / 64
+	lineHeight := math.Round(size * 1.5)
+	gutter := 0.0
+	digits := len(strconv.Itoa(len(lines)))
+	if cfg.LineNumbers {
+		gutter = float64(digits+2) * advance
+	}
+	bar := 0.0
+	if cfg.Chrome {
+		bar = snippetBar
+	}
+	winW := math.Ceil(max(float64(columns)*advance+gutter+2*snippetInset, 320))
+	winH := math.Ceil(bar + 2*snippetInset + float64(len(lines))*lineHeight)
+	if cfg.Chrome {
+		winH -= snippetInset / 2
+	}
+	c.width, c.y = winW+2*pad, winH+2*pad
+
+	if background := cfg.Background; background != "transparent" {
+		if background == "" {
+			background = c.theme.Frame
+		}
+		c.boxes = append(c.boxes, cardBox{0, 0, c.width, c.y, 0, background})
+	}
+	// A shadow blurred by stacking faint ones, each a little larger
+	for i := min(pad, 12); i >= 1; i -= 2 {
+		c.boxes = append(c.boxes, cardBox{pad - i, pad + 10 - i, winW + 2*i, winH + 2*i, snippetRadius + i, "#0000000D"})
+	}
+	c.boxes = append(c.boxes, cardBox{pad, pad, winW, winH, snippetRadius, c.theme.Background})
+	if cfg.Chrome {
+		for i, color := range snippetButtons {
+			c.boxes = append(c.boxes, cardBox{pad + 14 + float64(i)*20, pad + bar/2 - 6, 12, 12, 6, color})
+		}
+	}
+
+	top := pad + bar + snippetInset
+	if cfg.Chrome {
+		top -= snippetInset / 2
+	}
+	left := pad + snippetInset
+	for row, line := range lines {
+		baseline := top + float64(row)*lineHeight + (lineHeight+size*0.7)/2
+		if cfg.LineNumbers {
+			number := strconv.Itoa(row + 1)
+			c.runs = append(c.runs, cardRun{X: left + float64(digits-len(number))*advance, Y: baseline,
+				Text: number, Font: cardMono, Size: size, Color: c.theme.Muted})
+		}
+		col := 0
+		for _, token := range line {
+			if strings.TrimSpace(token.Text) != "" {
+				color := c.theme.Syntax[token.Class]
+				if color == "" {
+					color = c.theme.CodeText
+				}
+				c.runs = append(c.runs, cardRun{X: left + gutter + float64(col)*advance, Y: baseline,
+					Text: token.Text, Font: cardMono, Size: size, Color: color})
+			}
+			col += len([]rune(token.Text))
+		}
+	}
+	return c.png()
+}
+
+// copyImageToClipboard puts the PNG file at path on the clipboard. Outside
+// Windows and macOS it needs wl-copy or xclip.
+func copyImageToClipboard(path string) error {
+	var cmd *exec.Cmd
+	tool := ""
+	switch {
+	case runtime.GOOS == "windows":
+		cmd = exec.Command("powershell", "-NoProfile", "-Command",
+			"Add-Type -AssemblyName System.Windows.Forms; Add-Type -AssemblyName System.Drawing; "+
+				"[System.Windows.Forms.Clipboard]::SetImage([System.Drawing.Image]::FromFile('"+strings.ReplaceAll(path, "'", "''")+"'))")
+	case runtime.GOOS == "darwin":
+		cmd = exec.Command("osascript", "-e", fmt.Sprintf("set the clipboard to (read (POSIX file %q) as «class PNGf»)", path))
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		cmd, tool = exec.Command("wl-copy", "--type", "image/png"), "wl-copy"
+		in, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("error copying the image: %v", err)
+		}
+		defer in.Close()
+		cmd.Stdin = in
+	default:
+		cmd, tool = exec.Command("xclip", "-selection", "clipboard", "-target", "image/png", "-in", path), "xclip"
+	}
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("copying images to the clipboard needs %s", tool)
+	}
+	if err != nil {
+		return fmt.Errorf("error copying the image: %v", err)
+	}
+	return nil
+}
+
+// snippetImage draws the code block under the cursor as a snippet image,
+// saves it next to the document and copies it to the clipboard.
+func (m *model) snippetImage() {
+	content := m.textarea.Value()
+	fence, ok := codeFenceAt(content, m.cursorOffset())
+	if m.mode == previewMode && m.scrollMap != nil {
+		fence, ok = m.previewCodeFence(content)
+	}
+	if !ok {
+		m.status = "No code block here"
+		return
+	}
+	code := fence.body(content)
+	m.guardSecrets(code, func(m *model) {
+		data, err := RenderSnippet(code, fenceLanguage(fence.Lang), m.snippet)
+		if err != nil {
+			m.status = err.Error()
+			return
+		}
+		path := cardPath(m.filename, "snippet")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			m.status = fmt.Sprintf("error writing snippet image: %v", err)
+			return
+		}
+		if err := copyImageToClipboard(path); err != nil {
+			m.status = fmt.Sprintf("Saved the snippet image as %s, but %v", path, err)
+			return
+		}
+		m.status = fmt.Sprintf("Copied the snippet image, saved as %s", path)
+	})
+}
+
+// snippetImage copies the code block under the cursor to the clipboard as
+// a snippet image, or offers to save it where that cannot be done.
+func (g *GUIApp) snippetImage() {
+	content := g.editor.Text
+	start, _ := g.selectedRange()
+	fence, ok := codeFenceAt(content, len(string([]rune(content)[:start])))
+	if !ok {
+		dialog.ShowInformation("Snippet Image", "The cursor is not in a fenced code block.", g.window)
+		return
+	}
+	code := fence.body(content)
+	g.guardSecrets(code, func() { g.copySnippet(code, fenceLanguage(fence.Lang)) })
+}
+
+// copySnippet draws code, highlighted as lang, and copies it.
+func (g *GUIApp) copySnippet(code, lang string) {
+	data, err := RenderSnippet(code, lang, g.config.Snippet)
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+
+	copyErr := func() error {
+		file, err := os.CreateTemp("", "parselt-snippet-*.png")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(file.Name())
+		_, err = file.Write(data)
+		file.Close()
+		if err != nil {
+			return err
+		}
+		return copyImageToClipboard(file.Name())
+	}()
+	if copyErr == nil {
+		dialog.ShowInformation("Snippet Image", "Copied the code block to the clipboard as an image.", g.window)
+		return
+	}
+
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, g.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+		if _, err := writer.Write(data); err != nil {
+			dialog.ShowError(err, g.window)
+		}
+	}, g.window)
+	saveDialog.SetFileName("snippet.png")
+	dialog.ShowConfirm("Snippet Image", fmt.Sprintf("The image could not be copied: %v. Save it to a file instead?", copyErr), func(save bool) {
+		if save {
+			saveDialog.Show()
+		}
+	}, g.window)
+}
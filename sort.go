@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type SortMode int
+
+const (
+	SortAlphabetical SortMode = iota
+	SortNumeric
+	SortReverse
+	SortUnique
+)
+
+// sortCommand is one entry of the sort menu. List commands work on the list
+// under the cursor, the others on the selected lines.
+type sortCommand struct {
+	title string
+	list  bool
+	mode  SortMode
+}
+
+var sortCommands = []sortCommand{
+	{"Sort List Alphabetically", true, SortAlphabetical},
+	{"Sort List Numerically", true, SortNumeric},
+	{"Reverse List", true, SortReverse},
+	{"Remove Duplicate List Items", true, SortUnique},
+	{"Sort Lines Alphabetically", false, SortAlphabetical},
+	{"Sort Lines Numerically", false, SortNumeric},
+	{"Reverse Lines", false, SortReverse},
+	{"Remove Duplicate Lines", false, SortUnique},
+}
+
+var (
+	sortListItemRe = regexp.MustCompile(`^(\s*)([-+*]|(\d+)([.)]))(\s+|$)`)
+	sortTaskRe     = regexp.MustCompile(`^\[[ xX]\]\s+`)
+	sortNumberRe   = regexp.MustCompile(`^[-+]?(\d+(\.\d*)?|\.\d+)`)
+)
+
+// sortEntry is a line, or a list item together with its nested children,
+// and the text it is compared by.
+type sortEntry struct {
+	lines []string
+	key   string
+}
+
+// orderEntries applies mode to entries. Sorting is stable and ignores case;
+// numeric sorting compares the leading number and puts entries without one
+// last.
+func orderEntries(entries []sortEntry, mode SortMode) []sortEntry {
+	ordered := append([]sortEntry(nil), entries...)
+	switch mode {
+	case SortAlphabetical:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return strings.ToLower(ordered[i].key) < strings.ToLower(ordered[j].key)
+		})
+	case SortNumeric:
+		number := func(key string) (float64, bool) {
+			n, err := strconv.ParseFloat(sortNumberRe.FindString(key), 64)
+			return n, err == nil
+		}
+		sort.SliceStable(ordered, func(i, j int) bool {
+			a, okA := number(ordered[i].key)
+			b, okB := number(ordered[j].key)
+			if okA != okB {
+				return okA
+			}
+			if !okA || a == b {
+				return strings.ToLower(ordered[i].key) < strings.ToLower(ordered[j].key)
+			}
+			return a < b
+		})
+	case SortReverse:
+		for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		}
+	case SortUnique:
+		seen := map[string]bool{}
+		unique := ordered[:0]
+		for _, entry := range ordered {
+			// Compared without list markers so renumbered items still match
+			text := strings.Join(append([]string{entry.key}, entry.lines[1:]...), "\n")
+			if !seen[text] {
+				seen[text] = true
+				unique = append(unique, entry)
+			}
+		}
+		ordered = unique
+	}
+	return ordered
+}
+
+// SortLines applies mode to lines, comparing them without leading
+// whitespace.
+func SortLines(lines []string, mode SortMode) []string {
+	entries := make([]sortEntry, len(lines))
+	for i, line := range lines {
+		entries[i] = sortEntry{lines: []string{line}, key: strings.TrimSpace(line)}
+	}
+	var sorted []string
+	for _, entry := range orderEntries(entries, mode) {
+		sorted = append(sorted, entry.lines...)
+	}
+	return sorted
+}
+
+// SortLineRange applies mode to lines start to end of content, inclusive.
+func SortLineRange(content string, start, end int, mode SortMode) string {
+	lines := strings.Split(content, "\n")
+	start = max(start, 0)
+	end = min(end, len(lines)-1)
+	if start >= end {
+		return content
+	}
+	sorted := SortLines(lines[start:end+1], mode)
+	result := append(append(append([]string{}, lines[:start]...), sorted...), lines[end+1:]...)
+	return strings.Join(result, "\n")
+}
+
+// ParagraphAt returns the first and last line of the block of non-blank
+// lines around line, which stands in for a selection where there is none.
+func ParagraphAt(content string, line int) (int, int) {
+	lines := strings.Split(content, "\n")
+	if line < 0 || line >= len(lines) || strings.TrimSpace(lines[line]) == "" {
+		return line, line
+	}
+	start, end := line, line
+	for start > 0 && strings.TrimSpace(lines[start-1]) != "" {
+		start--
+	}
+	for end < len(lines)-1 && strings.TrimSpace(lines[end+1]) != "" {
+		end++
+	}
+	return start, end
+}
+
+func indentWidth(line string) int {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// SortList applies mode to the items of the list under the cursor line. Only
+// the items at the level of the one under the cursor are reordered; nested
+// lists and continuation lines move along with their item. Ordered lists are
+// renumbered afterwards.
+func SortList(content string, line int, mode SortMode) (string, error) {
+	lines := strings.Split(content, "\n")
+	if line < 0 || line >= len(lines) {
+		return content, fmt.Errorf("no list under the cursor")
+	}
+
+	// Find the item the cursor line belongs to
+	item := -1
+	for i := line; i >= 0; i-- {
+		if sortListItemRe.MatchString(lines[i]) && (i == line || indentWidth(lines[i]) < indentWidth(lines[line]) || strings.TrimSpace(lines[line]) == "") {
+			item = i
+			break
+		}
+		if strings.TrimSpace(lines[i]) != "" && indentWidth(lines[i]) == 0 {
+			break
+		}
+	}
+	if item < 0 {
+		return content, fmt.Errorf("no list under the cursor")
+	}
+	indent := indentWidth(lines[item])
+	ordered := sortListItemRe.FindStringSubmatch(lines[item])[3] != ""
+	// A bullet list right after an ordered one, or the other way round, is a
+	// separate list
+	sibling := func(text string) bool {
+		match := sortListItemRe.FindStringSubmatch(text)
+		return match != nil && indentWidth(text) == indent && (match[3] != "") == ordered
+	}
+
+	belongs := func(i int) bool {
+		text := lines[i]
+		if strings.TrimSpace(text) == "" {
+			return true
+		}
+		if indentWidth(text) > indent {
+			return true
+		}
+		return sibling(text)
+	}
+	start, end := item, item
+	for start > 0 && belongs(start-1) {
+		start--
+	}
+	for end < len(lines)-1 && belongs(end+1) {
+		end++
+	}
+	for strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	for strings.TrimSpace(lines[end]) == "" {
+		end--
+	}
+	// Lines above the first sibling belong to an item further up
+	for start < item && !sibling(lines[start]) {
+		start++
+	}
+
+	var entries []sortEntry
+	loose := false
+	for i := start; i <= end; i++ {
+		text := lines[i]
+		if sibling(text) {
+			key := strings.TrimPrefix(text, sortListItemRe.FindString(text))
+			key = strings.TrimSpace(sortTaskRe.ReplaceAllString(key, ""))
+			entries = append(entries, sortEntry{lines: []string{text}, key: key})
+			continue
+		}
+		last := &entries[len(entries)-1]
+		last.lines = append(last.lines, text)
+	}
+	// Blank lines between items make the list loose; they are put back
+	// between the items after reordering
+	for i := range entries {
+		trimmed := len(entries[i].lines)
+		for trimmed > 1 && strings.TrimSpace(entries[i].lines[trimmed-1]) == "" {
+			trimmed--
+			if i < len(entries)-1 {
+				loose = true
+			}
+		}
+		entries[i].lines = entries[i].lines[:trimmed]
+	}
+
+	number := -1
+	if ordered {
+		number, _ = strconv.Atoi(sortListItemRe.FindStringSubmatch(lines[start])[3])
+	}
+	var sorted []string
+	for i, entry := range orderEntries(entries, mode) {
+		if loose && i > 0 {
+			sorted = append(sorted, "")
+		}
+		first := entry.lines[0]
+		if ordered {
+			match := sortListItemRe.FindStringSubmatch(first)
+			first = match[1] + strconv.Itoa(number+i) + match[4] + first[len(match[1])+len(match[2]):]
+		}
+		sorted = append(sorted, first)
+		sorted = append(sorted, entry.lines[1:]...)
+	}
+
+	result := append(append(append([]string{}, lines[:start]...), sorted...), lines[end+1:]...)
+	return strings.Join(result, "\n"), nil
+}
+
+func (m *model) openSort() {
+	items := make([]pickerItem, len(sortCommands))
+	for i, command := range sortCommands {
+		detail := "paragraph under the cursor"
+		if command.list {
+			detail = "list under the cursor"
+		}
+		items[i] = pickerItem{title: command.title, detail: detail, index: i}
+	}
+	m.overlay = overlaySort
+	m.picker = newPicker("Sort", items)
+}
+
+// applySort runs command at the cursor. The terminal editor has no
+// selection, so line commands work on the paragraph around the cursor.
+func (m *model) applySort(command sortCommand) {
+	content := m.textarea.Value()
+	info := m.textarea.LineInfo()
+	row, col := m.textarea.Line(), info.StartColumn+info.ColumnOffset
+
+	var sorted string
+	if command.list {
+		var err error
+		sorted, err = SortList(content, row, command.mode)
+		if err != nil {
+			m.status = err.Error()
+			return
+		}
+	} else {
+		start, end := ParagraphAt(content, row)
+		sorted = SortLineRange(content, start, end, command.mode)
+	}
+	if sorted == content {
+		m.status = "Already in order"
+		return
+	}
+
+	m.textarea.SetValue(sorted)
+	moveCursorTo(&m.textarea, row, col)
+	m.content = sorted
+	if m.mode != editMode {
+		m.refreshPreview()
+	}
+	m.status = command.title
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+const (
+	// wordsPerMinute is an average silent reading speed for prose.
+	wordsPerMinute = 230
+	statsDebounce  = 300 * time.Millisecond
+)
+
+// DocumentStats counts the parts of a document. Words are those of the
+// prose, headings and inline code included; code blocks and front matter
+// are left out, as nobody reads them at reading speed.
+type DocumentStats struct {
+	Words      int `json:"words"`
+	Characters int `json:"characters"`
+	// NonSpace leaves out the whitespace from Characters
+	NonSpace   int `json:"characters_no_spaces"`
+	Lines      int `json:"lines"`
+	Paragraphs int `json:"paragraphs"`
+	Headings   int `json:"headings"`
+	Links      int `json:"links"`
+	Images     int `json:"images"`
+	CodeBlocks int `json:"code_blocks"`
+	Tables     int `json:"tables"`
+	Tasks      int `json:"tasks"`
+	TasksDone  int `json:"tasks_done"`
+}
+
+type statsTickMsg struct {
+	seq int
+}
+
+// Stats counts the words and elements of content.
+func (smp *SharedMarkdownProcessor) Stats(content string) DocumentStats {
+	stats := DocumentStats{Characters: utf8.RuneCountInString(content)}
+	for _, r := range content {
+		if !unicode.IsSpace(r) {
+			stats.NonSpace++
+		}
+	}
+	if content != "" {
+		stats.Lines = strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
+	}
+
+	doc, source := smp.Parse(content)
+	var prose strings.Builder
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		if n.Type() == ast.TypeBlock {
+			// Words never run on from one block into the next
+			prose.WriteString("\n")
+		}
+		switch node := n.(type) {
+		case *ast.Heading:
+			stats.Headings++
+		case *ast.Paragraph:
+			stats.Paragraphs++
+		case *ast.Link, *ast.AutoLink:
+			stats.Links++
+		case *ast.Image:
+			stats.Images++
+			return ast.WalkSkipChildren, nil
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			stats.CodeBlocks++
+			return ast.WalkSkipChildren, nil
+		case *east.Table:
+			stats.Tables++
+		case *east.TaskCheckBox:
+			stats.Tasks++
+			if node.IsChecked {
+				stats.TasksDone++
+			}
+		case *ast.Text:
+			prose.Write(node.Segment.Value(source))
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				prose.WriteString(" ")
+			}
+		case *ast.String:
+			prose.Write(node.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	stats.Words = len(strings.Fields(prose.String()))
+	return stats
+}
+
+// ReadingTime is how long the words take to read, in whole minutes.
+func (s DocumentStats) ReadingTime() time.Duration {
+	minutes := (s.Words + wordsPerMinute - 1) / wordsPerMinute
+	return time.Duration(minutes) * time.Minute
+}
+
+func (s DocumentStats) readingTimeText() string {
+	return fmt.Sprintf("%d min", int(s.ReadingTime().Minutes()))
+}
+
+// Summary is the short form for the status line.
+func (s DocumentStats) Summary() string {
+	words := "words"
+	if s.Words == 1 {
+		words = "word"
+	}
+	return fmt.Sprintf("%d %s · %s read", s.Words, words, s.readingTimeText())
+}
+
+// Rows lists every count with its label, for the detailed views.
+func (s DocumentStats) Rows() [][2]string {
+	rows := [][2]string{
+		{"Words", fmt.Sprint(s.Words)},
+		{"Characters", fmt.Sprint(s.Characters)},
+		{"Characters (no spaces)", fmt.Sprint(s.NonSpace)},
+		{"Lines", fmt.Sprint(s.Lines)},
+		{"Paragraphs", fmt.Sprint(s.Paragraphs)},
+		{"Headings", fmt.Sprint(s.Headings)},
+		{"Links", fmt.Sprint(s.Links)},
+		{"Images", fmt.Sprint(s.Images)},
+		{"Code blocks", fmt.Sprint(s.CodeBlocks)},
+		{"Tables", fmt.Sprint(s.Tables)},
+	}
+	if s.Tasks > 0 {
+		rows = append(rows, [2]string{"Tasks done", fmt.Sprintf("%d of %d", s.TasksDone, s.Tasks)})
+	}
+	return append(rows, [2]string{"Reading time", s.readingTimeText()})
+}
+
+// scheduleStats recounts the document a moment after the last change, so
+// that typing does not parse the whole document on every key.
+func (m *model) scheduleStats() tea.Cmd {
+	content := m.textarea.Value()
+	if content == m.statsText {
+		return nil
+	}
+	m.statsText = content
+	m.statsSeq++
+	seq := m.statsSeq
+	return tea.Tick(statsDebounce, func(time.Time) tea.Msg {
+		return statsTickMsg{seq: seq}
+	})
+}
+
+func (m model) statsView() string {
+	var labels, values []string
+	for _, row := range m.stats.Rows() {
+		labels = append(labels, row[0])
+		values = append(values, row[1])
+	}
+	table := lipgloss.JoinHorizontal(lipgloss.Top,
+		lipgloss.NewStyle().PaddingRight(3).Render(strings.Join(labels, "\n")),
+		lipgloss.NewStyle().Align(lipgloss.Right).Render(strings.Join(values, "\n")),
+	)
+	body := lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render("Document Statistics"),
+		"",
+		table,
+		"",
+		helpStyle.Render("press any key to close"),
+	)
+	return pickerStyle.Render(body)
+}
+
+// printDocumentStats prints the counts of each of paths, for parselt stats,
+// or with asJSON a list of them with their path.
+func printDocumentStats(paths []string, asJSON bool) error {
+	type fileStats struct {
+		Path string `json:"path"`
+		DocumentStats
+	}
+	var files []fileStats
+	for i, path := range paths {
+		content, err := readDocument(path)
+		if err != nil {
+			return err
+		}
+		cfg, err := LoadConfigFor(path)
+		if err != nil {
+			return err
+		}
+		stats := cfg.Processor().Stats(content)
+		if asJSON {
+			files = append(files, fileStats{Path: path, DocumentStats: stats})
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		if len(paths) > 1 {
+			fmt.Println(path)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, row := range stats.Rows() {
+			fmt.Fprintf(w, "%s\t%s\n", row[0], row[1])
+		}
+		w.Flush()
+	}
+	if asJSON {
+		data, err := json.MarshalIndent(files, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	}
+	return nil
+}
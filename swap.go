@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"fyne.io/fyne/v2"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// swapInterval is how often unsaved edits go to the swap file, and so about
+// the most typing a crash can lose.
+const swapInterval = 2 * time.Second
+
+// maxSwapEdits is how many edits a swap file collects before it is rewritten
+// as a single snapshot.
+const maxSwapEdits = 200
+
+// SwapPath is where the swap file of docPath is kept, next to its autosave
+// copy. Untitled documents have none.
+func SwapPath(docPath string) string {
+	return recoveryPath(docPath, ".swp")
+}
+
+// SwapFile is the journal of unsaved edits to a document. It starts with the
+// process that writes it and a snapshot of the text, followed by every edit
+// since, so that each sync appends only what changed and is flushed to disk
+// right away.
+//
+// The format is line based:
+//
+//	parselt-swap <pid> <host>
+//	S <length>
+//	<text>
+//	E <offset> <deleted> <length>
+//	<inserted text>
+type SwapFile struct {
+	path  string
+	last  string
+	edits int
+}
+
+// SwapInfo is what a swap file left on disk holds.
+type SwapInfo struct {
+	Content string
+	PID     int
+	Host    string
+	ModTime time.Time
+}
+
+// Live tells whether the process that wrote the swap file is still running,
+// which means the document is open elsewhere rather than left by a crash.
+// Swap files from other machines count as live: there is no telling.
+func (s SwapInfo) Live() bool {
+	host, _ := os.Hostname()
+	if s.Host != host {
+		return true
+	}
+	return s.PID == os.Getpid() || processAlive(s.PID)
+}
+
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		// FindProcess only succeeds for running processes there
+		return true
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// NewSwapFile starts the swap file of docPath. It refuses when another
+// running parselt keeps one for the same document.
+func NewSwapFile(docPath string) (*SwapFile, error) {
+	if pid, ok := swapOwner(docPath); ok {
+		return nil, fmt.Errorf("%s is open in another parselt (pid %d)", filepath.Base(docPath), pid)
+	}
+	return &SwapFile{path: SwapPath(docPath)}, nil
+}
+
+// Sync records the edit from the last synced text to content.
+func (s *SwapFile) Sync(content string) error {
+	if s.path == "" || (content == s.last && s.edits > 0) {
+		return nil
+	}
+	if _, err := os.Stat(s.path); err != nil || s.edits >= maxSwapEdits {
+		return s.rewrite(content)
+	}
+
+	start, oldEnd, newEnd := diffRange(s.last, content)
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing swap file: %v", err)
+	}
+	defer file.Close()
+	inserted := content[start:newEnd]
+	if _, err := fmt.Fprintf(file, "E %d %d %d\n%s\n", start, oldEnd-start, len(inserted), inserted); err != nil {
+		return fmt.Errorf("error writing swap file: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("error writing swap file: %v", err)
+	}
+	s.last = content
+	s.edits++
+	return nil
+}
+
+// rewrite replaces the journal by a snapshot of content. The new file is
+// moved into place so that a crash halfway leaves the old one intact.
+func (s *SwapFile) rewrite(content string) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("error writing swap file: %v", err)
+	}
+	host, _ := os.Hostname()
+	tmp := s.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("error writing swap file: %v", err)
+	}
+	_, err = fmt.Fprintf(file, "parselt-swap %d %s\nS %d\n%s\n", os.Getpid(), host, len(content), content)
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err == nil {
+		err = os.Rename(tmp, s.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error writing swap file: %v", err)
+	}
+	s.last = content
+	s.edits = 1
+	return nil
+}
+
+// Remove deletes the swap file once its edits are saved or discarded.
+func (s *SwapFile) Remove() {
+	os.Remove(s.path)
+	s.last, s.edits = "", 0
+}
+
+// diffRange finds the part of old that new replaced: new[start:newEnd] stands
+// where old[start:oldEnd] was.
+func diffRange(old, new string) (start, oldEnd, newEnd int) {
+	for start < len(old) && start < len(new) && old[start] == new[start] {
+		start++
+	}
+	oldEnd, newEnd = len(old), len(new)
+	for oldEnd > start && newEnd > start && old[oldEnd-1] == new[newEnd-1] {
+		oldEnd--
+		newEnd--
+	}
+	return start, oldEnd, newEnd
+}
+
+// ReadSwap replays the swap file of docPath. A record cut short by a crash
+// while it was written ends the replay; everything before it is kept.
+func ReadSwap(docPath string) (SwapInfo, error) {
+	path := SwapPath(docPath)
+	if path == "" {
+		return SwapInfo{}, os.ErrNotExist
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return SwapInfo{}, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return SwapInfo{}, err
+	}
+	info := SwapInfo{ModTime: stat.ModTime()}
+
+	r := bufio.NewReader(file)
+	header, err := r.ReadString('\n')
+	fields := strings.Fields(header)
+	if err != nil || len(fields) != 3 || fields[0] != "parselt-swap" {
+		return SwapInfo{}, fmt.Errorf("error reading swap file: %s is not one", path)
+	}
+	info.PID, _ = strconv.Atoi(fields[1])
+	info.Host = fields[2]
+
+	content := ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			break
+		}
+		numbers := make([]int, len(fields))
+		for i := 1; i < len(fields); i++ {
+			numbers[i], _ = strconv.Atoi(fields[i])
+		}
+		n := numbers[len(numbers)-1]
+		if n < 0 {
+			break
+		}
+		text := make([]byte, n+1)
+		if _, err := io.ReadFull(r, text); err != nil {
+			break
+		}
+		switch {
+		case len(fields) == 2 && fields[0] == "S":
+			content = string(text[:n])
+		case len(fields) == 4 && fields[0] == "E" &&
+			numbers[1] >= 0 && numbers[2] >= 0 && numbers[1]+numbers[2] <= len(content):
+			content = content[:numbers[1]] + string(text[:n]) + content[numbers[1]+numbers[2]:]
+		}
+	}
+	info.Content = content
+	return info, nil
+}
+
+// removeSwap deletes the swap file of docPath unless another running parselt
+// is writing it.
+func removeSwap(docPath string) {
+	if _, ok := swapOwner(docPath); ok {
+		return
+	}
+	os.Remove(SwapPath(docPath))
+}
+
+// swapOwner returns the process id of another running parselt that keeps a
+// swap file for docPath.
+func swapOwner(docPath string) (int, bool) {
+	info, err := ReadSwap(docPath)
+	if err != nil || info.PID == os.Getpid() || !info.Live() {
+		return 0, false
+	}
+	return info.PID, true
+}
+
+func (m model) scheduleSwap() tea.Cmd {
+	if !m.swapOn {
+		return nil
+	}
+	return tea.Tick(swapInterval, func(time.Time) tea.Msg {
+		return swapMsg{}
+	})
+}
+
+// writeSwaps brings the swap file of every buffer up to date: buffers with
+// unsaved changes get their edits appended, the others lose theirs.
+func (m *model) writeSwaps() {
+	m.stashBuffer()
+	for _, b := range m.buffers {
+		if b.filename == "" {
+			continue
+		}
+		swap, ok := m.swaps[b.filename]
+		if !b.dirty() {
+			if swap != nil && swap.edits > 0 {
+				swap.Remove()
+			}
+			continue
+		}
+		if !ok {
+			var err error
+			if swap, err = NewSwapFile(b.filename); err != nil {
+				m.status = err.Error()
+			}
+			// A nil entry keeps from asking again for a document open elsewhere
+			m.swaps[b.filename] = swap
+		}
+		if swap == nil {
+			continue
+		}
+		if err := swap.Sync(b.document()); err != nil {
+			m.status = err.Error()
+		}
+	}
+}
+
+// startSwap keeps the swap file of the current document up to date while
+// it has unsaved changes.
+func (g *GUIApp) startSwap() {
+	if !g.config.Autosave.Swap {
+		return
+	}
+	var swap *SwapFile
+	swapFile := ""
+	go func() {
+		for range time.Tick(swapInterval) {
+			fyne.Do(func() {
+				if g.currentFile != swapFile {
+					swap, swapFile = nil, g.currentFile
+					if g.currentFile != "" {
+						var err error
+						if swap, err = NewSwapFile(g.currentFile); err != nil {
+							log.Printf("Error opening swap file: %v", err)
+						}
+					}
+				}
+				if swap == nil {
+					return
+				}
+				if !g.dirty() {
+					if swap.edits > 0 {
+						swap.Remove()
+					}
+					return
+				}
+				if err := swap.Sync(g.document()); err != nil {
+					log.Printf("Error writing swap file: %v", err)
+				}
+			})
+		}
+	}()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var targetCommentRe = regexp.MustCompile(`(?i)<!--\s*target:\s*(\d+)\s*(?:words\s*)?-->`)
+
+// SectionTarget is the word target of the section under a heading and the
+// words the section has, those of the sections nested in it included. A zero
+// Target means the section has none.
+type SectionTarget struct {
+	Words  int
+	Target int
+}
+
+// Reached tells whether the section has as many words as its target.
+func (t SectionTarget) Reached() bool {
+	return t.Target > 0 && t.Words >= t.Target
+}
+
+// Progress is the short form for the outline, such as "420/800".
+func (t SectionTarget) Progress() string {
+	if t.Target == 0 {
+		return ""
+	}
+	if t.Reached() {
+		return fmt.Sprintf("✓ %d/%d", t.Words, t.Target)
+	}
+	return fmt.Sprintf("%d/%d", t.Words, t.Target)
+}
+
+// SectionTargets finds the word targets of the sections of content under
+// headings, as Outline lists them, and counts their words. A target is set
+// by a <!-- target: 800 --> comment in the section, before any heading
+// nested in it, or under targets in the front matter, keyed by the text of
+// the heading or its id:
+//
+//	targets:
+//	  Introduction: 800
+//	  related-work: 1500
+//
+// It returns nil when no section has a target, so documents without any
+// are not counted section by section.
+func (smp *SharedMarkdownProcessor) SectionTargets(content string, headings []OutlineHeading) []SectionTarget {
+	targets := make([]SectionTarget, len(headings))
+	found := false
+
+	if fm := ParseFrontMatter(content); fm != nil {
+		if byHeading, ok := fm.Fields["targets"].(map[string]any); ok {
+			for name, value := range byHeading {
+				target, err := strconv.Atoi(strings.TrimSpace(frontMatterString(value)))
+				if err != nil || target <= 0 {
+					continue
+				}
+				name = strings.ToLower(strings.TrimSpace(name))
+				for i, heading := range headings {
+					if strings.ToLower(heading.Text) == name || helpAnchor(heading.Text) == name {
+						targets[i].Target, found = target, true
+					}
+				}
+			}
+		}
+	}
+
+	lines := strings.Split(content, "\n")
+	for row, line := range lines {
+		if !strings.Contains(line, "<!--") {
+			continue
+		}
+		m := targetCommentRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		path := headingPath(headings, row)
+		if target, err := strconv.Atoi(m[1]); err == nil && target > 0 && len(path) > 0 {
+			targets[path[len(path)-1]].Target, found = target, true
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	offsets := make([]int, len(lines)+1)
+	for i, line := range lines {
+		offsets[i+1] = offsets[i] + len(line) + 1
+	}
+	for i, heading := range headings {
+		if targets[i].Target == 0 {
+			continue
+		}
+		end := len(lines)
+		for _, next := range headings[i+1:] {
+			if next.Level <= heading.Level {
+				end = next.Line
+				break
+			}
+		}
+		section := content[offsets[heading.Line]:min(offsets[end], len(content))]
+		targets[i].Words = smp.Stats(section).Words
+	}
+	return targets
+}
+
+// loadHeadings reads the headings of the document afresh for the outline
+// pane and the breadcrumb, with the progress of their word targets.
+func (m *model) loadHeadings() {
+	content := m.textarea.Value()
+	m.crumbHeadings = m.mdProcessor.Outline(content)
+	m.targets = m.mdProcessor.SectionTargets(content, m.crumbHeadings)
+}
+
+// targetProgress is the progress of heading i, or "" when it has no target.
+func targetProgress(targets []SectionTarget, i int) string {
+	if i >= len(targets) {
+		return ""
+	}
+	return targets[i].Progress()
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+// taskUnchecked and taskChecked stand in for the checkboxes while a preview
+// is rendered, so they can be found again in the output. They are private
+// use characters no document contains.
+const (
+	taskUnchecked = '\ue000'
+	taskChecked   = '\ue001'
+)
+
+var termTaskFocusStyle = lipgloss.NewStyle().Reverse(true)
+
+// TaskItem is a task list checkbox in the markdown source.
+type TaskItem struct {
+	Line    int
+	Offset  int // Byte offset of the [
+	Checked bool
+	Text    string
+}
+
+// TaskItems lists the checkboxes of content in document order, the order in
+// which the previews render them.
+func (smp *SharedMarkdownProcessor) TaskItems(content string) []TaskItem {
+	doc, source := smp.Parse(content)
+	var items []TaskItem
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		checkbox, ok := n.(*east.TaskCheckBox)
+		if !ok || !entering || checkbox.Parent().Lines().Len() == 0 {
+			return ast.WalkContinue, nil
+		}
+		offset := checkbox.Parent().Lines().At(0).Start
+		items = append(items, TaskItem{
+			Line:    strings.Count(content[:offset], "\n"),
+			Offset:  offset,
+			Checked: checkbox.IsChecked,
+			Text:    smp.PlainText(checkbox.Parent(), source),
+		})
+		return ast.WalkContinue, nil
+	})
+	return items
+}
+
+// ToggleTask checks or unchecks item. The length of the text stays the same.
+func ToggleTask(content string, item TaskItem) string {
+	if item.Offset+3 > len(content) || content[item.Offset] != '[' || content[item.Offset+2] != ']' {
+		return content
+	}
+	mark := "x"
+	if item.Checked {
+		mark = " "
+	}
+	return content[:item.Offset+1] + mark + content[item.Offset+2:]
+}
+
+// terminalTasks puts the checkbox glyphs in place of the placeholders and
+// returns the lines they are on.
+func terminalTasks(rendered string) (string, []int) {
+	lines := strings.Split(rendered, "\n")
+	var taskLines []int
+	for i, line := range lines {
+		if !strings.ContainsAny(line, string([]rune{taskUnchecked, taskChecked})) {
+			continue
+		}
+		taskLines = append(taskLines, i)
+		lines[i] = strings.NewReplacer(string(taskUnchecked), taskBoxes[0], string(taskChecked), taskBoxes[1]).Replace(line)
+	}
+	return strings.Join(lines, "\n"), taskLines
+}
+
+// taskSegments replaces the placeholders in the GUI preview with check
+// boxes. toggle gets the index of the task; without it the boxes are
+// read-only. index counts the tasks, it carries on from one call to the next.
+func taskSegments(segments []widget.RichTextSegment, toggle func(task int), index *int) []widget.RichTextSegment {
+	var walk func([]widget.RichTextSegment) []widget.RichTextSegment
+	walk = func(segments []widget.RichTextSegment) []widget.RichTextSegment {
+		var out []widget.RichTextSegment
+		for _, segment := range segments {
+			switch seg := segment.(type) {
+			case *widget.ListSegment:
+				seg.Items = walk(seg.Items)
+			case *widget.ParagraphSegment:
+				seg.Texts = walk(seg.Texts)
+			case *widget.TextSegment:
+				out = append(out, splitTaskSegment(seg, index, toggle)...)
+				continue
+			}
+			out = append(out, segment)
+		}
+		return out
+	}
+	return walk(segments)
+}
+
+func splitTaskSegment(seg *widget.TextSegment, index *int, toggle func(task int)) []widget.RichTextSegment {
+	var out []widget.RichTextSegment
+	text := seg.Text
+	for {
+		i := strings.IndexAny(text, string([]rune{taskUnchecked, taskChecked}))
+		if i < 0 {
+			break
+		}
+		if i > 0 {
+			before := *seg
+			before.Text = text[:i]
+			before.Style.Inline = true
+			out = append(out, &before)
+		}
+		box, size := []rune(text[i:])[0], len(string(taskUnchecked))
+		check := &taskCheckSegment{checked: box == taskChecked}
+		if toggle != nil {
+			task := *index
+			check.toggle = func() { toggle(task) }
+		}
+		out = append(out, check)
+		*index++
+		text = strings.TrimPrefix(text[i+size:], " ")
+	}
+	if out == nil {
+		return []widget.RichTextSegment{seg}
+	}
+	if text != "" || !seg.Style.Inline {
+		seg.Text = text
+		out = append(out, seg)
+	}
+	return out
+}
+
+// taskCheckSegment is a checkbox in the GUI preview.
+type taskCheckSegment struct {
+	checked bool
+	toggle  func()
+}
+
+func (s *taskCheckSegment) Inline() bool {
+	return true
+}
+
+func (s *taskCheckSegment) Textual() string {
+	if s.checked {
+		return "☑ "
+	}
+	return "☐ "
+}
+
+func (s *taskCheckSegment) Visual() fyne.CanvasObject {
+	check := widget.NewCheck("", nil)
+	s.Update(check)
+	return check
+}
+
+func (s *taskCheckSegment) Update(o fyne.CanvasObject) {
+	check := o.(*widget.Check)
+	// Set the state first so that it does not count as a click
+	check.OnChanged = nil
+	check.SetChecked(s.checked)
+	if s.toggle == nil {
+		check.Disable()
+		return
+	}
+	check.Enable()
+	check.OnChanged = func(bool) { s.toggle() }
+}
+
+func (s *taskCheckSegment) Select(begin, end fyne.Position) {}
+
+func (s *taskCheckSegment) SelectedText() string {
+	return ""
+}
+
+func (s *taskCheckSegment) Unselect() {}
+
+// toggleTask checks or unchecks task i and leaves the preview where it was.
+func (m *model) toggleTask(i int) {
+	content := m.textarea.Value()
+	items := m.mdProcessor.TaskItems(content)
+	if i < 0 || i >= len(items) || len(items) != len(m.taskLines) {
+		return
+	}
+	cursor := m.cursorOffset()
+	toggled := ToggleTask(content, items[i])
+	m.textarea.SetValue(toggled)
+	row, col := rowColumn(toggled, cursor)
+	moveCursorTo(&m.textarea, row, col)
+
+	m.content = toggled
+	offset := m.viewport.YOffset
+	m.refreshPreview()
+	m.viewport.SetYOffset(offset)
+	if items[i].Checked {
+		m.status = "Unchecked: " + items[i].Text
+	} else {
+		m.status = "Checked: " + items[i].Text
+	}
+}
+
+// focusTask moves the highlight by delta through the tasks and form fields,
+// starting from the top (or bottom) of the visible part of the preview.
+func (m *model) focusTask(delta int) {
+	controls := m.controls()
+	n := len(controls)
+	if n == 0 {
+		m.status = "No tasks"
+		return
+	}
+	switch {
+	case m.taskFocus >= 0:
+		m.taskFocus = (m.taskFocus + delta + n) % n
+	case delta > 0:
+		m.taskFocus = 0
+		for i, c := range controls {
+			if c.line >= m.viewport.YOffset {
+				m.taskFocus = i
+				break
+			}
+		}
+	default:
+		m.taskFocus = n - 1
+		for i := n - 1; i >= 0; i-- {
+			if controls[i].line < m.viewport.YOffset+m.viewport.Height {
+				m.taskFocus = i
+				break
+			}
+		}
+	}
+
+	line := controls[m.taskFocus].line
+	m.viewport.SetContent(m.taskPreview())
+	if line < m.viewport.YOffset || line >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(line - m.viewport.Height/2)
+	}
+	if len(m.fieldLines) == 0 {
+		m.status = fmt.Sprintf("Task %d of %d", m.taskFocus+1, n)
+	} else {
+		m.status = fmt.Sprintf("Field %d of %d", m.taskFocus+1, n)
+	}
+}
+
+func (m *model) toggleFocusedTask() {
+	if m.taskFocus < 0 {
+		m.status = "Pick a task with " + m.keys.nextTask.Help().Key + " first"
+		return
+	}
+	m.activateControl(m.taskFocus)
+}
+
+// clickTask toggles the task, or activates the form field, on screen row y,
+// if there is one.
+func (m *model) clickTask(y int) {
+	// The preview box has a border and a line of padding
+	line := y - lipgloss.Height(m.headerView()) - 2 + m.viewport.YOffset
+	for i, c := range m.controls() {
+		if c.line == line {
+			m.activateControl(i)
+			return
+		}
+	}
+}
+
+// taskPreview is the rendered preview with the focused checkbox or form
+// field highlighted.
+func (m *model) taskPreview() string {
+	controls := m.controls()
+	if m.taskFocus < 0 || m.taskFocus >= len(controls) {
+		return m.renderedMD
+	}
+	lines := strings.Split(m.renderedMD, "\n")
+	focus := controls[m.taskFocus]
+	marks := taskBoxes[:]
+	if focus.field {
+		marks = []string{optionMarks[0], optionMarks[1], blankMarks[0]}
+	}
+	// Count the controls of the same kind before it on its line
+	nth := 0
+	for _, c := range controls[:m.taskFocus] {
+		if c.line == focus.line && c.field == focus.field {
+			nth++
+		}
+	}
+	lines[focus.line] = highlightNth(lines[focus.line], marks, nth)
+	return strings.Join(lines, "\n")
+}
+
+// highlightNth highlights the nth of marks on line, whichever they are.
+func highlightNth(line string, marks []string, nth int) string {
+	for i := 0; i < len(line); i++ {
+		for _, mark := range marks {
+			if !strings.HasPrefix(line[i:], mark) {
+				continue
+			}
+			if nth == 0 {
+				return line[:i] + termTaskFocusStyle.Render(mark) + line[i+len(mark):]
+			}
+			nth--
+			i += len(mark) - 1
+			break
+		}
+	}
+	return line
+}
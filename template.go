@@ -0,0 +1,326 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+// TemplateNode is the data passed to a node template. Content holds the
+// already rendered children, Text the node's plain text.
+type TemplateNode struct {
+	Kind     string
+	Content  string
+	Text     string
+	Level    int
+	Ordered  bool
+	Start    int
+	Index    int
+	Depth    int
+	URL      string
+	Title    string
+	Language string
+	Code     string
+	Checked  bool
+	Header   bool
+	Align    string
+	Meta     map[string]string
+}
+
+var templateFuncs = template.FuncMap{
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"trim":    strings.TrimSpace,
+	"add":     func(a, b int) int { return a + b },
+	"sub":     func(a, b int) int { return a - b },
+	"repeat":  func(count int, s string) string { return strings.Repeat(s, max(count, 0)) },
+	"replace": func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"prefix": func(prefix, s string) string {
+		lines := strings.Split(s, "\n")
+		for i, line := range lines {
+			lines[i] = prefix + line
+		}
+		return strings.Join(lines, "\n")
+	},
+	"indent": func(spaces int, s string) string {
+		pad := strings.Repeat(" ", spaces)
+		lines := strings.Split(s, "\n")
+		for i, line := range lines {
+			if line != "" {
+				lines[i] = pad + line
+			}
+		}
+		return strings.Join(lines, "\n")
+	},
+}
+
+type templateRenderer struct {
+	smp    *SharedMarkdownProcessor
+	source []byte
+	tmpl   *template.Template
+	meta   map[string]string
+}
+
+func TemplatesDir() string {
+	path := ConfigPath()
+	if path == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(path), "templates")
+}
+
+// ResolveTemplate accepts a template file path or the name of a template in
+// the user's templates directory.
+func ResolveTemplate(name string) (string, error) {
+	if _, err := os.Stat(name); err == nil {
+		return name, nil
+	}
+	if dir := TemplatesDir(); dir != "" {
+		path := filepath.Join(dir, strings.TrimSuffix(name, ".tmpl")+".tmpl")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("template %q not found (looked in %s)", name, TemplatesDir())
+}
+
+// UserTemplates lists the export templates in the templates directory.
+func UserTemplates() []string {
+	dir := TemplatesDir()
+	if dir == "" {
+		return nil
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	var names []string
+	for _, match := range matches {
+		names = append(names, strings.TrimSuffix(filepath.Base(match), ".tmpl"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// templateFormat wraps a template file as an export format. A template named
+// wiki.mediawiki.tmpl exports to .mediawiki, anything else to .txt.
+func templateFormat(path string) exportFormat {
+	name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+	extension := filepath.Ext(name)
+	if extension == "" {
+		extension = ".txt"
+	}
+	return exportFormat{
+		name:        "template",
+		extension:   extension,
+		description: name,
+		export: func(content string, opts ExportOptions) ([]byte, error) {
+			opts.Template = path
+			return ExportTemplate(content, opts)
+		},
+	}
+}
+
+// ExportTemplate renders the document through the template file in
+// opts.Template, in which each node type has its own {{define "kind"}} block.
+// Node types without a template pass their children through unchanged.
+func ExportTemplate(content string, opts ExportOptions) ([]byte, error) {
+	data, err := os.ReadFile(opts.Template)
+	if err != nil {
+		return nil, fmt.Errorf("error reading template: %v", err)
+	}
+
+	tmpl, err := template.New(filepath.Base(opts.Template)).Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template: %v", err)
+	}
+
+	smp := opts.processor()
+	doc, source := smp.Parse(content)
+	r := &templateRenderer{
+		smp:    smp,
+		source: source,
+		tmpl:   tmpl,
+		meta:   map[string]string{"title": opts.Title, "source": opts.SourcePath},
+	}
+
+	out, err := r.render(doc, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+func (r *templateRenderer) children(n ast.Node, depth int) (string, error) {
+	var buf strings.Builder
+	index := 1
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		out, err := r.render(child, depth, index)
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(out)
+		index++
+	}
+	return buf.String(), nil
+}
+
+func (r *templateRenderer) render(n ast.Node, depth, index int) (string, error) {
+	node := TemplateNode{Index: index, Depth: depth, Meta: r.meta}
+	childDepth := depth
+
+	switch t := n.(type) {
+	case *ast.Document:
+		node.Kind = "document"
+	case *ast.Heading:
+		node.Kind = "heading"
+		node.Level = t.Level
+	case *ast.Paragraph:
+		node.Kind = "paragraph"
+	case *ast.TextBlock:
+		node.Kind = "text_block"
+	case *ast.List:
+		node.Kind = "list"
+		node.Ordered = t.IsOrdered()
+		node.Start = t.Start
+		childDepth = depth + 1
+	case *ast.ListItem:
+		node.Kind = "list_item"
+		if list, ok := t.Parent().(*ast.List); ok {
+			node.Ordered = list.IsOrdered()
+			node.Start = list.Start
+		}
+	case *ast.FencedCodeBlock:
+		node.Kind = "code_block"
+		node.Language = string(t.Language(r.source))
+		node.Code = r.smp.CodeBlockText(t, r.source)
+	case *ast.CodeBlock:
+		node.Kind = "code_block"
+		node.Code = r.smp.CodeBlockText(t, r.source)
+	case *ast.Blockquote:
+		node.Kind = "blockquote"
+	case *ast.ThematicBreak:
+		node.Kind = "thematic_break"
+	case *ast.HTMLBlock:
+		node.Kind = "html_block"
+		node.Code = r.smp.CodeBlockText(t, r.source)
+	case *ast.Text:
+		node.Kind = "text"
+		node.Text = string(t.Segment.Value(r.source))
+		out, err := r.execute("text", node, node.Text)
+		if err != nil {
+			return "", err
+		}
+		if t.HardLineBreak() {
+			brk, err := r.execute("hard_break", node, "\n")
+			return out + brk, err
+		}
+		if t.SoftLineBreak() {
+			brk, err := r.execute("soft_break", node, "\n")
+			return out + brk, err
+		}
+		return out, nil
+	case *ast.String:
+		node.Kind = "text"
+		node.Text = string(t.Value)
+		return r.execute("text", node, node.Text)
+	case *ast.Emphasis:
+		node.Kind = "emphasis"
+		if t.Level >= 2 {
+			node.Kind = "strong"
+		}
+		node.Level = t.Level
+	case *ast.CodeSpan:
+		node.Kind = "code_span"
+		node.Text = r.smp.PlainText(t, r.source)
+		return r.execute("code_span", node, node.Text)
+	case *ast.Link:
+		node.Kind = "link"
+		node.URL = string(t.Destination)
+		node.Title = string(t.Title)
+	case *ast.Image:
+		node.Kind = "image"
+		node.URL = string(t.Destination)
+		node.Title = string(t.Title)
+	case *ast.AutoLink:
+		node.Kind = "autolink"
+		node.URL = string(t.URL(r.source))
+		node.Text = string(t.Label(r.source))
+		return r.execute("autolink", node, node.Text)
+	case *ast.RawHTML:
+		node.Kind = "raw_html"
+		var raw strings.Builder
+		for i := 0; i < t.Segments.Len(); i++ {
+			segment := t.Segments.At(i)
+			raw.Write(segment.Value(r.source))
+		}
+		node.Text = raw.String()
+		return r.execute("raw_html", node, "")
+	case *east.Strikethrough:
+		node.Kind = "strikethrough"
+	case *east.TaskCheckBox:
+		node.Kind = "task_checkbox"
+		node.Checked = t.IsChecked
+		return r.execute("task_checkbox", node, "")
+	case *east.Table:
+		node.Kind = "table"
+	case *east.TableHeader:
+		node.Kind = "table_header"
+		node.Header = true
+	case *east.TableRow:
+		node.Kind = "table_row"
+	case *east.TableCell:
+		node.Kind = "table_cell"
+		_, node.Header = t.Parent().(*east.TableHeader)
+		switch t.Alignment {
+		case east.AlignLeft:
+			node.Align = "left"
+		case east.AlignCenter:
+			node.Align = "center"
+		case east.AlignRight:
+			node.Align = "right"
+		}
+	default:
+		node.Kind = strings.ToLower(n.Kind().String())
+	}
+
+	content, err := r.children(n, childDepth)
+	if err != nil {
+		return "", err
+	}
+	node.Content = content
+	if node.Text == "" {
+		node.Text = r.smp.PlainText(n, r.source)
+	}
+
+	fallback := content
+	switch node.Kind {
+	case "code_block", "html_block":
+		fallback = node.Code + "\n\n"
+	case "list_item", "table_header", "table_row", "text_block":
+		fallback = strings.TrimRight(content, "\n") + "\n"
+	case "table_cell":
+		fallback = content + "\t"
+	default:
+		if n.Type() == ast.TypeBlock && node.Kind != "document" {
+			fallback = strings.TrimRight(content, "\n") + "\n\n"
+		}
+	}
+	return r.execute(node.Kind, node, fallback)
+}
+
+// execute runs the template for kind, returning fallback when the user
+// template does not define one.
+func (r *templateRenderer) execute(kind string, node TemplateNode, fallback string) (string, error) {
+	if r.tmpl.Lookup(kind) == nil {
+		return fallback, nil
+	}
+	var buf strings.Builder
+	if err := r.tmpl.ExecuteTemplate(&buf, kind, node); err != nil {
+		return "", fmt.Errorf("error executing template %q: %v", kind, err)
+	}
+	return buf.String(), nil
+}
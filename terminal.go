@@ -1,17 +1,20 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 )
 
 type mode int
@@ -19,27 +22,256 @@ type mode int
 const (
 	editMode mode = iota
 	previewMode
+	splitMode
 )
 
+// splitBreakpoint is the terminal width below which split mode stacks the
+// preview under the editor instead of beside it.
+const (
+	splitBreakpoint = 100
+	previewDebounce = 150 * time.Millisecond
+)
+
+type previewTickMsg struct {
+	seq int
+}
+
+// savedMsg reports a save. content is what was written, which differs from
+// the editor text, before, when a before_save hook changed it.
+type savedMsg struct {
+	filename string
+	content  string
+	before   string
+}
+
+type autosaveMsg struct{}
+
+type swapMsg struct{}
+
 type keyMap struct {
-	quit    key.Binding
-	save    key.Binding
-	preview key.Binding
-	edit    key.Binding
-	help    key.Binding
+	quit        key.Binding
+	save        key.Binding
+	preview     key.Binding
+	edit        key.Binding
+	split       key.Binding
+	splitView   key.Binding
+	closeView   key.Binding
+	filesPane   key.Binding
+	outlinePane key.Binding
+	nextPane    key.Binding
+	narrower    key.Binding
+	wider       key.Binding
+	docMap      key.Binding
+	lint        key.Binding
+	quickFix    key.Binding
+	help        key.Binding
+	cheatsheet  key.Binding
+	outline     key.Binding
+	files       key.Binding
+	nextBuffer  key.Binding
+	prevBuffer  key.Binding
+	close       key.Binding
+	replace     key.Binding
+	sort        key.Binding
+	lineUp      key.Binding
+	lineDown    key.Binding
+	duplicate   key.Binding
+	deleteLine  key.Binding
+	join        key.Binding
+	sentences   key.Binding
+	expand      key.Binding
+	shrink      key.Binding
+	undo        key.Binding
+	redo        key.Binding
+	bold        key.Binding
+	italic      key.Binding
+	inlineCode  key.Binding
+	link        key.Binding
+	image       key.Binding
+	codeBlock   key.Binding
+	table       key.Binding
+	task        key.Binding
+	escape      key.Binding
+	strip       key.Binding
+	match       key.Binding
+	nextHead    key.Binding
+	prevHead    key.Binding
+	nextCode    key.Binding
+	prevCode    key.Binding
+	copyCode    key.Binding
+	copy        key.Binding
+	cut         key.Binding
+	pasteClip   key.Binding
+	jumpBack    key.Binding
+	jumpFwd     key.Binding
+	peek        key.Binding
+	scratch     key.Binding
+	scratchTo   key.Binding
+	nextTask    key.Binding
+	prevTask    key.Binding
+	toggleTask  key.Binding
+	stats       key.Binding
+	insertCode  key.Binding
+	codeLang    key.Binding
+	wordLeft    key.Binding
+	wordRight   key.Binding
+	delWordL    key.Binding
+	delWordR    key.Binding
+	lineStart   key.Binding
+	lineEnd     key.Binding
+	paraUp      key.Binding
+	paraDown    key.Binding
+	tools       key.Binding
+	runCommand  key.Binding
+	refreshOut  key.Binding
+	dictate     key.Binding
+	ocr         key.Binding
+	ocrQuote    key.Binding
+	flashcards  key.Binding
+	randomNote  key.Binding
+	resurface   key.Binding
+	periodic    key.Binding
+	prevPeriod  key.Binding
+	nextPeriod  key.Binding
+	meeting     key.Binding
+	actionItems key.Binding
+	runbook     key.Binding
+	narrow      key.Binding
+	card        key.Binding
+	snippet     key.Binding
+	changes     key.Binding
+	bookmark    key.Binding
+	bookmarks   key.Binding
 }
 
 func (k keyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.help, k.quit}
+	return []key.Binding{k.save, k.preview, k.edit, k.split, k.files, k.help, k.cheatsheet, k.quit}
 }
 
 func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.save, k.preview, k.edit},
-		{k.help, k.quit},
+		{k.save, k.preview, k.edit, k.split, k.splitView, k.closeView, k.outline, k.lint, k.quickFix, k.replace, k.sort, k.stats, k.flashcards, k.tools, k.runCommand, k.refreshOut, k.dictate, k.ocr, k.ocrQuote},
+		{k.lineUp, k.lineDown, k.duplicate, k.deleteLine, k.join, k.sentences},
+		{k.undo, k.redo, k.expand, k.shrink, k.copy, k.cut, k.pasteClip},
+		{k.wordLeft, k.wordRight, k.delWordL, k.delWordR, k.lineStart, k.lineEnd, k.paraUp, k.paraDown},
+		{k.bold, k.italic, k.inlineCode, k.link, k.image, k.codeBlock, k.insertCode, k.codeLang, k.table, k.task, k.escape, k.strip},
+		{k.narrow, k.match, k.nextHead, k.prevHead, k.nextCode, k.prevCode, k.copyCode, k.snippet, k.card, k.jumpBack, k.jumpFwd, k.peek},
+		{k.nextTask, k.prevTask, k.toggleTask, k.runbook, k.changes, k.bookmark, k.bookmarks},
+		{k.files, k.randomNote, k.resurface, k.periodic, k.prevPeriod, k.nextPeriod, k.meeting, k.actionItems, k.nextBuffer, k.prevBuffer, k.close},
+		{k.filesPane, k.outlinePane, k.nextPane, k.narrower, k.wider, k.docMap, k.scratch, k.scratchTo},
+		{k.help, k.cheatsheet, k.quit},
+	}
+}
+
+// byName maps the [keys] config names to the bindings they remap.
+func (k *keyMap) byName() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"quit":              &k.quit,
+		"save":              &k.save,
+		"preview":           &k.preview,
+		"edit":              &k.edit,
+		"split":             &k.split,
+		"split_editor":      &k.splitView,
+		"unsplit_editor":    &k.closeView,
+		"files_pane":        &k.filesPane,
+		"outline_pane":      &k.outlinePane,
+		"next_pane":         &k.nextPane,
+		"narrower":          &k.narrower,
+		"wider":             &k.wider,
+		"minimap":           &k.docMap,
+		"scratch":           &k.scratch,
+		"scratch_move":      &k.scratchTo,
+		"lint":              &k.lint,
+		"quick_fix":         &k.quickFix,
+		"help":              &k.help,
+		"cheatsheet":        &k.cheatsheet,
+		"outline":           &k.outline,
+		"files":             &k.files,
+		"random_note":       &k.randomNote,
+		"resurface":         &k.resurface,
+		"periodic":          &k.periodic,
+		"previous_period":   &k.prevPeriod,
+		"next_period":       &k.nextPeriod,
+		"meeting":           &k.meeting,
+		"action_items":      &k.actionItems,
+		"runbook":           &k.runbook,
+		"narrow":            &k.narrow,
+		"card":              &k.card,
+		"snippet_image":     &k.snippet,
+		"changes":           &k.changes,
+		"bookmark":          &k.bookmark,
+		"bookmarks":         &k.bookmarks,
+		"next":              &k.nextBuffer,
+		"prev":              &k.prevBuffer,
+		"close":             &k.close,
+		"replace":           &k.replace,
+		"sort":              &k.sort,
+		"line_up":           &k.lineUp,
+		"line_down":         &k.lineDown,
+		"duplicate":         &k.duplicate,
+		"delete_line":       &k.deleteLine,
+		"join":              &k.join,
+		"sentences":         &k.sentences,
+		"expand":            &k.expand,
+		"shrink":            &k.shrink,
+		"copy":              &k.copy,
+		"cut":               &k.cut,
+		"paste_history":     &k.pasteClip,
+		"undo":              &k.undo,
+		"redo":              &k.redo,
+		"bold":              &k.bold,
+		"italic":            &k.italic,
+		"code":              &k.inlineCode,
+		"link":              &k.link,
+		"image":             &k.image,
+		"code_block":        &k.codeBlock,
+		"insert_code":       &k.insertCode,
+		"code_lang":         &k.codeLang,
+		"table":             &k.table,
+		"task":              &k.task,
+		"escape":            &k.escape,
+		"strip":             &k.strip,
+		"match":             &k.match,
+		"next_heading":      &k.nextHead,
+		"prev_heading":      &k.prevHead,
+		"next_code":         &k.nextCode,
+		"prev_code":         &k.prevCode,
+		"copy_code":         &k.copyCode,
+		"back":              &k.jumpBack,
+		"forward":           &k.jumpFwd,
+		"peek":              &k.peek,
+		"next_task":         &k.nextTask,
+		"prev_task":         &k.prevTask,
+		"toggle_task":       &k.toggleTask,
+		"stats":             &k.stats,
+		"word_left":         &k.wordLeft,
+		"word_right":        &k.wordRight,
+		"delete_word_left":  &k.delWordL,
+		"delete_word_right": &k.delWordR,
+		"line_start":        &k.lineStart,
+		"line_end":          &k.lineEnd,
+		"paragraph_up":      &k.paraUp,
+		"paragraph_down":    &k.paraDown,
+		"tools":             &k.tools,
+		"command_output":    &k.runCommand,
+		"refresh_output":    &k.refreshOut,
+		"dictate":           &k.dictate,
+		"ocr":               &k.ocr,
+		"ocr_quote":         &k.ocrQuote,
+		"flashcards":        &k.flashcards,
 	}
 }
 
+// helpLine lists the everyday bindings as "key: action" for the footer. The
+// cheat sheet has the rest.
+func (k keyMap) helpLine() string {
+	var parts []string
+	for _, binding := range k.ShortHelp() {
+		parts = append(parts, binding.Help().Key+": "+binding.Help().Desc)
+	}
+	return strings.Join(parts, " • ")
+}
+
 var keys = keyMap{
 	quit: key.NewBinding(
 		key.WithKeys("ctrl+q"),
@@ -57,9 +289,367 @@ var keys = keyMap{
 		key.WithKeys("ctrl+e"),
 		key.WithHelp("ctrl+e", "edit"),
 	),
+	split: key.NewBinding(
+		key.WithKeys("ctrl+\\"),
+		key.WithHelp("ctrl+\\", "split"),
+	),
+	splitView: key.NewBinding(
+		key.WithKeys("alt+v"),
+		key.WithHelp("alt+v", "split editor"),
+	),
+	closeView: key.NewBinding(
+		key.WithKeys("alt+V"),
+		key.WithHelp("alt+V", "unsplit editor"),
+	),
+	filesPane: key.NewBinding(
+		key.WithKeys("alt+1"),
+		key.WithHelp("alt+1", "file tree"),
+	),
+	outlinePane: key.NewBinding(
+		key.WithKeys("alt+2"),
+		key.WithHelp("alt+2", "outline pane"),
+	),
+	nextPane: key.NewBinding(
+		key.WithKeys("f6"),
+		key.WithHelp("f6", "next pane"),
+	),
+	narrower: key.NewBinding(
+		key.WithKeys("alt+("),
+		key.WithHelp("alt+(", "narrower pane"),
+	),
+	wider: key.NewBinding(
+		key.WithKeys("alt+)"),
+		key.WithHelp("alt+)", "wider pane"),
+	),
+	docMap: key.NewBinding(
+		key.WithKeys("alt+m"),
+		key.WithHelp("alt+m", "document map"),
+	),
+	scratch: key.NewBinding(
+		key.WithKeys("alt+a"),
+		key.WithHelp("alt+a", "scratch pad"),
+	),
+	scratchTo: key.NewBinding(
+		key.WithKeys("alt+enter"),
+		key.WithHelp("alt+enter", "move scratch pad to document"),
+	),
+	lint: key.NewBinding(
+		key.WithKeys("ctrl+l"),
+		key.WithHelp("ctrl+l", "lint"),
+	),
+	// alt+enter moves the scratch pad instead while it is open
+	quickFix: key.NewBinding(
+		key.WithKeys("alt+enter"),
+		key.WithHelp("alt+enter", "quick fix"),
+	),
+	// ctrl+h is what terminals send for ctrl+backspace, so it only opens the
+	// manual where there is no text to delete
 	help: key.NewBinding(
+		key.WithKeys("f1", "ctrl+h"),
+		key.WithHelp("F1", "manual"),
+	),
+	outline: key.NewBinding(
+		key.WithKeys("ctrl+o"),
+		key.WithHelp("ctrl+o", "outline"),
+	),
+	files: key.NewBinding(
+		key.WithKeys("alt+o"),
+		key.WithHelp("alt+o", "files"),
+	),
+	randomNote: key.NewBinding(
+		key.WithKeys("alt+N"),
+		key.WithHelp("alt+N", "open random note"),
+	),
+	resurface: key.NewBinding(
+		key.WithKeys("alt+O"),
+		key.WithHelp("alt+O", "resurface old notes"),
+	),
+	periodic: key.NewBinding(
+		key.WithKeys("alt+T"),
+		key.WithHelp("alt+T", "periodic notes"),
+	),
+	prevPeriod: key.NewBinding(
+		key.WithKeys("alt+<"),
+		key.WithHelp("alt+<", "previous periodic note"),
+	),
+	nextPeriod: key.NewBinding(
+		key.WithKeys("alt+>"),
+		key.WithHelp("alt+>", "next periodic note"),
+	),
+	meeting: key.NewBinding(
+		key.WithKeys("alt+M"),
+		key.WithHelp("alt+M", "new meeting note"),
+	),
+	actionItems: key.NewBinding(
+		key.WithKeys("alt+A"),
+		key.WithHelp("alt+A", "send action items to tasks"),
+	),
+	runbook: key.NewBinding(
+		key.WithKeys("alt+B"),
+		key.WithHelp("alt+B", "step through runbook"),
+	),
+	narrow: key.NewBinding(
+		key.WithKeys("alt+S"),
+		key.WithHelp("alt+S", "narrow to section / widen"),
+	),
+	card: key.NewBinding(
+		key.WithKeys("alt+I"),
+		key.WithHelp("alt+I", "export image card"),
+	),
+	snippet: key.NewBinding(
+		key.WithKeys("alt+G"),
+		key.WithHelp("alt+G", "copy code block as image"),
+	),
+	changes: key.NewBinding(
+		key.WithKeys("alt+H"),
+		key.WithHelp("alt+H", "change history"),
+	),
+	bookmark: key.NewBinding(
+		key.WithKeys("alt+F"),
+		key.WithHelp("alt+F", "toggle bookmark"),
+	),
+	bookmarks: key.NewBinding(
+		key.WithKeys("alt+J"),
+		key.WithHelp("alt+J", "bookmarks"),
+	),
+	nextBuffer: key.NewBinding(
+		key.WithKeys("alt+n"),
+		key.WithHelp("alt+n", "next buffer"),
+	),
+	prevBuffer: key.NewBinding(
+		key.WithKeys("alt+p"),
+		key.WithHelp("alt+p", "previous buffer"),
+	),
+	close: key.NewBinding(
+		key.WithKeys("alt+w"),
+		key.WithHelp("alt+w", "close buffer"),
+	),
+	replace: key.NewBinding(
+		key.WithKeys("alt+r"),
+		key.WithHelp("alt+r", "replace in files"),
+	),
+	sort: key.NewBinding(
+		key.WithKeys("alt+s"),
+		key.WithHelp("alt+s", "sort"),
+	),
+	lineUp: key.NewBinding(
+		key.WithKeys("alt+up"),
+		key.WithHelp("alt+↑", "move line up"),
+	),
+	lineDown: key.NewBinding(
+		key.WithKeys("alt+down"),
+		key.WithHelp("alt+↓", "move line down"),
+	),
+	// Terminals send ctrl+shift+d and ctrl+shift+k as plain ctrl+d and
+	// ctrl+k, so the terminal app uses alt+shift instead
+	duplicate: key.NewBinding(
+		key.WithKeys("alt+D"),
+		key.WithHelp("alt+D", "duplicate line"),
+	),
+	deleteLine: key.NewBinding(
+		key.WithKeys("alt+K"),
+		key.WithHelp("alt+K", "delete line"),
+	),
+	join: key.NewBinding(
+		key.WithKeys("alt+j"),
+		key.WithHelp("alt+j", "join lines"),
+	),
+	sentences: key.NewBinding(
+		key.WithKeys("alt+."),
+		key.WithHelp("alt+.", "one sentence per line"),
+	),
+	expand: key.NewBinding(
+		key.WithKeys("alt+shift+right"),
+		key.WithHelp("alt+shift+→", "expand selection"),
+	),
+	shrink: key.NewBinding(
+		key.WithKeys("alt+shift+left"),
+		key.WithHelp("alt+shift+←", "shrink selection"),
+	),
+	copy: key.NewBinding(
+		key.WithKeys("alt+W"),
+		key.WithHelp("alt+W", "copy selection or line"),
+	),
+	cut: key.NewBinding(
+		key.WithKeys("alt+X"),
+		key.WithHelp("alt+X", "cut selection or line"),
+	),
+	pasteClip: key.NewBinding(
+		key.WithKeys("alt+Y"),
+		key.WithHelp("alt+Y", "paste from history"),
+	),
+	undo: key.NewBinding(
+		key.WithKeys("ctrl+z"),
+		key.WithHelp("ctrl+z", "undo"),
+	),
+	redo: key.NewBinding(
+		key.WithKeys("ctrl+y"),
+		key.WithHelp("ctrl+y", "redo"),
+	),
+	bold: key.NewBinding(
+		key.WithKeys("ctrl+b"),
+		key.WithHelp("ctrl+b", "bold"),
+	),
+	// Terminals send ctrl+i as tab, so italic is on alt+i
+	italic: key.NewBinding(
+		key.WithKeys("alt+i"),
+		key.WithHelp("alt+i", "italic"),
+	),
+	inlineCode: key.NewBinding(
+		key.WithKeys("alt+`"),
+		key.WithHelp("alt+`", "inline code"),
+	),
+	link: key.NewBinding(
+		key.WithKeys("alt+l"),
+		key.WithHelp("alt+l", "link"),
+	),
+	image: key.NewBinding(
+		key.WithKeys("alt+g"),
+		key.WithHelp("alt+g", "image"),
+	),
+	codeBlock: key.NewBinding(
+		key.WithKeys("alt+c"),
+		key.WithHelp("alt+c", "code block"),
+	),
+	insertCode: key.NewBinding(
+		key.WithKeys("alt+C"),
+		key.WithHelp("alt+C", "code block with language"),
+	),
+	codeLang: key.NewBinding(
+		key.WithKeys("alt+L"),
+		key.WithHelp("alt+L", "code block language"),
+	),
+	table: key.NewBinding(
+		key.WithKeys("alt+t"),
+		key.WithHelp("alt+t", "table"),
+	),
+	task: key.NewBinding(
+		key.WithKeys("alt+x"),
+		key.WithHelp("alt+x", "task item"),
+	),
+	wordLeft: key.NewBinding(
+		key.WithKeys("ctrl+left"),
+		key.WithHelp("ctrl+←", "word left"),
+	),
+	wordRight: key.NewBinding(
+		key.WithKeys("ctrl+right"),
+		key.WithHelp("ctrl+→", "word right"),
+	),
+	delWordL: key.NewBinding(
 		key.WithKeys("ctrl+h"),
-		key.WithHelp("ctrl+h", "help"),
+		key.WithHelp("ctrl+backspace", "delete word left"),
+	),
+	delWordR: key.NewBinding(
+		key.WithKeys("alt+delete"),
+		key.WithHelp("ctrl+delete", "delete word right"),
+	),
+	lineStart: key.NewBinding(
+		key.WithKeys("home"),
+		key.WithHelp("home", "first non-blank / line start"),
+	),
+	lineEnd: key.NewBinding(
+		key.WithKeys("end"),
+		key.WithHelp("end", "line end / text end"),
+	),
+	paraUp: key.NewBinding(
+		key.WithKeys("alt+shift+up", "alt+{"),
+		key.WithHelp("alt+shift+↑", "previous paragraph"),
+	),
+	paraDown: key.NewBinding(
+		key.WithKeys("alt+shift+down", "alt+}"),
+		key.WithHelp("alt+shift+↓", "next paragraph"),
+	),
+	escape: key.NewBinding(
+		key.WithKeys("alt+\\"),
+		key.WithHelp("alt+\\", "escape markdown"),
+	),
+	strip: key.NewBinding(
+		key.WithKeys("alt+P"),
+		key.WithHelp("alt+P", "strip formatting"),
+	),
+	match: key.NewBinding(
+		key.WithKeys("ctrl+]"),
+		key.WithHelp("ctrl+]", "matching element"),
+	),
+	nextHead: key.NewBinding(
+		key.WithKeys("ctrl+down"),
+		key.WithHelp("ctrl+↓", "next heading"),
+	),
+	prevHead: key.NewBinding(
+		key.WithKeys("ctrl+up"),
+		key.WithHelp("ctrl+↑", "previous heading"),
+	),
+	nextCode: key.NewBinding(
+		key.WithKeys("alt+pgdown"),
+		key.WithHelp("alt+pgdown", "next code block"),
+	),
+	prevCode: key.NewBinding(
+		key.WithKeys("alt+pgup"),
+		key.WithHelp("alt+pgup", "previous code block"),
+	),
+	copyCode: key.NewBinding(
+		key.WithKeys("alt+y"),
+		key.WithHelp("alt+y", "copy code block"),
+	),
+	jumpBack: key.NewBinding(
+		key.WithKeys("alt+-"),
+		key.WithHelp("alt+-", "jump back"),
+	),
+	jumpFwd: key.NewBinding(
+		key.WithKeys("alt+_"),
+		key.WithHelp("alt+_", "jump forward"),
+	),
+	peek: key.NewBinding(
+		key.WithKeys("alt+k"),
+		key.WithHelp("alt+k", "peek at link"),
+	),
+	nextTask: key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("tab", "next task"),
+	),
+	prevTask: key.NewBinding(
+		key.WithKeys("shift+tab"),
+		key.WithHelp("shift+tab", "previous task"),
+	),
+	toggleTask: key.NewBinding(
+		key.WithKeys("enter", " "),
+		key.WithHelp("enter/space", "toggle task"),
+	),
+	stats: key.NewBinding(
+		key.WithKeys("alt+#"),
+		key.WithHelp("alt+#", "document statistics"),
+	),
+	tools: key.NewBinding(
+		key.WithKeys("alt+!"),
+		key.WithHelp("alt+!", "external tools"),
+	),
+	runCommand: key.NewBinding(
+		key.WithKeys("alt+$"),
+		key.WithHelp("alt+$", "insert command output"),
+	),
+	refreshOut: key.NewBinding(
+		key.WithKeys("alt+R"),
+		key.WithHelp("alt+R", "refresh command output"),
+	),
+	dictate: key.NewBinding(
+		key.WithKeys("f5"),
+		key.WithHelp("f5", "start or stop dictation"),
+	),
+	ocr: key.NewBinding(
+		key.WithKeys("alt+e"),
+		key.WithHelp("alt+e", "insert text from image"),
+	),
+	ocrQuote: key.NewBinding(
+		key.WithKeys("alt+E"),
+		key.WithHelp("alt+E", "quote text from image"),
+	),
+	flashcards: key.NewBinding(
+		key.WithKeys("alt+z"),
+		key.WithHelp("alt+z", "review flashcards"),
+	),
+	cheatsheet: key.NewBinding(
+		key.WithKeys("ctrl+g"),
+		key.WithHelp("ctrl+g", "keys"),
 	),
 }
 
@@ -88,21 +678,112 @@ var (
 )
 
 type model struct {
-	textarea    textarea.Model
-	viewport    viewport.Model
-	filename    string
-	mode        mode
-	width       int
-	height      int
-	showHelp    bool
-	content     string
-	renderedMD  string
-	keys        keyMap
-	mdProcessor *SharedMarkdownProcessor
+	textarea      textarea.Model
+	viewport      viewport.Model
+	filename      string
+	mode          mode
+	width         int
+	height        int
+	content       string
+	renderedMD    string
+	taskLines     []int
+	fieldLines    []int
+	blank         *blankPrompt
+	scrollMap     ScrollMap
+	previewLine   int
+	taskFocus     int
+	stats         DocumentStats
+	statsText     string
+	statsSeq      int
+	languages     []codeLanguage
+	languageFence *codeFence
+	languageSel   TextRange
+	keys          keyMap
+	mdProcessor   *SharedMarkdownProcessor
+	imageOpts     ImageOptions
+	status        string
+	linter        *Linter
+	lintIssues    []LintIssue
+	quickFixes    []QuickFix
+	headings      []OutlineHeading
+	crumbHeadings []OutlineHeading
+	targets       []SectionTarget
+	buffers       []buffer
+	active        int
+	browserFiles  []string
+	trashEntries  []TrashEntry
+	renameFrom    string
+	renameTo      string
+	overlay       overlayKind
+	picker        *picker
+	help          *helpBrowser
+	replacer      *replacer
+	tutorial      *tutorial
+	saved         string
+	pending       func(model) (tea.Model, tea.Cmd)
+	autosave      time.Duration
+	swapOn        bool
+	lockOn        bool
+	readOnly      bool
+	lockHolder    FileLock
+	swaps         map[string]*SwapFile
+	hooks         HooksConfig
+	tools         []ToolConfig
+	toolPanel     *toolPanel
+	dictation     DictationConfig
+	listening     *Dictation
+	ocr           OCRConfig
+	commandInput  string
+	hookOpen      string
+	links         LinksConfig
+	recovery      string
+	recoveredAt   time.Time
+	watcher       *FileWatcher
+	reload        string
+	server        *PreviewServer
+	selection     *TextRange
+	history       *History
+	jumps         JumpList
+	clips         []string
+	twin          *textarea.Model
+	twinFirst     bool
+	panes         paneLayout
+	docMap        bool
+	mapMarks      []MapMark
+	peek          *peekPopup
+	scratch       *textarea.Model
+	flashcards    *flashcardReview
+	review        ReviewConfig
+	periodic      PeriodicConfig
+	meetings      MeetingsConfig
+	meeting       *meetingForm
+	runbook       RunbookConfig
+	export        ExportOptions
+	snippet       SnippetConfig
+	changelog     ChangelogConfig
+	changes       []ChangeEntry
+	positions     PositionsConfig
+	bookmarkList  []Bookmark
+	untitled      UntitledConfig
+	untitledPath  string
+	secrets       SecretsConfig
+	secretsFound  []Secret
+	publish       func(*model)
+	split         SplitConfig
+	follow        sectionFollow
+	runbookRun    *runbookSession
+	narrow        *Narrowing
+	oldNotes      []ReviewNote
+	selections    []TextRange
+	previewSeq    int
 }
 
+// TerminalApp runs the terminal editor. It talks to the terminal unless
+// SetIO gives it other streams, as a test driving it with keys would.
 type TerminalApp struct {
-	model model
+	model  model
+	input  io.Reader
+	output io.Writer
 }
 
 func NewTerminalApp(filename string) *TerminalApp {
@@ -113,727 +794,1361 @@ func NewTerminalApp(filename string) *TerminalApp {
 }
 
 func (t *TerminalApp) Run() error {
-	p := tea.NewProgram(t.model, tea.WithAltScreen())
-	_, err := p.Run()
+	if t.model.watcher != nil {
+		defer t.model.watcher.Close()
+	}
+	opts := []tea.ProgramOption{tea.WithAltScreen(), tea.WithMouseCellMotion()}
+	if t.input != nil {
+		opts = append(opts, tea.WithInput(t.input))
+	}
+	if t.output != nil {
+		opts = append(opts, tea.WithOutput(t.output))
+	}
+	_, err := tea.NewProgram(t.model, opts...).Run()
 	return err
 }
 
+// SetIO makes the editor read keys from in and draw to out instead of the
+// terminal. Either may be nil to keep the terminal.
+func (t *TerminalApp) SetIO(in io.Reader, out io.Writer) {
+	t.input, t.output = in, out
+}
+
 func initialModel(filename string) model {
-	ta := textarea.New()
-	ta.Placeholder = "Start writing your markdown ..."
-	ta.Focus()
+	ta := newEditor()
 
 	vp := viewport.New(0, 0)
 
+	cfg, err := LoadConfigFor(filename)
+	palette, paletteErr := cfg.Palette()
+	applyPalette(palette, cfg.Theme == "light")
+	caps, capsErr := DetectTermCaps(cfg.Terminal)
+	applyTermCaps(caps)
+	glyphs, glyphsErr := cfg.GlyphSet(caps)
+	applyGlyphs(glyphs)
+	km, keysErr := cfg.KeyMap()
+	schemesErr := cfg.CheckCodeSchemes()
+
 	m := model{
 		textarea:    ta,
 		viewport:    vp,
 		filename:    filename,
 		mode:        editMode,
-		keys:        keys,
-		mdProcessor: NewSharedMarkdownProcessor(),
+		keys:        km,
+		mdProcessor: cfg.Processor(),
+		imageOpts:   cfg.Images,
+		linter:      cfg.Linter(),
+		autosave:    cfg.AutosaveInterval(),
+		swapOn:      cfg.Autosave.Swap,
+		lockOn:      cfg.Autosave.Lock,
+		swaps:       map[string]*SwapFile{},
+		hooks:       cfg.Hooks,
+		tools:       cfg.Tools,
+		dictation:   cfg.Dictation,
+		ocr:         cfg.OCR,
+		review:      cfg.Review,
+		periodic:    cfg.Periodic,
+		meetings:    cfg.Meetings,
+		runbook:     cfg.Runbook,
+		export:      cfg.ExportOptions(filename),
+		snippet:     cfg.Snippet,
+		changelog:   cfg.Changelog,
+		positions:   cfg.Positions,
+		untitled:    cfg.Untitled,
+		secrets:     cfg.Secrets,
+		split:       cfg.Split,
+		links:       cfg.Links,
+		taskFocus:   -1,
+		previewLine: -1,
+		panes:       defaultPaneLayout(),
+	}
+	watcher, watchErr := NewFileWatcher()
+	if watchErr == nil {
+		m.watcher = watcher
+		watchErr = watcher.Watch(filename)
+	}
+	for _, err := range []error{err, paletteErr, capsErr, glyphsErr, keysErr, schemesErr, watchErr} {
+		if err != nil {
+			m.status = err.Error()
+		}
 	}
 
 	if filename != "" {
-		if content, err := os.ReadFile(filename); err == nil {
-			m.content = string(content)
+		if content, err := readDocument(filename); err == nil {
+			m.content = content
+			m.saved = m.content
 			m.textarea.SetValue(m.content)
+			m.restorePosition()
+			m.hookOpen = filename
+		} else if os.IsNotExist(err) {
+			m.status = fmt.Sprintf("New file %s, created on the first save", filename)
+		} else {
+			// Saving the empty editor over a file that could not be read
+			// would lose it
+			m.filename = ""
+			m.status = errorMessage(err)
 		}
 	}
 
+	if !cfg.HasPanel("editor") && m.content != "" {
+		m.mode = previewMode
+	}
+
+	m.history = NewHistory(m.textarea.Value())
+	m.stats = m.mdProcessor.Stats(m.textarea.Value())
+	m.loadHeadings()
+	m.statsText = m.textarea.Value()
+	m.buffers = []buffer{{}}
+	m.stashBuffer()
+	if cfg.HasPanel("files") {
+		m.panes.files = true
+		m.loadFileList()
+	}
+	m.panes.outline = cfg.HasPanel("outline")
+	if cfg.HasPanel("minimap") {
+		m.docMap = true
+		m.mapMarks = m.mdProcessor.DocumentMap(m.textarea.Value())
+	}
+	if m.lockBuffer() {
+		m.offerRecovery()
+	}
 	return m
 }
 
 func (m model) Init() tea.Cmd {
-	return textarea.Blink
+	return tea.Batch(textarea.Blink, m.scheduleAutosave(), m.scheduleSwap(), m.waitForChange())
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	if updated, ok := next.(model); ok && updated.tutorial != nil {
+		updated.tutorial.advance(&updated)
+		next = updated
+	}
+	if updated, ok := next.(model); ok && updated.history != nil {
+		updated.history.Record(updated.document())
+	}
+	if updated, ok := next.(model); ok {
+		updated.syncTwin()
+		updated.syncFocus()
+		next = updated
+	}
+	if updated, ok := next.(model); ok {
+		cmd = tea.Batch(cmd, updated.scheduleStats(), updated.followSection(msg))
+		next = updated
+	}
+	if updated, ok := next.(model); ok && updated.hookOpen != "" {
+		cmd = tea.Batch(cmd, hooksCmd(updated.hooks.Open, "open", updated.hookOpen, ""))
+		updated.hookOpen = ""
+		next = updated
+	}
+	if updated, ok := next.(model); ok && updated.server != nil {
+		// Browsers follow the editor as you type, unsaved changes included
+		updated.server.Update(updated.filename, updated.textarea.Value())
+	}
+	return next, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var (
 		tiCmd tea.Cmd
 		vpCmd tea.Cmd
 	)
 
+	msg = translateSequence(msg)
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		m.layout()
+		if m.help != nil {
+			m.help.resize(m.width, m.height-6)
+		}
 
-		headerHeight := 3
-		footerHeight := 3
-		verticalMargins := headerHeight + footerHeight
+		if m.mode != editMode && m.content != "" {
+			m.refreshPreview()
+		}
+
+		return m, nil
+
+	case previewTickMsg:
+		if msg.seq == m.previewSeq && m.mode == splitMode {
+			m.content = m.textarea.Value()
+			m.refreshPreview()
+		}
+		return m, nil
+
+	case formattedMsg:
+		return m, m.reviewFormatting(msg)
+
+	case savedMsg:
+		// The save may finish after switching to another buffer
+		m.stashBuffer()
+		previous := ""
+		for i, b := range m.buffers {
+			if b.filename == msg.filename || (b.filename == "" && msg.filename == "untitled.md") {
+				previous = b.saved
+				m.buffers[i].saved = msg.content
+				if msg.content != msg.before && b.document() == msg.before {
+					// A before_save hook changed the document
+					text, narrow := narrowed(b.narrow, msg.content)
+					m.buffers[i].textarea = reformatted(b.textarea, text)
+					m.buffers[i].narrow = narrow
+				}
+			}
+		}
+		m.textarea = m.buffers[m.active].textarea
+		m.narrow = m.buffers[m.active].narrow
+		m.saved = m.buffers[m.active].saved
+		if m.content != m.textarea.Value() {
+			m.content = m.textarea.Value()
+			if m.mode != editMode {
+				m.refreshPreview()
+			}
+		}
+		m.status = fmt.Sprintf("Saved to %s", msg.filename)
+		RemoveAutosave(msg.filename)
+		if m.changelog.Record {
+			if err := RecordChange(m.mdProcessor, msg.filename, previous, msg.content, time.Now()); err != nil {
+				m.status = err.Error()
+			}
+		}
+		if msg.filename == m.filename {
+			m.offerLinkUpdates(m.filename, previous, msg.content)
+		}
+		return m, hooksCmd(m.hooks.AfterSave, "after_save", msg.filename, "")
+
+	case fileChangedMsg:
+		m.handleFileChange(msg.path)
+		return m, m.waitForChange()
+
+	case linkTitleMsg:
+		m.applyLinkTitle(msg)
+		return m, nil
 
-		m.textarea.SetWidth(msg.Width - 4)
-		m.textarea.SetHeight(msg.Height - verticalMargins)
+	case followTickMsg:
+		return m, m.glide(msg)
 
-		m.viewport.Width = msg.Width - 6
-		m.viewport.Height = msg.Height - verticalMargins
+	case followFlashMsg:
+		m.endFlash(msg)
+		return m, nil
 
-		if m.mode == previewMode && m.content != "" {
-			m.renderedMD = m.RenderMarkdown(m.content)
-			m.viewport.SetContent(m.renderedMD)
+	case statsTickMsg:
+		if msg.seq == m.statsSeq {
+			m.stats = m.mdProcessor.Stats(m.textarea.Value())
+			m.loadHeadings()
+			if m.docMap {
+				m.mapMarks = m.mdProcessor.DocumentMap(m.textarea.Value())
+			}
 		}
+		return m, nil
 
-		if m.mode == previewMode && m.content != "" {
-			m.renderedMD = m.RenderMarkdown(m.content)
-			m.viewport.SetContent(m.renderedMD)
+	case tea.MouseMsg:
+		if m.overlay != overlayNone {
+			return m, nil
+		}
+		if msg.Action == tea.MouseActionPress && m.peek != nil {
+			m.peek = nil
+			return m, nil
+		}
+		if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft && m.clickSidePane(msg.X, msg.Y) {
+			return m, nil
+		}
+		if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft && m.clickDocMap(msg.X, msg.Y) {
+			return m, nil
+		}
+		if m.mode == previewMode && msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft {
+			m.clickTask(msg.Y)
+			return m, nil
 		}
+		if m.mode == splitMode && msg.Action == tea.MouseActionPress &&
+			(msg.Button == tea.MouseButtonWheelUp || msg.Button == tea.MouseButtonWheelDown) {
+			m.scrollSplit(msg)
+			return m, nil
+		}
+
+	case toolDoneMsg:
+		m.toolDone(msg)
+		return m, nil
+
+	case commandDoneMsg:
+		m.commandDone(msg)
+		return m, nil
+
+	case runbookRunMsg:
+		m.runbookRan(msg)
+		return m, nil
+
+	case dictationMsg:
+		m.dictated(msg)
+		return m, nil
+
+	case ocrDoneMsg:
+		m.ocrDone(msg)
+		return m, nil
+
+	case autosaveMsg:
+		m.writeAutosaves()
+		return m, m.scheduleAutosave()
+
+	case swapMsg:
+		m.writeSwaps()
+		return m, m.scheduleSwap()
+
+	case string:
+		m.status = msg
+		return m, nil
 
+	case error:
+		m.status = errorMessage(msg)
+		if errors.Is(msg, ErrFileLocked) && m.overlay == overlayNone {
+			holder, _ := ReadLock(m.filename)
+			if m.readOnly {
+				holder = FileLock{}
+			}
+			m.offerTakeover(holder)
+		}
 		return m, nil
 
 	case tea.KeyMsg:
+		if m.overlay != overlayNone {
+			return m.updateOverlay(msg)
+		}
+		if m.peek != nil && m.closePeek(msg) {
+			return m, nil
+		}
+		if m.scratch != nil && m.updateScratch(msg) {
+			return m, nil
+		}
+		if focus := m.focusedPane(); (focus == paneFiles || focus == paneOutline) && m.updateSidePane(msg) {
+			return m, nil
+		}
+
+		if url := strings.TrimSpace(string(msg.Runes)); msg.Paste && m.mode != previewMode && isBareURL(url) &&
+			m.selection != nil && strings.TrimSpace(m.textarea.Value()[m.selection.Start:m.selection.End]) != "" {
+			m.pasteLink(url)
+			return m, nil
+		}
+		if msg.Paste && m.mode == editMode {
+			if path := pastedImagePath(string(msg.Runes)); path != "" {
+				m.embedImage(path)
+				return m, nil
+			}
+			if url := strings.TrimSpace(string(msg.Runes)); m.links.FetchTitles && isBareURL(url) {
+				return m, m.pasteURL(url)
+			}
+		}
+
+		m.keys.countKey(msg)
+		if command, ok := m.keys.formatFor(msg); ok && m.mode != previewMode {
+			return m, m.applyFormat(command)
+		}
+
 		switch {
 		case key.Matches(msg, m.keys.quit):
-			return m, tea.Quit
+			return quitAll(m)
+
+		case key.Matches(msg, m.keys.scratch):
+			m.toggleScratch()
+			return m, nil
+
+		case key.Matches(msg, m.keys.quickFix):
+			m.openQuickFix()
+			return m, nil
+
+		case key.Matches(msg, m.keys.scratchTo):
+			m.moveScratch()
+			return m, nil
 
 		case key.Matches(msg, m.keys.save):
+			if m.filename == "" {
+				if path, ok := m.untitled.Path(m.mdProcessor, m.document()); ok {
+					m.openSaveUntitled(path)
+					return m, nil
+				}
+			}
 			return m, m.saveFile()
 
 		case key.Matches(msg, m.keys.preview):
 			m.mode = previewMode
+			m.layout()
 			m.content = m.textarea.Value()
-			m.renderedMD = m.RenderMarkdown(m.content)
-			m.viewport.SetContent(m.renderedMD)
+			m.refreshPreview()
 			return m, nil
 
 		case key.Matches(msg, m.keys.edit):
 			m.mode = editMode
+			m.layout()
 			m.textarea.Focus()
 			return m, nil
 
-		case key.Matches(msg, m.keys.help):
-			m.showHelp = !m.showHelp
+		case key.Matches(msg, m.keys.split):
+			if m.mode == splitMode {
+				m.mode = editMode
+			} else {
+				m.mode = splitMode
+			}
+			m.layout()
+			m.textarea.Focus()
+			m.content = m.textarea.Value()
+			m.refreshPreview()
 			return m, nil
-		}
-	}
 
-	if m.mode == editMode {
-		m.textarea, tiCmd = m.textarea.Update(msg)
-	} else {
-		m.viewport, vpCmd = m.viewport.Update(msg)
-	}
+		case m.mode != previewMode && key.Matches(msg, m.keys.splitView):
+			m.splitEditor()
+			return m, nil
 
-	return m, tea.Batch(tiCmd, vpCmd)
-}
+		case m.mode != previewMode && key.Matches(msg, m.keys.closeView):
+			m.unsplitEditor()
+			return m, nil
 
-func (m model) View() string {
-	var content string
+		case key.Matches(msg, m.keys.filesPane):
+			m.toggleFilesPane()
+			return m, nil
 
-	title := titleStyle.Render("Parselt")
-	if m.filename != "" {
-		title = titleStyle.Render(fmt.Sprintf("Parselt - %s", filepath.Base(m.filename)))
-	}
+		case key.Matches(msg, m.keys.outlinePane):
+			m.toggleOutlinePane()
+			return m, nil
 
-	modeText := "EDIT"
-	if m.mode == previewMode {
-		modeText = "PREVIEW"
-	}
-	status := statusStyle.Render(fmt.Sprintf(" %s ", modeText))
+		case key.Matches(msg, m.keys.nextPane):
+			m.nextPane()
+			return m, nil
 
-	header := lipgloss.JoinHorizontal(lipgloss.Left, title, " ", status)
+		case key.Matches(msg, m.keys.docMap):
+			m.toggleDocMap()
+			return m, nil
 
-	if m.mode == editMode {
-		content = editorStyle.Render(m.textarea.View())
-	} else {
-		content = previewStyle.Render(m.viewport.View())
-	}
+		case key.Matches(msg, m.keys.narrower):
+			m.resizeFocused(-1)
+			return m, nil
 
-	help := helpStyle.Render("ctrl+s: save • ctrl+p: preview • ctrl+e: edit • ctrl+h: help • ctrl+q: quit")
-	if m.showHelp {
-		help = m.helpView()
-	}
+		case key.Matches(msg, m.keys.wider):
+			m.resizeFocused(1)
+			return m, nil
 
-	return lipgloss.JoinVertical(
-		lipgloss.Left,
-		header,
-		content,
-		help,
-	)
-}
+		case key.Matches(msg, m.keys.lint):
+			m.openLint()
+			return m, nil
 
-func (m model) helpView() string {
-	help := `
-Keyboard Shortcuts:
+		case key.Matches(msg, m.keys.outline):
+			m.openOutline()
+			return m, nil
 
-  ctrl+s    Save file
-  ctrl+p    Switch to preview mode
-  ctrl+e    Switch to edit mode  
-  ctrl+h    Toggle this help
-  ctrl+q    Quit application
+		case key.Matches(msg, m.keys.replace):
+			m.overlay = overlayReplace
+			m.replacer = &replacer{}
+			return m, nil
 
-Navigation (Preview Mode):
-  ↑/k       Scroll up
-  ↓/j       Scroll down
-  g         Go to top
-  G         Go to bottom
-  
-Edit Mode:
-  All standard text editing shortcuts work
-  Tab       Insert 2 spaces (for indentation)
-`
-	return helpStyle.Render(help)
-}
+		case m.mode != previewMode && key.Matches(msg, m.keys.wordLeft):
+			return m, m.forwardKey(tea.KeyMsg{Type: tea.KeyLeft, Alt: true})
 
-func (m model) saveFile() tea.Cmd {
-	return func() tea.Msg {
-		content := m.textarea.Value()
+		case m.mode != previewMode && key.Matches(msg, m.keys.wordRight):
+			return m, m.forwardKey(tea.KeyMsg{Type: tea.KeyRight, Alt: true})
 
-		filename := m.filename
-		if filename == "" {
-			filename = "untitled.md"
-		}
+		case m.mode != previewMode && key.Matches(msg, m.keys.delWordL):
+			return m, m.forwardKey(tea.KeyMsg{Type: tea.KeyBackspace, Alt: true})
 
-		err := os.WriteFile(filename, []byte(content), 0644)
-		if err != nil {
-			return fmt.Errorf("error saving file: %v", err)
-		}
+		case m.mode != previewMode && key.Matches(msg, m.keys.delWordR):
+			return m, m.forwardKey(tea.KeyMsg{Type: tea.KeyDelete, Alt: true})
 
-		return fmt.Sprintf("Saved to %s", filename)
-	}
-}
+		case m.mode != previewMode && key.Matches(msg, m.keys.lineStart):
+			m.moveInLine(SmartHome)
+			return m, nil
 
-func (m model) RenderMarkdown(content string) string {
-	htmlContent := m.mdProcessor.ConvertMarkdownToHTML(content)
-	return m.htmlToTerminal(htmlContent)
-}
+		case m.mode != previewMode && key.Matches(msg, m.keys.lineEnd):
+			m.moveInLine(SmartEnd)
+			return m, nil
 
-func (m model) htmlToTerminal(html string) string {
-	text := m.mdProcessor.UnescapeHTML(html)
+		case m.mode != previewMode && key.Matches(msg, m.keys.paraUp):
+			m.jumpParagraph(-1)
+			return m, nil
 
-	lines := strings.Split(text, "\n")
-	var formatted []string
-	var inCodeBlock bool
-	var codeBlockContent []string
-	var codeBlockLang string
+		case m.mode != previewMode && key.Matches(msg, m.keys.paraDown):
+			m.jumpParagraph(1)
+			return m, nil
 
-	availableWidth := m.width - 8
-	if availableWidth < 40 {
-		availableWidth = 40
-	}
+		case m.mode != previewMode && key.Matches(msg, m.keys.lineUp):
+			m.editLine(moveLineUp)
+			return m, nil
 
-	h1TagRe := regexp.MustCompile(`<h1[^>]*>`)
-	h2TagRe := regexp.MustCompile(`<h2[^>]*>`)
-	h3TagRe := regexp.MustCompile(`<h3[^>]*>`)
-	h4TagRe := regexp.MustCompile(`<h4[^>]*>`)
+		case m.mode != previewMode && key.Matches(msg, m.keys.lineDown):
+			m.editLine(moveLineDown)
+			return m, nil
 
-	for _, line := range lines {
-		originalLine := line
-		line = strings.TrimSpace(line)
+		case m.mode != previewMode && key.Matches(msg, m.keys.duplicate):
+			m.editLine(duplicateLine)
+			return m, nil
 
-		if line == "" {
-			if inCodeBlock {
-				codeBlockContent = append(codeBlockContent, "")
-			} else {
-				formatted = append(formatted, "")
-			}
-			continue
-		}
+		case m.mode != previewMode && key.Matches(msg, m.keys.deleteLine):
+			line := m.lineRange()
+			m.clips = addClip(m.clips, m.textarea.Value()[line.Start:line.End])
+			m.editLine(deleteLine)
+			return m, nil
 
-		if strings.Contains(line, "<pre><code") {
-			inCodeBlock = true
-			codeBlockContent = []string{}
-			codeBlockLang = m.mdProcessor.ExtractCodeLanguage(line)
-			content := m.mdProcessor.RemoveHTMLTags(line)
-			if strings.TrimSpace(content) != "" {
-				codeBlockContent = append(codeBlockContent, content)
-			}
-			continue
-		}
+		case m.mode != previewMode && key.Matches(msg, m.keys.copy):
+			m.copyClip()
+			return m, nil
 
-		if strings.Contains(line, "</code></pre>") {
-			inCodeBlock = false
-			content := strings.ReplaceAll(line, "</code></pre>", "")
-			content = m.mdProcessor.RemoveHTMLTags(content)
-			if strings.TrimSpace(content) != "" {
-				codeBlockContent = append(codeBlockContent, content)
-			}
+		case m.mode != previewMode && key.Matches(msg, m.keys.cut):
+			m.cutClip()
+			return m, nil
 
-			codeHeader := "Code"
-			if codeBlockLang != "" {
-				codeHeader = strings.ToUpper(codeBlockLang)
-			}
+		case m.mode != previewMode && key.Matches(msg, m.keys.pasteClip):
+			m.openClips()
+			return m, nil
 
-			headerStyle := lipgloss.NewStyle().
-				Bold(true).
-				Foreground(lipgloss.Color("#00FF00")).
-				Background(lipgloss.Color("#1a1a1a")).
-				Padding(0, 1)
-
-			blockStyle := lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#00FF41")).
-				Background(lipgloss.Color("#1a1a1a")).
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(lipgloss.Color("#555555")).
-				Padding(1, 2).
-				Margin(1, 0)
-
-			formatted = append(formatted, headerStyle.Render("┌─ "+codeHeader+" ─┐"))
-			codeContent := m.wrapCodeBlock(codeBlockContent, availableWidth-6)
-			formatted = append(formatted, blockStyle.Render(codeContent))
-			continue
-		}
+		case m.mode != previewMode && (key.Matches(msg, m.textarea.KeyMap.DeleteAfterCursor) || key.Matches(msg, m.textarea.KeyMap.DeleteBeforeCursor)):
+			return m, m.killText(msg)
 
-		if inCodeBlock {
-			content := m.mdProcessor.RemoveHTMLTags(line)
-			codeBlockContent = append(codeBlockContent, content)
-			continue
-		}
+		case m.mode != previewMode && key.Matches(msg, m.keys.join):
+			m.editLine(joinParagraph)
+			return m, nil
 
-		if h1TagRe.MatchString(line) {
-			if content := m.mdProcessor.ExtractHeaderContent(line, 1); content != "" {
-				styled := lipgloss.NewStyle().
-					Bold(true).
-					Foreground(lipgloss.Color("#FF0000")).
-					Background(lipgloss.Color("#2A0A0A")).
-					Padding(0, 2).
-					Render("▶ " + strings.ToUpper(content) + " ◀")
-				formatted = append(formatted, styled, "")
-			}
-		} else if h2TagRe.MatchString(line) {
-			if content := m.mdProcessor.ExtractHeaderContent(line, 2); content != "" {
-				styled := lipgloss.NewStyle().
-					Bold(true).
-					Foreground(lipgloss.Color("#00FFFF")).
-					Render("▶▶ " + content)
-				underline := lipgloss.NewStyle().
-					Foreground(lipgloss.Color("#00FFFF")).
-					Render(strings.Repeat("═", len(content)+3))
-				formatted = append(formatted, styled, underline, "")
-			}
-		} else if h3TagRe.MatchString(line) {
-			if content := m.mdProcessor.ExtractHeaderContent(line, 3); content != "" {
-				styled := lipgloss.NewStyle().
-					Bold(true).
-					Foreground(lipgloss.Color("#FFFF00")).
-					Render("▶▶▶ " + content)
-				formatted = append(formatted, styled)
-			}
-		} else if h4TagRe.MatchString(line) {
-			if content := m.mdProcessor.ExtractHeaderContent(line, 4); content != "" {
-				styled := lipgloss.NewStyle().
-					Bold(true).
-					Foreground(lipgloss.Color("#96CEB4")).
-					Render("◦ " + content)
-				formatted = append(formatted, styled)
-			}
-		} else if strings.Contains(line, "<ol>") || strings.Contains(line, "</ol>") {
-			continue
-		} else if strings.Contains(line, "<ul>") || strings.Contains(line, "</ul>") {
-			continue
-		} else if strings.Contains(line, "<li>") {
-			content := strings.ReplaceAll(line, "<li>", "")
-			content = strings.ReplaceAll(content, "</li>", "")
-
-			content = m.processInlineFormatting(content)
-			content = m.mdProcessor.RemoveHTMLTags(content)
-			content = strings.TrimSpace(content)
-
-			if content != "" {
-				leadingSpaces := len(originalLine) - len(strings.TrimLeft(originalLine, " \t"))
-				indent := ""
-				bullet := "•"
-
-				if leadingSpaces >= 4 {
-					indent = "    "
-					bullet = "◦"
-				} else if leadingSpaces >= 2 {
-					indent = "  "
-					bullet = "▪"
-				}
+		case m.mode != previewMode && key.Matches(msg, m.keys.sentences):
+			m.editLine(splitParagraph)
+			return m, nil
 
-				if strings.Contains(originalLine, "1.") || strings.Contains(originalLine, "2.") {
-					numRe := regexp.MustCompile(`(\d+)\.`)
-					if matches := numRe.FindStringSubmatch(content); len(matches) > 1 {
-						bullet = matches[1] + "."
-						content = numRe.ReplaceAllString(content, "")
-						content = strings.TrimSpace(content)
-					}
-				}
+		case m.mode != previewMode && key.Matches(msg, m.keys.undo):
+			m.undo()
+			return m, nil
 
-				styled := lipgloss.NewStyle().
-					Foreground(lipgloss.Color("#FFEAA7")).
-					Render(indent + bullet + " " + content)
-				formatted = append(formatted, styled)
-			}
-		} else if strings.Contains(line, "<p>") {
-			content := strings.ReplaceAll(line, "<p>", "")
-			content = strings.ReplaceAll(content, "</p>", "")
-			content = m.processInlineFormatting(content)
-			content = m.mdProcessor.RemoveHTMLTags(content)
-			content = strings.TrimSpace(content)
-
-			if content != "" {
-				if len(content) > availableWidth {
-					content = m.wrapTextPreservingCode(content, availableWidth, 0)
-				}
+		case m.mode != previewMode && key.Matches(msg, m.keys.redo):
+			m.redo()
+			return m, nil
 
-				styled := lipgloss.NewStyle().
-					Foreground(lipgloss.Color("#E6E6E6")).
-					Render(content)
-				formatted = append(formatted, styled, "")
-			}
-		} else if strings.Contains(line, "<blockquote>") {
-			content := strings.ReplaceAll(line, "<blockquote>", "")
-			content = strings.ReplaceAll(content, "</blockquote>", "")
-			content = m.processInlineFormatting(content)
-			content = m.mdProcessor.RemoveHTMLTags(content)
-			content = strings.TrimSpace(content)
-
-			if content != "" {
-				styled := lipgloss.NewStyle().
-					Foreground(lipgloss.Color("#888888")).
-					BorderLeft(true).
-					BorderStyle(lipgloss.ThickBorder()).
-					BorderForeground(lipgloss.Color("#666666")).
-					PaddingLeft(2).
-					Italic(true).
-					Render("❝ " + content)
-				formatted = append(formatted, styled)
-			}
-		} else {
-			content := m.processInlineFormatting(line)
-			cleanLine := m.mdProcessor.RemoveHTMLTags(content)
-			cleanLine = strings.TrimSpace(cleanLine)
-			if cleanLine != "" {
-				if len(cleanLine) > availableWidth {
-					cleanLine = m.wrapTextPreservingCode(cleanLine, availableWidth, 0)
-				}
+		case m.mode != previewMode && key.Matches(msg, m.keys.match):
+			m.jumpToMatch()
+			return m, nil
+
+		case m.mode != previewMode && key.Matches(msg, m.keys.nextHead):
+			m.jumpHeading(1)
+			return m, nil
+
+		case m.mode != previewMode && key.Matches(msg, m.keys.prevHead):
+			m.jumpHeading(-1)
+			return m, nil
+
+		case m.mode != previewMode && key.Matches(msg, m.keys.nextCode):
+			m.jumpCodeBlock(1)
+			return m, nil
+
+		case m.mode != previewMode && key.Matches(msg, m.keys.prevCode):
+			m.jumpCodeBlock(-1)
+			return m, nil
+
+		case m.mode != previewMode && key.Matches(msg, m.keys.jumpBack):
+			m.jumpBack()
+			return m, nil
+
+		case m.mode != previewMode && key.Matches(msg, m.keys.jumpFwd):
+			m.jumpForward()
+			return m, nil
+
+		case m.mode != previewMode && key.Matches(msg, m.keys.peek):
+			m.openPeek()
+			return m, nil
+
+		case key.Matches(msg, m.keys.copyCode):
+			m.copyCodeBlock()
+			return m, nil
+
+		case m.mode == previewMode && key.Matches(msg, m.keys.nextTask):
+			m.focusTask(1)
+			return m, nil
 
-				styled := lipgloss.NewStyle().
-					Foreground(lipgloss.Color("#CCCCCC")).
-					Render(cleanLine)
-				formatted = append(formatted, styled)
+		case m.mode == previewMode && key.Matches(msg, m.keys.prevTask):
+			m.focusTask(-1)
+			return m, nil
+
+		case m.mode == previewMode && key.Matches(msg, m.keys.toggleTask):
+			m.toggleFocusedTask()
+			return m, nil
+
+		case m.mode != previewMode && key.Matches(msg, m.keys.expand):
+			m.expandSelection()
+			return m, nil
+
+		case m.mode != previewMode && key.Matches(msg, m.keys.shrink):
+			m.shrinkSelection()
+			return m, nil
+
+		case key.Matches(msg, m.keys.sort):
+			m.openSort()
+			return m, nil
+
+		case key.Matches(msg, m.keys.files):
+			m.openBrowser()
+			return m, nil
+
+		case key.Matches(msg, m.keys.randomNote):
+			m.openRandomNote()
+			return m, nil
+
+		case key.Matches(msg, m.keys.resurface):
+			m.openResurface()
+			return m, nil
+
+		case key.Matches(msg, m.keys.periodic):
+			m.openPeriodicPicker()
+			return m, nil
+
+		case key.Matches(msg, m.keys.prevPeriod):
+			m.openAdjacentNote(-1)
+			return m, nil
+
+		case key.Matches(msg, m.keys.nextPeriod):
+			m.openAdjacentNote(1)
+			return m, nil
+
+		case key.Matches(msg, m.keys.meeting):
+			m.openMeetingForm()
+			return m, nil
+
+		case key.Matches(msg, m.keys.actionItems):
+			m.sendActionItems()
+			return m, nil
+
+		case key.Matches(msg, m.keys.runbook):
+			m.openRunbook()
+			return m, nil
+
+		case key.Matches(msg, m.keys.narrow):
+			m.toggleNarrow()
+			return m, nil
+
+		case key.Matches(msg, m.keys.card):
+			m.exportCard()
+			return m, nil
+
+		case key.Matches(msg, m.keys.snippet):
+			m.snippetImage()
+			return m, nil
+
+		case key.Matches(msg, m.keys.changes):
+			m.openChanges()
+			return m, nil
+
+		case key.Matches(msg, m.keys.bookmark):
+			m.toggleBookmark()
+			return m, nil
+
+		case key.Matches(msg, m.keys.bookmarks):
+			m.openBookmarks()
+			return m, nil
+
+		case key.Matches(msg, m.keys.nextBuffer):
+			m.switchBuffer((m.active + 1) % len(m.buffers))
+			return m, nil
+
+		case key.Matches(msg, m.keys.prevBuffer):
+			m.switchBuffer((m.active - 1 + len(m.buffers)) % len(m.buffers))
+			return m, nil
+
+		case key.Matches(msg, m.keys.close):
+			return m.confirmDiscard(model.closeBuffer)
+
+		case key.Matches(msg, m.keys.help):
+			m.overlay = overlayHelp
+			m.help = newHelpBrowser(m.mdProcessor, m.keys.help, m.width, m.height-6)
+			return m, nil
+
+		case key.Matches(msg, m.keys.cheatsheet):
+			m.overlay = overlayKeys
+			return m, nil
+
+		case m.mode != previewMode && key.Matches(msg, m.keys.insertCode):
+			m.openLanguagePicker(false)
+			return m, nil
+
+		case m.mode != previewMode && key.Matches(msg, m.keys.codeLang):
+			m.openLanguagePicker(true)
+			return m, nil
+
+		case key.Matches(msg, m.keys.stats):
+			m.stats = m.mdProcessor.Stats(m.textarea.Value())
+			m.overlay = overlayStats
+			return m, nil
+
+		case key.Matches(msg, m.keys.flashcards):
+			m.openFlashcards()
+			return m, nil
+
+		case key.Matches(msg, m.keys.tools):
+			m.openTools()
+			return m, nil
+
+		case m.mode != previewMode && key.Matches(msg, m.keys.runCommand):
+			m.openCommandPrompt()
+			return m, nil
+
+		case m.mode != previewMode && key.Matches(msg, m.keys.refreshOut):
+			return m, m.refreshCommandBlock()
+
+		case m.mode != previewMode && key.Matches(msg, m.keys.dictate):
+			return m, m.toggleDictation()
+
+		case m.mode != previewMode && key.Matches(msg, m.keys.ocr):
+			return m, m.recognizeText(false)
+
+		case m.mode != previewMode && key.Matches(msg, m.keys.ocrQuote):
+			return m, m.recognizeText(true)
+		}
+	}
+
+	// Typing or moving the cursor ends the selection
+	if _, ok := msg.(tea.KeyMsg); ok && m.selection != nil {
+		m.clearSelection()
+	}
+
+	switch m.mode {
+	case editMode:
+		m.textarea, tiCmd = m.textarea.Update(msg)
+	case splitMode:
+		if m.focusedPane() == panePreview {
+			// The preview has the keyboard and the editor follows it
+			m.viewport, vpCmd = m.viewport.Update(msg)
+			if _, ok := msg.(tea.KeyMsg); ok {
+				m.syncEditorScroll()
 			}
+			break
 		}
+		before := m.textarea.Value()
+		m.textarea, tiCmd = m.textarea.Update(msg)
+		if m.textarea.Value() != before {
+			m.previewSeq++
+			seq := m.previewSeq
+			vpCmd = tea.Tick(previewDebounce, func(time.Time) tea.Msg {
+				return previewTickMsg{seq: seq}
+			})
+		}
+		m.syncPreviewScroll()
+	default:
+		m.viewport, vpCmd = m.viewport.Update(msg)
 	}
-	return strings.Join(formatted, "\n")
+	if msg, ok := msg.(tea.KeyMsg); ok && msg.Paste && m.mode != previewMode {
+		m.suggestLanguage()
+	}
+
+	return m, tea.Batch(tiCmd, vpCmd)
+}
+
+func (m *model) splitStacked() bool {
+	return m.mainWidth() < splitBreakpoint
+}
+
+// editorColumns is the width of the editor beside the preview in split mode.
+func (m model) editorColumns() int {
+	return m.mainWidth() * m.panes.split / 100
 }
 
-func (m model) wrapText(text string, width int, indent int) string {
-	if width <= 0 {
-		return text
+// layout sizes the textarea and viewport for the current mode.
+func (m *model) layout() {
+	headerHeight := 3
+	footerHeight := 3
+	if m.tutorial != nil {
+		headerHeight++
 	}
+	if len(m.buffers) > 1 {
+		headerHeight++
+	}
+	height := m.height - headerHeight - footerHeight
+
+	m.panes.height = height
+	width := m.mainWidth()
+
+	editorWidth, editorHeight := width-4, height
+	switch {
+	case m.mode != splitMode:
+		m.viewport.Width = width - 6
+		m.viewport.Height = height
+	case m.splitStacked():
+		editorHeight = height*m.panes.split/100 - 1
+		m.viewport.Width = width - 6
+		m.viewport.Height = height - editorHeight - 4
+	default:
+		editorWidth = m.editorColumns() - 4
+		m.viewport.Width = width - m.editorColumns() - 6
+		m.viewport.Height = height
+	}
+	m.layoutViews(editorWidth-m.docMapWidth(), editorHeight)
+	if m.scratch != nil {
+		m.scratch.SetWidth(m.peekWidth() - peekStyle.GetHorizontalFrameSize())
+		m.scratch.SetHeight(m.scratchHeight())
+	}
+}
 
-	if strings.Contains(text, "\x1b[") {
-		return text
+func (m *model) refreshPreview() {
+	preview := m.RenderMarkdown(m.content)
+	m.renderedMD, m.taskLines, m.fieldLines, m.scrollMap = preview.Text, preview.TaskLines, preview.FieldLines, preview.Scroll
+	if m.taskFocus >= len(m.taskLines)+len(m.fieldLines) {
+		m.taskFocus = -1
+	}
+	m.viewport.SetContent(m.taskPreview())
+	if m.mode == splitMode {
+		m.syncPreviewScroll()
+		m.previewLine = -1
+	} else if m.previewLine >= 0 && m.viewport.Height > 0 {
+		m.viewport.SetYOffset(m.scrollMap.RenderedLine(m.previewLine))
+		m.previewLine = -1
 	}
+}
 
-	words := strings.Fields(text)
-	if len(words) == 0 {
-		return text
+// syncPreviewScroll scrolls the split preview to where the line of the
+// editor cursor was rendered, a third of the way down.
+func (m *model) syncPreviewScroll() {
+	if m.scrollMap != nil {
+		m.viewport.SetYOffset(m.scrollMap.RenderedLine(m.textarea.Line()) - m.viewport.Height/3)
+		return
+	}
+	lines := m.textarea.LineCount()
+	if lines <= 1 {
+		m.viewport.GotoTop()
+		return
+	}
+	scrollable := m.viewport.TotalLineCount() - m.viewport.Height
+	if scrollable <= 0 {
+		return
 	}
+	m.viewport.SetYOffset(m.textarea.Line() * scrollable / (lines - 1))
+}
 
-	var lines []string
-	var currentLine string
-	indentStr := strings.Repeat(" ", indent)
+// headerView is the title bar with the mode, the status message, the buffer
+// tabs and the tutorial step.
+func (m model) headerView() string {
+	titleText := "Parselt"
+	if m.filename != "" {
+		titleText = fmt.Sprintf("Parselt - %s", filepath.Base(m.filename))
+	}
+	if fm := ParseFrontMatter(m.document()); fm != nil && fm.Title != "" {
+		titleText += fmt.Sprintf(" (%s)", fm.Title)
+	}
+	if m.dirty() {
+		titleText += " *"
+	}
+	title := titleStyle.Render(titleText)
 
-	for i, word := range words {
-		if strings.Contains(word, "`") && strings.Count(currentLine+" "+word, "`")%2 == 1 {
-			if currentLine != "" {
-				currentLine += " " + word
-			} else {
-				currentLine = word
-			}
-			continue
-		}
+	modeText := "EDIT"
+	switch m.mode {
+	case previewMode:
+		modeText = "PREVIEW"
+	case splitMode:
+		modeText = "SPLIT"
+	}
+	if m.narrow != nil {
+		modeText += " · NARROWED"
+	}
+	if m.readOnly {
+		modeText += " · READ-ONLY"
+	}
+	status := statusStyle.Render(fmt.Sprintf(" %s ", modeText)) + " " + helpStyle.Render(m.stats.Summary())
+	if crumb := breadcrumb(m.crumbHeadings, headingPath(m.crumbHeadings, m.sourceLine())); crumb != "" {
+		status += " " + helpStyle.Render(ansi.Truncate(crumb, max(m.width/3, 20), "…"))
+	}
 
-		testLine := currentLine
-		if testLine != "" {
-			testLine += " "
-		}
-		testLine += word
+	header := lipgloss.JoinHorizontal(lipgloss.Left, title, " ", status)
+	if m.status != "" {
+		header = lipgloss.JoinHorizontal(lipgloss.Left, header, " ", helpStyle.Render(m.status))
+	}
+	if len(m.buffers) > 1 {
+		header = lipgloss.JoinVertical(lipgloss.Left, header, m.tabsView())
+	}
+	if m.tutorial != nil {
+		header = lipgloss.JoinVertical(lipgloss.Left, header, m.tutorial.view(m.keys, m.width))
+	}
+	return header
+}
 
-		linePrefix := ""
-		if i == 0 || currentLine == "" {
-			linePrefix = ""
+func (m model) View() string {
+	var content string
+	header := m.headerView()
+
+	if m.overlay == overlayConfirm {
+		content = m.confirmView()
+	} else if m.overlay == overlayRecover {
+		content = m.recoverView()
+	} else if m.overlay == overlayLock {
+		content = m.lockView()
+	} else if m.overlay == overlayRename {
+		content = m.renameView()
+	} else if m.overlay == overlayCommand {
+		content = m.commandPromptView()
+	} else if m.overlay == overlaySaveUntitled {
+		content = m.saveUntitledView()
+	} else if m.overlay == overlaySecrets {
+		content = m.secretsView()
+	} else if m.overlay == overlayMeeting {
+		content = m.meetingFormView()
+	} else if m.overlay == overlayRunbook {
+		content = m.runbookView()
+	} else if m.overlay == overlayBlank {
+		content = m.blankView()
+	} else if m.overlay == overlayReload {
+		content = m.reloadView()
+	} else if m.overlay == overlayKeys {
+		content = m.keys.cheatsheetView(m.width)
+	} else if m.overlay == overlayStats {
+		content = m.statsView()
+	} else if m.overlay == overlayFlashcards {
+		content = m.flashcardsView()
+	} else if m.overlay == overlayToolOutput {
+		content = m.toolPanelView()
+	} else if m.overlay == overlayReplace {
+		content = m.replacer.view(m.width, m.height-6)
+	} else if m.overlay == overlayHelp {
+		content = m.help.view(m.width, m.height-6)
+	} else if m.overlay != overlayNone && m.overlay != overlayQuickFix {
+		content = m.picker.view(m.width, m.height-6)
+	} else if m.mode == editMode {
+		content = m.editorView()
+	} else if m.mode == splitMode {
+		editor := m.editorView()
+		preview := previewStyle.Padding(0, 2).Render(m.splitPreview())
+		if m.splitStacked() {
+			content = lipgloss.JoinVertical(lipgloss.Left, editor, preview)
 		} else {
-			linePrefix = indentStr
+			content = lipgloss.JoinHorizontal(lipgloss.Top, editor, preview)
 		}
+	} else {
+		content = previewStyle.Render(m.viewport.View())
+	}
+	if m.overlay == overlayNone {
+		content = m.withScratch(m.withPeek(m.withSidePanes(content)))
+	} else if m.overlay == overlayQuickFix {
+		content = m.withQuickFix(m.withSidePanes(content))
+	}
 
-		if len(linePrefix+testLine) <= width {
-			currentLine = testLine
-		} else {
-			if currentLine != "" {
-				lines = append(lines, linePrefix+currentLine)
-				currentLine = word
-			} else {
-				lines = append(lines, linePrefix+word)
-				currentLine = ""
-			}
-		}
+	help := helpStyle.Render(m.keys.helpLine())
+
+	view := lipgloss.JoinVertical(
+		lipgloss.Left,
+		header,
+		content,
+		help,
+	)
+	return fitTerminal(view, m.width, m.height)
+}
+
+// saveFile saves the active buffer in the background, first showing what
+// the before_save hooks change when [hooks] asks to review it.
+func (m model) saveFile() tea.Cmd {
+	if m.hooks.Review && len(m.hooks.BeforeSave) > 0 && !m.readOnly {
+		return m.formatForReview()
 	}
+	return m.saveCmd(true)
+}
 
-	if currentLine != "" {
-		linePrefix := indentStr
-		if len(lines) == 0 {
-			linePrefix = ""
+// saveCmd saves the active buffer in the background, passing it through
+// the before_save hooks when filter is set.
+func (m model) saveCmd(filter bool) tea.Cmd {
+	return func() tea.Msg {
+		saved, err := m.writeFile(filter)
+		if err != nil {
+			return err
 		}
-		lines = append(lines, linePrefix+currentLine)
+		return saved
 	}
-	return strings.Join(lines, "\n")
 }
 
-func (m model) wrapCodeBlock(codeLines []string, maxWidth int) string {
-	if maxWidth <= 20 {
-		return strings.Join(codeLines, "\n")
-	}
+func (m model) writeFile(filter bool) (savedMsg, error) {
+	before := m.document()
 
-	var wrappedLines []string
+	filename := m.filename
+	if filename == "" {
+		filename = "untitled.md"
+	}
+	if m.readOnly {
+		return savedMsg{}, fmt.Errorf("error saving %s: it was opened read-only as it is %w", filename, ErrFileLocked)
+	}
+	if m.lockOn {
+		if err := checkLock(filename); err != nil {
+			return savedMsg{}, err
+		}
+	}
 
-	for _, line := range codeLines {
-		if len(line) <= maxWidth {
-			wrappedLines = append(wrappedLines, line)
-		} else {
-			wrapped := m.wrapCodeLine(line, maxWidth)
-			wrappedLines = append(wrappedLines, wrapped...)
+	content := before
+	if filter {
+		var err error
+		if content, err = m.hooks.FilterBeforeSave(filename, before); err != nil {
+			return savedMsg{}, err
 		}
 	}
+	if err := writeDocument(filename, content); err != nil {
+		return savedMsg{}, err
+	}
+	return savedMsg{filename: filename, content: content, before: before}, nil
+}
+
+// reformatted is ta holding content instead, with the cursor on the same
+// line.
+func reformatted(ta textarea.Model, content string) textarea.Model {
+	row, col := ta.Line(), ta.LineInfo().StartColumn+ta.LineInfo().ColumnOffset
+	ta.SetValue(content)
+	moveCursorTo(&ta, row, col)
+	return ta
+}
 
-	return strings.Join(wrappedLines, "\n")
+// dirty reports whether the editor holds changes that were never saved.
+func (m model) dirty() bool {
+	return m.document() != m.saved
 }
 
-func (m model) wrapCodeLine(line string, maxWidth int) []string {
-	if len(line) <= maxWidth {
-		return []string{line}
+// confirmDiscard runs action right away when there is nothing to lose and
+// otherwise asks first whether to save, discard or cancel.
+func (m model) confirmDiscard(action func(model) (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	if !m.dirty() {
+		return action(m)
 	}
+	m.overlay = overlayConfirm
+	m.pending = action
+	return m, nil
+}
 
-	leadingSpaces := 0
-	for _, char := range line {
-		if char == ' ' || char == '\t' {
-			if char == '\t' {
-				leadingSpaces += 4
-			} else {
-				leadingSpaces++
+func (m model) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	action := m.pending
+	switch msg.String() {
+	case "s", "y", "enter":
+		if m.filename == "" {
+			if path, ok := m.untitled.Path(m.mdProcessor, m.document()); ok {
+				if err := m.adoptFilename(path); err != nil {
+					m.status = err.Error()
+					return m, nil
+				}
 			}
-		} else {
-			break
 		}
+		saved, err := m.writeFile(true)
+		if err != nil {
+			m.status = err.Error()
+			return m, nil
+		}
+		if saved.content != saved.before {
+			var text string
+			text, m.narrow = narrowed(m.narrow, saved.content)
+			m.textarea = reformatted(m.textarea, text)
+		}
+		m.saved = saved.content
+		m.status = fmt.Sprintf("Saved to %s", saved.filename)
+		RemoveAutosave(saved.filename)
+		// The hooks finish before whatever comes next, quitting included
+		m.overlay = overlayNone
+		m.pending = nil
+		next, cmd := action(m)
+		return next, tea.Sequence(hooksCmd(m.hooks.AfterSave, "after_save", saved.filename, ""), cmd)
+	case "d", "n":
+		RemoveAutosave(m.filename)
+	case "c", "esc":
+		m.overlay = overlayNone
+		m.pending = nil
+		return m, nil
+	default:
+		return m, nil
 	}
+	m.overlay = overlayNone
+	m.pending = nil
+	return action(m)
+}
 
-	contIndent := strings.Repeat(" ", leadingSpaces+2)
+func (m model) confirmView() string {
+	name := "untitled.md"
+	if m.filename != "" {
+		name = filepath.Base(m.filename)
+	} else if path, ok := m.untitled.Path(m.mdProcessor, m.document()); ok {
+		name = path
+	}
+	body := lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render("Unsaved Changes"),
+		"",
+		fmt.Sprintf("Save changes to %s?", name),
+		"",
+		helpStyle.Render("s: save • d: discard • esc: cancel"),
+	)
+	return pickerStyle.Render(body)
+}
 
-	var lines []string
-	remaining := line
+func (m model) updateOverlay(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.overlay == overlayConfirm {
+		return m.updateConfirm(msg)
+	}
 
-	for len(remaining) > maxWidth {
-		breakPoint := m.findCodeBreakPoint(remaining, maxWidth)
+	if m.overlay == overlayRecover {
+		m.updateRecover(msg)
+		return m, nil
+	}
 
-		if breakPoint <= leadingSpaces {
-			breakPoint = maxWidth - 3 // Leave room for "..."
-			lines = append(lines, remaining[:breakPoint]+"...")
-			remaining = contIndent + "..." + remaining[breakPoint:]
-		} else {
-			lines = append(lines, remaining[:breakPoint])
-			remaining = contIndent + strings.TrimLeft(remaining[breakPoint:], " ")
-		}
+	if m.overlay == overlayLock {
+		m.updateLock(msg)
+		return m, nil
+	}
 
-		maxWidth = maxWidth - len(contIndent)
-		if maxWidth < 20 {
-			lines = append(lines, remaining)
-			break
-		}
+	if m.overlay == overlayReload {
+		m.updateReload(msg)
+		return m, nil
 	}
 
-	if remaining != "" {
-		lines = append(lines, remaining)
+	if m.overlay == overlayRename {
+		m.updateRename(msg)
+		return m, nil
 	}
 
-	return lines
-}
+	if m.overlay == overlayCommand {
+		return m, m.updateCommandPrompt(msg)
+	}
+
+	if m.overlay == overlaySaveUntitled {
+		return m, m.updateSaveUntitled(msg)
+	}
 
-func (m model) findCodeBreakPoint(line string, maxWidth int) int {
-	if maxWidth >= len(line) {
-		return len(line)
+	if m.overlay == overlaySecrets {
+		m.updateSecrets(msg)
+		return m, nil
 	}
 
-	breakChars := []string{" ", ",", ";", ".", ")", "}", "]", ">", "|", "&", "+", "-", "="}
+	if m.overlay == overlayMeeting {
+		m.updateMeetingForm(msg)
+		return m, nil
+	}
 
-	for i := maxWidth - 1; i > maxWidth/2; i-- {
-		if i >= len(line) {
-			continue
+	if m.overlay == overlayRunbook {
+		return m, m.updateRunbook(msg)
+	}
+
+	if m.overlay == overlayBlank {
+		m.updateBlank(msg)
+		return m, nil
+	}
+
+	if m.overlay == overlayLint && m.updateLint(msg) {
+		return m, nil
+	}
+
+	if m.overlay == overlayFiles && m.updateBrowser(msg) {
+		return m, nil
+	}
+
+	// The cheat sheet closes on the next key, which then runs as usual
+	if m.overlay == overlayKeys {
+		m.overlay = overlayNone
+		if msg.String() == "esc" || key.Matches(msg, m.keys.cheatsheet) {
+			return m, nil
 		}
+		return m.update(msg)
+	}
 
-		char := string(line[i])
-		for _, breakChar := range breakChars {
-			if char == breakChar {
-				if breakChar == " " {
-					return i // Break before space
-				} else {
-					return i + 1 // Break after punctuation
-				}
-			}
+	if m.overlay == overlayStats {
+		m.overlay = overlayNone
+		return m, nil
+	}
+
+	if m.overlay == overlayToolOutput {
+		m.updateToolPanel(msg)
+		return m, nil
+	}
+
+	if m.overlay == overlayFlashcards {
+		m.updateFlashcards(msg)
+		return m, nil
+	}
+
+	if m.overlay == overlayReplace {
+		closed, apply := m.replacer.update(msg, m.replaceSources)
+		var cmd tea.Cmd
+		if apply && m.replacer.rewrite != "" {
+			cmd = m.applyRewrite()
+		} else if apply {
+			m.applyReplacements()
+		}
+		if closed {
+			m.overlay = overlayNone
+			m.replacer = nil
 		}
+		return m, cmd
 	}
-	return maxWidth / 2
-}
 
-func (m model) wrapTextPreservingCode(text string, width int, indent int) string {
-	if width <= 0 {
-		return text
+	if m.overlay == overlayHelp {
+		if m.help.update(msg) {
+			m.overlay = overlayNone
+			m.help = nil
+		}
+		return m, nil
 	}
 
-	if strings.Contains(text, "`") {
-		return m.wrapTextWithInlineCode(text, width, indent)
+	closed, chosen := m.picker.update(msg)
+	if !closed {
+		return m, nil
 	}
 
-	return m.wrapText(text, width, indent)
+	kind := m.overlay
+	item, _ := m.picker.selected()
+	m.overlay = overlayNone
+	m.picker = nil
+	if !chosen {
+		return m, nil
+	}
+
+	switch kind {
+	case overlayLint:
+		m.applyLintIssue(m.lintIssues[item.index])
+	case overlayQuickFix:
+		m.applyQuickFix(m.quickFixes[item.index])
+	case overlayOutline:
+		m.jumpToHeading(item.index)
+	case overlayFiles:
+		m.openBuffer(m.browserFiles[item.index])
+	case overlayResurface:
+		m.openBuffer(m.oldNotes[item.index].Path)
+	case overlayPeriodic:
+		m.openPeriodicNote(notePeriods[item.index])
+	case overlayTrash:
+		m.restoreTrash(m.trashEntries[item.index])
+	case overlayTools:
+		return m, m.runTool(m.tools[item.index])
+	case overlaySort:
+		m.applySort(sortCommands[item.index])
+	case overlayLanguage:
+		m.applyLanguage(m.languages[item.index].tag)
+	case overlayClips:
+		m.pasteClip(item.index)
+	case overlayChanges:
+		m.jumpToChange(m.changes[item.index])
+	case overlayBookmarks:
+		m.jumpToBookmark(m.bookmarkList[item.index])
+	}
+	return m, nil
 }
 
-func (m model) wrapTextWithInlineCode(text string, width int, indent int) string {
-	if width <= 0 {
-		return text
+func (m *model) openLint() {
+	m.lintIssues = m.linter.Lint(m.textarea.Value())
+	if len(m.lintIssues) == 0 {
+		m.status = "No lint issues"
+		return
 	}
 
-	segments := m.splitTextPreservingCode(text)
+	items := make([]pickerItem, len(m.lintIssues))
+	for i, issue := range m.lintIssues {
+		detail := issue.Rule
+		if issue.Fix != nil {
+			detail = fmt.Sprintf("suggestion: %q", issue.Fix.Text)
+		}
+		items[i] = pickerItem{
+			title:  fmt.Sprintf("%d: %s", issue.Line+1, issue.Message),
+			detail: detail,
+			index:  i,
+		}
+	}
 
-	var lines []string
-	var currentLine string
-	indentStr := strings.Repeat(" ", indent)
+	m.overlay = overlayLint
+	m.picker = newPicker(fmt.Sprintf("Lint (%d issues)", len(m.lintIssues)), items)
+	m.picker.hint = "enter: go to issue and review its fix • ctrl+f: review all fixes"
+}
 
-	for i, segment := range segments {
-		isCode := strings.HasPrefix(segment.text, "`") && strings.HasSuffix(segment.text, "`")
+func (m *model) openOutline() {
+	m.headings = m.mdProcessor.Outline(m.textarea.Value())
+	if len(m.headings) == 0 {
+		m.status = "No headings"
+		return
+	}
+	m.overlay = overlayOutline
+	m.picker = newPicker("Outline", outlineItems(m.headings, m.mdProcessor.SectionTargets(m.textarea.Value(), m.headings)))
+	if path := headingPath(m.headings, m.sourceLine()); len(path) > 0 {
+		m.picker.cursor = path[len(path)-1]
+	}
+}
 
-		testLine := currentLine
-		if testLine != "" && !isCode {
-			testLine += " "
-		}
-		testLine += segment.text
+// sourceLine is the line the cursor is on or, in preview mode, the source
+// line of the top of the preview.
+func (m model) sourceLine() int {
+	if m.mode == previewMode && m.scrollMap != nil {
+		return m.scrollMap.SourceLine(m.viewport.YOffset)
+	}
+	return m.textarea.Line()
+}
 
-		linePrefix := ""
-		if i == 0 || currentLine == "" {
-			linePrefix = ""
-		} else {
-			linePrefix = indentStr
+// jumpToHeading moves the editor cursor to the heading or, in preview mode,
+// scrolls to where the heading was rendered.
+func (m *model) jumpToHeading(index int) {
+	heading := m.headings[index]
+	if m.mode != previewMode {
+		m.jumps.Push(m.jumpPosition())
+		moveCursorTo(&m.textarea, heading.Line, 0)
+		if m.mode == splitMode {
+			m.syncPreviewScroll()
 		}
+		return
+	}
 
-		if len(linePrefix+testLine) <= width || isCode {
-			if currentLine == "" {
-				currentLine = segment.text
-			} else if isCode {
-				currentLine += segment.text // No space before inline code
-			} else {
-				currentLine += " " + segment.text
-			}
-		} else {
-			// Line too long, break here
-			if currentLine != "" {
-				lines = append(lines, linePrefix+currentLine)
-				currentLine = segment.text
-			} else {
-				// Single segment longer than width
-				lines = append(lines, linePrefix+segment.text)
-				currentLine = ""
-			}
+	// Headings sharing a title are told apart by how many came before
+	text := strings.ToLower(heading.Text)
+	occurrence := 0
+	for _, h := range m.headings[:index] {
+		if strings.ToLower(h.Text) == text {
+			occurrence++
 		}
 	}
-
-	if currentLine != "" {
-		linePrefix := indentStr
-		if len(lines) == 0 {
-			linePrefix = ""
+	for i, line := range strings.Split(m.renderedMD, "\n") {
+		if !strings.Contains(strings.ToLower(ansi.Strip(line)), text) {
+			continue
+		}
+		if occurrence == 0 {
+			m.viewport.SetYOffset(i)
+			return
 		}
-		lines = append(lines, linePrefix+currentLine)
+		occurrence--
 	}
+}
+
+func (m *model) applyLintIssue(issue LintIssue) {
+	m.jumps.Push(m.jumpPosition())
+	m.mode = editMode
+	m.textarea.Focus()
 
-	return strings.Join(lines, "\n")
+	moveCursorTo(&m.textarea, issue.Line, issue.Column)
+	if matches := LintFixChanges(m.filename, m.textarea.Value(), []LintIssue{issue}); len(matches) > 0 {
+		m.reviewRewrite("Lint Fix", m.filename, m.textarea.Value(), matches, false)
+	}
 }
 
-type textSegment struct {
-	text   string
-	isCode bool
+func moveCursorTo(ta *textarea.Model, row, col int) {
+	row = max(min(row, ta.LineCount()-1), 0)
+	for ta.Line() > row {
+		ta.CursorUp()
+	}
+	for ta.Line() < row {
+		ta.CursorDown()
+	}
+	ta.SetCursor(col)
 }
 
-func (m model) splitTextPreservingCode(text string) []textSegment {
-	var segments []textSegment
-	var current strings.Builder
-	inCode := false
+func pastedImagePath(text string) string {
+	path := droppedPath(text)
+	if !IsImageFile(path) {
+		return ""
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
 
-	for _, char := range text {
-		if char == '`' {
-			if inCode {
-				current.WriteRune(char)
-				segments = append(segments, textSegment{
-					text:   current.String(),
-					isCode: true,
-				})
-				current.Reset()
-				inCode = false
-			} else {
-				if current.Len() > 0 {
-					segments = append(segments, textSegment{
-						text:   current.String(),
-						isCode: false,
-					})
-					current.Reset()
-				}
-				current.WriteRune(char)
-				inCode = true
-			}
-		} else if char == ' ' && !inCode {
-			if current.Len() > 0 {
-				segments = append(segments, textSegment{
-					text:   current.String(),
-					isCode: false,
-				})
-				current.Reset()
-			}
-		} else {
-			current.WriteRune(char)
-		}
-	}
-
-	if current.Len() > 0 {
-		segments = append(segments, textSegment{
-			text:   current.String(),
-			isCode: inCode,
-		})
-	}
-
-	return segments
-}
-
-func (m model) processInlineFormatting(content string) string {
-	codeRe := regexp.MustCompile(`<code[^>]*>(.*?)</code>`)
-	content = codeRe.ReplaceAllStringFunc(content, func(match string) string {
-		matches := codeRe.FindStringSubmatch(match)
-		if len(matches) > 1 {
-			codeContent := strings.TrimSpace(matches[1])
-			styled := lipgloss.NewStyle().
-				Background(lipgloss.Color("#333333")).
-				Foreground(lipgloss.Color("#00FF00")).
-				Render("`" + codeContent + "`")
-			return styled
-		}
-		return match
-	})
-
-	strongRe := regexp.MustCompile(`<strong[^>]*>(.*?)</strong>`)
-	content = strongRe.ReplaceAllStringFunc(content, func(match string) string {
-		matches := strongRe.FindStringSubmatch(match)
-		if len(matches) > 1 {
-			boldContent := strings.TrimSpace(matches[1])
-			styled := lipgloss.NewStyle().
-				Bold(true).
-				Foreground(lipgloss.Color("#FFFFFF")).
-				Render(boldContent)
-			return styled
-		}
-		return match
-	})
-
-	emRe := regexp.MustCompile(`<em[^>]*>(.*?)</em>`)
-	content = emRe.ReplaceAllStringFunc(content, func(match string) string {
-		matches := emRe.FindStringSubmatch(match)
-		if len(matches) > 1 {
-			italicContent := strings.TrimSpace(matches[1])
-			styled := lipgloss.NewStyle().
-				Italic(true).
-				Foreground(lipgloss.Color("#DDDDDD")).
-				Render(italicContent)
-			return styled
-		}
-		return match
-	})
-
-	return content
+func (m *model) embedImage(path string) {
+	embedded, err := EmbedImage(path, m.filename, m.imageOpts)
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+
+	m.textarea.InsertString(embedded.Markdown(""))
+	m.status = embedded.Report()
+}
+
+// RenderMarkdown renders the preview. For org files it has no task lines or
+// scroll map, as the preview shows the markdown they were converted to.
+func (m model) RenderMarkdown(content string) TerminalPreview {
+	defer timeRender("terminal_preview", time.Now())
+	width := m.mainWidth()
+	if m.mode == splitMode && !m.splitStacked() {
+		width = m.viewport.Width
+	}
+	if isOrgFile(m.filename) {
+		return TerminalPreview{Text: m.mdProcessor.RenderTerminal(OrgToMarkdown(content), width)}
+	}
+	return m.mdProcessor.RenderTerminalPreview(markFormFields(ExpandVars(content, time.Now())), width)
 }
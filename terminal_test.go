@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/exp/golden"
+	"github.com/charmbracelet/x/exp/teatest"
+	"github.com/muesli/termenv"
+)
+
+const testDocument = `# Shopping
+
+Things to get **before** Friday:
+
+- [ ] bread
+- [x] milk
+
+` + "```go\nfmt.Println(\"hi\")\n```\n"
+
+// testConfig keeps the split preview still: following the section of the
+// cursor glides over time, so the screen would depend on when it is taken.
+const testConfig = `[split]
+follow_sections = false
+`
+
+// packageDir holds testdata, while each editor runs in a directory of its
+// own.
+var packageDir, _ = os.Getwd()
+
+// newTestTerminal starts the editor on testDocument in notes.md, away from
+// the config and state of the user, with no colors so that the screens
+// compare as text.
+func newTestTerminal(t *testing.T, width, height int) *teatest.TestModel {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TERM", "xterm-256color")
+	lipgloss.SetColorProfile(termenv.Ascii)
+
+	if err := os.MkdirAll(filepath.Join(config, "parselt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(config, "parselt", "config.toml"), []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("notes.md", []byte(testDocument), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tm := teatest.NewTestModel(t, initialModel("notes.md"), teatest.WithInitialTermSize(width, height))
+	t.Cleanup(func() { tm.Quit() })
+	waitForScreen(t, tm, "bread")
+	return tm
+}
+
+// waitForScreen waits until the editor has drawn text.
+func waitForScreen(t *testing.T, tm *teatest.TestModel, text string) {
+	t.Helper()
+	teatest.WaitFor(t, tm.Output(), func(out []byte) bool {
+		return bytes.Contains(out, []byte(text))
+	}, teatest.WithDuration(3*time.Second))
+}
+
+// requireScreen stops the editor and compares what it shows last with the
+// golden file of the test; go test -update writes it.
+func requireScreen(t *testing.T, tm *teatest.TestModel) model {
+	t.Helper()
+	if err := tm.Quit(); err != nil {
+		t.Fatal(err)
+	}
+	final := tm.FinalModel(t, teatest.WithFinalTimeout(3*time.Second)).(model)
+	t.Chdir(packageDir)
+	golden.RequireEqual(t, []byte(final.View()))
+	return final
+}
+
+func TestTerminalModes(t *testing.T) {
+	tm := newTestTerminal(t, 80, 24)
+
+	tm.Send(tea.KeyMsg{Type: tea.KeyCtrlP})
+	waitForScreen(t, tm, "PREVIEW")
+	tm.Send(tea.KeyMsg{Type: tea.KeyCtrlE})
+	waitForScreen(t, tm, "EDIT")
+	tm.Send(tea.KeyMsg{Type: tea.KeyCtrlBackslash})
+	waitForScreen(t, tm, "SPLIT")
+
+	if m := requireScreen(t, tm); m.mode != splitMode {
+		t.Errorf("mode = %v, want split", m.mode)
+	}
+}
+
+func TestTerminalSave(t *testing.T) {
+	tm := newTestTerminal(t, 80, 24)
+
+	tm.Type("Eggs. ")
+	waitForScreen(t, tm, "9 words")
+	tm.Send(tea.KeyMsg{Type: tea.KeyCtrlS})
+	waitForScreen(t, tm, "Saved to notes.md")
+
+	saved, err := os.ReadFile("notes.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := testDocument + "Eggs. "; string(saved) != want {
+		t.Errorf("saved %q, want %q", saved, want)
+	}
+	requireScreen(t, tm)
+}
+
+func TestTerminalResize(t *testing.T) {
+	tm := newTestTerminal(t, 80, 24)
+
+	tm.Send(tea.KeyMsg{Type: tea.KeyCtrlBackslash})
+	waitForScreen(t, tm, "SPLIT")
+	tm.Send(tea.WindowSizeMsg{Width: 60, Height: 14})
+	tm.Send(tea.KeyMsg{Type: tea.KeyCtrlP})
+	waitForScreen(t, tm, "PREVIEW")
+
+	if m := requireScreen(t, tm); m.width != 60 || m.height != 14 {
+		t.Errorf("size = %dx%d, want 60x14", m.width, m.height)
+	}
+}
+
+func TestTerminalPreview(t *testing.T) {
+	tm := newTestTerminal(t, 80, 24)
+
+	tm.Send(tea.KeyMsg{Type: tea.KeyCtrlP})
+	waitForScreen(t, tm, "PREVIEW")
+
+	requireScreen(t, tm)
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+const defaultTextWidth = 72
+
+type plainTextWriter struct {
+	smp    *SharedMarkdownProcessor
+	source []byte
+	width  int
+	lines  []string
+}
+
+// ExportPlainText renders the document as fixed-width text with underlined
+// headings, wrapped paragraphs and indented code.
+func ExportPlainText(content string, opts ExportOptions) ([]byte, error) {
+	smp := opts.processor()
+	doc, source := smp.Parse(content)
+
+	width := opts.Width
+	if width <= 0 {
+		width = defaultTextWidth
+	}
+
+	w := &plainTextWriter{smp: smp, source: source, width: width}
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		w.block(n, "", "")
+	}
+
+	out := strings.Join(collapseBlankLines(w.lines), "\n")
+	return []byte(strings.TrimSpace(out) + "\n"), nil
+}
+
+func (w *plainTextWriter) blank() {
+	if len(w.lines) > 0 && w.lines[len(w.lines)-1] != "" {
+		w.lines = append(w.lines, "")
+	}
+}
+
+// block writes n with firstPrefix on its first line and prefix on the rest.
+func (w *plainTextWriter) block(n ast.Node, firstPrefix, prefix string) {
+	switch node := n.(type) {
+	case *ast.Heading:
+		text := w.inline(node)
+		w.blank()
+		switch node.Level {
+		case 1:
+			w.lines = append(w.lines, strings.ToUpper(text), strings.Repeat("=", len([]rune(text))))
+		case 2:
+			w.lines = append(w.lines, text, strings.Repeat("-", len([]rune(text))))
+		default:
+			w.lines = append(w.lines, text, strings.Repeat("~", len([]rune(text))))
+		}
+		w.blank()
+
+	case *ast.Paragraph, *ast.TextBlock:
+		text := taskPrefix(node) + w.inline(node)
+		w.lines = append(w.lines, wrapWords(text, w.width, firstPrefix, prefix)...)
+		if _, ok := node.(*ast.Paragraph); ok && prefix == "" {
+			w.blank()
+		}
+
+	case *ast.List:
+		number := node.Start
+		for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+			marker := "* "
+			if node.IsOrdered() {
+				marker = fmt.Sprintf("%d. ", number)
+				number++
+			}
+			itemFirst := prefix + "  " + marker
+			itemRest := prefix + "  " + strings.Repeat(" ", len(marker))
+			first := true
+			for child := item.FirstChild(); child != nil; child = child.NextSibling() {
+				if first {
+					w.block(child, itemFirst, itemRest)
+					first = false
+				} else {
+					w.block(child, itemRest, itemRest)
+				}
+			}
+		}
+		if prefix == "" {
+			w.blank()
+		}
+
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		w.blank()
+		for _, line := range strings.Split(w.smp.CodeBlockText(node, w.source), "\n") {
+			w.lines = append(w.lines, strings.TrimRight(prefix+"    "+line, " "))
+		}
+		w.blank()
+
+	case *ast.Blockquote:
+		for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+			w.block(child, prefix+"  | ", prefix+"  | ")
+		}
+		w.blank()
+
+	case *ast.ThematicBreak:
+		w.lines = append(w.lines, strings.Repeat("-", w.width))
+		w.blank()
+
+	case *east.Table:
+		var rows [][]string
+		for row := node.FirstChild(); row != nil; row = row.NextSibling() {
+			var cells []string
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, w.inline(cell))
+			}
+			rows = append(rows, cells)
+		}
+		for _, line := range formatTextTable(rows) {
+			w.lines = append(w.lines, prefix+"  "+line)
+		}
+		w.blank()
+	}
+}
+
+func (w *plainTextWriter) inline(n ast.Node) string {
+	var buf strings.Builder
+	ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := child.(type) {
+		case *ast.Link:
+			label := w.smp.PlainText(node, w.source)
+			url := string(node.Destination)
+			if label == url {
+				buf.WriteString("<" + url + ">")
+			} else {
+				buf.WriteString(label + " <" + url + ">")
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.Image:
+			buf.WriteString("[image: " + w.smp.PlainText(node, w.source) + "]")
+			return ast.WalkSkipChildren, nil
+		case *ast.AutoLink:
+			buf.WriteString("<" + string(node.URL(w.source)) + ">")
+		case *ast.CodeSpan:
+			buf.WriteString(w.smp.PlainText(node, w.source))
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			buf.Write(node.Segment.Value(w.source))
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				buf.WriteString(" ")
+			}
+		case *ast.String:
+			buf.Write(node.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(buf.String())
+}
+
+// wrapWords fills text into lines no wider than width, including prefixes.
+func wrapWords(text string, width int, firstPrefix, prefix string) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil
+	}
+
+	var lines []string
+	current := firstPrefix + words[0]
+	for _, word := range words[1:] {
+		if len([]rune(current))+1+len([]rune(word)) > width {
+			lines = append(lines, current)
+			current = prefix + word
+		} else {
+			current += " " + word
+		}
+	}
+	return append(lines, current)
+}
+
+type manWriter struct {
+	smp         *SharedMarkdownProcessor
+	source      []byte
+	out         []string
+	headingBase int
+}
+
+// ExportMan renders the document as a roff man page. A single leading H1
+// becomes the page title, shifting the remaining headings up one level.
+func ExportMan(content string, opts ExportOptions) ([]byte, error) {
+	smp := opts.processor()
+	doc, source := smp.Parse(content)
+
+	w := &manWriter{smp: smp, source: source, headingBase: 1}
+
+	name := opts.Title
+	h1Count := 0
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if heading, ok := n.(*ast.Heading); ok && heading.Level == 1 {
+			h1Count++
+		}
+	}
+	first, isHeading := doc.FirstChild().(*ast.Heading)
+	if h1Count == 1 && isHeading && first.Level == 1 {
+		name = smp.PlainText(first, source)
+		doc.RemoveChild(doc, first)
+		w.headingBase = 2
+	}
+	if name == "" {
+		name = "untitled"
+	}
+
+	section := opts.ManSection
+	if section == "" {
+		section = "1"
+	}
+
+	w.out = append(w.out, fmt.Sprintf(".TH %s %s %s",
+		manQuote(strings.ToUpper(strings.Fields(name + " ")[0])), section, manQuote(time.Now().Format("2006-01-02"))))
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		w.block(n)
+	}
+
+	return []byte(strings.Join(w.out, "\n") + "\n"), nil
+}
+
+func (w *manWriter) block(n ast.Node) {
+	switch node := n.(type) {
+	case *ast.Heading:
+		text := w.inline(node)
+		if node.Level <= w.headingBase {
+			w.out = append(w.out, ".SH "+manQuote(strings.ToUpper(text)))
+		} else {
+			w.out = append(w.out, ".SS "+manQuote(text))
+		}
+
+	case *ast.Paragraph:
+		w.out = append(w.out, ".PP")
+		w.out = append(w.out, w.lines(taskPrefix(node)+w.inline(node))...)
+
+	case *ast.TextBlock:
+		w.out = append(w.out, w.lines(taskPrefix(node)+w.inline(node))...)
+
+	case *ast.List:
+		number := node.Start
+		for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+			if node.IsOrdered() {
+				w.out = append(w.out, fmt.Sprintf(".IP %d. 4", number))
+				number++
+			} else {
+				w.out = append(w.out, `.IP \(bu 2`)
+			}
+			for child := item.FirstChild(); child != nil; child = child.NextSibling() {
+				if _, ok := child.(*ast.List); ok {
+					w.out = append(w.out, ".RS")
+					w.block(child)
+					w.out = append(w.out, ".RE")
+				} else if p, ok := child.(*ast.Paragraph); ok && child != item.FirstChild() {
+					w.out = append(w.out, ".IP")
+					w.out = append(w.out, w.lines(w.inline(p))...)
+				} else {
+					w.out = append(w.out, w.lines(taskPrefix(child)+w.inline(child))...)
+				}
+			}
+		}
+
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		w.out = append(w.out, ".PP", ".RS 4", ".nf")
+		for _, line := range strings.Split(w.smp.CodeBlockText(node, w.source), "\n") {
+			w.out = append(w.out, manEscapeLine(manEscape(line)))
+		}
+		w.out = append(w.out, ".fi", ".RE")
+
+	case *ast.Blockquote:
+		w.out = append(w.out, ".RS 4")
+		for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+			w.block(child)
+		}
+		w.out = append(w.out, ".RE")
+
+	case *ast.ThematicBreak:
+		w.out = append(w.out, ".PP")
+
+	case *east.Table:
+		var rows [][]string
+		for row := node.FirstChild(); row != nil; row = row.NextSibling() {
+			var cells []string
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, w.smp.PlainText(cell, w.source))
+			}
+			rows = append(rows, cells)
+		}
+		w.out = append(w.out, ".PP", ".RS 4", ".nf")
+		for _, line := range formatTextTable(rows) {
+			w.out = append(w.out, manEscapeLine(manEscape(line)))
+		}
+		w.out = append(w.out, ".fi", ".RE")
+	}
+}
+
+func (w *manWriter) lines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == ".br" {
+			lines = append(lines, line)
+		} else if line != "" {
+			lines = append(lines, manEscapeLine(line))
+		}
+	}
+	return lines
+}
+
+func (w *manWriter) inline(n ast.Node) string {
+	var buf strings.Builder
+	ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch node := child.(type) {
+		case *ast.Emphasis:
+			if entering {
+				if node.Level >= 2 {
+					buf.WriteString(`\fB`)
+				} else {
+					buf.WriteString(`\fI`)
+				}
+			} else {
+				buf.WriteString(`\fR`)
+			}
+		case *ast.CodeSpan:
+			if entering {
+				buf.WriteString(`\fB` + manEscape(w.smp.PlainText(node, w.source)) + `\fR`)
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.Link:
+			if entering {
+				label := w.smp.PlainText(node, w.source)
+				url := string(node.Destination)
+				buf.WriteString(manEscape(label))
+				if label != url {
+					buf.WriteString(" <" + manEscape(url) + ">")
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.Image:
+			if entering {
+				buf.WriteString(manEscape("[image: " + w.smp.PlainText(node, w.source) + "]"))
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.AutoLink:
+			if entering {
+				buf.WriteString(manEscape(string(node.URL(w.source))))
+			}
+		case *ast.Text:
+			if entering {
+				buf.WriteString(manEscape(string(node.Segment.Value(w.source))))
+				if node.HardLineBreak() {
+					buf.WriteString("\n.br\n")
+				} else if node.SoftLineBreak() {
+					buf.WriteString("\n")
+				}
+			}
+		case *ast.String:
+			if entering {
+				buf.WriteString(manEscape(string(node.Value)))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(buf.String())
+}
+
+func manEscape(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\e`)
+	return strings.ReplaceAll(text, "-", `\-`)
+}
+
+// manEscapeLine protects lines that roff would read as requests.
+func manEscapeLine(line string) string {
+	if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+		return `\&` + line
+	}
+	return line
+}
+
+func manQuote(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, `\(dq`) + `"`
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ToolConfig is an external tool from a [[tools]] table. Output is "panel"
+// to show what the command prints, or "insert" to put it in place of the
+// selection or at the cursor.
+type ToolConfig struct {
+	Name    string `toml:"name"`
+	Command string `toml:"command"`
+	Output  string `toml:"output"`
+}
+
+func (t ToolConfig) inserts() bool {
+	return t.Output == "insert"
+}
+
+// insertedOutput is output as it replaces selected: the final line break that
+// commands print goes unless what it replaces ended in one too.
+func insertedOutput(output, selected string) string {
+	if strings.HasSuffix(selected, "\n") {
+		return output
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(output, "\n"), "\r")
+}
+
+// ToolContext is what the placeholders of a tool command stand for.
+type ToolContext struct {
+	File      string
+	Selection string
+	Line      int
+}
+
+// toolVars are the environment variables the placeholders of a tool command
+// stand for.
+var toolVars = map[string]string{
+	"FILE":      "PARSELT_FILE",
+	"DIR":       "PARSELT_DIR",
+	"SELECTION": "PARSELT_SELECTION",
+	"LINE":      "PARSELT_LINE",
+}
+
+// expandTool turns the placeholders $FILE, $DIR, $SELECTION and $LINE into
+// the quoted environment variables that hold them, so that the shell never
+// reads their values as commands, and returns those variables. Other
+// variables are left for the shell to expand.
+func expandTool(command string, ctx ToolContext) (string, []string) {
+	dir := ""
+	if ctx.File != "" {
+		if abs, err := filepath.Abs(ctx.File); err == nil {
+			dir = filepath.Dir(abs)
+		}
+	}
+	env := []string{"PARSELT_DIR=" + dir, "PARSELT_SELECTION=" + ctx.Selection, "PARSELT_LINE=" + strconv.Itoa(ctx.Line)}
+	return expandVars(command, toolVars), env
+}
+
+// expandVars turns the placeholders of command that vars names into the
+// environment variables they stand for.
+func expandVars(command string, vars map[string]string) string {
+	return os.Expand(command, func(name string) string {
+		if v, ok := vars[name]; ok {
+			return shellVar(v)
+		}
+		return "${" + name + "}"
+	})
+}
+
+// RunTool runs tool and returns what it printed. It runs in the directory of
+// the document, which it sees as last saved.
+func RunTool(tool ToolConfig, ctx ToolContext) (string, error) {
+	command, env := expandTool(tool.Command, ctx)
+	return runCommand(tool.Name, command, "tool", ctx.File, env...)
+}
+
+// runCommand runs command through the shell like a hook, with env added to
+// its environment, and returns its standard output. An error carries its
+// error output.
+func runCommand(name, command, event, file string, env ...string) (string, error) {
+	timeout, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+	cmd := hookCommand(timeout, command, event, file, "", env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return stdout.String(), fmt.Errorf("%s failed: %s", name, message)
+	}
+	return stdout.String(), nil
+}
+
+// toolDoneMsg brings back the output of a tool. content and target are the
+// document and the range the output goes to when the tool started.
+type toolDoneMsg struct {
+	tool    ToolConfig
+	output  string
+	err     error
+	content string
+	target  TextRange
+}
+
+// toolPanel shows the output of a tool.
+type toolPanel struct {
+	title  string
+	lines  []string
+	offset int
+}
+
+func (m *model) openTools() {
+	if len(m.tools) == 0 {
+		m.status = "No external tools, add [[tools]] to " + ConfigPath()
+		return
+	}
+	items := make([]pickerItem, len(m.tools))
+	for i, tool := range m.tools {
+		items[i] = pickerItem{title: tool.Name, detail: tool.Command, index: i}
+	}
+	m.overlay = overlayTools
+	m.picker = newPicker("External Tools", items)
+	m.picker.hint = "enter: run"
+}
+
+// runTool starts tool in the background with the selection, or the cursor,
+// of the active buffer.
+func (m *model) runTool(tool ToolConfig) tea.Cmd {
+	content := m.textarea.Value()
+	target := TextRange{Start: m.cursorOffset(), End: m.cursorOffset()}
+	if m.selection != nil {
+		target = *m.selection
+	}
+	ctx := ToolContext{File: m.filename, Selection: content[target.Start:target.End], Line: m.textarea.Line() + 1}
+	m.status = "Running " + tool.Name + " ..."
+	return func() tea.Msg {
+		output, err := RunTool(tool, ctx)
+		return toolDoneMsg{tool: tool, output: output, err: err, content: content, target: target}
+	}
+}
+
+func (m *model) toolDone(msg toolDoneMsg) {
+	if msg.err != nil {
+		m.status = msg.err.Error()
+		if strings.TrimSpace(msg.output) == "" {
+			return
+		}
+	}
+	if !msg.tool.inserts() || msg.err != nil {
+		m.toolPanel = &toolPanel{
+			title: msg.tool.Name,
+			lines: strings.Split(strings.TrimRight(msg.output, "\n"), "\n"),
+		}
+		m.overlay = overlayToolOutput
+		return
+	}
+
+	content := m.textarea.Value()
+	target := msg.target
+	if content != msg.content {
+		// Edited in the meantime: the cursor is the only safe place
+		target = TextRange{Start: m.cursorOffset(), End: m.cursorOffset()}
+	}
+	output := insertedOutput(msg.output, content[target.Start:target.End])
+	updated := content[:target.Start] + output + content[target.End:]
+	m.clearSelection()
+	m.textarea.SetValue(updated)
+	row, col := rowColumn(updated, target.Start+len(output))
+	moveCursorTo(&m.textarea, row, col)
+	m.content = updated
+	if m.mode == splitMode {
+		m.refreshPreview()
+	}
+	m.status = "Inserted the output of " + msg.tool.Name
+}
+
+func (m *model) updateToolPanel(msg tea.KeyMsg) {
+	p := m.toolPanel
+	visible := m.height - 10
+	switch msg.String() {
+	case "up", "k":
+		p.offset--
+	case "down", "j":
+		p.offset++
+	case "pgup":
+		p.offset -= visible
+	case "pgdown", " ":
+		p.offset += visible
+	case "esc", "q", "enter":
+		m.overlay = overlayNone
+		m.toolPanel = nil
+		return
+	}
+	p.offset = max(min(p.offset, len(p.lines)-visible), 0)
+}
+
+func (m model) toolPanelView() string {
+	p := m.toolPanel
+	visible := max(m.height-10, 3)
+	end := min(p.offset+visible, len(p.lines))
+	body := lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render(p.title),
+		"",
+		lipgloss.NewStyle().MaxWidth(m.width-6).Render(strings.Join(p.lines[p.offset:end], "\n")),
+		"",
+		helpStyle.Render(fmt.Sprintf("lines %d-%d of %d • ↑/↓: scroll • esc: close", p.offset+1, end, len(p.lines))),
+	)
+	return pickerStyle.Render(body)
+}
+
+// toolsMenu lists the external tools for the Tools menu.
+func (g *GUIApp) toolsMenu() *fyne.Menu {
+	var items []*fyne.MenuItem
+	for _, tool := range g.config.Tools {
+		items = append(items, fyne.NewMenuItem(tool.Name, func() { g.runTool(tool) }))
+	}
+	if len(items) == 0 {
+		none := fyne.NewMenuItem("No tools in config.toml", nil)
+		none.Disabled = true
+		items = append(items, none)
+	}
+	return fyne.NewMenu("", items...)
+}
+
+func (g *GUIApp) runTool(tool ToolConfig) {
+	content := g.editor.Text
+	text := []rune(content)
+	start, end := g.selectedRange()
+	target := TextRange{Start: len(string(text[:start])), End: len(string(text[:end]))}
+	ctx := ToolContext{File: g.currentFile, Selection: content[target.Start:target.End], Line: g.editor.CursorRow + 1}
+	go func() {
+		output, err := RunTool(tool, ctx)
+		fyne.Do(func() {
+			if err != nil && strings.TrimSpace(output) == "" {
+				dialog.ShowError(err, g.window)
+				return
+			}
+			if !tool.inserts() || err != nil {
+				g.showToolOutput(tool.Name, output)
+				return
+			}
+			current := g.editor.Text
+			if current != content {
+				cursor := runeOffset(current, g.editor.CursorRow, g.editor.CursorColumn)
+				target = TextRange{Start: cursor, End: cursor}
+			}
+			output := insertedOutput(output, current[target.Start:target.End])
+			g.editor.SetText(current[:target.Start] + output + current[target.End:])
+			cursor := target.Start + len(output)
+			g.selectRange(TextRange{Start: cursor, End: cursor})
+		})
+	}()
+}
+
+func (g *GUIApp) showToolOutput(title, output string) {
+	text := widget.NewLabel(strings.TrimRight(output, "\n"))
+	text.TextStyle = fyne.TextStyle{Monospace: true}
+	scroll := container.NewScroll(text)
+	scroll.SetMinSize(fyne.NewSize(640, 400))
+	dialog.ShowCustom(title, "Close", scroll, g.window)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestToolSelectionStaysText hands a tool a selection full of shell syntax,
+// which it has to print rather than run.
+func TestToolSelectionStaysText(t *testing.T) {
+	t.Chdir(t.TempDir())
+	selection := `it's "a" & echo pwned > pwned; $(echo pwned) %PATH% !PATH!`
+	tool := ToolConfig{Name: "echo", Command: `printf '%s|%s' $SELECTION $LINE`}
+	want := selection + "|3"
+	if runtime.GOOS == "windows" {
+		tool.Command, want = "echo $SELECTION $LINE", `"`+selection+`" "3"`
+	}
+
+	output, err := RunTool(tool, ToolContext{File: "notes.md", Selection: selection, Line: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimRight(output, "\r\n"); got != want {
+		t.Errorf("printed %q, want %q", got, want)
+	}
+	if _, err := os.Stat("pwned"); err == nil {
+		t.Error("the selection ran as a command")
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// workspaceDir keeps what belongs to a workspace rather than to the user.
+const workspaceDir = ".parselt"
+
+// trashDir is the workspace trash, in the .parselt directory of the working
+// directory, where the file browser is rooted.
+var trashDir = filepath.Join(workspaceDir, "trash")
+
+// TrashEntry is a file in the trash: Name within the trash directory, and
+// the path it was deleted from.
+type TrashEntry struct {
+	Name    string    `toml:"name"`
+	Path    string    `toml:"path"`
+	Deleted time.Time `toml:"deleted"`
+}
+
+type trashIndex struct {
+	Entries []TrashEntry `toml:"entries"`
+}
+
+func trashIndexPath() string {
+	return filepath.Join(trashDir, "trash.toml")
+}
+
+func loadTrash() (*trashIndex, error) {
+	index := &trashIndex{}
+	if _, err := os.Stat(trashIndexPath()); os.IsNotExist(err) {
+		return index, nil
+	}
+	if _, err := toml.DecodeFile(trashIndexPath(), index); err != nil {
+		return index, fmt.Errorf("error reading trash: %v", err)
+	}
+	return index, nil
+}
+
+func (t *trashIndex) save() error {
+	file, err := os.Create(trashIndexPath())
+	if err != nil {
+		return fmt.Errorf("error saving trash: %v", err)
+	}
+	defer file.Close()
+	if err := toml.NewEncoder(file).Encode(t); err != nil {
+		return fmt.Errorf("error saving trash: %v", err)
+	}
+	return nil
+}
+
+// TrashFile moves path into the workspace trash, from where RestoreTrash can
+// bring it back.
+func TrashFile(path string) error {
+	abs, err := normalizePath(path)
+	if err != nil {
+		return fmt.Errorf("error moving to trash: %v", err)
+	}
+	index, err := loadTrash()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(trashDir, 0755); err != nil {
+		return fmt.Errorf("error moving to trash: %v", err)
+	}
+	entry := TrashEntry{
+		Name:    fmt.Sprintf("%d-%s", time.Now().UnixNano(), filepath.Base(path)),
+		Path:    abs,
+		Deleted: time.Now(),
+	}
+	if err := os.Rename(path, filepath.Join(trashDir, entry.Name)); err != nil {
+		return fmt.Errorf("error moving to trash: %v", err)
+	}
+	index.Entries = append(index.Entries, entry)
+	RemoveAutosave(path)
+	return index.save()
+}
+
+// TrashEntries lists the trash, most recently deleted first.
+func TrashEntries() ([]TrashEntry, error) {
+	index, err := loadTrash()
+	if err != nil {
+		return nil, err
+	}
+	entries := index.Entries
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Deleted.After(entries[j].Deleted)
+	})
+	return entries, nil
+}
+
+// RestoreTrash moves a file in the trash back to where it was deleted from.
+// It refuses to overwrite a file that took its place since.
+func RestoreTrash(name string) (string, error) {
+	index, err := loadTrash()
+	if err != nil {
+		return "", err
+	}
+	for i, entry := range index.Entries {
+		if entry.Name != name {
+			continue
+		}
+		if _, err := os.Stat(entry.Path); err == nil {
+			return "", fmt.Errorf("error restoring %s: the file exists again", filepath.Base(entry.Path))
+		}
+		if err := os.MkdirAll(filepath.Dir(entry.Path), 0755); err != nil {
+			return "", fmt.Errorf("error restoring %s: %v", filepath.Base(entry.Path), err)
+		}
+		if err := os.Rename(filepath.Join(trashDir, entry.Name), entry.Path); err != nil {
+			return "", fmt.Errorf("error restoring %s: %v", filepath.Base(entry.Path), err)
+		}
+		index.Entries = append(index.Entries[:i], index.Entries[i+1:]...)
+		return entry.Path, index.save()
+	}
+	return "", fmt.Errorf("error restoring: %s is not in the trash", name)
+}
+
+// RenameFile moves oldPath to newPath, creating its directory, without
+// replacing anything there.
+func RenameFile(oldPath, newPath string) error {
+	if _, err := os.Stat(newPath); err == nil {
+		return fmt.Errorf("error renaming: %s already exists", newPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return fmt.Errorf("error renaming: %v", err)
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("error renaming: %v", err)
+	}
+	RemoveAutosave(oldPath)
+	// The change log goes along, when there is one
+	os.Rename(changelogPath(oldPath), changelogPath(newPath))
+	return nil
+}
+
+// openBufferFor is the buffer holding path, or -1.
+func (m *model) openBufferFor(path string) int {
+	m.stashBuffer()
+	for i, b := range m.buffers {
+		if b.filename != "" && sameFile(b.filename, path) {
+			return i
+		}
+	}
+	return -1
+}
+
+// updateBrowser handles the file operations of the file browser. It reports
+// whether msg was one of them.
+func (m *model) updateBrowser(msg tea.KeyMsg) bool {
+	item, ok := m.picker.selected()
+	switch msg.String() {
+	case "ctrl+d":
+		if !ok {
+			return true
+		}
+		path := m.browserFiles[item.index]
+		if m.openBufferFor(path) >= 0 {
+			m.status = fmt.Sprintf("Close %s before moving it to the trash", filepath.Base(path))
+			return true
+		}
+		if err := TrashFile(path); err != nil {
+			m.status = err.Error()
+			return true
+		}
+		m.overlay, m.picker = overlayNone, nil
+		m.openBrowser()
+		m.status = fmt.Sprintf("Moved %s to the trash", path)
+	case "ctrl+r":
+		if !ok {
+			return true
+		}
+		m.overlay = overlayRename
+		m.renameFrom = m.browserFiles[item.index]
+		m.renameTo = m.renameFrom
+		m.picker = nil
+	case "ctrl+t":
+		m.openTrash()
+	default:
+		return false
+	}
+	return true
+}
+
+func (m *model) openTrash() {
+	entries, err := TrashEntries()
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	if len(entries) == 0 {
+		m.overlay = overlayNone
+		m.picker = nil
+		m.status = "The trash is empty"
+		return
+	}
+	items := make([]pickerItem, len(entries))
+	for i, entry := range entries {
+		path := entry.Path
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+				path = rel
+			}
+		}
+		items[i] = pickerItem{title: path, detail: entry.Deleted.Format("Jan 2 15:04"), index: i}
+	}
+	m.trashEntries = entries
+	m.overlay = overlayTrash
+	m.picker = newPicker("Trash", items)
+	m.picker.hint = "enter: restore"
+}
+
+func (m *model) restoreTrash(entry TrashEntry) {
+	path, err := RestoreTrash(entry.Name)
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	m.status = fmt.Sprintf("Restored %s", path)
+}
+
+func (m *model) updateRename(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "esc":
+		m.overlay = overlayNone
+	case "enter":
+		m.overlay = overlayNone
+		m.renameBrowserFile(m.renameFrom, strings.TrimSpace(m.renameTo))
+	case "backspace":
+		if runes := []rune(m.renameTo); len(runes) > 0 {
+			m.renameTo = string(runes[:len(runes)-1])
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			m.renameTo += string(msg.Runes)
+		}
+	}
+}
+
+// renameBrowserFile renames a file, and the buffer that has it open.
+func (m *model) renameBrowserFile(from, to string) {
+	if to == "" || to == from {
+		return
+	}
+	i := m.openBufferFor(from)
+	if err := RenameFile(from, to); err != nil {
+		m.status = err.Error()
+		return
+	}
+	m.status = fmt.Sprintf("Renamed %s to %s", from, to)
+	if i < 0 {
+		return
+	}
+	if m.watcher != nil {
+		m.watcher.Unwatch(m.buffers[i].filename)
+		if err := m.watcher.Watch(to); err != nil {
+			m.status = err.Error()
+		}
+	}
+	if m.lockOn && !m.buffers[i].readOnly {
+		Unlock(from)
+		if _, err := LockFile(to); err != nil {
+			m.status = err.Error()
+		}
+	}
+	m.buffers[i].filename = to
+	if i == m.active {
+		m.filename = to
+	}
+}
+
+func (m model) renameView() string {
+	body := lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render("Rename File"),
+		"",
+		"From: "+m.renameFrom,
+		pickerSelectedStyle.Render("To:   "+m.renameTo+"█"),
+		"",
+		helpStyle.Render("enter: rename • esc: cancel"),
+	)
+	return pickerStyle.Render(body)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const tutorialDocument = `<!-- Parselt tutorial. The bar above the editor tells you what to do next. -->
+
+Welcome to parselt! Work through the steps shown above the editor. Each one
+is checked off as soon as you have done it, and your progress is kept when
+you quit, so you can come back later with "parselt tutorial".
+
+Write below this line:
+
+`
+
+var (
+	tutorialHeadingRe  = regexp.MustCompile(`(?m)^#{1,6} \S`)
+	tutorialStrongRe   = regexp.MustCompile(`\*\*[^*\n]+\*\*|__[^_\n]+__`)
+	tutorialListRe     = regexp.MustCompile(`(?m)^\s*([-*+]|\d+\.) \S`)
+	tutorialLinkRe     = regexp.MustCompile(`\[[^\]\n]+\]\([^)\n]+\)`)
+	tutorialDoneStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575"))
+	tutorialTodoStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#626262"))
+	tutorialTitleStyle = lipgloss.NewStyle().Bold(true)
+)
+
+type tutorialStep struct {
+	id          string
+	instruction func(k keyMap) string
+	done        func(m *model) bool
+}
+
+var tutorialSteps = []tutorialStep{
+	{
+		id:          "heading",
+		instruction: func(keyMap) string { return "Type a heading: start a line with # and a space" },
+		done:        func(m *model) bool { return tutorialHeadingRe.MatchString(m.textarea.Value()) },
+	},
+	{
+		id:          "strong",
+		instruction: func(keyMap) string { return "Make some words bold by wrapping them in **double asterisks**" },
+		done:        func(m *model) bool { return tutorialStrongRe.MatchString(m.textarea.Value()) },
+	},
+	{
+		id:          "list",
+		instruction: func(keyMap) string { return "Start a list: begin a line with - and a space" },
+		done:        func(m *model) bool { return tutorialListRe.MatchString(m.textarea.Value()) },
+	},
+	{
+		id:          "link",
+		instruction: func(keyMap) string { return "Add a link: [parselt](https://github.com/lunararch/parselt)" },
+		done:        func(m *model) bool { return tutorialLinkRe.MatchString(m.textarea.Value()) },
+	},
+	{
+		id: "preview",
+		instruction: func(k keyMap) string {
+			return fmt.Sprintf("Press %s to see the rendered document", k.preview.Help().Key)
+		},
+		done: func(m *model) bool { return m.mode == previewMode },
+	},
+	{
+		id: "edit",
+		instruction: func(k keyMap) string {
+			return fmt.Sprintf("Press %s to go back to the editor", k.edit.Help().Key)
+		},
+		done: func(m *model) bool { return m.mode == editMode },
+	},
+	{
+		id: "split",
+		instruction: func(k keyMap) string {
+			return fmt.Sprintf("Press %s to edit with a live preview next to you", k.split.Help().Key)
+		},
+		done: func(m *model) bool { return m.mode == splitMode },
+	},
+	{
+		id: "lint",
+		instruction: func(k keyMap) string {
+			return fmt.Sprintf("Press %s to check the document for problems", k.lint.Help().Key)
+		},
+		done: func(m *model) bool { return m.overlay == overlayLint || m.status == "No lint issues" },
+	},
+	{
+		id: "help",
+		instruction: func(k keyMap) string {
+			return fmt.Sprintf("Press %s to open the manual (esc closes it again)", k.help.Help().Key)
+		},
+		done: func(m *model) bool { return m.overlay == overlayHelp },
+	},
+	{
+		id: "save",
+		instruction: func(k keyMap) string {
+			return fmt.Sprintf("Press %s to save your work", k.save.Help().Key)
+		},
+		done: func(m *model) bool { return strings.HasPrefix(m.status, "Saved to ") },
+	},
+}
+
+// tutorial tracks which steps are done. Progress is stored next to the user
+// config so quitting halfway does not lose it.
+type tutorial struct {
+	path      string
+	Completed []string `toml:"completed"`
+}
+
+func loadTutorial(path string) (*tutorial, error) {
+	t := &tutorial{path: path}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return t, nil
+	}
+	if _, err := toml.DecodeFile(path, t); err != nil {
+		return t, fmt.Errorf("error reading tutorial progress: %v", err)
+	}
+	return t, nil
+}
+
+func (t *tutorial) save() error {
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return fmt.Errorf("error saving tutorial progress: %v", err)
+	}
+	file, err := os.Create(t.path)
+	if err != nil {
+		return fmt.Errorf("error saving tutorial progress: %v", err)
+	}
+	defer file.Close()
+	if err := toml.NewEncoder(file).Encode(t); err != nil {
+		return fmt.Errorf("error saving tutorial progress: %v", err)
+	}
+	return nil
+}
+
+func (t *tutorial) isDone(id string) bool {
+	for _, done := range t.Completed {
+		if done == id {
+			return true
+		}
+	}
+	return false
+}
+
+// current is the first step not done yet, or -1 once all are.
+func (t *tutorial) current() int {
+	for i, step := range tutorialSteps {
+		if !t.isDone(step.id) {
+			return i
+		}
+	}
+	return -1
+}
+
+// advance checks the current step against the model. Steps are taken in
+// order, so later steps only count once the earlier ones are done.
+func (t *tutorial) advance(m *model) {
+	i := t.current()
+	if i < 0 || !tutorialSteps[i].done(m) {
+		return
+	}
+	t.Completed = append(t.Completed, tutorialSteps[i].id)
+	if err := t.save(); err != nil {
+		m.status = err.Error()
+		return
+	}
+	if t.current() < 0 {
+		m.status = "Tutorial complete! Everything else is in the manual."
+	} else {
+		m.status = fmt.Sprintf("Step %d done", i+1)
+	}
+}
+
+func (t *tutorial) view(k keyMap, width int) string {
+	var progress strings.Builder
+	for _, step := range tutorialSteps {
+		if t.isDone(step.id) {
+			progress.WriteString(tutorialDoneStyle.Render("●"))
+		} else {
+			progress.WriteString(tutorialTodoStyle.Render("○"))
+		}
+	}
+
+	i := t.current()
+	text := "All steps done, " + k.quit.Help().Key + " quits"
+	title := "Tutorial complete"
+	if i >= 0 {
+		title = fmt.Sprintf("Step %d/%d", i+1, len(tutorialSteps))
+		text = tutorialSteps[i].instruction(k)
+	}
+	line := tutorialTitleStyle.Render(title) + " " + progress.String() + " " + text
+	return lipgloss.NewStyle().MaxWidth(width).Render(line)
+}
+
+func runTutorial(args []string) error {
+	fs := flag.NewFlagSet("tutorial", flag.ContinueOnError)
+	reset := fs.Bool("reset", false, "start over from the first step")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: parselt tutorial [-reset]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dir := stateDir()
+	t, err := loadTutorial(filepath.Join(dir, "tutorial.toml"))
+	if err != nil {
+		return err
+	}
+
+	document := filepath.Join(dir, "tutorial.md")
+	_, statErr := os.Stat(document)
+	if *reset || os.IsNotExist(statErr) {
+		t.Completed = nil
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error creating tutorial: %v", err)
+		}
+		if err := os.WriteFile(document, []byte(tutorialDocument), 0644); err != nil {
+			return fmt.Errorf("error creating tutorial: %v", err)
+		}
+		if err := t.save(); err != nil {
+			return err
+		}
+	}
+
+	app := NewTerminalApp(document)
+	app.model.tutorial = t
+	return app.Run()
+}
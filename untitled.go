@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// UntitledConfig is the [untitled] table: what an untitled document is
+// saved as. With FromHeading set it is named after its first level 1
+// heading, # Trip to Lisbon making trip-to-lisbon.md, in Directory, which
+// is relative to the working directory. The name is asked for before the
+// first save, and without such a heading the document is untitled.md.
+type UntitledConfig struct {
+	FromHeading bool   `toml:"from_heading"`
+	Directory   string `toml:"directory"`
+}
+
+func (c UntitledConfig) directory() string {
+	return expandHome(c.Directory)
+}
+
+// Path is the file to save the untitled content as, named after its first
+// level 1 heading and numbered past the files already there. It returns
+// false when the content has no such heading or the config turns naming
+// off.
+func (c UntitledConfig) Path(smp *SharedMarkdownProcessor, content string) (string, bool) {
+	if !c.FromHeading {
+		return "", false
+	}
+	for _, heading := range smp.Outline(content) {
+		if heading.Level != 1 {
+			continue
+		}
+		slug := meetingSlug(heading.Text)
+		if slug == "" {
+			return "", false
+		}
+		path := filepath.Join(c.directory(), slug+".md")
+		for n := 2; ; n++ {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				return path, true
+			}
+			path = filepath.Join(c.directory(), fmt.Sprintf("%s-%d.md", slug, n))
+		}
+	}
+	return "", false
+}
+
+// adoptFilename makes path the file of the untitled active buffer, about
+// to be saved there for the first time.
+func (m *model) adoptFilename(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error saving %s: %v", path, err)
+	}
+	m.filename = path
+	m.buffers[m.active].filename = path
+	if m.watcher != nil {
+		if err := m.watcher.Watch(path); err != nil {
+			m.status = err.Error()
+		}
+	}
+	m.lockBuffer()
+	return nil
+}
+
+// openSaveUntitled asks for the name to save the untitled active buffer
+// under, starting from path.
+func (m *model) openSaveUntitled(path string) {
+	m.overlay = overlaySaveUntitled
+	m.untitledPath = path
+}
+
+func (m *model) updateSaveUntitled(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		m.overlay = overlayNone
+	case "enter":
+		path := strings.TrimSpace(m.untitledPath)
+		if path == "" {
+			return nil
+		}
+		if !strings.Contains(filepath.Base(path), ".") {
+			path += ".md"
+		}
+		if _, err := os.Stat(path); err == nil {
+			m.status = fmt.Sprintf("%s is already there, pick another name", path)
+			return nil
+		}
+		m.overlay = overlayNone
+		if err := m.adoptFilename(path); err != nil {
+			m.status = err.Error()
+			return nil
+		}
+		return m.saveFile()
+	case "backspace":
+		if runes := []rune(m.untitledPath); len(runes) > 0 {
+			m.untitledPath = string(runes[:len(runes)-1])
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			m.untitledPath += string(msg.Runes)
+		}
+	}
+	return nil
+}
+
+func (m model) saveUntitledView() string {
+	body := lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render("Save New Document"),
+		"",
+		pickerSelectedStyle.Render("As: "+m.untitledPath+"█"),
+		"",
+		helpStyle.Render("enter: save • esc: cancel"),
+	)
+	return pickerStyle.Render(body)
+}
+
+// saveUntitledThen asks where to save the untitled document, offering the
+// name of its first heading, then saves it and runs next. It returns false
+// when there is no name to offer.
+func (g *GUIApp) saveUntitledThen(next func()) bool {
+	path, ok := g.config.Untitled.Path(g.mdProcessor, g.document())
+	if !ok {
+		return false
+	}
+	entry := widget.NewEntry()
+	entry.SetText(path)
+
+	var d *dialog.CustomDialog
+	save := widget.NewButton("Save", func() {
+		path := strings.TrimSpace(entry.Text)
+		if path == "" {
+			return
+		}
+		if _, err := os.Stat(path); err == nil {
+			dialog.ShowError(fmt.Errorf("%s is already there, pick another name", path), g.window)
+			return
+		}
+		d.Hide()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			dialog.ShowError(notWritable(path, err), g.window)
+			return
+		}
+		if abs, err := normalizePath(path); err == nil {
+			path = abs
+		}
+		g.currentFile = path
+		g.loadConfig()
+		g.watchCurrentFile()
+		g.lockCurrentFile()
+		g.updatePreview(g.editor.Text)
+		g.saveThen(next)
+	})
+	save.Importance = widget.HighImportance
+	d = dialog.NewCustomWithoutButtons("Save New Document", widget.NewForm(widget.NewFormItem("File", entry)), g.window)
+	d.SetButtons([]fyne.CanvasObject{
+		widget.NewButton("Cancel", d.Hide),
+		widget.NewButton("Choose…", func() {
+			d.Hide()
+			g.saveAsThen(next)
+		}),
+		save,
+	})
+	d.Resize(fyne.NewSize(480, 0))
+	d.Show()
+	return true
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+var varRefRe = regexp.MustCompile(`\{\{\s*var\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// ExpandVars replaces the {{var.name}} references of content with the
+// values of the vars in its front matter:
+//
+//	vars:
+//	  version: 2.1
+//	  due: =today + 14d
+//	  next: =version + 0.1
+//
+// A value starting with = is an expression, worked out with now as the
+// current time; see evalVar. References in code blocks and code spans, and
+// to vars that are not defined or cannot be worked out, are left as they
+// are. Line breaks are never added or removed.
+func ExpandVars(content string, now time.Time) string {
+	if !strings.Contains(content, "{{") {
+		return content
+	}
+	fm := ParseFrontMatter(content)
+	if fm == nil {
+		return content
+	}
+	defs, ok := fm.Fields["vars"].(map[string]any)
+	if !ok {
+		return content
+	}
+	vars := &varScope{defs: defs, plain: plainVars(fm.source), now: now, values: map[string]string{}, busy: map[string]bool{}}
+
+	lines := strings.Split(content[fm.End:], "\n")
+	fence := ""
+	for i, line := range lines {
+		if matches := fenceRe.FindStringSubmatch(line); matches != nil && (fence == "" || matches[1] == fence) {
+			if fence == "" {
+				fence = matches[1]
+			} else {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" || !strings.Contains(line, "{{") {
+			continue
+		}
+		// Between backticks is code
+		parts := strings.Split(line, "`")
+		for j := 0; j < len(parts); j += 2 {
+			parts[j] = varRefRe.ReplaceAllStringFunc(parts[j], func(ref string) string {
+				if value, err := vars.value(varRefRe.FindStringSubmatch(ref)[1]); err == nil {
+					return strings.ReplaceAll(value, "\n", " ")
+				}
+				return ref
+			})
+		}
+		lines[i] = strings.Join(parts, "`")
+	}
+	return content[:fm.End] + strings.Join(lines, "\n")
+}
+
+// plainVars returns the vars of the front matter source that are not
+// expressions, as they are written: version: 1.10 decodes to 1.1.
+func plainVars(source string) map[string]string {
+	var doc struct {
+		Vars map[string]yaml.Node `yaml:"vars"`
+	}
+	plain := map[string]string{}
+	if yaml.Unmarshal([]byte(source), &doc) != nil {
+		return plain
+	}
+	for name, node := range doc.Vars {
+		if node.Kind == yaml.ScalarNode && node.Tag != "!!null" && !strings.HasPrefix(strings.TrimSpace(node.Value), "=") {
+			plain[name] = node.Value
+		}
+	}
+	return plain
+}
+
+// varScope works out the vars of one document, each once. Plain vars are
+// expanded as written, and computed with as the values they decode to.
+type varScope struct {
+	defs   map[string]any
+	plain  map[string]string
+	now    time.Time
+	values map[string]string
+	busy   map[string]bool
+}
+
+func (s *varScope) value(name string) (string, error) {
+	if value, ok := s.values[name]; ok {
+		return value, nil
+	}
+	if text, ok := s.plain[name]; ok {
+		s.values[name] = text
+		return text, nil
+	}
+	v, err := s.eval(name)
+	if err != nil {
+		return "", err
+	}
+	s.values[name] = v.String()
+	return s.values[name], nil
+}
+
+// eval works out var name, as a value that expressions can compute with.
+func (s *varScope) eval(name string) (varValue, error) {
+	def, ok := s.defs[name]
+	if !ok {
+		return varValue{}, fmt.Errorf("no var %q", name)
+	}
+	switch v := def.(type) {
+	case int:
+		return varValue{kind: varNumber, num: float64(v)}, nil
+	case float64:
+		return varValue{kind: varNumber, num: v}, nil
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+			// A plain date, to count days with today
+			return varValue{kind: varDate, date: time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, s.now.Location())}, nil
+		}
+		return varValue{kind: varDate, date: v, hasTime: true}, nil
+	case string:
+		if expr, ok := strings.CutPrefix(strings.TrimSpace(v), "="); ok {
+			if s.busy[name] {
+				return varValue{}, fmt.Errorf("var %q refers to itself", name)
+			}
+			s.busy[name] = true
+			defer delete(s.busy, name)
+			return evalVar(expr, s)
+		}
+		return varValue{kind: varString, str: v}, nil
+	}
+	return varValue{kind: varString, str: frontMatterString(def)}, nil
+}
+
+type varKind int
+
+const (
+	varString varKind = iota
+	varNumber
+	varDate
+	varPeriod
+)
+
+// varValue is the value of an expression: a string, a number, a date, with
+// the time of day when hasTime is set, or a period of years, months and
+// days to add to a date.
+type varValue struct {
+	kind    varKind
+	str     string
+	num     float64
+	date    time.Time
+	hasTime bool
+	years   int
+	months  int
+	days    int
+}
+
+func (v varValue) String() string {
+	switch v.kind {
+	case varNumber:
+		// Fifteen digits hide the binary error: 2.1 + 0.1 is 2.2
+		num, _ := strconv.ParseFloat(strconv.FormatFloat(v.num, 'g', 15, 64), 64)
+		return strconv.FormatFloat(num, 'f', -1, 64)
+	case varDate:
+		if v.hasTime {
+			return v.date.Format("2006-01-02 15:04")
+		}
+		return v.date.Format("2006-01-02")
+	case varPeriod:
+		return fmt.Sprintf("%dy%dm%dd", v.years, v.months, v.days)
+	}
+	return v.str
+}
+
+// evalVar works out expr. It knows numbers, "strings", dates such as
+// 2024-03-01, today and now, periods such as 7d, 2w, 3m and 1y, other vars
+// by name, parentheses and + - * /. A period added to or taken from a date
+// moves it, one date taken from another is the days between them, and a
+// string added to anything joins the two.
+func evalVar(expr string, vars *varScope) (varValue, error) {
+	p := &varParser{input: expr, vars: vars}
+	v, err := p.sum()
+	if err != nil {
+		return varValue{}, err
+	}
+	if p.skipSpace(); p.pos < len(p.input) {
+		return varValue{}, fmt.Errorf("unexpected %q in %q", p.input[p.pos:], expr)
+	}
+	return v, nil
+}
+
+type varParser struct {
+	input string
+	pos   int
+	vars  *varScope
+}
+
+func (p *varParser) skipSpace() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// next consumes op when it comes next.
+func (p *varParser) next(op byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.input) && p.input[p.pos] == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *varParser) sum() (varValue, error) {
+	left, err := p.product()
+	for err == nil {
+		var right varValue
+		switch {
+		case p.next('+'):
+			if right, err = p.product(); err == nil {
+				left, err = addVars(left, right, 1)
+			}
+		case p.next('-'):
+			if right, err = p.product(); err == nil {
+				left, err = addVars(left, right, -1)
+			}
+		default:
+			return left, nil
+		}
+	}
+	return varValue{}, err
+}
+
+func (p *varParser) product() (varValue, error) {
+	left, err := p.operand()
+	for err == nil {
+		var op byte
+		switch {
+		case p.next('*'):
+			op = '*'
+		case p.next('/'):
+			op = '/'
+		default:
+			return left, nil
+		}
+		var right varValue
+		if right, err = p.operand(); err != nil {
+			break
+		}
+		if left.kind != varNumber || right.kind != varNumber {
+			return varValue{}, fmt.Errorf("%c needs two numbers", op)
+		}
+		if op == '*' {
+			left.num *= right.num
+		} else if right.num == 0 {
+			return varValue{}, fmt.Errorf("division by zero")
+		} else {
+			left.num /= right.num
+		}
+	}
+	return varValue{}, err
+}
+
+func (p *varParser) operand() (varValue, error) {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return varValue{}, fmt.Errorf("%q ends too soon", p.input)
+	}
+	start := p.pos
+	switch c := p.input[p.pos]; {
+	case c == '(':
+		p.pos++
+		v, err := p.sum()
+		if err == nil && !p.next(')') {
+			err = fmt.Errorf("missing ) in %q", p.input)
+		}
+		return v, err
+	case c == '-':
+		p.pos++
+		v, err := p.operand()
+		if err == nil && v.kind != varNumber && v.kind != varPeriod {
+			err = fmt.Errorf("- needs a number or a period")
+		}
+		v.num, v.years, v.months, v.days = -v.num, -v.years, -v.months, -v.days
+		return v, err
+	case c == '"':
+		end := strings.IndexByte(p.input[p.pos+1:], '"')
+		if end < 0 {
+			return varValue{}, fmt.Errorf("unclosed string in %q", p.input)
+		}
+		p.pos += end + 2
+		return varValue{kind: varString, str: p.input[start+1 : p.pos-1]}, nil
+	case c >= '0' && c <= '9':
+		for p.pos < len(p.input) && (isVarDigit(p.input[p.pos]) || p.input[p.pos] == '.' || p.input[p.pos] == '-') {
+			p.pos++
+		}
+		word := p.input[start:p.pos]
+		if date, err := time.ParseInLocation("2006-01-02", word, p.vars.now.Location()); err == nil {
+			return varValue{kind: varDate, date: date}, nil
+		}
+		// A minus after a number is taking away, not part of a date
+		if i := strings.IndexByte(word, '-'); i >= 0 {
+			p.pos = start + i
+			word = word[:i]
+		}
+		num, err := strconv.ParseFloat(word, 64)
+		if err != nil {
+			return varValue{}, fmt.Errorf("bad number %q", word)
+		}
+		if p.pos < len(p.input) && strings.IndexByte("dwmy", p.input[p.pos]) >= 0 && !p.identAt(p.pos+1) {
+			unit := p.input[p.pos]
+			p.pos++
+			return period(int(num), unit), nil
+		}
+		return varValue{kind: varNumber, num: num}, nil
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for p.identAt(p.pos) {
+			p.pos++
+		}
+		name := p.input[start:p.pos]
+		switch name {
+		case "today":
+			y, m, d := p.vars.now.Date()
+			return varValue{kind: varDate, date: time.Date(y, m, d, 0, 0, 0, 0, p.vars.now.Location())}, nil
+		case "now":
+			return varValue{kind: varDate, date: p.vars.now, hasTime: true}, nil
+		}
+		return p.vars.eval(name)
+	}
+	return varValue{}, fmt.Errorf("unexpected %q in %q", p.input[p.pos:], p.input)
+}
+
+func (p *varParser) identAt(i int) bool {
+	return i < len(p.input) && (p.input[i] == '_' || isVarDigit(p.input[i]) || unicode.IsLetter(rune(p.input[i])))
+}
+
+func isVarDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func period(n int, unit byte) varValue {
+	v := varValue{kind: varPeriod}
+	switch unit {
+	case 'd':
+		v.days = n
+	case 'w':
+		v.days = 7 * n
+	case 'm':
+		v.months = n
+	case 'y':
+		v.years = n
+	}
+	return v
+}
+
+// addVars adds right to left, or takes it away when sign is -1.
+func addVars(left, right varValue, sign int) (varValue, error) {
+	switch {
+	case sign > 0 && (left.kind == varString || right.kind == varString):
+		return varValue{kind: varString, str: left.String() + right.String()}, nil
+	case left.kind == varNumber && right.kind == varNumber:
+		left.num += float64(sign) * right.num
+		return left, nil
+	case left.kind == varDate && right.kind == varPeriod:
+		left.date = left.date.AddDate(sign*right.years, sign*right.months, sign*right.days)
+		return left, nil
+	case sign > 0 && left.kind == varPeriod && right.kind == varDate:
+		return addVars(right, left, 1)
+	case left.kind == varPeriod && right.kind == varPeriod:
+		left.years += sign * right.years
+		left.months += sign * right.months
+		left.days += sign * right.days
+		return left, nil
+	case sign < 0 && left.kind == varDate && right.kind == varDate:
+		days := left.date.Sub(right.date).Hours() / 24
+		return varValue{kind: varNumber, num: math.Round(days)}, nil
+	}
+	op := "+"
+	if sign < 0 {
+		op = "-"
+	}
+	return varValue{}, fmt.Errorf("cannot compute %s %s %s", left, op, right)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce lets an external save finish before the file is read;
+// editors often truncate and write in several steps.
+const watchDebounce = 100 * time.Millisecond
+
+// FileWatcher reports changes to open files made by other programs. It
+// watches the directories rather than the files themselves so that editors
+// which save by renaming a new file over the old one are noticed too.
+type FileWatcher struct {
+	watcher *fsnotify.Watcher
+	changes chan string
+	done    chan struct{}
+
+	mu    sync.Mutex
+	files map[string]bool
+}
+
+func NewFileWatcher() (*FileWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("error watching files: %v", err)
+	}
+	w := &FileWatcher{
+		watcher: watcher,
+		changes: make(chan string),
+		done:    make(chan struct{}),
+		files:   map[string]bool{},
+	}
+	go w.run()
+	return w, nil
+}
+
+// Changes delivers the absolute path of every watched file that changed.
+func (w *FileWatcher) Changes() <-chan string {
+	return w.changes
+}
+
+func (w *FileWatcher) Watch(path string) error {
+	if path == "" {
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("error watching %s: %v", path, err)
+	}
+	w.mu.Lock()
+	w.files[abs] = true
+	w.mu.Unlock()
+	if err := w.watcher.Add(filepath.Dir(abs)); err != nil {
+		return fmt.Errorf("error watching %s: %v", path, err)
+	}
+	return nil
+}
+
+// Unwatch stops reporting changes to path, and stops watching its directory
+// when no other watched file is left in it.
+func (w *FileWatcher) Unwatch(path string) {
+	abs, err := filepath.Abs(path)
+	if path == "" || err != nil {
+		return
+	}
+	w.mu.Lock()
+	delete(w.files, abs)
+	for file := range w.files {
+		if filepath.Dir(file) == filepath.Dir(abs) {
+			w.mu.Unlock()
+			return
+		}
+	}
+	w.mu.Unlock()
+	w.watcher.Remove(filepath.Dir(abs))
+}
+
+func (w *FileWatcher) Close() {
+	close(w.done)
+	w.watcher.Close()
+}
+
+func (w *FileWatcher) watching(path string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.files[path]
+}
+
+func (w *FileWatcher) run() {
+	pending := map[string]*time.Timer{}
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			path := filepath.Clean(event.Name)
+			if event.Op == fsnotify.Chmod || !w.watching(path) {
+				continue
+			}
+			if timer := pending[path]; timer != nil {
+				timer.Stop()
+			}
+			pending[path] = time.AfterFunc(watchDebounce, func() {
+				select {
+				case w.changes <- path:
+				case <-w.done:
+				}
+			})
+		case _, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// externalChange reads a file that changed on disk and reports whether its
+// content is news to the editor, which is not the case after an own save.
+func externalChange(path, saved, current string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	disk := string(data)
+	return disk, disk != saved && disk != current
+}
+
+type fileChangedMsg struct {
+	path string
+}
+
+func (m model) waitForChange() tea.Cmd {
+	if m.watcher == nil {
+		return nil
+	}
+	changes := m.watcher.Changes()
+	return func() tea.Msg {
+		return fileChangedMsg{path: <-changes}
+	}
+}
+
+// handleFileChange reloads a buffer that changed on disk when it has no
+// unsaved changes, and otherwise asks whether to reload or keep the edits.
+func (m *model) handleFileChange(path string) {
+	m.stashBuffer()
+	prompt := -1
+	for i, b := range m.buffers {
+		if b.filename == "" || !sameFile(b.filename, path) {
+			continue
+		}
+		disk, changed := externalChange(path, b.saved, b.document())
+		switch {
+		case !changed:
+			// An own save, or the editor already has this content
+			if disk == b.document() {
+				m.buffers[i].saved = disk
+			}
+		case !b.dirty():
+			m.buffers[i].narrow = nil
+			m.buffers[i].textarea.SetValue(disk)
+			m.buffers[i].history.Record(m.buffers[i].textarea.Value())
+			m.buffers[i].saved = disk
+			m.status = fmt.Sprintf("Reloaded %s", b.name())
+		default:
+			prompt = i
+			m.reload = disk
+		}
+	}
+	m.loadBuffer(m.active)
+
+	if prompt < 0 {
+		return
+	}
+	if m.overlay != overlayNone {
+		m.status = fmt.Sprintf("%s changed on disk", m.buffers[prompt].name())
+		return
+	}
+	m.switchBuffer(prompt)
+	m.overlay = overlayReload
+}
+
+func (m *model) updateReload(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "r", "y", "enter":
+		m.narrow = nil
+		m.textarea.SetValue(m.reload)
+		m.status = fmt.Sprintf("Reloaded %s", filepath.Base(m.filename))
+	case "k", "n", "esc":
+		m.status = "Kept your changes; saving will overwrite the file on disk"
+	default:
+		return
+	}
+	// Either way the editor now knows what is on disk
+	m.saved = m.reload
+	m.content = m.textarea.Value()
+	if m.mode != editMode {
+		m.refreshPreview()
+	}
+	m.overlay = overlayNone
+	m.reload = ""
+}
+
+func (m model) reloadView() string {
+	body := lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render("File Changed on Disk"),
+		"",
+		fmt.Sprintf("%s was changed by another program and has unsaved changes here.", filepath.Base(m.filename)),
+		"",
+		helpStyle.Render("r: reload from disk • k: keep my changes"),
+	)
+	return pickerStyle.Render(body)
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/charmbracelet/x/term"
+)
+
+// workspaceTop is how many notes the largest and most linked lists hold.
+const workspaceTop = 10
+
+// WorkspaceStats sums up the notes below a directory: how many there are
+// and how much they hold, which notes nothing links to, which links lead
+// nowhere, and which notes are largest and most linked to. Paths are
+// relative to Root.
+type WorkspaceStats struct {
+	Root          string       `json:"root"`
+	Files         int          `json:"files"`
+	Words         int          `json:"words"`
+	Links         int          `json:"links"`
+	ExternalLinks int          `json:"external_links"`
+	Orphans       []string     `json:"orphans"`
+	BrokenLinks   []BrokenLink `json:"broken_links"`
+	Largest       []NoteCount  `json:"largest"`
+	MostLinked    []NoteCount  `json:"most_linked"`
+}
+
+// BrokenLink is a link on Line of File to a local Target that is not there.
+type BrokenLink struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Target string `json:"target"`
+}
+
+// NoteCount is a note with its words, or the links to it.
+type NoteCount struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+}
+
+// workspaceNote is a note found by ScanWorkspace, with where it links to.
+type workspaceNote struct {
+	path    string
+	words   int
+	targets []noteLink
+}
+
+type noteLink struct {
+	line   int
+	target string
+	path   string
+}
+
+// workspaceFiles lists the markdown and org files below root, skipping
+// hidden directories as the file browser does.
+func workspaceFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".md", ".markdown", ".org":
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// noteLinks finds the links of content, a note at path, outside its code
+// blocks: markdown links and wiki links, with the file each one points to,
+// or no path for URLs and links within the note.
+func noteLinks(content, path string, byName map[string]string) []noteLink {
+	lines := strings.Split(content, "\n")
+	for _, fence := range codeFences(content) {
+		for i := fence.Open; i <= fence.Close && i < len(lines); i++ {
+			lines[i] = ""
+		}
+	}
+	var links []noteLink
+	for row, line := range lines {
+		for _, m := range peekLinkRe.FindAllStringSubmatchIndex(line, -1) {
+			if m[0] > 0 && line[m[0]-1] == '!' {
+				continue
+			}
+			dest := linkDestination(line[m[4]:m[5]])
+			link := noteLink{line: row + 1, target: dest}
+			file, _, _ := strings.Cut(dest, "#")
+			if file != "" && !isURL(dest) {
+				if unescaped, err := url.PathUnescape(file); err == nil {
+					file = unescaped
+				}
+				link.path = peekPath(file, path)
+			}
+			if dest != "" {
+				links = append(links, link)
+			}
+		}
+		for _, m := range peekWikiRe.FindAllStringSubmatch(line, -1) {
+			target, _, _ := strings.Cut(m[1], "|")
+			page, _, _ := strings.Cut(target, "#")
+			link := noteLink{line: row + 1, target: strings.TrimSpace(target)}
+			if page = strings.TrimSpace(page); page != "" {
+				name := page
+				if filepath.Ext(name) == "" {
+					name += ".md"
+				}
+				link.path = peekPath(name, path)
+				if _, err := os.Stat(link.path); err != nil {
+					if found, ok := byName[strings.ToLower(filepath.Base(name))]; ok {
+						link.path = found
+					}
+				}
+			}
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// ScanWorkspace reads every note below root and sums them up.
+func ScanWorkspace(smp *SharedMarkdownProcessor, root string) (WorkspaceStats, error) {
+	stats := WorkspaceStats{Root: root, Orphans: []string{}, BrokenLinks: []BrokenLink{}}
+	files, err := workspaceFiles(root)
+	if err != nil {
+		return stats, fmt.Errorf("error reading %s: %v", root, err)
+	}
+	byName := map[string]string{}
+	for _, file := range files {
+		if _, taken := byName[strings.ToLower(filepath.Base(file))]; !taken {
+			byName[strings.ToLower(filepath.Base(file))] = file
+		}
+	}
+
+	var notes []workspaceNote
+	for _, file := range files {
+		content, err := readDocument(file)
+		if err != nil {
+			continue
+		}
+		if isOrgFile(file) {
+			content = OrgToMarkdown(content)
+		}
+		notes = append(notes, workspaceNote{
+			path:    file,
+			words:   smp.Stats(content).Words,
+			targets: noteLinks(content, file, byName),
+		})
+	}
+
+	rel := func(path string) string {
+		if r, err := filepath.Rel(root, path); err == nil {
+			return filepath.ToSlash(r)
+		}
+		return path
+	}
+	index := map[string]int{}
+	for i, note := range notes {
+		if abs, err := normalizePath(note.path); err == nil {
+			index[abs] = i
+		}
+	}
+	incoming := make([]int, len(notes))
+	stats.Files = len(notes)
+	for i, note := range notes {
+		stats.Words += note.words
+		for _, link := range note.targets {
+			stats.Links++
+			if link.path == "" {
+				if isURL(link.target) {
+					stats.ExternalLinks++
+				}
+				continue
+			}
+			abs, err := normalizePath(link.path)
+			if err != nil {
+				continue
+			}
+			if j, ok := index[abs]; ok {
+				if j != i {
+					incoming[j]++
+				}
+				continue
+			}
+			if _, err := os.Stat(link.path); err != nil {
+				stats.BrokenLinks = append(stats.BrokenLinks, BrokenLink{File: rel(note.path), Line: link.line, Target: link.target})
+			}
+		}
+	}
+
+	largest, linked := []NoteCount{}, []NoteCount{}
+	for i, note := range notes {
+		largest = append(largest, NoteCount{Path: rel(note.path), Count: note.words})
+		if incoming[i] == 0 {
+			stats.Orphans = append(stats.Orphans, rel(note.path))
+		} else {
+			linked = append(linked, NoteCount{Path: rel(note.path), Count: incoming[i]})
+		}
+	}
+	sort.SliceStable(largest, func(i, j int) bool { return largest[i].Count > largest[j].Count })
+	sort.SliceStable(linked, func(i, j int) bool { return linked[i].Count > linked[j].Count })
+	stats.Largest = largest[:min(len(largest), workspaceTop)]
+	stats.MostLinked = linked[:min(len(linked), workspaceTop)]
+	return stats, nil
+}
+
+// Rows lists the totals with their labels, for the report and dashboard.
+func (s WorkspaceStats) Rows() [][2]string {
+	return [][2]string{
+		{"Notes", fmt.Sprint(s.Files)},
+		{"Words", fmt.Sprint(s.Words)},
+		{"Links", fmt.Sprint(s.Links)},
+		{"External links", fmt.Sprint(s.ExternalLinks)},
+		{"Orphan notes", fmt.Sprint(len(s.Orphans))},
+		{"Broken links", fmt.Sprint(len(s.BrokenLinks))},
+	}
+}
+
+// Report is the statistics as a markdown document.
+func (s WorkspaceStats) Report() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Statistics of %s\n\n| Total | |\n|---|--:|\n", s.Root)
+	for _, row := range s.Rows() {
+		fmt.Fprintf(&b, "| %s | %s |\n", row[0], row[1])
+	}
+	counts := func(title, unit string, notes []NoteCount) {
+		if len(notes) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n", title)
+		for i, note := range notes {
+			counted := unit
+			if note.Count == 1 {
+				counted = strings.TrimSuffix(unit, "s")
+			}
+			fmt.Fprintf(&b, "%d. %s — %d %s\n", i+1, note.Path, note.Count, counted)
+		}
+	}
+	counts("Largest Notes", "words", s.Largest)
+	counts("Most Linked Notes", "links", s.MostLinked)
+	if len(s.BrokenLinks) > 0 {
+		b.WriteString("\n## Broken Links\n\n")
+		for _, link := range s.BrokenLinks {
+			fmt.Fprintf(&b, "- %s:%d → `%s`\n", link.File, link.Line, link.Target)
+		}
+	}
+	if len(s.Orphans) > 0 {
+		b.WriteString("\n## Orphan Notes\n\nNo other note links to these.\n\n")
+		for _, path := range s.Orphans {
+			fmt.Fprintf(&b, "- %s\n", path)
+		}
+	}
+	return b.String()
+}
+
+// printWorkspaceStats prints the statistics of the notes below root, for
+// parselt stats: rendered on a terminal, as markdown when piped, or as JSON.
+func printWorkspaceStats(root string, asJSON bool) error {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	smp := cfg.Processor()
+	stats, err := ScanWorkspace(smp, root)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	report := stats.Report()
+	if stat, err := os.Stdout.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 {
+		width := 80
+		if w, _, err := term.GetSize(os.Stdout.Fd()); err == nil && w > 0 {
+			width = w
+		}
+		report = smp.RenderTerminal(report, width)
+	}
+	fmt.Print(report)
+	return nil
+}
+
+// showWorkspaceStats shows the statistics of the notes below the working
+// directory; choosing a note in one of the lists opens it.
+func (g *GUIApp) showWorkspaceStats() {
+	stats, err := ScanWorkspace(g.mdProcessor, ".")
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+	if stats.Files == 0 {
+		dialog.ShowInformation("Workspace Statistics", "There are no notes in the working directory.", g.window)
+		return
+	}
+
+	var d dialog.Dialog
+	open := func(path string, line int) {
+		d.Hide()
+		g.confirmDiscard(func() { g.openPath(filepath.FromSlash(path), Location{Line: line}) })
+	}
+	list := func(n int, label func(i int) string, chosen func(i int)) fyne.CanvasObject {
+		if n == 0 {
+			return widget.NewLabel("None.")
+		}
+		l := widget.NewList(
+			func() int { return n },
+			func() fyne.CanvasObject { return widget.NewLabel("") },
+			func(id widget.ListItemID, obj fyne.CanvasObject) { obj.(*widget.Label).SetText(label(id)) },
+		)
+		l.OnSelected = func(id widget.ListItemID) { chosen(id) }
+		return l
+	}
+
+	totals := container.New(layout.NewFormLayout())
+	for _, row := range stats.Rows() {
+		value := widget.NewLabel(row[1])
+		value.Alignment = fyne.TextAlignTrailing
+		totals.Add(widget.NewLabelWithStyle(row[0], fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		totals.Add(value)
+	}
+	tabs := container.NewAppTabs(
+		container.NewTabItem("Largest", list(len(stats.Largest),
+			func(i int) string { return fmt.Sprintf("%s   %d words", stats.Largest[i].Path, stats.Largest[i].Count) },
+			func(i int) { open(stats.Largest[i].Path, 0) })),
+		container.NewTabItem("Most Linked", list(len(stats.MostLinked),
+			func(i int) string {
+				return fmt.Sprintf("%s   %d links", stats.MostLinked[i].Path, stats.MostLinked[i].Count)
+			},
+			func(i int) { open(stats.MostLinked[i].Path, 0) })),
+		container.NewTabItem("Broken Links", list(len(stats.BrokenLinks),
+			func(i int) string {
+				link := stats.BrokenLinks[i]
+				return fmt.Sprintf("%s:%d   %s", link.File, link.Line, link.Target)
+			},
+			func(i int) { open(stats.BrokenLinks[i].File, stats.BrokenLinks[i].Line) })),
+		container.NewTabItem("Orphans", list(len(stats.Orphans),
+			func(i int) string { return stats.Orphans[i] },
+			func(i int) { open(stats.Orphans[i], 0) })),
+	)
+	d = dialog.NewCustom("Workspace Statistics", "Close", container.NewBorder(totals, nil, nil, nil, tabs), g.window)
+	d.Resize(fyne.NewSize(640, 520))
+	d.Show()
+}